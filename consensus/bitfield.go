@@ -0,0 +1,61 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package consensus
+
+import "math/bits"
+
+// A bitField is a set of terminals.
+type bitField []uint64
+
+func newBitField(size int) bitField {
+	return make(bitField, (size+63)/64)
+}
+
+func (bf bitField) set(i int) {
+	bf[i/64] |= 1 << uint(i%64)
+}
+
+func (bf bitField) isSet(i int) bool {
+	return bf[i/64]&(1<<uint(i%64)) != 0
+}
+
+func (bf bitField) or(o bitField) {
+	for i := range bf {
+		bf[i] |= o[i]
+	}
+}
+
+// Count returns the number of elements
+// in the set.
+func (bf bitField) count() int {
+	n := 0
+	for _, v := range bf {
+		n += bits.OnesCount64(v)
+	}
+	return n
+}
+
+// Contains returns true
+// if all the elements of o are in bf.
+func (bf bitField) contains(o bitField) bool {
+	for i := range bf {
+		if bf[i]&o[i] != o[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Key returns a string
+// that identifies the set.
+func (bf bitField) key() string {
+	b := make([]byte, 0, len(bf)*8)
+	for _, v := range bf {
+		for j := uint(0); j < 64; j += 8 {
+			b = append(b, byte(v>>j))
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,378 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package consensus implements consensus trees
+// from a collection of trees.
+package consensus
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+// A Clade is a group of terminals
+// found on one or more trees.
+type Clade struct {
+	Terms []string // Terminals of the clade
+	Count int      // Number of trees with the clade
+	Len   float64  // Sum of the branch lengths of the clade
+
+	// Support values,
+	// from the labels of the trees
+	Support  float64 // Sum of the supports of the clade
+	Supports int     // Number of trees with a support for the clade
+
+	lenSq float64 // sum of the squared branch lengths
+	set   bitField
+}
+
+// Size returns the number of terminals in the clade.
+func (c *Clade) Size() int {
+	return len(c.Terms)
+}
+
+// A Set is a set of clades
+// counted from a tree collection.
+//
+// As a Set stores only the clades,
+// trees can be added one at a time,
+// so large tree collections
+// do not need to be kept in memory.
+type Set struct {
+	// If Unique is true,
+	// repeated topologies
+	// (e.g. the same tree found in different replicates
+	// of a search)
+	// are added only once.
+	// To keep the memory bounded,
+	// only a hash of each topology is stored,
+	// so there is a very small chance
+	// that a new topology is taken as repeated.
+	Unique bool
+
+	terms  []string
+	idx    map[string]int
+	clades map[string]*Clade
+	trees  int
+	hashes map[uint64]bool
+}
+
+// Trees returns the number of trees
+// added to the set.
+func (s *Set) Trees() int {
+	return s.trees
+}
+
+// Terms returns the terminals of the trees in the set.
+func (s *Set) Terms() []string {
+	return s.terms
+}
+
+// Add adds the clades of a tree
+// into the set.
+// All trees must have the same terminals.
+// If the set is Unique,
+// and the topology of the tree
+// is already in the set,
+// the tree is ignored.
+func (s *Set) Add(t *tree.Tree) error {
+	terms := t.Terms()
+	if s.idx == nil {
+		s.terms = append([]string{}, terms...)
+		sort.Strings(s.terms)
+		s.idx = make(map[string]int, len(s.terms))
+		for i, nm := range s.terms {
+			if _, ok := s.idx[nm]; ok {
+				return errors.Errorf("consensus: terminal %s repeated", nm)
+			}
+			s.idx[nm] = i
+		}
+		s.clades = make(map[string]*Clade)
+	}
+	if len(terms) != len(s.terms) {
+		return errors.Errorf("consensus: tree %d: %d terminals, want %d", s.trees+1, len(terms), len(s.terms))
+	}
+	for _, nm := range terms {
+		if _, ok := s.idx[nm]; !ok {
+			return errors.Errorf("consensus: tree %d: terminal %s not in previous trees", s.trees+1, nm)
+		}
+	}
+
+	if s.Unique {
+		if s.hashes == nil {
+			s.hashes = make(map[uint64]bool)
+		}
+		h := t.Hash()
+		if s.hashes[h] {
+			return nil
+		}
+		s.hashes[h] = true
+	}
+
+	s.trees++
+	s.add(t.Root, t.Root)
+	return nil
+}
+
+// Add adds the clades of a node
+// and its descendants.
+func (s *Set) add(n, root *tree.Node) bitField {
+	bf := newBitField(len(s.terms))
+	if n.IsTerm() {
+		bf.set(s.idx[n.Name])
+	} else {
+		for _, d := range n.Desc {
+			bf.or(s.add(d, root))
+		}
+	}
+	if n == root {
+		return bf
+	}
+	k := bf.key()
+	c, ok := s.clades[k]
+	if !ok {
+		c = &Clade{set: bf}
+		for i, nm := range s.terms {
+			if bf.isSet(i) {
+				c.Terms = append(c.Terms, nm)
+			}
+		}
+		s.clades[k] = c
+	}
+	c.Count++
+	c.Len += n.Len
+	c.lenSq += n.Len * n.Len
+	if v, err := strconv.ParseFloat(n.Label, 64); err == nil {
+		c.Support += v
+		c.Supports++
+	}
+	return bf
+}
+
+// Clades returns the clades found in the set,
+// with the frequency greater than the indicated value,
+// sorted by its frequency.
+// Clades of a single terminal are excluded.
+func (s *Set) Clades(freq float64) []*Clade {
+	var ls []*Clade
+	for _, c := range s.clades {
+		if c.Size() < 2 {
+			continue
+		}
+		if s.Freq(c) <= freq {
+			continue
+		}
+		ls = append(ls, c)
+	}
+	sort.Slice(ls, func(i, j int) bool {
+		if ls[i].Count != ls[j].Count {
+			return ls[i].Count > ls[j].Count
+		}
+		if ls[i].Size() != ls[j].Size() {
+			return ls[i].Size() > ls[j].Size()
+		}
+		return ls[i].set.key() < ls[j].set.key()
+	})
+	return ls
+}
+
+// Freq returns the frequency of a clade.
+func (s *Set) Freq(c *Clade) float64 {
+	if s.trees == 0 {
+		return 0
+	}
+	return float64(c.Count) / float64(s.trees)
+}
+
+// Support returns the frequency
+// of the clade formed by the indicated terminals.
+// The terminals must be in the trees of the set.
+func (s *Set) Support(terms []string) (float64, error) {
+	if s.trees == 0 {
+		return 0, nil
+	}
+	bf := newBitField(len(s.terms))
+	for _, nm := range terms {
+		i, ok := s.idx[nm]
+		if !ok {
+			return 0, errors.Errorf("consensus: terminal %s not in trees", nm)
+		}
+		bf.set(i)
+	}
+	if bf.count() == len(s.terms) {
+		return 1, nil
+	}
+	c, ok := s.clades[bf.key()]
+	if !ok {
+		return 0, nil
+	}
+	return s.Freq(c), nil
+}
+
+// Majority returns the majority rule consensus tree,
+// i.e. a tree with all the clades
+// found in more than the given proportion of trees.
+// The cutoff must be at least 0.5.
+// Each clade is labeled with its frequency,
+// and branch lengths are the average
+// over the trees that have the clade.
+func (s *Set) Majority(cutoff float64) (*tree.Tree, error) {
+	if cutoff < 0.5 {
+		return nil, errors.Errorf("consensus: majority: cutoff %.3f, want at least 0.5", cutoff)
+	}
+	if s.trees == 0 {
+		return nil, errors.New("consensus: majority: empty tree set")
+	}
+	return s.build(s.Clades(cutoff), true, false), nil
+}
+
+// Annotated returns a majority rule consensus tree
+// (as in Majority)
+// in which each node is annotated,
+// with a comment in the format used by FigTree,
+// with the frequency of the clade (freq),
+// and the mean and standard deviation
+// of its branch length (length_mean, and length_sd),
+// and the mean of its support values
+// (support_mean),
+// over the trees that have the clade.
+// Support values are read from the labels
+// of the internal nodes of the trees.
+// If the clade has support values,
+// the clade is labeled with its mean support,
+// otherwise it is labeled with its frequency.
+func (s *Set) Annotated(cutoff float64) (*tree.Tree, error) {
+	if cutoff < 0.5 {
+		return nil, errors.Errorf("consensus: annotated: cutoff %.3f, want at least 0.5", cutoff)
+	}
+	if s.trees == 0 {
+		return nil, errors.New("consensus: annotated: empty tree set")
+	}
+	return s.build(s.Clades(cutoff), true, true), nil
+}
+
+// Strict returns the strict consensus tree,
+// i.e. a tree with the clades
+// found in all the trees.
+// Branch lengths are the average
+// over all the trees.
+func (s *Set) Strict() (*tree.Tree, error) {
+	if s.trees == 0 {
+		return nil, errors.New("consensus: strict: empty tree set")
+	}
+	var clades []*Clade
+	for _, c := range s.Clades(0) {
+		if c.Count == s.trees {
+			clades = append(clades, c)
+		}
+	}
+	return s.build(clades, false, false), nil
+}
+
+// Build builds a tree
+// from a list of compatible clades.
+// If annot is true,
+// the nodes are annotated
+// with the clade statistics.
+func (s *Set) build(clades []*Clade, label, annot bool) *tree.Tree {
+	sort.SliceStable(clades, func(i, j int) bool {
+		return clades[i].Size() > clades[j].Size()
+	})
+
+	t := &tree.Tree{Root: &tree.Node{}, Lens: true}
+	sets := map[*tree.Node]bitField{
+		t.Root: newBitField(len(s.terms)),
+	}
+	deepest := make([]*tree.Node, len(s.terms))
+	for i, nm := range s.terms {
+		n := &tree.Node{Name: nm}
+		if c, ok := s.clades[singleKey(len(s.terms), i)]; ok && c.Count > 0 {
+			n.Len = c.Len / float64(c.Count)
+			if annot {
+				n.Comment = s.annotation(c)
+			}
+		}
+		t.Root.Add(n)
+		sets[t.Root].set(i)
+		deepest[i] = t.Root
+	}
+
+	for _, c := range clades {
+		var first int
+		for i := range s.terms {
+			if c.set.isSet(i) {
+				first = i
+				break
+			}
+		}
+		p := deepest[first]
+		n := &tree.Node{
+			Len: c.Len / float64(c.Count),
+		}
+		if label {
+			n.Label = fmt.Sprintf("%.2f", s.Freq(c))
+		}
+		if annot {
+			n.Comment = s.annotation(c)
+			if c.Supports > 0 {
+				n.Label = strconv.FormatFloat(c.Support/float64(c.Supports), 'f', -1, 64)
+			}
+		}
+		sets[n] = c.set
+		var desc []*tree.Node
+		for _, d := range p.Desc {
+			if d.IsTerm() {
+				if c.set.isSet(s.idx[d.Name]) {
+					n.Add(d)
+					continue
+				}
+			} else if c.set.contains(sets[d]) {
+				n.Add(d)
+				continue
+			}
+			desc = append(desc, d)
+		}
+		p.Desc = append(desc, n)
+		n.Anc = p
+		for i := range s.terms {
+			if c.set.isSet(i) {
+				deepest[i] = n
+			}
+		}
+	}
+	return t
+}
+
+// Annotation returns the annotation of a clade.
+func (s *Set) annotation(c *Clade) string {
+	mean := c.Len / float64(c.Count)
+	v := c.lenSq/float64(c.Count) - mean*mean
+	if v < 0 {
+		v = 0
+	}
+	ann := []string{
+		fmt.Sprintf("freq=%.4f", s.Freq(c)),
+		fmt.Sprintf("length_mean=%.6f", mean),
+		fmt.Sprintf("length_sd=%.6f", math.Sqrt(v)),
+	}
+	if c.Supports > 0 {
+		ann = append(ann, fmt.Sprintf("support_mean=%.4f", c.Support/float64(c.Supports)))
+	}
+	return "&" + strings.Join(ann, ",")
+}
+
+// SingleKey returns the key of a clade
+// with a single terminal.
+func singleKey(size, term int) string {
+	bf := newBitField(size)
+	bf.set(term)
+	return bf.key()
+}
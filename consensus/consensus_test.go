@@ -0,0 +1,203 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package consensus
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/tree"
+)
+
+var treesBlob = `
+(A,(B,(C,(D,E))));
+(A,(B,(D,(C,E))));
+(A,(C,(B,(D,E))));
+(A,(B,(C,(D,E))));
+`
+
+func readSet(t *testing.T, blob string) *Set {
+	s := &Set{}
+	r := tree.NewReader(strings.NewReader(blob))
+	for r.Scan() {
+		if err := s.Add(r.Tree()); err != nil {
+			t.Fatalf("consensus: unexpected error: %v", err)
+		}
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("consensus: unexpected error: %v", err)
+	}
+	return s
+}
+
+func TestMajority(t *testing.T) {
+	s := readSet(t, treesBlob)
+	if s.Trees() != 4 {
+		t.Errorf("consensus: majority: %d trees, want %d", s.Trees(), 4)
+	}
+	mj, err := s.Majority(0.5)
+	if err != nil {
+		t.Fatalf("consensus: majority: unexpected error: %v", err)
+	}
+	mj.Lens = false
+	var b bytes.Buffer
+	mj.Write(&b, true)
+	if want := "(A,(B,(C,(D,E)0.75)0.75)1.00);"; b.String() != want {
+		t.Errorf("consensus: majority: tree %s, want %s", b.String(), want)
+	}
+
+	mj, err = s.Majority(0.8)
+	if err != nil {
+		t.Fatalf("consensus: majority: unexpected error: %v", err)
+	}
+	mj.Lens = false
+	b.Reset()
+	mj.Write(&b, true)
+	if want := "(A,(B,C,D,E)1.00);"; b.String() != want {
+		t.Errorf("consensus: majority: cutoff 0.8: tree %s, want %s", b.String(), want)
+	}
+	if _, err := s.Majority(0.4); err == nil {
+		t.Errorf("consensus: majority: expecting error on cutoff 0.4")
+	}
+
+	cl := s.Clades(0.5)
+	if len(cl) != 3 {
+		t.Errorf("consensus: majority: %d clades, want %d", len(cl), 3)
+	}
+
+	if err := s.Add(&tree.Tree{Root: &tree.Node{Name: "A"}}); err == nil {
+		t.Errorf("consensus: majority: expecting error on different terminals")
+	}
+}
+
+func TestUnique(t *testing.T) {
+	s := &Set{Unique: true}
+	r := tree.NewReader(strings.NewReader(treesBlob + "(((E,D),C),B,A);\n"))
+	for r.Scan() {
+		if err := s.Add(r.Tree()); err != nil {
+			t.Fatalf("consensus: unique: unexpected error: %v", err)
+		}
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("consensus: unique: unexpected error: %v", err)
+	}
+	if s.Trees() != 4 {
+		t.Errorf("consensus: unique: %d trees, want %d", s.Trees(), 4)
+	}
+}
+
+func TestStrict(t *testing.T) {
+	s := readSet(t, treesBlob)
+	st, err := s.Strict()
+	if err != nil {
+		t.Fatalf("consensus: strict: unexpected error: %v", err)
+	}
+	st.Lens = false
+	var b bytes.Buffer
+	st.Write(&b, true)
+	if want := "(A,(B,C,D,E));"; b.String() != want {
+		t.Errorf("consensus: strict: tree %s, want %s", b.String(), want)
+	}
+
+	if _, err := (&Set{}).Strict(); err == nil {
+		t.Errorf("consensus: strict: expecting error on empty set")
+	}
+}
+
+func TestSupport(t *testing.T) {
+	s := readSet(t, treesBlob)
+	tests := []struct {
+		terms []string
+		want  float64
+	}{
+		{[]string{"D", "E"}, 0.75},
+		{[]string{"E", "C", "D"}, 0.75},
+		{[]string{"C", "E"}, 0.25},
+		{[]string{"A", "B"}, 0},
+		{[]string{"A", "B", "C", "D", "E"}, 1},
+	}
+	for _, test := range tests {
+		f, err := s.Support(test.terms)
+		if err != nil {
+			t.Errorf("consensus: support: %v: unexpected error: %v", test.terms, err)
+			continue
+		}
+		if f != test.want {
+			t.Errorf("consensus: support: %v: %.3f, want %.3f", test.terms, f, test.want)
+		}
+	}
+	if _, err := s.Support([]string{"A", "X"}); err == nil {
+		t.Errorf("consensus: support: expecting error on unknown terminal")
+	}
+}
+
+var roguesBlob = `
+(A,(B,(C,(D,(E,(F,X))))));
+(A,((B,X),(C,(D,(E,F)))));
+(A,(B,(C,((D,X),(E,F)))));
+(A,(X,(B,(C,(D,(E,F))))));
+`
+
+func TestRogues(t *testing.T) {
+	var trees []*tree.Tree
+	r := tree.NewReader(strings.NewReader(roguesBlob))
+	for r.Scan() {
+		trees = append(trees, r.Tree())
+	}
+	rogues, err := Rogues(trees, 3)
+	if err != nil {
+		t.Fatalf("consensus: rogues: unexpected error: %v", err)
+	}
+	if len(rogues) != 1 || rogues[0] != "X" {
+		t.Errorf("consensus: rogues: %v, want %v", rogues, []string{"X"})
+	}
+	if tp := trees[0]; len(tp.Terms()) != 7 {
+		t.Errorf("consensus: rogues: input trees modified")
+	}
+}
+
+func TestAnnotated(t *testing.T) {
+	s := readSet(t, `
+(A:1,(B:1,(C:1,(D:1,E:1)90:2)80:1)70:1);
+(A:1,(B:1,(C:1,(D:1,E:1)70:4)60:1)50:1);
+(A:3,(B:1,(D:1,(C:1,E:1)):1):1);
+`)
+	an, err := s.Annotated(0.5)
+	if err != nil {
+		t.Fatalf("consensus: annotated: unexpected error: %v", err)
+	}
+	var de *tree.Node
+	for _, n := range an.Nodes() {
+		if n.IsTerm() {
+			continue
+		}
+		if terms := n.Terms(); len(terms) == 2 {
+			de = n
+		}
+	}
+	if de == nil {
+		t.Fatalf("consensus: annotated: clade D E not found")
+	}
+	if de.Label != "80" {
+		t.Errorf("consensus: annotated: label %q, want %q", de.Label, "80")
+	}
+	want := "&freq=0.6667,length_mean=3.000000,length_sd=1.000000,support_mean=80.0000"
+	if de.Comment != want {
+		t.Errorf("consensus: annotated: comment %q, want %q", de.Comment, want)
+	}
+	for _, n := range an.Root.Desc {
+		if n.Name != "A" {
+			continue
+		}
+		if !strings.HasPrefix(n.Comment, "&freq=1.0000,length_mean=1.666667,") {
+			t.Errorf("consensus: annotated: terminal comment %q", n.Comment)
+		}
+	}
+
+	if _, err := s.Annotated(0.4); err == nil {
+		t.Errorf("consensus: annotated: expecting error")
+	}
+}
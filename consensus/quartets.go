@@ -0,0 +1,467 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package consensus
+
+import (
+	"sort"
+
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+// A quartet is an unrooted tree of four terminals,
+// ab|cd.
+// In a normalized quartet
+// a < b, c < d, and a < c.
+type quartet [4]int
+
+func newQuartet(a, b, c, d int) quartet {
+	if a > b {
+		a, b = b, a
+	}
+	if c > d {
+		c, d = d, c
+	}
+	if a > c {
+		a, b, c, d = c, d, a, b
+	}
+	return quartet{a, b, c, d}
+}
+
+// A QuartetSet is a set of weighted quartets
+// counted from a tree collection.
+//
+// The trees can have different terminals
+// (e.g. gene trees with missing taxa),
+// so a QuartetSet can be used
+// to build a supertree.
+// The weight of a quartet
+// is the number of trees that display it.
+type QuartetSet struct {
+	terms []string
+	idx   map[string]int
+	qs    map[quartet]float64
+	trees int
+}
+
+// Trees returns the number of trees
+// added to the set.
+func (qs *QuartetSet) Trees() int {
+	return qs.trees
+}
+
+// Terms returns the terminals of the trees in the set,
+// in the order in which they were found.
+func (qs *QuartetSet) Terms() []string {
+	return qs.terms
+}
+
+// Len returns the number of different quartets
+// in the set.
+func (qs *QuartetSet) Len() int {
+	return len(qs.qs)
+}
+
+// Add adds the quartets of a tree
+// into the set.
+// Unresolved quartets
+// (i.e. from polytomies)
+// are ignored.
+func (qs *QuartetSet) Add(t *tree.Tree) error {
+	if qs.idx == nil {
+		qs.idx = make(map[string]int)
+		qs.qs = make(map[quartet]float64)
+	}
+	terms, d := leafDists(t)
+	seen := make(map[string]bool, len(terms))
+	ids := make([]int, len(terms))
+	for i, nm := range terms {
+		if seen[nm] {
+			return errors.Errorf("consensus: quartets: tree %d: terminal %s repeated", qs.trees+1, nm)
+		}
+		seen[nm] = true
+		id, ok := qs.idx[nm]
+		if !ok {
+			id = len(qs.terms)
+			qs.idx[nm] = id
+			qs.terms = append(qs.terms, nm)
+		}
+		ids[i] = id
+	}
+
+	qs.trees++
+	for i := 0; i < len(terms); i++ {
+		for j := i + 1; j < len(terms); j++ {
+			for k := j + 1; k < len(terms); k++ {
+				for l := k + 1; l < len(terms); l++ {
+					s1 := d[i][j] + d[k][l]
+					s2 := d[i][k] + d[j][l]
+					s3 := d[i][l] + d[j][k]
+					switch {
+					case s1 < s2 && s1 < s3:
+						qs.qs[newQuartet(ids[i], ids[j], ids[k], ids[l])]++
+					case s2 < s1 && s2 < s3:
+						qs.qs[newQuartet(ids[i], ids[k], ids[j], ids[l])]++
+					case s3 < s1 && s3 < s2:
+						qs.qs[newQuartet(ids[i], ids[l], ids[j], ids[k])]++
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Score returns the proportion
+// of the weight of the quartets in the set
+// that are displayed by a tree.
+// Quartets with terminals not in the tree
+// are ignored.
+func (qs *QuartetSet) Score(t *tree.Tree) float64 {
+	sum, total := qs.weight(t)
+	if total == 0 {
+		return 0
+	}
+	return sum / total
+}
+
+// Weight returns the weight of the quartets
+// displayed by a tree,
+// and the total weight of the quartets
+// with terminals in the tree.
+func (qs *QuartetSet) weight(t *tree.Tree) (sum, total float64) {
+	terms, d := leafDists(t)
+	pos := make(map[int]int, len(terms))
+	for i, nm := range terms {
+		if id, ok := qs.idx[nm]; ok {
+			pos[id] = i
+		}
+	}
+	for q, w := range qs.qs {
+		var p [4]int
+		ok := true
+		for i, id := range q {
+			p[i], ok = pos[id]
+			if !ok {
+				break
+			}
+		}
+		if !ok {
+			continue
+		}
+		total += w
+		s := d[p[0]][p[1]] + d[p[2]][p[3]]
+		if s < d[p[0]][p[2]]+d[p[1]][p[3]] && s < d[p[0]][p[3]]+d[p[1]][p[2]] {
+			sum += w
+		}
+	}
+	return sum, total
+}
+
+// Supertree returns a supertree
+// of the trees in the set,
+// using a weighted quartet MaxCut heuristic
+// (Snir & Rao 2010, IEEE/ACM TCBB 7: 704).
+//
+// At each step,
+// the terminals are split in two groups
+// that maximize the weight of the satisfied quartets
+// (i.e. quartets ab|cd with a and b in one group,
+// and c and d in the other),
+// minus the weight of the violated quartets
+// (i.e. other quartets split two and two).
+// Then each group is solved recursively,
+// with an artificial terminal
+// that represents the other group.
+// If no split has a positive score
+// the terminals are left as a polytomy.
+//
+// The tree is rooted
+// on the first terminal of the first tree.
+func (qs *QuartetSet) Supertree() (*tree.Tree, error) {
+	if len(qs.terms) < 4 {
+		return nil, errors.Errorf("consensus: supertree: %d terminals, want at least 4", len(qs.terms))
+	}
+	ws := make([]weighted, 0, len(qs.qs))
+	for q, w := range qs.qs {
+		ws = append(ws, weighted{q: q, w: w})
+	}
+	ids := make([]int, len(qs.terms))
+	for i := range ids {
+		ids[i] = i
+	}
+
+	b := &quartetBuilder{
+		next: len(qs.terms),
+		adj:  make(map[int][]int),
+	}
+	b.build(ids, ws)
+
+	out := 0
+	root := &tree.Node{}
+	root.Add(&tree.Node{Name: qs.terms[out]})
+	root.Add(b.node(b.adj[out][0], out, qs.terms))
+	return &tree.Tree{Root: root}, nil
+}
+
+// A weighted is a quartet with a weight.
+type weighted struct {
+	q quartet
+	w float64
+}
+
+// A QuartetBuilder builds
+// an unrooted supertree.
+type quartetBuilder struct {
+	next int           // next free node identifier
+	adj  map[int][]int // adjacency list of the tree
+}
+
+func (b *quartetBuilder) connect(x, y int) {
+	b.adj[x] = append(b.adj[x], y)
+	b.adj[y] = append(b.adj[y], x)
+}
+
+// Build builds the tree of a set of terminals
+// (given by its node identifiers),
+// with the quartets given by local indexes
+// of the terminals.
+func (b *quartetBuilder) build(ids []int, ws []weighted) {
+	if len(ids) < 4 {
+		b.star(ids)
+		return
+	}
+	side, score := bestCut(len(ids), ws)
+	if score <= 0 {
+		b.star(ids)
+		return
+	}
+
+	var ids0, ids1 []int
+	loc := make([]int, len(ids))
+	for i, id := range ids {
+		if side[i] == 0 {
+			loc[i] = len(ids0)
+			ids0 = append(ids0, id)
+		} else {
+			loc[i] = len(ids1)
+			ids1 = append(ids1, id)
+		}
+	}
+	// artificial terminals
+	x0, x1 := b.next, b.next+1
+	b.next += 2
+	art := [2]int{len(ids0), len(ids1)}
+	ids0 = append(ids0, x0)
+	ids1 = append(ids1, x1)
+
+	var ws0, ws1 []weighted
+	for _, w := range ws {
+		var n [2]int
+		for _, t := range w.q {
+			n[side[t]]++
+		}
+		s := 0
+		if n[1] > n[0] {
+			s = 1
+		}
+		if n[s] < 3 {
+			continue
+		}
+		var q quartet
+		for i, t := range w.q {
+			q[i] = art[s]
+			if side[t] == s {
+				q[i] = loc[t]
+			}
+		}
+		nw := weighted{q: newQuartet(q[0], q[1], q[2], q[3]), w: w.w}
+		if s == 0 {
+			ws0 = append(ws0, nw)
+		} else {
+			ws1 = append(ws1, nw)
+		}
+	}
+	b.build(ids0, ws0)
+	b.build(ids1, ws1)
+
+	// join both trees
+	// removing the artificial terminals
+	n0, n1 := b.adj[x0][0], b.adj[x1][0]
+	delete(b.adj, x0)
+	delete(b.adj, x1)
+	replace(b.adj[n0], x0, n1)
+	replace(b.adj[n1], x1, n0)
+}
+
+// Star connects a set of terminals
+// to a single node.
+func (b *quartetBuilder) star(ids []int) {
+	c := b.next
+	b.next++
+	for _, id := range ids {
+		b.connect(c, id)
+	}
+}
+
+// Node returns a tree node
+// from a node of the unrooted tree,
+// as seen from one of its neighbors.
+func (b *quartetBuilder) node(v, from int, terms []string) *tree.Node {
+	if v < len(terms) {
+		return &tree.Node{Name: terms[v]}
+	}
+	n := &tree.Node{}
+	for _, u := range b.adj[v] {
+		if u == from {
+			continue
+		}
+		n.Add(b.node(u, v, terms))
+	}
+	return n
+}
+
+func replace(ls []int, old, v int) {
+	for i, x := range ls {
+		if x == old {
+			ls[i] = v
+			return
+		}
+	}
+}
+
+// BestCut returns the best bipartition
+// of a set of terminals,
+// with at least two terminals on each side,
+// and its score.
+// The local search starts from the heaviest quartets.
+func bestCut(n int, ws []weighted) ([]int, float64) {
+	if len(ws) == 0 {
+		return nil, 0
+	}
+	byTerm := make([][]int, n)
+	for i, w := range ws {
+		for _, t := range w.q {
+			byTerm[t] = append(byTerm[t], i)
+		}
+	}
+	seeds := make([]int, len(ws))
+	for i := range seeds {
+		seeds[i] = i
+	}
+	sort.Slice(seeds, func(i, j int) bool {
+		a, b := ws[seeds[i]], ws[seeds[j]]
+		if a.w != b.w {
+			return a.w > b.w
+		}
+		for k := range a.q {
+			if a.q[k] != b.q[k] {
+				return a.q[k] < b.q[k]
+			}
+		}
+		return false
+	})
+	if len(seeds) > maxSeeds {
+		seeds = seeds[:maxSeeds]
+	}
+
+	var best []int
+	bestScore := 0.0
+	for _, s := range seeds {
+		side := make([]int, n)
+		side[ws[s].q[2]] = 1
+		side[ws[s].q[3]] = 1
+		size := [2]int{n - 2, 2}
+		score := 0.0
+		for _, w := range ws {
+			score += w.w * quartetValue(w.q, side)
+		}
+		for {
+			improved := false
+			for t := 0; t < n; t++ {
+				if size[side[t]] <= 2 {
+					continue
+				}
+				var delta float64
+				for _, i := range byTerm[t] {
+					delta -= ws[i].w * quartetValue(ws[i].q, side)
+				}
+				side[t] = 1 - side[t]
+				for _, i := range byTerm[t] {
+					delta += ws[i].w * quartetValue(ws[i].q, side)
+				}
+				if delta <= 1e-9 {
+					side[t] = 1 - side[t]
+					continue
+				}
+				size[1-side[t]]--
+				size[side[t]]++
+				score += delta
+				improved = true
+			}
+			if !improved {
+				break
+			}
+		}
+		if score > bestScore+1e-9 {
+			best = side
+			bestScore = score
+		}
+	}
+	return best, bestScore
+}
+
+// MaxSeeds is the maximum number of starting quartets
+// used in the search of the best bipartition.
+const maxSeeds = 10
+
+// QuartetValue returns 1 if a quartet is satisfied by a bipartition,
+// -1 if it is violated,
+// and 0 otherwise.
+func quartetValue(q quartet, side []int) float64 {
+	a, b, c, d := side[q[0]], side[q[1]], side[q[2]], side[q[3]]
+	if a+b+c+d != 2 {
+		return 0
+	}
+	if a == b {
+		return 1
+	}
+	return -1
+}
+
+// LeafDists returns the terminals of a tree,
+// and the number of branches
+// between each pair of terminals.
+func leafDists(t *tree.Tree) ([]string, [][]int) {
+	var leaves []*tree.Node
+	for _, n := range t.Nodes() {
+		if n.IsTerm() {
+			leaves = append(leaves, n)
+		}
+	}
+	terms := make([]string, len(leaves))
+	d := make([][]int, len(leaves))
+	for i, x := range leaves {
+		terms[i] = x.Name
+		depth := make(map[*tree.Node]int)
+		for n, k := x, 0; n != nil; n, k = n.Anc, k+1 {
+			depth[n] = k
+		}
+		d[i] = make([]int, len(leaves))
+		for j, y := range leaves {
+			k := 0
+			n := y
+			for ; n != nil; n = n.Anc {
+				if dx, ok := depth[n]; ok {
+					d[i][j] = dx + k
+					break
+				}
+				k++
+			}
+		}
+	}
+	return terms, d
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package consensus
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/tree"
+)
+
+// Gene trees with missing terminals,
+// from the tree (Out,(A,(B,(C,(D,E))))).
+var geneBlob = `
+(A,(B,(C,(D,E))));
+(Out,(B,(C,(D,E))));
+(Out,(A,(C,(D,E))));
+(Out,(A,(B,(D,E))));
+(Out,(A,(B,(C,E))));
+(Out,(A,(B,(C,D))));
+`
+
+func readQuartets(t *testing.T, blob string) *QuartetSet {
+	qs := &QuartetSet{}
+	r := tree.NewReader(strings.NewReader(blob))
+	for r.Scan() {
+		if err := qs.Add(r.Tree()); err != nil {
+			t.Fatalf("consensus: quartets: unexpected error: %v", err)
+		}
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("consensus: quartets: unexpected error: %v", err)
+	}
+	return qs
+}
+
+func TestSupertree(t *testing.T) {
+	qs := readQuartets(t, geneBlob)
+	if qs.Trees() != 6 {
+		t.Errorf("consensus: supertree: %d trees, want %d", qs.Trees(), 6)
+	}
+	if len(qs.Terms()) != 6 {
+		t.Errorf("consensus: supertree: %d terminals, want %d", len(qs.Terms()), 6)
+	}
+	// 15 quartets in a 6 terminal tree
+	if qs.Len() != 15 {
+		t.Errorf("consensus: supertree: %d quartets, want %d", qs.Len(), 15)
+	}
+
+	st, err := qs.Supertree()
+	if err != nil {
+		t.Fatalf("consensus: supertree: unexpected error: %v", err)
+	}
+	if st.Root.Desc[0].Name != "A" {
+		t.Errorf("consensus: supertree: rooted on %q, want %q", st.Root.Desc[0].Name, "A")
+	}
+	if err := st.Reroot("Out"); err != nil {
+		t.Fatalf("consensus: supertree: unexpected error: %v", err)
+	}
+	for _, cl := range [][]string{
+		{"A", "B", "C", "D", "E"},
+		{"B", "C", "D", "E"},
+		{"C", "D", "E"},
+		{"D", "E"},
+	} {
+		if !st.HasClade(cl) {
+			t.Errorf("consensus: supertree: clade %v not found", cl)
+		}
+	}
+	if s := qs.Score(st); math.Abs(s-1) > 1e-6 {
+		t.Errorf("consensus: supertree: score %.4f, want %.4f", s, 1.0)
+	}
+}
+
+func TestSupertreeConflict(t *testing.T) {
+	// without information on the relationships of A, B, and C
+	qs := readQuartets(t, `
+(Out,(A,(B,C)));
+(Out,(B,(A,C)));
+(Out,(C,(A,B)));
+`)
+	st, err := qs.Supertree()
+	if err != nil {
+		t.Fatalf("consensus: supertree: unexpected error: %v", err)
+	}
+	var b bytes.Buffer
+	st.Write(&b, false)
+	if want := "(Out (A B C));"; b.String() != want {
+		t.Errorf("consensus: supertree: %s, want %s", b.String(), want)
+	}
+
+	qs = readQuartets(t, "(A,(B,C));")
+	if _, err := qs.Supertree(); err == nil {
+		t.Errorf("consensus: supertree: expecting error on a tree with 3 terminals")
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package consensus
+
+import (
+	"sort"
+
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+// Resolution returns the resolution of a tree,
+// i.e. the number of clades of the tree
+// divided by the number of clades
+// of a fully resolved tree.
+func Resolution(t *tree.Tree) float64 {
+	n := len(t.Terms())
+	if n < 3 {
+		return 1
+	}
+	return float64(len(t.Clades())) / float64(n-2)
+}
+
+// WeightedResolution returns the resolution
+// of the majority rule consensus
+// of the set,
+// in which each clade is weighted
+// by its frequency.
+func (s *Set) WeightedResolution() float64 {
+	n := len(s.terms)
+	if n < 3 {
+		return 1
+	}
+	sum := float64(0)
+	for _, c := range s.Clades(0.5) {
+		sum += s.Freq(c)
+	}
+	return sum / float64(n-2)
+}
+
+// Rogues searches for rogue terminals
+// in a tree collection,
+// i.e. terminals whose removal
+// improves the resolution
+// of the majority rule consensus.
+// To break ties,
+// the resolution is weighted
+// by the frequency of each clade
+// of the consensus.
+// Terminals are removed in a greedy fashion,
+// at each step,
+// the terminal with the best improvement
+// is removed,
+// until no removal improves the resolution,
+// or the maximum number of terminals is removed.
+// It returns the rogue terminals,
+// in the order in which they were removed.
+func Rogues(trees []*tree.Tree, max int) ([]string, error) {
+	if len(trees) == 0 {
+		return nil, errors.New("consensus: rogues: empty tree set")
+	}
+	cur := make([]*tree.Tree, 0, len(trees))
+	for _, t := range trees {
+		cur = append(cur, t.Copy())
+	}
+	res, err := weightedRes(cur, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var rogues []string
+	for len(rogues) < max {
+		terms := cur[0].Terms()
+		if len(terms) < 5 {
+			break
+		}
+		sort.Strings(terms)
+		best := ""
+		for _, nm := range terms {
+			r, err := weightedRes(cur, nm)
+			if err != nil {
+				return nil, err
+			}
+			if r > res {
+				res = r
+				best = nm
+			}
+		}
+		if best == "" {
+			break
+		}
+		rogues = append(rogues, best)
+		for _, t := range cur {
+			t.Prune([]string{best})
+		}
+	}
+	return rogues, nil
+}
+
+// WeightedRes returns the resolution
+// of the majority rule consensus
+// of a tree collection,
+// weighted by the frequency of each clade,
+// after the removal of the indicated terminal.
+func weightedRes(trees []*tree.Tree, prune string) (float64, error) {
+	s := &Set{}
+	for _, t := range trees {
+		if prune != "" {
+			t = t.Copy()
+			t.Prune([]string{prune})
+		}
+		if err := s.Add(t); err != nil {
+			return 0, err
+		}
+	}
+	return s.WeightedResolution(), nil
+}
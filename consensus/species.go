@@ -0,0 +1,388 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package consensus
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+// ExactTerms is the maximum number of terminals
+// in which the species tree is searched
+// by exhaustive enumeration.
+const ExactTerms = 9
+
+// SpeciesTree returns the binary tree
+// that maximizes the quartet score
+// of the trees in the set
+// (as in ASTRAL, Mirarab et al. 2014,
+// Bioinformatics 30: i541),
+// i.e. a statistically consistent estimate
+// of the species tree
+// under the multispecies coalescent
+// when the trees in the set are gene trees.
+//
+// If the set has ExactTerms terminals or less,
+// all binary trees are evaluated.
+// Otherwise,
+// the search starts from the quartet supertree
+// (see Supertree),
+// with its polytomies resolved at random,
+// and improved by NNI branch swapping.
+//
+// The tree is rooted
+// on the first terminal of the first tree,
+// and each internal node is labeled
+// with its local posterior probability
+// (see LocalPP).
+func (qs *QuartetSet) SpeciesTree() (*tree.Tree, error) {
+	if len(qs.terms) < 4 {
+		return nil, errors.Errorf("consensus: species tree: %d terminals, want at least 4", len(qs.terms))
+	}
+	var t *tree.Tree
+	if len(qs.terms) <= ExactTerms {
+		t = qs.exact()
+	} else {
+		var err error
+		t, err = qs.Supertree()
+		if err != nil {
+			return nil, errors.Wrap(err, "consensus: species tree")
+		}
+		t.Resolve()
+		qs.nni(t)
+	}
+	for _, b := range qs.LocalPP(t) {
+		b.Node.Label = fmt.Sprintf("%.2f", b.PP)
+	}
+	return t, nil
+}
+
+// Exact returns the tree with the best quartet score
+// from all the binary trees of the terminals.
+func (qs *QuartetSet) exact() *tree.Tree {
+	root := &tree.Node{}
+	root.Add(&tree.Node{Name: qs.terms[0]})
+	x := &tree.Node{}
+	root.Add(x)
+	x.Add(&tree.Node{Name: qs.terms[1]})
+	x.Add(&tree.Node{Name: qs.terms[2]})
+	t := &tree.Tree{Root: root}
+
+	var best *tree.Tree
+	bestScore := -1.0
+	var add func(k int)
+	add = func(k int) {
+		if k == len(qs.terms) {
+			if s, _ := qs.weight(t); s > bestScore {
+				bestScore = s
+				best = t.Copy()
+			}
+			return
+		}
+		// add the terminal on each branch,
+		// except the outgroup branch,
+		// that is the same branch
+		// as the one of its sister
+		for _, n := range t.Nodes() {
+			if n == root || n == root.Desc[0] {
+				continue
+			}
+			p := n.Anc
+			i := 0
+			for p.Desc[i] != n {
+				i++
+			}
+			y := &tree.Node{}
+			y.Anc = p
+			p.Desc[i] = y
+			y.Add(n)
+			y.Add(&tree.Node{Name: qs.terms[k]})
+			add(k + 1)
+
+			// restore the tree
+			p.Desc[i] = n
+			n.Anc = p
+		}
+	}
+	add(3)
+	return best
+}
+
+// Nni improves the quartet score of a binary tree
+// with NNI branch swapping.
+//
+// For each internal branch,
+// each descendant of the node
+// is exchanged with the sister of the node.
+func (qs *QuartetSet) nni(t *tree.Tree) {
+	best, _ := qs.weight(t)
+	for improve := true; improve; {
+		improve = false
+		for _, n := range t.Nodes() {
+			if n.IsTerm() || n == t.Root || n.Anc == t.Root || len(n.Anc.Desc) != 2 {
+				continue
+			}
+			for i := range n.Desc {
+				p := n.Anc
+				j := 0
+				if p.Desc[j] == n {
+					j = 1
+				}
+				d, sis := n.Desc[i], p.Desc[j]
+				n.Desc[i], p.Desc[j] = sis, d
+				sis.Anc, d.Anc = n, p
+				if s, _ := qs.weight(t); s > best+1e-9 {
+					best = s
+					improve = true
+					break
+				}
+
+				// restore the nodes
+				n.Desc[i], p.Desc[j] = d, sis
+				d.Anc, sis.Anc = n, p
+			}
+		}
+	}
+}
+
+// A BranchSupport is the quartet support
+// of an internal branch of a tree.
+type BranchSupport struct {
+	Node *tree.Node // Node of the branch
+
+	// Mean weight of the quartets around the branch
+	// for the topology of the tree,
+	// and the two alternative topologies.
+	Freq [3]float64
+
+	// Local posterior probability of the branch.
+	PP float64
+
+	// Length of the branch in coalescent units
+	// (infinite if there is no discordance).
+	Length float64
+}
+
+// LocalPP returns the local posterior probability
+// of each internal branch of a binary tree
+// (Sayyari & Mirarab 2016, Mol. Biol. Evol. 33: 1654).
+//
+// An internal branch defines four groups of terminals,
+// and three possible topologies
+// of the quartets with a terminal of each group.
+// Under the multispecies coalescent,
+// the topology of the tree
+// is displayed by a proportion p >= 1/3 of the gene trees,
+// and the alternatives by (1-p)/2.
+// With equal priors for the three topologies,
+// and a uniform prior for p,
+// the posterior probability of the topology of the tree
+// is calculated from the mean weight
+// of the quartets of each topology.
+//
+// Branches around a polytomy,
+// or adjacent to the root
+// when the root has two descendants,
+// are ignored.
+func (qs *QuartetSet) LocalPP(t *tree.Tree) []BranchSupport {
+	var bs []BranchSupport
+	for _, n := range t.Nodes() {
+		if n.IsTerm() || n == t.Root || len(n.Desc) != 2 {
+			continue
+		}
+		p := n.Anc
+		var groups [4][]string
+		groups[0] = n.Desc[0].Terms()
+		groups[1] = n.Desc[1].Terms()
+		switch {
+		case p == t.Root && len(p.Desc) == 3:
+			k := 2
+			for _, d := range p.Desc {
+				if d != n {
+					groups[k] = d.Terms()
+					k++
+				}
+			}
+		case p != t.Root && len(p.Desc) == 2:
+			sis := p.Desc[0]
+			if sis == n {
+				sis = p.Desc[1]
+			}
+			groups[2] = sis.Terms()
+			in := make(map[string]bool)
+			for _, nm := range p.Terms() {
+				in[nm] = true
+			}
+			for _, nm := range t.Terms() {
+				if !in[nm] {
+					groups[3] = append(groups[3], nm)
+				}
+			}
+		default:
+			continue
+		}
+		bs = append(bs, qs.branchSupport(n, groups))
+	}
+	return bs
+}
+
+// BranchSupport returns the support of a branch
+// defined by four groups of terminals,
+// in which the first two groups are sisters.
+func (qs *QuartetSet) branchSupport(n *tree.Node, groups [4][]string) BranchSupport {
+	g := make(map[int]int)
+	m := 1.0
+	for i, grp := range groups {
+		m *= float64(len(grp))
+		for _, nm := range grp {
+			if id, ok := qs.idx[nm]; ok {
+				g[id] = i
+			}
+		}
+	}
+
+	var f [3]float64
+	for q, w := range qs.qs {
+		var x [4]int
+		var seen [4]bool
+		ok := true
+		for i, id := range q {
+			gi, in := g[id]
+			if !in || seen[gi] {
+				ok = false
+				break
+			}
+			seen[gi] = true
+			x[i] = gi
+		}
+		if !ok {
+			continue
+		}
+		// the pair with the first group
+		// defines the topology
+		other := x[1]
+		switch {
+		case x[0] == 0:
+		case x[1] == 0:
+			other = x[0]
+		case x[2] == 0:
+			other = x[3]
+		default:
+			other = x[2]
+		}
+		f[other-1] += w
+	}
+
+	b := BranchSupport{Node: n, PP: 1.0 / 3}
+	for i := range f {
+		b.Freq[i] = f[i] / m
+	}
+	sum := b.Freq[0] + b.Freq[1] + b.Freq[2]
+	if sum == 0 {
+		return b
+	}
+
+	var lv [3]float64
+	for i, z := range b.Freq {
+		lv[i] = logPosterior(z, sum)
+	}
+	max := math.Max(lv[0], math.Max(lv[1], lv[2]))
+	var tot float64
+	for i := range lv {
+		tot += math.Exp(lv[i] - max)
+	}
+	b.PP = math.Exp(lv[0]-max) / tot
+
+	b.Length = math.Inf(1)
+	if p := b.Freq[0] / sum; p <= 1.0/3 {
+		b.Length = 0
+	} else if p < 1 {
+		b.Length = -math.Log(1.5 * (1 - p))
+	}
+	return b
+}
+
+// LogPosterior returns the logarithm
+// of the (unnormalized) posterior
+// of a quartet topology,
+// displayed by z of n gene trees,
+// integrating p, the probability of the topology,
+// between 1/3 and 1.
+func logPosterior(z, n float64) float64 {
+	a, b := z+1, n-z+1
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+
+	// 1 - I_{1/3}(a, b) = I_{2/3}(b, a)
+	return la + lb - lab + math.Log(regBeta(2.0/3, b, a)) - (n-z)*math.Ln2
+}
+
+// RegBeta returns the regularized
+// incomplete beta function I_x(a, b).
+func regBeta(x, a, b float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	front := math.Exp(lab - la - lb + a*math.Log(x) + b*math.Log(1-x))
+	if x < (a+1)/(a+b+2) {
+		return front * betaCF(x, a, b) / a
+	}
+	return 1 - front*betaCF(1-x, b, a)/b
+}
+
+// BetaCF evaluates the continued fraction
+// of the incomplete beta function
+// (modified Lentz method).
+func betaCF(x, a, b float64) float64 {
+	const tiny = 1e-300
+	c := 1.0
+	d := 1 - (a+b)*x/(a+1)
+	if math.Abs(d) < tiny {
+		d = tiny
+	}
+	d = 1 / d
+	h := d
+	for m := 1; m < 1000; m++ {
+		fm := float64(m)
+		aa := fm * (b - fm) * x / ((a + 2*fm - 1) * (a + 2*fm))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		h *= d * c
+		aa = -(a + fm) * (a + b + fm) * x / ((a + 2*fm) * (a + 2*fm + 1))
+		d = 1 + aa*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = 1 + aa/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < 1e-14 {
+			break
+		}
+	}
+	return h
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package consensus
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/tree"
+)
+
+// Gene trees from the species tree
+// (Out,(A,(B,(C,(D,(E,(F,(G,(H,I))))))))),
+// with some discordance.
+var speciesBlob = `
+(Out,(A,(B,(C,(D,(E,(F,(G,(H,I)))))))));
+(Out,(A,(B,(C,(D,(E,(F,(G,(H,I)))))))));
+(Out,(B,(A,(C,(D,(E,(F,(G,(H,I)))))))));
+(Out,(A,(B,(C,(D,(E,(F,(H,(G,I)))))))));
+(Out,(A,(B,(D,(C,(E,(F,(G,(H,I)))))))));
+(Out,(A,(B,(C,(D,(E,(F,(G,(H,I)))))))));
+`
+
+func TestSpeciesTree(t *testing.T) {
+	// exact search
+	qs := readQuartets(t, geneBlob)
+	st, err := qs.SpeciesTree()
+	if err != nil {
+		t.Fatalf("consensus: species tree: unexpected error: %v", err)
+	}
+	if !st.IsBinary() {
+		t.Errorf("consensus: species tree: tree is not binary")
+	}
+	if s := qs.Score(st); math.Abs(s-1) > 1e-6 {
+		t.Errorf("consensus: species tree: score %.4f, want %.4f", s, 1.0)
+	}
+
+	// heuristic search
+	qs = readQuartets(t, speciesBlob)
+	st, err = qs.SpeciesTree()
+	if err != nil {
+		t.Fatalf("consensus: species tree: unexpected error: %v", err)
+	}
+	if st.Root.Desc[0].Name != "Out" {
+		t.Errorf("consensus: species tree: rooted on %q, want %q", st.Root.Desc[0].Name, "Out")
+	}
+	want := []string{"A", "B", "C", "D", "E", "F", "G", "H", "I"}
+	for i := 0; i < len(want)-1; i++ {
+		if !st.HasClade(want[i:]) {
+			t.Errorf("consensus: species tree: clade %v not found", want[i:])
+		}
+	}
+	bs := qs.LocalPP(st)
+	if len(bs) != 7 {
+		t.Fatalf("consensus: species tree: %d branches with support, want %d", len(bs), 7)
+	}
+	for _, b := range bs {
+		if b.PP < 0.5 || b.PP > 1 {
+			t.Errorf("consensus: species tree: clade %v: local PP %.4f", b.Node.Terms(), b.PP)
+		}
+		if b.Node.Label == "" {
+			t.Errorf("consensus: species tree: clade %v: without label", b.Node.Terms())
+		}
+	}
+}
+
+func TestSpeciesNNI(t *testing.T) {
+	qs := readQuartets(t, speciesBlob)
+	st := readQuartetTree(t, "(Out,(I,(H,(G,(F,(E,(D,(C,(B,A)))))))));")
+	qs.nni(st)
+	ex, _ := qs.weight(readQuartetTree(t, "(Out,(A,(B,(C,(D,(E,(F,(G,(H,I)))))))));"))
+	if s, _ := qs.weight(st); s < ex {
+		t.Errorf("consensus: species tree: nni: weight %.1f, want %.1f", s, ex)
+	}
+}
+
+func readQuartetTree(t *testing.T, s string) *tree.Tree {
+	r := tree.NewReader(strings.NewReader(s))
+	if !r.Scan() {
+		t.Fatalf("consensus: unexpected error: %v", r.Err())
+	}
+	return r.Tree()
+}
+
+func TestLocalPP(t *testing.T) {
+	// without information
+	if pp := math.Exp(logPosterior(0, 0)); math.Abs(pp-2.0/3) > 1e-6 {
+		t.Errorf("consensus: local PP: uninformative posterior %.6f, want %.6f", pp, 2.0/3)
+	}
+
+	var lv [3]float64
+	z := [3]float64{30, 0, 0}
+	for i := range z {
+		lv[i] = logPosterior(z[i], 30)
+	}
+	pp := 1 / (1 + math.Exp(lv[1]-lv[0]) + math.Exp(lv[2]-lv[0]))
+	if pp < 0.999 {
+		t.Errorf("consensus: local PP: %.6f, want > 0.999", pp)
+	}
+
+	if v := regBeta(0.5, 2, 3); math.Abs(v-0.6875) > 1e-9 {
+		t.Errorf("consensus: regBeta: %.9f, want %.9f", v, 0.6875)
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package anc implements the bio.anc command,
+// i.e. reconstruct ancestral areas on a dated tree.
+package anc
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/likelihood"
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `bio.anc [-b|--block <name>] [-t|--tree <treefile>] <dataset>`,
+	Short:     "reconstruct ancestral areas",
+	Long: `
+Command bio.anc reads a dated tree (i.e. a tree with branch lengths in
+time units), and a dataset with a block of area codings, and
+reconstructs the ancestral areas of each node of the tree, using
+maximum likelihood.
+
+The areas are coded as a morphology block, in which each character is
+an area scheme (usually a single character), and each state is an
+area. Terminals distributed in more than one area can be coded as
+polymorphic (e.g. [01]), and they are taken as uncertain. By default,
+the block named "areas" is used, for example:
+
+	> morpho areas
+	Homo_sapiens     0
+	Lemur_catta      1
+	Mus_musculus     [02]
+
+Another block can be selected with the option -b, or --block. Up to 8
+areas can be used.
+
+The areas evolve under an Mk model (i.e. equal rates of dispersal
+between any pair of areas), and the global dispersal rate, in changes
+per time unit, is estimated from the tree. Terminals not found in the
+tree are removed from the analysis, and a warning is printed.
+
+First, the tree is printed with the internal nodes labeled with a
+numeric identifier, in pre-order, starting at the root with the number
+of terminals plus one. Then, for each internal node, the age of the
+node, and the marginal probability of each area, for each character
+of the block, are printed.
+
+The tree will be read from the standard input, unless the option -t
+or --tree is defined with a tree file.
+
+Options are:
+
+    -b <name>
+    --block <name>
+      Set the name of the block with the area codings. Default:
+      areas.
+
+    -t <treefile>
+    --tree <treefile>
+      If defined, the tree will be read from the indicated file,
+      instead of the standard input.
+
+    <dataset>
+      The phylogenetic data matrix. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var block string
+var treefile string
+
+func register(c *cmdapp.Command) {
+	c.Flag.StringVar(&block, "block", "areas", "")
+	c.Flag.StringVar(&block, "b", "areas", "")
+	c.Flag.StringVar(&treefile, "tree", "", "")
+	c.Flag.StringVar(&treefile, "t", "", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a dataset filename", c.Name())
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+	}
+	defer f.Close()
+
+	m, err := matrix.NewMatrix(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+	}
+
+	// keep only the block with the areas
+	area := -1
+	var others []int
+	for i, b := range m.Blocks {
+		if b.Name == block {
+			area = i
+			continue
+		}
+		others = append(others, i)
+	}
+	if area < 0 {
+		return errors.Errorf("%s: block %s not found", c.Name(), block)
+	}
+	if m.Blocks[area].Type != matrix.Morphology {
+		return errors.Errorf("%s: block %s: area codings must be a morphology block", c.Name(), block)
+	}
+	if len(others) > 0 {
+		m = m.DropBlocks(others)
+	}
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	tf := os.Stdin
+	if treefile != "" {
+		tf, err = os.Open(treefile)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), treefile)
+		}
+		defer tf.Close()
+	}
+
+	tp, err := tree.Read(tf)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing tree", c.Name())
+	}
+	if !tp.Lens {
+		return errors.Errorf("%s: tree without branch lengths", c.Name())
+	}
+
+	inTree := make(map[string]bool)
+	for _, nm := range tp.Terms() {
+		inTree[nm] = true
+	}
+	var del []string
+	for nm := range m.Names {
+		if !inTree[nm] {
+			fmt.Printf("# Warning: terminal %s not in tree: removed\n", nm)
+			del = append(del, nm)
+		}
+	}
+	if len(del) > 0 {
+		m = m.DropTaxa(del)
+	}
+
+	tr, err := likelihood.FromTopology(tp, likelihood.NewFromMatrix(m))
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	rate := tr.ClockRate()
+	marg := tr.Marginals()
+
+	// pre-order identifiers of the nodes
+	ids := make(map[*likelihood.Node]string, len(tr.Nodes))
+	ages := make(map[*likelihood.Node]float64, len(tr.Nodes))
+	var nodes []*likelihood.Node
+	tAges := tp.NodeAges()
+	next := len(m.Names) + 1
+	var setID func(tn *tree.Node, n *likelihood.Node)
+	setID = func(tn *tree.Node, n *likelihood.Node) {
+		ages[n] = tAges[tn]
+		if n.Term != nil {
+			return
+		}
+		nodes = append(nodes, n)
+		ids[n] = strconv.Itoa(next)
+		tn.Label = ids[n]
+		next++
+		setID(tn.Desc[0], n.Left)
+		setID(tn.Desc[1], n.Right)
+	}
+	setID(tp.Root, tr.Root)
+
+	fmt.Printf("# Areas block: %s\n", block)
+	fmt.Printf("# Dispersal rate: %.6g changes per time unit\n", rate)
+	fmt.Printf("# Tree -log Likelihood: %.6f\n", -tr.Like())
+	tp.Write(os.Stdout, true)
+	fmt.Printf("\n")
+
+	fmt.Printf("# node\tage\tchar\tareas\n")
+	for _, n := range nodes {
+		for ch, p := range marg[n] {
+			fmt.Printf("%s\t%.6f\t%d", ids[n], ages[n], ch+1)
+			for s, v := range p {
+				fmt.Printf("\t%s:%.3f", m.Kind[ch].Symbol(s), v)
+			}
+			fmt.Printf("\n")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package env reports the environment
+// in which an analysis was made
+// (program version, Go version, operating system,
+// and the seed of the random number generator),
+// so the results carry their own provenance.
+//
+// The seed of the random number generator
+// is set the first time it is requested.
+// If the environment variable RAMITA_SEED is defined,
+// it will be used as the seed,
+// otherwise the seed is taken from the current time.
+//
+// The number of threads
+// used by parallel analyses
+// is set with the global option --threads
+// (see ParseThreads).
+package env
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Version is the version of the program.
+// It can be set at build time with:
+//
+//	go build -ldflags "-X github.com/js-arias/ramita/internal/env.Version=<version>"
+var Version = "devel"
+
+// SeedVar is the name of the environment variable
+// used to set the seed of the random number generator.
+const SeedVar = "RAMITA_SEED"
+
+var (
+	once    sync.Once
+	seed    int64
+	seedErr error
+	threads = 1
+)
+
+// InitSeed sets the seed
+// of the random number generator.
+func initSeed() {
+	seed = time.Now().UnixNano()
+	if s := os.Getenv(SeedVar); s != "" {
+		v, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			seedErr = errors.Errorf("env: invalid %s value %q", SeedVar, s)
+		} else {
+			seed = v
+		}
+	}
+	rand.Seed(seed)
+}
+
+// Seed returns the seed
+// of the random number generator.
+func Seed() int64 {
+	once.Do(initSeed)
+	return seed
+}
+
+// ParseThreads reads the global option --threads
+// (given before the command name)
+// from the arguments of the program,
+// e.g. "ramita --threads 4 p.wagday data.txt",
+// and returns the arguments without the option.
+func ParseThreads(args []string) ([]string, error) {
+	if len(args) == 0 || !strings.HasPrefix(args[0], "--threads") {
+		return args, nil
+	}
+	var v string
+	rest := args[1:]
+	switch {
+	case strings.HasPrefix(args[0], "--threads="):
+		v = strings.TrimPrefix(args[0], "--threads=")
+	case args[0] == "--threads" && len(args) > 1:
+		v = args[1]
+		rest = args[2:]
+	case args[0] == "--threads":
+		return nil, errors.New("env: expecting a number of threads")
+	default:
+		return args, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		return nil, errors.Errorf("env: invalid number of threads %q", v)
+	}
+	threads = n
+	return rest, nil
+}
+
+// Threads returns the number of threads
+// used by parallel analyses.
+func Threads() int {
+	return threads
+}
+
+// String returns the environment
+// as a single line.
+// The number of threads
+// is only reported
+// if it is greater than 1.
+func String() string {
+	s := fmt.Sprintf("ramita %s (%s %s/%s) seed=%d", Version, runtime.Version(), runtime.GOOS, runtime.GOARCH, Seed())
+	if threads > 1 {
+		s += fmt.Sprintf(" threads=%d", threads)
+	}
+	return s
+}
+
+// Header writes the environment
+// as a comment line (i.e. a line starting with '#').
+// It returns an error
+// if the seed defined in the environment
+// is invalid.
+func Header(w io.Writer) error {
+	s := String()
+	if seedErr != nil {
+		return seedErr
+	}
+	_, err := fmt.Fprintf(w, "# %s\n", s)
+	return err
+}
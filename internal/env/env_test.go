@@ -0,0 +1,81 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package env
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestHeader(t *testing.T) {
+	os.Setenv(SeedVar, "42")
+	defer os.Unsetenv(SeedVar)
+
+	if s := Seed(); s != 42 {
+		t.Errorf("env: seed %d, want %d", s, 42)
+	}
+	v := rand.Int()
+	rand.Seed(42)
+	if w := rand.Int(); v != w {
+		t.Errorf("env: random generator not seeded")
+	}
+
+	var b bytes.Buffer
+	if err := Header(&b); err != nil {
+		t.Fatalf("env: header: unexpected error: %v", err)
+	}
+	h := b.String()
+	for _, s := range []string{"# ramita " + Version, runtime.Version(), runtime.GOOS + "/" + runtime.GOARCH, "seed=42"} {
+		if !strings.Contains(h, s) {
+			t.Errorf("env: header %q: %q not found", h, s)
+		}
+	}
+}
+
+func TestParseThreads(t *testing.T) {
+	defer func() { threads = 1 }()
+
+	args, err := ParseThreads([]string{"p.wagday", "data.txt"})
+	if err != nil {
+		t.Fatalf("env: threads: unexpected error: %v", err)
+	}
+	if len(args) != 2 || Threads() != 1 {
+		t.Errorf("env: threads: args %v, threads %d", args, Threads())
+	}
+
+	for _, a := range [][]string{
+		{"--threads", "4", "p.wagday", "data.txt"},
+		{"--threads=4", "p.wagday", "data.txt"},
+	} {
+		threads = 1
+		args, err := ParseThreads(a)
+		if err != nil {
+			t.Fatalf("env: threads: %v: unexpected error: %v", a, err)
+		}
+		if len(args) != 2 || args[0] != "p.wagday" {
+			t.Errorf("env: threads: %v: args %v", a, args)
+		}
+		if Threads() != 4 {
+			t.Errorf("env: threads: %v: threads %d, want %d", a, Threads(), 4)
+		}
+		if !strings.Contains(String(), "threads=4") {
+			t.Errorf("env: threads: %q: threads not found", String())
+		}
+	}
+
+	for _, a := range [][]string{
+		{"--threads"},
+		{"--threads", "0", "p.wagday"},
+		{"--threads=x", "p.wagday"},
+	} {
+		if _, err := ParseThreads(a); err == nil {
+			t.Errorf("env: threads: %v: expecting error", a)
+		}
+	}
+}
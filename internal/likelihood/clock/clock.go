@@ -0,0 +1,206 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package clock implements the l.clock command,
+// i.e. estimate a global substitution rate.
+package clock
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/likelihood"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `l.clock [-a|--age <value>] [--alpha <value>]
+		[-c|--calib <file>] [--gamma <number>] [--model <definition>]
+		[-s|--subst] [-t|--tree <treefile>] <dataset>`,
+	Short: "estimate a global substitution rate",
+	Long: `
+Command l.clock reads an ultrametric tree (i.e. a tree with branch
+lengths in time units), and estimates the global substitution rate
+under a strict clock, that is, the rate that maximizes the likelihood
+of the data when the branch lengths (in substitutions per site) are
+the product of the rate and the branch times.
+
+The rate, in substitutions per site per time unit, is printed in the
+standard output, followed by the tree. By default the tree is printed
+in time units. If the option -s, or --subst is set, the tree will be
+printed in substitution units (i.e. the branch times multiplied by the
+rate).
+
+If the branch lengths of the tree are not in the desired time units
+(for example, if the tree comes from a relative rate smoothing), the
+option -a, or --age, can be used to set the age of the root, and the
+tree will be re-scaled accordingly.
+
+If the tree is not ultrametric, a calibration file can be given with
+the option -c, or --calib, and the tree will be transformed into a
+calibrated chronogram before the estimation of the rate (see the help
+of t.chrono for the format of the calibration file).
+
+The tree will be read from the standard input, unless the option -t or
+--tree is defined with a tree file.
+
+Options are:
+
+    -a <value>
+    --age <value>
+      If set, the tree will be scaled so the root has the indicated
+      age.
+
+    --alpha <value>
+      Set the shape (alpha) of the gamma distribution of rates.
+      Default: 1.
+
+    -c <file>
+    --calib <file>
+      If set, the tree will be transformed into a chronogram, using
+      the calibrations in the indicated file.
+
+    --gamma <number>
+      If defined, and greater than 1, rate heterogeneity among
+      characters will be modeled with a discrete gamma distribution
+      with the indicated number of categories.
+
+    --model <definition>
+      If defined, models will be assigned to the characters, as a
+      list of model names and character ranges, separated by
+      semicolons (e.g. "jc:1-2555; mk2:2556-2922"). Valid models are
+      jc, poisson, mk<n>, and mkv<n>. See l.models.
+
+    -s
+    --subst
+      If set, the tree will be printed in substitution units.
+
+    -t <treefile>
+    --tree <treefile>
+      If defined, the tree will be read from the indicated file,
+      instead of the standard input.
+
+    <dataset>
+      The phylogenetic data matrix. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var age float64
+var calib string
+var subst bool
+var treefile string
+var model string
+var alpha float64
+var gamma int
+
+func register(c *cmdapp.Command) {
+	c.Flag.Float64Var(&age, "age", 0, "")
+	c.Flag.Float64Var(&age, "a", 0, "")
+	c.Flag.StringVar(&calib, "calib", "", "")
+	c.Flag.StringVar(&calib, "c", "", "")
+	c.Flag.BoolVar(&subst, "subst", false, "")
+	c.Flag.BoolVar(&subst, "s", false, "")
+	c.Flag.StringVar(&treefile, "tree", "", "")
+	c.Flag.StringVar(&treefile, "t", "", "")
+	c.Flag.StringVar(&model, "model", "", "")
+	c.Flag.Float64Var(&alpha, "alpha", 1, "")
+	c.Flag.IntVar(&gamma, "gamma", 0, "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a dataset filename", c.Name())
+	}
+	if age > 0 && calib != "" {
+		return errors.Errorf("%s: options --age and --calib are incompatible", c.Name())
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+	}
+	defer f.Close()
+
+	m, err := likelihood.NewMatrix(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+	}
+	if err := m.SetModels(model); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if err := m.SetGamma(gamma, alpha); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	tf := os.Stdin
+	if treefile != "" {
+		tf, err = os.Open(treefile)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), treefile)
+		}
+		defer tf.Close()
+	}
+
+	tp, err := tree.Read(tf)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing tree", c.Name())
+	}
+	if !tp.Lens {
+		return errors.Errorf("%s: tree without branch lengths", c.Name())
+	}
+	if calib != "" {
+		cals, err := readCalibrations(calib)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		if err := tp.Chronogram(cals); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	}
+	if !tp.IsUltrametric(0.01) {
+		return errors.Errorf("%s: tree is not ultrametric", c.Name())
+	}
+	if age > 0 {
+		tp.Scale(age / tp.Age())
+	}
+
+	tr, err := likelihood.FromTopology(tp, m)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	r := tr.ClockRate()
+	fmt.Printf("# Root age: %.6f\n", tp.Age())
+	fmt.Printf("# Clock rate: %.6g substitutions per site per time unit\n", r)
+	fmt.Printf("# Tree -log Likelihood: %.6f\n", -tr.Like())
+	if subst {
+		tr.Write(os.Stdout, true)
+	} else {
+		tp.Write(os.Stdout, true)
+	}
+	fmt.Printf("\n")
+	return nil
+}
+
+func readCalibrations(name string) ([]tree.Calibration, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return tree.ReadCalibrations(f)
+}
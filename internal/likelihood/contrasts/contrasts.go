@@ -0,0 +1,192 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package contrasts implements the l.contrasts command,
+// i.e. calculate independent contrasts of continuous characters.
+package contrasts
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/likelihood"
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `l.contrasts [-c|--chars <list>] [-t|--tree <treefile>]
+		<dataset>`,
+	Short: "calculate independent contrasts of continuous characters",
+	Long: `
+Command l.contrasts reads a tree with branch lengths, and a table of
+continuous characters, and calculates the phylogenetic independent
+contrasts (Felsenstein 1985, Am. Nat. 125: 1) of each character.
+
+In the table of continuous characters, the first line is a header,
+with a label for the terminals column, and the name of each character.
+Each following line has the name of a terminal, and the value of each
+character, separated by spaces, or tabs. Missing values are indicated
+with '?'. Terminals without a value in any of the evaluated characters
+are pruned from the tree, and a warning is printed.
+
+The tree is printed as a comment, with the internal nodes labeled by
+its identifier, followed by a tab-delimited table with the node, the
+character, the estimated value at the node, the raw contrast (the
+difference between the values of the first and second descendants of
+the node), the variance of the contrast (the sum of the adjusted
+branch lengths of the descendants), and the standardized contrast
+(the raw contrast divided by the square root of its variance). In a
+polytomy, the descendants are taken as resolved with branches of
+length 0, so the node has a contrast for each additional descendant.
+
+The option -c, or --chars, can be used to define the characters to be
+evaluated. By default, all characters are evaluated.
+
+The tree will be read from the standard input, unless the option -t or
+--tree is defined with a tree file.
+
+Options are:
+
+    -c <list>
+    --chars <list>
+      If set, only the indicated characters will be evaluated. The
+      characters can be given as a list of numbers, or ranges (e.g.
+      "1-5 8"). The first character is 1.
+
+    -t <treefile>
+    --tree <treefile>
+      If defined, the tree will be read from the indicated file,
+      instead of the standard input.
+
+    <dataset>
+      The table of continuous characters. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var chars string
+var treefile string
+
+func register(c *cmdapp.Command) {
+	c.Flag.StringVar(&chars, "chars", "", "")
+	c.Flag.StringVar(&chars, "c", "", "")
+	c.Flag.StringVar(&treefile, "tree", "", "")
+	c.Flag.StringVar(&treefile, "t", "", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a dataset filename", c.Name())
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+	}
+	defer f.Close()
+
+	cm, err := matrix.ReadContinuous(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+	}
+
+	var ls []int
+	if chars != "" {
+		ls, err = matrix.ParseRange(chars, len(cm.Chars))
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	} else {
+		for i := range cm.Chars {
+			ls = append(ls, i)
+		}
+	}
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	tf := os.Stdin
+	if treefile != "" {
+		tf, err = os.Open(treefile)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), treefile)
+		}
+		defer tf.Close()
+	}
+
+	tp, err := tree.Read(tf)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing tree", c.Name())
+	}
+	if !tp.Lens {
+		return errors.Errorf("%s: tree without branch lengths", c.Name())
+	}
+
+	var del []string
+	for _, nm := range tp.Terms() {
+		v, ok := cm.Values[nm]
+		if !ok {
+			fmt.Printf("# Warning: terminal %s without data: removed\n", nm)
+			del = append(del, nm)
+			continue
+		}
+		for _, ch := range ls {
+			if math.IsNaN(v[ch]) {
+				fmt.Printf("# Warning: terminal %s without value for %s: removed\n", nm, cm.Chars[ch])
+				del = append(del, nm)
+				break
+			}
+		}
+	}
+	if len(del) > 0 {
+		tp.Prune(del)
+	}
+	if len(tp.Terms()) < 3 {
+		return errors.Errorf("%s: less than three terminals with data", c.Name())
+	}
+
+	// pre-order identifiers of the nodes
+	ids := make(map[*tree.Node]int)
+	next := len(tp.Terms()) + 1
+	for _, n := range tp.Nodes() {
+		if n.IsTerm() {
+			continue
+		}
+		ids[n] = next
+		n.Label = strconv.Itoa(next)
+		next++
+	}
+	fmt.Printf("# ")
+	tp.Write(os.Stdout, true)
+	fmt.Printf("\n")
+
+	fmt.Printf("node\tchar\tvalue\tcontrast\tvariance\tstandardized\n")
+	for _, ch := range ls {
+		cs, err := likelihood.Contrasts(tp, cm.Char(ch))
+		if err != nil {
+			return errors.Wrapf(err, "%s: character %s", c.Name(), cm.Chars[ch])
+		}
+		sort.SliceStable(cs, func(i, j int) bool {
+			return ids[cs[i].Node] < ids[cs[j].Node]
+		})
+		for _, x := range cs {
+			fmt.Printf("%d\t%s\t%.6g\t%.6g\t%.6g\t%.6g\n", ids[x.Node], cm.Chars[ch], x.Value, x.Raw, x.Var, x.Std)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package genetrees implements the l.genetrees command,
+// i.e. estimate a likelihood tree for each gene.
+package genetrees
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/likelihood"
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/parsimony"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `l.genetrees [--alpha <value>] [-c|--cpu <number>]
+		[--gamma <number>] [-m|--max <number>] [-o|--output <file>]
+		[-p|--partitions <file>] [-s|--slack <number>] <dataset>`,
+	Short: "estimate a likelihood tree for each gene",
+	Long: `
+Command l.genetrees reads a partitioned supermatrix, and estimates a
+maximum likelihood tree for each partition (e.g. each gene). The trees
+are written in NEXUS format, to be used by summary methods (for
+example, t.species, or t.reconcile).
+
+By default, each partition of the dataset (for example, the one
+produced by l.parts) is used as a gene, or if the dataset does not
+have partitions, each block of the dataset. With the option -p, or
+--partitions, a partition file can be used to define the genes. Each
+gene is analyzed only with the terminals with data in the gene, and
+genes with less than 4 terminals are ignored.
+
+Each tree is searched starting from a Wagner tree, that is improved
+under likelihood with a hybrid SPR branch swapping (see l.search).
+The characters use the default models, i.e. jc for DNA, poisson for
+protein, and mk<n> for other characters.
+
+The genes are analyzed concurrently. By default, as many genes as
+processors in the computer are analyzed at the same time, and it can
+be changed with the option -c, or --cpu. As the random numbers are
+shared by all the searches, the results are only reproducible (see
+RAMITA_SEED) if a single processor is used.
+
+Each tree is named with the name of its gene, and its log likelihood
+is stored as a comment of the tree. By default, the trees are written
+in the standard output, and other messages are printed in the
+standard error. If the option -o, or --output, is defined, the trees
+will be written in the indicated file, and the messages will be
+printed in the standard output. The messages include the number of
+terminals, and the -log likelihood of each gene.
+
+Options are:
+
+    --alpha <value>
+      Set the shape (alpha) of the gamma distribution of rates.
+      Default: 1.
+
+    -c <number>
+    --cpu <number>
+      Set the number of genes analyzed at the same time. By default
+      it is the number of processors.
+
+    --gamma <number>
+      If defined, and greater than 1, rate heterogeneity among
+      characters will be modeled with a discrete gamma distribution
+      with the indicated number of categories.
+
+    -m <number>
+    --max <number>
+      Set the maximum number of rearrangements evaluated under
+      likelihood in each round. Default: 10.
+
+    -o <file>
+    --output <file>
+      If defined, the trees will be written in the indicated file.
+
+    -p <file>
+    --partitions <file>
+      If defined, the genes will be read from the indicated
+      partition file.
+
+    -s <number>
+    --slack <number>
+      Set the maximum number of extra parsimony steps of the
+      rearrangements evaluated under likelihood. Default: 2.
+
+    <dataset>
+      The phylogenetic data matrix. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var max int
+var slack int
+var cpu int
+var output string
+var partfile string
+var alpha float64
+var gamma int
+
+func register(c *cmdapp.Command) {
+	c.Flag.IntVar(&max, "max", 10, "")
+	c.Flag.IntVar(&max, "m", 10, "")
+	c.Flag.IntVar(&slack, "slack", 2, "")
+	c.Flag.IntVar(&slack, "s", 2, "")
+	c.Flag.IntVar(&cpu, "cpu", runtime.NumCPU(), "")
+	c.Flag.IntVar(&cpu, "c", runtime.NumCPU(), "")
+	c.Flag.StringVar(&output, "output", "", "")
+	c.Flag.StringVar(&output, "o", "", "")
+	c.Flag.StringVar(&partfile, "partitions", "", "")
+	c.Flag.StringVar(&partfile, "p", "", "")
+	c.Flag.Float64Var(&alpha, "alpha", 1, "")
+	c.Flag.IntVar(&gamma, "gamma", 0, "")
+}
+
+// A gene is the matrix
+// and the estimated tree
+// of a gene.
+type gene struct {
+	name string
+	m    *matrix.Matrix
+	tr   *likelihood.Tree
+	err  error
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a dataset filename", c.Name())
+	}
+	if slack < 0 {
+		return errors.Errorf("%s: invalid slack: %d", c.Name(), slack)
+	}
+	if max < 0 {
+		return errors.Errorf("%s: invalid number of rearrangements: %d", c.Name(), max)
+	}
+	if cpu < 1 {
+		return errors.Errorf("%s: invalid number of processors: %d", c.Name(), cpu)
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+	}
+	m, err := matrix.NewMatrix(f)
+	f.Close()
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+	}
+
+	ps := m.Partitions()
+	if partfile != "" {
+		ps, err = matrix.ReadPartitionFile(partfile, len(m.Kind))
+		if err != nil {
+			return errors.Wrapf(err, "%s: while reading %s", c.Name(), partfile)
+		}
+	}
+	if ps == nil {
+		ps = m.BlockPartitions()
+	}
+
+	// with the standard output as the tree file,
+	// messages are printed in the standard error
+	msg := os.Stderr
+	if output != "" {
+		msg = os.Stdout
+	}
+	if err := env.Header(msg); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	var genes []*gene
+	for _, p := range ps {
+		gm := m.PartitionMatrix(p)
+		if len(gm.Names) < 4 {
+			fmt.Fprintf(msg, "# Warning: gene %s: %d terminals: ignored\n", p.Name, len(gm.Names))
+			continue
+		}
+		genes = append(genes, &gene{name: p.Name, m: gm})
+	}
+	if len(genes) == 0 {
+		return errors.Errorf("%s: no genes with at least 4 terminals", c.Name())
+	}
+
+	jobs := make(chan *gene)
+	var wg sync.WaitGroup
+	for i := 0; i < cpu; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for g := range jobs {
+				g.tr, g.err = search(g.m)
+			}
+		}()
+	}
+	for _, g := range genes {
+		jobs <- g
+	}
+	close(jobs)
+	wg.Wait()
+
+	var tw *tree.Writer
+	ls := make([]string, 0, len(m.Names))
+	for nm := range m.Names {
+		ls = append(ls, nm)
+	}
+	if output != "" {
+		tw, err = tree.Create(output, ls)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		defer tw.Close()
+	} else {
+		tw = tree.NewWriter(os.Stdout, ls)
+	}
+	if err := tw.Comment(env.String()); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	fmt.Fprintf(msg, "# gene\tterms\t-lnL\n")
+	for _, g := range genes {
+		if g.err != nil {
+			return errors.Wrapf(g.err, "%s: gene %s", c.Name(), g.name)
+		}
+		fmt.Fprintf(msg, "# %s\t%d\t%.6f\n", g.name, len(g.m.Names), -g.tr.Like())
+		tp := g.tr.Topology()
+		tp.Name = g.name
+		tp.Root.Comment = fmt.Sprintf("lnL=%.6f", g.tr.Like())
+		if err := tw.Write(tp); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	return nil
+}
+
+// Search returns the maximum likelihood tree
+// of a matrix,
+// starting from a Wagner tree.
+func search(mt *matrix.Matrix) (*likelihood.Tree, error) {
+	m := likelihood.NewFromMatrix(mt)
+	if err := m.SetGamma(gamma, alpha); err != nil {
+		return nil, err
+	}
+	tr, err := likelihood.FromTopology(parsimony.Wagner(mt).Topology(), m)
+	if err != nil {
+		return nil, err
+	}
+	tr.Refine()
+	if _, err := tr.HybridSPR(slack, max); err != nil {
+		return nil, err
+	}
+	return tr, nil
+}
@@ -0,0 +1,100 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package jplace implements the l.jplace command,
+// i.e. print the placements of a jplace file.
+package jplace
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/likelihood"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `l.jplace [--best] [<jplace-file>]`,
+	Short:     "print the placements of a jplace file",
+	Long: `
+Command l.jplace reads a file in jplace format (as written by l.place,
+pplacer, EPA, or other placement tools), and prints the placements of
+each query as a tab-delimited table, with the name of the query, the
+terminals of the branch of the placement (i.e. the terminals descendant
+from the branch), the log likelihood of the placement, its likelihood
+weight ratio, and the distal and pendant lengths of the placement.
+
+Fields that are not defined in the file are printed as 0. A query with
+multiple names is printed once for each name.
+
+The file will be read from the standard input, unless a file is given
+as an argument.
+
+Options are:
+
+    --best
+      If set, only the placement with the highest likelihood weight
+      ratio of each query will be printed.
+
+    <jplace-file>
+      If defined, the jplace file will be read from the indicated
+      file, instead of the standard input.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var best bool
+
+func register(c *cmdapp.Command) {
+	c.Flag.BoolVar(&best, "best", false, "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("%s: too many arguments", c.Name())
+	}
+
+	f := os.Stdin
+	if len(args) == 1 {
+		var err error
+		f, err = os.Open(args[0])
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+		}
+		defer f.Close()
+	}
+
+	t, pqs, err := likelihood.ReadJPlace(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing placements", c.Name())
+	}
+	nodes := t.Nodes()
+
+	fmt.Printf("# query\tbranch\tlikelihood\tlwr\tdistal\tpendant\n")
+	for _, pq := range pqs {
+		ps := pq.Placements
+		if best && len(ps) > 0 {
+			b := 0
+			for i, p := range ps {
+				if p.Weight > ps[b].Weight {
+					b = i
+				}
+			}
+			ps = ps[b : b+1]
+		}
+		for _, p := range ps {
+			terms := strings.Join(nodes[p.Edge].Terms(), ",")
+			fmt.Printf("%s\t%s\t%.6f\t%.6f\t%.6f\t%.6f\n", pq.Name, terms, p.Like, p.Weight, p.Distal, p.Pendant)
+		}
+	}
+	return nil
+}
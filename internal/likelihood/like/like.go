@@ -11,13 +11,19 @@ import (
 	"os"
 
 	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
 	"github.com/js-arias/ramita/likelihood"
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/tree"
 
 	"github.com/pkg/errors"
 )
 
 var cmd = &cmdapp.Command{
-	UsageLine: `l.like [-o|--optimize] [-p|--print]
+	UsageLine: `l.like [--alpha <value>] [-c|--checkpoint <number>]
+		[--fix-alpha] [--gamma <number>] [--model <definition>]
+		[--node-ids] [-o|--optimize] [--params] [-p|--print]
+		[--prune] [--scale <value>] [-s|--scheme <file>]
 		[-t|--tree <treefile>] <dataset>`,
 	Short: "print the likelihood of a tree",
 	Long: `
@@ -31,22 +37,96 @@ improve branch lengths. If this option is combined with -p, or
 --print, option then the tree with the new branch lengths will be
 printed in the standard output.
 
+The printed tree can be annotated for other programs (for example,
+dating tools). With the option --node-ids, internal nodes are labeled
+with a number, in pre-order, starting at the root with the number of
+terminals plus one (as in PAML). With the option --scale, branch
+lengths are multiplied by the indicated value. With the option
+--params, a comment with the model parameters (the log likelihood,
+the number of characters, the scale, the gamma categories and alpha,
+and the characters of each model, with its relative rate, if any) is
+printed after the tree.
+
+If the option -s, or --scheme, is defined with a partition scheme file
+(for example, the one produced by l.parts), each partition will have
+its own relative rate, estimated with the initial branch lengths of
+the tree. If the dataset is a bundle file (see mat.bundle) with a
+partition scheme, and the option -s is not defined, the scheme stored
+in the bundle will be used.
+
+For large trees, the option -c, or --checkpoint, sets a low-memory
+mode, in which the conditional likelihoods are only stored in nodes
+separated by the indicated number of levels (checkpoint nodes), and
+the conditionals of the other nodes are recomputed when needed. This
+trades running time for memory.
+
 The tree will be read from the standard input, unless the option
 -t or --tree is defined with a tree file.
 
+By default, the tree must have all the terminals of the matrix. If
+the option --prune is set, the terminals of the matrix that are not
+in the tree are removed from the matrix (with a warning), for example,
+to evaluate a published tree with a larger matrix.
+
 Options are:
 
+    --alpha <value>
+      Set the shape (alpha) of the gamma distribution of rates.
+      Default: 1.
+
+    -c <number>
+    --checkpoint <number>
+      If defined, and greater than 1, the conditionals will be
+      stored only on checkpoint nodes, separated by the indicated
+      number of levels.
+
+    --fix-alpha
+      If set, the shape (alpha) of the gamma distribution will not
+      be estimated when the tree is optimized.
+
+    --gamma <number>
+      If defined, and greater than 1, rate heterogeneity among
+      characters will be modeled with a discrete gamma distribution
+      with the indicated number of categories.
+
+    --model <definition>
+      If defined, models will be assigned to the characters, as a
+      list of model names and character ranges, separated by
+      semicolons (e.g. "jc:1-2555; mk2:2556-2922"). Valid models are
+      jc, poisson, mk<n>, and mkv<n>. See l.models.
+
+    --node-ids
+      If set, the internal nodes of the printed tree will be labeled
+      with a numeric identifier.
+
     -o
     --optimize
       Try to optimize the current branch lengths to increase the
       likelihood.
 
+    --params
+      If set, a comment with the model parameters will be printed
+      after the tree.
+
     -p
     --print
       If defined, it will print the tree with the cuurent branch
       lengths (in the case of an optimization is made, with the
       optimal ones).
 
+    --prune
+      If set, the terminals of the matrix that are not in the tree
+      will be removed from the matrix.
+
+    --scale <value>
+      If defined, the branch lengths of the printed tree will be
+      multiplied by the indicated value.
+
+    -s <file>
+    --scheme <file>
+      If defined, the partition scheme will be read from the
+      indicated file.
+
     -t <treefile>
     --tree <treefile>
       If defined, the tree will be read from the indicated file,
@@ -63,23 +143,48 @@ func init() {
 	cmdapp.Add(cmd)
 }
 
+var checkpoint int
 var treefile string
 var optimize bool
 var print bool
+var prune bool
+var scheme string
+var model string
+var alpha float64
+var fixAlpha bool
+var gamma int
+var nodeIDs bool
+var params bool
+var scale float64
 
 func register(c *cmdapp.Command) {
+	c.Flag.IntVar(&checkpoint, "checkpoint", 0, "")
+	c.Flag.IntVar(&checkpoint, "c", 0, "")
 	c.Flag.StringVar(&treefile, "tree", "", "")
 	c.Flag.StringVar(&treefile, "t", "", "")
 	c.Flag.BoolVar(&optimize, "optimize", false, "")
 	c.Flag.BoolVar(&optimize, "o", false, "")
 	c.Flag.BoolVar(&print, "print", false, "")
 	c.Flag.BoolVar(&print, "p", false, "")
+	c.Flag.BoolVar(&prune, "prune", false, "")
+	c.Flag.StringVar(&scheme, "scheme", "", "")
+	c.Flag.StringVar(&scheme, "s", "", "")
+	c.Flag.StringVar(&model, "model", "", "")
+	c.Flag.Float64Var(&alpha, "alpha", 1, "")
+	c.Flag.BoolVar(&fixAlpha, "fix-alpha", false, "")
+	c.Flag.IntVar(&gamma, "gamma", 0, "")
+	c.Flag.BoolVar(&nodeIDs, "node-ids", false, "")
+	c.Flag.BoolVar(&params, "params", false, "")
+	c.Flag.Float64Var(&scale, "scale", 0, "")
 }
 
 func run(c *cmdapp.Command, args []string) error {
 	if len(args) != 1 {
 		return errors.Errorf("%s: expecting a dataset filename", c.Name())
 	}
+	if scale < 0 {
+		return errors.Errorf("%s: invalid scale: %.6f", c.Name(), scale)
+	}
 
 	f, err := os.Open(args[0])
 	if err != nil {
@@ -100,18 +205,68 @@ func run(c *cmdapp.Command, args []string) error {
 		}
 		defer tf.Close()
 	}
+	tp, err := tree.Read(tf)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing tree", c.Name())
+	}
+	var del []string
+	if prune {
+		del = m.M.Outside(tp.Terms())
+		if len(del) > 0 {
+			m = likelihood.NewFromMatrix(m.M.DropTaxa(del))
+		}
+	}
 
-	tr, err := likelihood.ReadTree(tf, m)
+	if err := m.SetModels(model); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if err := m.SetGamma(gamma, alpha); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	m.FixAlpha(fixAlpha)
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	m.SetCheckpoints(checkpoint)
+
+	for _, nm := range del {
+		fmt.Printf("# Warning: terminal %s not in tree: removed\n", nm)
+	}
+
+	tr, err := likelihood.FromTopology(tp, m)
 	if err != nil {
 		return errors.Wrapf(err, "%s: when parsing tree", c.Name())
 	}
+	if checkpoint > 1 {
+		fmt.Printf("# Nodes with stored conditionals: %d of %d\n", tr.Stored(), len(tr.Nodes))
+	}
+	ps := m.M.Partitions()
+	if scheme != "" {
+		ps, err = matrix.ReadPartitionFile(scheme, m.Chars())
+		if err != nil {
+			return errors.Wrapf(err, "%s: while reading %s", c.Name(), scheme)
+		}
+	}
+	if ps != nil {
+		for _, f := range tr.SetPartitions(ps) {
+			fmt.Printf("# Partition %s: rate %.6f\n", f.Partition.Name, f.Rate)
+		}
+	}
 	if optimize {
 		fmt.Printf("# Origina tree -log Likelihood: %.6f\n", -tr.Like())
 		tr.Refine()
 	}
 	fmt.Printf("# Tree -log Likelihood: %.6f\n", -tr.Like())
+	if cats, a := m.Gamma(); cats > 1 {
+		fmt.Printf("# Gamma categories: %d\talpha: %.6f\n", cats, a)
+	}
 	if print {
-		tr.Write(os.Stdout, true)
+		tr.WriteOpts(os.Stdout, true, likelihood.WriteOptions{
+			IDs:    nodeIDs,
+			Scale:  scale,
+			Params: params,
+		})
 		fmt.Printf("\n")
 	}
 	return nil
@@ -0,0 +1,147 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package models implements the l.models command,
+// i.e. print the models assigned to the characters.
+package models
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/likelihood"
+	"github.com/js-arias/ramita/matrix"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `l.models [--alpha <value>] [--gamma <number>]
+		[--model <definition>] [<dataset>]`,
+	Short: "print the models assigned to the characters",
+	Long: `
+Command l.models reads a data matrix, and prints the model of evolution
+assigned to each character in a likelihood analysis.
+
+By default, DNA characters are assigned to the Jukes-Cantor model (jc),
+protein characters to the Poisson model of amino acids (poisson), and
+other characters to the Mk model (a Poisson model) with the number of
+states of the character, as the largest observed state (e.g. mk2 for
+binary characters, mk3 for characters with three states).
+
+The output is a partition scheme, in which each line is a model, with
+the model name, an equal sign, and the list of characters assigned to
+the model (the first character is 1). Before each model, a comment
+line indicates the number of states of the model, and the number of
+characters assigned to it.
+
+The option --model, also available in other likelihood commands,
+assigns models to the characters. It is a list of assignments,
+separated by semicolons, each one with a model name, a colon, and the
+list of characters, given as numbers, or ranges (e.g. "1-5 8"), that
+can include a step (e.g. "1-300\3" for the first codon position of the
+first 300 characters). For example:
+
+	--model "jc:1-2555; mk2:2556-2922"
+
+Valid models are jc, poisson (with 20 states), mk<n>, and mkv<n>, with
+n from 2 to 8. The mkv<n> model is the mk<n> model, conditioned on the
+characters being variable, as it is usual in morphological data, in
+which constant characters are not scored. Characters not included in
+the definition keep the default model. A model can not be assigned to
+a character with more states than the model.
+
+With the option --gamma, also available in other likelihood commands,
+rate heterogeneity among characters is modeled with a discrete gamma
+distribution, with the indicated number of categories, and the shape
+(alpha) set with the option --alpha. The relative rate of each
+category is printed.
+
+Options are:
+
+    --alpha <value>
+      Set the shape (alpha) of the gamma distribution of rates.
+      Default: 1.
+
+    --gamma <number>
+      If defined, and greater than 1, rate heterogeneity among
+      characters will be modeled with a discrete gamma distribution
+      with the indicated number of categories.
+
+    --model <definition>
+      If defined, models will be assigned to the characters, using
+      the indicated definition.
+
+    <dataset>
+      The phylogenetic data matrix. If not given explicitly, it will
+      be read from the standard input.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var model string
+var alpha float64
+var gamma int
+
+func register(c *cmdapp.Command) {
+	c.Flag.StringVar(&model, "model", "", "")
+	c.Flag.Float64Var(&alpha, "alpha", 1, "")
+	c.Flag.IntVar(&gamma, "gamma", 0, "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("%s: too many arguments", c.Name())
+	}
+
+	f := os.Stdin
+	if len(args) == 1 {
+		var err error
+		f, err = os.Open(args[0])
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+		}
+		defer f.Close()
+	}
+
+	pm, err := matrix.NewMatrix(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+	}
+	m := likelihood.NewFromMatrix(pm)
+	if err := m.SetModels(model); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if err := m.SetGamma(gamma, alpha); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	ps := m.ModelPartitions()
+	fmt.Printf("# Characters: %d\n", m.Chars())
+	if cats, a := m.Gamma(); cats > 1 {
+		fmt.Printf("# Gamma categories: %d\talpha: %.6f\n", cats, a)
+		for i, r := range likelihood.GammaRates(a, cats) {
+			fmt.Printf("# Category %d: rate %.6f\n", i+1, r)
+		}
+	}
+	fmt.Printf("# Models: %d\n", len(ps))
+	for _, p := range ps {
+		md := m.Model(p.Chars[0])
+		fmt.Printf("# %s: states %d\tcharacters %d\n", p.Name, md.States(), len(p.Chars))
+		if err := matrix.WritePartitions(os.Stdout, []matrix.Partition{p}); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	}
+	return nil
+}
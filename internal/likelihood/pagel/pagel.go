@@ -0,0 +1,208 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package pagel implements the l.pagel command,
+// i.e. estimate Pagel's branch length transformations.
+package pagel
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/likelihood"
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `l.pagel [-c|--chars <list>] [--continuous]
+		[-t|--tree <treefile>] [-x|--transform <name>] <dataset>`,
+	Short: "estimate Pagel's branch length transformations",
+	Long: `
+Command l.pagel reads a tree with branch lengths, and for each character
+of a dataset, estimates by maximum likelihood the parameters of Pagel's
+transformations of the branch lengths. Valid transformations are:
+
+    lambda   internal branches are multiplied by lambda, and terminal
+             branches are extended, so the distance from the root to
+             the terminals is unchanged. A lambda of 0 is a star tree
+             (i.e. without phylogenetic signal). Estimated between 0
+             and 1.
+    kappa    each branch length is raised to the power kappa. A kappa
+             of 0 is a punctuated model (i.e. all branches with the
+             same length). Estimated between 0 and 1.
+    delta    the distance from the root to each node is raised to the
+             power delta. A delta greater than 1 increases the
+             changes near the terminals. Estimated between 0.01 and 3.
+
+By default, all the transformations are estimated. The option -x, or
+--transform, can be used to estimate a single transformation.
+
+By default, the dataset is a matrix of discrete characters, and each
+character is evaluated with its Mk model. If the option --continuous
+is set, the dataset is a table of continuous characters, and each
+character is evaluated with a Brownian motion model. In a table of
+continuous characters, the first line is a header, with a label for
+the terminals column, and the name of each character. Each following
+line has the name of a terminal, and the value of each character,
+separated by spaces, or tabs. Missing values are indicated with '?'.
+Terminals without a value are pruned from the tree.
+
+The results are printed as a table with the character, the
+transformation, the estimated value, the rate (in changes per unit of
+branch length in discrete characters, or the variance of the Brownian
+motion in continuous characters), the -log likelihood of the
+transformed tree, the -log likelihood of the untransformed tree (i.e.
+a parameter value of 1), the likelihood ratio statistic, and its
+p-value (from a chi-square distribution with one degree of freedom).
+
+The option -c, or --chars, can be used to define the characters to be
+evaluated.
+
+The tree will be read from the standard input, unless the option -t or
+--tree is defined with a tree file.
+
+Options are:
+
+    -c <list>
+    --chars <list>
+      If set, only the indicated characters will be evaluated. The
+      characters can be given as a list of numbers, or ranges (e.g.
+      "1-5 8"). The first character is 1.
+
+    --continuous
+      If set, the dataset will be read as a table of continuous
+      characters.
+
+    -t <treefile>
+    --tree <treefile>
+      If defined, the tree will be read from the indicated file,
+      instead of the standard input.
+
+    -x <name>
+    --transform <name>
+      If defined, only the indicated transformation will be
+      estimated.
+
+    <dataset>
+      The data matrix. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var chars string
+var continuous bool
+var treefile string
+var transform string
+
+func register(c *cmdapp.Command) {
+	c.Flag.StringVar(&chars, "chars", "", "")
+	c.Flag.StringVar(&chars, "c", "", "")
+	c.Flag.BoolVar(&continuous, "continuous", false, "")
+	c.Flag.StringVar(&treefile, "tree", "", "")
+	c.Flag.StringVar(&treefile, "t", "", "")
+	c.Flag.StringVar(&transform, "transform", "", "")
+	c.Flag.StringVar(&transform, "x", "", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a dataset filename", c.Name())
+	}
+	tfs := []likelihood.Transform{likelihood.Lambda, likelihood.Kappa, likelihood.Delta}
+	if transform != "" {
+		tf, err := likelihood.ParseTransform(transform)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		tfs = []likelihood.Transform{tf}
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+	}
+	defer f.Close()
+
+	var m *likelihood.Matrix
+	var cm *matrix.Continuous
+	var nc int
+	if continuous {
+		cm, err = matrix.ReadContinuous(f)
+		if err != nil {
+			return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+		}
+		nc = len(cm.Chars)
+	} else {
+		m, err = likelihood.NewMatrix(f)
+		if err != nil {
+			return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+		}
+		nc = m.Chars()
+	}
+
+	var ls []int
+	if chars != "" {
+		ls, err = matrix.ParseRange(chars, nc)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	} else {
+		for i := 0; i < nc; i++ {
+			ls = append(ls, i)
+		}
+	}
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	tf := os.Stdin
+	if treefile != "" {
+		tf, err = os.Open(treefile)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), treefile)
+		}
+		defer tf.Close()
+	}
+
+	tp, err := tree.Read(tf)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing tree", c.Name())
+	}
+	if !tp.Lens {
+		return errors.Errorf("%s: tree without branch lengths", c.Name())
+	}
+
+	fmt.Printf("char\ttransform\tvalue\trate\t-lnL\t-lnL(1)\tLR\tp\n")
+	for _, ch := range ls {
+		name := fmt.Sprintf("%d", ch+1)
+		if continuous {
+			name = cm.Chars[ch]
+		}
+		for _, x := range tfs {
+			var pf likelihood.PagelFit
+			if continuous {
+				pf, err = likelihood.FitPagelContinuous(tp, cm.Char(ch), x)
+			} else {
+				pf, err = likelihood.FitPagelDiscrete(tp, m, ch, x)
+			}
+			if err != nil {
+				fmt.Printf("# Warning: char %s: %v\n", name, err)
+				break
+			}
+			fmt.Printf("%s\t%s\t%.6f\t%.6g\t%.6f\t%.6f\t%.6f\t%.6f\n", name, x, pf.Value, pf.Rate, -pf.LogLike, -pf.NullLike, pf.LR, pf.P)
+		}
+	}
+	return nil
+}
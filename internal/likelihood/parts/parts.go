@@ -0,0 +1,266 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package parts implements the l.parts command,
+// i.e. make a partition scheme.
+package parts
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/likelihood"
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `l.parts [--alpha <value>] [--code <table>] [-g|--genes <file>]
+		[--gamma <number>] [-n|--nocodon] [-m|--merge]
+		[--model <definition>] [-t|--tree <treefile>] <dataset>`,
+	Short: "make a partition scheme",
+	Long: `
+Command l.parts reads a data matrix, and prints a partition scheme, in
+which the characters of each gene are split by codon position (i.e. a
+partition for each gene and codon position). Genes that are not DNA
+are kept as a single partition.
+
+By default, each block of the matrix is taken as a gene. With the
+option -g, or --genes, the gene boundaries are read from a gene file.
+In a gene file (as well as in the resulting partition scheme), each
+line defines a gene (or partition), with its name, an equal sign, and
+the list of characters, given as numbers, or ranges (e.g. "1-5 8"),
+that can include a step (e.g. "1-300\3" for the first codon position
+of the first 300 characters). The first character is 1. Lines starting
+with '#' are ignored. For example:
+
+	# genes
+	coi = 1-660
+	cytb = 661-1040
+
+Genes must start at the first codon position. When genes are split by
+codon positions, characters of the matrix that are not assigned to any
+gene will be included in a partition called "unassigned".
+
+Before the genes are split by codon positions, the reading frame of
+each DNA gene is checked, and a warning is printed for each internal
+stop codon, and for each frame shift (i.e. a codon with gaps in only
+some of its positions) found in a terminal, as well as for genes with
+a number of characters that is not a multiple of 3. By default, the
+standard genetic code is used, and another code can be set with the
+option --code, using the NCBI number of the table, or its name. Valid
+codes are:
+
+    1   standard
+    2   vertebrate mitochondrial
+    3   yeast mitochondrial
+    4   mold mitochondrial
+    5   invertebrate mitochondrial
+    9   echinoderm mitochondrial
+    11  bacterial
+
+If the option -m, or --merge, is set, the partitions will be merged
+using a greedy algorithm (as in PartitionFinder): at each step, the
+pair of partitions whose merging produces the best improvement of the
+Bayesian Information Criterion (BIC) of the scheme is merged, until no
+merge improves the BIC. Each partition is fitted with a relative rate,
+using the branch lengths of a tree. The tree will be read from the
+standard input, unless the option -t or --tree is defined with a tree
+file. If the tree does not have branch lengths, a default length of
+0.01 will be used. By default, the models of the characters are the
+ones given by l.models, and they can be changed with the option
+--model.
+
+Options are:
+
+    --alpha <value>
+      Set the shape (alpha) of the gamma distribution of rates.
+      Default: 1.
+
+    --code <table>
+      Set the genetic code used to check the reading frame of the
+      genes. Default: 1 (standard).
+
+    -g <file>
+    --genes <file>
+      If defined, the gene boundaries will be read from the indicated
+      file.
+
+    --gamma <number>
+      If defined, and greater than 1, rate heterogeneity among
+      characters will be modeled with a discrete gamma distribution
+      with the indicated number of categories.
+
+    -n
+    --nocodon
+      If set, genes will not be split by codon positions.
+
+    -m
+    --merge
+      If set, partitions will be merged using the BIC.
+
+    --model <definition>
+      If defined, models will be assigned to the characters, as a
+      list of model names and character ranges, separated by
+      semicolons (e.g. "jc:1-2555; mk2:2556-2922"). Valid models are
+      jc, poisson, mk<n>, and mkv<n>.
+
+    -t <treefile>
+    --tree <treefile>
+      If defined, the tree used to merge the partitions will be read
+      from the indicated file, instead of the standard input.
+
+    <dataset>
+      The phylogenetic data matrix. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var code string
+var genes string
+var nocodon bool
+var merge bool
+var model string
+var treefile string
+var alpha float64
+var gamma int
+
+func register(c *cmdapp.Command) {
+	c.Flag.StringVar(&genes, "genes", "", "")
+	c.Flag.StringVar(&genes, "g", "", "")
+	c.Flag.BoolVar(&nocodon, "nocodon", false, "")
+	c.Flag.BoolVar(&nocodon, "n", false, "")
+	c.Flag.StringVar(&code, "code", "", "")
+	c.Flag.BoolVar(&merge, "merge", false, "")
+	c.Flag.BoolVar(&merge, "m", false, "")
+	c.Flag.StringVar(&model, "model", "", "")
+	c.Flag.StringVar(&treefile, "tree", "", "")
+	c.Flag.StringVar(&treefile, "t", "", "")
+	c.Flag.Float64Var(&alpha, "alpha", 1, "")
+	c.Flag.IntVar(&gamma, "gamma", 0, "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a dataset filename", c.Name())
+	}
+	gc, err := matrix.ParseGeneticCode(code)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+	}
+	defer f.Close()
+
+	m, err := matrix.NewMatrix(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+	}
+	lm := likelihood.NewFromMatrix(m)
+	if err := lm.SetModels(model); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if err := lm.SetGamma(gamma, alpha); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	gs := m.BlockPartitions()
+	if genes != "" {
+		gs, err = matrix.ReadPartitionFile(genes, len(m.Kind))
+		if err != nil {
+			return errors.Wrapf(err, "%s: while reading %s", c.Name(), genes)
+		}
+	}
+
+	ps := gs
+	if !nocodon {
+		checkFrames(m, gs, gc)
+		ps = m.CodonPartitions(gs)
+	}
+	if merge {
+		tf := os.Stdin
+		if treefile != "" {
+			tf, err = os.Open(treefile)
+			if err != nil {
+				return errors.Wrapf(err, "%s: while opening %s", c.Name(), treefile)
+			}
+			defer tf.Close()
+		}
+		tp, err := tree.Read(tf)
+		if err != nil {
+			return errors.Wrapf(err, "%s: when parsing tree", c.Name())
+		}
+		tr, err := likelihood.FromTopology(tp, lm)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+
+		var init []likelihood.PartitionFit
+		for _, p := range ps {
+			init = append(init, tr.FitPartition(p))
+		}
+		fits := tr.MergePartitions(ps)
+		fmt.Printf("# Initial partitions: %d\tBIC: %.6f\n", len(init), tr.BIC(init))
+		fmt.Printf("# Merged partitions: %d\tBIC: %.6f\n", len(fits), tr.BIC(fits))
+		ps = make([]matrix.Partition, 0, len(fits))
+		for i, f := range fits {
+			p := f.Partition
+			p.Name = fmt.Sprintf("part%d", i+1)
+			fmt.Printf("# %s: %s: rate %.6f\tlog like %.6f\n", p.Name, f.Partition.Name, f.Rate, f.LogLike)
+			ps = append(ps, p)
+		}
+	}
+
+	fmt.Printf("# Partitions: %d\n", len(ps))
+	if err := matrix.WritePartitions(os.Stdout, ps); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	return nil
+}
+
+// CheckFrames prints a warning
+// for each problem in the reading frame
+// of the DNA genes.
+func checkFrames(m *matrix.Matrix, gs []matrix.Partition, gc *matrix.GeneticCode) {
+	for _, g := range gs {
+		dna := true
+		for _, c := range g.Chars {
+			if m.Kind[c] != matrix.DNA {
+				dna = false
+				break
+			}
+		}
+		if !dna {
+			continue
+		}
+		fp, err := m.CheckFrame(g, gc)
+		if err != nil {
+			fmt.Printf("# Warning: %v\n", err)
+			continue
+		}
+		for _, p := range fp {
+			if p.Stop {
+				fmt.Printf("# Warning: gene %s: terminal %s: internal stop codon at character %d\n", g.Name, p.Term, p.Char+1)
+				continue
+			}
+			fmt.Printf("# Warning: gene %s: terminal %s: frame shift at character %d\n", g.Name, p.Term, p.Char+1)
+		}
+	}
+}
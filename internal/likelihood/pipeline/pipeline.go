@@ -0,0 +1,322 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package pipeline implements the l.pipeline command,
+// i.e. compare the concatenated and the coalescent trees.
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/consensus"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/likelihood"
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/parsimony"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `l.pipeline [--alpha <value>] [--gamma <number>]
+		[-m|--max <number>] [-p|--partitions <file>]
+		[-s|--slack <number>] <dataset>`,
+	Short: "compare concatenated and coalescent trees",
+	Long: `
+Command l.pipeline reads a dataset with several genes, and estimates,
+in a single run, a maximum likelihood tree for each gene, a maximum
+likelihood tree of the concatenated data, and a species tree from the
+gene trees, and reports the conflicts between them.
+
+By default, each partition of the dataset (for example, the one
+produced by l.parts) is used as a gene, or if the dataset does not
+have partitions, each block of the dataset. With the option -p, or
+--partitions, a partition file can be used to define the genes. Each
+gene is analyzed only with the terminals with data in the gene, and
+genes with less than 4 terminals are ignored.
+
+Each tree is searched starting from a Wagner tree, that is improved
+under likelihood with a hybrid SPR branch swapping (see l.search).
+The characters use the default models, i.e. jc for DNA, poisson for
+protein, and mk<n> for other characters. The species tree is the tree
+that maximizes the quartets of the gene trees (see t.species), and its
+internal nodes are labeled with the local posterior probability.
+
+The results are printed as comments: for each gene, the number of
+terminals, the -log likelihood of its tree, and the number of clades
+of the concatenated and the species trees found in the gene tree, of
+the clades that can be evaluated in the gene (i.e. with at least two
+terminals of the gene in and outside the clade). Then the gene trees,
+the concatenated tree, and the species tree are printed, rooted on
+the outgroup of the dataset.
+
+Finally, a tab-delimited table is printed with each clade of the
+concatenated and the species trees, with a column indicating if the
+clade is found in the concatenated tree, a column indicating if the
+clade is found in the species tree, and a column with the number of
+gene trees with the clade, of the gene trees in which the clade can be
+evaluated.
+
+Options are:
+
+    --alpha <value>
+      Set the shape (alpha) of the gamma distribution of rates.
+      Default: 1.
+
+    --gamma <number>
+      If defined, and greater than 1, rate heterogeneity among
+      characters will be modeled with a discrete gamma distribution
+      with the indicated number of categories.
+
+    -m <number>
+    --max <number>
+      Set the maximum number of rearrangements evaluated under
+      likelihood in each round. Default: 10.
+
+    -p <file>
+    --partitions <file>
+      If defined, the genes will be read from the indicated
+      partition file.
+
+    -s <number>
+    --slack <number>
+      Set the maximum number of extra parsimony steps of the
+      rearrangements evaluated under likelihood. Default: 2.
+
+    <dataset>
+      The phylogenetic data matrix. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var max int
+var slack int
+var partfile string
+var alpha float64
+var gamma int
+
+func register(c *cmdapp.Command) {
+	c.Flag.IntVar(&max, "max", 10, "")
+	c.Flag.IntVar(&max, "m", 10, "")
+	c.Flag.IntVar(&slack, "slack", 2, "")
+	c.Flag.IntVar(&slack, "s", 2, "")
+	c.Flag.StringVar(&partfile, "partitions", "", "")
+	c.Flag.StringVar(&partfile, "p", "", "")
+	c.Flag.Float64Var(&alpha, "alpha", 1, "")
+	c.Flag.IntVar(&gamma, "gamma", 0, "")
+}
+
+// A gene stores the tree of a gene.
+type gene struct {
+	name  string
+	terms int
+	like  float64
+	t     *tree.Tree
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a dataset filename", c.Name())
+	}
+	if slack < 0 {
+		return errors.Errorf("%s: invalid slack: %d", c.Name(), slack)
+	}
+	if max < 0 {
+		return errors.Errorf("%s: invalid number of rearrangements: %d", c.Name(), max)
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+	}
+	m, err := matrix.NewMatrix(f)
+	f.Close()
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+	}
+	if empty := m.Empty(); len(empty) > 0 {
+		m = m.DropTaxa(empty)
+	}
+
+	ps := m.Partitions()
+	if partfile != "" {
+		ps, err = matrix.ReadPartitionFile(partfile, len(m.Kind))
+		if err != nil {
+			return errors.Wrapf(err, "%s: while reading %s", c.Name(), partfile)
+		}
+	}
+	if ps == nil {
+		ps = m.BlockPartitions()
+	}
+	if len(ps) < 2 {
+		return errors.Errorf("%s: %d genes, want at least 2", c.Name(), len(ps))
+	}
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	qs := &consensus.QuartetSet{}
+	var genes []gene
+	for _, p := range ps {
+		gm := m.PartitionMatrix(p)
+		if len(gm.Names) < 4 {
+			fmt.Printf("# Gene %s: %d terminals: ignored\n", p.Name, len(gm.Names))
+			continue
+		}
+		tr, err := search(gm)
+		if err != nil {
+			return errors.Wrapf(err, "%s: gene %s", c.Name(), p.Name)
+		}
+		g := gene{
+			name:  p.Name,
+			terms: len(gm.Names),
+			like:  tr.Like(),
+			t:     tr.Topology(),
+		}
+		if err := qs.Add(g.t); err != nil {
+			return errors.Wrapf(err, "%s: gene %s", c.Name(), p.Name)
+		}
+		genes = append(genes, g)
+	}
+	if len(genes) < 2 {
+		return errors.Errorf("%s: %d gene trees, want at least 2", c.Name(), len(genes))
+	}
+
+	ct, err := search(m)
+	if err != nil {
+		return errors.Wrapf(err, "%s: concatenated", c.Name())
+	}
+	concat := ct.Topology()
+	concat.Reroot(m.Out.Name)
+
+	st, err := qs.SpeciesTree()
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if err := st.Reroot(m.Out.Name); err == nil {
+		// the clades of the nodes
+		// are changed by the rerooting
+		for _, n := range st.Nodes() {
+			n.Label = ""
+		}
+		for _, b := range qs.LocalPP(st) {
+			b.Node.Label = fmt.Sprintf("%.2f", b.PP)
+		}
+	}
+
+	ccl := concat.Clades()
+	scl := st.Clades()
+	fmt.Printf("# gene\tterms\t-lnL\tconcat\tspecies\n")
+	for _, g := range genes {
+		fmt.Printf("# %s\t%d\t%.6f\t%s\t%s\n", g.name, g.terms, -g.like, support(g.t, ccl), support(g.t, scl))
+	}
+	fmt.Printf("# Concatenated tree -log Likelihood: %.6f\n", -ct.Like())
+	fmt.Printf("# Concatenated tree quartet score: %.4f\n", qs.Score(concat))
+	fmt.Printf("# Species tree quartet score: %.4f\n", qs.Score(st))
+
+	for _, g := range genes {
+		g.t.Reroot(m.Out.Name)
+		fmt.Printf("# Gene %s: ", g.name)
+		g.t.Write(os.Stdout, true)
+		fmt.Printf("\n")
+	}
+	fmt.Printf("# Concatenated: ")
+	concat.Write(os.Stdout, true)
+	fmt.Printf("\n")
+	fmt.Printf("# Species: ")
+	st.Write(os.Stdout, true)
+	fmt.Printf("\n")
+
+	clades := ccl
+	seen := make(map[string]bool)
+	for _, cl := range ccl {
+		seen[strings.Join(cl, " ")] = true
+	}
+	for _, cl := range scl {
+		if !seen[strings.Join(cl, " ")] {
+			clades = append(clades, cl)
+		}
+	}
+	conflicts := 0
+	fmt.Printf("concat\tspecies\tgenes\tclade\n")
+	for _, cl := range clades {
+		inC, inf := concat.HasSplit(cl)
+		if !inf {
+			continue
+		}
+		inS, _ := st.HasSplit(cl)
+		if inC != inS {
+			conflicts++
+		}
+		k, n := 0, 0
+		for _, g := range genes {
+			ok, inf := g.t.HasSplit(cl)
+			if !inf {
+				continue
+			}
+			n++
+			if ok {
+				k++
+			}
+		}
+		fmt.Printf("%s\t%s\t%d/%d\t%s\n", yesNo(inC), yesNo(inS), k, n, strings.Join(cl, " "))
+	}
+	fmt.Printf("# Clades in conflict: %d\n", conflicts)
+	return nil
+}
+
+// Search returns the maximum likelihood tree
+// of a matrix,
+// starting from a Wagner tree.
+func search(mt *matrix.Matrix) (*likelihood.Tree, error) {
+	m := likelihood.NewFromMatrix(mt)
+	if err := m.SetGamma(gamma, alpha); err != nil {
+		return nil, err
+	}
+	tr, err := likelihood.FromTopology(parsimony.Wagner(mt).Topology(), m)
+	if err != nil {
+		return nil, err
+	}
+	tr.Refine()
+	if _, err := tr.HybridSPR(slack, max); err != nil {
+		return nil, err
+	}
+	return tr, nil
+}
+
+// Support returns the number of clades
+// found in a gene tree,
+// of the clades that can be evaluated
+// in the gene tree.
+func support(t *tree.Tree, clades [][]string) string {
+	k, n := 0, 0
+	for _, cl := range clades {
+		ok, inf := t.HasSplit(cl)
+		if !inf {
+			continue
+		}
+		n++
+		if ok {
+			k++
+		}
+	}
+	return fmt.Sprintf("%d/%d", k, n)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
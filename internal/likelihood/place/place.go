@@ -0,0 +1,267 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package place implements the l.place command,
+// i.e. place query sequences on a reference tree.
+package place
+
+import (
+	"os"
+	"strings"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/likelihood"
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `l.place [--max-placements <number>] [--model <definition>]
+		[-q|--queries <file>] [--refpkg <file>] [-t|--tree <treefile>]
+		<dataset>`,
+	Short: "place query sequences on a reference tree",
+	Long: `
+Command l.place reads a reference tree in parenthetical or NEXUS
+format, and places each query sequence on the reference tree by
+maximum likelihood (as in the evolutionary placement algorithm, EPA).
+The reference tree is not modified: each query is placed on each
+branch of the reference tree, independently of the other queries,
+estimating the lengths of the branches adjacent to the query, and the
+placements are reported with its likelihood weight ratio (i.e. the
+likelihood of the placement, relative to the sum of the likelihoods
+of all the placements of the query).
+
+The placements are printed in the standard output in jplace format,
+that can be read by iTOL, gappa, and other placement tools.
+
+By default, the queries are the terminals of the matrix that are not
+in the reference tree. With the option -q, or --queries, the queries
+are read from a file, one terminal per line. Lines starting with '#'
+are ignored.
+
+If the reference tree does not have branch lengths, the branch lengths
+will be estimated before the placement.
+
+If the option --refpkg is defined with a reference package file (see
+l.refpkg), the reference tree, the reference matrix, and the model
+parameters will be read from the package, and the dataset is only used
+for the query sequences, that must have the same characters as the
+reference matrix. In this case the options --model, and --tree, are
+not valid.
+
+The reference tree will be read from the standard input, unless the
+option -t or --tree is defined with a tree file. If the file has
+multiple trees, only the first one will be used. All the terminals of
+the reference tree must be in the matrix.
+
+Options are:
+
+    --max-placements <number>
+      Set the maximum number of placements reported for each query.
+      Default: 7.
+
+    --model <definition>
+      If defined, models will be assigned to the characters, as a
+      list of model names and character ranges, separated by
+      semicolons (e.g. "jc:1-2555; mk2:2556-2922"). Valid models are
+      jc, poisson, mk<n>, and mkv<n>. See l.models.
+
+    -q <file>
+    --queries <file>
+      If defined, the queries will be read from the indicated file.
+
+    --refpkg <file>
+      If defined, the reference tree, matrix and models will be read
+      from the indicated reference package file.
+
+    -t <treefile>
+    --tree <treefile>
+      If defined, the reference tree will be read from the indicated
+      file, instead of the standard input.
+
+    <dataset>
+      The phylogenetic data matrix. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var maxPlace int
+var model string
+var queries string
+var refpkg string
+var treefile string
+
+func register(c *cmdapp.Command) {
+	c.Flag.IntVar(&maxPlace, "max-placements", 7, "")
+	c.Flag.StringVar(&model, "model", "", "")
+	c.Flag.StringVar(&queries, "queries", "", "")
+	c.Flag.StringVar(&queries, "q", "", "")
+	c.Flag.StringVar(&refpkg, "refpkg", "", "")
+	c.Flag.StringVar(&treefile, "tree", "", "")
+	c.Flag.StringVar(&treefile, "t", "", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a dataset filename", c.Name())
+	}
+	if maxPlace < 1 {
+		return errors.Errorf("%s: invalid number of placements: %d", c.Name(), maxPlace)
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+	}
+	defer f.Close()
+
+	m, err := matrix.NewMatrix(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+	}
+
+	var rp *likelihood.RefPackage
+	var ref *tree.Tree
+	if refpkg != "" {
+		if model != "" || treefile != "" {
+			return errors.Errorf("%s: options --model and --tree are not valid with --refpkg", c.Name())
+		}
+		rp, err = readRefPackage(refpkg)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while reading %s", c.Name(), refpkg)
+		}
+		ref = rp.Tree
+	} else {
+		tf := os.Stdin
+		if treefile != "" {
+			tf, err = os.Open(treefile)
+			if err != nil {
+				return errors.Wrapf(err, "%s: while opening %s", c.Name(), treefile)
+			}
+			defer tf.Close()
+		}
+		ref, err = tree.Read(tf)
+		if err != nil {
+			return errors.Wrapf(err, "%s: when parsing tree", c.Name())
+		}
+	}
+	refTerms := ref.Terms()
+	if rp == nil {
+		for _, nm := range refTerms {
+			if m.Names[nm] == nil {
+				return errors.Errorf("%s: terminal %s not in matrix", c.Name(), nm)
+			}
+		}
+	}
+
+	qs := m.Outside(refTerms)
+	if queries != "" {
+		qf, err := os.Open(queries)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), queries)
+		}
+		qs, err = matrix.ReadTaxa(qf)
+		qf.Close()
+		if err != nil {
+			return errors.Wrapf(err, "%s: while reading %s", c.Name(), queries)
+		}
+		in := make(map[string]bool, len(refTerms))
+		for _, nm := range refTerms {
+			in[nm] = true
+		}
+		for _, nm := range qs {
+			if m.Names[nm] == nil {
+				return errors.Errorf("%s: query %s not in matrix", c.Name(), nm)
+			}
+			if in[nm] {
+				return errors.Errorf("%s: query %s in reference tree", c.Name(), nm)
+			}
+		}
+	}
+	if len(qs) == 0 {
+		return errors.Errorf("%s: no queries to place", c.Name())
+	}
+
+	if !ref.Lens {
+		lm, err := newMatrix(m, refTerms, nil)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		tr, err := likelihood.FromTopology(ref, lm)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		tr.Refine()
+		ref = tr.Topology()
+	}
+
+	pqs := make([]likelihood.PQuery, 0, len(qs))
+	for _, nm := range qs {
+		terms := append(refTerms, nm)
+		if rp != nil {
+			terms = []string{nm}
+		}
+		lm, err := newMatrix(m, terms, rp)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		ps, err := likelihood.Place(ref, lm, nm)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		if len(ps) > maxPlace {
+			ps = ps[:maxPlace]
+		}
+		pqs = append(pqs, likelihood.PQuery{Name: nm, Placements: ps})
+	}
+
+	meta := map[string]string{
+		"invocation": strings.Join(os.Args, " "),
+		"software":   env.String(),
+	}
+	if err := likelihood.WriteJPlace(os.Stdout, ref, pqs, meta); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	return nil
+}
+
+// NewMatrix returns a likelihood matrix
+// with the indicated terminals,
+// and the models of the characters.
+// If a reference package is given,
+// the terminals are added
+// to the reference matrix,
+// with the models of the package.
+func newMatrix(m *matrix.Matrix, terms []string, rp *likelihood.RefPackage) (*likelihood.Matrix, error) {
+	sub, err := m.Subset(terms)
+	if err != nil {
+		return nil, err
+	}
+	if rp != nil {
+		return rp.Matrix(sub)
+	}
+	lm := likelihood.NewFromMatrix(sub)
+	if err := lm.SetModels(model); err != nil {
+		return nil, err
+	}
+	return lm, nil
+}
+
+// ReadRefPackage reads a reference package file.
+func readRefPackage(name string) (*likelihood.RefPackage, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return likelihood.ReadRefPackage(f)
+}
@@ -0,0 +1,220 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package puzzle implements the l.puzzle command,
+// i.e. build a tree using quartet puzzling.
+package puzzle
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/consensus"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/likelihood"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `l.puzzle [-s|--steps <number>] [-i|--intermediate]
+		[--alpha <value>] [--gamma <number>] [--min-overlap <number>]
+		[--model <definition>] [-o|--output <file>] <dataset>`,
+	Short: "build a tree using quartet puzzling",
+	Long: `
+Command l.puzzle reads a data matrix, and builds a tree using quartet
+puzzling. First, the three possible topologies of each quartet of
+terminals are evaluated by maximum likelihood (with terminal branch
+lengths estimated from the pairwise distances, and an optimized
+internal branch), and the best topology of each quartet is stored. Then, in
+each puzzling step, an intermediate tree is assembled, adding the
+terminals in a random order, each one at the branch that has the
+smallest number of contradictions with the quartet topologies.
+
+The output is the majority rule consensus of the intermediate trees,
+rooted at the outgroup, in which each clade is labeled with its
+support (i.e. the proportion of puzzling steps that recovered the
+clade).
+
+If the option -o, or --output, is defined, each intermediate tree will
+be written in the indicated file (in NEXUS format), as soon as its
+puzzling step is completed. Trees are named "step<n>".
+
+The pairwise distances use only the sites in which both terminals have
+an unambiguous state (i.e. pairwise deletion of missing data). If a
+pair of terminals shares fewer sites than the value of the option
+--min-overlap (by default, 1), a warning is printed, and a default
+distance is used for the pair. The same sites are counted for the
+warnings and for the distances: a gap, when gaps are a state, is not
+an unambiguous state. These distances are only used to estimate the
+terminal branch lengths of the quartets, as ramita does not have
+distance methods.
+
+As all quartets are evaluated, the command can be very slow on
+datasets with many terminals.
+
+Options are:
+
+    -s <number>
+    --steps <number>
+      Set the number of puzzling steps. Default: 1000.
+
+    -i
+    --intermediate
+      If set, the intermediate trees will be printed, instead of the
+      consensus tree.
+
+    --alpha <value>
+      Set the shape (alpha) of the gamma distribution of rates.
+      Default: 1.
+
+    --gamma <number>
+      If defined, and greater than 1, rate heterogeneity among
+      characters will be modeled with a discrete gamma distribution
+      with the indicated number of categories.
+
+    --min-overlap <number>
+      Set the minimum number of shared sites of a pair of terminals
+      used to estimate its distance. Default: 1.
+
+    --model <definition>
+      If defined, models will be assigned to the characters, as a
+      list of model names and character ranges, separated by
+      semicolons (e.g. "jc:1-2555; mk2:2556-2922"). Valid models are
+      jc, poisson, mk<n>, and mkv<n>. See l.models.
+
+    -o <file>
+    --output <file>
+      If defined, the intermediate trees will be written in the
+      indicated file.
+
+    <dataset>
+      The phylogenetic data matrix. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var steps int
+var intermediate bool
+var output string
+var model string
+var alpha float64
+var gamma int
+var minOverlap int
+
+func register(c *cmdapp.Command) {
+	c.Flag.IntVar(&steps, "steps", 1000, "")
+	c.Flag.IntVar(&steps, "s", 1000, "")
+	c.Flag.BoolVar(&intermediate, "intermediate", false, "")
+	c.Flag.BoolVar(&intermediate, "i", false, "")
+	c.Flag.StringVar(&output, "output", "", "")
+	c.Flag.StringVar(&output, "o", "", "")
+	c.Flag.StringVar(&model, "model", "", "")
+	c.Flag.Float64Var(&alpha, "alpha", 1, "")
+	c.Flag.IntVar(&gamma, "gamma", 0, "")
+	c.Flag.IntVar(&minOverlap, "min-overlap", 1, "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a dataset filename", c.Name())
+	}
+	if steps < 1 {
+		return errors.Errorf("%s: invalid number of puzzling steps: %d", c.Name(), steps)
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+	}
+	defer f.Close()
+
+	m, err := likelihood.NewMatrix(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+	}
+	if err := m.SetModels(model); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if err := m.SetGamma(gamma, alpha); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if m.Terms() < 4 {
+		return errors.Errorf("%s: matrix with less than 4 terminals", c.Name())
+	}
+
+	for _, ov := range m.Overlaps(minOverlap) {
+		fmt.Printf("# Warning: terminals %s and %s share %d sites: using a default distance\n", ov.A, ov.B, ov.Sites)
+	}
+	q := m.Quartets(minOverlap)
+
+	var tw *tree.Writer
+	if output != "" {
+		ls := make([]string, 0, len(q.Taxa))
+		for _, tx := range q.Taxa {
+			ls = append(ls, tx.Name)
+		}
+		tw, err = tree.Create(output, ls)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		if err := tw.Comment(env.String()); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		defer tw.Close()
+	}
+
+	trees := make([]*tree.Tree, 0, steps)
+	for i := 0; i < steps; i++ {
+		t := q.PuzzleStep()
+		trees = append(trees, t)
+		if tw == nil {
+			continue
+		}
+		t.Name = fmt.Sprintf("step%d", i+1)
+		if err := tw.Write(t); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	}
+	if tw != nil {
+		if err := tw.Close(); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	}
+	fmt.Printf("# Quartets: %d\n", q.Len())
+	fmt.Printf("# Puzzling steps: %d\n", steps)
+	if intermediate {
+		for _, t := range trees {
+			t.Write(os.Stdout, true)
+			fmt.Printf("\n")
+		}
+		return nil
+	}
+
+	set := &consensus.Set{}
+	for _, t := range trees {
+		if err := set.Add(t); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	}
+	t, err := set.Majority(0.5)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	t.Lens = false
+	t.Write(os.Stdout, true)
+	fmt.Printf("\n")
+	return nil
+}
@@ -0,0 +1,149 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package ratetest implements the l.ratetest command,
+// i.e. test for asymmetric rates in binary characters.
+package ratetest
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/likelihood"
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `l.ratetest [-c|--chars <list>] [-t|--tree <treefile>]
+		<dataset>`,
+	Short: "test for asymmetric rates in binary characters",
+	Long: `
+Command l.ratetest reads a tree with branch lengths, and for each binary
+character of a dataset, compares the fit of a one-rate Mk model (i.e.
+the rate of change from 0 to 1 is equal to the rate from 1 to 0)
+against an asymmetric two-rate Mk model, using a likelihood ratio test.
+
+The branch lengths of the tree are fixed, and the rates are in changes
+per unit of branch length. The state frequencies at the root are the
+stationary frequencies of each model.
+
+The results are printed as a table with the character, the rate and the
+-log likelihood of the one-rate model, the rates (from 0 to 1, and from
+1 to 0) and the -log likelihood of the two-rate model, the likelihood
+ratio statistic, and its p-value (from a chi-square distribution with
+one degree of freedom).
+
+By default, all binary characters are tested. The option -c, or
+--chars, can be used to define the characters to be tested.
+
+The tree will be read from the standard input, unless the option -t or
+--tree is defined with a tree file.
+
+Options are:
+
+    -c <list>
+    --chars <list>
+      If set, only the indicated characters will be tested. The
+      characters can be given as a list of numbers, or ranges (e.g.
+      "1-5 8"). The first character is 1.
+
+    -t <treefile>
+    --tree <treefile>
+      If defined, the tree will be read from the indicated file,
+      instead of the standard input.
+
+    <dataset>
+      The phylogenetic data matrix. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var chars string
+var treefile string
+
+func register(c *cmdapp.Command) {
+	c.Flag.StringVar(&chars, "chars", "", "")
+	c.Flag.StringVar(&chars, "c", "", "")
+	c.Flag.StringVar(&treefile, "tree", "", "")
+	c.Flag.StringVar(&treefile, "t", "", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a dataset filename", c.Name())
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+	}
+	defer f.Close()
+
+	m, err := likelihood.NewMatrix(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+	}
+
+	var ls []int
+	if chars != "" {
+		ls, err = matrix.ParseRange(chars, m.Chars())
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	} else {
+		for i := 0; i < m.Chars(); i++ {
+			if m.States(i) == 2 {
+				ls = append(ls, i)
+			}
+		}
+		if len(ls) == 0 {
+			return errors.Errorf("%s: dataset without binary characters", c.Name())
+		}
+	}
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	tf := os.Stdin
+	if treefile != "" {
+		tf, err = os.Open(treefile)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), treefile)
+		}
+		defer tf.Close()
+	}
+
+	tp, err := tree.Read(tf)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing tree", c.Name())
+	}
+	if !tp.Lens {
+		return errors.Errorf("%s: tree without branch lengths", c.Name())
+	}
+	tr, err := likelihood.FromTopology(tp, m)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	fmt.Printf("char\trate\t-lnL1\tq01\tq10\t-lnL2\tLR\tp\n")
+	for _, ch := range ls {
+		rt, err := tr.TwoRateTest(ch)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		fmt.Printf("%d\t%.6g\t%.6f\t%.6g\t%.6g\t%.6f\t%.6f\t%.6f\n", ch+1, rt.Rate, -rt.OneLike, rt.Q01, rt.Q10, -rt.TwoLike, rt.LR, rt.P)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package refpkg implements the l.refpkg command,
+// i.e. write a reference package for placement.
+package refpkg
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/likelihood"
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `l.refpkg [--alpha <value>] [--fix-alpha] [--gamma <number>]
+		[--model <definition>] [-o|--output <file>]
+		[-t|--tree <treefile>] <dataset>`,
+	Short: "write a reference package for placement",
+	Long: `
+Command l.refpkg reads a reference tree in parenthetical or NEXUS
+format, and a data matrix, and writes a reference package file (a
+.rpk file), that stores the reference matrix, the reference tree with
+its branch lengths, and the model parameters fitted on the reference
+tree. The reference package can be used by l.place (with the option
+--refpkg), so the queries can be placed repeatedly without fitting
+the reference tree each time.
+
+The branch lengths of the reference tree, and the model parameters,
+are estimated by maximum likelihood before the package is written. The
+terminals of the matrix that are not in the reference tree are not
+included in the package.
+
+By default the package is written in a file with the name of the
+dataset and the extension .rpk, another name can be set with the
+option -o, or --output.
+
+The reference tree will be read from the standard input, unless the
+option -t or --tree is defined with a tree file. If the file has
+multiple trees, only the first one will be used. All the terminals of
+the reference tree must be in the matrix.
+
+Options are:
+
+    --alpha <value>
+      Set the shape (alpha) of the gamma distribution of rates.
+      Default: 1.
+
+    --fix-alpha
+      If set, the shape (alpha) of the gamma distribution will not
+      be estimated.
+
+    --gamma <number>
+      If defined, and greater than 1, rate heterogeneity among
+      characters will be modeled with a discrete gamma distribution
+      with the indicated number of categories.
+
+    --model <definition>
+      If defined, models will be assigned to the characters, as a
+      list of model names and character ranges, separated by
+      semicolons (e.g. "jc:1-2555; mk2:2556-2922"). Valid models are
+      jc, poisson, mk<n>, and mkv<n>. See l.models.
+
+    -o <file>
+    --output <file>
+      Set the name of the reference package file.
+
+    -t <treefile>
+    --tree <treefile>
+      If defined, the reference tree will be read from the indicated
+      file, instead of the standard input.
+
+    <dataset>
+      The phylogenetic data matrix. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var alpha float64
+var fixAlpha bool
+var gamma int
+var model string
+var output string
+var treefile string
+
+func register(c *cmdapp.Command) {
+	c.Flag.Float64Var(&alpha, "alpha", 1, "")
+	c.Flag.BoolVar(&fixAlpha, "fix-alpha", false, "")
+	c.Flag.IntVar(&gamma, "gamma", 0, "")
+	c.Flag.StringVar(&model, "model", "", "")
+	c.Flag.StringVar(&output, "output", "", "")
+	c.Flag.StringVar(&output, "o", "", "")
+	c.Flag.StringVar(&treefile, "tree", "", "")
+	c.Flag.StringVar(&treefile, "t", "", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a dataset filename", c.Name())
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+	}
+	defer f.Close()
+
+	mt, err := matrix.NewMatrix(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+	}
+
+	tf := os.Stdin
+	if treefile != "" {
+		tf, err = os.Open(treefile)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), treefile)
+		}
+		defer tf.Close()
+	}
+	ref, err := tree.Read(tf)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing tree", c.Name())
+	}
+	sub, err := mt.Subset(ref.Terms())
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	m := likelihood.NewFromMatrix(sub)
+	if err := m.SetModels(model); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if err := m.SetGamma(gamma, alpha); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	m.FixAlpha(fixAlpha)
+
+	tr, err := likelihood.FromTopology(ref, m)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing tree", c.Name())
+	}
+	tr.Refine()
+	rp, err := likelihood.NewRefPackage(tr)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	name := output
+	if name == "" {
+		name = strings.TrimSuffix(args[0], ".rpk") + ".rpk"
+	}
+	if name == args[0] {
+		return errors.Errorf("%s: output file %s is the same as the dataset", c.Name(), name)
+	}
+	out, err := os.Create(name)
+	if err != nil {
+		return errors.Wrapf(err, "%s: while creating %s", c.Name(), name)
+	}
+	if err := likelihood.WriteRefPackage(out, rp); err != nil {
+		out.Close()
+		return errors.Wrap(err, c.Name())
+	}
+	if err := out.Close(); err != nil {
+		return errors.Wrapf(err, "%s: while closing %s", c.Name(), name)
+	}
+
+	fmt.Printf("# Reference package: %s\n", name)
+	fmt.Printf("# Terminals: %d\n", len(sub.Names))
+	fmt.Printf("# Characters: %d\n", len(sub.Kind))
+	fmt.Printf("# Models: %s\n", rp.Models)
+	if rp.Cats > 1 {
+		fmt.Printf("# Gamma categories: %d\talpha: %.6f\n", rp.Cats, rp.Alpha)
+	}
+	fmt.Printf("# Tree -log Likelihood: %.6f\n", -tr.Like())
+	return nil
+}
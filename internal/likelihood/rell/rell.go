@@ -0,0 +1,265 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package rell implements the l.rell command,
+// i.e. a fast bootstrap using resampling of site likelihoods.
+package rell
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/consensus"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/likelihood"
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `l.rell [--alpha <value>] [--fix-alpha] [--gamma <number>]
+		[-g|--genes] [--model <definition>] [-o|--optimize]
+		[-p|--parts <file>] [-r|--replicates <number>]
+		[-t|--tree <treefile>] <dataset>`,
+	Short: "fast bootstrap using resampling of site likelihoods",
+	Long: `
+Command l.rell reads a set of trees in parenthetical or NEXUS format
+(for example, the best trees found in a likelihood search), and
+performs a resampling estimated log-likelihood (RELL) bootstrap. In
+each replicate, the characters are resampled with replacement, and the
+likelihood of each tree is calculated from the likelihoods of the
+sampled characters, without re-optimizing the trees. As the site
+likelihoods are calculated only once (for each site pattern), the
+bootstrap is orders of magnitude faster than a standard bootstrap.
+
+For multilocus supermatrices, whole genes (or partitions) can be
+resampled, instead of individual characters. With the option -g, or
+--genes, each block of the matrix is taken as a gene, and with the
+option -p, or --parts, the partitions are read from a partition file
+(see l.parts for the format of the file). Characters not assigned to
+a partition are ignored.
+
+The output is the list of trees, with its likelihood, and the
+proportion of replicates in which the tree was the best tree, followed
+by the tree with the best likelihood, with each clade labeled with its
+RELL support, i.e. the proportion of replicates in which the best tree
+has the clade.
+
+If a tree does not have branch lengths, a default length of 0.01 will
+be used. If the option -o, or --optimize, is used, the branch lengths
+of each tree will be optimized before the bootstrap.
+
+The trees will be read from the standard input, unless the option -t
+or --tree is defined with a tree file.
+
+Options are:
+
+    --alpha <value>
+      Set the shape (alpha) of the gamma distribution of rates.
+      Default: 1.
+
+    --fix-alpha
+      If set, the shape (alpha) of the gamma distribution will not
+      be estimated when the tree is optimized.
+
+    --gamma <number>
+      If defined, and greater than 1, rate heterogeneity among
+      characters will be modeled with a discrete gamma distribution
+      with the indicated number of categories.
+
+    -g
+    --genes
+      If set, the blocks of the matrix will be resampled, instead of
+      individual characters.
+
+    --model <definition>
+      If defined, models will be assigned to the characters, as a
+      list of model names and character ranges, separated by
+      semicolons (e.g. "jc:1-2555; mk2:2556-2922"). Valid models are
+      jc, poisson, mk<n>, and mkv<n>. See l.models.
+
+    -o
+    --optimize
+      If set, the branch lengths of each tree will be optimized.
+
+    -p <file>
+    --parts <file>
+      If defined, the partitions defined in the indicated file will be
+      resampled, instead of individual characters.
+
+    -r <number>
+    --replicates <number>
+      Set the number of replicates. Default: 1000.
+
+    -t <treefile>
+    --tree <treefile>
+      If defined, the trees will be read from the indicated file,
+      instead of the standard input.
+
+    <dataset>
+      The phylogenetic data matrix. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var genes bool
+var optimize bool
+var parts string
+var reps int
+var treefile string
+var model string
+var alpha float64
+var fixAlpha bool
+var gamma int
+
+func register(c *cmdapp.Command) {
+	c.Flag.BoolVar(&genes, "genes", false, "")
+	c.Flag.BoolVar(&genes, "g", false, "")
+	c.Flag.BoolVar(&optimize, "optimize", false, "")
+	c.Flag.BoolVar(&optimize, "o", false, "")
+	c.Flag.StringVar(&parts, "parts", "", "")
+	c.Flag.StringVar(&parts, "p", "", "")
+	c.Flag.IntVar(&reps, "replicates", 1000, "")
+	c.Flag.IntVar(&reps, "r", 1000, "")
+	c.Flag.StringVar(&treefile, "tree", "", "")
+	c.Flag.StringVar(&treefile, "t", "", "")
+	c.Flag.StringVar(&model, "model", "", "")
+	c.Flag.Float64Var(&alpha, "alpha", 1, "")
+	c.Flag.BoolVar(&fixAlpha, "fix-alpha", false, "")
+	c.Flag.IntVar(&gamma, "gamma", 0, "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a dataset filename", c.Name())
+	}
+	if reps < 1 {
+		return errors.Errorf("%s: invalid number of replicates: %d", c.Name(), reps)
+	}
+	if genes && parts != "" {
+		return errors.Errorf("%s: options --genes and --parts are incompatible", c.Name())
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+	}
+	defer f.Close()
+
+	m, err := likelihood.NewMatrix(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+	}
+	if err := m.SetModels(model); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if err := m.SetGamma(gamma, alpha); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	m.FixAlpha(fixAlpha)
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	var ps []matrix.Partition
+	if genes {
+		ps = m.M.BlockPartitions()
+	}
+	if parts != "" {
+		ps, err = matrix.ReadPartitionFile(parts, m.Chars())
+		if err != nil {
+			return errors.Wrapf(err, "%s: while reading %s", c.Name(), parts)
+		}
+	}
+
+	// the likelihood is computed on site patterns,
+	// and the characters are resampled
+	// from the original matrix
+	m = m.Compress()
+
+	tf := os.Stdin
+	if treefile != "" {
+		tf, err = os.Open(treefile)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), treefile)
+		}
+		defer tf.Close()
+	}
+
+	var trees []*tree.Tree
+	var likes []float64
+	var sites [][]float64
+	r := tree.NewReader(tf)
+	for r.Scan() {
+		tr, err := likelihood.FromTopology(r.Tree(), m)
+		if err != nil {
+			return errors.Wrapf(err, "%s: tree %d", c.Name(), len(trees)+1)
+		}
+		if optimize {
+			tr.Refine()
+		}
+		trees = append(trees, tr.Topology())
+		likes = append(likes, tr.Like())
+		sites = append(sites, m.ExpandSites(tr.SiteLikes()))
+	}
+	if err := r.Err(); err != nil {
+		return errors.Wrapf(err, "%s: when parsing trees", c.Name())
+	}
+	if len(trees) == 0 {
+		return errors.Errorf("%s: no trees found", c.Name())
+	}
+
+	var best []int
+	if ps != nil {
+		best = likelihood.RELLPartitions(sites, ps, reps)
+	} else {
+		best = likelihood.RELL(sites, reps)
+	}
+	count := make([]int, len(trees))
+	set := &consensus.Set{}
+	for _, b := range best {
+		count[b]++
+		if err := set.Add(trees[b]); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	}
+
+	ml := 0
+	fmt.Printf("# Replicates: %d\n", reps)
+	if ps != nil {
+		fmt.Printf("# Resampled partitions: %d\n", len(ps))
+	}
+	fmt.Printf("# tree\t-log like\tRELL\n")
+	for i, l := range likes {
+		if l > likes[ml] {
+			ml = i
+		}
+		fmt.Printf("%d\t%.6f\t%.4f\n", i+1, -l, float64(count[i])/float64(reps))
+	}
+
+	t := trees[ml]
+	for _, n := range t.Nodes() {
+		if n.IsTerm() || n == t.Root {
+			continue
+		}
+		s, err := set.Support(n.Terms())
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		n.Label = fmt.Sprintf("%.2f", s)
+	}
+	fmt.Printf("# Best tree: %d\n", ml+1)
+	t.Write(os.Stdout, true)
+	fmt.Printf("\n")
+	return nil
+}
@@ -0,0 +1,227 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package search implements the l.search command,
+// i.e. a likelihood search with parsimony pre-screening.
+package search
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/likelihood"
+	"github.com/js-arias/ramita/matrix"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `l.search [--alpha <value>] [--gamma <number>]
+		[-m|--max <number>] [--model <definition>]
+		[-s|--slack <number>] [--total-evidence]
+		[-t|--tree <treefile>] [--trim <definition>] <dataset>`,
+	Short: "likelihood search with parsimony pre-screening",
+	Long: `
+Command l.search reads a starting tree (for example, a tree made with
+p.wagday), and improves it under likelihood, with a hybrid SPR branch
+swapping, in which the rearrangements are pre-screened with parsimony.
+The resulting tree is printed in the standard output.
+
+In each round, the SPR rearrangements of the tree are evaluated under
+parsimony, and only the rearrangements with a length not worse than
+the best length plus a slack are evaluated under likelihood, in order
+of length. By default, the slack is 2 steps, and it can be changed
+with the option -s, or --slack. The number of rearrangements evaluated
+under likelihood in each round is at most 10, and it can be changed
+with the option -m, or --max (0 means no limit). The branch lengths of
+each evaluated tree are refined, and if a tree with a better
+likelihood is found, it replaces the current tree, and a new round
+starts. The search ends when a round does not improve the tree.
+
+With the option --total-evidence, the search is made as a combined
+analysis of morphological and molecular data: the characters of DNA
+blocks use the jc model, and the other characters (e.g. morphology) use
+the mkv<n> model, i.e. the mk<n> model conditioned on the characters
+being variable, with n the number of states of the character. All the
+characters share the branch lengths of the tree. Models defined with
+the option --model replace the models of the preset.
+
+Before the search, identical characters with the same model are
+collapsed into site patterns, and each pattern is evaluated once, with
+its log likelihood multiplied by the number of characters with the
+pattern. This does not change the likelihood of the trees.
+
+Poorly aligned, or noisy, columns can be removed when the matrix is
+read with the option --trim, as a list of filters, separated by
+semicolons, each one with the name of the filter, an equal sign, and
+its value. With the filter "gaps", columns with a proportion of gaps
+and missing data greater than the value are removed, and with the
+filter "entropy", columns with a Shannon entropy (in bits) greater than
+the value are removed (e.g. "gaps=0.5; entropy=1.8"). The number of
+columns removed from each partition is printed (the partitions are
+the partition scheme of a bundle file, see mat.bundle, or the blocks
+of the matrix).
+
+The tree will be read from the standard input, unless the option -t
+or --tree is defined with a tree file. Polytomies are resolved at
+random, with new branches of length 0. If the tree does not have branch
+lengths, a default branch length of 0.01 will be used, and in any case,
+the branch lengths are refined before the search.
+
+Options are:
+
+    --alpha <value>
+      Set the shape (alpha) of the gamma distribution of rates.
+      Default: 1.
+
+    --gamma <number>
+      If defined, and greater than 1, rate heterogeneity among
+      characters will be modeled with a discrete gamma distribution
+      with the indicated number of categories.
+
+    -m <number>
+    --max <number>
+      Set the maximum number of rearrangements evaluated under
+      likelihood in each round. Default: 10.
+
+    --model <definition>
+      If defined, models will be assigned to the characters, as a
+      list of model names and character ranges, separated by
+      semicolons (e.g. "jc:1-2555; mk2:2556-2922"). Valid models are
+      jc, poisson, mk<n>, and mkv<n>. See l.models.
+
+    -s <number>
+    --slack <number>
+      Set the maximum number of extra parsimony steps of the
+      rearrangements evaluated under likelihood. Default: 2.
+
+    --total-evidence
+      If set, the models of the characters will be set for a combined
+      analysis of morphological and DNA data.
+
+    -t <treefile>
+    --tree <treefile>
+      If defined, the tree will be read from the indicated file,
+      instead of the standard input.
+
+    --trim <definition>
+      If defined, the columns rejected by the indicated filters will
+      be removed.
+
+    <dataset>
+      The phylogenetic data matrix. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var max int
+var slack int
+var treefile string
+var model string
+var alpha float64
+var gamma int
+var totalEvidence bool
+var trim string
+
+func register(c *cmdapp.Command) {
+	c.Flag.IntVar(&max, "max", 10, "")
+	c.Flag.IntVar(&max, "m", 10, "")
+	c.Flag.IntVar(&slack, "slack", 2, "")
+	c.Flag.IntVar(&slack, "s", 2, "")
+	c.Flag.StringVar(&treefile, "tree", "", "")
+	c.Flag.StringVar(&treefile, "t", "", "")
+	c.Flag.StringVar(&model, "model", "", "")
+	c.Flag.Float64Var(&alpha, "alpha", 1, "")
+	c.Flag.IntVar(&gamma, "gamma", 0, "")
+	c.Flag.BoolVar(&totalEvidence, "total-evidence", false, "")
+	c.Flag.StringVar(&trim, "trim", "", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a dataset filename", c.Name())
+	}
+	if slack < 0 {
+		return errors.Errorf("%s: invalid slack: %d", c.Name(), slack)
+	}
+	if max < 0 {
+		return errors.Errorf("%s: invalid number of rearrangements: %d", c.Name(), max)
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+	}
+	defer f.Close()
+
+	m, err := likelihood.NewMatrix(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+	}
+	filter, err := matrix.ParseTrim(trim)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	trimmed := m.M.Trim(filter)
+	if totalEvidence {
+		if err := m.TotalEvidence("jc"); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	}
+	if err := m.SetModels(model); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if err := m.SetGamma(gamma, alpha); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if !filter.IsZero() {
+		ps, kind := m.M.Partitions(), "partition"
+		if len(ps) == 0 {
+			ps, kind = m.M.BlockPartitions(), "block"
+		}
+		for i, n := range matrix.PartitionCounts(ps, trimmed) {
+			fmt.Printf("# Trimmed: %s %s: %d of %d characters\n", kind, ps[i].Name, n, len(ps[i].Chars))
+		}
+	}
+	chars := m.Chars()
+	m = m.Compress()
+	fmt.Printf("# Site patterns: %d of %d characters\n", m.Chars(), chars)
+
+	tf := os.Stdin
+	if treefile != "" {
+		tf, err = os.Open(treefile)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), treefile)
+		}
+		defer tf.Close()
+	}
+
+	tr, err := likelihood.ReadTree(tf, m)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing tree", c.Name())
+	}
+	tr.Refine()
+	fmt.Printf("# Initial tree -log Likelihood: %.6f\n", -tr.Like())
+
+	imp, err := tr.HybridSPR(slack, max)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	fmt.Printf("# Improvements: %d\n", imp)
+	fmt.Printf("# Tree -log Likelihood: %.6f\n", -tr.Like())
+	tr.Write(os.Stdout, true)
+	fmt.Printf("\n")
+	return nil
+}
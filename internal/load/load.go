@@ -0,0 +1,173 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package load implements the options
+// used by the parsimony commands
+// to read a data matrix
+// (terminals, weights, gaps, excluded and trimmed characters,
+// and step matrices),
+// so all the commands read the data
+// in the same way.
+package load
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/matrix"
+
+	"github.com/pkg/errors"
+)
+
+// Options are the options
+// used to read a data matrix.
+type Options struct {
+	Assumptions string // file with step matrices
+	Exclude     string // list of excluded characters
+	GapMode     string // treatment of DNA gaps
+	Taxa        string // file with the terminals to analyze
+	Trim        string // trimming filters
+	Weights     string // definition of character weights
+	WeightsFile string // file with character weights
+
+	trimmed []int // characters excluded by the trimming filters
+}
+
+// Register sets the options as flags of a command.
+func (o *Options) Register(c *cmdapp.Command) {
+	c.Flag.StringVar(&o.Assumptions, "assumptions", "", "")
+	c.Flag.StringVar(&o.Exclude, "exclude", "", "")
+	c.Flag.StringVar(&o.GapMode, "gapmode", "missing", "")
+	c.Flag.StringVar(&o.Taxa, "taxa", "", "")
+	c.Flag.StringVar(&o.Trim, "trim", "", "")
+	c.Flag.StringVar(&o.Weights, "weights", "", "")
+	c.Flag.StringVar(&o.WeightsFile, "weights-file", "", "")
+}
+
+// Read reads a data matrix
+// from a file,
+// or from the standard input,
+// if the name is empty,
+// and sets the options on the matrix.
+func (o *Options) Read(name string) (*matrix.Matrix, error) {
+	f := os.Stdin
+	if name != "" {
+		var err error
+		f, err = os.Open(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "while opening %s", name)
+		}
+		defer f.Close()
+	}
+
+	m, err := matrix.NewMatrix(f)
+	if err != nil {
+		return nil, errors.Wrap(err, "when parsing matrix")
+	}
+	return o.Set(m)
+}
+
+// Set sets the options on a matrix.
+// As the terminals can be subsampled,
+// it returns a new matrix.
+func (o *Options) Set(m *matrix.Matrix) (*matrix.Matrix, error) {
+	if o.Taxa != "" {
+		f, err := os.Open(o.Taxa)
+		if err != nil {
+			return nil, errors.Wrapf(err, "while opening %s", o.Taxa)
+		}
+		ls, err := matrix.ReadTaxa(f)
+		f.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "while reading %s", o.Taxa)
+		}
+		if m, err = m.Subset(ls); err != nil {
+			return nil, err
+		}
+		if len(m.Names) < 3 {
+			return nil, errors.Errorf("%s: %d terminals, at least 3 are required", o.Taxa, len(m.Names))
+		}
+	}
+	if o.WeightsFile != "" {
+		f, err := os.Open(o.WeightsFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "while opening %s", o.WeightsFile)
+		}
+		err = m.ReadWeights(f)
+		f.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "while reading %s", o.WeightsFile)
+		}
+	}
+	if err := m.SetWeights(o.Weights); err != nil {
+		return nil, err
+	}
+	gm, err := matrix.ParseGapMode(o.GapMode)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.SetGapMode(gm); err != nil {
+		return nil, err
+	}
+	ex, err := matrix.ParseRange(o.Exclude, len(m.Kind))
+	if err != nil {
+		return nil, err
+	}
+	m.Exclude(ex)
+	if len(ex) > 0 && len(m.Excluded()) == len(m.Kind) {
+		return nil, errors.New("all characters excluded")
+	}
+	filter, err := matrix.ParseTrim(o.Trim)
+	if err != nil {
+		return nil, err
+	}
+	o.trimmed = nil
+	if !filter.IsZero() {
+		o.trimmed = m.Trim(filter)
+	}
+	if o.Assumptions != "" {
+		f, err := os.Open(o.Assumptions)
+		if err != nil {
+			return nil, errors.Wrapf(err, "while opening %s", o.Assumptions)
+		}
+		err = m.ReadAssumptions(f)
+		f.Close()
+		if err != nil {
+			return nil, errors.Wrapf(err, "while reading %s", o.Assumptions)
+		}
+	}
+	if m.Active() == 0 {
+		return nil, errors.New("no characters with a weight greater than 0")
+	}
+
+	// DNA matrices are stored with 4 bits per character
+	if m.CanPack() {
+		if err := m.Pack(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// WriteTrimmed writes the number of characters
+// excluded by the trimming filters
+// in each partition of the matrix,
+// or in each block,
+// if the matrix does not have a partition scheme.
+// If no filter was defined,
+// it writes nothing.
+func (o *Options) WriteTrimmed(w io.Writer, m *matrix.Matrix) {
+	if o.Trim == "" {
+		return
+	}
+	ps, kind := m.Partitions(), "partition"
+	if len(ps) == 0 {
+		ps, kind = m.BlockPartitions(), "block"
+	}
+	for i, n := range matrix.PartitionCounts(ps, o.trimmed) {
+		fmt.Fprintf(w, "# Trimmed: %s %s: %d of %d characters\n", kind, ps[i].Name, n, len(ps[i].Chars))
+	}
+}
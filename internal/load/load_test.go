@@ -0,0 +1,89 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package load
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/matrix"
+)
+
+var blob = `
+> morpho
+A 000000
+B 011010
+C 110101
+D 110111
+`
+
+func TestSet(t *testing.T) {
+	tests := map[string]struct {
+		o   Options
+		err bool
+	}{
+		"default":      {o: Options{}},
+		"exclude":      {o: Options{Exclude: "1-3"}},
+		"exclude all":  {o: Options{Exclude: "1-6"}, err: true},
+		"weights":      {o: Options{Weights: "0:1-5"}},
+		"zero weights": {o: Options{Weights: "0:1-6"}, err: true},
+		"both":         {o: Options{Exclude: "1-3", Weights: "0:4-6"}, err: true},
+	}
+	for name, test := range tests {
+		m, err := matrix.NewMatrix(strings.NewReader(blob))
+		if err != nil {
+			t.Fatalf("load: unexpected error while reading matrix: %v", err)
+		}
+		_, err = test.o.Set(m)
+		if test.err && err == nil {
+			t.Errorf("load: %s: expecting error", name)
+		}
+		if !test.err && err != nil {
+			t.Errorf("load: %s: unexpected error: %v", name, err)
+		}
+	}
+}
+
+func TestTaxa(t *testing.T) {
+	f, err := ioutil.TempFile("", "taxa")
+	if err != nil {
+		t.Fatalf("load: taxa: unexpected error: %v", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("A\nC\n")
+	f.Close()
+
+	m, err := matrix.NewMatrix(strings.NewReader(blob))
+	if err != nil {
+		t.Fatalf("load: taxa: unexpected error while reading matrix: %v", err)
+	}
+	o := Options{Taxa: f.Name()}
+	if _, err := o.Set(m); err == nil {
+		t.Errorf("load: taxa: 2 terminals: expecting error")
+	}
+}
+
+func TestTrim(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(blob))
+	if err != nil {
+		t.Fatalf("load: trim: unexpected error while reading matrix: %v", err)
+	}
+	o := Options{Trim: "entropy=0.9"}
+	m, err = o.Set(m)
+	if err != nil {
+		t.Fatalf("load: trim: unexpected error: %v", err)
+	}
+	if n := m.Active(); n != 2 {
+		t.Errorf("load: trim: active %d, want %d", n, 2)
+	}
+	var buf bytes.Buffer
+	o.WriteTrimmed(&buf, m)
+	if !strings.Contains(buf.String(), "4 of 6 characters") {
+		t.Errorf("load: trim: report %q, want %q", buf.String(), "4 of 6 characters")
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package bundle implements the mat.bundle command,
+// i.e. write a dataset as a binary bundle file.
+package bundle
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/matrix"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `mat.bundle [-o|--output <file>] [-s|--scheme <file>]
+		<dataset>`,
+	Short: "write a dataset as a binary bundle file",
+	Long: `
+Command mat.bundle reads a data matrix and writes it as a binary
+bundle file (a .ram file), that stores the parsed matrix, its site
+patterns (i.e. the sets of identical characters), and optionally, a
+partition scheme.
+
+A bundle file can be used as the dataset of any other command, so
+repeated analyses on the same (large) dataset skip the parsing of the
+text matrix. The partition scheme stored in the bundle will be used by
+l.like, unless a partition file is given explicitly.
+
+By default the bundle is written in a file with the name of the
+dataset and the extension .ram, another name can be set with the
+option -o, or --output.
+
+Options are:
+
+    -o <file>
+    --output <file>
+      Set the name of the bundle file.
+
+    -s <file>
+    --scheme <file>
+      If defined, the partition scheme will be read from the
+      indicated file (for example, the one produced by l.parts), and
+      stored in the bundle.
+
+    <dataset>
+      The phylogenetic data matrix. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var output string
+var scheme string
+
+func register(c *cmdapp.Command) {
+	c.Flag.StringVar(&output, "output", "", "")
+	c.Flag.StringVar(&output, "o", "", "")
+	c.Flag.StringVar(&scheme, "scheme", "", "")
+	c.Flag.StringVar(&scheme, "s", "", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a dataset filename", c.Name())
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+	}
+	defer f.Close()
+
+	m, err := matrix.NewMatrix(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+	}
+
+	ps := m.Partitions()
+	if scheme != "" {
+		ps, err = matrix.ReadPartitionFile(scheme, len(m.Kind))
+		if err != nil {
+			return errors.Wrapf(err, "%s: while reading %s", c.Name(), scheme)
+		}
+	}
+
+	name := output
+	if name == "" {
+		name = strings.TrimSuffix(args[0], ".ram") + ".ram"
+	}
+	if name == args[0] {
+		return errors.Errorf("%s: output file %s is the same as the dataset", c.Name(), name)
+	}
+	out, err := os.Create(name)
+	if err != nil {
+		return errors.Wrapf(err, "%s: while creating %s", c.Name(), name)
+	}
+	if err := matrix.WriteBundle(out, m, ps); err != nil {
+		out.Close()
+		return errors.Wrap(err, c.Name())
+	}
+	if err := out.Close(); err != nil {
+		return errors.Wrapf(err, "%s: while closing %s", c.Name(), name)
+	}
+
+	fmt.Printf("# Bundle: %s\n", name)
+	fmt.Printf("# Terminals: %d\n", len(m.Names))
+	fmt.Printf("# Characters: %d\n", len(m.Kind))
+	fmt.Printf("# Site patterns: %d\n", len(m.Patterns()))
+	fmt.Printf("# Partitions: %d\n", len(ps))
+	return nil
+}
@@ -0,0 +1,131 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package convert implements the mat.convert command,
+// i.e. write a dataset in another format.
+package convert
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/matrix"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `mat.convert [-f|--format <format>] [-o|--output <file>]
+		<dataset>`,
+	Short: "write a dataset in another format",
+	Long: `
+Command mat.convert reads a data matrix, in any of the formats
+supported by ramita (the native format, NEXUS, PHYLIP, FASTA, TNT, or
+a bundle file), and writes it in another format.
+
+The output format is set with the option -f, or --format. Valid
+formats are:
+
+    fasta    aligned FASTA file, only for DNA, or protein data.
+    nexus    NEXUS file, with a DATA block, or a CHARACTERS block for
+             each block of the dataset.
+    phylip   relaxed sequential PHYLIP file, all the characters must
+             be of the same type, and morphological polymorphisms are
+             not allowed.
+    ramita   the native format of ramita (the default).
+    tnt      the xread command of a TNT file.
+
+In all formats, the outgroup is the first terminal, and gaps are
+written as unknown states.
+
+By default the matrix is written in the standard output, another
+file can be set with the option -o, or --output.
+
+Options are:
+
+    -f <format>
+    --format <format>
+      Set the format of the output matrix. Default: ramita.
+
+    -o <file>
+    --output <file>
+      If defined, the matrix will be written in the indicated file.
+
+    <dataset>
+      The phylogenetic data matrix. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var format string
+var output string
+
+func register(c *cmdapp.Command) {
+	c.Flag.StringVar(&format, "format", "ramita", "")
+	c.Flag.StringVar(&format, "f", "ramita", "")
+	c.Flag.StringVar(&output, "output", "", "")
+	c.Flag.StringVar(&output, "o", "", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a dataset filename", c.Name())
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+	}
+	defer f.Close()
+
+	m, err := matrix.NewMatrix(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+	}
+
+	var write func(w io.Writer) error
+	switch strings.ToLower(format) {
+	case "fasta":
+		write = m.WriteFasta
+	case "nexus":
+		write = m.WriteNexus
+	case "phylip":
+		write = m.WritePhylip
+	case "ramita":
+		write = m.Write
+	case "tnt":
+		write = m.WriteTNT
+	default:
+		return errors.Errorf("%s: unknown format %q", c.Name(), format)
+	}
+
+	if output == "" {
+		if err := write(os.Stdout); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		return nil
+	}
+	if output == args[0] {
+		return errors.Errorf("%s: output file %s is the same as the dataset", c.Name(), output)
+	}
+	out, err := os.Create(output)
+	if err != nil {
+		return errors.Wrapf(err, "%s: while creating %s", c.Name(), output)
+	}
+	if err := write(out); err != nil {
+		out.Close()
+		return errors.Wrap(err, c.Name())
+	}
+	if err := out.Close(); err != nil {
+		return errors.Wrapf(err, "%s: while closing %s", c.Name(), output)
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package cover implements the mat.cover command,
+// i.e. print the taxon coverage of a matrix.
+package cover
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/matrix"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `mat.cover [-s|--sample <number>] [-n|--number <number>]
+		[<dataset>]`,
+	Short: "print the taxon coverage of a matrix",
+	Long: `
+Command mat.cover reads a data matrix (usually a supermatrix build
+from several blocks, e.g. genes) and prints the taxon coverage of each
+block, the number of blocks that cover each terminal, and the
+decisiveness of the coverage pattern.
+
+A terminal is covered by a block if it has at least one known
+character in the block. A quartet of terminals is covered if the four
+terminals are covered by at least one block. If all quartets are
+covered, the coverage pattern is decisive, i.e. the data can
+distinguish among all possible trees.
+
+At the end, the command prints the terminals whose removal most
+improves the proportion of covered quartets, and the blocks whose
+removal most improves the matrix occupancy (the proportion of
+terminal-block pairs covered).
+
+Options are:
+
+    -s <number>
+    --sample <number>
+      Set the maximum number of quartets to be evaluated. If the
+      number of quartets is greater than this value, a random sample
+      of quartets will be used. By default it is 1000000. If it is 0,
+      all quartets will be evaluated.
+
+    -n <number>
+    --number <number>
+      Set the number of terminals and blocks to be suggested for
+      removal. By default it is 5.
+
+    <dataset>
+      The phylogenetic data matrix. If not given explicitly, it will
+      be read from the standard input.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var sample int
+var number int
+
+func register(c *cmdapp.Command) {
+	c.Flag.IntVar(&sample, "sample", 1000000, "")
+	c.Flag.IntVar(&sample, "s", 1000000, "")
+	c.Flag.IntVar(&number, "number", 5, "")
+	c.Flag.IntVar(&number, "n", 5, "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("%s: too many arguments", c.Name())
+	}
+
+	f := os.Stdin
+	if len(args) == 1 {
+		var err error
+		f, err = os.Open(args[0])
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+		}
+		defer f.Close()
+	}
+
+	m, err := matrix.NewMatrix(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+	}
+
+	taxa := m.Taxa()
+	blockCov := make([]int, len(m.Blocks))
+	termCov := make(map[string]int, len(taxa))
+	total := 0
+	for _, t := range taxa {
+		for j, b := range m.Blocks {
+			if m.Covered(t, b) {
+				blockCov[j]++
+				termCov[t.Name]++
+				total++
+			}
+		}
+	}
+
+	fmt.Printf("# Block coverage\n")
+	fmt.Printf("# block\ttype\tchars\tterminals\tcoverage\n")
+	for j, b := range m.Blocks {
+		fmt.Printf("%s\t%s\t%d\t%d\t%.4f\n", b.Name, b.Type, b.Len(), blockCov[j], float64(blockCov[j])/float64(len(taxa)))
+	}
+
+	fmt.Printf("\n# Terminal coverage\n")
+	fmt.Printf("# terminal\tblocks\tcoverage\n")
+	for _, t := range taxa {
+		fmt.Printf("%s\t%d\t%.4f\n", t.Name, termCov[t.Name], float64(termCov[t.Name])/float64(len(m.Blocks)))
+	}
+
+	occ := m.Occupancy()
+	d := m.Decisiveness(sample)
+	fmt.Printf("\n# Occupancy: %.4f\n", occ)
+	fmt.Printf("# Quartets evaluated: %d", d.Quartets)
+	if !d.Exact {
+		fmt.Printf(" (random sample)")
+	}
+	fmt.Printf("\n# Covered quartets: %d (%.4f)\n", d.Covered, d.Prop())
+	if d.Exact {
+		if d.IsDecisive() {
+			fmt.Printf("# The coverage pattern is decisive\n")
+		} else {
+			fmt.Printf("# The coverage pattern is not decisive\n")
+		}
+	}
+
+	if d.IsDecisive() {
+		return nil
+	}
+	ts := make([]string, 0, len(taxa))
+	for _, t := range taxa {
+		ts = append(ts, t.Name)
+	}
+	sort.SliceStable(ts, func(i, j int) bool {
+		return d.Without(ts[i]) > d.Without(ts[j])
+	})
+	fmt.Printf("\n# Terminals whose removal improves decisiveness\n")
+	fmt.Printf("# terminal\tcovered quartets\n")
+	for i, nm := range ts {
+		if i >= number {
+			break
+		}
+		if d.Without(nm) <= d.Prop() {
+			break
+		}
+		fmt.Printf("%s\t%.4f\n", nm, d.Without(nm))
+	}
+
+	if len(m.Blocks) < 2 {
+		return nil
+	}
+	bs := make([]int, len(m.Blocks))
+	occWithout := make([]float64, len(m.Blocks))
+	for j := range m.Blocks {
+		bs[j] = j
+		occWithout[j] = float64(total-blockCov[j]) / float64(len(taxa)*(len(m.Blocks)-1))
+	}
+	sort.SliceStable(bs, func(i, j int) bool {
+		return occWithout[bs[i]] > occWithout[bs[j]]
+	})
+	fmt.Printf("\n# Blocks whose removal improves occupancy\n")
+	fmt.Printf("# block\toccupancy\n")
+	for i, j := range bs {
+		if i >= number {
+			break
+		}
+		if occWithout[j] <= occ {
+			break
+		}
+		fmt.Printf("%s\t%.4f\n", m.Blocks[j].Name, occWithout[j])
+	}
+	return nil
+}
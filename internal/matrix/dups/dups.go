@@ -0,0 +1,88 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package dups implements the mat.dups command,
+// i.e. print duplicated terminals.
+package dups
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/matrix"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: "mat.dups [-e|--epsilon <number>] [<dataset>]",
+	Short:     "print duplicated terminals",
+	Long: `
+Command mat.dups reads a data matrix and prints the groups of
+terminals with identical data. Each group is printed in a single
+line, the first terminal of the group is the one that will be used as
+representative of the group, for example, when the option
+--duplicates of p.wagday is used.
+
+Unknown states are compared as any other state, so two terminals
+that only differ in missing data are not considered as duplicates.
+
+Options are:
+
+    -e <number>
+    --epsilon <number>
+      If set, terminals that differ in at most the indicated number
+      of characters will be reported as near duplicates.
+
+    <dataset>
+      The phylogenetic data matrix. If not given explicitly, it will
+      be read from the standard input.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var epsilon int
+
+func register(c *cmdapp.Command) {
+	c.Flag.IntVar(&epsilon, "epsilon", 0, "")
+	c.Flag.IntVar(&epsilon, "e", 0, "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("%s: too many arguments", c.Name())
+	}
+	if epsilon < 0 {
+		return errors.Errorf("%s: invalid epsilon value %d", c.Name(), epsilon)
+	}
+
+	f := os.Stdin
+	if len(args) == 1 {
+		var err error
+		f, err = os.Open(args[0])
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+		}
+		defer f.Close()
+	}
+
+	m, err := matrix.NewMatrix(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+	}
+
+	groups := m.Duplicates(epsilon)
+	fmt.Printf("# Groups of duplicated terminals: %d\n", len(groups))
+	for _, g := range groups {
+		fmt.Printf("%s\n", strings.Join(g, " "))
+	}
+	return nil
+}
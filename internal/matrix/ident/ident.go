@@ -0,0 +1,101 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package ident implements the mat.ident command,
+// i.e. print the pairwise identity between terminals.
+package ident
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/matrix"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: "mat.ident [-m|--min <value>] [<dataset>]",
+	Short:     "print the pairwise identity between terminals",
+	Long: `
+Command mat.ident reads a data matrix and prints, for each pair of
+terminals, the proportion of identical characters (identity), the
+number of characters known in both terminals, and the proportion of
+characters of the matrix known in both terminals (coverage).
+Characters unknown in any of the terminals are ignored.
+
+Pairs with a high identity, or a low coverage, can be the result of
+duplicated or mislabeled sequences, so it is a good idea to check them
+before starting an analysis.
+
+The output is a tab delimited table.
+
+Options are:
+
+    -m <value>
+    --min <value>
+      If set, only the pairs with an identity equal or greater than
+      the indicated value will be printed. The value must be between
+      0 and 1.
+
+    <dataset>
+      The phylogenetic data matrix. If not given explicitly, it will
+      be read from the standard input.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var min float64
+
+func register(c *cmdapp.Command) {
+	c.Flag.Float64Var(&min, "min", 0, "")
+	c.Flag.Float64Var(&min, "m", 0, "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("%s: too many arguments", c.Name())
+	}
+
+	f := os.Stdin
+	if len(args) == 1 {
+		var err error
+		f, err = os.Open(args[0])
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+		}
+		defer f.Close()
+	}
+
+	m, err := matrix.NewMatrix(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+	}
+
+	names := make([]string, 0, len(m.Names))
+	for nm := range m.Names {
+		names = append(names, nm)
+	}
+	sort.Strings(names)
+
+	fmt.Printf("# taxon1\ttaxon2\tidentity\tshared\tcoverage\n")
+	for i, n1 := range names {
+		for _, n2 := range names[i+1:] {
+			id, shared := m.Identity(m.Names[n1], m.Names[n2])
+			if id < min {
+				continue
+			}
+			cov := float64(shared) / float64(len(m.Kind))
+			fmt.Printf("%s\t%s\t%.4f\t%d\t%.4f\n", n1, n2, id, shared, cov)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package info implements the mat.info command,
+// i.e. print a summary of a matrix.
+package info
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/matrix"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `mat.info [<dataset>]`,
+	Short:     "print a summary of a matrix",
+	Long: `
+Command mat.info reads a data matrix and prints a summary of its
+content, as a sanity check before a long analysis.
+
+The summary includes the number of terminals and characters, the
+number of constant, variable, and parsimony informative characters,
+and the proportion of missing data in the matrix. Then it prints the
+data type and number of characters of each block, the missing data of
+each terminal, and the missing data of each character (the first
+character is 1).
+
+A character is parsimony informative, if it has at least two states,
+each one present in at least two terminals. Unknown and polymorphic
+data are ignored when counting the states of a character.
+
+Options are:
+
+    <dataset>
+      The phylogenetic data matrix. If not given explicitly, it will
+      be read from the standard input.
+	`,
+	Run: run,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("%s: too many arguments", c.Name())
+	}
+
+	f := os.Stdin
+	if len(args) == 1 {
+		var err error
+		f, err = os.Open(args[0])
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+		}
+		defer f.Close()
+	}
+
+	m, err := matrix.NewMatrix(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+	}
+
+	taxa := m.Taxa()
+	chars := len(m.Kind)
+	missing := make([]int, chars)
+	total := 0
+	for i := range missing {
+		missing[i] = m.Missing(i)
+		total += missing[i]
+	}
+	cells := len(taxa) * chars
+	prop := float64(0)
+	if cells > 0 {
+		prop = float64(total) / float64(cells)
+	}
+
+	sum := m.Summary()
+	fmt.Printf("# Terminals: %d\n", len(taxa))
+	fmt.Printf("# Characters: %d\n", chars)
+	fmt.Printf("# Constant characters: %d\n", sum.Constant)
+	fmt.Printf("# Variable characters: %d\n", sum.Uninformative+sum.Informative)
+	fmt.Printf("# Informative characters: %d\n", sum.Informative)
+	fmt.Printf("# Missing data: %.4f\n", prop)
+
+	fmt.Printf("\n# Blocks\n")
+	fmt.Printf("# block\ttype\tchars\n")
+	for _, b := range m.Blocks {
+		fmt.Printf("%s\t%s\t%d\n", b.Name, b.Type, b.Len())
+	}
+
+	fmt.Printf("\n# Missing data per terminal\n")
+	fmt.Printf("# terminal\tmissing\tprop\n")
+	for _, t := range taxa {
+		n := chars - m.Known(t)
+		p := float64(0)
+		if chars > 0 {
+			p = float64(n) / float64(chars)
+		}
+		fmt.Printf("%s\t%d\t%.4f\n", t.Name, n, p)
+	}
+
+	fmt.Printf("\n# Missing data per character\n")
+	fmt.Printf("# char\tmissing\tprop\n")
+	for i, n := range missing {
+		fmt.Printf("%d\t%d\t%.4f\n", i+1, n, float64(n)/float64(len(taxa)))
+	}
+	return nil
+}
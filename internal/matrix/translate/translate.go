@@ -0,0 +1,172 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package translate implements the mat.translate command,
+// i.e. translate coding DNA into amino acids.
+package translate
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/matrix"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `mat.translate [--code <table>] [-g|--genes <file>]
+		[-o|--output <file>] <dataset>`,
+	Short: "translate coding DNA into amino acids",
+	Long: `
+Command mat.translate reads a data matrix, and writes a new matrix in
+which the DNA characters of each gene are translated into amino acids,
+as a protein block named after the gene. Genes that are not DNA are
+ignored.
+
+By default, each block of the matrix is taken as a gene. With the
+option -g, or --genes, the gene boundaries are read from a gene file,
+in which each line defines a gene, with its name, an equal sign, and
+the list of characters (the first character is 1), as in l.parts. Genes
+must start at the first codon position, and its number of characters
+must be a multiple of 3.
+
+Before the translation, the reading frame of each gene is checked, and
+a warning is printed in the standard error for each internal stop
+codon, and for each frame shift (i.e. a codon with gaps in only some
+of its positions) found in a terminal. Stop codons, and codons with
+gaps, are translated as unknown amino acids.
+
+By default, the standard genetic code is used, and another code can be
+set with the option --code, using the NCBI number of the table, or its
+name. Valid codes are:
+
+    1   standard
+    2   vertebrate mitochondrial
+    3   yeast mitochondrial
+    4   mold mitochondrial
+    5   invertebrate mitochondrial
+    9   echinoderm mitochondrial
+    11  bacterial
+
+By default the matrix is written in the standard output, in the native
+format of ramita, another file can be set with the option -o, or
+--output.
+
+Options are:
+
+    --code <table>
+      Set the genetic code used in the translation. Default: 1
+      (standard).
+
+    -g <file>
+    --genes <file>
+      If defined, the gene boundaries will be read from the indicated
+      file.
+
+    -o <file>
+    --output <file>
+      If defined, the matrix will be written in the indicated file.
+
+    <dataset>
+      The phylogenetic data matrix. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var code string
+var genes string
+var output string
+
+func register(c *cmdapp.Command) {
+	c.Flag.StringVar(&code, "code", "", "")
+	c.Flag.StringVar(&genes, "genes", "", "")
+	c.Flag.StringVar(&genes, "g", "", "")
+	c.Flag.StringVar(&output, "output", "", "")
+	c.Flag.StringVar(&output, "o", "", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a dataset filename", c.Name())
+	}
+	gc, err := matrix.ParseGeneticCode(code)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+	}
+	defer f.Close()
+
+	m, err := matrix.NewMatrix(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+	}
+
+	gs := m.BlockPartitions()
+	if genes != "" {
+		gs, err = matrix.ReadPartitionFile(genes, len(m.Kind))
+		if err != nil {
+			return errors.Wrapf(err, "%s: while reading %s", c.Name(), genes)
+		}
+	}
+
+	var dna []matrix.Partition
+	for _, g := range gs {
+		if m.Kind[g.Chars[0]] != matrix.DNA {
+			continue
+		}
+		fp, err := m.CheckFrame(g, gc)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		for _, p := range fp {
+			if p.Stop {
+				fmt.Fprintf(os.Stderr, "# Warning: gene %s: terminal %s: internal stop codon at character %d\n", g.Name, p.Term, p.Char+1)
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "# Warning: gene %s: terminal %s: frame shift at character %d\n", g.Name, p.Term, p.Char+1)
+		}
+		dna = append(dna, g)
+	}
+	if len(dna) == 0 {
+		return errors.Errorf("%s: no DNA genes in %s", c.Name(), args[0])
+	}
+
+	p, err := m.Translate(dna, gc)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	if output == "" {
+		if err := p.Write(os.Stdout); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		return nil
+	}
+	if output == args[0] {
+		return errors.Errorf("%s: output file %s is the same as the dataset", c.Name(), output)
+	}
+	out, err := os.Create(output)
+	if err != nil {
+		return errors.Wrapf(err, "%s: while creating %s", c.Name(), output)
+	}
+	if err := p.Write(out); err != nil {
+		out.Close()
+		return errors.Wrap(err, c.Name())
+	}
+	if err := out.Close(); err != nil {
+		return errors.Wrapf(err, "%s: while closing %s", c.Name(), output)
+	}
+	return nil
+}
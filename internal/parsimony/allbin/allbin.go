@@ -0,0 +1,300 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package allbin implements the p.allbin command,
+// i.e. enumerate all the binary trees of a matrix.
+package allbin
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/internal/load"
+	"github.com/js-arias/ramita/likelihood"
+	"github.com/js-arias/ramita/parsimony"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `p.allbin [--assumptions <file>] [--bin <value>] [-c|--comma]
+		[--exclude <list>] [--gapmode <mode>] [-l|--like]
+		[--model <definition>] [--taxa <file>] [-t|--trees]
+		[--trim <definition>] [--weights <definition>]
+		[--weights-file <file>] [<dataset>]`,
+	Short: "enumerate all the binary trees of a matrix",
+	Long: `
+Command p.allbin enumerates all the binary trees of a small matrix,
+rooted on the outgroup, and prints the distribution of the scores of
+the trees. It is intended for teaching, to show the shape of the tree
+space of a dataset, for example, how many trees are near the optimal
+trees.
+
+As the number of trees grows very fast with the number of terminals
+(there are 2027025 trees of 10 terminals), only matrices with 10
+terminals or less are accepted.
+
+The trees are scored under parsimony, and the number of trees of each
+length is printed, with the cumulative proportion of trees. If the
+option -l, or --like, is set, the trees are also scored under
+likelihood (after a simple branch length refinement, as in l.like
+with the -o option), and the number of trees in each interval of
+-log likelihood is printed. The width of the intervals is set with
+the option --bin. Scoring the trees under likelihood can be slow.
+
+If the option -t, or --trees, is set, each tree is printed, preceded
+by its length, and its -log likelihood (if the option -l is set). By
+default, the trees will be printed with sister groups separed by
+spaces (tnt format). If the option -c or --comma is set, then sister
+groups will be separated by commas (,) as in phylip.
+
+Characters can be weighted with the option --weights, as a list of
+assignments, separated by semicolons, each one with a weight, a colon,
+and a list of characters (the first character is 1), given as
+numbers, or ranges, that can include a step (e.g. "2:1-300\3; 0:301"
+to give weight 2 to the first codon position of the first 300
+characters, and exclude character 301). With the option
+--weights-file, the assignments are read from a file, one assignment
+per line. By default, each character has weight 1.
+
+With the option --taxa, the analysis can be restricted to a subset of
+the terminals of the matrix (e.g. to remove rogue taxa), listed in a
+file, one terminal per line. Lines starting with '#' are ignored.
+
+Characters can be deactivated with the option --exclude, as a list of
+characters (the first character is 1), given as numbers, or ranges
+(e.g. "100-250 300"). Excluded characters are ignored in the analysis,
+but the numbers of the characters are not changed.
+
+Poorly aligned, or noisy, columns can be excluded when the matrix is
+read with the option --trim, as a list of filters, separated by
+semicolons, each one with the name of the filter, an equal sign, and
+its value. With the filter "gaps", columns with a proportion of gaps
+and missing data greater than the value are excluded, and with the
+filter "entropy", columns with a Shannon entropy (in bits) greater than
+the value are excluded (e.g. "gaps=0.5; entropy=1.8"). The number of
+columns removed from each partition is printed (the partitions are
+the partition scheme of a bundle file, see mat.bundle, or the blocks
+of the matrix).
+
+By default, gaps in DNA characters are treated as missing data. With
+the option --gapmode, gaps can be treated as a fifth state, so a
+change between a gap and a nucleotide counts as a step.
+
+Characters can be optimized with a step matrix (i.e. generalized, or
+Sankoff, parsimony) with the option --assumptions, that reads the step
+matrices from a file. Each step matrix is defined by a line with the
+keyword 'stepmatrix' and the name of the matrix, followed by a line
+for each state, with the costs of the transformation from that state
+to each state. A line with the name of a step matrix, an equal sign,
+and a list of characters, assigns the step matrix to the characters.
+Characters without a step matrix are optimized as unordered (Fitch)
+characters.
+
+Options are:
+
+    --assumptions <file>
+      If defined, the step matrices of the characters will be read
+      from the indicated file.
+
+    --bin <value>
+      Set the width of the intervals of -log likelihood.
+      Default: 1.
+
+    -c
+    --comma
+      If set, sister groups will be separated by commas.
+
+    --exclude <list>
+      If defined, the indicated characters will be excluded from the
+      analysis.
+
+    --gapmode <mode>
+      Set the treatment of gaps in DNA characters. Valid values are
+      missing, and state (a fifth state). Default: missing.
+
+    -l
+    --like
+      If set, the trees will be scored under likelihood.
+
+    --model <definition>
+      If defined, the models of the characters used in the
+      likelihood scores will be assigned using the indicated
+      definition. It is a list of model names and character ranges,
+      separated by semicolons (e.g. "jc:1-2555; mk2:2556-2922").
+      Valid models are jc, poisson, mk<n>, and mkv<n>. See l.models.
+
+    --taxa <file>
+      If defined, only the terminals listed in the indicated file
+      will be used in the analysis.
+
+    -t
+    --trees
+      If set, each tree will be printed with its scores.
+
+    --trim <definition>
+      If defined, the columns rejected by the indicated filters will
+      be excluded.
+
+    --weights <definition>
+      If defined, the characters will be weighted using the indicated
+      definition.
+
+    --weights-file <file>
+      If defined, the characters will be weighted using the
+      definition in the indicated file.
+
+    <dataset>
+      The phylogenetic data matrix. If not given explicitly, it will
+      be read from the standard input.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+// maxTerms is the maximum number of terminals
+// of a matrix.
+const maxTerms = 10
+
+var bin float64
+var comma bool
+var like bool
+var model string
+var trees bool
+
+var opts load.Options
+
+func register(c *cmdapp.Command) {
+	opts.Register(c)
+	c.Flag.Float64Var(&bin, "bin", 1, "")
+	c.Flag.BoolVar(&comma, "comma", false, "")
+	c.Flag.BoolVar(&comma, "c", false, "")
+	c.Flag.BoolVar(&like, "like", false, "")
+	c.Flag.BoolVar(&like, "l", false, "")
+	c.Flag.StringVar(&model, "model", "", "")
+	c.Flag.BoolVar(&trees, "trees", false, "")
+	c.Flag.BoolVar(&trees, "t", false, "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("%s: too many arguments", c.Name())
+	}
+	if bin <= 0 {
+		return errors.Errorf("%s: invalid interval width: %.4f", c.Name(), bin)
+	}
+
+	name := ""
+	if len(args) == 1 {
+		name = args[0]
+	}
+	m, err := opts.Read(name)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if len(m.Names) < 3 {
+		return errors.Errorf("%s: matrix with %d terminals, at least 3 are required", c.Name(), len(m.Names))
+	}
+	if len(m.Names) > maxTerms {
+		return errors.Errorf("%s: matrix with %d terminals, at most %d are allowed", c.Name(), len(m.Names), maxTerms)
+	}
+	var lm *likelihood.Matrix
+	if like {
+		lm = likelihood.NewFromMatrix(m)
+		if err := lm.SetModels(model); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	}
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	opts.WriteTrimmed(os.Stdout, m)
+	fmt.Printf("# Terminals: %d\n", len(m.Names))
+	fmt.Printf("# Trees: %d\n", parsimony.NumTrees(len(m.Names)))
+	if trees {
+		if like {
+			fmt.Printf("# length\t-lnL\ttree\n")
+		} else {
+			fmt.Printf("# length\ttree\n")
+		}
+	}
+
+	lens := make(map[int]int)
+	bins := make(map[int]int)
+	bestLike := math.Inf(1)
+	parsimony.Enumerate(m, func(tr *parsimony.Tree) {
+		if err != nil {
+			return
+		}
+		lens[tr.Cost()]++
+		var l float64
+		if like {
+			var lt *likelihood.Tree
+			lt, err = likelihood.FromTopology(tr.Topology(), lm)
+			if err != nil {
+				return
+			}
+			lt.Refine()
+			l = -lt.Like()
+			bins[int(math.Floor(l/bin))]++
+			if l < bestLike {
+				bestLike = l
+			}
+		}
+		if !trees {
+			return
+		}
+		fmt.Printf("%d\t", tr.Cost())
+		if like {
+			fmt.Printf("%.6f\t", l)
+		}
+		tr.Write(os.Stdout, comma)
+		fmt.Printf("\n")
+	})
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	total := parsimony.NumTrees(len(m.Names))
+	fmt.Printf("# Length distribution\n")
+	fmt.Printf("length\ttrees\tcumulative\n")
+	printDist(lens, total, func(k int) string {
+		return fmt.Sprintf("%d", k)
+	})
+	if like {
+		fmt.Printf("# Likelihood distribution\n")
+		fmt.Printf("# Best -log likelihood: %.6f\n", bestLike)
+		fmt.Printf("-lnL\ttrees\tcumulative\n")
+		printDist(bins, total, func(k int) string {
+			return fmt.Sprintf("%.4f-%.4f", float64(k)*bin, float64(k+1)*bin)
+		})
+	}
+	return nil
+}
+
+// PrintDist prints a distribution of scores,
+// sorted from the best to the worst score,
+// with the number of trees,
+// and the cumulative proportion of trees.
+func printDist(dist map[int]int, total int, label func(k int) string) {
+	keys := make([]int, 0, len(dist))
+	for k := range dist {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	sum := 0
+	for _, k := range keys {
+		sum += dist[k]
+		fmt.Printf("%s\t%d\t%.6f\n", label(k), dist[k], float64(sum)/float64(total))
+	}
+}
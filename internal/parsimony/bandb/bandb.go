@@ -0,0 +1,197 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package bandb implements the p.bandb command,
+// i.e. an exact parsimony search with branch and bound.
+package bandb
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/internal/load"
+	"github.com/js-arias/ramita/parsimony"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `p.bandb [--assumptions <file>] [-c|--comma] [--exclude <list>]
+		[--gapmode <mode>] [--taxa <file>] [--trim <definition>]
+		[--weights <definition>] [--weights-file <file>] [<dataset>]`,
+	Short: "exact parsimony search with branch and bound",
+	Long: `
+Command p.bandb makes an exact search with the branch and bound
+algorithm, and prints all the most parsimonious trees.
+
+The terminals are added to a growing tree in all possible positions,
+and a partial tree is discarded as soon as its length is greater than
+the length of the best tree found. As the number of trees grows very
+fast with the number of terminals, it is only useful for small
+matrices (about 20 terminals or less). A warning is printed if the
+matrix is larger.
+
+By default, the trees will be printed with sister groups separed by
+spaces (tnt format). If the option -c or --comma is set, then sister
+groups will be separated by commas (,) as in phylip.
+
+Terminals without data, as well as blocks without data in all
+terminals, are removed before the analysis, and a warning is printed.
+
+Characters can be weighted with the option --weights, as a list of
+assignments, separated by semicolons, each one with a weight, a colon,
+and a list of characters (the first character is 1), given as
+numbers, or ranges, that can include a step (e.g. "2:1-300\3; 0:301"
+to give weight 2 to the first codon position of the first 300
+characters, and exclude character 301). With the option
+--weights-file, the assignments are read from a file, one assignment
+per line. By default, each character has weight 1.
+
+With the option --taxa, the analysis can be restricted to a subset of
+the terminals of the matrix (e.g. to remove rogue taxa), listed in a
+file, one terminal per line. Lines starting with '#' are ignored.
+
+Characters can be deactivated with the option --exclude, as a list of
+characters (the first character is 1), given as numbers, or ranges
+(e.g. "100-250 300"). Excluded characters are ignored in the analysis,
+but the numbers of the characters are not changed.
+
+Poorly aligned, or noisy, columns can be excluded when the matrix is
+read with the option --trim, as a list of filters, separated by
+semicolons, each one with the name of the filter, an equal sign, and
+its value. With the filter "gaps", columns with a proportion of gaps
+and missing data greater than the value are excluded, and with the
+filter "entropy", columns with a Shannon entropy (in bits) greater than
+the value are excluded (e.g. "gaps=0.5; entropy=1.8"). The number of
+columns removed from each partition is printed (the partitions are
+the partition scheme of a bundle file, see mat.bundle, or the blocks
+of the matrix).
+
+By default, gaps in DNA characters are treated as missing data. With
+the option --gapmode, gaps can be treated as a fifth state, so a
+change between a gap and a nucleotide counts as a step.
+
+Characters can be optimized with a step matrix (i.e. generalized, or
+Sankoff, parsimony) with the option --assumptions, that reads the step
+matrices from a file. Each step matrix is defined by a line with the
+keyword 'stepmatrix' and the name of the matrix, followed by a line
+for each state, with the costs of the transformation from that state
+to each state. A line with the name of a step matrix, an equal sign,
+and a list of characters, assigns the step matrix to the characters.
+Characters without a step matrix are optimized as unordered (Fitch)
+characters.
+
+Options are:
+
+    --assumptions <file>
+      If defined, the step matrices of the characters will be read
+      from the indicated file.
+
+    -c
+    --comma
+      If set, sister groups will be separated by commas.
+
+    --exclude <list>
+      If defined, the indicated characters will be excluded from the
+      analysis.
+
+    --gapmode <mode>
+      Set the treatment of gaps in DNA characters. Valid values are
+      missing, and state (a fifth state). Default: missing.
+
+    --taxa <file>
+      If defined, only the terminals listed in the indicated file
+      will be used in the analysis.
+
+    --trim <definition>
+      If defined, the columns rejected by the indicated filters will
+      be excluded.
+
+    --weights <definition>
+      If defined, the characters will be weighted using the indicated
+      definition.
+
+    --weights-file <file>
+      If defined, the characters will be weighted using the
+      definition in the indicated file.
+
+    <dataset>
+      The phylogenetic data matrix. If not given explicitly, it will
+      be read from the standard input.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+// maxTerms is the number of terminals
+// above which a warning is printed.
+const maxTerms = 20
+
+var comma bool
+
+var opts load.Options
+
+func register(c *cmdapp.Command) {
+	opts.Register(c)
+	c.Flag.BoolVar(&comma, "comma", false, "")
+	c.Flag.BoolVar(&comma, "c", false, "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("%s: too many arguments", c.Name())
+	}
+
+	name := ""
+	if len(args) == 1 {
+		name = args[0]
+	}
+	m, err := opts.Read(name)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	opts.WriteTrimmed(os.Stdout, m)
+
+	if empty := m.Empty(); len(empty) > 0 {
+		for _, nm := range empty {
+			fmt.Printf("# Warning: terminal %s without data: removed\n", nm)
+		}
+		m = m.DropTaxa(empty)
+	}
+	if empty := m.EmptyBlocks(); len(empty) > 0 {
+		for _, b := range empty {
+			fmt.Printf("# Warning: block %s without data: removed\n", m.Blocks[b].Name)
+		}
+		m = m.DropBlocks(empty)
+	}
+	if len(m.Names) < 3 {
+		return errors.Errorf("%s: matrix with %d terminals, at least 3 are required", c.Name(), len(m.Names))
+	}
+	if len(m.Names) > maxTerms {
+		fmt.Printf("# Warning: matrix with %d terminals: the search can be very slow\n", len(m.Names))
+	}
+
+	chars := len(m.Kind)
+	m, _ = m.Compress(nil)
+	fmt.Printf("# Site patterns: %d of %d characters\n", len(m.Kind), chars)
+
+	ts := parsimony.BranchAndBound(m)
+	fmt.Printf("# Length: %d\n", ts.Cost())
+	fmt.Printf("# Trees: %d\n", ts.Len())
+	for _, tp := range ts.Trees() {
+		tp.Write(os.Stdout, comma)
+		fmt.Printf("\n")
+	}
+	return nil
+}
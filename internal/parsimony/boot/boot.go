@@ -0,0 +1,326 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package boot implements the p.boot command,
+// i.e. a parsimony bootstrap.
+package boot
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/consensus"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/internal/load"
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/parsimony"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `p.boot [-a|--additions <number>] [--assumptions <file>]
+		[-c|--comma] [--exclude <list>] [--gapmode <mode>]
+		[-r|--replicates <number>] [--replicate-range <a-b>]
+		[--swap <name>] [--taxa <file>] [-t|--trees]
+		[--trim <definition>] [--weights <definition>]
+		[--weights-file <file>] [<dataset>]`,
+	Short: "parsimony bootstrap",
+	Long: `
+Command p.boot performs a non-parametric bootstrap with parsimony. In
+each replicate, the characters are resampled with replacement, and a
+Wagner-Dayoff search is made on the resampled matrix. The output is
+the majority rule consensus of the trees of the replicates, with each
+clade labeled with its bootstrap proportion.
+
+By default, 100 replicates are made, and the number of replicates can
+be changed with the option -r, or --replicates. In each replicate a
+single Wagner-Dayoff tree is made, and with the option -a, or
+--additions, the number of Wagner-Dayoff trees made in each replicate
+can be changed (the shortest tree is used). By default, the Wagner
+trees are improved with SPR branch swapping, and the swapping algorithm
+can be changed with the option --swap (nni, spr, or tbr).
+
+If the option -t, or --trees, is set, the tree of each replicate will
+be printed, instead of the consensus tree.
+
+If the option --replicate-range is defined with a range of replicates
+(e.g. 101-200), only the replicates in the range will be made, and
+the trees will be printed in NEXUS format, named "boot<n>". Each
+replicate uses a random seed derived from its number, so a large
+number of replicates can be split across several jobs (for example in
+a cluster). The resulting files can be combined with t.merge, and
+summarized with p.consensus -m 0.5.
+
+By default, the tree will be printed with sister groups separated by
+spaces (tnt format). If the option -c or --comma is set, then sister
+groups will be separated by commas (,) as in newick.
+
+Characters can be weighted with the option --weights, as a list of
+assignments, separated by semicolons, each one with a weight, a colon,
+and a list of characters (the first character is 1), given as
+numbers, or ranges, that can include a step (e.g. "2:1-300\3; 0:301"
+to give weight 2 to the first codon position of the first 300
+characters, and exclude character 301). With the option
+--weights-file, the assignments are read from a file, one assignment
+per line. By default, each character has weight 1.
+
+With the option --taxa, the analysis can be restricted to a subset of
+the terminals of the matrix (e.g. to remove rogue taxa), listed in a
+file, one terminal per line. Lines starting with '#' are ignored.
+
+Characters can be deactivated with the option --exclude, as a list of
+characters (the first character is 1), given as numbers, or ranges
+(e.g. "100-250 300"). Excluded characters are ignored in the analysis,
+but the numbers of the characters are not changed.
+
+Poorly aligned, or noisy, columns can be excluded when the matrix is
+read with the option --trim, as a list of filters, separated by
+semicolons, each one with the name of the filter, an equal sign, and
+its value. With the filter "gaps", columns with a proportion of gaps
+and missing data greater than the value are excluded, and with the
+filter "entropy", columns with a Shannon entropy (in bits) greater than
+the value are excluded (e.g. "gaps=0.5; entropy=1.8"). The number of
+columns removed from each partition is printed (the partitions are
+the partition scheme of a bundle file, see mat.bundle, or the blocks
+of the matrix).
+
+By default, gaps in DNA characters are treated as missing data. With
+the option --gapmode, gaps can be treated as a fifth state, so a
+change between a gap and a nucleotide counts as a step.
+
+Characters can be optimized with a step matrix (i.e. generalized, or
+Sankoff, parsimony) with the option --assumptions, that reads the step
+matrices from a file. Each step matrix is defined by a line with the
+keyword 'stepmatrix' and the name of the matrix, followed by a line
+for each state, with the costs of the transformation from that state
+to each state. A line with the name of a step matrix, an equal sign,
+and a list of characters, assigns the step matrix to the characters.
+Characters without a step matrix are optimized as unordered (Fitch)
+characters.
+
+Options are:
+
+    -a <number>
+    --additions <number>
+      Set the number of Wagner-Dayoff trees made in each replicate.
+      Default: 1.
+
+    --assumptions <file>
+      If defined, the step matrices of the characters will be read
+      from the indicated file.
+
+    -c
+    --comma
+      If set, sister groups will be separated by commas.
+
+    --exclude <list>
+      If defined, the indicated characters will be excluded from the
+      analysis.
+
+    --gapmode <mode>
+      Set the treatment of gaps in DNA characters. Valid values are
+      missing, and state (a fifth state). Default: missing.
+
+    -r <number>
+    --replicates <number>
+      Set the number of bootstrap replicates. Default: 100.
+
+    --replicate-range <a-b>
+      If defined, the indicated range of replicates will be made,
+      and the trees printed in NEXUS format.
+
+    --swap <name>
+      Set the branch swapping algorithm, either nni, spr, or tbr.
+      Default: spr.
+
+    --taxa <file>
+      If defined, only the terminals listed in the indicated file
+      will be used in the analysis.
+
+    -t
+    --trees
+      If set, the trees of each replicate will be printed.
+
+    --trim <definition>
+      If defined, the columns rejected by the indicated filters will
+      be excluded.
+
+    --weights <definition>
+      If defined, the characters will be weighted using the indicated
+      definition.
+
+    --weights-file <file>
+      If defined, the characters will be weighted using the
+      definition in the indicated file.
+
+    <dataset>
+      The phylogenetic data matrix. If not given explicitly, it will
+      be read from the standard input.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var additions int
+var comma bool
+var reps int
+var repRange string
+var swap string
+var trees bool
+
+// swapping algorithm
+var sw parsimony.Swap
+
+var opts load.Options
+
+func register(c *cmdapp.Command) {
+	c.Flag.IntVar(&additions, "additions", 1, "")
+	c.Flag.IntVar(&additions, "a", 1, "")
+	opts.Register(c)
+	c.Flag.BoolVar(&comma, "comma", false, "")
+	c.Flag.BoolVar(&comma, "c", false, "")
+	c.Flag.IntVar(&reps, "replicates", 100, "")
+	c.Flag.IntVar(&reps, "r", 100, "")
+	c.Flag.StringVar(&repRange, "replicate-range", "", "")
+	c.Flag.StringVar(&swap, "swap", "spr", "")
+	c.Flag.BoolVar(&trees, "trees", false, "")
+	c.Flag.BoolVar(&trees, "t", false, "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("%s: too many arguments", c.Name())
+	}
+	if reps < 1 {
+		return errors.Errorf("%s: invalid number of replicates: %d", c.Name(), reps)
+	}
+	if additions < 1 {
+		return errors.Errorf("%s: invalid number of additions: %d", c.Name(), additions)
+	}
+	var err error
+	sw, err = parsimony.ParseSwap(swap)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	first, last := 0, 0
+	if repRange != "" {
+		first, last, err = parseRange(repRange)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	}
+
+	name := ""
+	if len(args) == 1 {
+		name = args[0]
+	}
+	m, err := opts.Read(name)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	if repRange != "" {
+		if err := replicates(m, first, last); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		return nil
+	}
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	opts.WriteTrimmed(os.Stdout, m)
+	bt := parsimony.Bootstrap(m, reps, search)
+	fmt.Printf("# Replicates: %d\n", reps)
+	if trees {
+		for _, t := range bt {
+			t.Write(os.Stdout, comma)
+			fmt.Printf("\n")
+		}
+		return nil
+	}
+
+	set := &consensus.Set{}
+	for _, t := range bt {
+		if err := set.Add(t); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	}
+	t, err := set.Majority(0.5)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	t.Lens = false
+	t.Write(os.Stdout, comma)
+	fmt.Printf("\n")
+	return nil
+}
+
+// Search returns the shortest tree
+// of a set of Wagner-Dayoff trees.
+func search(m *matrix.Matrix) *parsimony.Tree {
+	var best *parsimony.Tree
+	for i := 0; i < additions; i++ {
+		tr := parsimony.Wagner(m)
+		tr.SwapLimit(sw, nil, nil)
+		if best == nil || tr.Cost() < best.Cost() {
+			best = tr
+		}
+	}
+	return best
+}
+
+// Replicates makes the bootstrap replicates
+// in a range,
+// and writes the trees in NEXUS format.
+func replicates(m *matrix.Matrix, first, last int) error {
+	ls := make([]string, 0, len(m.Names))
+	for nm := range m.Names {
+		ls = append(ls, nm)
+	}
+	tw := tree.NewWriter(os.Stdout, ls)
+	if err := tw.Comment(env.String() + " replicate seeds=replicate number"); err != nil {
+		return err
+	}
+	for i := first; i <= last; i++ {
+		rand.Seed(int64(i))
+		t := parsimony.Bootstrap(m, 1, search)[0]
+		t.Name = fmt.Sprintf("boot%d", i)
+		if err := tw.Write(t); err != nil {
+			return err
+		}
+	}
+	return tw.Close()
+}
+
+// ParseRange parses a replicate range
+// in the form a-b.
+func parseRange(s string) (first, last int, err error) {
+	i := strings.Index(s, "-")
+	if i < 0 {
+		return 0, 0, errors.Errorf("invalid replicate range %q", s)
+	}
+	first, err = strconv.Atoi(strings.TrimSpace(s[:i]))
+	if err != nil {
+		return 0, 0, errors.Errorf("invalid replicate range %q", s)
+	}
+	last, err = strconv.Atoi(strings.TrimSpace(s[i+1:]))
+	if err != nil {
+		return 0, 0, errors.Errorf("invalid replicate range %q", s)
+	}
+	if first < 1 || last < first {
+		return 0, 0, errors.Errorf("invalid replicate range %q", s)
+	}
+	return first, last, nil
+}
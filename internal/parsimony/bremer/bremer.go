@@ -0,0 +1,322 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package bremer implements the p.bremer command,
+// i.e. the Bremer support of the clades of a tree.
+package bremer
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/internal/load"
+	"github.com/js-arias/ramita/parsimony"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `p.bremer [--assumptions <file>] [-c|--comma]
+		[--exclude <list>] [--gapmode <mode>] [-l|--list]
+		[--max-rearrangements <number>] [--max-time <duration>]
+		[-m|--max-trees <number>] [-s|--slack <number>]
+		[--taxa <file>] [-t|--tree <treefile>] [--trim <definition>]
+		[--weights <definition>] [--weights-file <file>] <dataset>`,
+	Short: "Bremer support of the clades of a tree",
+	Long: `
+Command p.bremer reads an optimal tree (for example, a tree made with
+p.wagday), and prints the tree with the Bremer support (or decay
+index) of each clade, i.e. the number of extra steps needed to lose
+the clade.
+
+Bremer supports are estimated using suboptimal trees. Starting from
+the given tree, all the trees found by SPR rearrangements of the trees
+in a buffer, that are at most a given number of steps (the slack)
+longer than the best tree, are stored in the buffer, until all the
+trees in the buffer are swapped. The support of each clade is the
+difference between the length of the shortest tree of the buffer
+without the clade and the best length. By default, the slack is 2
+steps, and it can be changed with the option -s, or --slack. Clades
+found in all the trees of the buffer have a support larger than the
+slack, and they are labeled with the slack plus one, followed by a
+'+' sign (e.g. "3+").
+
+By default, the buffer keeps up to 10000 trees, and this number can
+be changed with the option -m, or --max-trees (0 means no limit). The
+search can be also bounded with the options --max-time, that sets the
+maximum running time (e.g. 30s, 10m, 1h30m), and --max-rearrangements,
+that sets the maximum number of rearrangements evaluated. If the
+buffer is full, or a limit is reached, the support values might be
+overestimated, and a warning is printed.
+
+If a tree shorter than the given tree is found, a warning is printed,
+and support values are calculated using the length of the shortest
+tree. In that case, clades of the given tree that are not found in the
+shortest tree will have a support of 0.
+
+The tree will be read from the standard input, unless the option -t,
+or --tree, is defined with a tree file. The tree will be rooted at the
+outgroup, and its polytomies will be resolved at random (so the
+supports of the new clades are arbitrary).
+
+If the option -l, or --list, is set, instead of a tree, the support
+of each clade is printed in a line, with the support, a tab, and the
+terminals of the clade.
+
+By default, the tree will be printed with sister groups separated by
+spaces (tnt format). If the option -c or --comma is set, then sister
+groups will be separated by commas (,) as in newick.
+
+Characters can be weighted with the option --weights, as a list of
+assignments, separated by semicolons, each one with a weight, a colon,
+and a list of characters (the first character is 1), given as
+numbers, or ranges, that can include a step (e.g. "2:1-300\3; 0:301"
+to give weight 2 to the first codon position of the first 300
+characters, and exclude character 301). With the option
+--weights-file, the assignments are read from a file, one assignment
+per line. By default, each character has weight 1.
+
+With the option --taxa, the analysis can be restricted to a subset of
+the terminals of the matrix (e.g. to remove rogue taxa), listed in a
+file, one terminal per line. Lines starting with '#' are ignored.
+
+Characters can be deactivated with the option --exclude, as a list of
+characters (the first character is 1), given as numbers, or ranges
+(e.g. "100-250 300"). Excluded characters are ignored in the analysis,
+but the numbers of the characters are not changed.
+
+Poorly aligned, or noisy, columns can be excluded when the matrix is
+read with the option --trim, as a list of filters, separated by
+semicolons, each one with the name of the filter, an equal sign, and
+its value. With the filter "gaps", columns with a proportion of gaps
+and missing data greater than the value are excluded, and with the
+filter "entropy", columns with a Shannon entropy (in bits) greater than
+the value are excluded (e.g. "gaps=0.5; entropy=1.8"). The number of
+columns removed from each partition is printed (the partitions are
+the partition scheme of a bundle file, see mat.bundle, or the blocks
+of the matrix).
+
+By default, gaps in DNA characters are treated as missing data. With
+the option --gapmode, gaps can be treated as a fifth state, so a
+change between a gap and a nucleotide counts as a step.
+
+Characters can be optimized with a step matrix (i.e. generalized, or
+Sankoff, parsimony) with the option --assumptions, that reads the step
+matrices from a file. Each step matrix is defined by a line with the
+keyword 'stepmatrix' and the name of the matrix, followed by a line
+for each state, with the costs of the transformation from that state
+to each state. A line with the name of a step matrix, an equal sign,
+and a list of characters, assigns the step matrix to the characters.
+Characters without a step matrix are optimized as unordered (Fitch)
+characters.
+
+Options are:
+
+    --assumptions <file>
+      If defined, the step matrices of the characters will be read
+      from the indicated file.
+
+    -c
+    --comma
+      If set, sister groups will be separated by commas.
+
+    --exclude <list>
+      If defined, the indicated characters will be excluded from the
+      analysis.
+
+    --gapmode <mode>
+      Set the treatment of gaps in DNA characters. Valid values are
+      missing, and state (a fifth state). Default: missing.
+
+    -l
+    --list
+      If set, the support of each clade will be printed as a list.
+
+    --max-rearrangements <number>
+      If defined, the search will be stopped after the indicated
+      number of rearrangements.
+
+    --max-time <duration>
+      If defined, the search will be stopped after the indicated
+      time.
+
+    -m <number>
+    --max-trees <number>
+      Set the maximum number of trees in the buffer. Default: 10000.
+
+    -s <number>
+    --slack <number>
+      Set the maximum number of extra steps of the trees in the
+      buffer. Default: 2.
+
+    --taxa <file>
+      If defined, only the terminals listed in the indicated file
+      will be used in the analysis.
+
+    -t <treefile>
+    --tree <treefile>
+      If defined, the tree will be read from the indicated file,
+      instead of the standard input.
+
+    --trim <definition>
+      If defined, the columns rejected by the indicated filters will
+      be excluded.
+
+    --weights <definition>
+      If defined, the characters will be weighted using the indicated
+      definition.
+
+    --weights-file <file>
+      If defined, the characters will be weighted using the
+      definition in the indicated file.
+
+    <dataset>
+      The phylogenetic data matrix. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var comma bool
+var list bool
+var maxRearr int
+var maxTime time.Duration
+var maxTrees int
+var slack int
+var treefile string
+
+var opts load.Options
+
+func register(c *cmdapp.Command) {
+	opts.Register(c)
+	c.Flag.BoolVar(&comma, "comma", false, "")
+	c.Flag.BoolVar(&comma, "c", false, "")
+	c.Flag.BoolVar(&list, "list", false, "")
+	c.Flag.BoolVar(&list, "l", false, "")
+	c.Flag.IntVar(&maxRearr, "max-rearrangements", 0, "")
+	c.Flag.DurationVar(&maxTime, "max-time", 0, "")
+	c.Flag.IntVar(&maxTrees, "max-trees", 10000, "")
+	c.Flag.IntVar(&maxTrees, "m", 10000, "")
+	c.Flag.IntVar(&slack, "slack", 2, "")
+	c.Flag.IntVar(&slack, "s", 2, "")
+	c.Flag.StringVar(&treefile, "tree", "", "")
+	c.Flag.StringVar(&treefile, "t", "", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a dataset filename", c.Name())
+	}
+	if slack < 1 {
+		return errors.Errorf("%s: invalid slack: %d", c.Name(), slack)
+	}
+	if maxTrees < 0 {
+		return errors.Errorf("%s: invalid number of trees: %d", c.Name(), maxTrees)
+	}
+
+	m, err := opts.Read(args[0])
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	opts.WriteTrimmed(os.Stdout, m)
+	chars := len(m.Kind)
+	m, _ = m.Compress(nil)
+	fmt.Printf("# Site patterns: %d of %d characters\n", len(m.Kind), chars)
+
+	tf := os.Stdin
+	if treefile != "" {
+		tf, err = os.Open(treefile)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), treefile)
+		}
+		defer tf.Close()
+	}
+	tp, err := tree.Read(tf)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing tree", c.Name())
+	}
+	if err := tp.Reroot(m.Out.Name); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	tr, err := parsimony.FromTopology(tp, m)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	var lim *parsimony.Limit
+	if maxTime > 0 || maxRearr > 0 {
+		lim = parsimony.NewLimit(maxTime, maxRearr)
+	}
+
+	ts := &parsimony.TreeSet{
+		Max:   maxTrees,
+		Slack: slack,
+	}
+	ts.Add(tr)
+	ok, err := ts.Suboptimal(m, lim)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	fmt.Printf("# Tree Length: %d\n", tr.Cost())
+	if ts.Cost() < tr.Cost() {
+		fmt.Printf("# Warning: shorter tree found: length %d\n", ts.Cost())
+	}
+	fmt.Printf("# Trees: %d\n", ts.Len())
+	if !ok {
+		fmt.Printf("# Warning: search stopped: limit reached\n")
+	} else if maxTrees > 0 && ts.Len() >= maxTrees {
+		fmt.Printf("# Warning: search stopped: tree buffer full\n")
+	}
+
+	sup := parsimony.Bremer(tp, ts)
+	if list {
+		for _, s := range sup {
+			fmt.Printf("%s\t%s\n", label(s), strings.Join(s.Clade, " "))
+		}
+		return nil
+	}
+
+	labels := make(map[string]string, len(sup))
+	for _, s := range sup {
+		labels[strings.Join(s.Clade, " ")] = label(s)
+	}
+	for _, n := range tp.Nodes() {
+		if n.IsTerm() || n == tp.Root {
+			continue
+		}
+		terms := n.Terms()
+		sort.Strings(terms)
+		n.Label = labels[strings.Join(terms, " ")]
+	}
+	tp.Lens = false
+	tp.Write(os.Stdout, comma)
+	fmt.Printf("\n")
+	return nil
+}
+
+// Label returns the label
+// of a support value.
+func label(s parsimony.Support) string {
+	v := strconv.Itoa(s.Value)
+	if s.Lower {
+		v += "+"
+	}
+	return v
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package cons implements the p.consensus command,
+// i.e. the consensus of a set of trees.
+package cons
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/consensus"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `p.consensus [-c|--comma] [-m|--majority <cutoff>]
+		[-u|--unique] [<treefile>...]`,
+	Short: "consensus of a set of trees",
+	Long: `
+Command p.consensus reads a set of trees, for example the equally
+parsimonious trees found with p.wagday -a, and prints its strict
+consensus, i.e. a tree with the clades found in all the trees. The
+consensus can be polytomic.
+
+If the option -m, or --majority, is defined, the majority rule
+consensus will be printed instead, i.e. a tree with the clades found
+in more than the indicated proportion of the trees (for example, 0.5
+for the usual majority rule consensus). The cutoff must be at least
+0.5, and less than 1. Each clade of the majority rule consensus is
+labeled with its frequency. This is useful to summarize the trees of
+bootstrap, or multi-replicate searches.
+
+Trees can be in parenthetical or NEXUS format. All the trees must have
+the same terminals. Lines starting with '#' are ignored, so the output
+of other commands can be used directly.
+
+If the option -u, or --unique, is set, repeated topologies (e.g. the
+same tree found in different replicates of a search, or in different
+files) are used only once. Trees are compared by its topology, so
+trees that differ only in the order of its nodes, or in its branch
+lengths, are taken as the same tree.
+
+By default, the tree will be printed with sister groups separated by
+spaces (tnt format). If the option -c or --comma is set, then sister
+groups will be separated by commas (,) as in newick.
+
+Options are:
+
+    -c
+    --comma
+      If set, sister groups will be separated by commas.
+
+    -m <cutoff>
+    --majority <cutoff>
+      If defined, the majority rule consensus with the indicated
+      cutoff will be printed.
+
+    -u
+    --unique
+      If set, repeated topologies will be used only once.
+
+    <treefile>...
+      One or more files with trees. If no file is given, the trees
+      will be read from the standard input.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var comma bool
+var majority float64
+var unique bool
+
+func register(c *cmdapp.Command) {
+	c.Flag.BoolVar(&comma, "comma", false, "")
+	c.Flag.BoolVar(&comma, "c", false, "")
+	c.Flag.Float64Var(&majority, "majority", 0, "")
+	c.Flag.Float64Var(&majority, "m", 0, "")
+	c.Flag.BoolVar(&unique, "unique", false, "")
+	c.Flag.BoolVar(&unique, "u", false, "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if majority != 0 && (majority < 0.5 || majority >= 1) {
+		return errors.Errorf("%s: invalid majority cutoff: %.3f", c.Name(), majority)
+	}
+
+	set := &consensus.Set{Unique: unique}
+	if len(args) == 0 {
+		if err := addTrees(set, os.Stdin); err != nil {
+			return errors.Wrapf(err, "%s: while reading trees", c.Name())
+		}
+	}
+	for _, fn := range args {
+		f, err := os.Open(fn)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), fn)
+		}
+		err = addTrees(set, f)
+		f.Close()
+		if err != nil {
+			return errors.Wrapf(err, "%s: while reading %s", c.Name(), fn)
+		}
+	}
+	if set.Trees() == 0 {
+		return errors.Errorf("%s: no trees found", c.Name())
+	}
+
+	var t *tree.Tree
+	var err error
+	if majority > 0 {
+		t, err = set.Majority(majority)
+	} else {
+		t, err = set.Strict()
+	}
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	t.Lens = false
+	fmt.Printf("# Trees: %d\n", set.Trees())
+	t.Write(os.Stdout, comma)
+	fmt.Printf("\n")
+	return nil
+}
+
+// AddTrees adds the trees of a reader
+// to a consensus set.
+func addTrees(set *consensus.Set, f io.Reader) error {
+	r := tree.NewReader(f)
+	for r.Scan() {
+		if err := set.Add(r.Tree()); err != nil {
+			return err
+		}
+	}
+	return r.Err()
+}
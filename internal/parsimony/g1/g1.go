@@ -0,0 +1,228 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package g1 implements the p.g1 command,
+// i.e. the length distribution of random trees.
+package g1
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/internal/load"
+	"github.com/js-arias/ramita/parsimony"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `p.g1 [-a|--all] [--assumptions <file>] [--exclude <list>]
+		[--gapmode <mode>] [--taxa <file>] [-n|--trees <number>]
+		[--trim <definition>] [--weights <definition>]
+		[--weights-file <file>] [<dataset>]`,
+	Short: "length distribution of random trees",
+	Long: `
+Command p.g1 builds a sample of random binary trees of a matrix,
+prints the distribution of the parsimony lengths of the trees, and
+its g1 statistic (the skewness of the distribution).
+
+The g1 statistic is a classical measure of the phylogenetic signal of
+a matrix (Hillis & Huelsenbeck 1992, J. Hered. 83: 189). Matrices
+with a strong signal have few short trees and many long trees, i.e. a
+distribution with a strong negative skew, while random data produces
+a nearly symmetric distribution. The critical values of g1 depend on
+the number of terminals and characters, so the value should be
+compared with the values of random matrices of the same size.
+
+The random trees are built adding the terminals in a random order,
+each one in a random branch, so all the trees, rooted on the outgroup,
+have the same probability. By default 10000 trees are built, the
+number can be changed with the option -n, or --trees. If the option
+-a, or --all, is set, all the binary trees will be used (see
+p.allbin), and then the matrix can not have more than 10 terminals.
+
+The output includes the number of trees, the mean length, the
+standard deviation, the shortest length found, and the g1 statistic,
+followed by the number of trees of each length, with the cumulative
+proportion of trees.
+
+With the option --taxa, the analysis can be restricted to a subset of
+the terminals of the matrix (e.g. to remove rogue taxa), listed in a
+file, one terminal per line. Lines starting with '#' are ignored.
+
+Characters can be deactivated with the option --exclude, as a list of
+characters (the first character is 1), given as numbers, or ranges
+(e.g. "100-250 300"). Excluded characters are ignored in the analysis,
+but the numbers of the characters are not changed.
+
+Poorly aligned, or noisy, columns can be excluded when the matrix is
+read with the option --trim, as a list of filters, separated by
+semicolons, each one with the name of the filter, an equal sign, and
+its value. With the filter "gaps", columns with a proportion of gaps
+and missing data greater than the value are excluded, and with the
+filter "entropy", columns with a Shannon entropy (in bits) greater than
+the value are excluded (e.g. "gaps=0.5; entropy=1.8"). The number of
+columns removed from each partition is printed (the partitions are
+the partition scheme of a bundle file, see mat.bundle, or the blocks
+of the matrix).
+
+By default, gaps in DNA characters are treated as missing data. With
+the option --gapmode, gaps can be treated as a fifth state, so a
+change between a gap and a nucleotide counts as a step.
+
+Characters can be optimized with a step matrix (i.e. generalized, or
+Sankoff, parsimony) with the option --assumptions, that reads the step
+matrices from a file (see p.wagday). Characters without a step matrix
+are optimized as unordered (Fitch) characters.
+
+Options are:
+
+    -a
+    --all
+      If set, all the binary trees will be used, instead of random
+      trees.
+
+    --assumptions <file>
+      If defined, the step matrices of the characters will be read
+      from the indicated file.
+
+    --exclude <list>
+      If defined, the indicated characters will be excluded from the
+      analysis.
+
+    --gapmode <mode>
+      Set the treatment of gaps in DNA characters. Valid values are
+      missing, and state (a fifth state). Default: missing.
+
+    --taxa <file>
+      If defined, only the terminals listed in the indicated file
+      will be used in the analysis.
+
+    -n <number>
+    --trees <number>
+      Set the number of random trees. Default: 10000.
+
+    --trim <definition>
+      If defined, the columns rejected by the indicated filters will
+      be excluded.
+
+    --weights <definition>
+      If defined, the characters will be weighted using the indicated
+      definition (see p.bandb).
+
+    --weights-file <file>
+      If defined, the characters will be weighted using the
+      definition in the indicated file.
+
+    <dataset>
+      The phylogenetic data matrix. If not given explicitly, it will
+      be read from the standard input.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+// maxTerms is the maximum number of terminals
+// of a matrix
+// when all the trees are used.
+const maxTerms = 10
+
+var all bool
+var numTrees int
+
+var opts load.Options
+
+func register(c *cmdapp.Command) {
+	c.Flag.BoolVar(&all, "all", false, "")
+	c.Flag.BoolVar(&all, "a", false, "")
+	c.Flag.IntVar(&numTrees, "trees", 10000, "")
+	c.Flag.IntVar(&numTrees, "n", 10000, "")
+	opts.Register(c)
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("%s: too many arguments", c.Name())
+	}
+	if numTrees < 2 {
+		return errors.Errorf("%s: invalid number of trees: %d", c.Name(), numTrees)
+	}
+
+	name := ""
+	if len(args) == 1 {
+		name = args[0]
+	}
+	m, err := opts.Read(name)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if len(m.Names) < 4 {
+		return errors.Errorf("%s: matrix with %d terminals, at least 4 are required", c.Name(), len(m.Names))
+	}
+	if all && len(m.Names) > maxTerms {
+		return errors.Errorf("%s: matrix with %d terminals, at most %d are allowed with --all", c.Name(), len(m.Names), maxTerms)
+	}
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	opts.WriteTrimmed(os.Stdout, m)
+	chars := len(m.Kind)
+	m, _ = m.Compress(nil)
+	fmt.Printf("# Site patterns: %d of %d characters\n", len(m.Kind), chars)
+
+	var lens []int
+	if all {
+		lens = make([]int, 0, parsimony.NumTrees(len(m.Names)))
+		parsimony.Enumerate(m, func(tr *parsimony.Tree) {
+			lens = append(lens, tr.Cost())
+		})
+	} else {
+		lens = make([]int, 0, numTrees)
+		for i := 0; i < numTrees; i++ {
+			lens = append(lens, parsimony.RandomTree(m).Cost())
+		}
+	}
+
+	dist := make(map[int]int)
+	mean := 0.0
+	for _, l := range lens {
+		dist[l]++
+		mean += float64(l)
+	}
+	mean /= float64(len(lens))
+	sd := 0.0
+	for _, l := range lens {
+		d := float64(l) - mean
+		sd += d * d
+	}
+	sd = math.Sqrt(sd / float64(len(lens)))
+
+	keys := make([]int, 0, len(dist))
+	for k := range dist {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+
+	fmt.Printf("# Trees: %d\n", len(lens))
+	fmt.Printf("# Mean length: %.4f\n", mean)
+	fmt.Printf("# Standard deviation: %.4f\n", sd)
+	fmt.Printf("# Shortest length: %d\n", keys[0])
+	fmt.Printf("# g1: %.6f\n", parsimony.G1(lens))
+	fmt.Printf("length\ttrees\tcumulative\n")
+	sum := 0
+	for _, k := range keys {
+		sum += dist[k]
+		fmt.Printf("%d\t%d\t%.6f\n", k, dist[k], float64(sum)/float64(len(lens)))
+	}
+	return nil
+}
@@ -0,0 +1,314 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package lba implements the p.lba command,
+// i.e. search for long-branch attraction.
+package lba
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/internal/load"
+	"github.com/js-arias/ramita/likelihood"
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/parsimony"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `p.lba [--assumptions <file>] [--exclude <list>]
+		[-f|--factor <value>] [--gapmode <mode>]
+		[-p|--parsimony <treefile>] [--taxa <file>]
+		[-t|--tree <treefile>] [--trim <definition>]
+		[--weights <definition>] [--weights-file <file>] <dataset>`,
+	Short: "search for long-branch attraction",
+	Long: `
+Command p.lba reads a likelihood tree (i.e. a tree with branch lengths
+estimated by maximum likelihood) and a parsimony tree, and searches
+for pairs of long terminal branches that are attracted (i.e. they are
+sister terminals) in the parsimony tree, but separated in the
+likelihood tree.
+
+A terminal branch is considered long if its length in the likelihood
+tree is greater than the average length of the terminal branches
+multiplied by a factor (by default 2), that can be changed with the
+option -f, or --factor.
+
+For each pair of attracted long branches, a taxon-deletion experiment
+is performed: one terminal of the pair is removed, a new parsimony
+search (Wagner-Dayoff) is made, and if the sister group of the other
+terminal changes, the terminal is reported as moved. Attracted pairs
+that are separated in the likelihood tree, or in which a terminal
+moves after the deletion of the other, are reported as suspect clades.
+
+The likelihood tree will be read from the standard input, unless the
+option -t or --tree is defined with a tree file. If the tree does not
+have branch lengths, they will be optimized. If the option -p, or
+--parsimony, is not defined, the parsimony tree will be searched using
+Wagner-Dayoff.
+
+Characters can be weighted with the option --weights, as a list of
+assignments, separated by semicolons, each one with a weight, a colon,
+and a list of characters (the first character is 1), given as
+numbers, or ranges, that can include a step (e.g. "2:1-300\3; 0:301"
+to give weight 2 to the first codon position of the first 300
+characters, and exclude character 301). With the option
+--weights-file, the assignments are read from a file, one assignment
+per line. By default, each character has weight 1.
+
+With the option --taxa, the analysis can be restricted to a subset of
+the terminals of the matrix (e.g. to remove rogue taxa), listed in a
+file, one terminal per line. Lines starting with '#' are ignored.
+
+Characters can be deactivated with the option --exclude, as a list of
+characters (the first character is 1), given as numbers, or ranges
+(e.g. "100-250 300"). Excluded characters are ignored in the analysis,
+but the numbers of the characters are not changed.
+
+Poorly aligned, or noisy, columns can be excluded when the matrix is
+read with the option --trim, as a list of filters, separated by
+semicolons, each one with the name of the filter, an equal sign, and
+its value. With the filter "gaps", columns with a proportion of gaps
+and missing data greater than the value are excluded, and with the
+filter "entropy", columns with a Shannon entropy (in bits) greater than
+the value are excluded (e.g. "gaps=0.5; entropy=1.8"). The number of
+columns removed from each partition is printed (the partitions are
+the partition scheme of a bundle file, see mat.bundle, or the blocks
+of the matrix).
+
+By default, gaps in DNA characters are treated as missing data. With
+the option --gapmode, gaps can be treated as a fifth state, so a
+change between a gap and a nucleotide counts as a step.
+
+Characters can be optimized with a step matrix (i.e. generalized, or
+Sankoff, parsimony) with the option --assumptions, that reads the step
+matrices from a file. Each step matrix is defined by a line with the
+keyword 'stepmatrix' and the name of the matrix, followed by a line
+for each state, with the costs of the transformation from that state
+to each state. A line with the name of a step matrix, an equal sign,
+and a list of characters, assigns the step matrix to the characters.
+Characters without a step matrix are optimized as unordered (Fitch)
+characters.
+
+Options are:
+
+    --assumptions <file>
+      If defined, the step matrices of the characters will be read
+      from the indicated file.
+
+    --exclude <list>
+      If defined, the indicated characters will be excluded from the
+      analysis.
+
+    -f <value>
+    --factor <value>
+      Set the factor used to define a long branch. Default: 2.
+
+    --gapmode <mode>
+      Set the treatment of gaps in DNA characters. Valid values are
+      missing, and state (a fifth state). Default: missing.
+
+    -p <treefile>
+    --parsimony <treefile>
+      If defined, the parsimony tree will be read from the indicated
+      file.
+
+    --taxa <file>
+      If defined, only the terminals listed in the indicated file
+      will be used in the analysis.
+
+    -t <treefile>
+    --tree <treefile>
+      If defined, the likelihood tree will be read from the indicated
+      file, instead of the standard input.
+
+    --trim <definition>
+      If defined, the columns rejected by the indicated filters will
+      be excluded.
+
+    --weights <definition>
+      If defined, the characters will be weighted using the indicated
+      definition.
+
+    --weights-file <file>
+      If defined, the characters will be weighted using the
+      definition in the indicated file.
+
+    <dataset>
+      The phylogenetic data matrix. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var factor float64
+var parsfile string
+var treefile string
+
+var opts load.Options
+
+func register(c *cmdapp.Command) {
+	opts.Register(c)
+	c.Flag.Float64Var(&factor, "factor", 2, "")
+	c.Flag.Float64Var(&factor, "f", 2, "")
+	c.Flag.StringVar(&parsfile, "parsimony", "", "")
+	c.Flag.StringVar(&parsfile, "p", "", "")
+	c.Flag.StringVar(&treefile, "tree", "", "")
+	c.Flag.StringVar(&treefile, "t", "", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a dataset filename", c.Name())
+	}
+
+	m, err := opts.Read(args[0])
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	opts.WriteTrimmed(os.Stdout, m)
+
+	tf := os.Stdin
+	if treefile != "" {
+		tf, err = os.Open(treefile)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), treefile)
+		}
+		defer tf.Close()
+	}
+	ml, err := tree.Read(tf)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing tree", c.Name())
+	}
+	if !ml.Lens {
+		tr, err := likelihood.FromTopology(ml, likelihood.NewFromMatrix(m))
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		tr.Refine()
+		ml = tr.Topology()
+	}
+
+	var pars *tree.Tree
+	if parsfile != "" {
+		pf, err := os.Open(parsfile)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), parsfile)
+		}
+		defer pf.Close()
+		pars, err = tree.Read(pf)
+		if err != nil {
+			return errors.Wrapf(err, "%s: when parsing tree", c.Name())
+		}
+	} else {
+		tr := parsimony.Wagner(m)
+		tr.Dayoff()
+		pars = tr.Topology()
+	}
+
+	// long branches
+	lens := make(map[string]float64)
+	sum := float64(0)
+	for _, n := range ml.Nodes() {
+		if n.IsTerm() {
+			lens[n.Name] = n.Len
+			sum += n.Len
+		}
+	}
+	mean := sum / float64(len(lens))
+	var long []string
+	for nm, l := range lens {
+		if l > mean*factor {
+			long = append(long, nm)
+		}
+	}
+	sort.Strings(long)
+	fmt.Printf("# Mean terminal branch length: %.6f\n", mean)
+	fmt.Printf("# Long branches: %d\n", len(long))
+	for _, nm := range long {
+		fmt.Printf("#\t%s\t%.6f\n", nm, lens[nm])
+	}
+
+	isLong := make(map[string]bool, len(long))
+	for _, nm := range long {
+		isLong[nm] = true
+	}
+	suspects := 0
+	for _, a := range long {
+		sis := pars.Sister(a)
+		if len(sis) != 1 || !isLong[sis[0]] {
+			continue
+		}
+		b := sis[0]
+		if b < a {
+			// each pair is reported once
+			continue
+		}
+		sep := !sameSet(ml.Sister(a), []string{b})
+		movA := moves(pars, m, a, b)
+		movB := moves(pars, m, b, a)
+		if !sep && !movA && !movB {
+			continue
+		}
+		suspects++
+		fmt.Printf("\n# Suspect clade: %s %s\n", a, b)
+		if sep {
+			fmt.Printf("#\tseparated in likelihood tree\n")
+			fmt.Printf("#\tlikelihood sister of %s: %s\n", a, strings.Join(ml.Sister(a), " "))
+			fmt.Printf("#\tlikelihood sister of %s: %s\n", b, strings.Join(ml.Sister(b), " "))
+		}
+		if movA {
+			fmt.Printf("#\t%s moves when %s is removed\n", a, b)
+		}
+		if movB {
+			fmt.Printf("#\t%s moves when %s is removed\n", b, a)
+		}
+	}
+	fmt.Printf("\n# Suspect clades: %d\n", suspects)
+	return nil
+}
+
+// Moves returns true if the sister group of a terminal
+// changes in a parsimony search
+// after the removal of other terminal.
+func moves(pars *tree.Tree, m *matrix.Matrix, term, del string) bool {
+	if len(m.Names) < 4 {
+		return false
+	}
+	ref := pars.Copy()
+	ref.Prune([]string{del})
+
+	tr := parsimony.Wagner(m.DropTaxa([]string{del}))
+	tr.Dayoff()
+	return !sameSet(ref.Sister(term), tr.Topology().Sister(term))
+}
+
+// SameSet returns true
+// if two sorted lists are equal.
+func sameSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
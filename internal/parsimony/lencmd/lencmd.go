@@ -9,31 +9,139 @@ package lencmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/js-arias/biodv/cmdapp"
-	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/internal/load"
 	"github.com/js-arias/ramita/parsimony"
 
 	"github.com/pkg/errors"
 )
 
 var cmd = &cmdapp.Command{
-	UsageLine: "p.len [-t|--tree <treefile>] <dataset>",
-	Short:     "print the length of a tree",
+	UsageLine: `p.len [--assumptions <file>] [--exclude <list>]
+		[--gapmode <mode>] [--prune] [-r|--resolve <method>]
+		[--taxa <file>] [-t|--tree <treefile>] [--trim <definition>]
+		[--weights <definition>] [--weights-file <file>] <dataset>`,
+	Short: "print the length of a tree",
 	Long: `
 Command p.len reads a tree in parenthetical format and prints its
 length under parsimony.
 
+If the input has several trees (in parenthetical format, separated by
+semicolons, or in the trees block of a NEXUS file), the length of each
+tree is printed, one per line, in the order of the input.
+
 The tree will be read from the standard input, unless the option
 -t or --tree is defined with a tree file.
 
+By default, the tree must have all the terminals of the matrix. If
+the option --prune is set, the terminals of the matrix that are not
+in the tree are removed from the matrix (with a warning), for example,
+to score a published tree with a larger matrix.
+
+If the tree has polytomies, they are resolved before calculating the
+length. With the option -r, or --resolve, the resolution method can
+be selected. Valid methods are:
+
+    random  polytomies are resolved at random (the default).
+    best    polytomies are resolved to minimize the length of the
+            tree, i.e. the length of the tree if the polytomies are
+            taken as uncertainty (soft polytomies). As it uses SPR
+            swapping that keeps the clades of the tree, the minimum
+            length is not guaranteed.
+
+With the best method, the tree is rooted at the outgroup, and the
+resolved tree is printed after its length.
+
+Characters can be weighted with the option --weights, as a list of
+assignments, separated by semicolons, each one with a weight, a colon,
+and a list of characters (the first character is 1), given as
+numbers, or ranges, that can include a step (e.g. "2:1-300\3; 0:301"
+to give weight 2 to the first codon position of the first 300
+characters, and exclude character 301). With the option
+--weights-file, the assignments are read from a file, one assignment
+per line. By default, each character has weight 1.
+
+With the option --taxa, the matrix can be restricted to a subset of
+its terminals, listed in a file, one terminal per line. Lines starting
+with '#' are ignored.
+
+Characters can be deactivated with the option --exclude, as a list of
+characters (the first character is 1), given as numbers, or ranges
+(e.g. "100-250 300"). Excluded characters are ignored in the analysis,
+but the numbers of the characters are not changed.
+
+Poorly aligned, or noisy, columns can be excluded when the matrix is
+read with the option --trim, as a list of filters, separated by
+semicolons, each one with the name of the filter, an equal sign, and
+its value. With the filter "gaps", columns with a proportion of gaps
+and missing data greater than the value are excluded, and with the
+filter "entropy", columns with a Shannon entropy (in bits) greater than
+the value are excluded (e.g. "gaps=0.5; entropy=1.8"). The number of
+columns removed from each partition is printed (the partitions are
+the partition scheme of a bundle file, see mat.bundle, or the blocks
+of the matrix).
+
+By default, gaps in DNA characters are treated as missing data. With
+the option --gapmode, gaps can be treated as a fifth state, so a
+change between a gap and a nucleotide counts as a step.
+
+Characters can be optimized with a step matrix (i.e. generalized, or
+Sankoff, parsimony) with the option --assumptions, that reads the step
+matrices from a file. Each step matrix is defined by a line with the
+keyword 'stepmatrix' and the name of the matrix, followed by a line
+for each state, with the costs of the transformation from that state
+to each state. A line with the name of a step matrix, an equal sign,
+and a list of characters, assigns the step matrix to the characters.
+Characters without a step matrix are optimized as unordered (Fitch)
+characters.
+
 Options are:
 
+    --assumptions <file>
+      If defined, the step matrices of the characters will be read
+      from the indicated file.
+
+    --exclude <list>
+      If defined, the indicated characters will be excluded from the
+      analysis.
+
+    --gapmode <mode>
+      Set the treatment of gaps in DNA characters. Valid values are
+      missing, and state (a fifth state). Default: missing.
+
+    --prune
+      If set, the terminals of the matrix that are not in the tree
+      will be removed from the matrix.
+
+    -r <method>
+    --resolve <method>
+      Set the method used to resolve polytomies. Valid values are
+      random and best. Default: random.
+
+    --taxa <file>
+      If defined, only the terminals listed in the indicated file
+      will be used in the analysis.
+
     -t <treefile>
     --tree <treefile>
       If defined, the tree will be read from the indicated file,
       instead of the standard input.
 
+    --trim <definition>
+      If defined, the columns rejected by the indicated filters will
+      be excluded.
+
+    --weights <definition>
+      If defined, the characters will be weighted using the indicated
+      definition.
+
+    --weights-file <file>
+      If defined, the characters will be weighted using the
+      definition in the indicated file.
+
     <dataset>
       The phylogenetic data matrix. It is a required option.
 	`,
@@ -46,27 +154,38 @@ func init() {
 }
 
 var treefile string
+var resolve string
+var prune bool
+
+var opts load.Options
 
 func register(c *cmdapp.Command) {
+	opts.Register(c)
+	c.Flag.StringVar(&resolve, "resolve", "random", "")
+	c.Flag.StringVar(&resolve, "r", "random", "")
 	c.Flag.StringVar(&treefile, "tree", "", "")
 	c.Flag.StringVar(&treefile, "t", "", "")
+	c.Flag.BoolVar(&prune, "prune", false, "")
 }
 
 func run(c *cmdapp.Command, args []string) error {
 	if len(args) != 1 {
 		return errors.Errorf("%s: expecting a dataset filename", c.Name())
 	}
+	resolve = strings.ToLower(resolve)
+	if resolve != "random" && resolve != "best" {
+		return errors.Errorf("%s: unknown resolution method %q", c.Name(), resolve)
+	}
 
-	f, err := os.Open(args[0])
+	m, err := opts.Read(args[0])
 	if err != nil {
-		return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+		return errors.Wrap(err, c.Name())
 	}
-	defer f.Close()
 
-	m, err := matrix.NewMatrix(f)
-	if err != nil {
-		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
 	}
+	opts.WriteTrimmed(os.Stdout, m)
 
 	tf := os.Stdin
 	if treefile != "" {
@@ -77,10 +196,32 @@ func run(c *cmdapp.Command, args []string) error {
 		defer tf.Close()
 	}
 
-	tr, err := parsimony.ReadTree(tf, m)
-	if err != nil {
+	r := parsimony.NewTreeReader(tf, m)
+	r.Prune = prune
+	fmt.Printf("# Tree Length:\n")
+	n := 0
+	for r.Scan() {
+		n++
+		for _, nm := range r.Pruned() {
+			fmt.Printf("# Warning: terminal %s not in tree: removed\n", nm)
+		}
+		if resolve == "random" {
+			fmt.Printf("%d\n", r.Tree().Cost())
+			continue
+		}
+		tr, err := parsimony.ResolveBest(r.Topology(), r.Matrix())
+		if err != nil {
+			return errors.Wrapf(err, "%s: when parsing tree", c.Name())
+		}
+		fmt.Printf("%d\n", tr.Cost())
+		tr.Write(os.Stdout, true)
+		fmt.Printf("\n")
+	}
+	if err := r.Err(); err != nil {
 		return errors.Wrapf(err, "%s: when parsing tree", c.Name())
 	}
-	fmt.Printf("# Tree Length:\n%d\n", tr.Cost())
+	if n == 0 {
+		return errors.Errorf("%s: no tree found", c.Name())
+	}
 	return nil
 }
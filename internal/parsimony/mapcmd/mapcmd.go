@@ -0,0 +1,428 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package mapcmd implements the p.map command,
+// i.e. print the character changes of each branch of a tree.
+package mapcmd
+
+import (
+	"fmt"
+	"math/bits"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/internal/load"
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/parsimony"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `p.map [--assumptions <file>] [-c|--chars <list>] [-d|--dated]
+		[--exclude <list>] [--gapmode <mode>] [-m|--method <method>]
+		[--prune] [--states] [--taxa <file>] [-t|--tree <treefile>]
+		[--trim <definition>] [--weights <definition>]
+		[--weights-file <file>] <dataset>`,
+	Short: "print the character changes of each branch",
+	Long: `
+Command p.map reads a tree in parenthetical format, optimizes the
+characters with parsimony, and prints, for each branch, the characters
+that change in the branch, and the states before and after the change
+(i.e. a list of apomorphies, or synapomorphies, of each node).
+
+First, the tree is printed with the internal nodes labeled with a
+numeric identifier, in pre-order, starting at the root with the number
+of terminals plus one. Then, for each branch with changes, the node at
+the end of the branch (an internal node, or a terminal) is printed,
+followed by the list of changes, one per line, with the character (the
+first character is 1), the state before the change, and the state
+after the change. If the change is not found with the other
+reconstruction method (i.e. acctran or deltran), it is marked as
+ambiguous.
+
+If the option -d, or --dated, is set, the branch lengths of the tree
+are taken as times (e.g. a chronogram made with t.chrono), and the
+changes are printed as a single table, with a row for each change,
+with the node at the end of the branch, the character, the state
+before and after the change, and the time interval of the branch, as
+the age of the start (i.e. the ancestral node), and the age of the end
+of the branch. The ages are measured from the most distant terminal.
+As the position of a change in a branch is unknown, the change is
+assumed to occur at any time in the interval. In this way, the table
+can be used to integrate the changes with biogeographic or other trait
+analyses.
+
+If the option --states is set, the state sets assigned to each node in
+the first pass (down-pass) of the optimization are printed, instead of
+the changes, as a table with a row for each node (in the same order as
+the identifiers), and a column for each character. The state set of
+a node is the set of states that minimize the length of the subtree
+of the node, and it is printed as the list of its states (e.g. "01",
+or "AG"), or as '?' if the state is unknown (i.e. all states are
+possible). As the final states of the nodes are derived from the
+down-pass sets, the table is useful to follow, or to teach, the
+parsimony optimization of a character. It is better to use it with a
+few characters (see the option -c).
+
+By default, ambiguous reconstructions are resolved using accelerated
+transformation (acctran). With the -m, or --method option, other
+methods can be used:
+
+    acctran  accelerated transformation (changes are placed as close
+             to the root as possible).
+    deltran  delayed transformation (changes are placed as far as
+             possible from the root).
+
+The tree will be read from the standard input, unless the option -t
+or --tree is defined with a tree file.
+
+By default, the tree must have all the terminals of the matrix. If
+the option --prune is set, the terminals of the matrix that are not
+in the tree are removed from the matrix (with a warning).
+
+The data is read as in p.wagday, so the tree length is the same as
+in the search. Characters can be weighted with the option --weights,
+as a list of assignments, separated by semicolons, each one with a
+weight, a colon, and a list of characters (e.g. "2:1-300\3; 0:301"),
+or with the option --weights-file, that reads the assignments from a
+file, one assignment per line. Characters can be deactivated with the
+option --exclude, as a list of characters (e.g. "100-250 300").
+Excluded characters are not reported. With the option --taxa, the
+matrix can be restricted to the terminals listed in a file, one
+terminal per line.
+
+Poorly aligned, or noisy, columns can be excluded when the matrix is
+read with the option --trim, as a list of filters, separated by
+semicolons, each one with the name of the filter, an equal sign, and
+its value. With the filter "gaps", columns with a proportion of gaps
+and missing data greater than the value are excluded, and with the
+filter "entropy", columns with a Shannon entropy (in bits) greater than
+the value are excluded (e.g. "gaps=0.5; entropy=1.8"). The number of
+columns removed from each partition is printed (the partitions are
+the partition scheme of a bundle file, see mat.bundle, or the blocks
+of the matrix).
+
+By default, gaps in DNA characters are treated as missing data. With
+the option --gapmode, gaps can be treated as a fifth state, so a
+change between a gap and a nucleotide counts as a step.
+
+Characters can be optimized with a step matrix (i.e. generalized, or
+Sankoff, parsimony) with the option --assumptions, that reads the step
+matrices from a file, as in p.wagday. Characters without a step matrix
+are optimized as unordered (Fitch) characters.
+
+Options are:
+
+    --assumptions <file>
+      If defined, the step matrices of the characters will be read
+      from the indicated file.
+
+    -c <list>
+    --chars <list>
+      If set, only the indicated characters will be reported. The
+      characters can be given as a list of numbers, or ranges (e.g.
+      "1-5 8"). The first character is 1.
+
+    -d
+    --dated
+      If set, the branch lengths of the tree are taken as times, and
+      the changes are printed as a table with the time interval of
+      each change.
+
+    --exclude <list>
+      If defined, the indicated characters will be excluded from the
+      analysis.
+
+    --gapmode <mode>
+      Set the treatment of gaps in DNA characters. Valid values are
+      missing, and state (a fifth state). Default: missing.
+
+    -m <method>
+    --method <method>
+      Set the reconstruction method. Valid values are acctran and
+      deltran. Default: acctran.
+
+    --prune
+      If set, the terminals of the matrix that are not in the tree
+      will be removed from the matrix.
+
+    --states
+      If set, the down-pass state sets of each node are printed,
+      instead of the changes.
+
+    --taxa <file>
+      If defined, only the terminals listed in the indicated file
+      will be used in the analysis.
+
+    -t <treefile>
+    --tree <treefile>
+      If defined, the tree will be read from the indicated file,
+      instead of the standard input.
+
+    --trim <definition>
+      If defined, the columns rejected by the indicated filters will
+      be excluded.
+
+    --weights <definition>
+      If defined, the characters will be weighted using the indicated
+      definition.
+
+    --weights-file <file>
+      If defined, the characters will be weighted using the
+      definition in the indicated file.
+
+    <dataset>
+      The phylogenetic data matrix. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var chars string
+var dated bool
+var method string
+var prune bool
+var states bool
+var treefile string
+
+var opts load.Options
+
+func register(c *cmdapp.Command) {
+	c.Flag.StringVar(&chars, "chars", "", "")
+	c.Flag.StringVar(&chars, "c", "", "")
+	c.Flag.BoolVar(&dated, "dated", false, "")
+	c.Flag.BoolVar(&dated, "d", false, "")
+	c.Flag.StringVar(&method, "method", "acctran", "")
+	c.Flag.StringVar(&method, "m", "acctran", "")
+	c.Flag.BoolVar(&prune, "prune", false, "")
+	c.Flag.BoolVar(&states, "states", false, "")
+	c.Flag.StringVar(&treefile, "tree", "", "")
+	c.Flag.StringVar(&treefile, "t", "", "")
+	opts.Register(c)
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a dataset filename", c.Name())
+	}
+	pm, err := parsimony.ParseMethod(method)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	other := parsimony.Deltran
+	if pm == parsimony.Deltran {
+		other = parsimony.Acctran
+	}
+
+	m, err := opts.Read(args[0])
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	sel := make(map[int]bool, len(m.Kind))
+	if chars != "" {
+		ls, err := matrix.ParseRange(chars, len(m.Kind))
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		for _, ch := range ls {
+			sel[ch] = true
+		}
+	} else {
+		for ch := range m.Kind {
+			sel[ch] = true
+		}
+	}
+	// excluded characters are not reported
+	for _, ch := range m.Excluded() {
+		delete(sel, ch)
+	}
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	opts.WriteTrimmed(os.Stdout, m)
+
+	tf := os.Stdin
+	if treefile != "" {
+		tf, err = os.Open(treefile)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), treefile)
+		}
+		defer tf.Close()
+	}
+
+	tt, err := tree.Read(tf)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing tree", c.Name())
+	}
+	if dated && !tt.Lens {
+		return errors.Errorf("%s: tree without branch lengths", c.Name())
+	}
+	if prune {
+		del := m.Outside(tt.Terms())
+		for _, nm := range del {
+			fmt.Printf("# Warning: terminal %s not in tree: removed\n", nm)
+		}
+		if len(del) > 0 {
+			m = m.DropTaxa(del)
+		}
+	}
+	tr, err := parsimony.FromTopology(tt, m)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing tree", c.Name())
+	}
+
+	// pre-order identifiers of the nodes
+	ids := make(map[*parsimony.Node]string, len(tr.Nodes))
+	var nodes []*parsimony.Node
+	next := len(m.Names) + 1
+	var setID func(n *parsimony.Node)
+	setID = func(n *parsimony.Node) {
+		nodes = append(nodes, n)
+		if n.Term != nil {
+			ids[n] = n.Term.Name
+			return
+		}
+		ids[n] = strconv.Itoa(next)
+		next++
+		setID(n.Left)
+		setID(n.Right)
+	}
+	setID(tr.Root)
+
+	fmt.Printf("# Tree Length: %d\n", tr.Cost())
+	fmt.Printf("# Method: %s\n", pm)
+	tp := tr.Topology()
+	setLabels(tp.Root, tr.Root, ids)
+	tp.Write(os.Stdout, true)
+	fmt.Printf("\n")
+
+	if states {
+		var ls []int
+		fmt.Printf("node")
+		for ch := range m.Kind {
+			if !sel[ch] {
+				continue
+			}
+			ls = append(ls, ch)
+			fmt.Printf("\t%d", ch+1)
+		}
+		fmt.Printf("\n")
+		for _, n := range nodes {
+			fmt.Printf("%s", ids[n])
+			st := n.States()
+			for _, ch := range ls {
+				fmt.Printf("\t%s", stateSet(m.Kind[ch], st[ch]))
+			}
+			fmt.Printf("\n")
+		}
+		return nil
+	}
+
+	chs := tr.Changes(pm)
+	alt := tr.Changes(other)
+	if dated {
+		ages := make(map[*parsimony.Node]float64, len(tr.Nodes))
+		setAges(tt.Root, tr.Root, tt.NodeAges(), ages)
+		fmt.Printf("# node\tchar\tfrom\tto\tstart\tend\n")
+		for _, n := range nodes {
+			for _, ch := range chs[n] {
+				if !sel[ch.Char] {
+					continue
+				}
+				k := m.Kind[ch.Char]
+				fmt.Printf("%s\t%d\t%s\t%s\t%.6f\t%.6f", ids[n], ch.Char+1, k.Symbol(bits.TrailingZeros32(ch.From)), k.Symbol(bits.TrailingZeros32(ch.To)), ages[n.Anc], ages[n])
+				if !hasChange(alt[n], ch) {
+					fmt.Printf("\tambiguous")
+				}
+				fmt.Printf("\n")
+			}
+		}
+		return nil
+	}
+
+	for _, n := range nodes {
+		var ls []string
+		for _, ch := range chs[n] {
+			if !sel[ch.Char] {
+				continue
+			}
+			k := m.Kind[ch.Char]
+			ln := fmt.Sprintf("%d\t%s\t%s", ch.Char+1, k.Symbol(bits.TrailingZeros32(ch.From)), k.Symbol(bits.TrailingZeros32(ch.To)))
+			if !hasChange(alt[n], ch) {
+				ln += "\tambiguous"
+			}
+			ls = append(ls, ln)
+		}
+		if len(ls) == 0 {
+			continue
+		}
+		if n.Term != nil {
+			fmt.Printf("\n# Terminal %s: %d changes\n", ids[n], len(ls))
+		} else {
+			fmt.Printf("\n# Node %s: %d changes\n", ids[n], len(ls))
+		}
+		fmt.Printf("# char\tfrom\tto\n")
+		fmt.Printf("%s\n", strings.Join(ls, "\n"))
+	}
+	return nil
+}
+
+// StateSet returns the symbols
+// of the states in a state set.
+func stateSet(k matrix.DataType, st uint32) string {
+	if st == matrix.Unknown(k) || (k == matrix.DNA && st == matrix.Unknown(k)|matrix.Gap) {
+		return "?"
+	}
+	var ss []string
+	for s := 0; s < 32; s++ {
+		if st&(1<<uint(s)) != 0 {
+			ss = append(ss, k.Symbol(s))
+		}
+	}
+	return strings.Join(ss, "")
+}
+
+// SetLabels sets the identifiers of the internal nodes
+// as labels of a topology.
+func setLabels(tn *tree.Node, n *parsimony.Node, ids map[*parsimony.Node]string) {
+	if n.Term != nil {
+		return
+	}
+	tn.Label = ids[n]
+	setLabels(tn.Desc[0], n.Left, ids)
+	setLabels(tn.Desc[1], n.Right, ids)
+}
+
+// SetAges sets the age of each node of a tree
+// from the ages of the nodes of its topology.
+func setAges(tn *tree.Node, n *parsimony.Node, tAges map[*tree.Node]float64, ages map[*parsimony.Node]float64) {
+	ages[n] = tAges[tn]
+	if n.Term != nil {
+		return
+	}
+	setAges(tn.Desc[0], n.Left, tAges, ages)
+	setAges(tn.Desc[1], n.Right, tAges, ages)
+}
+
+// HasChange returns true
+// if a change is in a list of changes.
+func hasChange(ls []parsimony.Change, ch parsimony.Change) bool {
+	for _, c := range ls {
+		if c == ch {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,235 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package ptp implements the p.ptp command,
+// i.e. a permutation tail probability test.
+package ptp
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/internal/load"
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/parsimony"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `p.ptp [-a|--additions <number>] [--assumptions <file>]
+		[--exclude <list>] [--gapmode <mode>] [-l|--lengths]
+		[-r|--replicates <number>] [--swap <name>] [--taxa <file>]
+		[--trim <definition>] [--weights <definition>]
+		[--weights-file <file>] [<dataset>]`,
+	Short: "permutation tail probability test",
+	Long: `
+Command p.ptp performs a permutation tail probability (PTP) test
+(Archie 1989, Syst. Zool. 38: 239; Faith & Cranston 1991, Cladistics
+7: 1), to test if a matrix has a significant phylogenetic structure.
+
+In each replicate, the states of each character are permuted among
+the terminals, destroying any phylogenetic structure (but keeping the
+number of terminals with each state), and a Wagner-Dayoff search is
+made on the permuted matrix. The length of the tree of the original
+matrix is compared with the lengths of the trees of the permuted
+matrices. The tail probability is the proportion of matrices
+(including the original matrix) with a tree as short, or shorter,
+than the tree of the original matrix. A small probability (e.g. 0.05
+or less) indicates that the matrix has a significant structure.
+
+By default, 100 replicates are made, and the number of replicates can
+be changed with the option -r, or --replicates. In each search a
+single Wagner-Dayoff tree is made, and with the option -a, or
+--additions, the number of Wagner-Dayoff trees made in each search
+can be changed (the shortest tree is used). By default, the Wagner
+trees are improved with SPR branch swapping, and the swapping algorithm
+can be changed with the option --swap (nni, spr, or tbr).
+
+If the option -l, or --lengths, is set, the length of the tree of
+each replicate will be printed.
+
+With the option --taxa, the analysis can be restricted to a subset of
+the terminals of the matrix (e.g. to remove rogue taxa), listed in a
+file, one terminal per line. Lines starting with '#' are ignored.
+
+Characters can be deactivated with the option --exclude, as a list of
+characters (the first character is 1), given as numbers, or ranges
+(e.g. "100-250 300"). Excluded characters are ignored in the analysis,
+but the numbers of the characters are not changed.
+
+Poorly aligned, or noisy, columns can be excluded when the matrix is
+read with the option --trim, as a list of filters, separated by
+semicolons, each one with the name of the filter, an equal sign, and
+its value. With the filter "gaps", columns with a proportion of gaps
+and missing data greater than the value are excluded, and with the
+filter "entropy", columns with a Shannon entropy (in bits) greater than
+the value are excluded (e.g. "gaps=0.5; entropy=1.8"). The number of
+columns removed from each partition is printed (the partitions are
+the partition scheme of a bundle file, see mat.bundle, or the blocks
+of the matrix).
+
+By default, gaps in DNA characters are treated as missing data. With
+the option --gapmode, gaps can be treated as a fifth state, so a
+change between a gap and a nucleotide counts as a step.
+
+Characters can be optimized with a step matrix (i.e. generalized, or
+Sankoff, parsimony) with the option --assumptions, that reads the step
+matrices from a file (see p.wagday). Characters without a step matrix
+are optimized as unordered (Fitch) characters.
+
+Options are:
+
+    -a <number>
+    --additions <number>
+      Set the number of Wagner-Dayoff trees made in each search.
+      Default: 1.
+
+    --assumptions <file>
+      If defined, the step matrices of the characters will be read
+      from the indicated file.
+
+    --exclude <list>
+      If defined, the indicated characters will be excluded from the
+      analysis.
+
+    --gapmode <mode>
+      Set the treatment of gaps in DNA characters. Valid values are
+      missing, and state (a fifth state). Default: missing.
+
+    -l
+    --lengths
+      If set, the length of each replicate will be printed.
+
+    -r <number>
+    --replicates <number>
+      Set the number of replicates. Default: 100.
+
+    --swap <name>
+      Set the branch swapping algorithm, either nni, spr, or tbr.
+      Default: spr.
+
+    --taxa <file>
+      If defined, only the terminals listed in the indicated file
+      will be used in the analysis.
+
+    --trim <definition>
+      If defined, the columns rejected by the indicated filters will
+      be excluded.
+
+    --weights <definition>
+      If defined, the characters will be weighted using the indicated
+      definition (see p.bandb).
+
+    --weights-file <file>
+      If defined, the characters will be weighted using the
+      definition in the indicated file.
+
+    <dataset>
+      The phylogenetic data matrix. If not given explicitly, it will
+      be read from the standard input.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var additions int
+var lengths bool
+var reps int
+var swap string
+
+// sw is the parsed swapping algorithm.
+var sw parsimony.Swap
+
+var opts load.Options
+
+func register(c *cmdapp.Command) {
+	c.Flag.IntVar(&additions, "additions", 1, "")
+	c.Flag.IntVar(&additions, "a", 1, "")
+	opts.Register(c)
+	c.Flag.BoolVar(&lengths, "lengths", false, "")
+	c.Flag.BoolVar(&lengths, "l", false, "")
+	c.Flag.IntVar(&reps, "replicates", 100, "")
+	c.Flag.IntVar(&reps, "r", 100, "")
+	c.Flag.StringVar(&swap, "swap", "spr", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("%s: too many arguments", c.Name())
+	}
+	if reps < 1 {
+		return errors.Errorf("%s: invalid number of replicates: %d", c.Name(), reps)
+	}
+	if additions < 1 {
+		return errors.Errorf("%s: invalid number of additions: %d", c.Name(), additions)
+	}
+	var err error
+	sw, err = parsimony.ParseSwap(swap)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	name := ""
+	if len(args) == 1 {
+		name = args[0]
+	}
+	m, err := opts.Read(name)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if len(m.Names) < 4 {
+		return errors.Errorf("%s: matrix with %d terminals, at least 4 are required", c.Name(), len(m.Names))
+	}
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	opts.WriteTrimmed(os.Stdout, m)
+	p := parsimony.PermutationTest(m, reps, search)
+
+	min, max, mean := p.Lengths[0], p.Lengths[0], 0.0
+	for _, l := range p.Lengths {
+		if l < min {
+			min = l
+		}
+		if l > max {
+			max = l
+		}
+		mean += float64(l)
+	}
+	mean /= float64(len(p.Lengths))
+
+	fmt.Printf("# Replicates: %d\n", reps)
+	fmt.Printf("# Length: %d\n", p.Length)
+	fmt.Printf("# Permuted lengths: min %d, max %d, mean %.4f\n", min, max, mean)
+	fmt.Printf("# PTP: %.6f\n", p.Prob())
+	if lengths {
+		fmt.Printf("replicate\tlength\n")
+		for i, l := range p.Lengths {
+			fmt.Printf("%d\t%d\n", i+1, l)
+		}
+	}
+	return nil
+}
+
+// Search returns the shortest tree
+// of a set of Wagner-Dayoff trees.
+func search(m *matrix.Matrix) *parsimony.Tree {
+	var best *parsimony.Tree
+	for i := 0; i < additions; i++ {
+		tr := parsimony.Wagner(m)
+		tr.SwapLimit(sw, nil, nil)
+		if best == nil || tr.Cost() < best.Cost() {
+			best = tr
+		}
+	}
+	return best
+}
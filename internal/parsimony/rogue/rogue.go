@@ -0,0 +1,372 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package rogue implements the p.rogue command,
+// i.e. search after the removal of rogue terminals.
+package rogue
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/consensus"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/internal/load"
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/parsimony"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `p.rogue [--assumptions <file>] [--exclude <list>]
+		[--gapmode <mode>] [-m|--max <number>]
+		[--max-rearrangements <number>] [--max-time <duration>]
+		[-o|--output <file>] [-r|--replicates <number>]
+		[--swap <name>] [--taxa <file>] [--trim <definition>]
+		[--weights <definition>] [--weights-file <file>] [<dataset>]`,
+	Short: "search after the removal of rogue terminals",
+	Long: `
+Command p.rogue makes a parsimony search, detects the rogue terminals
+(i.e. terminals with unstable positions), removes them, and repeats
+the search without the rogue terminals.
+
+The search consists of a number of Wagner-Dayoff replicates, and the
+shortest trees found are kept. The swapping algorithm of the
+replicates can be set with the option --swap (nni, spr, or tbr; by
+default, spr). Rogue terminals are detected in a
+greedy fashion: at each step, the terminal whose removal produces the
+best improvement in the resolution of the majority rule consensus of
+the shortest trees is removed, until no removal improves the
+resolution, or the maximum number of rogue terminals is reached. The
+resolution is the number of clades of the consensus, divided by the
+number of clades of a fully resolved tree. As the majority rule
+consensus can be fully resolved even with rogue terminals, the
+resolution is weighted by the frequency of each clade of the
+consensus.
+
+The output includes the list of rogue terminals, the resolution of the
+consensus of the initial search, and the resolution of the consensus of
+the search without the rogue terminals (both unweighted and weighted
+by the frequency of the clades). The consensus of the final
+search is printed in the standard output.
+
+Each search can be bounded with the options --max-time, that sets the
+maximum running time of the search (e.g. 30s, 10m, 1h30m), and
+--max-rearrangements, that sets the maximum number of rearrangements
+evaluated during the search. If a limit is reached, the search is
+stopped, the trees found so far are used, and a warning is printed.
+
+If the option -o, or --output, is defined, the tree of each replicate
+of both searches will be written in the indicated file (in NEXUS
+format), as soon as the replicate is completed, with its length as a
+comment. Trees of the initial search are named "initial<n>", and
+trees of the final search "final<n>".
+
+Characters can be weighted with the option --weights, as a list of
+assignments, separated by semicolons, each one with a weight, a colon,
+and a list of characters (the first character is 1), given as
+numbers, or ranges, that can include a step (e.g. "2:1-300\3; 0:301"
+to give weight 2 to the first codon position of the first 300
+characters, and exclude character 301). With the option
+--weights-file, the assignments are read from a file, one assignment
+per line. By default, each character has weight 1.
+
+With the option --taxa, the analysis can be restricted to a subset of
+the terminals of the matrix (e.g. to remove rogue taxa), listed in a
+file, one terminal per line. Lines starting with '#' are ignored.
+
+Characters can be deactivated with the option --exclude, as a list of
+characters (the first character is 1), given as numbers, or ranges
+(e.g. "100-250 300"). Excluded characters are ignored in the analysis,
+but the numbers of the characters are not changed.
+
+Poorly aligned, or noisy, columns can be excluded when the matrix is
+read with the option --trim, as a list of filters, separated by
+semicolons, each one with the name of the filter, an equal sign, and
+its value. With the filter "gaps", columns with a proportion of gaps
+and missing data greater than the value are excluded, and with the
+filter "entropy", columns with a Shannon entropy (in bits) greater than
+the value are excluded (e.g. "gaps=0.5; entropy=1.8"). The number of
+columns removed from each partition is printed (the partitions are
+the partition scheme of a bundle file, see mat.bundle, or the blocks
+of the matrix).
+
+By default, gaps in DNA characters are treated as missing data. With
+the option --gapmode, gaps can be treated as a fifth state, so a
+change between a gap and a nucleotide counts as a step.
+
+Characters can be optimized with a step matrix (i.e. generalized, or
+Sankoff, parsimony) with the option --assumptions, that reads the step
+matrices from a file. Each step matrix is defined by a line with the
+keyword 'stepmatrix' and the name of the matrix, followed by a line
+for each state, with the costs of the transformation from that state
+to each state. A line with the name of a step matrix, an equal sign,
+and a list of characters, assigns the step matrix to the characters.
+Characters without a step matrix are optimized as unordered (Fitch)
+characters.
+
+Options are:
+
+    --assumptions <file>
+      If defined, the step matrices of the characters will be read
+      from the indicated file.
+
+    --exclude <list>
+      If defined, the indicated characters will be excluded from the
+      analysis.
+
+    --gapmode <mode>
+      Set the treatment of gaps in DNA characters. Valid values are
+      missing, and state (a fifth state). Default: missing.
+
+    -m <number>
+    --max <number>
+      Set the maximum number of rogue terminals to be removed.
+      Default: 5.
+
+    --max-rearrangements <number>
+      If defined, each search will be stopped after the indicated
+      number of rearrangements.
+
+    --max-time <duration>
+      If defined, each search will be stopped after the indicated
+      time.
+
+    -o <file>
+    --output <file>
+      If defined, the tree of each replicate will be written in the
+      indicated file.
+
+    -r <number>
+    --replicates <number>
+      Set the number of replicates of each search. Default: 100.
+
+    --swap <name>
+      Set the branch swapping algorithm, either nni, spr, or tbr.
+      Default: spr.
+
+    --taxa <file>
+      If defined, only the terminals listed in the indicated file
+      will be used in the analysis.
+
+    --trim <definition>
+      If defined, the columns rejected by the indicated filters will
+      be excluded.
+
+    --weights <definition>
+      If defined, the characters will be weighted using the indicated
+      definition.
+
+    --weights-file <file>
+      If defined, the characters will be weighted using the
+      definition in the indicated file.
+
+    <dataset>
+      The phylogenetic data matrix. If not given explicitly, it will
+      be read from the standard input.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var max int
+var maxRearr int
+var maxTime time.Duration
+var output string
+var reps int
+var swap string
+
+// swapping algorithm
+var sw parsimony.Swap
+
+var opts load.Options
+
+func register(c *cmdapp.Command) {
+	opts.Register(c)
+	c.Flag.IntVar(&max, "max", 5, "")
+	c.Flag.IntVar(&max, "m", 5, "")
+	c.Flag.IntVar(&maxRearr, "max-rearrangements", 0, "")
+	c.Flag.DurationVar(&maxTime, "max-time", 0, "")
+	c.Flag.StringVar(&output, "output", "", "")
+	c.Flag.StringVar(&output, "o", "", "")
+	c.Flag.IntVar(&reps, "replicates", 100, "")
+	c.Flag.IntVar(&reps, "r", 100, "")
+	c.Flag.StringVar(&swap, "swap", "spr", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("%s: too many arguments", c.Name())
+	}
+	if reps < 1 {
+		return errors.Errorf("%s: invalid number of replicates: %d", c.Name(), reps)
+	}
+	var err error
+	sw, err = parsimony.ParseSwap(swap)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	name := ""
+	if len(args) == 1 {
+		name = args[0]
+	}
+	m, err := opts.Read(name)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	opts.WriteTrimmed(os.Stdout, m)
+
+	var tw *tree.Writer
+	if output != "" {
+		tw, err = tree.Create(output, names(m))
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		if err := tw.Comment(env.String()); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		defer tw.Close()
+	}
+
+	trees, length, err := search(m, tw, "initial")
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	mj, wr, err := majority(trees)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	fmt.Printf("# Initial search: %d trees of length %d\n", len(trees), length)
+	fmt.Printf("# Initial resolution: %.4f\tweighted: %.4f\n", consensus.Resolution(mj), wr)
+
+	rogues, err := consensus.Rogues(trees, max)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if len(rogues) == 0 {
+		fmt.Printf("# No rogue terminals found\n")
+		mj.Write(os.Stdout, true)
+		fmt.Printf("\n")
+		return closeWriter(tw, c.Name())
+	}
+	fmt.Printf("# Rogue terminals: %s\n", strings.Join(rogues, " "))
+
+	trees, length, err = search(m.DropTaxa(rogues), tw, "final")
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	mj, wr, err = majority(trees)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	fmt.Printf("# Final search: %d trees of length %d\n", len(trees), length)
+	fmt.Printf("# Final resolution: %.4f\tweighted: %.4f\n", consensus.Resolution(mj), wr)
+	mj.Write(os.Stdout, true)
+	fmt.Printf("\n")
+	return closeWriter(tw, c.Name())
+}
+
+// Search returns the different shortest trees
+// found in a set of Wagner-Dayoff replicates,
+// and its length.
+// If tw is not nil,
+// the tree of each replicate is written on it.
+// If a search limit is defined,
+// the search stops when the limit is reached.
+// The search is made on the site patterns of the matrix.
+func search(m *matrix.Matrix, tw *tree.Writer, prefix string) ([]*tree.Tree, int, error) {
+	m, _ = m.Compress(nil)
+	var lim *parsimony.Limit
+	if maxTime > 0 || maxRearr > 0 {
+		lim = parsimony.NewLimit(maxTime, maxRearr)
+	}
+
+	var trees []*tree.Tree
+	var found tree.Unique
+	best := -1
+	for i := 0; i < reps; i++ {
+		if lim.Done() {
+			fmt.Printf("# Warning: %s search stopped after %d replicates: limit reached\n", prefix, i)
+			break
+		}
+		tr := parsimony.Wagner(m)
+		tr.SwapLimit(sw, nil, lim)
+		if tw != nil {
+			tp := tr.Topology()
+			tp.Name = fmt.Sprintf("%s%d", prefix, i+1)
+			tp.Root.Comment = fmt.Sprintf("length=%d", tr.Cost())
+			if err := tw.Write(tp); err != nil {
+				return nil, 0, err
+			}
+		}
+		if best >= 0 && tr.Cost() > best {
+			continue
+		}
+		if tr.Cost() < best || best < 0 {
+			best = tr.Cost()
+			trees = nil
+			found = tree.Unique{}
+		}
+		tp := tr.Topology()
+		if !found.Add(tp) {
+			continue
+		}
+		trees = append(trees, tp)
+	}
+	return trees, best, nil
+}
+
+// Majority returns the majority rule consensus
+// of a tree collection,
+// and its weighted resolution.
+func majority(trees []*tree.Tree) (*tree.Tree, float64, error) {
+	s := &consensus.Set{}
+	for _, t := range trees {
+		if err := s.Add(t); err != nil {
+			return nil, 0, err
+		}
+	}
+	mj, err := s.Majority(0.5)
+	if err != nil {
+		return nil, 0, err
+	}
+	mj.Lens = false
+	return mj, s.WeightedResolution(), nil
+}
+
+// Names returns the names of the terminals
+// of a matrix.
+func names(m *matrix.Matrix) []string {
+	ls := make([]string, 0, len(m.Names))
+	for nm := range m.Names {
+		ls = append(ls, nm)
+	}
+	return ls
+}
+
+// CloseWriter closes a tree writer,
+// if it is defined.
+func closeWriter(tw *tree.Writer, cmd string) error {
+	if tw == nil {
+		return nil
+	}
+	if err := tw.Close(); err != nil {
+		return errors.Wrap(err, cmd)
+	}
+	return nil
+}
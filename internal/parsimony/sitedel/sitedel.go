@@ -0,0 +1,359 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package sitedel implements the p.sitedel command,
+// i.e. a site removal sensitivity analysis.
+package sitedel
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/internal/load"
+	"github.com/js-arias/ramita/internal/wdsearch"
+	"github.com/js-arias/ramita/likelihood"
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/parsimony"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `p.sitedel [--assumptions <file>] [--exclude <list>]
+		[--gapmode <mode>] [-l|--likelihood <treefile>]
+		[-n|--steps <number>] [-o|--output <file>]
+		[-p|--proportion <value>] [-r|--replicates <number>]
+		[--swap <name>] [--taxa <file>] [--trim <definition>]
+		[--weights <definition>] [--weights-file <file>] <dataset>`,
+	Short: "site removal sensitivity analysis",
+	Long: `
+Command p.sitedel performs a site removal analysis: the characters are
+sorted by their rate of evolution, and the fastest evolving characters
+are progressively removed, repeating the parsimony search at each step.
+Topological changes associated with the removal of fast characters
+are commonly taken as an indication of systematic error (e.g. long
+branch attraction caused by saturated characters).
+
+By default the rate of a character is its number of steps in the most
+parsimonious tree found with all the characters. If the option -l, or
+--likelihood, is defined with a tree file, the rate of each character
+will be its maximum likelihood relative rate on that tree. If the tree
+does not have branch lengths, they will be optimized.
+
+At each step a proportion of the characters (by default 0.1) is
+removed, that can be changed with the option -p, or --proportion. The
+number of steps (by default 5) can be changed with the option -n, or
+--steps.
+
+The output is the tree found with all the characters, and for each
+step, the number of removed characters, the lowest rate of the removed
+characters, the length of the tree, the number of clades of the
+original tree that are lost, the number of new clades, and the tree.
+Finally, for each clade of the original tree, the first step in which
+it is lost is printed.
+
+Each search is a Wagner-Dayoff search, and the number of replicates of
+each search can be set with the option -r, or --replicates. The
+shortest tree of each search is used. By default, the Wagner trees are
+improved with SPR branch swapping, and the swapping algorithm can be
+changed with the option --swap (nni, spr, or tbr).
+
+If the option -o, or --output, is defined, the tree of each step will
+be written in the indicated file (in NEXUS format), as soon as the
+search is completed, with its length as a comment. The tree with all
+the characters is named "all", and the tree of each step is named
+"step<n>".
+
+Characters can be weighted with the option --weights, as a list of
+assignments, separated by semicolons, each one with a weight, a colon,
+and a list of characters (the first character is 1), given as
+numbers, or ranges, that can include a step (e.g. "2:1-300\3; 0:301"
+to give weight 2 to the first codon position of the first 300
+characters, and exclude character 301). With the option
+--weights-file, the assignments are read from a file, one assignment
+per line. By default, each character has weight 1.
+
+With the option --taxa, the analysis can be restricted to a subset of
+the terminals of the matrix (e.g. to remove rogue taxa), listed in a
+file, one terminal per line. Lines starting with '#' are ignored.
+
+Characters can be deactivated with the option --exclude, as a list of
+characters (the first character is 1), given as numbers, or ranges
+(e.g. "100-250 300"). Excluded characters are ignored in the analysis,
+but the numbers of the characters are not changed.
+
+Poorly aligned, or noisy, columns can be excluded when the matrix is
+read with the option --trim, as a list of filters, separated by
+semicolons, each one with the name of the filter, an equal sign, and
+its value. With the filter "gaps", columns with a proportion of gaps
+and missing data greater than the value are excluded, and with the
+filter "entropy", columns with a Shannon entropy (in bits) greater than
+the value are excluded (e.g. "gaps=0.5; entropy=1.8"). The number of
+columns removed from each partition is printed (the partitions are
+the partition scheme of a bundle file, see mat.bundle, or the blocks
+of the matrix).
+
+By default, gaps in DNA characters are treated as missing data. With
+the option --gapmode, gaps can be treated as a fifth state, so a
+change between a gap and a nucleotide counts as a step.
+
+Characters can be optimized with a step matrix (i.e. generalized, or
+Sankoff, parsimony) with the option --assumptions, that reads the step
+matrices from a file. Each step matrix is defined by a line with the
+keyword 'stepmatrix' and the name of the matrix, followed by a line
+for each state, with the costs of the transformation from that state
+to each state. A line with the name of a step matrix, an equal sign,
+and a list of characters, assigns the step matrix to the characters.
+Characters without a step matrix are optimized as unordered (Fitch)
+characters.
+
+Options are:
+
+    --assumptions <file>
+      If defined, the step matrices of the characters will be read
+      from the indicated file.
+
+    --exclude <list>
+      If defined, the indicated characters will be excluded from the
+      analysis.
+
+    --gapmode <mode>
+      Set the treatment of gaps in DNA characters. Valid values are
+      missing, and state (a fifth state). Default: missing.
+
+    -l <treefile>
+    --likelihood <treefile>
+      If defined, the rate of the characters will be estimated by
+      maximum likelihood using the tree in the indicated file.
+
+    -o <file>
+    --output <file>
+      If defined, the tree of each step will be written in the
+      indicated file.
+
+    -p <value>
+    --proportion <value>
+      Set the proportion of characters removed at each step.
+      Default: 0.1.
+
+    -r <number>
+    --replicates <number>
+      Set the number of replicates of each search. Default: 10.
+
+    -n <number>
+    --steps <number>
+      Set the number of removal steps. Default: 5.
+
+    --swap <name>
+      Set the branch swapping algorithm, either nni, spr, or tbr.
+      Default: spr.
+
+    --taxa <file>
+      If defined, only the terminals listed in the indicated file
+      will be used in the analysis.
+
+    --trim <definition>
+      If defined, the columns rejected by the indicated filters will
+      be excluded.
+
+    --weights <definition>
+      If defined, the characters will be weighted using the indicated
+      definition.
+
+    --weights-file <file>
+      If defined, the characters will be weighted using the
+      definition in the indicated file.
+
+    <dataset>
+      The phylogenetic data matrix. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var mlfile string
+var output string
+var steps int
+var prop float64
+
+var wd wdsearch.Options
+
+var opts load.Options
+
+func register(c *cmdapp.Command) {
+	wd.Register(c)
+	opts.Register(c)
+	c.Flag.StringVar(&mlfile, "likelihood", "", "")
+	c.Flag.StringVar(&mlfile, "l", "", "")
+	c.Flag.IntVar(&steps, "steps", 5, "")
+	c.Flag.IntVar(&steps, "n", 5, "")
+	c.Flag.StringVar(&output, "output", "", "")
+	c.Flag.StringVar(&output, "o", "", "")
+	c.Flag.Float64Var(&prop, "proportion", 0.1, "")
+	c.Flag.Float64Var(&prop, "p", 0.1, "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a dataset filename", c.Name())
+	}
+	if err := wd.Check(); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if steps < 1 {
+		return errors.Errorf("%s: invalid number of steps: %d", c.Name(), steps)
+	}
+	if prop <= 0 || prop*float64(steps) >= 1 {
+		return errors.Errorf("%s: invalid proportion: %.4f", c.Name(), prop)
+	}
+
+	m, err := opts.Read(args[0])
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	opts.WriteTrimmed(os.Stdout, m)
+	// excluded characters are never removed
+	chars := len(m.Kind) - len(m.Excluded())
+
+	tw, err := wdsearch.Create(output, m)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if tw != nil {
+		defer tw.Close()
+	}
+
+	ref := wd.Search(m)
+	if err := wdsearch.Write(tw, ref, "all"); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	rates := make([]float64, len(m.Kind))
+	if mlfile != "" {
+		rates, err = mlRates(mlfile, m)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	} else {
+		// the search is made on site patterns,
+		// so the steps are counted on the whole matrix
+		full, err := parsimony.FromTopology(ref.Topology(), m)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		for i, s := range full.Steps() {
+			rates[i] = float64(s)
+		}
+	}
+
+	// fastest characters first
+	order := make([]int, 0, chars)
+	for i := range m.Kind {
+		if !m.IsExcluded(i) {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return rates[order[i]] > rates[order[j]]
+	})
+
+	clades := ref.Topology().Clades()
+	keys := make(map[string]bool, len(clades))
+	for _, cl := range clades {
+		keys[strings.Join(cl, " ")] = true
+	}
+	lostAt := make([]int, len(clades))
+
+	fmt.Printf("# Tree length: %d\n", ref.Cost())
+	ref.Write(os.Stdout, true)
+	fmt.Printf("\n")
+
+	for s := 1; s <= steps; s++ {
+		k := int(prop * float64(s) * float64(chars))
+		if k == 0 {
+			continue
+		}
+		tr := wd.Search(m.DropChars(order[:k]))
+		if err := wdsearch.Write(tw, tr, fmt.Sprintf("step%d", s)); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		tc := make(map[string]bool)
+		for _, cl := range tr.Topology().Clades() {
+			tc[strings.Join(cl, " ")] = true
+		}
+		lost, added := 0, 0
+		for j, cl := range clades {
+			if tc[strings.Join(cl, " ")] {
+				continue
+			}
+			lost++
+			if lostAt[j] == 0 {
+				lostAt[j] = s
+			}
+		}
+		for key := range tc {
+			if !keys[key] {
+				added++
+			}
+		}
+
+		fmt.Printf("\n# Step %d\n", s)
+		fmt.Printf("# Removed characters: %d (rate >= %.6f)\n", k, rates[order[k-1]])
+		fmt.Printf("# Tree length: %d\n", tr.Cost())
+		fmt.Printf("# Lost clades: %d\n", lost)
+		fmt.Printf("# New clades: %d\n", added)
+		tr.Write(os.Stdout, true)
+		fmt.Printf("\n")
+	}
+
+	fmt.Printf("\n# lost at\tclade\n")
+	for j, cl := range clades {
+		at := "-"
+		if lostAt[j] > 0 {
+			at = fmt.Sprintf("%d", lostAt[j])
+		}
+		fmt.Printf("%s\t%s\n", at, strings.Join(cl, " "))
+	}
+	if tw != nil {
+		if err := tw.Close(); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	}
+	return nil
+}
+
+// MlRates returns the maximum likelihood rates
+// of the characters,
+// using a tree read from a file.
+func mlRates(name string, m *matrix.Matrix) ([]float64, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while opening %s", name)
+	}
+	defer f.Close()
+	t, err := tree.Read(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "when parsing tree")
+	}
+	tr, err := likelihood.FromTopology(t, likelihood.NewFromMatrix(m))
+	if err != nil {
+		return nil, err
+	}
+	if !t.Lens {
+		tr.Refine()
+	}
+	return tr.SiteRates(), nil
+}
@@ -0,0 +1,273 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package taxjack implements the p.taxjack command,
+// i.e. a taxon jackknife (leave-one-out) stability analysis.
+package taxjack
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/internal/load"
+	"github.com/js-arias/ramita/internal/wdsearch"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `p.taxjack [--assumptions <file>] [--exclude <list>]
+		[--gapmode <mode>] [-o|--output <file>]
+		[-r|--replicates <number>] [--swap <name>] [--taxa <file>]
+		[--trim <definition>] [--weights <definition>]
+		[--weights-file <file>] [<dataset>]`,
+	Short: "taxon jackknife (leave-one-out) stability analysis",
+	Long: `
+Command p.taxjack performs a taxon jackknife analysis: a parsimony
+search is made with all the terminals, and then the search is
+repeated removing one terminal at a time. The outgroup is never
+removed.
+
+For each clade of the tree found with all the terminals, its stability
+is printed, i.e. the proportion of deletions in which the clade (minus
+the deleted terminal) is recovered. Deletions of terminals of a clade
+of two terminals are not counted for that clade.
+
+For each terminal, its influence is printed, i.e. the proportion of
+clades of the tree with all terminals (minus the deleted terminal)
+that are lost when the terminal is removed.
+
+Each search is a Wagner-Dayoff search, and the number of replicates of
+each search can be set with the option -r, or --replicates. The
+shortest tree of each search is used. By default, the Wagner trees are
+improved with SPR branch swapping, and the swapping algorithm can be
+changed with the option --swap (nni, spr, or tbr).
+
+If the option -o, or --output, is defined, the tree of each search
+will be written in the indicated file (in NEXUS format), as soon as
+the search is completed, with its length as a comment. The tree with
+all the terminals is named "all", and each jackknife tree is named
+after the removed terminal.
+
+Characters can be weighted with the option --weights, as a list of
+assignments, separated by semicolons, each one with a weight, a colon,
+and a list of characters (the first character is 1), given as
+numbers, or ranges, that can include a step (e.g. "2:1-300\3; 0:301"
+to give weight 2 to the first codon position of the first 300
+characters, and exclude character 301). With the option
+--weights-file, the assignments are read from a file, one assignment
+per line. By default, each character has weight 1.
+
+With the option --taxa, the analysis can be restricted to a subset of
+the terminals of the matrix (e.g. to remove rogue taxa), listed in a
+file, one terminal per line. Lines starting with '#' are ignored.
+
+Characters can be deactivated with the option --exclude, as a list of
+characters (the first character is 1), given as numbers, or ranges
+(e.g. "100-250 300"). Excluded characters are ignored in the analysis,
+but the numbers of the characters are not changed.
+
+Poorly aligned, or noisy, columns can be excluded when the matrix is
+read with the option --trim, as a list of filters, separated by
+semicolons, each one with the name of the filter, an equal sign, and
+its value. With the filter "gaps", columns with a proportion of gaps
+and missing data greater than the value are excluded, and with the
+filter "entropy", columns with a Shannon entropy (in bits) greater than
+the value are excluded (e.g. "gaps=0.5; entropy=1.8"). The number of
+columns removed from each partition is printed (the partitions are
+the partition scheme of a bundle file, see mat.bundle, or the blocks
+of the matrix).
+
+By default, gaps in DNA characters are treated as missing data. With
+the option --gapmode, gaps can be treated as a fifth state, so a
+change between a gap and a nucleotide counts as a step.
+
+Characters can be optimized with a step matrix (i.e. generalized, or
+Sankoff, parsimony) with the option --assumptions, that reads the step
+matrices from a file. Each step matrix is defined by a line with the
+keyword 'stepmatrix' and the name of the matrix, followed by a line
+for each state, with the costs of the transformation from that state
+to each state. A line with the name of a step matrix, an equal sign,
+and a list of characters, assigns the step matrix to the characters.
+Characters without a step matrix are optimized as unordered (Fitch)
+characters.
+
+Options are:
+
+    --assumptions <file>
+      If defined, the step matrices of the characters will be read
+      from the indicated file.
+
+    --exclude <list>
+      If defined, the indicated characters will be excluded from the
+      analysis.
+
+    --gapmode <mode>
+      Set the treatment of gaps in DNA characters. Valid values are
+      missing, and state (a fifth state). Default: missing.
+
+    -o <file>
+    --output <file>
+      If defined, the tree of each search will be written in the
+      indicated file.
+
+    -r <number>
+    --replicates <number>
+      Set the number of replicates of each search. Default: 10.
+
+    --swap <name>
+      Set the branch swapping algorithm, either nni, spr, or tbr.
+      Default: spr.
+
+    --taxa <file>
+      If defined, only the terminals listed in the indicated file
+      will be used in the analysis.
+
+    --trim <definition>
+      If defined, the columns rejected by the indicated filters will
+      be excluded.
+
+    --weights <definition>
+      If defined, the characters will be weighted using the indicated
+      definition.
+
+    --weights-file <file>
+      If defined, the characters will be weighted using the
+      definition in the indicated file.
+
+    <dataset>
+      The phylogenetic data matrix. If not given explicitly, it will
+      be read from the standard input.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var output string
+
+var wd wdsearch.Options
+
+var opts load.Options
+
+func register(c *cmdapp.Command) {
+	wd.Register(c)
+	opts.Register(c)
+	c.Flag.StringVar(&output, "output", "", "")
+	c.Flag.StringVar(&output, "o", "", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) > 1 {
+		return errors.Errorf("%s: too many arguments", c.Name())
+	}
+	if err := wd.Check(); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	name := ""
+	if len(args) == 1 {
+		name = args[0]
+	}
+	m, err := opts.Read(name)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	opts.WriteTrimmed(os.Stdout, m)
+	if len(m.Names) < 5 {
+		return errors.Errorf("%s: matrix with less than 5 terminals", c.Name())
+	}
+
+	tw, err := wdsearch.Create(output, m)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if tw != nil {
+		defer tw.Close()
+	}
+
+	ref := wd.Search(m)
+	if err := wdsearch.Write(tw, ref, "all"); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	clades := ref.Topology().Clades()
+	found := make([]int, len(clades))
+	tests := make([]int, len(clades))
+
+	taxa := m.Taxa()[1:]
+	influence := make([]float64, len(taxa))
+	for i, tx := range taxa {
+		jt := wd.Search(m.DropTaxa([]string{tx.Name}))
+		if err := wdsearch.Write(tw, jt, tx.Name); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		jc := make(map[string]bool)
+		for _, cl := range jt.Topology().Clades() {
+			jc[strings.Join(cl, " ")] = true
+		}
+		lost, valid := 0, 0
+		for j, cl := range clades {
+			k := without(cl, tx.Name)
+			if len(k) < 2 {
+				continue
+			}
+			tests[j]++
+			valid++
+			if jc[strings.Join(k, " ")] {
+				found[j]++
+				continue
+			}
+			lost++
+		}
+		if valid > 0 {
+			influence[i] = float64(lost) / float64(valid)
+		}
+	}
+
+	fmt.Printf("# Tree length: %d\n", ref.Cost())
+	ref.Write(os.Stdout, true)
+	fmt.Printf("\n")
+
+	fmt.Printf("\n# stability\tclade\n")
+	for j, cl := range clades {
+		s := float64(0)
+		if tests[j] > 0 {
+			s = float64(found[j]) / float64(tests[j])
+		}
+		fmt.Printf("%.4f\t%s\n", s, strings.Join(cl, " "))
+	}
+
+	fmt.Printf("\n# influence\tterminal\n")
+	for i, tx := range taxa {
+		fmt.Printf("%.4f\t%s\n", influence[i], tx.Name)
+	}
+	if tw != nil {
+		if err := tw.Close(); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	}
+	return nil
+}
+
+// Without returns a list of terminals
+// without the indicated terminal.
+func without(terms []string, name string) []string {
+	ls := make([]string, 0, len(terms))
+	for _, nm := range terms {
+		if nm != name {
+			ls = append(ls, nm)
+		}
+	}
+	return ls
+}
@@ -7,33 +7,262 @@
 package wagday
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
+	"math/rand"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/internal/load"
 	"github.com/js-arias/ramita/matrix"
 	"github.com/js-arias/ramita/parsimony"
+	"github.com/js-arias/ramita/tree"
 
 	"github.com/pkg/errors"
 )
 
 var cmd = &cmdapp.Command{
-	UsageLine: "p.wagday [-c|--comma] [<dataset>]",
-	Short:     "make a Wagner-Dayoff tree with parsimony",
+	UsageLine: `p.wagday [-a|--all] [--assumptions <file>] [--clades <list>]
+		[--collapse] [-c|--comma] [--constraint <file>] [-d|--duplicates]
+		[--exclude <list>] [--gapmode <mode>]
+		[--max-rearrangements <number>] [--max-time <duration>]
+		[--negative] [--replicate-range <a-b>]
+		[-s|--sequence <name>] [--swap <name>] [--taxa <file>]
+		[-t|--tree <treefile>] [--trim <definition>]
+		[--weights <definition>] [--weights-file <file>] [<dataset>]`,
+	Short: "make a Wagner-Dayoff tree with parsimony",
 	Long: `
 Command p.wagday makes a tree with parsimony using a random addition
 sequence. The resulting tree will be printed in the standard output.
 
+Other addition sequences can be selected with the option -s, or
+--sequence. Valid sequences are:
+
+    random   terminals are added in a random order (the default).
+    asis     terminals are added in the order of the matrix (i.e.
+             sorted by name).
+    closest  at each step, the terminal that produces the smallest
+             increase in length is added.
+    maxmini  at each step, the terminal whose minimum increase in
+             length is the largest is added.
+
+By default, the tree is improved with SPR branch swapping. Other
+swapping algorithms can be selected with the option --swap. Valid
+algorithms are:
+
+    nni      nearest neighbor interchange, a fast, but less effective
+             swapping, useful for very large matrices.
+    spr      subtree pruning and regrafting (the default).
+    tbr      tree bisection and reconnection, a slower, but more
+             effective swapping.
+
 By default, the tree will be printed with sister groups separed by
 spaces (tnt format). If the option -c or --comma is set, then sister
 groups will be separated by commas (,) as in phylip.
 
+Terminals without data, as well as blocks without data in all
+terminals, are removed before the analysis, and a warning is printed.
+
+If the option -d or --duplicates is set, terminals with identical
+data will be collapsed into a single representative before the
+search, and then re-expanded as a polytomy in the resulting tree.
+
+Before the search, identical characters are collapsed into site
+patterns, and each pattern is evaluated once, with the sum of the
+weights of its characters. This does not change the length of the
+trees.
+
+If the option -a, or --all, is set, all the different trees with the
+best length found during the branch swapping will be printed, instead
+of a single tree.
+
+If the option --collapse is set, the internal branches without
+unambiguous support (i.e. branches with a minimum length of zero) will
+be collapsed into polytomies before printing the trees (as the "rule
+1" of TNT). With the option -a, or --all, the trees that are identical
+after collapsing are printed only once.
+
+The search can be bounded with the options --max-time, that sets the
+maximum running time (e.g. 30s, 10m, 1h30m), and --max-rearrangements,
+that sets the maximum number of rearrangements evaluated during the
+branch swapping. If a limit is reached, the swapping is stopped, the
+best tree found so far is printed, and a warning is printed. With a
+replicate range, the limits apply to the whole set of replicates, and
+the replicates not started are not made.
+
+If the option -t, or --tree, is defined with a tree file, the Wagner
+tree will not be built, and instead, the first tree of the file will
+be used as the starting tree of the branch swapping. In this way,
+trees produced by other programs can be improved. The starting tree
+will be rooted at the outgroup, and its polytomies will be resolved at
+random.
+Terminals removed from the analysis (e.g. terminals without data)
+are pruned from the starting tree.
+
+If the option --constraint is defined with a tree file, the first tree
+of the file will be used as a constraint: the terminals will be added
+only at positions that keep the clades of the constraint tree, and the
+branch swapping will only accept trees with those clades. If the
+option --negative is set, the constraint is negative, and the trees
+must not have all the clades of the constraint tree (e.g. to search
+for the best tree without a given clade). The constraint tree can have
+polytomies, and the terminals that are not in the constraint tree can
+be placed anywhere. If the outgroup is in the constraint tree, the
+clades are taken as if the tree were rooted at the outgroup. A
+starting tree given with -t, or --tree, must fulfill the constraint.
+
+The option --constraint can also be defined with a clade definition
+file, in which each line defines a clade, with the name of the clade,
+an equal sign, and the list of terminals of the clade (as in
+t.subtree). Then the constraint is made with the defined clades, or
+with the clades named in the option --clades (a comma separated list),
+and all the terminals are constrained, i.e. in a positive constraint,
+each clade must have only the terminals of its definition. Terminals
+removed from the analysis are removed from the constraint.
+
+If the option --replicate-range is defined with a range of replicates
+(e.g. 101-200), a Wagner-Dayoff tree will be made for each replicate
+in the range, and the trees will be printed in NEXUS format, named
+"rep<n>", with its length as a comment. Each replicate uses a random
+seed derived from its number, so the trees do not depend on how the
+replicates are split. Then a large number of replicates can be split
+across several jobs (for example in a cluster), and the resulting
+files combined with t.merge.
+
+Characters can be weighted with the option --weights, as a list of
+assignments, separated by semicolons, each one with a weight, a colon,
+and a list of characters (the first character is 1), given as
+numbers, or ranges, that can include a step (e.g. "2:1-300\3; 0:301"
+to give weight 2 to the first codon position of the first 300
+characters, and exclude character 301). With the option
+--weights-file, the assignments are read from a file, one assignment
+per line. By default, each character has weight 1.
+
+With the option --taxa, the analysis can be restricted to a subset of
+the terminals of the matrix (e.g. to remove rogue taxa), listed in a
+file, one terminal per line. Lines starting with '#' are ignored.
+
+Characters can be deactivated with the option --exclude, as a list of
+characters (the first character is 1), given as numbers, or ranges
+(e.g. "100-250 300"). Excluded characters are ignored in the analysis,
+but the numbers of the characters are not changed.
+
+Poorly aligned, or noisy, columns can be excluded when the matrix is
+read with the option --trim, as a list of filters, separated by
+semicolons, each one with the name of the filter, an equal sign, and
+its value. With the filter "gaps", columns with a proportion of gaps
+and missing data greater than the value are excluded, and with the
+filter "entropy", columns with a Shannon entropy (in bits) greater than
+the value are excluded (e.g. "gaps=0.5; entropy=1.8"). The number of
+columns removed from each partition is printed (the partitions are
+the partition scheme of a bundle file, see mat.bundle, or the blocks
+of the matrix).
+
+By default, gaps in DNA characters are treated as missing data. With
+the option --gapmode, gaps can be treated as a fifth state, so a
+change between a gap and a nucleotide counts as a step.
+
+Characters can be optimized with a step matrix (i.e. generalized, or
+Sankoff, parsimony) with the option --assumptions, that reads the step
+matrices from a file. Each step matrix is defined by a line with the
+keyword 'stepmatrix' and the name of the matrix, followed by a line
+for each state, with the costs of the transformation from that state
+to each state. A line with the name of a step matrix, an equal sign,
+and a list of characters, assigns the step matrix to the characters.
+Characters without a step matrix are optimized as unordered (Fitch)
+characters.
+
 Options are:
 
+    -a
+    --all
+      If set, all the trees with the best length found during the
+      branch swapping will be printed.
+
+    --assumptions <file>
+      If defined, the step matrices of the characters will be read
+      from the indicated file.
+
+    --clades <list>
+      If defined, only the indicated clades of the clade definition
+      file will be used as constraint.
+
+    --collapse
+      If set, branches with a minimum length of zero will be
+      collapsed.
+
     -c
     --comma
       If set, sister groups will be separated by commas.
 
+    --constraint <file>
+      If defined, the first tree of the indicated file, or the clades
+      of a clade definition file, will be used as a topological
+      constraint of the search.
+
+    -d
+    --duplicates
+      If set, terminals with identical data will be analyzed as a
+      single terminal.
+
+    --exclude <list>
+      If defined, the indicated characters will be excluded from the
+      analysis.
+
+    --gapmode <mode>
+      Set the treatment of gaps in DNA characters. Valid values are
+      missing, and state (a fifth state). Default: missing.
+
+    --max-rearrangements <number>
+      If defined, the search will be stopped after the indicated
+      number of rearrangements.
+
+    --max-time <duration>
+      If defined, the search will be stopped after the indicated
+      time.
+
+    --negative
+      If set, the constraint tree will be used as a negative
+      constraint.
+
+    --replicate-range <a-b>
+      If defined, the indicated range of replicates will be made,
+      and the trees printed in NEXUS format.
+
+    -s <name>
+    --sequence <name>
+      Set the addition sequence used to build the Wagner tree.
+      Default: random.
+
+    --swap <name>
+      Set the branch swapping algorithm. Default: spr.
+
+    --taxa <file>
+      If defined, only the terminals listed in the indicated file
+      will be used in the analysis.
+
+    -t <treefile>
+    --tree <treefile>
+      If defined, the first tree of the indicated file will be used as
+      the starting tree, instead of a Wagner tree.
+
+    --trim <definition>
+      If defined, the columns rejected by the indicated filters will
+      be excluded.
+
+    --weights <definition>
+      If defined, the characters will be weighted using the indicated
+      definition.
+
+    --weights-file <file>
+      If defined, the characters will be weighted using the
+      definition in the indicated file.
+
     <dataset>
       The phylogenetic data matrix. If not given explicitly, it will
       be read from the standard input.
@@ -46,39 +275,376 @@ func init() {
 	cmdapp.Add(cmd)
 }
 
+var all bool
+var clades string
+var collapse bool
 var comma bool
+var consFile string
+var dups bool
+var maxRearr int
+var maxTime time.Duration
+var negative bool
+var repRange string
+var sequence string
+var swap string
+var treefile string
+
+var opts load.Options
 
 func register(c *cmdapp.Command) {
+	c.Flag.BoolVar(&all, "all", false, "")
+	c.Flag.BoolVar(&all, "a", false, "")
+	opts.Register(c)
+	c.Flag.StringVar(&clades, "clades", "", "")
+	c.Flag.BoolVar(&collapse, "collapse", false, "")
 	c.Flag.BoolVar(&comma, "comma", false, "")
 	c.Flag.BoolVar(&comma, "c", false, "")
+	c.Flag.StringVar(&consFile, "constraint", "", "")
+	c.Flag.BoolVar(&dups, "duplicates", false, "")
+	c.Flag.BoolVar(&dups, "d", false, "")
+	c.Flag.IntVar(&maxRearr, "max-rearrangements", 0, "")
+	c.Flag.DurationVar(&maxTime, "max-time", 0, "")
+	c.Flag.BoolVar(&negative, "negative", false, "")
+	c.Flag.StringVar(&repRange, "replicate-range", "", "")
+	c.Flag.StringVar(&sequence, "sequence", "random", "")
+	c.Flag.StringVar(&sequence, "s", "random", "")
+	c.Flag.StringVar(&swap, "swap", "spr", "")
+	c.Flag.StringVar(&treefile, "tree", "", "")
+	c.Flag.StringVar(&treefile, "t", "", "")
 }
 
 func run(c *cmdapp.Command, args []string) error {
 	if len(args) > 1 {
 		return errors.Errorf("%s: too many arguments", c.Name())
 	}
+	seq, err := parsimony.ParseAddition(sequence)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	sw, err := parsimony.ParseSwap(swap)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if clades != "" && consFile == "" {
+		return errors.Errorf("%s: option --clades requires a clade definition file", c.Name())
+	}
+	first, last := 0, 0
+	if repRange != "" {
+		first, last, err = parseRange(repRange)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		if all {
+			return errors.Errorf("%s: options --all and --replicate-range are incompatible", c.Name())
+		}
+	}
 
-	f := os.Stdin
+	name := ""
 	if len(args) == 1 {
-		var err error
-		f, err = os.Open(args[0])
+		name = args[0]
+	}
+	m, err := opts.Read(name)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	// with a replicate range,
+	// the standard output is a NEXUS file
+	// so messages are printed in the standard error
+	msg := os.Stdout
+	if repRange != "" {
+		msg = os.Stderr
+	}
+
+	if err := env.Header(msg); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	opts.WriteTrimmed(msg, m)
+
+	var removed []string
+	if empty := m.Empty(); len(empty) > 0 {
+		for _, nm := range empty {
+			fmt.Fprintf(msg, "# Warning: terminal %s without data: removed\n", nm)
+		}
+		m = m.DropTaxa(empty)
+		removed = append(removed, empty...)
+	}
+	if empty := m.EmptyBlocks(); len(empty) > 0 {
+		for _, b := range empty {
+			fmt.Fprintf(msg, "# Warning: block %s without data: removed\n", m.Blocks[b].Name)
+		}
+		m = m.DropBlocks(empty)
+	}
+
+	var groups [][]string
+	if dups {
+		groups = m.Duplicates(0)
+		for _, g := range groups {
+			fmt.Fprintf(msg, "# Duplicates: %s\n", strings.Join(g, " "))
+			removed = append(removed, g[1:]...)
+		}
+		m = m.Collapse(groups)
+	}
+
+	chars := len(m.Kind)
+	m, _ = m.Compress(nil)
+	fmt.Fprintf(msg, "# Site patterns: %d of %d characters\n", len(m.Kind), chars)
+
+	var cons *parsimony.Constraint
+	if consFile != "" {
+		cons, err = readConstraint(consFile, m, removed)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	}
+
+	var start *tree.Tree
+	if treefile != "" {
+		start, err = readStart(treefile, m, removed)
 		if err != nil {
-			return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+			return errors.Wrap(err, c.Name())
 		}
-		defer f.Close()
 	}
 
-	m, err := matrix.NewMatrix(f)
+	var lim *parsimony.Limit
+	if maxTime > 0 || maxRearr > 0 {
+		lim = parsimony.NewLimit(maxTime, maxRearr)
+	}
+
+	if repRange != "" {
+		if err := replicates(m, start, seq, cons, sw, lim, groups, first, last); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		return nil
+	}
+
+	tr, err := build(m, start, seq, cons)
 	if err != nil {
-		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+		return errors.Wrap(err, c.Name())
+	}
+	if start != nil {
+		fmt.Printf("# Initial Length: %d\n", tr.Cost())
+	} else {
+		fmt.Printf("# Wagner Length: %d\n", tr.Cost())
+	}
+	if all {
+		ts := &parsimony.TreeSet{}
+		if !tr.SwapLimit(sw, ts, lim) {
+			fmt.Printf("# Warning: search stopped: limit reached\n")
+		}
+		fmt.Printf("# Final Length: %d\n", ts.Cost())
+		fmt.Printf("# Trees: %d\n", ts.Len())
+		trees := ts.Trees()
+		if collapse {
+			trees, err = parsimony.CollapseSet(ts, m)
+			if err != nil {
+				return errors.Wrap(err, c.Name())
+			}
+			fmt.Printf("# Collapsed trees: %d\n", len(trees))
+		}
+		for _, tp := range trees {
+			if len(groups) > 0 {
+				tp.Expand(groups)
+			}
+			tp.Write(os.Stdout, comma)
+			fmt.Printf("\n")
+		}
+		return nil
 	}
 
-	tr := parsimony.Wagner(m)
-	fmt.Printf("# Wagner Length: %d\n", tr.Cost())
-	tr.Dayoff()
+	if !tr.SwapLimit(sw, nil, lim) {
+		fmt.Printf("# Warning: search stopped: limit reached\n")
+	}
 	tr.Laderize(false)
 	fmt.Printf("# Final Length: %d\n", tr.Cost())
-	tr.Write(os.Stdout, comma)
+	tp := tr.Topology()
+	if collapse {
+		tp = tr.Collapse()
+	}
+	if len(groups) > 0 {
+		tp.Expand(groups)
+	}
+	tp.Write(os.Stdout, comma)
 	fmt.Printf("\n")
 	return nil
 }
+
+// Replicates makes a Wagner-Dayoff tree
+// for each replicate in a range,
+// and writes the trees in NEXUS format.
+// If the limit is reached,
+// no more replicates are made.
+func replicates(m *matrix.Matrix, start *tree.Tree, seq parsimony.Addition, cons *parsimony.Constraint, sw parsimony.Swap, lim *parsimony.Limit, groups [][]string, first, last int) error {
+	ls := make([]string, 0, len(m.Names))
+	for nm := range m.Names {
+		ls = append(ls, nm)
+	}
+	for _, g := range groups {
+		ls = append(ls, g[1:]...)
+	}
+	tw := tree.NewWriter(os.Stdout, ls)
+	if err := tw.Comment(env.String() + " replicate seeds=replicate number"); err != nil {
+		return err
+	}
+	for i := first; i <= last; i++ {
+		if lim.Done() {
+			fmt.Fprintf(os.Stderr, "# Warning: search stopped before replicate %d: limit reached\n", i)
+			break
+		}
+		rand.Seed(int64(i))
+		tr, err := build(m, start, seq, cons)
+		if err != nil {
+			return err
+		}
+		stopped := !tr.SwapLimit(sw, nil, lim)
+		tr.Laderize(false)
+		tp := tr.Topology()
+		if collapse {
+			tp = tr.Collapse()
+		}
+		if len(groups) > 0 {
+			tp.Expand(groups)
+		}
+		tp.Name = fmt.Sprintf("rep%d", i)
+		tp.Root.Comment = fmt.Sprintf("length=%d", tr.Cost())
+		if err := tw.Write(tp); err != nil {
+			return err
+		}
+		if stopped {
+			fmt.Fprintf(os.Stderr, "# Warning: search stopped at replicate %d: limit reached\n", i)
+			break
+		}
+	}
+	return tw.Close()
+}
+
+// Build returns the starting tree
+// of a search:
+// a Wagner tree,
+// or a tree built from the given topology,
+// with the indicated constraint.
+func build(m *matrix.Matrix, start *tree.Tree, seq parsimony.Addition, cons *parsimony.Constraint) (*parsimony.Tree, error) {
+	if start == nil {
+		return parsimony.WagnerConstraint(m, seq, cons), nil
+	}
+	tr, err := parsimony.FromTopology(start, m)
+	if err != nil {
+		return nil, err
+	}
+	if cons != nil {
+		if err := tr.SetConstraint(cons); err != nil {
+			return nil, errors.Wrap(err, "starting tree")
+		}
+	}
+	return tr, nil
+}
+
+// ReadConstraint reads a constraint from a file,
+// with a constraint tree,
+// or a list of clade definitions,
+// removing the indicated terminals.
+func readConstraint(name string, m *matrix.Matrix, removed []string) (*parsimony.Constraint, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while reading %s", name)
+	}
+	if isTree(b) {
+		if clades != "" {
+			return nil, errors.Errorf("%s: option --clades requires a clade definition file", name)
+		}
+		t, err := tree.Read(bytes.NewReader(b))
+		if err != nil {
+			return nil, errors.Wrapf(err, "when parsing constraint tree")
+		}
+		if len(removed) > 0 {
+			t.Prune(removed)
+		}
+		return parsimony.NewConstraint(t, m, negative)
+	}
+
+	defs, err := tree.ReadCladeDefs(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.Wrapf(err, "when parsing %s", name)
+	}
+	if clades != "" {
+		var sel []tree.CladeDef
+		for _, nm := range strings.Split(clades, ",") {
+			nm = strings.TrimSpace(nm)
+			d, ok := tree.FindClade(defs, nm)
+			if !ok {
+				return nil, errors.Errorf("clade %s not defined in %s", nm, name)
+			}
+			sel = append(sel, d)
+		}
+		defs = sel
+	}
+	rm := make(map[string]bool, len(removed))
+	for _, nm := range removed {
+		rm[nm] = true
+	}
+	for i, d := range defs {
+		var terms []string
+		for _, nm := range d.Terms {
+			if !rm[nm] {
+				terms = append(terms, nm)
+			}
+		}
+		defs[i] = tree.CladeDef{Name: d.Name, Terms: terms}
+	}
+	return parsimony.NewCladeConstraint(defs, m, negative)
+}
+
+// IsTree returns true
+// if a file starts with a tree
+// in parenthetical or NEXUS format.
+func isTree(b []byte) bool {
+	s := strings.TrimSpace(string(b))
+	return strings.HasPrefix(s, "(") || strings.HasPrefix(strings.ToUpper(s), "#NEXUS")
+}
+
+// ReadStart reads a starting tree from a file,
+// removing the indicated terminals,
+// and rooting the tree at the outgroup.
+func readStart(name string, m *matrix.Matrix, removed []string) (*tree.Tree, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, errors.Wrapf(err, "while opening %s", name)
+	}
+	defer f.Close()
+	t, err := tree.Read(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "when parsing tree")
+	}
+	if len(removed) > 0 {
+		t.Prune(removed)
+	}
+	if err := t.Reroot(m.Out.Name); err != nil {
+		return nil, err
+	}
+	if _, err := parsimony.FromTopology(t, m); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// ParseRange parses a replicate range
+// in the form a-b.
+func parseRange(s string) (first, last int, err error) {
+	i := strings.Index(s, "-")
+	if i < 0 {
+		return 0, 0, errors.Errorf("invalid replicate range %q", s)
+	}
+	first, err = strconv.Atoi(strings.TrimSpace(s[:i]))
+	if err != nil {
+		return 0, 0, errors.Errorf("invalid replicate range %q", s)
+	}
+	last, err = strconv.Atoi(strings.TrimSpace(s[i+1:]))
+	if err != nil {
+		return 0, 0, errors.Errorf("invalid replicate range %q", s)
+	}
+	if first < 1 || last < first {
+		return 0, 0, errors.Errorf("invalid replicate range %q", s)
+	}
+	return first, last, nil
+}
@@ -0,0 +1,357 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package window implements the p.window command,
+// i.e. a sliding window analysis.
+package window
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/internal/load"
+	"github.com/js-arias/ramita/internal/wdsearch"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `p.window [--assumptions <file>] [--exclude <list>]
+		[--gapmode <mode>] [-o|--output <file>]
+		[-r|--replicates <number>] [-s|--step <number>]
+		[--swap <name>] [--taxa <file>] [--trim <definition>]
+		[--weights <definition>] [--weights-file <file>]
+		[-w|--window <number>] <dataset>`,
+	Short: "sliding window analysis",
+	Long: `
+Command p.window performs a sliding window analysis: a parsimony search
+is made in windows of consecutive characters along the alignment, and
+the trees of each window are compared with the tree of the previous
+window, and with the tree found with all the characters. Topological
+changes along the sequence are commonly taken as an indication of
+recombination, or chimeric sequences.
+
+By default, each window has 300 characters, that can be changed with
+the option -w, or --window. Windows start every 100 characters, that
+can be changed with the option -s, or --step. If the last window does
+not end at the last character of the alignment, a window ending at the
+last character is added.
+
+Terminals without data in a window (e.g. a sequence that does not
+cover that region) are removed from the search of the window, and
+they are pruned from the compared trees before the calculation of the
+distances. Windows with less than 3 terminals with data, or without
+active characters, are skipped.
+
+The output is the tree found with all the characters, and for each
+window, its first and last character, the number of parsimony
+informative characters in the window, the length of the tree, the
+Robinson-Foulds distance (i.e. the number of splits found in only one
+of the trees) with the tree of the previous window, the Robinson-Foulds
+distance with the tree found with all the characters, and the tree.
+Finally, the number of windows with a topology different from the
+previous window is printed.
+
+Each search is a Wagner-Dayoff search, and the number of replicates of
+each search can be set with the option -r, or --replicates. The
+shortest tree of each search is used. By default, the Wagner trees are
+improved with SPR branch swapping, and the swapping algorithm can be
+changed with the option --swap (nni, spr, or tbr).
+
+If the option -o, or --output, is defined, the tree of each window will
+be written in the indicated file (in NEXUS format), as soon as the
+search is completed, with its length as a comment. The tree with all
+the characters is named "all", and the tree of each window is named
+"w<first>-<last>".
+
+Characters can be weighted with the option --weights, as a list of
+assignments, separated by semicolons, each one with a weight, a colon,
+and a list of characters (the first character is 1), given as
+numbers, or ranges, that can include a step (e.g. "2:1-300\3; 0:301"
+to give weight 2 to the first codon position of the first 300
+characters, and exclude character 301). With the option
+--weights-file, the assignments are read from a file, one assignment
+per line. By default, each character has weight 1.
+
+With the option --taxa, the analysis can be restricted to a subset of
+the terminals of the matrix (e.g. to remove rogue taxa), listed in a
+file, one terminal per line. Lines starting with '#' are ignored.
+
+Characters can be deactivated with the option --exclude, as a list of
+characters (the first character is 1), given as numbers, or ranges
+(e.g. "100-250 300"). Excluded characters are ignored in the analysis,
+but the numbers of the characters are not changed, so the windows are
+always defined on the original alignment.
+
+Poorly aligned, or noisy, columns can be excluded when the matrix is
+read with the option --trim, as a list of filters, separated by
+semicolons, each one with the name of the filter, an equal sign, and
+its value. With the filter "gaps", columns with a proportion of gaps
+and missing data greater than the value are excluded, and with the
+filter "entropy", columns with a Shannon entropy (in bits) greater than
+the value are excluded (e.g. "gaps=0.5; entropy=1.8"). The number of
+columns removed from each partition is printed (the partitions are
+the partition scheme of a bundle file, see mat.bundle, or the blocks
+of the matrix).
+
+By default, gaps in DNA characters are treated as missing data. With
+the option --gapmode, gaps can be treated as a fifth state, so a
+change between a gap and a nucleotide counts as a step.
+
+Characters can be optimized with a step matrix (i.e. generalized, or
+Sankoff, parsimony) with the option --assumptions, that reads the step
+matrices from a file. Each step matrix is defined by a line with the
+keyword 'stepmatrix' and the name of the matrix, followed by a line
+for each state, with the costs of the transformation from that state
+to each state. A line with the name of a step matrix, an equal sign,
+and a list of characters, assigns the step matrix to the characters.
+Characters without a step matrix are optimized as unordered (Fitch)
+characters.
+
+Options are:
+
+    --assumptions <file>
+      If defined, the step matrices of the characters will be read
+      from the indicated file.
+
+    --exclude <list>
+      If defined, the indicated characters will be excluded from the
+      analysis.
+
+    --gapmode <mode>
+      Set the treatment of gaps in DNA characters. Valid values are
+      missing, and state (a fifth state). Default: missing.
+
+    -o <file>
+    --output <file>
+      If defined, the tree of each window will be written in the
+      indicated file.
+
+    -r <number>
+    --replicates <number>
+      Set the number of replicates of each search. Default: 10.
+
+    -s <number>
+    --step <number>
+      Set the number of characters between the start of two
+      consecutive windows. Default: 100.
+
+    --swap <name>
+      Set the branch swapping algorithm, either nni, spr, or tbr.
+      Default: spr.
+
+    --taxa <file>
+      If defined, only the terminals listed in the indicated file
+      will be used in the analysis.
+
+    --trim <definition>
+      If defined, the columns rejected by the indicated filters will
+      be excluded.
+
+    --weights <definition>
+      If defined, the characters will be weighted using the indicated
+      definition.
+
+    --weights-file <file>
+      If defined, the characters will be weighted using the
+      definition in the indicated file.
+
+    -w <number>
+    --window <number>
+      Set the number of characters of each window. Default: 300.
+
+    <dataset>
+      The phylogenetic data matrix. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var output string
+var step int
+var size int
+
+var opts load.Options
+var wd wdsearch.Options
+
+func register(c *cmdapp.Command) {
+	opts.Register(c)
+	wd.Register(c)
+	c.Flag.StringVar(&output, "output", "", "")
+	c.Flag.StringVar(&output, "o", "", "")
+	c.Flag.IntVar(&step, "step", 100, "")
+	c.Flag.IntVar(&step, "s", 100, "")
+	c.Flag.IntVar(&size, "window", 300, "")
+	c.Flag.IntVar(&size, "w", 300, "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a dataset filename", c.Name())
+	}
+	if err := wd.Check(); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if size < 1 {
+		return errors.Errorf("%s: invalid window size: %d", c.Name(), size)
+	}
+	if step < 1 {
+		return errors.Errorf("%s: invalid window step: %d", c.Name(), step)
+	}
+
+	m, err := opts.Read(args[0])
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	chars := len(m.Kind)
+	if size > chars {
+		return errors.Errorf("%s: window size %d, but matrix with %d characters", c.Name(), size, chars)
+	}
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	opts.WriteTrimmed(os.Stdout, m)
+
+	tw, err := wdsearch.Create(output, m)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if tw != nil {
+		defer tw.Close()
+	}
+
+	ref := wd.Search(m)
+	if err := wdsearch.Write(tw, ref, "all"); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	all := ref.Topology()
+
+	fmt.Printf("# Window size: %d\n", size)
+	fmt.Printf("# Window step: %d\n", step)
+	fmt.Printf("# Tree length: %d\n", ref.Cost())
+	ref.Write(os.Stdout, true)
+	fmt.Printf("\n\n")
+
+	var starts []int
+	for start := 0; start+size <= chars; start += step {
+		starts = append(starts, start)
+	}
+	if last := starts[len(starts)-1]; last+size < chars {
+		// the last window ends
+		// at the last character
+		starts = append(starts, chars-size)
+	}
+
+	fmt.Printf("# first\tlast\tinformative\tlength\trf-prev\trf-all\ttree\n")
+	var prev *tree.Tree
+	changes := 0
+	for _, start := range starts {
+		end := start + size
+		var drop []int
+		inf := 0
+		for ch := 0; ch < chars; ch++ {
+			if ch < start || ch >= end {
+				drop = append(drop, ch)
+				continue
+			}
+			if !m.IsExcluded(ch) && m.Informative(ch) {
+				inf++
+			}
+		}
+
+		wm := m.DropChars(drop)
+		if empty := wm.Empty(); len(empty) > 0 {
+			fmt.Printf("# Window %d-%d: terminals without data: %s\n", start+1, end, strings.Join(empty, " "))
+			wm = wm.DropTaxa(empty)
+		}
+		if len(wm.Names) < 3 {
+			fmt.Printf("# Window %d-%d: %d terminals with data: skipped\n", start+1, end, len(wm.Names))
+			continue
+		}
+		if wm.Active() == 0 {
+			fmt.Printf("# Window %d-%d: without active characters: skipped\n", start+1, end)
+			continue
+		}
+
+		tr := wd.Search(wm)
+		if err := wdsearch.Write(tw, tr, fmt.Sprintf("w%d-%d", start+1, end)); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		tp := tr.Topology()
+		rfAll, err := rf(all, tp)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		rfPrev := "-"
+		if prev != nil {
+			d, err := rf(prev, tp)
+			if err != nil {
+				return errors.Wrap(err, c.Name())
+			}
+			if d > 0 {
+				changes++
+			}
+			rfPrev = fmt.Sprintf("%d", d)
+		}
+		prev = tp
+
+		fmt.Printf("%d\t%d\t%d\t%d\t%s\t%d\t", start+1, end, inf, tr.Cost(), rfPrev, rfAll)
+		tr.Write(os.Stdout, true)
+		fmt.Printf("\n")
+	}
+	fmt.Printf("\n# Topology changes: %d\n", changes)
+
+	if tw != nil {
+		if err := tw.Close(); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	}
+	return nil
+}
+
+// RF returns the Robinson-Foulds distance
+// between two trees,
+// after the terminals that are not in both trees
+// are pruned.
+func rf(a, b *tree.Tree) (int, error) {
+	ta := make(map[string]bool)
+	for _, nm := range a.Terms() {
+		ta[nm] = true
+	}
+	tb := make(map[string]bool)
+	for _, nm := range b.Terms() {
+		tb[nm] = true
+	}
+	a = prune(a, tb)
+	b = prune(b, ta)
+	return tree.RF(a, b)
+}
+
+// Prune returns a tree
+// without the terminals
+// that are not in a set.
+// The original tree is not modified.
+func prune(t *tree.Tree, in map[string]bool) *tree.Tree {
+	var del []string
+	for _, nm := range t.Terms() {
+		if !in[nm] {
+			del = append(del, nm)
+		}
+	}
+	if len(del) == 0 {
+		return t
+	}
+	t = t.Copy()
+	t.Prune(del)
+	return t
+}
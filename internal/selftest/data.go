@@ -0,0 +1,38 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package selftest
+
+// DnaData is a fragment of 300 characters
+// of a DNA dataset of 21 terminals.
+var dnaData = `
+> dna
+Acanthopleura_japonica     -----GCTCCT--GACCTACCA----TCGGGTTTT-----CCCTT---GG-TGCTCTTGACT-GAGTG-----T--CT-CGGGG--GGCC---AG---------------------------AACGTTTACTTTG-AAAAAATTAGAGTGTTCAAAGCAGGC---------CCCGT-CGCCTGAA-TAATGGTGCATGGAATAATGGAAGAGGACCT-CGGT-TCTA-TTTTG---TTGGTTT----TCGGAAG-TC--GAGGTAATGATTAAGAGGGACAGA-C-GGGGG-CATTCG--
+Anemonia_sulcata           GCTCTTGAC-TGAGTGTGCGCGGGAGTTGCG--------------------------------------------------------------------------------------------ACGTTTACTTTG-AAAAAATTAGAGTGTTCAAAGCAGGCC-----------AG-CGCTTGAA-TACATAAGCATGGAATAATGGAATAGGACTT-GGGT-TCTA-TTTTG---TTGGTTT----CTGGAAC-CT--GAAGTAATGATTAAGAGGGACAGT-T-GGGGG-CATTCG--
+Antedon_serrata            GT-GCTCTTAAT-TGAGTGGCGGGGGTGACCGG-----------------------------------------------------------------------------------------AACGTTTACTTTG-AAAAAATTAGAGTGTTCAAAGCAGGCC----------ATA-CGCCTGAA-TAGCAGAGCATGGAATAATGGAATAGGACCT-TGGT-TCTA-TTGCG---TTGGTTT----TCGGAAC-TC--GAGGTAATGATTAAGAGGGACTGA-C-GGGGG-CATTCG--
+Aphonopelma_sp.            ATCTTCAC-CGGTTGTCCTGGGTGACCGGC---------------------------------------------------------------------------------------------ACGTTTACTTTG-AAAAAATTAGAGTGCTCAAAGCAGGCG----------TGT-AGCCTGAA-TAATGGTGCATGGAATAATGGAATAGGACTT-CGTT-TCTA-TTTTG---TTGGTTT----TCGGAAT-AC--GAGGTAATGATTAAGAGGGACAGA-C-GGGGG-CATTCG--
+Argopecten_irradians       --------GTCCTG----------ATCTACCTCCCGGTTTTACCCTT-GG-TGCTCTTGATT-GAGTG-------TCTCG-GGT--GGCC---GG---------------------------AACATTTACTTTG-AAAAAATTAGAGTGTTCAAAGCAGGC----------AGCT-CGCCTGAA-TAATGGTGCATGGAATAATGGAATAGGACCT-CGGT-TCTA-TTTTG---TTGGTTT----TCGGAAC-TT--GAGGTAATGATTAAGAGGGACAGA-C-GGGGG-CATTCG--
+Balanoglossus_carnosus     CTCTTAGC-TGAGTGCCGGGGGTGGCCGG---------------------------------------------------------------------------------------------AACGTTTACTTTG-AAAAAATTAGAGTGTTCAAAGCAGGCCT---------CGA-TGCCTGAA-TAGTCCAGCATGGAATAATGGAATAGGACCT-CGGT-TCTA-TTGCG---GTGGTTT----TCGGAGC-GC--GAGGTAATGATTAAGAGGGACAGA-C-GGGGG-CATCCG--
+Barentsia_hildegardae      -------GTACCG--ACCTACCATCTGGTTTT-CT--------CTT--GG-TGCCCTTGATT-GGGTG-----T--CTCG-AGT--GGCC---AG---------------------------AACGTTTACTTTG-AAAAAATTAGAGTGCTCAAAGCAGGCG-----------AA-TGTCTGAA-TAGTTCAGCATGGAATAATGGAATAGGACCT-CGGT-TCTA-TTTTG---TTGGTTT----TCGGAAC-TC--GAGGTAATGATTAAGAGGGACTGA-C-GGGGG-CATTCG--
+Berndtia_purpurea          TCC---------GTACTG------AACCGCCCGCCGTTGCAGTCT-CC------AG-TGCTCTTCACT-GAGTG------T-TGGA-CGAT-TGCC---GG---------------------TACGTTTACTTTG-AAAAAATTAGAGTGCTCCAAGCAGGCG---------TTTC-CGCCTGAA-TATCTGTGCATGGAATAATGGAATAGGACCT-CGGT-TCTA-TTTTG---TTGGTTT----TCGGAAC-TT--GAGGTAATGATTAATAGGGACAGA-C-GGGGG-CATTCG--
+Brachionus_plicatilis      CCCTTTAT-TGGGTGTTTTCGGTAGCCGGT---------------------------------------------------------------------------------------------ACGTTTACTTTG-AGAAAATTAGAGTGCTTAAAGCAGGCC----------TTA-AGCCTGAA-TAATGTTGCATGGAATAATAGAATAGGACCT-CGGT-TCTA-TTTTG---TTGGTTT----TGTAGAGCATT-GAGGTAATGATTAATAGGGACAGA-C-GGGGG-CATTCG--
+Branchiostoma_floridae     CTCTTAAC-TGAGTGCCTCGGGGTGCCGG---------------------------------------------------------------------------------------------AGCGTTTACTTTG-AAAAAATTAGAGTGTTCAAAGCAGGCC----------TGG-CGCCTGAA-TAGTGGTGCATGGAATAATGGAATAGGACCT-CGGT-TCTA-TTTCG---TTGGTTT----TCGGAAC-GT--GAGGTAATGATCAAGAGGGACGGA-C-GGGGG-CATTCG--
+Chaetonotus_sp.            -------GCCCGG--------TCTACGTCTGGTTTGAAAAGTTCGT---AG-TGCCCTTAACC-GGGTG--------CTAC-GGAA-TGCC---AG--------------------------AAGGTTTACTCTG-AAAAAATTAGAGTGCTTAAAGCAGGCC--------GTTTG-TGCTTGTA-TACTGTTGCATGGAATAATGGAATAGGACCT-CGGA-TCTA-TTTTG---TTGGTTT----TCGGAGT--C--GAGGTAATGGTTAAGAGGGACAGA-C-GGGGG-CATTCG--
+Chlamys_islandica          ------GTCCTG----------ACCTACCTCCCGGTTTTACCCTT---GG-TGCTCTTGATT-GAGTG-------TCTCG-GGT--GGCC---GG---------------------------AACATTTACTTTG-AAAAAATTAGAGTGTTCAAAGCAGGC----------AATT-CGCCTGAA-TAATGGTGCATGGAATAATGGAATAGGACCT-CGGT-TCTA-TTTTG---TTGGTTT----TCGGAAC-TT--GAGGTAATGATTAAGAGGGACAGA-C-GGGGG-CATTCG--
+Dicyema_sp.                AGT-TGAGTGCTAC-------------------------------------------------------------------------------------------------------------GGCTTTACCTTG-AACAAAATAGAGTGCTTAAGGCAAGC----------ATTC-TGCTTGAA-TATCTCAGCATGGAATAATAGAAAAAGAC-T----------TTTCTG---TTGGTTT----ACCAGTAGTA--AAAGTAATGATTAACAGAGACAGA-C-GGGGG-CATTCG--
+Discocelis_tigrina         TCTTAAT-TGAGTGCCTTAACTGCCCGGC---------------------------------------------------------------------------------------------CACGTTTACTTTG-AAAAAATTGGAGTGCTCAAATCAGGCC----------CAC-TGCCTGAA-CAGATGTGCATGGAATAATGGAAAAGGACTT-CGGT-TCTA-TTTTG---TTGGTTT-----CGGAAC-AT--GAAGTAATGATTAAGAGGGACAGA-C-GGGGG-CATTCG--
+Eisenia_foetida            GCTCTTCGT-TGAGTGCCTCGCGCGGCCGA--------------------------------------------------------------------------------------------CAGATTTACTTTG-AAAAAATTAGAGTGCTCAAAGCAGGCG----------CTT-CGCTTGTA-TAGTCGTGCATGGAATAATGGAATAGGACCT-CGGT-TCTA-TTTTG---TTGGTTT----TCGGAAC-TT--GAGGTAATGATTAAGAGGGACAGA-C-GGGGG-CATTCG--
+Enchytraeus_sp.            GCTCTTCAT-TGAGTGCGTCGAGTGGCTGG--------------------------------------------------------------------------------------------AACGTTTACTTTG-AAAAAATTAGAGTGCTCAAAGCAGGCA----------GTC-TGCCTGAA-TAACCGCGCGTAGAATAATGGAATAGGACCT-CGGT-TCTA-TTTTG---TTGGTTT----TCGGAAC-TT--GAGGTAATGATTAAGAGGGACAGA-C-GGGGG-CATTCG--
+Fasciolopsis_bushi         ---GCCT----GC-TG---GTCTGTTGGCATGCTTC-TT------GG-TGCCTTTAAAC-GGGTG---T-CG-GA-G-GCG--GAC----AG------------------------------CACGTTTACTTTG-AACAAATTTGAGTGCTCAAAGCAGGCC---------TTTG-TGCCTGAA-AATTCTTGCATGGAATAATGGAATAGGACTT-CGGT-TCTA-TTTTG---TTGGTTT----TCGGATC--C--GAAGTAATGGTTAAGAGGGACAGA-C-GGGGG-CATTTG--
+Geocentrophora_sp.         TCTTTAT-TGAGCGCCTTA--TAGCCGAC----------------------------------------------------------------------------------------------ATGTTTACTTTG-AACAAATTAGAGTGCTTAAAGCAAGC-----------TTG-TGCTTGTA-TAGTCGTGCATGGAATAATAGAATAGGACTT-TAGT-TCTA-TTTTG---TTGGTTT----ACGGATC--T--AAAGTAATGATTAAAAGGGACAGG-C-GGGGG-CATTCG--
+Gnathostomula_paradoxa     ATCAT--------GCTTT-----------TATTCTCAGTTGGTC-----TAG---TG-TGCTCTTCACT-GAGTG---------TGTTAGTACCTCT---GA--------------------GACTTTTACTTTG-AGGAAATGAGAGTGCTCAGTGCAGGC-----------TAA-TGCCTGAA-TCTTAGTGCATGGAATAATGGAACATGACCTTTGGT--CTG-TTTTG---CTGGTTT----TAATACC------AGGTAATGATAAATAGGGACAGA-C-GGGGC-CGTTCG--
+Gordius_aquaticus          CTCTTTAC-TGAGTGTCTTGGGT--ACGGA---------------------------------------------------------------------------------------------ACTTTTACTTTG-AAAAAATTAGAGTGCTCAAAGCAGGC---------TCGAA-GCCTCGAA-TATCGATGCATGGAATAATGGAATAGGACCT-CGGT-TCTA-TTTTG---TTGGTTT----TCGGAAA-CC--GAGGTAATGATTAAGAGGAACGGA-C-GGGGG-CATCCG--
+Grillotia_erinaceus        GGCGGTGCTTCACTCAAATGAAGTCCGTCGGCTCGTTTACATGCCTTTGGATGCCCTTTAAAAGGTG----------------------------------------TCTGTGGGCGGATGGCACGTTTACTTTGGAACAAATTTGAGTGCTCAAACCAGGCC---------GATGTTGCCTGAA-AAGTTTTGCATGGAATAATGGAATAGGACTT-CGGT-TCTA-TTTTG---TTGGTTT----TCGGATC--C--GAAGTAATGATCAAAAGAGACAGG-C-GGGGA-CGTTTG--
+`
+
+// DnaTree is a most parsimonious tree
+// of the dna data.
+var dnaTree = `
+(Acanthopleura_japonica,((Anemonia_sulcata,(Argopecten_irradians,Chlamys_islandica)),(Enchytraeus_sp.,(Eisenia_foetida,(Barentsia_hildegardae,((Aphonopelma_sp.,((Balanoglossus_carnosus,Branchiostoma_floridae),(Gordius_aquaticus,(Brachionus_plicatilis,Gnathostomula_paradoxa)))),((Antedon_serrata,Berndtia_purpurea),(Chaetonotus_sp.,((Fasciolopsis_bushi,Grillotia_erinaceus),(Discocelis_tigrina,(Geocentrophora_sp.,Dicyema_sp.)))))))))));
+`
@@ -0,0 +1,259 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package selftest implements the selftest command,
+// i.e. run the built-in tests.
+package selftest
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/likelihood"
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/parsimony"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: "selftest [-n|--repeat <number>]",
+	Short:     "run the built-in tests",
+	Long: `
+Command selftest runs a built-in dataset through the parsimony and
+likelihood pipelines, verifying the expected scores, and reporting the
+time used by each test. It can be used to validate an installation,
+and to compare the speed of different machines.
+
+The built-in dataset is a fragment of 300 DNA characters for 21
+terminals. The tests are:
+
+    p.len      length of a reference tree
+    p.packed   length of the reference tree, with a packed matrix
+    p.steps    the sum of the steps of each character is the tree length
+    p.search   Wagner-Dayoff search (100 replicates) finds the optimal length
+    l.like     likelihood of the reference tree
+    l.optimize likelihood of the reference tree after branch length
+               optimization
+    l.anc      marginal ancestral states sum to one
+
+For each test, the output is "ok" or "FAIL", the name of the test, and
+the mean time used by the test. If the test fails, the obtained and
+expected values are printed. If any test fails, the command ends with
+an error.
+
+Options are:
+
+    -n <number>
+    --repeat <number>
+      Set the number of times each test is repeated. Default: 1.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var repeat int
+
+func register(c *cmdapp.Command) {
+	c.Flag.IntVar(&repeat, "repeat", 1, "")
+	c.Flag.IntVar(&repeat, "n", 1, "")
+}
+
+// Expected values
+const (
+	treeLen   = 553
+	treeLike  = -3542.347608
+	optLike   = -2671.172671
+	searchRep = 100
+)
+
+// A test is a built-in test.
+type test struct {
+	name string
+	run  func() error
+}
+
+var tests = []test{
+	{"p.len", parsLen},
+	{"p.packed", parsPacked},
+	{"p.steps", parsSteps},
+	{"p.search", parsSearch},
+	{"l.like", likeTree},
+	{"l.optimize", likeOptimize},
+	{"l.anc", likeAnc},
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("%s: too many arguments", c.Name())
+	}
+	if repeat < 1 {
+		return errors.Errorf("%s: invalid number of repetitions: %d", c.Name(), repeat)
+	}
+
+	failed := 0
+	var total time.Duration
+	for _, t := range tests {
+		var err error
+		start := time.Now()
+		for i := 0; i < repeat; i++ {
+			if err = t.run(); err != nil {
+				break
+			}
+		}
+		d := time.Since(start)
+		total += d
+		if err != nil {
+			failed++
+			fmt.Printf("FAIL\t%s\t%v\n", t.name, err)
+			continue
+		}
+		fmt.Printf("ok\t%s\t%v\n", t.name, d/time.Duration(repeat))
+	}
+	fmt.Printf("# Total time: %v\n", total)
+	if failed > 0 {
+		return errors.Errorf("%s: %d tests failed", c.Name(), failed)
+	}
+	return nil
+}
+
+func parsLen() error {
+	m, err := matrix.NewMatrix(strings.NewReader(dnaData))
+	if err != nil {
+		return err
+	}
+	tr, err := parsimony.ReadTree(strings.NewReader(dnaTree), m)
+	if err != nil {
+		return err
+	}
+	if tr.Cost() != treeLen {
+		return errors.Errorf("length %d, want %d", tr.Cost(), treeLen)
+	}
+	return nil
+}
+
+func parsPacked() error {
+	m, err := matrix.NewMatrix(strings.NewReader(dnaData))
+	if err != nil {
+		return err
+	}
+	if err := m.Pack(); err != nil {
+		return err
+	}
+	tr, err := parsimony.ReadTree(strings.NewReader(dnaTree), m)
+	if err != nil {
+		return err
+	}
+	if tr.Cost() != treeLen {
+		return errors.Errorf("length %d, want %d", tr.Cost(), treeLen)
+	}
+	return nil
+}
+
+func parsSteps() error {
+	m, err := matrix.NewMatrix(strings.NewReader(dnaData))
+	if err != nil {
+		return err
+	}
+	tr, err := parsimony.ReadTree(strings.NewReader(dnaTree), m)
+	if err != nil {
+		return err
+	}
+	sum := 0
+	for _, s := range tr.Steps() {
+		sum += s
+	}
+	if sum != tr.Cost() {
+		return errors.Errorf("sum of steps %d, want %d", sum, tr.Cost())
+	}
+	return nil
+}
+
+func parsSearch() error {
+	m, err := matrix.NewMatrix(strings.NewReader(dnaData))
+	if err != nil {
+		return err
+	}
+	best := -1
+	for i := 0; i < searchRep; i++ {
+		tr := parsimony.Wagner(m)
+		tr.Dayoff()
+		if best < 0 || tr.Cost() < best {
+			best = tr.Cost()
+		}
+	}
+	if best > treeLen {
+		return errors.Errorf("length %d, want %d", best, treeLen)
+	}
+	return nil
+}
+
+// LikeRefTree returns the reference tree
+// for likelihood.
+func likeRefTree() (*likelihood.Tree, error) {
+	m, err := likelihood.NewMatrix(strings.NewReader(dnaData))
+	if err != nil {
+		return nil, err
+	}
+	t, err := tree.Read(strings.NewReader(dnaTree))
+	if err != nil {
+		return nil, err
+	}
+	return likelihood.FromTopology(t, m)
+}
+
+func likeTree() error {
+	tr, err := likeRefTree()
+	if err != nil {
+		return err
+	}
+	if l := tr.Like(); math.Abs(l-treeLike) > 0.000001 {
+		return errors.Errorf("log likelihood %.6f, want %.6f", l, treeLike)
+	}
+	return nil
+}
+
+func likeOptimize() error {
+	tr, err := likeRefTree()
+	if err != nil {
+		return err
+	}
+	tr.Refine()
+	// as branches are optimized in random order,
+	// small differences are expected
+	if l := tr.Like(); math.Abs(l-optLike) > 0.01 {
+		return errors.Errorf("log likelihood %.6f, want %.6f", l, optLike)
+	}
+	return nil
+}
+
+func likeAnc() error {
+	tr, err := likeRefTree()
+	if err != nil {
+		return err
+	}
+	for n, conds := range tr.Marginals() {
+		if n.Term != nil {
+			continue
+		}
+		for c, cond := range conds {
+			sum := float64(0)
+			for _, p := range cond {
+				sum += p
+			}
+			if math.Abs(sum-1) > 0.000001 {
+				return errors.Errorf("character %d: sum of marginals %.6f, want 1", c+1, sum)
+			}
+		}
+	}
+	return nil
+}
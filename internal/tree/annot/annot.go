@@ -0,0 +1,250 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package annot implements the t.annot command,
+// i.e. write a tree annotated with ancestral reconstructions.
+package annot
+
+import (
+	"fmt"
+	"math/bits"
+	"os"
+	"strings"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/likelihood"
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/parsimony"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `t.annot [-m|--method <method>] [-c|--chars <list>]
+		[-t|--tree <treefile>] <dataset>`,
+	Short: "write a tree annotated with ancestral reconstructions",
+	Long: `
+Command t.annot reads a tree in parenthetical or NEXUS format,
+reconstructs the ancestral states of the characters, and writes the
+tree in NEXUS format, with each node annotated (as a node comment)
+with the reconstructed states and the changes mapped on the branch
+that leads to the node. The output can be read directly by FigTree
+and other tree viewers.
+
+For each character, the annotation c<number> stores the state
+reconstructed at the node. The annotation changes stores the list of
+changes in the branch, in the form <char>:<from>><to>, and nchanges
+stores the number of changes in the branch.
+
+By default, the states are reconstructed using parsimony, and
+ambiguous reconstructions are resolved using accelerated
+transformation (acctran). With the -m, or --method option, other
+methods can be used:
+
+    acctran  parsimony, accelerated transformation.
+    deltran  parsimony, delayed transformation.
+    ml       maximum likelihood, marginal reconstruction, using the
+             branch lengths of the tree (or 0.01 if the tree does not
+             have branch lengths). The annotated state is the state
+             with the highest marginal probability, and the annotation
+             c<number>.prob stores its probability.
+
+The tree will be read from the standard input, unless the option -t or
+--tree is defined with a tree file. If the file has multiple trees,
+only the first one will be used.
+
+Options are:
+
+    -m <method>
+    --method <method>
+      Set the reconstruction method. Valid values are acctran,
+      deltran, and ml. Default: acctran.
+
+    -c <list>
+    --chars <list>
+      If set, only the indicated characters will be annotated. The
+      characters can be given as a list of numbers, or ranges (e.g.
+      "1-5 8"). The first character is 1.
+
+    -t <treefile>
+    --tree <treefile>
+      If defined, the tree will be read from the indicated file,
+      instead of the standard input.
+
+    <dataset>
+      The phylogenetic data matrix. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var method string
+var chars string
+var treefile string
+
+func register(c *cmdapp.Command) {
+	c.Flag.StringVar(&method, "method", "acctran", "")
+	c.Flag.StringVar(&method, "m", "acctran", "")
+	c.Flag.StringVar(&chars, "chars", "", "")
+	c.Flag.StringVar(&chars, "c", "", "")
+	c.Flag.StringVar(&treefile, "tree", "", "")
+	c.Flag.StringVar(&treefile, "t", "", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a dataset filename", c.Name())
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+	}
+	defer f.Close()
+
+	m, err := matrix.NewMatrix(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+	}
+
+	sel := make([]int, len(m.Kind))
+	for i := range sel {
+		sel[i] = i
+	}
+	if chars != "" {
+		sel, err = matrix.ParseRange(chars, len(m.Kind))
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	}
+
+	tf := os.Stdin
+	if treefile != "" {
+		tf, err = os.Open(treefile)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), treefile)
+		}
+		defer tf.Close()
+	}
+	tp, err := tree.Read(tf)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing tree", c.Name())
+	}
+
+	var out *tree.Tree
+	if method == "ml" {
+		tr, err := likelihood.FromTopology(tp, likelihood.NewFromMatrix(m))
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		marg := tr.Marginals()
+		out = &tree.Tree{Root: likeNode(tr.Root, nil, marg, m, sel), Lens: true}
+	} else {
+		pm, err := parsimony.ParseMethod(method)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		tr, err := parsimony.FromTopology(tp, m)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		rec := tr.Reconstruct(pm)
+		out = &tree.Tree{Root: parsNode(tr.Root, rec, m, sel)}
+	}
+	out.Name = "annotated"
+
+	names := make([]string, 0, len(m.Names))
+	for nm := range m.Names {
+		names = append(names, nm)
+	}
+	w := tree.NewWriter(os.Stdout, names)
+	if err := w.Write(out); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if err := w.Close(); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	return nil
+}
+
+// ParsNode returns an annotated node
+// from a parsimony reconstruction.
+func parsNode(n *parsimony.Node, rec map[*parsimony.Node][]uint32, m *matrix.Matrix, sel []int) *tree.Node {
+	tn := &tree.Node{}
+	if n.Term != nil {
+		tn.Name = n.Term.Name
+	} else {
+		tn.Add(parsNode(n.Left, rec, m, sel))
+		tn.Add(parsNode(n.Right, rec, m, sel))
+	}
+
+	var ann, changes []string
+	for _, ch := range sel {
+		s := bits.TrailingZeros32(rec[n][ch])
+		ann = append(ann, fmt.Sprintf("c%d=\"%s\"", ch+1, m.Kind[ch].Symbol(s)))
+		if n.Anc == nil {
+			continue
+		}
+		a := bits.TrailingZeros32(rec[n.Anc][ch])
+		if a != s {
+			changes = append(changes, fmt.Sprintf("%d:%s>%s", ch+1, m.Kind[ch].Symbol(a), m.Kind[ch].Symbol(s)))
+		}
+	}
+	tn.Comment = annotation(ann, changes)
+	return tn
+}
+
+// LikeNode returns an annotated node
+// from a likelihood marginal reconstruction.
+// Parent is the most probable state
+// of each character in the ancestor.
+func likeNode(n *likelihood.Node, parent []int, marg map[*likelihood.Node][]likelihood.Conditional, m *matrix.Matrix, sel []int) *tree.Node {
+	tn := &tree.Node{Len: n.Len}
+	best := make([]int, len(sel))
+	var ann, changes []string
+	for i, ch := range sel {
+		if n.Term != nil {
+			best[i] = bits.TrailingZeros32(n.Term.State(ch))
+			ann = append(ann, fmt.Sprintf("c%d=\"%s\"", ch+1, m.Kind[ch].Symbol(best[i])))
+		} else {
+			p := float64(-1)
+			for s, v := range marg[n][ch] {
+				if v > p {
+					p = v
+					best[i] = s
+				}
+			}
+			ann = append(ann, fmt.Sprintf("c%d=\"%s\",c%d.prob=%.4f", ch+1, m.Kind[ch].Symbol(best[i]), ch+1, p))
+		}
+		if parent == nil {
+			continue
+		}
+		if parent[i] != best[i] {
+			changes = append(changes, fmt.Sprintf("%d:%s>%s", ch+1, m.Kind[ch].Symbol(parent[i]), m.Kind[ch].Symbol(best[i])))
+		}
+	}
+	tn.Comment = annotation(ann, changes)
+	if n.Term != nil {
+		tn.Name = n.Term.Name
+		return tn
+	}
+	tn.Add(likeNode(n.Left, best, marg, m, sel))
+	tn.Add(likeNode(n.Right, best, marg, m, sel))
+	return tn
+}
+
+// Annotation returns an annotation comment
+// in the format used by FigTree.
+func annotation(ann, changes []string) string {
+	ann = append(ann, fmt.Sprintf("nchanges=%d", len(changes)))
+	if len(changes) > 0 {
+		ann = append(ann, fmt.Sprintf("changes=\"%s\"", strings.Join(changes, " ")))
+	}
+	return "&" + strings.Join(ann, ",")
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package chrono implements the t.chrono command,
+// i.e. make a calibrated chronogram.
+package chrono
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `t.chrono [-c|--calib <file>] [-t|--tree <treefile>]`,
+	Short:     "make a calibrated chronogram",
+	Long: `
+Command t.chrono reads a tree with branch lengths in substitution units
+(a phylogram), and transforms it into a chronogram (a tree with branch
+lengths in time units), using the mean path length method, and the
+calibrations defined in a calibration file.
+
+A calibration file is a text file in which each line defines a
+calibration, with the name of the calibrated clade, the minimum age,
+the maximum age, and a list of terminals. The calibrated node is the
+most recent common ancestor of the listed terminals. If an age is not
+defined, use '-'. Lines starting with '#' are ignored. For example:
+
+	# clade     min   max   terminals
+	Mammalia    160   220   Homo_sapiens Ornithorhynchus_anatinus
+	Primates    55    -     Homo_sapiens Lemur_catta
+
+As the tree is scaled with a single rate, the calibrations must be
+compatible with a strict clock. If no calibration file is given, the
+age of the root will be 1.
+
+The tree will be read from the standard input, unless the option -t or
+--tree is defined with a tree file.
+
+Options are:
+
+    -c <file>
+    --calib <file>
+      Set the file with the calibrations.
+
+    -t <treefile>
+    --tree <treefile>
+      If defined, the tree will be read from the indicated file,
+      instead of the standard input.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var calib string
+var treefile string
+
+func register(c *cmdapp.Command) {
+	c.Flag.StringVar(&calib, "calib", "", "")
+	c.Flag.StringVar(&calib, "c", "", "")
+	c.Flag.StringVar(&treefile, "tree", "", "")
+	c.Flag.StringVar(&treefile, "t", "", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("%s: too many arguments", c.Name())
+	}
+
+	var cals []tree.Calibration
+	if calib != "" {
+		var err error
+		cals, err = readCalibrations(calib)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	}
+
+	tf := os.Stdin
+	if treefile != "" {
+		var err error
+		tf, err = os.Open(treefile)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), treefile)
+		}
+		defer tf.Close()
+	}
+	tp, err := tree.Read(tf)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing tree", c.Name())
+	}
+	if !tp.Lens {
+		return errors.Errorf("%s: tree without branch lengths", c.Name())
+	}
+	if err := tp.Chronogram(cals); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	fmt.Printf("# Root age: %.6f\n", tp.Age())
+	tp.Write(os.Stdout, true)
+	fmt.Printf("\n")
+	return nil
+}
+
+func readCalibrations(name string) ([]tree.Calibration, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return tree.ReadCalibrations(f)
+}
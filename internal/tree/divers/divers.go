@@ -0,0 +1,117 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package divers implements the t.divers command,
+// i.e. print diversification statistics of a chronogram.
+package divers
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `t.divers [-i|--intervals <number>] [-t|--tree <treefile>]`,
+	Short:     "print diversification statistics of a chronogram",
+	Long: `
+Command t.divers reads an ultrametric tree (a chronogram), and prints
+some simple diversification statistics.
+
+The gamma statistic of Pybus and Harvey (2000) compares the position of
+the branching events with the expectation under a pure birth (Yule)
+model. Negative values indicate that branching events are concentrated
+near the root (i.e. a slowdown of the diversification rate), positive
+values indicate that branching events are concentrated near the tips.
+The probability is the two-tailed probability under a standard normal
+distribution.
+
+The Yule rate is the maximum likelihood estimate of the speciation rate
+under a pure birth model, conditioned on the age of the root.
+
+The age of the tree is divided in a number of intervals of the same
+length, and for each interval, a skyline-like pure birth speciation
+rate is estimated, as the number of branching events in the interval,
+divided by the sum of the lineage time in the interval.
+
+The tree will be read from the standard input, unless the option -t or
+--tree is defined with a tree file. If the file has multiple trees,
+only the first one will be used.
+
+Options are:
+
+    -i <number>
+    --intervals <number>
+      Set the number of time intervals. Default: 5.
+
+    -t <treefile>
+    --tree <treefile>
+      If defined, the tree will be read from the indicated file,
+      instead of the standard input.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var intervals int
+var treefile string
+
+func register(c *cmdapp.Command) {
+	c.Flag.IntVar(&intervals, "intervals", 5, "")
+	c.Flag.IntVar(&intervals, "i", 5, "")
+	c.Flag.StringVar(&treefile, "tree", "", "")
+	c.Flag.StringVar(&treefile, "t", "", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("%s: too many arguments", c.Name())
+	}
+	if intervals < 1 {
+		return errors.Errorf("%s: invalid number of intervals: %d", c.Name(), intervals)
+	}
+
+	tf := os.Stdin
+	if treefile != "" {
+		var err error
+		tf, err = os.Open(treefile)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), treefile)
+		}
+		defer tf.Close()
+	}
+	tp, err := tree.Read(tf)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing tree", c.Name())
+	}
+	if !tp.Lens {
+		return errors.Errorf("%s: tree without branch lengths", c.Name())
+	}
+	if !tp.IsUltrametric(0.001) {
+		return errors.Errorf("%s: tree is not ultrametric", c.Name())
+	}
+	terms := len(tp.Terms())
+	if terms < 3 {
+		return errors.Errorf("%s: tree with less than 3 terminals", c.Name())
+	}
+
+	g := tp.Gamma()
+	fmt.Printf("# Terminals: %d\n", terms)
+	fmt.Printf("# Root age: %.6f\n", tp.Age())
+	fmt.Printf("# Gamma: %.6f\tp = %.6f\n", g, tree.GammaP(g))
+	fmt.Printf("# Yule rate: %.6f\n", tp.YuleRate())
+	fmt.Printf("# start\tend\tevents\tlineage-time\trate\n")
+	for _, ri := range tp.RateIntervals(intervals) {
+		fmt.Printf("%.6f\t%.6f\t%d\t%.6f\t%.6f\n", ri.Start, ri.End, ri.Events, ri.Lineages, ri.Rate())
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package graft implements the t.graft command,
+// i.e. add the missing terminals to a backbone tree.
+package graft
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/likelihood"
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/parsimony"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `t.graft [-c|--comma] [-m|--method <method>]
+		[--model <definition>] [-s|--sequence <name>]
+		[-t|--tree <treefile>] [--weights <definition>] <dataset>`,
+	Short: "add the missing terminals to a backbone tree",
+	Long: `
+Command t.graft reads a backbone tree in parenthetical or NEXUS
+format, and adds the terminals of the matrix that are not in the tree,
+each one at its best position, keeping the clades of the backbone. In
+this way, new terminals can be added to a previous analysis (for
+example, in an incremental taxon addition), without a new search. The
+resulting tree will be printed in the standard output.
+
+By default, the terminals are added with parsimony, as in the Wagner
+algorithm (i.e. each terminal is added at the position with the
+smallest increase in length), the polytomies of the backbone are
+resolved to minimize the length of the tree, and the tree is rooted at
+the outgroup. With the -m, or --method option, other methods can be
+used:
+
+    parsimony  the terminals are added with parsimony (the default).
+    ml         the terminals are added with maximum likelihood. Each
+               terminal is placed at the branch with the best
+               likelihood, estimating the lengths of the branches
+               adjacent to the new terminal. The other branch lengths
+               are taken from the backbone (or 0.01 if the backbone
+               does not have branch lengths).
+
+With parsimony, the order in which the terminals are added can be
+selected with the option -s, or --sequence. Valid sequences are:
+
+    random   terminals are added in a random order (the default).
+    asis     terminals are added in the order of the matrix (i.e.
+             sorted by name).
+    closest  at each step, the terminal that produces the smallest
+             increase in length is added.
+    maxmini  at each step, the terminal whose minimum increase in
+             length is the largest is added.
+
+With maximum likelihood, the terminals are added in the order of the
+matrix.
+
+By default, the tree will be printed with sister groups separed by
+spaces (tnt format). If the option -c or --comma is set, then sister
+groups will be separated by commas (,) as in phylip.
+
+The backbone tree will be read from the standard input, unless the
+option -t or --tree is defined with a tree file. If the file has
+multiple trees, only the first one will be used. All the terminals of
+the backbone must be in the matrix.
+
+Options are:
+
+    -c
+    --comma
+      If set, sister groups will be separated by commas.
+
+    -m <method>
+    --method <method>
+      Set the method used to add the terminals. Valid values are
+      parsimony, and ml. Default: parsimony.
+
+    --model <definition>
+      If defined, models will be assigned to the characters, as a
+      list of model names and character ranges, separated by
+      semicolons (e.g. "jc:1-2555; mk2:2556-2922"). Valid models are
+      jc, poisson, mk<n>, and mkv<n>. See l.models. Only used with
+      maximum likelihood.
+
+    -s <name>
+    --sequence <name>
+      Set the addition sequence used with parsimony. Default: random.
+
+    -t <treefile>
+    --tree <treefile>
+      If defined, the backbone tree will be read from the indicated
+      file, instead of the standard input.
+
+    --weights <definition>
+      If defined, the characters will be weighted using the indicated
+      definition. Only used with parsimony.
+
+    <dataset>
+      The phylogenetic data matrix. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var comma bool
+var method string
+var model string
+var sequence string
+var treefile string
+var weights string
+
+func register(c *cmdapp.Command) {
+	c.Flag.BoolVar(&comma, "comma", false, "")
+	c.Flag.BoolVar(&comma, "c", false, "")
+	c.Flag.StringVar(&method, "method", "parsimony", "")
+	c.Flag.StringVar(&method, "m", "parsimony", "")
+	c.Flag.StringVar(&model, "model", "", "")
+	c.Flag.StringVar(&sequence, "sequence", "random", "")
+	c.Flag.StringVar(&sequence, "s", "random", "")
+	c.Flag.StringVar(&treefile, "tree", "", "")
+	c.Flag.StringVar(&treefile, "t", "", "")
+	c.Flag.StringVar(&weights, "weights", "", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a dataset filename", c.Name())
+	}
+	if method != "parsimony" && method != "ml" {
+		return errors.Errorf("%s: unknown method: %s", c.Name(), method)
+	}
+	seq, err := parsimony.ParseAddition(sequence)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+	}
+	defer f.Close()
+
+	m, err := matrix.NewMatrix(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+	}
+	if err := m.SetWeights(weights); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if method == "parsimony" && m.Active() == 0 {
+		return errors.Errorf("%s: no characters with a weight greater than 0", c.Name())
+	}
+
+	tf := os.Stdin
+	if treefile != "" {
+		tf, err = os.Open(treefile)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), treefile)
+		}
+		defer tf.Close()
+	}
+	tp, err := tree.Read(tf)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing tree", c.Name())
+	}
+	for _, nm := range tp.Terms() {
+		if m.Names[nm] == nil {
+			return errors.Errorf("%s: terminal %s not in matrix", c.Name(), nm)
+		}
+	}
+	missing := m.Outside(tp.Terms())
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	fmt.Printf("# Grafted terminals: %d\n", len(missing))
+
+	if method == "ml" {
+		tp, err = mlGraft(tp, m, missing)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		lm, err := newMatrix(m)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		tr, err := likelihood.FromTopology(tp, lm)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		fmt.Printf("# Tree -log Likelihood: %.6f\n", -tr.Like())
+		tp.Write(os.Stdout, comma)
+		fmt.Printf("\n")
+		return nil
+	}
+
+	tr, err := parsimony.Graft(tp, m, seq)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	fmt.Printf("# Tree length: %d\n", tr.Cost())
+	tr.Topology().Write(os.Stdout, comma)
+	fmt.Printf("\n")
+	return nil
+}
+
+// MlGraft adds the missing terminals
+// to the tree,
+// one at a time,
+// at the placement with the best likelihood.
+func mlGraft(tp *tree.Tree, m *matrix.Matrix, missing []string) (*tree.Tree, error) {
+	tp = tp.Copy()
+	if !tp.Lens {
+		for _, n := range tp.Nodes() {
+			n.Len = 0.01
+		}
+		tp.Root.Len = 0
+		tp.Lens = true
+	}
+	for _, nm := range missing {
+		terms := append(tp.Terms(), nm)
+		lm, err := newMatrix(m.DropTaxa(m.Outside(terms)))
+		if err != nil {
+			return nil, err
+		}
+		ps, err := likelihood.Place(tp, lm, nm)
+		if err != nil {
+			return nil, err
+		}
+		p := ps[0]
+		sister := tp.Nodes()[p.Edge]
+		if err := tp.Graft(&tree.Node{Name: nm, Len: p.Pendant}, sister); err != nil {
+			return nil, err
+		}
+		sister.Len = p.Distal
+	}
+	return tp, nil
+}
+
+// NewMatrix returns a likelihood matrix
+// with the models of the characters.
+func newMatrix(m *matrix.Matrix) (*likelihood.Matrix, error) {
+	lm := likelihood.NewFromMatrix(m)
+	if err := lm.SetModels(model); err != nil {
+		return nil, err
+	}
+	return lm, nil
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package label implements the t.label command,
+// i.e. label named clades in a tree.
+package label
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `t.label [-t|--tree <treefile>] <defs-file>`,
+	Short:     "label named clades",
+	Long: `
+Command t.label reads a file with clade definitions, and a set of trees
+in parenthetical or NEXUS format, and writes the trees, in parenthetical
+format, with the name of each monophyletic clade attached to the most
+recent common ancestor of its terminals.
+
+The clade names are written as a node comment in FigTree format, for
+example:
+
+	((Homo_sapiens,Lemur_catta)[&clade="Primates"],Mus_musculus);
+
+so they are preserved when the trees are read by other commands. If a
+node already has an annotation, the clade name is added to it.
+
+In a clade definition file, each line defines a clade, with the name of
+the clade, an equal sign, and the list of terminals of the clade. Lines
+starting with '#' are ignored. Terminals of a clade that are not found
+in a tree are ignored. The clades that are not labeled in a tree (as
+they are not monophyletic, or none of its terminals is in the tree)
+are reported as a comment line before the tree.
+
+The trees will be read from the standard input, unless the option -t
+or --tree is defined with a tree file.
+
+Options are:
+
+    -t <treefile>
+    --tree <treefile>
+      If defined, the trees will be read from the indicated file,
+      instead of the standard input.
+
+    <defs-file>
+      The file with the clade definitions. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var treefile string
+
+func register(c *cmdapp.Command) {
+	c.Flag.StringVar(&treefile, "tree", "", "")
+	c.Flag.StringVar(&treefile, "t", "", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a clade definition file", c.Name())
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+	}
+	defer f.Close()
+	defs, err := tree.ReadCladeDefs(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing %s", c.Name(), args[0])
+	}
+	if len(defs) == 0 {
+		return errors.Errorf("%s: no clades defined in %s", c.Name(), args[0])
+	}
+
+	tf := os.Stdin
+	if treefile != "" {
+		tf, err = os.Open(treefile)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), treefile)
+		}
+		defer tf.Close()
+	}
+
+	r := tree.NewReader(tf)
+	trees := 0
+	for r.Scan() {
+		t := r.Tree()
+		trees++
+		for _, nm := range t.NameClades(defs) {
+			fmt.Printf("# tree %d: clade %s not labeled\n", trees, nm)
+		}
+		t.Write(os.Stdout, true)
+		fmt.Printf("\n")
+	}
+	if err := r.Err(); err != nil {
+		return errors.Wrapf(err, "%s: when parsing trees", c.Name())
+	}
+	if trees == 0 {
+		return errors.Errorf("%s: no trees found", c.Name())
+	}
+	return nil
+}
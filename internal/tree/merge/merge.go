@@ -0,0 +1,145 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package merge implements the t.merge command,
+// i.e. merge several tree files into a single file.
+package merge
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `t.merge [-o|--output <file>] <treefile>...`,
+	Short:     "merge several tree files into a single file",
+	Long: `
+Command t.merge reads one or more tree files, and writes all the trees
+into a single NEXUS file, in the order in which the files are given.
+It is intended to combine the replicates of an analysis that was
+split into several jobs (for example, using the option
+--replicate-range of p.wagday).
+
+Trees can be in NEXUS format (with or without a translation table) or
+in parenthetical format. The names and comments of the trees are
+preserved. If two trees have the same name (for example, when the
+same replicate was made in two different jobs), an error will be
+reported.
+
+By default the merged trees are printed in the standard output. If
+the option -o, or --output, is defined, the trees will be written in
+the indicated file.
+
+Options are:
+
+    -o <file>
+    --output <file>
+      If defined, the merged trees will be written in the indicated
+      file.
+
+    <treefile>...
+      One or more tree files. At least one file is required.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var output string
+
+func register(c *cmdapp.Command) {
+	c.Flag.StringVar(&output, "output", "", "")
+	c.Flag.StringVar(&output, "o", "", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) == 0 {
+		return errors.Errorf("%s: expecting one or more tree files", c.Name())
+	}
+
+	// the first pass collects the terminal names
+	// and checks the names of the trees
+	terms := make(map[string]bool)
+	found := make(map[string]string)
+	for _, a := range args {
+		err := scan(a, func(t *tree.Tree) error {
+			if t.Name != "" {
+				if prev, ok := found[t.Name]; ok {
+					return errors.Errorf("tree %s in %s already defined in %s", t.Name, a, prev)
+				}
+				found[t.Name] = a
+			}
+			for _, nm := range t.Terms() {
+				terms[nm] = true
+			}
+			return nil
+		})
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	}
+	names := make([]string, 0, len(terms))
+	for nm := range terms {
+		names = append(names, nm)
+	}
+
+	var tw *tree.Writer
+	if output != "" {
+		var err error
+		tw, err = tree.Create(output, names)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	} else {
+		tw = tree.NewWriter(os.Stdout, names)
+	}
+	defer tw.Close()
+
+	count := 0
+	for _, a := range args {
+		err := scan(a, func(t *tree.Tree) error {
+			count++
+			return tw.Write(t)
+		})
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if output != "" {
+		fmt.Printf("# Merged trees: %d\n", count)
+	}
+	return nil
+}
+
+// Scan reads the trees of a file,
+// calling fn with each tree.
+func scan(name string, fn func(t *tree.Tree) error) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return errors.Wrapf(err, "while opening %s", name)
+	}
+	defer f.Close()
+
+	r := tree.NewReader(f)
+	for r.Scan() {
+		if err := fn(r.Tree()); err != nil {
+			return err
+		}
+	}
+	if err := r.Err(); err != nil {
+		return errors.Wrapf(err, "when parsing %s", name)
+	}
+	return nil
+}
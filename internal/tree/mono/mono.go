@@ -0,0 +1,132 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package mono implements the t.mono command,
+// i.e. test the monophyly of named clades.
+package mono
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `t.mono [-t|--tree <treefile>] <defs-file>`,
+	Short:     "test the monophyly of named clades",
+	Long: `
+Command t.mono reads a file with clade definitions, and a set of trees
+in parenthetical or NEXUS format, and prints, for each tree, whether
+each of the defined clades is monophyletic. At the end, the number of
+trees in which each clade is monophyletic is printed.
+
+In a clade definition file, each line defines a clade, with the name of
+the clade, an equal sign, and the list of terminals of the clade. Lines
+starting with '#' are ignored. For example:
+
+	# clade definitions
+	Primates = Homo_sapiens Lemur_catta
+	Rodentia = Mus_musculus Rattus_norvegicus
+
+Terminals of a clade that are not found in a tree are ignored, so
+trees with an incomplete sampling can be tested.
+
+The trees will be read from the standard input, unless the option -t
+or --tree is defined with a tree file.
+
+Options are:
+
+    -t <treefile>
+    --tree <treefile>
+      If defined, the trees will be read from the indicated file,
+      instead of the standard input.
+
+    <defs-file>
+      The file with the clade definitions. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var treefile string
+
+func register(c *cmdapp.Command) {
+	c.Flag.StringVar(&treefile, "tree", "", "")
+	c.Flag.StringVar(&treefile, "t", "", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a clade definition file", c.Name())
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+	}
+	defer f.Close()
+	defs, err := tree.ReadCladeDefs(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing %s", c.Name(), args[0])
+	}
+	if len(defs) == 0 {
+		return errors.Errorf("%s: no clades defined in %s", c.Name(), args[0])
+	}
+
+	tf := os.Stdin
+	if treefile != "" {
+		tf, err = os.Open(treefile)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), treefile)
+		}
+		defer tf.Close()
+	}
+
+	fmt.Printf("# tree")
+	for _, d := range defs {
+		fmt.Printf("\t%s", d.Name)
+	}
+	fmt.Printf("\n")
+
+	mono := make([]int, len(defs))
+	r := tree.NewReader(tf)
+	trees := 0
+	for r.Scan() {
+		t := r.Tree()
+		trees++
+		fmt.Printf("%d", trees)
+		for i, d := range defs {
+			m, err := t.IsMonophyletic(d.Terms)
+			if err != nil {
+				fmt.Printf("\t-")
+				continue
+			}
+			if m {
+				mono[i]++
+			}
+			fmt.Printf("\t%v", m)
+		}
+		fmt.Printf("\n")
+	}
+	if err := r.Err(); err != nil {
+		return errors.Wrapf(err, "%s: when parsing trees", c.Name())
+	}
+	if trees == 0 {
+		return errors.Errorf("%s: no trees found", c.Name())
+	}
+
+	fmt.Printf("# Trees: %d\n", trees)
+	for i, d := range defs {
+		fmt.Printf("# %s: monophyletic in %d trees (%.4f)\n", d.Name, mono[i], float64(mono[i])/float64(trees))
+	}
+	return nil
+}
@@ -0,0 +1,224 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package reconcile implements the t.reconcile command,
+// i.e. reconcile gene trees with a species tree.
+package reconcile
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `t.reconcile [-m|--map <file>] -s|--species <treefile>
+		<treefile>...`,
+	Short: "reconcile gene trees with a species tree",
+	Long: `
+Command t.reconcile reads a species tree, and one or more files with
+gene trees, and reconciles each gene tree with the species tree, to
+diagnose the sources of conflict between the gene trees and the
+species tree.
+
+Each node of a gene tree is mapped to the most recent common ancestor
+of its species in the species tree. A gene node is a duplication if
+it maps to the same species node as one of its descendants, and the
+species branches missing in the path of a gene branch are counted as
+losses (Page 1994, Syst. Biol. 43: 58). As a species absent from a
+gene tree is counted as a loss, the gene trees should be complete.
+Deep coalescences are the extra lineages (Maddison 1997, Syst. Biol.
+46: 523), i.e. the number of gene lineages that cross the top of a
+species branch, minus one. They are calculated on the species tree
+restricted to the species in the gene tree.
+
+For each gene tree, the number of duplications, losses, and deep
+coalescences is printed as a comment. Then the species tree is
+printed as a comment, with the internal nodes labeled by its
+identifier, followed by a tab-delimited table with the number of
+duplications, losses, and deep coalescences on each branch of the
+species tree, summed over all gene trees.
+
+By default, the terminals of the gene trees must have the names of
+the species. The option -m, or --map, can be used to define a file
+that maps the terminals of the gene trees to the species. Each line
+of the file has the name of a terminal of the gene trees, and the
+name of its species, for example:
+
+	Homo_sapiens_a Homo_sapiens
+	Homo_sapiens_b Homo_sapiens
+
+Lines starting with '#' are ignored. Terminals not in the file use
+its own name as the species.
+
+Trees can be in NEXUS format (with or without a translation table) or
+in parenthetical format, one tree after the other.
+
+Options are:
+
+    -m <file>
+    --map <file>
+      If defined, the terminals of the gene trees will be mapped to
+      the species using the indicated file.
+
+    -s <treefile>
+    --species <treefile>
+      The file with the species tree. It is a required option.
+
+    <treefile>...
+      One or more files with gene trees. At least one file is
+      required.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var mapfile string
+var speciesfile string
+
+func register(c *cmdapp.Command) {
+	c.Flag.StringVar(&mapfile, "map", "", "")
+	c.Flag.StringVar(&mapfile, "m", "", "")
+	c.Flag.StringVar(&speciesfile, "species", "", "")
+	c.Flag.StringVar(&speciesfile, "s", "", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if speciesfile == "" {
+		return errors.Errorf("%s: expecting a species tree", c.Name())
+	}
+	if len(args) == 0 {
+		return errors.Errorf("%s: expecting a tree filename", c.Name())
+	}
+
+	f, err := os.Open(speciesfile)
+	if err != nil {
+		return errors.Wrapf(err, "%s: while opening %s", c.Name(), speciesfile)
+	}
+	sp, err := tree.Read(f)
+	f.Close()
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing tree", c.Name())
+	}
+
+	var smap map[string]string
+	if mapfile != "" {
+		smap, err = readMap(mapfile)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while reading %s", c.Name(), mapfile)
+		}
+	}
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	total := &tree.Reconciliation{
+		Dups:     make(map[*tree.Node]int),
+		Losses:   make(map[*tree.Node]int),
+		DeepCoal: make(map[*tree.Node]int),
+	}
+	fmt.Printf("# tree\tdups\tlosses\tdeepcoal\n")
+	i := 0
+	for _, fn := range args {
+		f, err := os.Open(fn)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), fn)
+		}
+		r := tree.NewReader(f)
+		for r.Scan() {
+			i++
+			gt := r.Tree()
+			rc, err := tree.Reconcile(sp, gt, smap)
+			if err != nil {
+				f.Close()
+				return errors.Wrapf(err, "%s: %s: tree %d", c.Name(), fn, i)
+			}
+			for n, v := range rc.Dups {
+				total.Dups[n] += v
+			}
+			for n, v := range rc.Losses {
+				total.Losses[n] += v
+			}
+			for n, v := range rc.DeepCoal {
+				total.DeepCoal[n] += v
+			}
+			name := gt.Name
+			if name == "" {
+				name = strconv.Itoa(i)
+			}
+			d, l, x := rc.Totals()
+			fmt.Printf("# %s\t%d\t%d\t%d\n", name, d, l, x)
+		}
+		err = r.Err()
+		f.Close()
+		if err != nil {
+			return errors.Wrapf(err, "%s: while reading %s", c.Name(), fn)
+		}
+	}
+	if i == 0 {
+		return errors.Errorf("%s: no gene trees found", c.Name())
+	}
+	d, l, x := total.Totals()
+	fmt.Printf("# Total\t%d\t%d\t%d\n", d, l, x)
+
+	// pre-order identifiers of the nodes
+	ids := make(map[*tree.Node]string)
+	next := len(sp.Terms()) + 1
+	for _, n := range sp.Nodes() {
+		if n.IsTerm() {
+			ids[n] = n.Name
+			continue
+		}
+		ids[n] = strconv.Itoa(next)
+		n.Label = ids[n]
+		next++
+	}
+	fmt.Printf("# ")
+	sp.Write(os.Stdout, true)
+	fmt.Printf("\n")
+
+	fmt.Printf("node\tdups\tlosses\tdeepcoal\n")
+	for _, n := range sp.Nodes() {
+		fmt.Printf("%s\t%d\t%d\t%d\n", ids[n], total.Dups[n], total.Losses[n], total.DeepCoal[n])
+	}
+	return nil
+}
+
+// ReadMap reads a file
+// that maps gene terminals to species.
+func readMap(name string) (map[string]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	smap := make(map[string]string)
+	s := bufio.NewScanner(f)
+	for ln := 1; s.Scan(); ln++ {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fs := strings.Fields(line)
+		if len(fs) != 2 {
+			return nil, errors.Errorf("line %d: expecting a terminal and a species", ln)
+		}
+		smap[fs[0]] = fs[1]
+	}
+	return smap, s.Err()
+}
@@ -0,0 +1,320 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package recons implements the t.recons command,
+// i.e. summarize ancestral reconstructions on a set of trees.
+package recons
+
+import (
+	"fmt"
+	"math/bits"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/likelihood"
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/parsimony"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `t.recons [-m|--method <method>] [-c|--chars <list>]
+		[-f|--freq <value>] [-t|--tree <treefile>] <dataset>`,
+	Short: "summarize ancestral reconstructions on a set of trees",
+	Long: `
+Command t.recons reads a set of trees in parenthetical or NEXUS format,
+reconstructs the ancestral states of the characters on each tree, and
+prints, for each clade found in the trees, the frequency of each state
+of each character.
+
+As each tree can have different clades, the frequency of a state is
+calculated over the trees that have the clade. Only the clades found in
+more than the indicated proportion of trees will be printed.
+
+By default, the states are reconstructed using parsimony, and ambiguous
+reconstructions are resolved using accelerated transformation
+(acctran). With the -m, or --method option, other methods can be
+used:
+
+    acctran  parsimony, accelerated transformation (changes are placed
+             as close to the root as possible).
+    deltran  parsimony, delayed transformation (changes are placed as
+             far as possible from the root).
+    mpr      parsimony, final state sets, i.e. all the states found in
+             at least one most parsimonious reconstruction. In this
+             case the frequency of a state is the proportion of trees
+             in which the state is in the set of the clade, so the
+             frequencies of a character can sum to more than 1.
+    ml       maximum likelihood, marginal reconstruction, using the
+             branch lengths of the trees (or 0.01 if the trees do not
+             have branch lengths). In this case the frequency of a
+             state is the average of its marginal probability.
+
+The trees will be read from the standard input, unless the option -t
+or --tree is defined with a tree file.
+
+Options are:
+
+    -m <method>
+    --method <method>
+      Set the reconstruction method. Valid values are acctran,
+      deltran, mpr, and ml. Default: acctran.
+
+    -c <list>
+    --chars <list>
+      If set, only the indicated characters will be reported. The
+      characters can be given as a list of numbers, or ranges (e.g.
+      "1-5 8"). The first character is 1.
+
+    -f <value>
+    --freq <value>
+      Set the minimum frequency of a clade to be reported. By default
+      it is 0.5.
+
+    -t <treefile>
+    --tree <treefile>
+      If defined, the trees will be read from the indicated file,
+      instead of the standard input.
+
+    <dataset>
+      The phylogenetic data matrix. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var method string
+var chars string
+var freq float64
+var treefile string
+
+func register(c *cmdapp.Command) {
+	c.Flag.StringVar(&method, "method", "acctran", "")
+	c.Flag.StringVar(&method, "m", "acctran", "")
+	c.Flag.StringVar(&chars, "chars", "", "")
+	c.Flag.StringVar(&chars, "c", "", "")
+	c.Flag.Float64Var(&freq, "freq", 0.5, "")
+	c.Flag.Float64Var(&freq, "f", 0.5, "")
+	c.Flag.StringVar(&treefile, "tree", "", "")
+	c.Flag.StringVar(&treefile, "t", "", "")
+}
+
+// A clade stores the reconstructions
+// of a clade.
+type clade struct {
+	terms  []string
+	trees  int
+	states [][]float64 // frequency of each state in each character
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a dataset filename", c.Name())
+	}
+
+	ml := false
+	mpr := false
+	var pm parsimony.Method
+	switch method {
+	case "ml":
+		ml = true
+	case "mpr":
+		mpr = true
+	default:
+		var err error
+		pm, err = parsimony.ParseMethod(method)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+	}
+	defer f.Close()
+
+	m, err := matrix.NewMatrix(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+	}
+	var lm *likelihood.Matrix
+	if ml {
+		lm = likelihood.NewFromMatrix(m)
+	}
+
+	sel := make([]int, len(m.Kind))
+	for i := range sel {
+		sel[i] = i
+	}
+	if chars != "" {
+		sel, err = matrix.ParseRange(chars, len(m.Kind))
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	}
+
+	// number of states of each character
+	size := make([]int, len(sel))
+	for i, ch := range sel {
+		size[i] = states(m, ch)
+		if ml {
+			size[i] = lm.Model(ch).States()
+		}
+	}
+
+	tf := os.Stdin
+	if treefile != "" {
+		tf, err = os.Open(treefile)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), treefile)
+		}
+		defer tf.Close()
+	}
+
+	clades := make(map[string]*clade)
+	add := func(terms []string) *clade {
+		sort.Strings(terms)
+		k := strings.Join(terms, " ")
+		cl, ok := clades[k]
+		if !ok {
+			cl = &clade{
+				terms:  terms,
+				states: make([][]float64, len(sel)),
+			}
+			for i := range cl.states {
+				cl.states[i] = make([]float64, size[i])
+			}
+			clades[k] = cl
+		}
+		cl.trees++
+		return cl
+	}
+
+	r := tree.NewReader(tf)
+	trees := 0
+	for r.Scan() {
+		tp := r.Tree()
+		trees++
+		if ml {
+			tr, err := likelihood.FromTopology(tp, lm)
+			if err != nil {
+				return errors.Wrapf(err, "%s: tree %d", c.Name(), trees)
+			}
+			marg := tr.Marginals()
+			for _, n := range tr.Nodes {
+				if n.Term != nil {
+					continue
+				}
+				cl := add(likeTerms(n))
+				for i, ch := range sel {
+					for s, p := range marg[n][ch] {
+						cl.states[i][s] += p
+					}
+				}
+			}
+			continue
+		}
+		tr, err := parsimony.FromTopology(tp, m)
+		if err != nil {
+			return errors.Wrapf(err, "%s: tree %d", c.Name(), trees)
+		}
+		var rec map[*parsimony.Node][]uint32
+		if mpr {
+			rec = tr.FinalStates()
+		} else {
+			rec = tr.Reconstruct(pm)
+		}
+		for _, n := range tr.Nodes {
+			if n.Term != nil {
+				continue
+			}
+			cl := add(parsTerms(n))
+			for i, ch := range sel {
+				for st := rec[n][ch]; st != 0; st &= st - 1 {
+					cl.states[i][bits.TrailingZeros32(st)]++
+				}
+			}
+		}
+	}
+	if err := r.Err(); err != nil {
+		return errors.Wrapf(err, "%s: when parsing trees", c.Name())
+	}
+	if trees == 0 {
+		return errors.Errorf("%s: no trees found", c.Name())
+	}
+
+	ls := make([]*clade, 0, len(clades))
+	for _, cl := range clades {
+		if float64(cl.trees)/float64(trees) <= freq {
+			continue
+		}
+		ls = append(ls, cl)
+	}
+	sort.Slice(ls, func(i, j int) bool {
+		if len(ls[i].terms) != len(ls[j].terms) {
+			return len(ls[i].terms) > len(ls[j].terms)
+		}
+		if ls[i].trees != ls[j].trees {
+			return ls[i].trees > ls[j].trees
+		}
+		return strings.Join(ls[i].terms, " ") < strings.Join(ls[j].terms, " ")
+	})
+
+	fmt.Printf("# Trees: %d\n", trees)
+	fmt.Printf("# Method: %s\n", method)
+	for i, cl := range ls {
+		fmt.Printf("\n# Clade %d: freq %.3f: %s\n", i+1, float64(cl.trees)/float64(trees), strings.Join(cl.terms, " "))
+		fmt.Printf("# char\tstates\n")
+		for j, ch := range sel {
+			fmt.Printf("%d", ch+1)
+			for s, v := range cl.states[j] {
+				if v == 0 {
+					continue
+				}
+				fmt.Printf("\t%s:%.3f", m.Kind[ch].Symbol(s), v/float64(cl.trees))
+			}
+			fmt.Printf("\n")
+		}
+	}
+	return nil
+}
+
+// States returns the number of states
+// of a character,
+// including the gap state,
+// if gaps are a state.
+func states(m *matrix.Matrix, ch int) int {
+	unk := matrix.Unknown(m.Kind[ch])
+	if m.Kind[ch] == matrix.DNA && m.GapMode() == matrix.GapState {
+		unk |= matrix.Gap
+	}
+	return bits.Len32(unk)
+}
+
+// ParsTerms returns the terminals of a node
+// of a parsimony tree.
+func parsTerms(n *parsimony.Node) []string {
+	if n.Term != nil {
+		return []string{n.Term.Name}
+	}
+	return append(parsTerms(n.Left), parsTerms(n.Right)...)
+}
+
+// LikeTerms returns the terminals of a node
+// of a likelihood tree.
+func likeTerms(n *likelihood.Node) []string {
+	if n.Term != nil {
+		return []string{n.Term.Name}
+	}
+	return append(likeTerms(n.Left), likeTerms(n.Right)...)
+}
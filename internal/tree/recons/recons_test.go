@@ -0,0 +1,67 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package recons
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/matrix"
+)
+
+var protBlob = `
+> protein
+Out MKLV
+A   MKWY
+B   MKWY
+C   ARLV
+D   ARLV
+`
+
+func TestStates(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader("> morpho\nOut 0\nA 1\n> dna\nOut A\nA -\n> protein\nOut M\nA K\n"))
+	if err != nil {
+		t.Fatalf("recons: states: unexpected error: %v", err)
+	}
+	for ch, want := range []int{8, 4, 20} {
+		if n := states(m, ch); n != want {
+			t.Errorf("recons: states: character %d: %d states, want %d", ch+1, n, want)
+		}
+	}
+	if err := m.SetGapMode(matrix.GapState); err != nil {
+		t.Fatalf("recons: states: unexpected error: %v", err)
+	}
+	if n := states(m, 1); n != 5 {
+		t.Errorf("recons: states: gap state: %d states, want %d", n, 5)
+	}
+}
+
+func TestProtein(t *testing.T) {
+	mf, err := ioutil.TempFile("", "prot")
+	if err != nil {
+		t.Fatalf("recons: protein: unexpected error: %v", err)
+	}
+	defer os.Remove(mf.Name())
+	mf.WriteString(protBlob)
+	mf.Close()
+
+	tf, err := ioutil.TempFile("", "trees")
+	if err != nil {
+		t.Fatalf("recons: protein: unexpected error: %v", err)
+	}
+	defer os.Remove(tf.Name())
+	tf.WriteString("(Out,((A,B),(C,D)));\n")
+	tf.Close()
+
+	defer func() { method, treefile = "acctran", "" }()
+	treefile = tf.Name()
+	for _, method = range []string{"acctran", "mpr", "ml"} {
+		if err := run(cmd, []string{mf.Name()}); err != nil {
+			t.Errorf("recons: protein: %s: unexpected error: %v", method, err)
+		}
+	}
+}
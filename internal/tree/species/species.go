@@ -0,0 +1,161 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package species implements the t.species command,
+// i.e. estimate a species tree from gene trees.
+package species
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/consensus"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `t.species [-b|--branches] [-o|--outgroup <name>]
+		<treefile>...`,
+	Short: "estimate a species tree from gene trees",
+	Long: `
+Command t.species reads one or more files with gene trees, that can
+have different terminals, and estimates the species tree that
+maximizes the quartet score, i.e. the number of quartets (unrooted
+trees of four terminals) of the gene trees displayed by the species
+tree, as in ASTRAL (Mirarab et al. 2014, Bioinformatics 30: i541).
+Under the multispecies coalescent, this is a statistically consistent
+estimate of the species tree.
+
+If there are 9 terminals or less, all the binary trees are evaluated.
+Otherwise, the search starts from the quartet supertree (see t.super)
+and it is improved with NNI branch swapping.
+
+Each internal node of the species tree is labeled with its local
+posterior probability (Sayyari & Mirarab 2016, Mol. Biol. Evol. 33:
+1654), calculated from the frequencies of the three possible
+topologies of the quartets around the branch of the node.
+
+The number of trees, the number of different quartets, and the
+proportion of the weight of the quartets displayed by the species
+tree (the quartet score) are printed as comments, followed by the
+species tree.
+
+If the option -b, or --branches, is set, a table with the support of
+each branch will be printed instead of the tree. The table includes
+the local posterior probability, the mean frequency of the quartets
+of the topology of the tree (q1) and of the two alternative
+topologies (q2 and q3), the length of the branch in coalescent units,
+and the terminals of the clade.
+
+By default, the species tree is rooted on the first terminal of the
+first tree. Use the option -o, or --outgroup, to set a different
+outgroup.
+
+Trees can be in NEXUS format (with or without a translation table) or
+in parenthetical format, one tree after the other.
+
+Options are:
+
+    -b
+    --branches
+      If set, the support of each branch will be printed, instead of
+      the species tree.
+
+    -o <name>
+    --outgroup <name>
+      If defined, the species tree will be rooted on the indicated
+      terminal.
+
+    <treefile>...
+      One or more files with gene trees. At least one file is
+      required.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var branches bool
+var outgroup string
+
+func register(c *cmdapp.Command) {
+	c.Flag.BoolVar(&branches, "branches", false, "")
+	c.Flag.BoolVar(&branches, "b", false, "")
+	c.Flag.StringVar(&outgroup, "outgroup", "", "")
+	c.Flag.StringVar(&outgroup, "o", "", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) == 0 {
+		return errors.Errorf("%s: expecting a tree filename", c.Name())
+	}
+
+	qs := &consensus.QuartetSet{}
+	for _, fn := range args {
+		if err := addTrees(qs, fn); err != nil {
+			return errors.Wrapf(err, "%s: while reading %s", c.Name(), fn)
+		}
+	}
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+
+	st, err := qs.SpeciesTree()
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if outgroup != "" {
+		if err := st.Reroot(outgroup); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		// the clades of the nodes
+		// are changed by the rerooting
+		for _, n := range st.Nodes() {
+			n.Label = ""
+		}
+		for _, b := range qs.LocalPP(st) {
+			b.Node.Label = fmt.Sprintf("%.2f", b.PP)
+		}
+	}
+
+	fmt.Printf("# Trees used: %d\n", qs.Trees())
+	fmt.Printf("# Quartets: %d\n", qs.Len())
+	fmt.Printf("# Quartet score: %.4f\n", qs.Score(st))
+	if branches {
+		fmt.Printf("pp\tq1\tq2\tq3\tlength\tclade\n")
+		for _, b := range qs.LocalPP(st) {
+			fmt.Printf("%.4f\t%.4f\t%.4f\t%.4f\t%.4f\t%s\n", b.PP, b.Freq[0], b.Freq[1], b.Freq[2], b.Length, strings.Join(b.Node.Terms(), " "))
+		}
+		return nil
+	}
+	st.Write(os.Stdout, true)
+	fmt.Printf("\n")
+	return nil
+}
+
+// AddTrees adds the trees of a file to a quartet set.
+func addTrees(qs *consensus.QuartetSet, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := tree.NewReader(f)
+	for r.Scan() {
+		if err := qs.Add(r.Tree()); err != nil {
+			return err
+		}
+	}
+	return r.Err()
+}
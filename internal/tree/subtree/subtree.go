@@ -0,0 +1,117 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package subtree implements the t.subtree command,
+// i.e. extract the subtree of a named clade.
+package subtree
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `t.subtree -d|--defs <file> [-t|--tree <treefile>] <clade>`,
+	Short:     "extract the subtree of a named clade",
+	Long: `
+Command t.subtree reads a set of trees in parenthetical or NEXUS format,
+and prints, for each tree, the subtree rooted at the most recent common
+ancestor of the terminals of the indicated clade.
+
+The clade is defined by its name in a clade definition file. In a
+clade definition file, each line defines a clade, with the name of the
+clade, an equal sign, and the list of terminals of the clade. Lines
+starting with '#' are ignored. For example:
+
+	# clade definitions
+	Primates = Homo_sapiens Lemur_catta
+
+The trees will be read from the standard input, unless the option -t
+or --tree is defined with a tree file.
+
+Options are:
+
+    -d <file>
+    --defs <file>
+      Set the clade definition file. It is a required option.
+
+    -t <treefile>
+    --tree <treefile>
+      If defined, the trees will be read from the indicated file,
+      instead of the standard input.
+
+    <clade>
+      The name of the clade. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var defs string
+var treefile string
+
+func register(c *cmdapp.Command) {
+	c.Flag.StringVar(&defs, "defs", "", "")
+	c.Flag.StringVar(&defs, "d", "", "")
+	c.Flag.StringVar(&treefile, "tree", "", "")
+	c.Flag.StringVar(&treefile, "t", "", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a clade name", c.Name())
+	}
+	if defs == "" {
+		return errors.Errorf("%s: expecting a clade definition file", c.Name())
+	}
+
+	f, err := os.Open(defs)
+	if err != nil {
+		return errors.Wrapf(err, "%s: while opening %s", c.Name(), defs)
+	}
+	defer f.Close()
+	ls, err := tree.ReadCladeDefs(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing %s", c.Name(), defs)
+	}
+	cd, ok := tree.FindClade(ls, args[0])
+	if !ok {
+		return errors.Errorf("%s: clade %s not defined in %s", c.Name(), args[0], defs)
+	}
+
+	tf := os.Stdin
+	if treefile != "" {
+		tf, err = os.Open(treefile)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), treefile)
+		}
+		defer tf.Close()
+	}
+
+	r := tree.NewReader(tf)
+	trees := 0
+	for r.Scan() {
+		t := r.Tree()
+		trees++
+		st, err := t.Subtree(cd.Terms)
+		if err != nil {
+			return errors.Wrapf(err, "%s: tree %d", c.Name(), trees)
+		}
+		st.Write(os.Stdout, true)
+		fmt.Printf("\n")
+	}
+	if err := r.Err(); err != nil {
+		return errors.Wrapf(err, "%s: when parsing trees", c.Name())
+	}
+	return nil
+}
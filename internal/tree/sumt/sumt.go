@@ -0,0 +1,227 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package sumt implements the t.sumt command,
+// i.e. summarize a sample of trees.
+package sumt
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/consensus"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `t.sumt [-a|--annotate] [-b|--burnin <value>]
+		[-c|--clades] [-d|--defs <file>] <treefile>...`,
+	Short: "summarize a sample of trees",
+	Long: `
+Command t.sumt reads one or more files with a sample of trees, for
+example the .t files produced by MrBayes or the .trees files produced
+by BEAST, and prints the majority rule consensus tree of the sample.
+Each clade of the consensus will be labeled with its frequency (i.e.
+the posterior probability of the clade), and the branch lengths will
+be the average of the branch lengths of the clade.
+
+If the option -a, or --annotate, is set, the consensus tree will be
+written in NEXUS format, with each node annotated (as a node comment,
+that can be read by FigTree) with the frequency of the clade (freq),
+the mean and standard deviation of its branch length (length_mean, and
+length_sd), and, if the input trees have support values as labels of
+the internal nodes (for example, trees from different analyses), the
+mean of the support values of the clade (support_mean). In that case,
+each clade will be labeled with its mean support, instead of its
+frequency.
+
+Trees can be in NEXUS format (with or without a translation table) or
+in parenthetical format, one tree after the other. The trees are read
+one at a time, so large samples can be summarized without storing all
+the trees in memory.
+
+Options are:
+
+    -a
+    --annotate
+      If set, the consensus tree will be annotated with the
+      statistics of each clade.
+
+    -b <value>
+    --burnin <value>
+      Set the number of trees discarded at the beginning of each
+      file. If the value is less than 1, it will be interpreted as
+      the proportion of the trees to be discarded. By default it is
+      0.25.
+
+    -c
+    --clades
+      If set, the list of clades with its frequencies will be
+      printed, instead of the consensus tree.
+
+    -d <file>
+    --defs <file>
+      If set, the frequency of each clade defined in the indicated
+      clade definition file will be printed, instead of the
+      consensus tree. In a clade definition file, each line defines
+      a clade, with the name of the clade, an equal sign, and the
+      list of terminals of the clade, for example:
+
+	Ingroup = Homo_sapiens Lemur_catta Mus_musculus
+
+    <treefile>...
+      One or more files with trees. If there are multiple files (for
+      example, from different runs), the burn-in is applied to each
+      file. At least one file is required.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var annotate bool
+var burnin float64
+var clades bool
+var defs string
+
+func register(c *cmdapp.Command) {
+	c.Flag.BoolVar(&annotate, "annotate", false, "")
+	c.Flag.BoolVar(&annotate, "a", false, "")
+	c.Flag.Float64Var(&burnin, "burnin", 0.25, "")
+	c.Flag.Float64Var(&burnin, "b", 0.25, "")
+	c.Flag.BoolVar(&clades, "clades", false, "")
+	c.Flag.BoolVar(&clades, "c", false, "")
+	c.Flag.StringVar(&defs, "defs", "", "")
+	c.Flag.StringVar(&defs, "d", "", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) == 0 {
+		return errors.Errorf("%s: expecting a tree filename", c.Name())
+	}
+	if burnin < 0 {
+		return errors.Errorf("%s: invalid burn-in value %.3f", c.Name(), burnin)
+	}
+
+	set := &consensus.Set{}
+	for _, fn := range args {
+		skip := int(burnin)
+		if burnin < 1 {
+			n, err := countTrees(fn)
+			if err != nil {
+				return errors.Wrapf(err, "%s: while reading %s", c.Name(), fn)
+			}
+			skip = int(float64(n) * burnin)
+		}
+		if err := addTrees(set, fn, skip); err != nil {
+			return errors.Wrapf(err, "%s: while reading %s", c.Name(), fn)
+		}
+	}
+	if set.Trees() == 0 {
+		return errors.Errorf("%s: no trees after burn-in", c.Name())
+	}
+
+	if annotate && defs == "" && !clades {
+		t, err := set.Annotated(0.5)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		t.Name = "consensus"
+		w := tree.NewWriter(os.Stdout, set.Terms())
+		if err := w.Comment(fmt.Sprintf("Trees used: %d", set.Trees())); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		if err := w.Write(t); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		if err := w.Close(); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		return nil
+	}
+
+	fmt.Printf("# Trees used: %d\n", set.Trees())
+	if defs != "" {
+		cds, err := readCladeDefs(defs)
+		if err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+		for _, d := range cds {
+			f, err := set.Support(d.Terms)
+			if err != nil {
+				return errors.Wrapf(err, "%s: clade %s", c.Name(), d.Name)
+			}
+			fmt.Printf("%.4f\t%s\n", f, d.Name)
+		}
+		return nil
+	}
+	if clades {
+		for _, cl := range set.Clades(0) {
+			fmt.Printf("%.4f\t%s\n", set.Freq(cl), strings.Join(cl.Terms, " "))
+		}
+		return nil
+	}
+	t, err := set.Majority(0.5)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	t.Write(os.Stdout, true)
+	fmt.Printf("\n")
+	return nil
+}
+
+// CountTrees returns the number of trees in a file.
+func countTrees(name string) (int, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	r := tree.NewReader(f)
+	n := 0
+	for r.Scan() {
+		r.Tree()
+		n++
+	}
+	return n, r.Err()
+}
+
+// AddTrees adds the trees of a file to a clade set.
+func addTrees(set *consensus.Set, name string, skip int) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := tree.NewReader(f)
+	for i := 0; r.Scan(); i++ {
+		t := r.Tree()
+		if i < skip {
+			continue
+		}
+		if err := set.Add(t); err != nil {
+			return err
+		}
+	}
+	return r.Err()
+}
+
+// ReadCladeDefs reads a clade definition file.
+func readCladeDefs(name string) ([]tree.CladeDef, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return tree.ReadCladeDefs(f)
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package super implements the t.super command,
+// i.e. build a quartet supertree.
+package super
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/consensus"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: `t.super [-o|--outgroup <name>] <treefile>...`,
+	Short:     "build a quartet supertree",
+	Long: `
+Command t.super reads one or more files with trees, for example gene
+trees, that can have different terminals, and builds a supertree
+that combines the quartets (i.e. the unrooted trees of four
+terminals) displayed by the input trees.
+
+Each resolved quartet of each tree is counted, so the weight of a
+quartet is the number of trees that display it. The supertree is
+built with a weighted quartet MaxCut heuristic (Snir & Rao 2010,
+IEEE/ACM TCBB 7: 704): the terminals are split in two groups that
+maximize the weight of the quartets satisfied by the split, minus the
+weight of the quartets violated by the split, and each group is
+resolved recursively. If there is no split with a positive score,
+the terminals are left as a polytomy.
+
+The number of trees, the number of different quartets, and the
+proportion of the weight of the quartets displayed by the supertree
+(the quartet score) are printed as comments, followed by the
+supertree.
+
+By default, the supertree is rooted on the first terminal of the
+first tree. Use the option -o, or --outgroup, to set a different
+outgroup.
+
+Trees can be in NEXUS format (with or without a translation table) or
+in parenthetical format, one tree after the other.
+
+Options are:
+
+    -o <name>
+    --outgroup <name>
+      If defined, the supertree will be rooted on the indicated
+      terminal.
+
+    <treefile>...
+      One or more files with trees. At least one file is required.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var outgroup string
+
+func register(c *cmdapp.Command) {
+	c.Flag.StringVar(&outgroup, "outgroup", "", "")
+	c.Flag.StringVar(&outgroup, "o", "", "")
+}
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) == 0 {
+		return errors.Errorf("%s: expecting a tree filename", c.Name())
+	}
+
+	qs := &consensus.QuartetSet{}
+	for _, fn := range args {
+		if err := addTrees(qs, fn); err != nil {
+			return errors.Wrapf(err, "%s: while reading %s", c.Name(), fn)
+		}
+	}
+
+	st, err := qs.Supertree()
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	if outgroup != "" {
+		if err := st.Reroot(outgroup); err != nil {
+			return errors.Wrap(err, c.Name())
+		}
+	}
+
+	if err := env.Header(os.Stdout); err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	fmt.Printf("# Trees used: %d\n", qs.Trees())
+	fmt.Printf("# Quartets: %d\n", qs.Len())
+	fmt.Printf("# Quartet score: %.4f\n", qs.Score(st))
+	st.Write(os.Stdout, true)
+	fmt.Printf("\n")
+	return nil
+}
+
+// AddTrees adds the trees of a file to a quartet set.
+func addTrees(qs *consensus.QuartetSet, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := tree.NewReader(f)
+	for r.Scan() {
+		if err := qs.Add(r.Tree()); err != nil {
+			return err
+		}
+	}
+	return r.Err()
+}
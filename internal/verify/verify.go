@@ -0,0 +1,214 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package verify implements the verify command,
+// i.e. check the consistency of the scores of a dataset.
+package verify
+
+import (
+	"fmt"
+	"math"
+	"os"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/likelihood"
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/parsimony"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+var cmd = &cmdapp.Command{
+	UsageLine: "verify [-t|--tree <treefile>] <dataset>",
+	Short:     "check the consistency of the scores of a dataset",
+	Long: `
+Command verify reads a dataset and a tree, and checks that the scores
+of the tree follow the expected invariants. It is useful to catch
+problems in the data (or in the implementation) early. The checks
+are:
+
+    p.steps    parsimony length equals the sum of the steps of each
+               character
+    p.rotate   parsimony length is the same after rotating all nodes
+    p.reroot   parsimony length is the same after rooting the tree on
+               each terminal
+    l.rotate   likelihood is the same after rotating all nodes
+    l.reroot   likelihood is the same after rooting the tree on each
+               terminal
+
+For each check, the output is "ok" or "FAIL", and the name of the
+check. If the check fails, the obtained and expected values are
+printed. If any check fails, the command ends with an error.
+
+The tree will be read from the standard input, unless the option -t,
+or --tree, is defined with a tree file. Polytomies are resolved at
+random. If the tree does not have branch lengths, a default length of
+0.01 will be used for the likelihood checks.
+
+Options are:
+
+    -t <treefile>
+    --tree <treefile>
+      If defined, the tree will be read from the indicated file,
+      instead of the standard input.
+
+    <dataset>
+      The phylogenetic data matrix. It is a required option.
+	`,
+	Run:           run,
+	RegisterFlags: register,
+}
+
+func init() {
+	cmdapp.Add(cmd)
+}
+
+var treefile string
+
+func register(c *cmdapp.Command) {
+	c.Flag.StringVar(&treefile, "tree", "", "")
+	c.Flag.StringVar(&treefile, "t", "", "")
+}
+
+// Tolerance of likelihood comparisons.
+const tolerance = 1e-6
+
+func run(c *cmdapp.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.Errorf("%s: expecting a dataset filename", c.Name())
+	}
+
+	f, err := os.Open(args[0])
+	if err != nil {
+		return errors.Wrapf(err, "%s: while opening %s", c.Name(), args[0])
+	}
+	defer f.Close()
+
+	m, err := matrix.NewMatrix(f)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing matrix", c.Name())
+	}
+
+	tf := os.Stdin
+	if treefile != "" {
+		tf, err = os.Open(treefile)
+		if err != nil {
+			return errors.Wrapf(err, "%s: while opening %s", c.Name(), treefile)
+		}
+		defer tf.Close()
+	}
+	t, err := tree.Read(tf)
+	if err != nil {
+		return errors.Wrapf(err, "%s: when parsing tree", c.Name())
+	}
+
+	pars, err := parsimony.FromTopology(t, m)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	lm := likelihood.NewFromMatrix(m)
+	like, err := likelihood.FromTopology(t, lm)
+	if err != nil {
+		return errors.Wrap(err, c.Name())
+	}
+	// use explicit branch lengths
+	// so they are kept after rerooting
+	t = like.Topology()
+	fmt.Printf("# Tree length: %d\n", pars.Cost())
+	fmt.Printf("# Tree -log Likelihood: %.6f\n", -like.Like())
+
+	failed := 0
+	check := func(name string, err error) {
+		if err != nil {
+			failed++
+			fmt.Printf("FAIL\t%s\t%v\n", name, err)
+			return
+		}
+		fmt.Printf("ok\t%s\n", name)
+	}
+
+	// parsimony
+	sum := 0
+	for _, s := range pars.Steps() {
+		sum += s
+	}
+	if sum != pars.Cost() {
+		err = errors.Errorf("sum of steps %d, want %d", sum, pars.Cost())
+	}
+	check("p.steps", err)
+
+	check("p.rotate", parsLen(rotate(t), m, pars.Cost()))
+
+	err = nil
+	for _, term := range t.Terms() {
+		rt := t.Copy()
+		if err = rt.Reroot(term); err != nil {
+			break
+		}
+		if err = parsLen(rt, m, pars.Cost()); err != nil {
+			err = errors.Wrapf(err, "rooted on %s", term)
+			break
+		}
+	}
+	check("p.reroot", err)
+
+	// likelihood
+	check("l.rotate", likeScore(rotate(t), lm, like.Like()))
+
+	err = nil
+	for _, term := range t.Terms() {
+		rt := t.Copy()
+		if err = rt.Reroot(term); err != nil {
+			break
+		}
+		if err = likeScore(rt, lm, like.Like()); err != nil {
+			err = errors.Wrapf(err, "rooted on %s", term)
+			break
+		}
+	}
+	check("l.reroot", err)
+
+	if failed > 0 {
+		return errors.Errorf("%s: %d checks failed", c.Name(), failed)
+	}
+	return nil
+}
+
+// Rotate returns a copy of a tree
+// with the order of the descendants
+// of each node reversed.
+func rotate(t *tree.Tree) *tree.Tree {
+	c := t.Copy()
+	for _, n := range c.Nodes() {
+		for i, j := 0, len(n.Desc)-1; i < j; i, j = i+1, j-1 {
+			n.Desc[i], n.Desc[j] = n.Desc[j], n.Desc[i]
+		}
+	}
+	return c
+}
+
+// ParsLen checks the parsimony length of a tree.
+func parsLen(t *tree.Tree, m *matrix.Matrix, want int) error {
+	tr, err := parsimony.FromTopology(t, m)
+	if err != nil {
+		return err
+	}
+	if tr.Cost() != want {
+		return errors.Errorf("length %d, want %d", tr.Cost(), want)
+	}
+	return nil
+}
+
+// LikeScore checks the likelihood of a tree.
+func likeScore(t *tree.Tree, m *likelihood.Matrix, want float64) error {
+	tr, err := likelihood.FromTopology(t, m)
+	if err != nil {
+		return err
+	}
+	if l := tr.Like(); math.Abs(l-want) > tolerance*math.Abs(want) {
+		return errors.Errorf("log likelihood %.6f, want %.6f", l, want)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package wdsearch implements the repeated Wagner-Dayoff searches
+// used by the sensitivity analyses
+// (p.sitedel, p.taxjack, and p.window),
+// in which the same search
+// is made on many variants of a matrix,
+// and the trees are written,
+// with its length,
+// in a tree file.
+package wdsearch
+
+import (
+	"fmt"
+
+	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/parsimony"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+// Options are the options
+// of a Wagner-Dayoff search.
+type Options struct {
+	Replicates int    // number of Wagner trees
+	Swap       string // branch swapping algorithm
+
+	sw parsimony.Swap
+}
+
+// Register sets the options as flags of a command.
+func (o *Options) Register(c *cmdapp.Command) {
+	c.Flag.IntVar(&o.Replicates, "replicates", 10, "")
+	c.Flag.IntVar(&o.Replicates, "r", 10, "")
+	c.Flag.StringVar(&o.Swap, "swap", "spr", "")
+}
+
+// Check validates the options.
+// It must be called before Search.
+func (o *Options) Check() error {
+	if o.Replicates < 1 {
+		return errors.Errorf("invalid number of replicates: %d", o.Replicates)
+	}
+	sw, err := parsimony.ParseSwap(o.Swap)
+	if err != nil {
+		return err
+	}
+	o.sw = sw
+	return nil
+}
+
+// Search returns the shortest tree
+// found in a set of Wagner-Dayoff replicates,
+// made on the site patterns of the matrix.
+func (o *Options) Search(m *matrix.Matrix) *parsimony.Tree {
+	m, _ = m.Compress(nil)
+	var best *parsimony.Tree
+	for i := 0; i < o.Replicates; i++ {
+		tr := parsimony.Wagner(m)
+		tr.SwapLimit(o.sw, nil, nil)
+		if best == nil || tr.Cost() < best.Cost() {
+			best = tr
+		}
+	}
+	best.Laderize(false)
+	return best
+}
+
+// Create creates a tree file
+// for the terminals of a matrix,
+// with the environment of the analysis
+// as a comment.
+// If the name is empty,
+// it returns a nil writer.
+func Create(name string, m *matrix.Matrix) (*tree.Writer, error) {
+	if name == "" {
+		return nil, nil
+	}
+	ls := make([]string, 0, len(m.Names))
+	for nm := range m.Names {
+		ls = append(ls, nm)
+	}
+	tw, err := tree.Create(name, ls)
+	if err != nil {
+		return nil, err
+	}
+	if err := tw.Comment(env.String()); err != nil {
+		tw.Close()
+		return nil, err
+	}
+	return tw, nil
+}
+
+// Write writes a tree with its length,
+// if the tree writer is defined.
+func Write(tw *tree.Writer, tr *parsimony.Tree, name string) error {
+	if tw == nil {
+		return nil
+	}
+	tp := tr.Topology()
+	tp.Name = name
+	tp.Root.Comment = fmt.Sprintf("length=%d", tr.Cost())
+	return tw.Write(tp)
+}
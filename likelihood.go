@@ -6,5 +6,19 @@ package main
 
 import (
 	// initialize likelihood sub-commands
+	_ "github.com/js-arias/ramita/internal/likelihood/clock"
+	_ "github.com/js-arias/ramita/internal/likelihood/contrasts"
+	_ "github.com/js-arias/ramita/internal/likelihood/genetrees"
+	_ "github.com/js-arias/ramita/internal/likelihood/jplace"
 	_ "github.com/js-arias/ramita/internal/likelihood/like"
+	_ "github.com/js-arias/ramita/internal/likelihood/models"
+	_ "github.com/js-arias/ramita/internal/likelihood/pagel"
+	_ "github.com/js-arias/ramita/internal/likelihood/parts"
+	_ "github.com/js-arias/ramita/internal/likelihood/pipeline"
+	_ "github.com/js-arias/ramita/internal/likelihood/place"
+	_ "github.com/js-arias/ramita/internal/likelihood/puzzle"
+	_ "github.com/js-arias/ramita/internal/likelihood/ratetest"
+	_ "github.com/js-arias/ramita/internal/likelihood/refpkg"
+	_ "github.com/js-arias/ramita/internal/likelihood/rell"
+	_ "github.com/js-arias/ramita/internal/likelihood/search"
 )
@@ -0,0 +1,116 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+// Marginals returns the marginal probability
+// of each state,
+// for each character,
+// on each internal node of the tree,
+// i.e. the marginal ancestral reconstruction.
+// With a discrete gamma model,
+// the probabilities are summed
+// over the rate categories.
+//
+// In low-memory mode
+// (see Matrix.SetCheckpoints),
+// the conditionals of all nodes
+// are stored during the reconstruction.
+func (tr *Tree) Marginals() map[*Node][]Conditional {
+	defer tr.expand()()
+
+	marg := make(map[*Node][]Conditional, len(tr.Nodes))
+	for k := range tr.M.rateCats() {
+		above := make(map[*Node][]Conditional, len(tr.Nodes))
+
+		// at the root,
+		// the probability from above
+		// is the frequency of each state
+		rootAbove := make([]Conditional, len(tr.Root.Cond))
+		for i, c := range tr.Root.Cond {
+			md := tr.M.Model(i)
+			rootAbove[i] = make(Conditional, len(c))
+			for s := range c {
+				rootAbove[i][s] = md.Freq(s)
+			}
+		}
+		above[tr.Root] = rootAbove
+		tr.Root.Left.up(tr.Root.Right, tr.M, k, above)
+		tr.Root.Right.up(tr.Root.Left, tr.M, k, above)
+
+		for n, ab := range above {
+			mg, ok := marg[n]
+			if !ok {
+				mg = make([]Conditional, len(n.Cond))
+				for i, c := range n.Cond {
+					mg[i] = make(Conditional, len(c))
+				}
+				marg[n] = mg
+			}
+			for i := range mg {
+				for s, l := range n.catCond(tr.M, i, k) {
+					mg[i][s] += ab[i][s] * l
+				}
+			}
+		}
+	}
+
+	for _, mg := range marg {
+		normalize(mg)
+	}
+	return marg
+}
+
+// Up calculates the probability from above
+// of a node and its descendants,
+// in a rate category.
+func (n *Node) up(sister *Node, m *Matrix, k int, above map[*Node][]Conditional) {
+	if n.Term != nil {
+		return
+	}
+	rate := m.rateCats()[k]
+	pa := above[n.Anc]
+	ab := make([]Conditional, len(n.Cond))
+	for i, c := range n.Cond {
+		md := m.Model(i)
+		sc := sister.catCond(m, i, k)
+		a := make(Conditional, len(c))
+		for x := range c {
+			p := float64(0)
+			for y, l := range sc {
+				p += md.Prob(x, y, sister.Len*rate) * l
+			}
+			a[x] = pa[i][x] * p
+		}
+		ab[i] = make(Conditional, len(c))
+		for z := range c {
+			p := float64(0)
+			for x, v := range a {
+				p += v * md.Prob(x, z, n.Len*rate)
+			}
+			ab[i][z] = p
+		}
+	}
+	above[n] = ab
+	n.Left.up(n.Right, m, k, above)
+	n.Right.up(n.Left, m, k, above)
+}
+
+// Normalize scales the probabilities
+// of each character
+// so they sum to 1.
+func normalize(mg []Conditional) {
+	for _, c := range mg {
+		sum := float64(0)
+		for _, v := range c {
+			sum += v
+		}
+		if sum == 0 {
+			continue
+		}
+		for s := range c {
+			c[s] /= sum
+		}
+	}
+}
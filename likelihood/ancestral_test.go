@@ -0,0 +1,53 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/tree"
+)
+
+var ancBlob = `
+> morpho
+A 0
+B 0
+C 1
+D 1
+`
+
+func TestMarginals(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(ancBlob))
+	if err != nil {
+		t.Fatalf("likelihood: marginals: unexpected error while reading matrix: %v", err)
+	}
+	tp, err := tree.Read(strings.NewReader("((A:0.1,B:0.1):0.1,(C:0.1,D:0.1):0.1);"))
+	if err != nil {
+		t.Fatalf("likelihood: marginals: unexpected error while reading tree: %v", err)
+	}
+	tr, err := FromTopology(tp, m)
+	if err != nil {
+		t.Fatalf("likelihood: marginals: unexpected error: %v", err)
+	}
+	marg := tr.Marginals()
+	for _, n := range tr.Nodes {
+		if n.Term != nil {
+			continue
+		}
+		sum := marg[n][0][0] + marg[n][0][1]
+		if math.Abs(sum-1) > 0.000001 {
+			t.Errorf("likelihood: marginals: sum of probabilities %.6f, want %.6f", sum, 1.0)
+		}
+	}
+	if p := marg[tr.Root][0][0]; math.Abs(p-0.5) > 0.000001 {
+		t.Errorf("likelihood: marginals: root: probability %.6f, want %.6f", p, 0.5)
+	}
+	ab := tr.Root.Left
+	if p := marg[ab][0][0]; p < 0.9 {
+		t.Errorf("likelihood: marginals: node AB: probability of state 0: %.6f, want > %.6f", p, 0.9)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import "sort"
+
+// A Backend implements the linear algebra operations
+// used by the default Kernel
+// to update the conditional likelihoods
+// of a node.
+//
+// Conditionals of the characters
+// with the same number of states
+// are stored in a single block
+// of contiguous memory,
+// as a row-major patterns × states matrix
+// (i.e. a column-major states × patterns matrix).
+// Then, the conditionals of a node
+// are updated in batches,
+// multiplying the conditionals of each descendant
+// by the transition matrix of its branch,
+// and taking the element-wise product
+// of both results.
+type Backend interface {
+	// Mul sets dst as the product of a and b,
+	// a is a row-major r×k matrix,
+	// and b is a row-major k×c matrix.
+	Mul(dst, a, b []float64, r, k, c int)
+
+	// MulElem sets dst
+	// as the element-wise product of a and b.
+	MulElem(dst, a, b []float64)
+}
+
+// GoBackend is the reference implementation
+// of a Backend,
+// written in pure Go.
+type GoBackend struct{}
+
+// Mul sets dst as the product of a and b.
+func (GoBackend) Mul(dst, a, b []float64, r, k, c int) {
+	for i := 0; i < r; i++ {
+		row := a[i*k : i*k+k]
+		out := dst[i*c : i*c+c]
+		for j := range out {
+			out[j] = 0
+		}
+		for x, v := range row {
+			if v == 0 {
+				continue
+			}
+			for j, p := range b[x*c : x*c+c] {
+				out[j] += v * p
+			}
+		}
+	}
+}
+
+// MulElem sets dst as the element-wise product
+// of a and b.
+func (GoBackend) MulElem(dst, a, b []float64) {
+	for i := range dst {
+		dst[i] = a[i] * b[i]
+	}
+}
+
+// A batch is a set of characters
+// with the same number of states,
+// whose conditionals are stored
+// in a contiguous block.
+type batch struct {
+	states int   // number of states
+	chars  []int // characters in the batch
+	off    int   // offset of the block
+}
+
+// Layout sets the memory layout
+// of the conditionals.
+func (m *Matrix) layout() {
+	if m.batches != nil {
+		return
+	}
+	byStates := make(map[int][]int)
+	for i := range m.model {
+		st := m.Model(i).States()
+		byStates[st] = append(byStates[st], i)
+	}
+	states := make([]int, 0, len(byStates))
+	for st := range byStates {
+		states = append(states, st)
+	}
+	sort.Ints(states)
+
+	m.batches = make([]batch, 0, len(states))
+	m.pos = make([]int, len(m.model))
+	m.size = 0
+	for _, st := range states {
+		b := batch{
+			states: st,
+			chars:  byStates[st],
+			off:    m.size,
+		}
+		for j, c := range b.chars {
+			m.pos[c] = b.off + j*st
+		}
+		m.size += len(b.chars) * st
+		m.batches = append(m.batches, b)
+	}
+}
+
+// BatchOpt updates the stored conditionals of a node
+// in batches.
+// If id is not empty,
+// only the characters with that model
+// are updated.
+func (n *Node) batchOpt(m *Matrix, id string) {
+	if n.buf == nil {
+		return
+	}
+	n.update(n.buf, m, id)
+}
+
+// Update sets dst as the conditionals of a node,
+// computed from the conditionals of its descendants.
+// With a discrete gamma model,
+// the conditionals of each rate category
+// are stored in its own block,
+// after the block of the previous category.
+func (n *Node) update(dst []float64, m *Matrix, id string) {
+	left := n.Left.conds(m, id)
+	right := n.Right.conds(m, id)
+	for k, rate := range m.rateCats() {
+		cat := k * m.size
+		for _, b := range m.batches {
+			pl := make([]float64, b.states*b.states)
+			pr := make([]float64, b.states*b.states)
+
+			// each run of characters with the same model
+			// is updated as a single operation
+			for start := 0; start < len(b.chars); {
+				mid := m.model[b.chars[start]]
+				end := start + 1
+				for end < len(b.chars) && m.model[b.chars[end]] == mid {
+					end++
+				}
+				if id == "" || id == mid {
+					md := m.mds[mid]
+					r := end - start
+					off := cat + b.off + start*b.states
+					sz := r * b.states
+					kernel.Transition(pl, md, b.states, n.Left.Len*rate)
+					kernel.Transition(pr, md, b.states, n.Right.Len*rate)
+					kernel.Update(dst[off:off+sz], left[off:off+sz], pl, right[off:off+sz], pr, r, b.states)
+				}
+				start = end
+			}
+		}
+	}
+}
+
+// CatCond returns the conditional likelihood
+// of a character
+// in a rate category.
+// The conditionals of the node
+// must be stored.
+func (n *Node) catCond(m *Matrix, c, k int) Conditional {
+	p := k*m.size + m.pos[c]
+	st := m.Model(c).States()
+	return Conditional(n.buf[p : p+st : p+st])
+}
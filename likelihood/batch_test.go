@@ -0,0 +1,121 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestGoBackend(t *testing.T) {
+	a := []float64{1, 2, 3, 4, 5, 6}
+	b := []float64{1, 0, 0, 1, 1, 1}
+	dst := make([]float64, 4)
+	GoBackend{}.Mul(dst, a, b, 2, 3, 2)
+	want := []float64{4, 5, 10, 11}
+	for i, v := range dst {
+		if v != want[i] {
+			t.Errorf("likelihood: backend: mul: element %d: %.2f, want %.2f", i, v, want[i])
+		}
+	}
+
+	GoBackend{}.MulElem(dst, want, want)
+	for i, v := range dst {
+		if v != want[i]*want[i] {
+			t.Errorf("likelihood: backend: mulelem: element %d: %.2f, want %.2f", i, v, want[i]*want[i])
+		}
+	}
+}
+
+func TestBatchOpt(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("likelihood: batch: unexpected error while reading matrix: %v", err)
+	}
+	tr, err := ReadTree(strings.NewReader(treeLenBlob), m)
+	if err != nil {
+		t.Fatalf("likelihood: batch: unexpected error while reading tree: %v", err)
+	}
+	for _, n := range tr.Nodes {
+		if n.Term != nil {
+			continue
+		}
+		for i, c := range n.Cond {
+			md := m.Model(i)
+			for s, v := range c {
+				want := n.Left.condState(md, i, s) * n.Right.condState(md, i, s)
+				if math.Abs(v-want) > 1e-12 {
+					t.Fatalf("likelihood: batch: character %d, state %d: conditional %g, want %g", i, s, v, want)
+				}
+			}
+		}
+	}
+}
+
+// CountKernel is a kernel
+// that counts the number of updates.
+type countKernel struct {
+	Kernel
+	updates int
+}
+
+func (k *countKernel) Update(dst, left, tl, right, tr []float64, patterns, states int) {
+	k.updates++
+	k.Kernel.Update(dst, left, tl, right, tr, patterns, states)
+}
+
+func TestSetKernel(t *testing.T) {
+	k := &countKernel{Kernel: NewKernel(nil)}
+	SetKernel(k)
+	defer SetKernel(nil)
+
+	m, err := NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("likelihood: kernel: unexpected error while reading matrix: %v", err)
+	}
+	tr, err := ReadTree(strings.NewReader(treeLenBlob), m)
+	if err != nil {
+		t.Fatalf("likelihood: kernel: unexpected error while reading tree: %v", err)
+	}
+
+	// a single block of DNA characters
+	// on each internal node
+	if want := (len(tr.Nodes) - 1) / 2; k.updates != want {
+		t.Errorf("likelihood: kernel: %d updates, want %d", k.updates, want)
+	}
+	if math.Abs(tr.Like()+20520.004095) > 0.000001 {
+		t.Errorf("likelihood: kernel: log likelihood %.6f, want %.6f", tr.Like(), -20520.004095)
+	}
+}
+
+func TestKernelUpdate(t *testing.T) {
+	k := NewKernel(nil)
+	id := []float64{1, 0, 0, 1}
+
+	// a large block,
+	// then a small one,
+	// reusing the buffers
+	left := []float64{1, 2, 3, 4, 5, 6}
+	right := []float64{2, 2, 2, 2, 2, 2}
+	dst := make([]float64, 6)
+	k.Update(dst, left, id, right, id, 3, 2)
+	for i, v := range dst {
+		if v != 2*left[i] {
+			t.Errorf("likelihood: kernel: update: value %d = %.2f, want %.2f", i, v, 2*left[i])
+		}
+	}
+	dst = dst[:2]
+	k.Update(dst, left[:2], id, left[:2], id, 1, 2)
+	if dst[0] != 1 || dst[1] != 4 {
+		t.Errorf("likelihood: kernel: update: values %v, want [1 4]", dst)
+	}
+
+	if a := testing.AllocsPerRun(100, func() {
+		k.Update(dst, left[:2], id, left[:2], id, 1, 2)
+	}); a >= 1 {
+		t.Errorf("likelihood: kernel: update: %.2f allocations per update", a)
+	}
+}
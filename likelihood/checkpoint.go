@@ -0,0 +1,101 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+// SetCheckpoints sets a low-memory mode
+// for the trees built with the matrix.
+// In this mode,
+// the conditionals are only stored
+// at the terminals,
+// the root,
+// and the checkpoint nodes,
+// i.e. the internal nodes
+// whose height
+// (the number of nodes to its farthest terminal)
+// is a multiple of levels.
+// The conditionals of the other nodes
+// are recomputed when they are required.
+// If levels is less than 2,
+// the conditionals of all nodes are stored.
+//
+// It should be set
+// before building the trees.
+func (m *Matrix) SetCheckpoints(levels int) {
+	m.checkpoint = levels
+}
+
+// Keep returns true if the conditionals of a node
+// should be stored.
+func (m *Matrix) keep(n *Node) bool {
+	if m.checkpoint < 2 || n.Anc == nil {
+		return true
+	}
+	return n.height%m.checkpoint == 0
+}
+
+// SetHeight sets the height of a node
+// from the height of its descendants.
+func (n *Node) setHeight() {
+	h := n.Left.height
+	if n.Right.height > h {
+		h = n.Right.height
+	}
+	n.height = h + 1
+}
+
+// Conds returns the conditionals of a node.
+// If the conditionals are not stored,
+// they are recomputed.
+func (n *Node) conds(m *Matrix, id string) []float64 {
+	if n.buf != nil {
+		return n.buf
+	}
+	tmp := make([]float64, m.size*len(m.rateCats()))
+	n.update(tmp, m, id)
+	return tmp
+}
+
+// Stored returns the number of nodes
+// with stored conditionals.
+func (tr *Tree) Stored() int {
+	st := 0
+	for _, n := range tr.Nodes {
+		if n.buf != nil {
+			st++
+		}
+	}
+	return st
+}
+
+// Expand stores the conditionals
+// of all the nodes of the tree,
+// and returns a function
+// that restores the checkpoint state.
+func (tr *Tree) expand() func() {
+	var added []*Node
+	for _, n := range tr.Nodes {
+		if n.buf != nil {
+			continue
+		}
+		n.buf = make([]float64, tr.M.size*len(tr.M.rateCats()))
+		for i := range n.Cond {
+			p := tr.M.pos[i]
+			st := tr.M.Model(i).States()
+			n.Cond[i] = Conditional(n.buf[p : p+st : p+st])
+		}
+		added = append(added, n)
+	}
+	if len(added) > 0 {
+		tr.Root.downPass(tr.M)
+	}
+	return func() {
+		for _, n := range added {
+			n.buf = nil
+			for i := range n.Cond {
+				n.Cond[i] = nil
+			}
+		}
+	}
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestCheckpoints(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("likelihood: checkpoints: unexpected error while reading matrix: %v", err)
+	}
+	for _, lv := range []int{2, 3} {
+		m.SetCheckpoints(0)
+		full, err := ReadTree(strings.NewReader(treeLenBlob), m)
+		if err != nil {
+			t.Fatalf("likelihood: checkpoints: unexpected error while reading tree: %v", err)
+		}
+		fm := full.Marginals()
+
+		m.SetCheckpoints(lv)
+		tr, err := ReadTree(strings.NewReader(treeLenBlob), m)
+		if err != nil {
+			t.Fatalf("likelihood: checkpoints: %d levels: unexpected error while reading tree: %v", lv, err)
+		}
+		if tr.Stored() >= len(tr.Nodes) {
+			t.Errorf("likelihood: checkpoints: %d levels: %d stored nodes, want less than %d", lv, tr.Stored(), len(tr.Nodes))
+		}
+		if math.Abs(tr.Like()-full.Like()) > 0.000001 {
+			t.Errorf("likelihood: checkpoints: %d levels: log likelihood %.6f, want %.6f", lv, tr.Like(), full.Like())
+		}
+
+		// ancestral reconstruction
+		mg := tr.Marginals()
+		for i, n := range tr.Nodes {
+			if n.Term != nil {
+				continue
+			}
+			fn := full.Nodes[i]
+			for c, cond := range mg[n] {
+				for s, v := range cond {
+					if math.Abs(v-fm[fn][c][s]) > 1e-9 {
+						t.Fatalf("likelihood: checkpoints: %d levels: node %d, character %d: marginal %.6f, want %.6f", lv, i, c, v, fm[fn][c][s])
+					}
+				}
+			}
+		}
+		if st := tr.Stored(); st >= len(tr.Nodes) {
+			t.Errorf("likelihood: checkpoints: %d levels: after marginals: %d stored nodes", lv, st)
+		}
+
+		// change a branch length
+		n := tr.Nodes[len(tr.Nodes)/2]
+		n.Len *= 2
+		increDown(n.Anc, m)
+		full.Nodes[len(tr.Nodes)/2].Len *= 2
+		increDown(full.Nodes[len(tr.Nodes)/2].Anc, m)
+		if math.Abs(tr.Like()-full.Like()) > 0.000001 {
+			t.Errorf("likelihood: checkpoints: %d levels: changed branch: log likelihood %.6f, want %.6f", lv, tr.Like(), full.Like())
+		}
+	}
+	m.SetCheckpoints(0)
+}
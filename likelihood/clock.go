@@ -0,0 +1,78 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import "math"
+
+// ClockRate estimates a global substitution rate
+// under a strict clock.
+// The current branch lengths of the tree
+// are taken as times,
+// and the rate that maximizes the likelihood
+// of the tree,
+// with branch lengths equal to the times
+// multiplied by the rate,
+// is returned.
+// After the estimation,
+// the branch lengths of the tree
+// are in substitution units.
+func (tr *Tree) ClockRate() float64 {
+	times := make(map[*Node]float64, len(tr.Nodes))
+	max := float64(0)
+	for _, n := range tr.Nodes {
+		times[n] = n.Len
+		if n.Len > max {
+			max = n.Len
+		}
+	}
+	if max == 0 {
+		return 0
+	}
+
+	like := func(lr float64) float64 {
+		r := math.Exp(lr)
+		for _, n := range tr.Nodes {
+			n.Len = times[n] * r
+		}
+		tr.Root.downPass(tr.M)
+		return tr.Like()
+	}
+
+	// golden section search on the log of the rate,
+	// from a maximum branch length of 0.0001
+	// to a maximum branch length of 100
+	a := math.Log(0.0001 / max)
+	b := math.Log(100 / max)
+	g := (math.Sqrt(5) - 1) / 2
+	c := b - g*(b-a)
+	d := a + g*(b-a)
+	lc, ld := like(c), like(d)
+	for b-a > 0.0001 {
+		if lc > ld {
+			b, d, ld = d, c, lc
+			c = b - g*(b-a)
+			lc = like(c)
+			continue
+		}
+		a, c, lc = c, d, ld
+		d = a + g*(b-a)
+		ld = like(d)
+	}
+	r := (a + b) / 2
+	like(r)
+	return math.Exp(r)
+}
+
+// DownPass optimizes a node
+// and all of its descendants
+// for all characters.
+func (n *Node) downPass(m *Matrix) {
+	if n.Term != nil {
+		return
+	}
+	n.Left.downPass(m)
+	n.Right.downPass(m)
+	n.optimize(m)
+}
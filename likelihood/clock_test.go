@@ -0,0 +1,44 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/tree"
+)
+
+func TestClockRate(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("likelihood: clockrate: unexpected error while reading matrix: %v", err)
+	}
+	tp, err := tree.Read(strings.NewReader(treeLenBlob))
+	if err != nil {
+		t.Fatalf("likelihood: clockrate: unexpected error while reading tree: %v", err)
+	}
+	tr, err := FromTopology(tp, m)
+	if err != nil {
+		t.Fatalf("likelihood: clockrate: unexpected error: %v", err)
+	}
+	r0 := tr.ClockRate()
+	like := tr.Like()
+
+	// with the tree scaled by 1/10,
+	// the estimated rate should be 10 times larger
+	tr, _ = FromTopology(tp, m)
+	for _, n := range tr.Nodes {
+		n.Len /= 10
+	}
+	r := tr.ClockRate()
+	if math.Abs(r/r0-10) > 0.1 {
+		t.Errorf("likelihood: clockrate: rate %.6f, want %.6f", r, r0*10)
+	}
+	if math.Abs(tr.Like()-like) > 0.01 {
+		t.Errorf("likelihood: clockrate: likelihood %.6f, want %.6f", tr.Like(), like)
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"math"
+
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+// A Contrast is a phylogenetic independent contrast
+// (Felsenstein 1985, Am. Nat. 125: 1)
+// of a continuous character.
+type Contrast struct {
+	Node  *tree.Node // node of the contrast
+	Raw   float64    // difference between the values of the descendants
+	Var   float64    // expected variance of the contrast
+	Std   float64    // standardized contrast
+	Value float64    // estimated value at the node
+}
+
+// Contrasts returns the independent contrasts
+// of a continuous character
+// on a tree with branch lengths,
+// in post-order.
+// The raw contrast is the difference
+// between the value of the first descendant
+// and the value of the second descendant,
+// and the standardized contrast
+// is the raw contrast
+// divided by the square root of its variance
+// (i.e. the sum of the adjusted branch lengths
+// of the descendants).
+// In a polytomy,
+// the descendants are taken as resolved
+// with branches of length 0,
+// so there is a contrast for each additional descendant.
+func Contrasts(t *tree.Tree, vals map[string]float64) ([]Contrast, error) {
+	cs, _, err := contrasts(t, vals)
+	if err != nil {
+		return nil, errors.Wrap(err, "likelihood: contrasts")
+	}
+	return cs, nil
+}
+
+// Contrasts returns the independent contrasts
+// of a continuous character,
+// and the additional variance
+// of the estimated value at the root.
+func contrasts(t *tree.Tree, vals map[string]float64) ([]Contrast, float64, error) {
+	var cs []Contrast
+	var prune func(n *tree.Node) (x, v float64, err error)
+	prune = func(n *tree.Node) (x, v float64, err error) {
+		if n.IsTerm() {
+			x, ok := vals[n.Name]
+			if !ok {
+				return 0, 0, errors.Errorf("terminal %s without value", n.Name)
+			}
+			return x, n.Len, nil
+		}
+		x, v, err = prune(n.Desc[0])
+		if err != nil {
+			return 0, 0, err
+		}
+		for _, d := range n.Desc[1:] {
+			dx, dv, err := prune(d)
+			if err != nil {
+				return 0, 0, err
+			}
+			vv := v + dv
+			if vv == 0 {
+				return 0, 0, errors.New("sister terminals with branches of length 0")
+			}
+			u := x - dx
+			x = (x*dv + dx*v) / vv
+			v = v * dv / vv
+			cs = append(cs, Contrast{
+				Node:  n,
+				Raw:   u,
+				Var:   vv,
+				Std:   u / math.Sqrt(vv),
+				Value: x,
+			})
+		}
+		if n == t.Root {
+			return x, v, nil
+		}
+		return x, v + n.Len, nil
+	}
+	_, v, err := prune(t.Root)
+	if err != nil {
+		return nil, 0, err
+	}
+	return cs, v, nil
+}
+
+// BrownianLike returns the log likelihood
+// of a continuous character
+// under a Brownian motion model,
+// with the maximum likelihood estimates
+// of the root state,
+// and the rate
+// (the variance, or sigma squared),
+// that is also returned.
+// The likelihood is calculated
+// from the independent contrasts
+// (Felsenstein 1973, Am. J. Hum. Genet. 25: 471).
+func BrownianLike(t *tree.Tree, vals map[string]float64) (logLike, rate float64, err error) {
+	logLike, rate, err = brownianLike(t, vals)
+	if err != nil {
+		return 0, 0, errors.Wrap(err, "likelihood: brownian")
+	}
+	return logLike, rate, nil
+}
+
+// BrownianLike returns the log likelihood
+// of a continuous character
+// under a Brownian motion model,
+// and the rate.
+func brownianLike(t *tree.Tree, vals map[string]float64) (logLike, rate float64, err error) {
+	cs, v, err := contrasts(t, vals)
+	if err != nil {
+		return 0, 0, err
+	}
+	var sumSq, sumLog float64
+	for _, c := range cs {
+		sumSq += c.Std * c.Std
+		sumLog += math.Log(c.Var)
+	}
+	if sumSq == 0 {
+		return 0, 0, errors.New("constant character")
+	}
+	n := float64(len(t.Terms()))
+	rate = sumSq / n
+	logLike = -0.5 * (n*math.Log(2*math.Pi*rate) + sumLog + math.Log(v) + n)
+	return logLike, rate, nil
+}
@@ -0,0 +1,87 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/tree"
+)
+
+func TestContrasts(t *testing.T) {
+	tp, err := tree.Read(strings.NewReader("((A:1,B:1):1,C:2);"))
+	if err != nil {
+		t.Fatalf("likelihood: contrasts: unexpected error: %v", err)
+	}
+	cs, err := Contrasts(tp, map[string]float64{"A": 1, "B": 2, "C": 4})
+	if err != nil {
+		t.Fatalf("likelihood: contrasts: unexpected error: %v", err)
+	}
+	want := []Contrast{
+		{Node: tp.Root.Desc[0], Raw: -1, Var: 2, Std: -1 / math.Sqrt(2), Value: 1.5},
+		{Node: tp.Root, Raw: -2.5, Var: 3.5, Std: -2.5 / math.Sqrt(3.5), Value: 18.0 / 7},
+	}
+	if len(cs) != len(want) {
+		t.Fatalf("likelihood: contrasts: %d contrasts, want %d", len(cs), len(want))
+	}
+	for i, w := range want {
+		c := cs[i]
+		if c.Node != w.Node {
+			t.Errorf("likelihood: contrasts: contrast %d: node %v, want %v", i, c.Node.Terms(), w.Node.Terms())
+		}
+		if math.Abs(c.Raw-w.Raw) > 1e-9 || math.Abs(c.Var-w.Var) > 1e-9 || math.Abs(c.Std-w.Std) > 1e-9 || math.Abs(c.Value-w.Value) > 1e-9 {
+			t.Errorf("likelihood: contrasts: contrast %d: %+v, want %+v", i, c, w)
+		}
+	}
+
+	// in a polytomy,
+	// there is a contrast for each additional descendant
+	pt, _ := tree.Read(strings.NewReader("(A:1,B:1,C:1);"))
+	cs, err = Contrasts(pt, map[string]float64{"A": 1, "B": 2, "C": 4})
+	if err != nil {
+		t.Fatalf("likelihood: contrasts: unexpected error: %v", err)
+	}
+	if len(cs) != 2 {
+		t.Errorf("likelihood: contrasts: %d contrasts, want %d", len(cs), 2)
+	}
+
+	zt, _ := tree.Read(strings.NewReader("((A:0,B:0):1,C:2);"))
+	if _, err := Contrasts(zt, map[string]float64{"A": 1, "B": 2, "C": 4}); err == nil {
+		t.Errorf("likelihood: contrasts: expecting error on branches of length 0")
+	}
+}
+
+func TestBrownianLike(t *testing.T) {
+	tp, err := tree.Read(strings.NewReader("((A:1,B:1):1,C:2);"))
+	if err != nil {
+		t.Fatalf("likelihood: brownian: unexpected error: %v", err)
+	}
+	vals := map[string]float64{"A": 1, "B": 2, "C": 4}
+	l, r, err := BrownianLike(tp, vals)
+	if err != nil {
+		t.Fatalf("likelihood: brownian: unexpected error: %v", err)
+	}
+
+	// values from the multivariate normal
+	// with covariance matrix
+	// [[2 1 0] [1 2 0] [0 0 2]]
+	wantR := 16.0 / 21
+	wantL := -0.5 * (3*math.Log(2*math.Pi*wantR) + math.Log(6) + 3)
+	if math.Abs(r-wantR) > 1e-9 {
+		t.Errorf("likelihood: brownian: rate %.6f, want %.6f", r, wantR)
+	}
+	if math.Abs(l-wantL) > 1e-9 {
+		t.Errorf("likelihood: brownian: log likelihood %.6f, want %.6f", l, wantL)
+	}
+
+	if _, _, err := BrownianLike(tp, map[string]float64{"A": 1, "B": 2}); err == nil {
+		t.Errorf("likelihood: brownian: expecting error on missing value")
+	}
+	if _, _, err := BrownianLike(tp, map[string]float64{"A": 1, "B": 1, "C": 1}); err == nil {
+		t.Errorf("likelihood: brownian: expecting error on constant character")
+	}
+}
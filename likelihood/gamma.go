@@ -0,0 +1,244 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// GammaRates returns the relative rates
+// of a discrete gamma distribution
+// with a given shape (alpha),
+// and number of categories,
+// i.e. the mean rate of each category
+// (Yang 1994, J. Mol. Evol. 39: 306).
+// The mean of the rates is 1.
+func GammaRates(alpha float64, cats int) []float64 {
+	if cats < 2 {
+		return []float64{1}
+	}
+
+	// the gamma distribution has shape alpha
+	// and rate alpha,
+	// so its mean is 1
+	cut := make([]float64, cats+1)
+	for k := 1; k < cats; k++ {
+		cut[k] = gammaQuantile(alpha, float64(k)/float64(cats))
+	}
+	cut[cats] = math.Inf(1)
+
+	rates := make([]float64, cats)
+	prev := float64(0)
+	sum := float64(0)
+	for k := range rates {
+		p := float64(1)
+		if k < cats-1 {
+			p = regGamma(alpha+1, cut[k+1]*alpha)
+		}
+		rates[k] = (p - prev) * float64(cats)
+		prev = p
+		sum += rates[k]
+	}
+
+	// normalize to remove numerical errors
+	for k := range rates {
+		rates[k] *= float64(cats) / sum
+	}
+	return rates
+}
+
+// RegGamma returns the regularized
+// lower incomplete gamma function P(a, x).
+func regGamma(a, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	lg, _ := math.Lgamma(a)
+	if x < a+1 {
+		// series expansion
+		sum := 1 / a
+		del := sum
+		for n := 1; n < 1000; n++ {
+			del *= x / (a + float64(n))
+			sum += del
+			if math.Abs(del) < math.Abs(sum)*1e-14 {
+				break
+			}
+		}
+		return sum * math.Exp(-x+a*math.Log(x)-lg)
+	}
+
+	// continued fraction (modified Lentz method)
+	const tiny = 1e-300
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for i := 1; i < 1000; i++ {
+		an := -float64(i) * (float64(i) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		del := d * c
+		h *= del
+		if math.Abs(del-1) < 1e-14 {
+			break
+		}
+	}
+	return 1 - h*math.Exp(-x+a*math.Log(x)-lg)
+}
+
+// GammaQuantile returns the quantile
+// of a probability p
+// of a gamma distribution
+// with shape alpha and rate alpha.
+func gammaQuantile(alpha, p float64) float64 {
+	lo, hi := float64(0), float64(1)
+	for regGamma(alpha, hi*alpha) < p {
+		hi *= 2
+	}
+	for i := 0; i < 200; i++ {
+		mid := (lo + hi) / 2
+		if regGamma(alpha, mid*alpha) < p {
+			lo = mid
+		} else {
+			hi = mid
+		}
+		if hi-lo < 1e-12*hi {
+			break
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// SetGamma sets a discrete gamma model
+// of rate heterogeneity among characters,
+// with the indicated number of categories,
+// and shape (alpha).
+// If the number of categories is less than 2,
+// all the characters evolve at the same rate.
+//
+// As the number of categories
+// changes the memory layout of the conditionals,
+// it should be set
+// before building the trees.
+// To change alpha on a tree,
+// use Tree.SetAlpha.
+func (m *Matrix) SetGamma(cats int, alpha float64) error {
+	if cats < 2 {
+		if m.rates != nil {
+			m.batches = nil
+		}
+		m.rates = nil
+		m.alpha = 0
+		return nil
+	}
+	if alpha <= 0 {
+		return errors.Errorf("likelihood: matrix: invalid gamma shape %.6f", alpha)
+	}
+	if len(m.rates) != cats {
+		m.batches = nil
+	}
+	m.rates = GammaRates(alpha, cats)
+	m.alpha = alpha
+	return nil
+}
+
+// Gamma returns the number of categories
+// and the shape (alpha)
+// of the discrete gamma model
+// of the matrix.
+// If there is no rate heterogeneity,
+// the number of categories is 1.
+func (m *Matrix) Gamma() (cats int, alpha float64) {
+	return len(m.rateCats()), m.alpha
+}
+
+// FixAlpha sets whether the shape (alpha)
+// of the discrete gamma model
+// is fixed.
+// If it is not fixed,
+// it will be estimated
+// with the other model parameters
+// (see Tree.Estimate).
+func (m *Matrix) FixAlpha(fix bool) {
+	m.fixed = fix
+}
+
+// RateCats returns the relative rate
+// of each rate category.
+func (m *Matrix) rateCats() []float64 {
+	if len(m.rates) == 0 {
+		return []float64{1}
+	}
+	return m.rates
+}
+
+// SetAlpha changes the shape (alpha)
+// of the discrete gamma model of a tree,
+// and updates its conditionals.
+// The tree must have
+// a discrete gamma model
+// (see Matrix.SetGamma).
+func (tr *Tree) SetAlpha(alpha float64) {
+	if len(tr.M.rates) == 0 || alpha <= 0 {
+		return
+	}
+	tr.M.rates = GammaRates(alpha, len(tr.M.rates))
+	tr.M.alpha = alpha
+	tr.Root.downPass(tr.M)
+}
+
+// EstimateAlpha sets the shape (alpha)
+// of the discrete gamma model of a tree
+// to its maximum likelihood estimate,
+// under the current branch lengths.
+func (tr *Tree) EstimateAlpha() {
+	if len(tr.M.rates) == 0 {
+		return
+	}
+	like := func(la float64) float64 {
+		tr.SetAlpha(math.Exp(la))
+		return tr.Like()
+	}
+
+	// golden section search on the log of alpha
+	a, b := math.Log(0.01), math.Log(100)
+	g := (math.Sqrt(5) - 1) / 2
+	c := b - g*(b-a)
+	d := a + g*(b-a)
+	lc, ld := like(c), like(d)
+	for b-a > 0.001 {
+		if lc > ld {
+			b, d, ld = d, c, lc
+			c = b - g*(b-a)
+			lc = like(c)
+			continue
+		}
+		a, c, lc = c, d, ld
+		d = a + g*(b-a)
+		ld = like(d)
+	}
+	tr.SetAlpha(math.Exp((a + b) / 2))
+}
+
+// ChiSquare1 returns the upper tail probability
+// of a chi-square distribution
+// with one degree of freedom.
+func chiSquare1(x float64) float64 {
+	if x <= 0 {
+		return 1
+	}
+	return math.Erfc(math.Sqrt(x / 2))
+}
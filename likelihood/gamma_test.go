@@ -0,0 +1,84 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestGammaRates(t *testing.T) {
+	// values from Yang (1994), table 1
+	want := []float64{0.0334, 0.2519, 0.8203, 2.8944}
+	rates := GammaRates(0.5, 4)
+	if len(rates) != len(want) {
+		t.Fatalf("likelihood: gamma rates: %d categories, want %d", len(rates), len(want))
+	}
+	for i, r := range rates {
+		if math.Abs(r-want[i]) > 0.0001 {
+			t.Errorf("likelihood: gamma rates: category %d: rate %.6f, want %.6f", i, r, want[i])
+		}
+	}
+	if r := GammaRates(1, 1); len(r) != 1 || r[0] != 1 {
+		t.Errorf("likelihood: gamma rates: single category: %v, want [1]", r)
+	}
+}
+
+func TestGammaLike(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("likelihood: gamma: unexpected error while reading matrix: %v", err)
+	}
+	tr, err := ReadTree(strings.NewReader(treeLenBlob), m)
+	if err != nil {
+		t.Fatalf("likelihood: gamma: unexpected error while reading tree: %v", err)
+	}
+	like := tr.Like()
+
+	gm, err := NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("likelihood: gamma: unexpected error while reading matrix: %v", err)
+	}
+	if err := gm.SetGamma(4, 0); err == nil {
+		t.Errorf("likelihood: gamma: expecting error on alpha 0")
+	}
+	// with a large alpha,
+	// all the rates are near 1
+	if err := gm.SetGamma(4, 10000); err != nil {
+		t.Fatalf("likelihood: gamma: unexpected error: %v", err)
+	}
+	gt, err := ReadTree(strings.NewReader(treeLenBlob), gm)
+	if err != nil {
+		t.Fatalf("likelihood: gamma: unexpected error while reading tree: %v", err)
+	}
+	if l := gt.Like(); math.Abs(l-like) > 0.5 {
+		t.Errorf("likelihood: gamma: log likelihood %.6f, want %.6f", l, like)
+	}
+
+	gt.EstimateAlpha()
+	if l := gt.Like(); l < like {
+		t.Errorf("likelihood: gamma: estimated log likelihood %.6f, want > %.6f", l, like)
+	}
+	cats, alpha := gm.Gamma()
+	if cats != 4 || alpha >= 10000 {
+		t.Errorf("likelihood: gamma: categories %d, alpha %.6f", cats, alpha)
+	}
+
+	for n, conds := range gt.Marginals() {
+		if n.Term != nil {
+			continue
+		}
+		for i, c := range conds {
+			sum := float64(0)
+			for _, p := range c {
+				sum += p
+			}
+			if math.Abs(sum-1) > 0.000001 {
+				t.Errorf("likelihood: gamma: marginals: character %d: sum of probabilities %.6f, want %.6f", i, sum, 1.0)
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/js-arias/ramita/parsimony"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+// HybridSPR performs an SPR branch swapping
+// of a tree,
+// in which the rearrangements
+// are pre-screened with parsimony.
+//
+// In each round,
+// the SPR rearrangements of the tree
+// with a parsimony length not worse than
+// the best length plus the slack
+// are evaluated under likelihood,
+// in order of parsimony length,
+// up to max rearrangements
+// (0 means no limit).
+// The branch lengths of each evaluated tree
+// are refined,
+// starting from the lengths of the branches
+// shared with the current tree.
+// If a tree with a better likelihood is found,
+// it replaces the current tree,
+// and a new round is started.
+//
+// It returns the number of improvements.
+func (tr *Tree) HybridSPR(slack, max int) (int, error) {
+	imp := 0
+	for {
+		tp := tr.Topology()
+		if err := tp.Reroot(tr.M.M.Out.Name); err != nil {
+			return imp, errors.Wrap(err, "likelihood: hybrid spr")
+		}
+		pt, err := parsimony.FromTopology(tp, tr.M.M)
+		if err != nil {
+			return imp, errors.Wrap(err, "likelihood: hybrid spr")
+		}
+		cands, _ := pt.SPRNeighbors(slack, nil)
+		lens := cladeLens(tp)
+
+		like := tr.Like()
+		improved := false
+		for i, ct := range cands {
+			if max > 0 && i >= max {
+				break
+			}
+			for _, n := range ct.Nodes() {
+				if l, ok := lens[cladeKey(n)]; ok {
+					n.Len = l
+				} else {
+					n.Len = 0.01
+				}
+			}
+			ct.Lens = true
+			nt, err := FromTopology(ct, tr.M)
+			if err != nil {
+				return imp, errors.Wrap(err, "likelihood: hybrid spr")
+			}
+			nt.Refine()
+			if nt.Like() > like+0.001 {
+				*tr = *nt
+				imp++
+				improved = true
+				break
+			}
+		}
+		if !improved {
+			return imp, nil
+		}
+	}
+}
+
+// CladeLens returns the length
+// of the branch of each clade of a tree.
+func cladeLens(t *tree.Tree) map[string]float64 {
+	lens := make(map[string]float64)
+	for _, n := range t.Nodes() {
+		lens[cladeKey(n)] = n.Len
+	}
+	return lens
+}
+
+// CladeKey returns a string
+// that identifies the clade of a node.
+func cladeKey(n *tree.Node) string {
+	terms := n.Terms()
+	sort.Strings(terms)
+	return strings.Join(terms, " ")
+}
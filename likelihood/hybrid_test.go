@@ -0,0 +1,53 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"strings"
+	"testing"
+)
+
+var hybridBlob = `
+> dna
+A AAAAAAAAAACCCCCCCCCCGGGGG
+B AAAAAAAAAACCCCCCCCCCGGGGT
+C AAAAATTTTTGGGGGCCCCCGGGGT
+D AAAAATTTTTGGGGGCCCCCTGGGT
+E TTAAATTTTTGGGGGAACCCTGGGT
+`
+
+func TestHybridSPR(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(hybridBlob))
+	if err != nil {
+		t.Fatalf("likelihood: hybrid spr: unexpected error while reading matrix: %v", err)
+	}
+	tr, err := ReadTree(strings.NewReader("(A,((B,D),(C,E)));"), m)
+	if err != nil {
+		t.Fatalf("likelihood: hybrid spr: unexpected error while reading tree: %v", err)
+	}
+	tr.Refine()
+	init := tr.Like()
+
+	imp, err := tr.HybridSPR(2, 0)
+	if err != nil {
+		t.Fatalf("likelihood: hybrid spr: unexpected error: %v", err)
+	}
+	if imp == 0 {
+		t.Errorf("likelihood: hybrid spr: tree not improved")
+	}
+	if tr.Like() <= init {
+		t.Errorf("likelihood: hybrid spr: likelihood %.6f, want better than %.6f", tr.Like(), init)
+	}
+
+	tp := tr.Topology()
+	if err := tp.Reroot("A"); err != nil {
+		t.Fatalf("likelihood: hybrid spr: unexpected error: %v", err)
+	}
+	for _, cl := range [][]string{{"C", "D", "E"}, {"D", "E"}} {
+		if !tp.HasClade(cl) {
+			t.Errorf("likelihood: hybrid spr: clade %v not found", cl)
+		}
+	}
+}
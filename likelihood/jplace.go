@@ -0,0 +1,267 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+// A PQuery is a query terminal
+// with its placements
+// on a reference tree.
+type PQuery struct {
+	Name       string
+	Placements []Placement
+}
+
+// JPlaceFields are the fields
+// of each placement
+// in a jplace file.
+var jplaceFields = []string{"edge_num", "likelihood", "like_weight_ratio", "distal_length", "pendant_length"}
+
+// A jplace is the content
+// of a jplace file.
+type jplace struct {
+	Tree       string         `json:"tree"`
+	Placements []jplacePQuery `json:"placements"`
+	Metadata   interface{}    `json:"metadata"`
+	Version    int            `json:"version"`
+	Fields     []string       `json:"fields"`
+}
+
+// A jplacePQuery is a query
+// in a jplace file.
+// The names of the query
+// can be given as a list of names,
+// or as a list of names
+// with its multiplicity.
+type jplacePQuery struct {
+	P  [][]float64     `json:"p"`
+	N  []string        `json:"n,omitempty"`
+	NM [][]interface{} `json:"nm,omitempty"`
+}
+
+// WriteJPlace writes the placements
+// of a set of queries
+// on a reference tree
+// in jplace format (version 3),
+// as used by pplacer, EPA,
+// and other placement tools.
+// The edges of the tree
+// are numbered in post-order.
+// The edges of the placements
+// are the indexes of the nodes
+// in the reference tree,
+// as in Place.
+// The metadata is a set of fields
+// stored as is in the file
+// (e.g. the invocation of the program).
+func WriteJPlace(w io.Writer, t *tree.Tree, pqs []PQuery, metadata map[string]string) error {
+	nodes := t.Nodes()
+	edges := make(map[*tree.Node]int, len(nodes))
+	t.PostOrder(func(n *tree.Node) {
+		edges[n] = len(edges)
+	})
+
+	var b bytes.Buffer
+	jplaceNode(&b, t.Root, edges)
+	b.WriteString(";")
+
+	jp := jplace{
+		Tree:       b.String(),
+		Placements: make([]jplacePQuery, 0, len(pqs)),
+		Metadata:   metadata,
+		Version:    3,
+		Fields:     jplaceFields,
+	}
+	for _, pq := range pqs {
+		q := jplacePQuery{N: []string{pq.Name}}
+		for _, p := range pq.Placements {
+			if p.Edge < 1 || p.Edge >= len(nodes) {
+				return errors.Errorf("likelihood: jplace: query %s: invalid edge %d", pq.Name, p.Edge)
+			}
+			n := nodes[p.Edge]
+
+			// the branches adjacent to the query
+			// are estimated in each placement,
+			// so the placement is kept
+			// inside the reference branch
+			distal := math.Min(p.Distal, n.Len)
+			q.P = append(q.P, []float64{float64(edges[n]), p.Like, p.Weight, distal, p.Pendant})
+		}
+		jp.Placements = append(jp.Placements, q)
+	}
+
+	e := json.NewEncoder(w)
+	e.SetIndent("", "\t")
+	if err := e.Encode(jp); err != nil {
+		return errors.Wrap(err, "likelihood: jplace")
+	}
+	return nil
+}
+
+// JPlaceNode writes a node of a tree
+// with its branch length
+// and its edge number.
+func jplaceNode(w io.Writer, n *tree.Node, edges map[*tree.Node]int) {
+	if n.IsTerm() {
+		name := n.Name
+		if strings.ContainsAny(name, " \t\n,;:()[]{}'") {
+			name = "'" + strings.Replace(name, "'", "''", -1) + "'"
+		}
+		fmt.Fprintf(w, "%s", name)
+	} else {
+		fmt.Fprintf(w, "(")
+		for i, d := range n.Desc {
+			if i > 0 {
+				fmt.Fprintf(w, ",")
+			}
+			jplaceNode(w, d, edges)
+		}
+		fmt.Fprintf(w, ")")
+	}
+	if n.Anc != nil {
+		fmt.Fprintf(w, ":%.6f", n.Len)
+	}
+	fmt.Fprintf(w, "{%d}", edges[n])
+}
+
+// ReadJPlace reads a jplace file,
+// and returns the reference tree,
+// and the placements of each query.
+// As in WriteJPlace,
+// the edges of the placements
+// are the indexes of the nodes
+// of the reference tree,
+// in pre-order.
+// Fields of the placements
+// that are not in the file
+// are set to 0.
+// A query with multiple names
+// is returned as a query for each name.
+func ReadJPlace(r io.Reader) (*tree.Tree, []PQuery, error) {
+	var jp jplace
+	if err := json.NewDecoder(r).Decode(&jp); err != nil {
+		return nil, nil, errors.Wrap(err, "likelihood: jplace")
+	}
+	t, err := tree.Read(strings.NewReader(edgeComments(jp.Tree)))
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "likelihood: jplace: tree")
+	}
+
+	// edge number of each node
+	edges := make(map[int]int)
+	for i, n := range t.Nodes() {
+		c := n.Comment
+		n.Comment = ""
+		k := strings.Index(c, "{")
+		if k < 0 {
+			// in jplace version 1,
+			// edge numbers are comments
+			if e, err := strconv.Atoi(strings.TrimSpace(c)); err == nil {
+				edges[e] = i
+			}
+			continue
+		}
+		c = c[k+1:]
+		if k = strings.Index(c, "}"); k >= 0 {
+			c = c[:k]
+		}
+		e, err := strconv.Atoi(strings.TrimSpace(c))
+		if err != nil {
+			return nil, nil, errors.Errorf("likelihood: jplace: tree: invalid edge number %q", c)
+		}
+		edges[e] = i
+	}
+
+	fields := make(map[string]int, len(jp.Fields))
+	for i, f := range jp.Fields {
+		fields[f] = i
+	}
+	if _, ok := fields["edge_num"]; !ok {
+		return nil, nil, errors.New("likelihood: jplace: field edge_num not defined")
+	}
+	value := func(v []float64, f string) float64 {
+		i, ok := fields[f]
+		if !ok || i >= len(v) {
+			return 0
+		}
+		return v[i]
+	}
+
+	var pqs []PQuery
+	for _, q := range jp.Placements {
+		var ps []Placement
+		for _, v := range q.P {
+			e := int(value(v, "edge_num"))
+			idx, ok := edges[e]
+			if !ok {
+				return nil, nil, errors.Errorf("likelihood: jplace: edge %d not in tree", e)
+			}
+			ps = append(ps, Placement{
+				Edge:    idx,
+				Like:    value(v, "likelihood"),
+				Weight:  value(v, "like_weight_ratio"),
+				Distal:  value(v, "distal_length"),
+				Pendant: value(v, "pendant_length"),
+			})
+		}
+		names := q.N
+		for _, nm := range q.NM {
+			if len(nm) == 0 {
+				continue
+			}
+			if s, ok := nm[0].(string); ok {
+				names = append(names, s)
+			}
+		}
+		for _, nm := range names {
+			pqs = append(pqs, PQuery{Name: nm, Placements: append([]Placement{}, ps...)})
+		}
+	}
+	return t, pqs, nil
+}
+
+// EdgeComments returns a tree string
+// in which the edge numbers
+// (between curly braces)
+// are stored as comments,
+// so the tree can be read
+// with tree.Read.
+func edgeComments(s string) string {
+	var b strings.Builder
+	quoted := false
+	comment := false
+	for _, r1 := range s {
+		switch {
+		case r1 == '\'' && !comment:
+			quoted = !quoted
+		case quoted:
+		case r1 == '[':
+			comment = true
+		case r1 == ']':
+			comment = false
+		case comment:
+		case r1 == '{':
+			b.WriteString("[{")
+			continue
+		case r1 == '}':
+			b.WriteString("}]")
+			continue
+		}
+		b.WriteRune(r1)
+	}
+	return b.String()
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/tree"
+)
+
+func TestJPlace(t *testing.T) {
+	ref, err := tree.Read(strings.NewReader("((A:0.1,B:0.2):0.3,C:0.4);"))
+	if err != nil {
+		t.Fatalf("likelihood: jplace: unexpected error while reading tree: %v", err)
+	}
+	pqs := []PQuery{
+		{
+			Name: "D",
+			Placements: []Placement{
+				// pre-order index 3 is B,
+				// with edge number 1
+				{Edge: 3, Like: -10, Weight: 0.75, Distal: 0.5, Pendant: 0.05},
+				{Edge: 4, Like: -11, Weight: 0.25, Distal: 0.1, Pendant: 0.02},
+			},
+		},
+	}
+	var b bytes.Buffer
+	if err := WriteJPlace(&b, ref, pqs, map[string]string{"invocation": "test"}); err != nil {
+		t.Fatalf("likelihood: jplace: unexpected error: %v", err)
+	}
+
+	var jp jplace
+	if err := json.Unmarshal(b.Bytes(), &jp); err != nil {
+		t.Fatalf("likelihood: jplace: unexpected error while decoding: %v", err)
+	}
+	if tr := "((A:0.100000{0},B:0.200000{1}):0.300000{2},C:0.400000{3}){4};"; jp.Tree != tr {
+		t.Errorf("likelihood: jplace: tree %q, want %q", jp.Tree, tr)
+	}
+	if jp.Version != 3 {
+		t.Errorf("likelihood: jplace: version %d, want %d", jp.Version, 3)
+	}
+	if !reflect.DeepEqual(jp.Fields, jplaceFields) {
+		t.Errorf("likelihood: jplace: fields %v, want %v", jp.Fields, jplaceFields)
+	}
+	if md, ok := jp.Metadata.(map[string]interface{}); !ok || md["invocation"] != "test" {
+		t.Errorf("likelihood: jplace: metadata %v", jp.Metadata)
+	}
+	if len(jp.Placements) != 1 {
+		t.Fatalf("likelihood: jplace: %d queries, want %d", len(jp.Placements), 1)
+	}
+	q := jp.Placements[0]
+	if !reflect.DeepEqual(q.N, []string{"D"}) {
+		t.Errorf("likelihood: jplace: query names %v, want %v", q.N, []string{"D"})
+	}
+	// distal length is bounded
+	// by the length of the branch
+	p := [][]float64{
+		{1, -10, 0.75, 0.2, 0.05},
+		{3, -11, 0.25, 0.1, 0.02},
+	}
+	if !reflect.DeepEqual(q.P, p) {
+		t.Errorf("likelihood: jplace: placements %v, want %v", q.P, p)
+	}
+
+	// read the placements back
+	rt, rq, err := ReadJPlace(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatalf("likelihood: jplace: read: unexpected error: %v", err)
+	}
+	if c := rt.Canonical(); c != ref.Canonical() {
+		t.Errorf("likelihood: jplace: read: tree %s, want %s", c, ref.Canonical())
+	}
+	want := []PQuery{
+		{
+			Name: "D",
+			Placements: []Placement{
+				{Edge: 3, Like: -10, Weight: 0.75, Distal: 0.2, Pendant: 0.05},
+				{Edge: 4, Like: -11, Weight: 0.25, Distal: 0.1, Pendant: 0.02},
+			},
+		},
+	}
+	if !reflect.DeepEqual(rq, want) {
+		t.Errorf("likelihood: jplace: read: placements %v, want %v", rq, want)
+	}
+
+	// a version 1 file,
+	// with names and multiplicity
+	v1 := `{
+		"tree": "((A:0.1[0],'B{x}':0.2[1]):0.3[2],C:0.4[3])[4];",
+		"placements": [{"p": [[2, -5.0, 1.0]], "nm": [["E", 1], ["F", 2]]}],
+		"version": 1,
+		"fields": ["edge_num", "likelihood", "like_weight_ratio"]
+	}`
+	rt, rq, err = ReadJPlace(strings.NewReader(v1))
+	if err != nil {
+		t.Fatalf("likelihood: jplace: read v1: unexpected error: %v", err)
+	}
+	if nm := rt.Nodes()[3].Name; nm != "B{x}" {
+		t.Errorf("likelihood: jplace: read v1: terminal %q, want %q", nm, "B{x}")
+	}
+	want = []PQuery{
+		{Name: "E", Placements: []Placement{{Edge: 1, Like: -5, Weight: 1}}},
+		{Name: "F", Placements: []Placement{{Edge: 1, Like: -5, Weight: 1}}},
+	}
+	if !reflect.DeepEqual(rq, want) {
+		t.Errorf("likelihood: jplace: read v1: placements %v, want %v", rq, want)
+	}
+
+	pqs[0].Placements[0].Edge = 0
+	if err := WriteJPlace(&b, ref, pqs, nil); err == nil {
+		t.Errorf("likelihood: jplace: expecting error on root edge")
+	}
+}
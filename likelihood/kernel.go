@@ -0,0 +1,117 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import "sync"
+
+// A Kernel computes the transition matrices
+// and updates the conditional likelihoods
+// of the nodes of a tree.
+//
+// The default kernel is built
+// over a linear algebra Backend,
+// so in most cases,
+// an accelerated implementation
+// (for example using gonum or BLAS)
+// only requires a new Backend
+// (see SetBackend).
+// A Kernel should be implemented
+// only to replace the computation
+// of the transition matrices,
+// or to fuse the update of the conditionals.
+type Kernel interface {
+	// Transition sets dst
+	// as the transposed transition matrix
+	// of a model,
+	// for a given branch length,
+	// i.e. the element [to][from]
+	// of the states × states row-major matrix
+	// is the probability of change
+	// from a state to another.
+	Transition(dst []float64, md Model, states int, blen float64)
+
+	// Update sets dst
+	// as the conditionals of a block of characters
+	// of a node,
+	// from the conditionals of its descendants
+	// (left and right),
+	// and the transposed transition matrices
+	// of their branches
+	// (tl and tr).
+	// All conditionals are
+	// patterns × states row-major matrices.
+	Update(dst, left, tl, right, tr []float64, patterns, states int)
+}
+
+// NewKernel returns the default kernel
+// using the indicated linear algebra backend.
+func NewKernel(b Backend) Kernel {
+	if b == nil {
+		b = GoBackend{}
+	}
+	return &linalgKernel{b: b}
+}
+
+// LinalgKernel is a kernel
+// built over a linear algebra backend.
+type linalgKernel struct {
+	b Backend
+
+	// buffers for the products
+	// of the descendants,
+	// in a pool,
+	// as the kernel is shared
+	// by concurrent searches
+	bufs sync.Pool
+}
+
+// Transition sets dst as the transposed transition matrix
+// of a model.
+func (k *linalgKernel) Transition(dst []float64, md Model, states int, blen float64) {
+	for from := 0; from < states; from++ {
+		for to := 0; to < states; to++ {
+			dst[to*states+from] = md.Prob(from, to, blen)
+		}
+	}
+}
+
+// Update sets dst as the conditionals of a block
+// of characters.
+func (k *linalgKernel) Update(dst, left, tl, right, tr []float64, patterns, states int) {
+	n := len(dst)
+	buf, _ := k.bufs.Get().(*[]float64)
+	if buf == nil || cap(*buf) < 2*n {
+		tmp := make([]float64, 2*n)
+		buf = &tmp
+	}
+	tmp := (*buf)[:2*n]
+	pl, pr := tmp[:n], tmp[n:]
+	k.b.Mul(pl, left, tl, patterns, states, states)
+	k.b.Mul(pr, right, tr, patterns, states, states)
+	k.b.MulElem(dst, pl, pr)
+	k.bufs.Put(buf)
+}
+
+// Kernel used to update conditionals.
+var kernel = NewKernel(GoBackend{})
+
+// SetKernel sets the kernel
+// used to update the conditional likelihoods.
+// If k is nil,
+// the default kernel will be used.
+func SetKernel(k Kernel) {
+	if k == nil {
+		k = NewKernel(GoBackend{})
+	}
+	kernel = k
+}
+
+// SetBackend sets the linear algebra backend
+// used by the default kernel.
+// If b is nil,
+// the pure Go backend will be used.
+func SetBackend(b Backend) {
+	SetKernel(NewKernel(b))
+}
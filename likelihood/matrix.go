@@ -7,6 +7,7 @@ package likelihood
 import (
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/js-arias/ramita/matrix"
 
@@ -21,6 +22,24 @@ type Matrix struct {
 	model  []string         // the model of each character
 	mds    map[string]Model // list of models assigned to the matrix
 	states []int            // number of states per character
+
+	// discrete gamma model
+	rates []float64 // relative rate of each category
+	alpha float64   // shape of the gamma distribution
+	fixed bool      // if true, alpha is not estimated
+
+	// memory layout of the conditionals
+	batches []batch
+	pos     []int // position of each character
+	size    int   // size of the conditionals of a node
+
+	checkpoint int // levels between stored nodes
+
+	// characters of the original matrix
+	// in each character of a compressed matrix
+	// (see Compress)
+	patterns []matrix.Pattern
+	orig     int // number of characters of the original matrix
 }
 
 // NewFromMatrix returns a new matrix
@@ -42,15 +61,17 @@ func NewFromMatrix(mt *matrix.Matrix) *Matrix {
 			m.states[i] = 4
 			continue
 		}
-		var states uint8
-		for _, tx := range m.M.Names {
-			if tx.Chars[i] == 255 {
-				continue
+		if k == matrix.Protein {
+			if _, ok := m.mds["poisson"]; !ok {
+				m.mds["poisson"] = NewPoisson(20)
 			}
-			states |= tx.Chars[i]
+			m.model[i] = "poisson"
+			m.states[i] = 20
+			continue
 		}
+		states := mt.States(i)
 		max := 1
-		for b := uint8(7); b > 0; b-- {
+		for b := uint32(7); b > 0; b-- {
 			if states&(1<<b) != 0 {
 				max = int(b) + 1
 				break
@@ -68,11 +89,18 @@ func NewFromMatrix(mt *matrix.Matrix) *Matrix {
 
 // NewMatrix returns a new matrix
 // from a reader.
+// DNA matrices are packed
+// (see matrix.Matrix.Pack).
 func NewMatrix(r io.Reader) (*Matrix, error) {
 	pm, err := matrix.NewMatrix(r)
 	if err != nil {
 		return nil, errors.Wrap(err, "likelihood")
 	}
+	if pm.CanPack() {
+		if err := pm.Pack(); err != nil {
+			return nil, errors.Wrap(err, "likelihood")
+		}
+	}
 	return NewFromMatrix(pm), nil
 }
 
@@ -83,6 +111,77 @@ func (m *Matrix) Model(char int) Model {
 	return m.mds[nm]
 }
 
+// SetModels assigns models to characters
+// from a definition,
+// i.e. a list of assignments,
+// separated by semicolons,
+// each one with a model name (as in ParseModel),
+// a colon,
+// and the list of characters,
+// as in matrix.ParseRange,
+// for example:
+//
+//	jc:1-2555; mk2:2556-2922
+//
+// Characters not included in the definition
+// keep its current model.
+func (m *Matrix) SetModels(def string) error {
+	for _, a := range strings.Split(def, ";") {
+		a = strings.TrimSpace(a)
+		if a == "" {
+			continue
+		}
+		i := strings.Index(a, ":")
+		if i < 0 {
+			return errors.Errorf("likelihood: matrix: model assignment %q: expecting ':'", a)
+		}
+		id := strings.ToLower(strings.TrimSpace(a[:i]))
+		md, err := ParseModel(id)
+		if err != nil {
+			return errors.Wrap(err, "likelihood: matrix")
+		}
+		chars, err := matrix.ParseRange(a[i+1:], m.Chars())
+		if err != nil {
+			return errors.Wrapf(err, "likelihood: matrix: model %s", id)
+		}
+		if len(chars) == 0 {
+			return errors.Errorf("likelihood: matrix: model %s without characters", id)
+		}
+		for _, c := range chars {
+			if err := m.SetModel(c, id, md); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ModelID returns the ID of the model
+// assigned to a character.
+func (m *Matrix) ModelID(char int) string {
+	return m.model[char]
+}
+
+// ModelPartitions returns the characters
+// assigned to each model,
+// as partitions named with the model ID.
+// Partitions are sorted
+// by its first character.
+func (m *Matrix) ModelPartitions() []matrix.Partition {
+	var ps []matrix.Partition
+	idx := make(map[string]int)
+	for i, id := range m.model {
+		j, ok := idx[id]
+		if !ok {
+			j = len(ps)
+			idx[id] = j
+			ps = append(ps, matrix.Partition{Name: id})
+		}
+		ps[j].Chars = append(ps[j].Chars, i)
+	}
+	return ps
+}
+
 // Terms return the number of terminals
 // in the datamatrix.
 func (m *Matrix) Terms() int {
@@ -104,11 +203,75 @@ func (m *Matrix) States(char int) int {
 // to a character.
 func (m *Matrix) SetModel(char int, id string, md Model) error {
 	if md.States() < m.states[char] {
-		return errors.Errorf("likelihood: matrix: model %s for %d states, char %d with %d states", id, md.States(), char, m.states[char])
+		return errors.Errorf("likelihood: matrix: model %s for %d states, character %d with %d states", id, md.States(), char+1, m.states[char])
 	}
 	if _, ok := m.mds[id]; !ok {
 		m.mds[id] = md
 	}
+	if m.Model(char).States() != m.mds[id].States() {
+		// the memory layout should be rebuilt
+		m.batches = nil
+	}
 	m.model[char] = id
 	return nil
 }
+
+// Compress returns a new matrix
+// with a character for each site pattern
+// of the matrix
+// (see matrix.Matrix.Compress),
+// so in the likelihood computations
+// each pattern is evaluated only once,
+// and its log likelihood
+// is multiplied by the number of characters
+// with the pattern.
+// Characters with different models
+// are not collapsed.
+// The models,
+// and the discrete gamma model,
+// are the same as in the original matrix.
+func (m *Matrix) Compress() *Matrix {
+	mt, ps := m.M.Compress(m.model)
+	c := &Matrix{
+		M:          mt,
+		model:      make([]string, len(ps)),
+		mds:        make(map[string]Model, len(m.mds)),
+		states:     make([]int, len(ps)),
+		rates:      m.rates,
+		alpha:      m.alpha,
+		fixed:      m.fixed,
+		checkpoint: m.checkpoint,
+		patterns:   ps,
+		orig:       m.Chars(),
+	}
+	for id, md := range m.mds {
+		c.mds[id] = md
+	}
+	for i, p := range ps {
+		c.model[i] = m.model[p.Chars[0]]
+		c.states[i] = m.states[p.Chars[0]]
+	}
+	return c
+}
+
+// ExpandSites returns the log likelihood
+// of each character of the original matrix
+// of a compressed matrix
+// (see Compress),
+// from the log likelihood of each site pattern
+// (as returned by SiteLikes).
+// Excluded characters have a log likelihood of 0.
+// If the matrix is not compressed,
+// the sites are returned unchanged.
+func (m *Matrix) ExpandSites(sites []float64) []float64 {
+	if m.patterns == nil {
+		return sites
+	}
+	exp := make([]float64, m.orig)
+	for i, p := range m.patterns {
+		for _, c := range p.Chars {
+			exp[c] = sites[i]
+		}
+	}
+	return exp
+}
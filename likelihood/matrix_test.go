@@ -9,6 +9,8 @@ import (
 	"testing"
 
 	"math"
+
+	"github.com/js-arias/ramita/matrix"
 )
 
 var dnaBlob = `
@@ -63,3 +65,115 @@ func TestModel(t *testing.T) {
 		t.Errorf("likelihood: model: probability: %.6f, want %.6f", m.Prob(0, 1, 0.1), 0.0238)
 	}
 }
+
+var modelsBlob = `
+> dna
+A	ACGT
+B	ACGA
+C	TCGA
+> morpho
+A	01
+B	12
+C	02
+`
+
+func TestModelPartitions(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(modelsBlob))
+	if err != nil {
+		t.Fatalf("likelihood: model partitions: unexpected error while reading matrix: %v", err)
+	}
+	if id := m.ModelID(0); id != "jc" {
+		t.Errorf("likelihood: model partitions: character 1: model %s, want %s", id, "jc")
+	}
+	ps := m.ModelPartitions()
+	want := []string{"jc = 1-4", "mk2 = 5", "mk3 = 6"}
+	if len(ps) != len(want) {
+		t.Fatalf("likelihood: model partitions: %d partitions, want %d", len(ps), len(want))
+	}
+	for i, p := range ps {
+		if got := p.Name + " = " + matrix.FormatRange(p.Chars); got != want[i] {
+			t.Errorf("likelihood: model partitions: partition %d: %q, want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestProteinModel(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader("> protein\nA MKLV\nB MKIV\nC MRIV\n"))
+	if err != nil {
+		t.Fatalf("likelihood: protein: unexpected error while reading matrix: %v", err)
+	}
+	if id := m.ModelID(0); id != "poisson" {
+		t.Errorf("likelihood: protein: model %s, want %s", id, "poisson")
+	}
+	if s := m.States(0); s != 20 {
+		t.Errorf("likelihood: protein: %d states, want 20", s)
+	}
+}
+
+func TestSetModels(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(modelsBlob))
+	if err != nil {
+		t.Fatalf("likelihood: set models: unexpected error while reading matrix: %v", err)
+	}
+	if err := m.SetModels("mk4: 1-2; mk3:5-6"); err != nil {
+		t.Fatalf("likelihood: set models: unexpected error: %v", err)
+	}
+	want := []string{"mk4", "mk4", "jc", "jc", "mk3", "mk3"}
+	for i, w := range want {
+		if id := m.ModelID(i); id != w {
+			t.Errorf("likelihood: set models: character %d: model %s, want %s", i+1, id, w)
+		}
+	}
+	if md := m.Model(4); md.States() != 3 {
+		t.Errorf("likelihood: set models: character 5: states %d, want %d", md.States(), 3)
+	}
+
+	for _, def := range []string{"mk2:1", "gtr:1-4", "jc 1-4", "jc:7"} {
+		if err := m.SetModels(def); err == nil {
+			t.Errorf("likelihood: set models: definition %q: expecting error", def)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("likelihood: compress: unexpected error while reading matrix: %v", err)
+	}
+	if err := m.SetModels("mkv4:1-100"); err != nil {
+		t.Fatalf("likelihood: compress: unexpected error: %v", err)
+	}
+	if err := m.SetGamma(2, 0.5); err != nil {
+		t.Fatalf("likelihood: compress: unexpected error: %v", err)
+	}
+	c := m.Compress()
+	if c.Chars() >= m.Chars() {
+		t.Errorf("likelihood: compress: %d patterns, from %d characters", c.Chars(), m.Chars())
+	}
+	if id := c.ModelID(0); id != "mkv4" {
+		t.Errorf("likelihood: compress: character 1: model %s, want %s", id, "mkv4")
+	}
+
+	tr, err := ReadTree(strings.NewReader(treeLenBlob), m)
+	if err != nil {
+		t.Fatalf("likelihood: compress: unexpected error while reading tree: %v", err)
+	}
+	ct, err := ReadTree(strings.NewReader(treeLenBlob), c)
+	if err != nil {
+		t.Fatalf("likelihood: compress: unexpected error while reading tree: %v", err)
+	}
+	if l, cl := tr.Like(), ct.Like(); math.Abs(l-cl) > 1e-6 {
+		t.Errorf("likelihood: compress: log likelihood %.6f, want %.6f", cl, l)
+	}
+
+	sites := tr.SiteLikes()
+	exp := c.ExpandSites(ct.SiteLikes())
+	if len(exp) != len(sites) {
+		t.Fatalf("likelihood: compress: %d expanded sites, want %d", len(exp), len(sites))
+	}
+	for i, s := range sites {
+		if math.Abs(exp[i]-s) > 1e-6 {
+			t.Errorf("likelihood: compress: character %d: log likelihood %.6f, want %.6f", i+1, exp[i], s)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"fmt"
+
+	"github.com/js-arias/ramita/matrix"
+)
+
+// Mkv is a poisson model
+// for characters in which only the variable characters
+// were scored,
+// as is usual in morphological data
+// (Lewis 2001).
+// The likelihood of each character
+// is conditioned on the character being variable.
+type Mkv struct {
+	Poisson
+}
+
+// NewMkv returns a new Mkv model
+// with a given number of states.
+func NewMkv(states int) Mkv {
+	return Mkv{Poisson(states)}
+}
+
+// IsVariable returns true
+// if a model is conditioned
+// on variable characters.
+func isVariable(md Model) bool {
+	switch m := md.(type) {
+	case Mkv:
+		return true
+	case *Rated:
+		return isVariable(m.Model)
+	}
+	return false
+}
+
+// VarProbs returns the probability
+// of a variable character
+// for each model ID
+// assigned to a character
+// with a model conditioned on variable characters.
+func (tr *Tree) varProbs() map[string]float64 {
+	var vp map[string]float64
+	for _, id := range tr.M.model {
+		if _, ok := vp[id]; ok {
+			continue
+		}
+		md := tr.M.mds[id]
+		if !isVariable(md) {
+			continue
+		}
+		if vp == nil {
+			vp = make(map[string]float64)
+		}
+		vp[id] = tr.varProb(md, 1)
+	}
+	return vp
+}
+
+// VarProb returns the probability
+// of a variable character
+// under a model,
+// i.e. 1 minus the probability
+// of all the constant characters,
+// averaged over the rate categories,
+// with the branch lengths multiplied by a rate.
+func (tr *Tree) varProb(md Model, rate float64) float64 {
+	cats := tr.M.rateCats()
+	cons := float64(0)
+	for _, r := range cats {
+		for s := 0; s < md.States(); s++ {
+			for x, p := range tr.Root.constCond(md, s, rate*r) {
+				cons += p * md.Freq(x)
+			}
+		}
+	}
+	return 1 - cons/float64(len(cats))
+}
+
+// ConstCond returns the conditional likelihood
+// on a node
+// of a constant character
+// in which all terminals have the state s,
+// with the branch lengths multiplied by a rate.
+func (n *Node) constCond(md Model, s int, rate float64) Conditional {
+	cond := make(Conditional, md.States())
+	if n.Term != nil {
+		cond[s] = 1
+		return cond
+	}
+	left := n.Left.constCond(md, s, rate)
+	right := n.Right.constCond(md, s, rate)
+	for x := range cond {
+		l, r := float64(0), float64(0)
+		for y := range left {
+			l += md.Prob(x, y, n.Left.Len*rate) * left[y]
+			r += md.Prob(x, y, n.Right.Len*rate) * right[y]
+		}
+		cond[x] = l * r
+	}
+	return cond
+}
+
+// TotalEvidence sets the models of a matrix
+// for a combined analysis
+// of morphological and molecular data:
+// the characters of DNA blocks
+// use the indicated DNA model
+// (as in ParseModel),
+// protein characters
+// use the poisson model,
+// and the other characters
+// use an Mkv model
+// with the number of states of the character.
+// All the characters share the branch lengths
+// of the tree.
+func (m *Matrix) TotalEvidence(dna string) error {
+	dm, err := ParseModel(dna)
+	if err != nil {
+		return err
+	}
+	for c, k := range m.M.Kind {
+		if k == matrix.DNA {
+			if err := m.SetModel(c, dna, dm); err != nil {
+				return err
+			}
+			continue
+		}
+		if k == matrix.Protein {
+			if err := m.SetModel(c, "poisson", NewPoisson(20)); err != nil {
+				return err
+			}
+			continue
+		}
+		id := fmt.Sprintf("mkv%d", m.states[c])
+		if err := m.SetModel(c, id, NewMkv(m.states[c])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
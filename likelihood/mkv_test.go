@@ -0,0 +1,87 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+var constBlob = `
+> morpho
+A 01
+B 01
+C 01
+D 01
+`
+
+func TestMkv(t *testing.T) {
+	treeBlob := "((A:0.1,B:0.2):0.1,(C:0.3,D:0.1):0.2);"
+
+	// probability of the constant characters
+	cm, err := NewMatrix(strings.NewReader(constBlob))
+	if err != nil {
+		t.Fatalf("likelihood: mkv: unexpected error while reading matrix: %v", err)
+	}
+	if err := cm.SetModels("mk2:1-2"); err != nil {
+		t.Fatalf("likelihood: mkv: unexpected error: %v", err)
+	}
+	ct, err := ReadTree(strings.NewReader(treeBlob), cm)
+	if err != nil {
+		t.Fatalf("likelihood: mkv: unexpected error while reading tree: %v", err)
+	}
+	cons := float64(0)
+	for _, l := range ct.SiteLikes() {
+		cons += math.Exp(l)
+	}
+
+	m, err := NewMatrix(strings.NewReader(ancBlob))
+	if err != nil {
+		t.Fatalf("likelihood: mkv: unexpected error while reading matrix: %v", err)
+	}
+	tr, err := ReadTree(strings.NewReader(treeBlob), m)
+	if err != nil {
+		t.Fatalf("likelihood: mkv: unexpected error while reading tree: %v", err)
+	}
+	mk := tr.Like()
+	if err := m.SetModels("mkv2:1"); err != nil {
+		t.Fatalf("likelihood: mkv: unexpected error: %v", err)
+	}
+	tr, err = ReadTree(strings.NewReader(treeBlob), m)
+	if err != nil {
+		t.Fatalf("likelihood: mkv: unexpected error while reading tree: %v", err)
+	}
+	want := mk - math.Log(1-cons)
+	if math.Abs(tr.Like()-want) > 1e-6 {
+		t.Errorf("likelihood: mkv: log likelihood %.6f, want %.6f", tr.Like(), want)
+	}
+	if s := tr.SiteLikes()[0]; math.Abs(s-want) > 1e-6 {
+		t.Errorf("likelihood: mkv: site log likelihood %.6f, want %.6f", s, want)
+	}
+
+	if _, err := ParseModel("mkv9"); err == nil {
+		t.Errorf("likelihood: mkv: expecting error")
+	}
+}
+
+func TestTotalEvidence(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(modelsBlob))
+	if err != nil {
+		t.Fatalf("likelihood: total evidence: unexpected error while reading matrix: %v", err)
+	}
+	if err := m.TotalEvidence("jc"); err != nil {
+		t.Fatalf("likelihood: total evidence: unexpected error: %v", err)
+	}
+	want := []string{"jc", "jc", "jc", "jc", "mkv2", "mkv3"}
+	for i, w := range want {
+		if id := m.ModelID(i); id != w {
+			t.Errorf("likelihood: total evidence: character %d: model %s, want %s", i+1, id, w)
+		}
+	}
+	if err := m.TotalEvidence("mk2"); err == nil {
+		t.Errorf("likelihood: total evidence: expecting error")
+	}
+}
@@ -4,7 +4,13 @@
 
 package likelihood
 
-import "math"
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
 
 // A Model is an evolutionary model.
 type Model interface {
@@ -94,3 +100,37 @@ func (p Poisson) SetChangeRate(tp int, r float64) {}
 func NewJC() Poisson {
 	return Poisson(4)
 }
+
+// ParseModel returns a model from its name.
+// Valid names are "jc",
+// for the Jukes-Cantor model,
+// "poisson",
+// for the Poisson model of amino acids,
+// "mk<n>",
+// for a poisson model with n states
+// (from 2 to 8),
+// and "mkv<n>",
+// for a poisson model with n states
+// in which only variable characters are scored.
+func ParseModel(name string) (Model, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "jc" {
+		return NewJC(), nil
+	}
+	if name == "poisson" {
+		return NewPoisson(20), nil
+	}
+	if strings.HasPrefix(name, "mkv") {
+		n, err := strconv.Atoi(name[3:])
+		if err == nil && n >= 2 && n <= 8 {
+			return NewMkv(n), nil
+		}
+	}
+	if strings.HasPrefix(name, "mk") {
+		n, err := strconv.Atoi(name[2:])
+		if err == nil && n >= 2 && n <= 8 {
+			return NewPoisson(n), nil
+		}
+	}
+	return nil, errors.Errorf("likelihood: unknown model %q", name)
+}
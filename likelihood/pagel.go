@@ -0,0 +1,246 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"math"
+
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+// A Transform is a Pagel's transformation
+// of the branch lengths of a tree.
+type Transform int
+
+// Pagel's transformations.
+const (
+	// Lambda multiplies the internal branches,
+	// keeping the distance from the root
+	// to the terminals.
+	Lambda Transform = iota
+
+	// Kappa raises each branch length
+	// to a power.
+	Kappa
+
+	// Delta raises the distance from the root
+	// of each node
+	// to a power.
+	Delta
+)
+
+// String returns the name of a transformation.
+func (tf Transform) String() string {
+	switch tf {
+	case Lambda:
+		return "lambda"
+	case Kappa:
+		return "kappa"
+	case Delta:
+		return "delta"
+	}
+	return "unknown"
+}
+
+// ParseTransform returns a transformation from its name.
+func ParseTransform(name string) (Transform, error) {
+	switch name {
+	case "lambda":
+		return Lambda, nil
+	case "kappa":
+		return Kappa, nil
+	case "delta":
+		return Delta, nil
+	}
+	return 0, errors.Errorf("likelihood: unknown transformation %q", name)
+}
+
+// Apply returns a copy of a tree
+// transformed with a given parameter value.
+func (tf Transform) apply(t *tree.Tree, v float64) *tree.Tree {
+	t = t.Copy()
+	switch tf {
+	case Lambda:
+		t.Lambda(v)
+	case Kappa:
+		t.Kappa(v)
+	case Delta:
+		t.Delta(v)
+	}
+	return t
+}
+
+// Bounds returns the range
+// of the parameter values
+// used in the estimation.
+func (tf Transform) bounds() (min, max float64) {
+	if tf == Delta {
+		return 0.01, 3
+	}
+	return 0, 1
+}
+
+// A PagelFit is the estimation
+// of a Pagel's transformation parameter
+// for a character.
+type PagelFit struct {
+	Transform Transform
+	Value     float64 // maximum likelihood estimate of the parameter
+	Rate      float64 // rate with the estimated parameter
+	LogLike   float64 // log likelihood with the estimated parameter
+	NullLike  float64 // log likelihood of the untransformed tree
+	LR        float64 // likelihood ratio statistic
+	P         float64 // p-value, from a chi-square with 1 df
+}
+
+// FitPagel estimates a transformation parameter
+// using a given likelihood function,
+// that returns the log likelihood,
+// and the rate,
+// of a tree.
+// The null model is the untransformed tree
+// (i.e. a parameter value of 1).
+func fitPagel(t *tree.Tree, tf Transform, like func(t *tree.Tree) (float64, float64, error)) (PagelFit, error) {
+	f := PagelFit{Transform: tf, Value: 1}
+	var err error
+	f.NullLike, f.Rate, err = like(t)
+	if err != nil {
+		return PagelFit{}, err
+	}
+	f.LogLike = f.NullLike
+
+	min, max := tf.bounds()
+	v := goldenMax(func(v float64) float64 {
+		l, _, err := like(tf.apply(t, v))
+		if err != nil {
+			return math.Inf(-1)
+		}
+		return l
+	}, min, max)
+	if l, r, err := like(tf.apply(t, v)); err == nil && l > f.LogLike {
+		f.Value, f.Rate, f.LogLike = v, r, l
+	}
+
+	f.LR = 2 * (f.LogLike - f.NullLike)
+	f.P = chiSquare1(f.LR)
+	return f, nil
+}
+
+// FitPagelContinuous estimates a transformation parameter
+// for a continuous character,
+// under a Brownian motion model.
+// Terminals without a value are pruned from the tree.
+// The rate is the variance of the Brownian motion
+// (sigma squared).
+func FitPagelContinuous(t *tree.Tree, vals map[string]float64, tf Transform) (PagelFit, error) {
+	var del []string
+	for _, nm := range t.Terms() {
+		if _, ok := vals[nm]; !ok {
+			del = append(del, nm)
+		}
+	}
+	if len(del) > 0 {
+		t = t.Copy()
+		t.Prune(del)
+	}
+	if len(t.Terms()) < 3 {
+		return PagelFit{}, errors.New("likelihood: pagel: less than three terminals with data")
+	}
+	f, err := fitPagel(t, tf, func(t *tree.Tree) (float64, float64, error) {
+		return brownianLike(t, vals)
+	})
+	if err != nil {
+		return PagelFit{}, errors.Wrap(err, "likelihood: pagel")
+	}
+	return f, nil
+}
+
+// FitPagelDiscrete estimates a transformation parameter
+// for a discrete character,
+// using the model of the character in the matrix.
+// The rate is in changes per unit
+// of branch length.
+func FitPagelDiscrete(t *tree.Tree, m *Matrix, char int, tf Transform) (PagelFit, error) {
+	if char < 0 || char >= m.Chars() {
+		return PagelFit{}, errors.Errorf("likelihood: pagel: invalid character %d", char+1)
+	}
+	for _, nm := range t.Terms() {
+		if m.M.Names[nm] == nil {
+			return PagelFit{}, errors.Errorf("likelihood: pagel: terminal %s not in matrix", nm)
+		}
+	}
+	f, err := fitPagel(t, tf, func(t *tree.Tree) (float64, float64, error) {
+		l, r := mkLike(t, m, char)
+		return l, r, nil
+	})
+	if err != nil {
+		return PagelFit{}, errors.Wrap(err, "likelihood: pagel")
+	}
+	return f, nil
+}
+
+// MkLike returns the log likelihood
+// of a discrete character,
+// with the maximum likelihood estimate
+// of the rate,
+// that is also returned.
+func mkLike(t *tree.Tree, m *Matrix, char int) (logLike, rate float64) {
+	md := m.Model(char)
+	max := float64(0)
+	for _, n := range t.Nodes() {
+		if n != t.Root && n.Len > max {
+			max = n.Len
+		}
+	}
+	if max == 0 {
+		max = 1
+	}
+	like := func(r float64) float64 {
+		cond := mkCond(t.Root, md, m, char, r)
+		l := float64(0)
+		for s, p := range cond {
+			l += p * md.Freq(s)
+		}
+		return math.Log(l)
+	}
+	lr := goldenMax(func(lr float64) float64 {
+		return like(math.Exp(lr))
+	}, math.Log(0.0001/max), math.Log(100/max))
+	rate = math.Exp(lr)
+	return like(rate), rate
+}
+
+// MkCond returns the conditional likelihood
+// of a discrete character on a node
+// of a tree topology,
+// with the branch lengths multiplied by a rate.
+func mkCond(n *tree.Node, md Model, m *Matrix, char int, rate float64) Conditional {
+	cond := make(Conditional, md.States())
+	if n.IsTerm() {
+		st := m.M.Names[n.Name].State(char)
+		for s := range cond {
+			if st&(1<<uint32(s)) != 0 {
+				cond[s] = 1
+			}
+		}
+		return cond
+	}
+	for s := range cond {
+		cond[s] = 1
+	}
+	for _, d := range n.Desc {
+		dc := mkCond(d, md, m, char, rate)
+		for s := range cond {
+			l := float64(0)
+			for x, p := range dc {
+				l += md.Prob(s, x, d.Len*rate) * p
+			}
+			cond[s] *= l
+		}
+	}
+	return cond
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/tree"
+)
+
+func TestPagel(t *testing.T) {
+	blob := "(((A:1,B:1):1,(C:1,D:1):1):1,((E:1,F:1):1,(G:1,H:1):1):1);"
+	tp, err := tree.Read(strings.NewReader(blob))
+	if err != nil {
+		t.Fatalf("likelihood: pagel: unexpected error: %v", err)
+	}
+	vals := map[string]float64{"A": 1.2, "B": 1.5, "C": 2.1, "D": 1.9, "E": 4.2, "F": 3.9, "G": 5.5, "H": 5.1}
+	m, err := NewMatrix(strings.NewReader(twoRateBlob))
+	if err != nil {
+		t.Fatalf("likelihood: pagel: unexpected error while reading matrix: %v", err)
+	}
+
+	for _, name := range []string{"lambda", "kappa", "delta"} {
+		tf, err := ParseTransform(name)
+		if err != nil {
+			t.Fatalf("likelihood: pagel: unexpected error: %v", err)
+		}
+		if tf.String() != name {
+			t.Errorf("likelihood: pagel: transformation %q, want %q", tf, name)
+		}
+		min, max := tf.bounds()
+
+		f, err := FitPagelContinuous(tp, vals, tf)
+		if err != nil {
+			t.Fatalf("likelihood: pagel: %s: unexpected error: %v", name, err)
+		}
+		if f.LogLike < f.NullLike || f.LR < 0 || f.P < 0 || f.P > 1 {
+			t.Errorf("likelihood: pagel: %s: continuous: log likelihood %.6f, null %.6f, p-value %.6f", name, f.LogLike, f.NullLike, f.P)
+		}
+		if f.Value < min || f.Value > max {
+			t.Errorf("likelihood: pagel: %s: continuous: value %.6f, out of range", name, f.Value)
+		}
+
+		for c := 0; c < m.Chars(); c++ {
+			f, err := FitPagelDiscrete(tp, m, c, tf)
+			if err != nil {
+				t.Fatalf("likelihood: pagel: %s: unexpected error: %v", name, err)
+			}
+			if f.LogLike < f.NullLike || f.LR < 0 || f.P < 0 || f.P > 1 {
+				t.Errorf("likelihood: pagel: %s: char %d: log likelihood %.6f, null %.6f, p-value %.6f", name, c+1, f.LogLike, f.NullLike, f.P)
+			}
+		}
+	}
+
+	// with a terminal without data
+	// the tree is pruned
+	delete(vals, "H")
+	if _, err := FitPagelContinuous(tp, vals, Lambda); err != nil {
+		t.Errorf("likelihood: pagel: unexpected error: %v", err)
+	}
+	if len(tp.Terms()) != 8 {
+		t.Errorf("likelihood: pagel: original tree modified")
+	}
+
+	if _, err := ParseTransform("omega"); err == nil {
+		t.Errorf("likelihood: pagel: expecting error on unknown transformation")
+	}
+	other, _ := tree.Read(strings.NewReader("((A:1,B:1):1,(C:1,X:1):1);"))
+	if _, err := FitPagelDiscrete(other, m, 0, Lambda); err == nil {
+		t.Errorf("likelihood: pagel: expecting error on terminal not in matrix")
+	}
+}
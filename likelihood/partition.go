@@ -0,0 +1,244 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"math"
+	"sort"
+
+	"github.com/js-arias/ramita/matrix"
+)
+
+// Rated is a model
+// with a relative rate,
+// for example,
+// the model of a partition
+// that evolves faster
+// than the rest of the data.
+type Rated struct {
+	Model
+	Rate float64
+}
+
+// Prob is the probability of change
+// from one state to another,
+// with a given branch length,
+// multiplied by the relative rate.
+func (r *Rated) Prob(from, to int, blen float64) float64 {
+	return r.Model.Prob(from, to, blen*r.Rate)
+}
+
+// A PartitionFit is the fit of a partition
+// on a tree.
+type PartitionFit struct {
+	Partition matrix.Partition
+	LogLike   float64 // log likelihood of the partition
+	Rate      float64 // maximum likelihood relative rate
+}
+
+// FitPartition returns the maximum likelihood fit
+// of a partition,
+// using the current branch lengths of the tree.
+// The only free parameter of the partition
+// is its relative rate.
+func (tr *Tree) FitPartition(p matrix.Partition) PartitionFit {
+	like := func(lr float64) float64 {
+		return tr.partLike(p.Chars, math.Exp(lr))
+	}
+
+	// golden section search on the log of the rate
+	a, b := math.Log(0.0001), math.Log(1000)
+	g := (math.Sqrt(5) - 1) / 2
+	c := b - g*(b-a)
+	d := a + g*(b-a)
+	lc, ld := like(c), like(d)
+	for b-a > 0.0001 {
+		if lc > ld {
+			b, d, ld = d, c, lc
+			c = b - g*(b-a)
+			lc = like(c)
+			continue
+		}
+		a, c, lc = c, d, ld
+		d = a + g*(b-a)
+		ld = like(d)
+	}
+	r := (a + b) / 2
+	return PartitionFit{
+		Partition: p,
+		LogLike:   like(r),
+		Rate:      math.Exp(r),
+	}
+}
+
+// PartLike returns the log likelihood
+// of a set of characters,
+// with the branch lengths of the tree
+// multiplied by a rate.
+func (tr *Tree) partLike(chars []int, rate float64) float64 {
+	logLike := float64(0)
+	cats := tr.M.rateCats()
+	vp := make(map[string]float64)
+	for _, c := range chars {
+		md := tr.M.Model(c)
+		like := float64(0)
+		for _, r := range cats {
+			cond := tr.Root.rateCond(md, c, rate*r)
+			for s, p := range cond {
+				like += p * md.Freq(s)
+			}
+		}
+		l := math.Log(like / float64(len(cats)))
+		if isVariable(md) {
+			id := tr.M.model[c]
+			v, ok := vp[id]
+			if !ok {
+				v = tr.varProb(md, rate)
+				vp[id] = v
+			}
+			l -= math.Log(v)
+		}
+		logLike += float64(tr.M.M.Count(c)) * l
+	}
+	return logLike
+}
+
+// RateCond returns the conditional likelihood
+// of a character on a node,
+// with the branch lengths multiplied by a rate.
+func (n *Node) rateCond(md Model, c int, rate float64) Conditional {
+	if n.Term != nil {
+		return n.Cond[c]
+	}
+	left := n.Left.rateCond(md, c, rate)
+	right := n.Right.rateCond(md, c, rate)
+	cond := make(Conditional, len(left))
+	for s := range cond {
+		l, r := float64(0), float64(0)
+		for x := range left {
+			l += md.Prob(s, x, n.Left.Len*rate) * left[x]
+			r += md.Prob(s, x, n.Right.Len*rate) * right[x]
+		}
+		cond[s] = l * r
+	}
+	return cond
+}
+
+// BIC returns the Bayesian Information Criterion
+// of a partition scheme.
+// The number of parameters
+// is the number of branches of the tree,
+// plus a relative rate for each partition
+// (except one, as the rates are relative).
+func (tr *Tree) BIC(fits []PartitionFit) float64 {
+	logLike := float64(0)
+	sites := 0
+	for _, f := range fits {
+		logLike += f.LogLike
+		sites += len(f.Partition.Chars)
+	}
+	k := len(tr.Nodes) - 1 + len(fits) - 1
+	return -2*logLike + float64(k)*math.Log(float64(sites))
+}
+
+// MergePartitions searches for a partition scheme
+// using greedy merging of partitions:
+// at each step,
+// the pair of partitions
+// whose merging produces the best improvement
+// of the BIC of the scheme
+// is merged,
+// until no merge improves the BIC.
+// It uses the current branch lengths of the tree.
+func (tr *Tree) MergePartitions(ps []matrix.Partition) []PartitionFit {
+	fits := make([]PartitionFit, 0, len(ps))
+	for _, p := range ps {
+		fits = append(fits, tr.FitPartition(p))
+	}
+
+	cache := make(map[string]PartitionFit)
+	best := tr.BIC(fits)
+	for len(fits) > 1 {
+		bi, bj := -1, -1
+		var bf PartitionFit
+		for i := 0; i < len(fits); i++ {
+			for j := i + 1; j < len(fits); j++ {
+				p := mergePartitions(fits[i].Partition, fits[j].Partition)
+				f, ok := cache[p.Name]
+				if !ok {
+					f = tr.FitPartition(p)
+					cache[p.Name] = f
+				}
+				scheme := make([]PartitionFit, 0, len(fits)-1)
+				scheme = append(scheme, f)
+				for x, o := range fits {
+					if x != i && x != j {
+						scheme = append(scheme, o)
+					}
+				}
+				if b := tr.BIC(scheme); b < best {
+					best = b
+					bi, bj, bf = i, j, f
+				}
+			}
+		}
+		if bi < 0 {
+			break
+		}
+		fits[bi] = bf
+		fits = append(fits[:bj], fits[bj+1:]...)
+	}
+	return fits
+}
+
+// MergePartitions returns a new partition
+// with the characters of two partitions.
+func mergePartitions(a, b matrix.Partition) matrix.Partition {
+	p := matrix.Partition{
+		Name:  a.Name + "+" + b.Name,
+		Chars: make([]int, 0, len(a.Chars)+len(b.Chars)),
+	}
+	p.Chars = append(p.Chars, a.Chars...)
+	p.Chars = append(p.Chars, b.Chars...)
+	sort.Ints(p.Chars)
+	return p
+}
+
+// SetPartitions sets a relative rate model
+// for each partition,
+// using the rates estimated
+// with the current branch lengths of the tree.
+func (tr *Tree) SetPartitions(ps []matrix.Partition) []PartitionFit {
+	fits := make([]PartitionFit, 0, len(ps))
+	for _, p := range ps {
+		f := tr.FitPartition(p)
+		fits = append(fits, f)
+		mds := make(map[string]*Rated)
+		for _, c := range p.Chars {
+			id := tr.M.model[c]
+			md, ok := mds[id]
+			if !ok {
+				md = &Rated{Model: tr.M.mds[id], Rate: f.Rate}
+				mds[id] = md
+			}
+			tr.M.model[c] = p.Name + ":" + id
+			tr.M.mds[tr.M.model[c]] = md
+		}
+	}
+	tr.Root.downPass(tr.M)
+	return fits
+}
+
+// SiteRates returns the maximum likelihood relative rate
+// of each character,
+// using the current branch lengths of the tree.
+func (tr *Tree) SiteRates() []float64 {
+	rates := make([]float64, tr.M.Chars())
+	for c := range rates {
+		f := tr.FitPartition(matrix.Partition{Chars: []int{c}})
+		rates[c] = f.Rate
+	}
+	return rates
+}
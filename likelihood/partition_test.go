@@ -0,0 +1,70 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/tree"
+)
+
+func TestMergePartitions(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("likelihood: partition: unexpected error while reading matrix: %v", err)
+	}
+	tp, err := tree.Read(strings.NewReader(treeLenBlob))
+	if err != nil {
+		t.Fatalf("likelihood: partition: unexpected error while reading tree: %v", err)
+	}
+	tr, err := FromTopology(tp, m)
+	if err != nil {
+		t.Fatalf("likelihood: partition: unexpected error: %v", err)
+	}
+
+	all := make([]int, m.Chars())
+	for i := range all {
+		all[i] = i
+	}
+	like := tr.Like()
+	if l := tr.partLike(all, 1); math.Abs(l-like) > 0.000001 {
+		t.Errorf("likelihood: partition: likelihood %.6f, want %.6f", l, like)
+	}
+	if f := tr.FitPartition(matrix.Partition{Name: "all", Chars: all}); f.LogLike < like-0.000001 {
+		t.Errorf("likelihood: partition: fitted likelihood %.6f, less than %.6f", f.LogLike, like)
+	}
+
+	ps := m.M.CodonPartitions([]matrix.Partition{{Name: "all", Chars: all}})
+	var init []PartitionFit
+	for _, p := range ps {
+		init = append(init, tr.FitPartition(p))
+	}
+	fits := tr.MergePartitions(ps)
+	if len(fits) > len(ps) {
+		t.Errorf("likelihood: partition: %d partitions, want at most %d", len(fits), len(ps))
+	}
+	if b, ib := tr.BIC(fits), tr.BIC(init); b > ib {
+		t.Errorf("likelihood: partition: merged BIC %.6f, greater than %.6f", b, ib)
+	}
+	chars := 0
+	for _, f := range fits {
+		chars += len(f.Partition.Chars)
+	}
+	if chars != m.Chars() {
+		t.Errorf("likelihood: partition: %d characters in scheme, want %d", chars, m.Chars())
+	}
+
+	fits = tr.SetPartitions(ps)
+	sum := float64(0)
+	for _, f := range fits {
+		sum += f.LogLike
+	}
+	if math.Abs(tr.Like()-sum) > 0.000001 {
+		t.Errorf("likelihood: partition: likelihood %.6f, want %.6f", tr.Like(), sum)
+	}
+}
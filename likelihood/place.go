@@ -0,0 +1,119 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"math"
+	"sort"
+
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+// A Placement is a position
+// of a query terminal
+// on a branch of a reference tree.
+type Placement struct {
+	Edge    int     // index of the node at the end of the branch
+	Like    float64 // log likelihood of the tree with the query
+	Weight  float64 // likelihood weight ratio
+	Distal  float64 // length from the node to the query
+	Pendant float64 // length of the branch of the query
+}
+
+// Place returns the placements
+// of a query terminal
+// on each branch of a reference tree,
+// sorted by its likelihood.
+// The branch is identified
+// by the index of the node at its end,
+// in pre-order,
+// as returned by the Nodes method
+// of the reference tree.
+// The matrix must have the terminals
+// of the reference tree
+// and the query terminal.
+// In each placement,
+// only the lengths of the branches
+// adjacent to the query are estimated,
+// the other branch lengths,
+// and the model parameters,
+// are not modified.
+func Place(t *tree.Tree, m *Matrix, name string) ([]Placement, error) {
+	if m.M.Names[name] == nil {
+		return nil, errors.Errorf("likelihood: place: terminal %s not in matrix", name)
+	}
+	for _, nm := range t.Terms() {
+		if nm == name {
+			return nil, errors.Errorf("likelihood: place: terminal %s already in tree", name)
+		}
+	}
+
+	nodes := t.Nodes()
+	ps := make([]Placement, 0, len(nodes)-1)
+	for i := 1; i < len(nodes); i++ {
+		ct := t.Copy()
+		q := &tree.Node{Name: name, Len: 0.1}
+		if err := ct.Graft(q, ct.Nodes()[i]); err != nil {
+			return nil, errors.Wrap(err, "likelihood: place")
+		}
+		tr, err := FromTopology(ct, m)
+		if err != nil {
+			return nil, errors.Wrap(err, "likelihood: place")
+		}
+		p := tr.placement(name)
+		p.Edge = i
+		ps = append(ps, p)
+	}
+
+	max := math.Inf(-1)
+	for _, p := range ps {
+		if p.Like > max {
+			max = p.Like
+		}
+	}
+	sum := float64(0)
+	for i := range ps {
+		ps[i].Weight = math.Exp(ps[i].Like - max)
+		sum += ps[i].Weight
+	}
+	for i := range ps {
+		ps[i].Weight /= sum
+	}
+	sort.SliceStable(ps, func(i, j int) bool {
+		return ps[i].Like > ps[j].Like
+	})
+	return ps, nil
+}
+
+// Placement estimates the lengths
+// of the branches around a query terminal
+// and returns its placement.
+func (tr *Tree) placement(name string) Placement {
+	var q *Node
+	for _, n := range tr.Nodes {
+		if n.Term != nil && n.Term.Name == name {
+			q = n
+			break
+		}
+	}
+	a := q.Anc
+	sister := a.Left
+	if sister == q {
+		sister = a.Right
+	}
+
+	tr.refine(q, 0.1)
+	tr.refine(sister, 0.1)
+	if a != tr.Root {
+		tr.refine(a, 0.1)
+	}
+	return Placement{
+		Like:    tr.Like(),
+		Distal:  sister.Len,
+		Pendant: q.Len,
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/tree"
+)
+
+func TestPlace(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("likelihood: place: unexpected error while reading matrix: %v", err)
+	}
+	ref, err := tree.Read(strings.NewReader(treeLenBlob))
+	if err != nil {
+		t.Fatalf("likelihood: place: unexpected error while reading tree: %v", err)
+	}
+	query := "Argopecten_irradians"
+	ref.Prune([]string{query})
+
+	ps, err := Place(ref, m, query)
+	if err != nil {
+		t.Fatalf("likelihood: place: unexpected error: %v", err)
+	}
+	nodes := ref.Nodes()
+	if len(ps) != len(nodes)-1 {
+		t.Errorf("likelihood: place: %d placements, want %d", len(ps), len(nodes)-1)
+	}
+	sum := float64(0)
+	for i, p := range ps {
+		sum += p.Weight
+		if i > 0 && p.Like > ps[i-1].Like {
+			t.Errorf("likelihood: place: placement %d: like %.6f, better than %.6f", i, p.Like, ps[i-1].Like)
+		}
+	}
+	if math.Abs(sum-1) > 1e-6 {
+		t.Errorf("likelihood: place: sum of weights %.6f, want %.6f", sum, 1.0)
+	}
+	if nm := nodes[ps[0].Edge].Name; nm != "Chlamys_islandica" {
+		t.Errorf("likelihood: place: best placement at %q, want %q", nm, "Chlamys_islandica")
+	}
+
+	if _, err := Place(ref, m, "Chlamys_islandica"); err == nil {
+		t.Errorf("likelihood: place: expecting error on terminal already in tree")
+	}
+}
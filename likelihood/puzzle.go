@@ -0,0 +1,589 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"math"
+	"math/bits"
+	"math/rand"
+
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/tree"
+)
+
+// Quartets stores the maximum likelihood topology
+// of each quartet of terminals
+// of a matrix.
+type Quartets struct {
+	M    *Matrix
+	Taxa []*matrix.Terminal
+
+	minOverlap int
+	best       map[[4]int]uint32
+}
+
+// Quartets evaluates the three possible topologies
+// of each quartet of terminals in the matrix,
+// and stores the topology with the best likelihood.
+// The branch lengths of each quartet topology
+// are optimized.
+//
+// The terminal branch lengths are estimated
+// from pairwise distances,
+// using the sites in which both terminals
+// have an unambiguous state
+// (i.e. pairwise deletion of missing data).
+// If a pair of terminals shares
+// less than minOverlap sites,
+// its distance is meaningless,
+// and a default distance of 0.1 is used
+// (see Overlaps).
+//
+// The topologies of a quartet a < b < c < d
+// are coded as 0 for ab|cd,
+// 1 for ac|bd,
+// and 2 for ad|bc.
+func (m *Matrix) Quartets(minOverlap int) *Quartets {
+	q := &Quartets{
+		M:          m,
+		Taxa:       m.M.Taxa(),
+		minOverlap: minOverlap,
+		best:       make(map[[4]int]uint32),
+	}
+	n := len(q.Taxa)
+	for a := 0; a < n; a++ {
+		for b := a + 1; b < n; b++ {
+			for c := b + 1; c < n; c++ {
+				for d := c + 1; d < n; d++ {
+					k := [4]int{a, b, c, d}
+					q.best[k] = q.evaluate(k)
+				}
+			}
+		}
+	}
+	return q
+}
+
+// Len returns the number of quartets.
+func (q *Quartets) Len() int {
+	return len(q.best)
+}
+
+// A qPattern is a site pattern
+// of a quartet.
+type qPattern struct {
+	md     Model
+	states [4]uint32
+	count  float64
+}
+
+// Evaluate returns the topology
+// with the best likelihood
+// of a quartet.
+func (q *Quartets) evaluate(k [4]int) uint32 {
+	var taxa [4]*matrix.Terminal
+	for i, t := range k {
+		taxa[i] = q.Taxa[t]
+	}
+	pats := q.M.qPatterns(taxa[:])
+
+	orders := [3][4]int{
+		{0, 1, 2, 3},
+		{0, 2, 1, 3},
+		{0, 3, 1, 2},
+	}
+	best := uint32(0)
+	max := math.Inf(-1)
+	for i, o := range orders {
+		if l := quartetLike(pats, o, q.M.rateCats(), q.minOverlap); l > max {
+			max = l
+			best = uint32(i)
+		}
+	}
+	return best
+}
+
+// QPatterns returns the site patterns
+// of up to four terminals.
+func (m *Matrix) qPatterns(taxa []*matrix.Terminal) []qPattern {
+	idx := make(map[qPattern]int)
+	var pats []qPattern
+	for c := range m.model {
+		p := qPattern{md: m.Model(c)}
+		for i, t := range taxa {
+			p.states[i] = t.State(c)
+		}
+		if j, ok := idx[p]; ok {
+			pats[j].count++
+			continue
+		}
+		idx[p] = len(pats)
+		p.count = 1
+		pats = append(pats, p)
+	}
+	return pats
+}
+
+// QuartetLike returns the log likelihood
+// of a quartet topology pq|rs,
+// averaged over the indicated rate categories.
+// Terminal branch lengths are estimated
+// by least squares from the pairwise distances,
+// and the length of the internal branch
+// is optimized.
+func quartetLike(pats []qPattern, o [4]int, rates []float64, minOverlap int) float64 {
+	d := func(i, j int) float64 {
+		return quartetDist(pats, o[i], o[j], minOverlap)
+	}
+	dpq, drs := d(0, 1), d(2, 3)
+	dpr, dps, dqr, dqs := d(0, 2), d(0, 3), d(1, 2), d(1, 3)
+	var lens [5]float64
+	lens[0] = (dpq + (dpr+dps)/2 - (dqr+dqs)/2) / 2
+	lens[1] = dpq - lens[0]
+	lens[2] = (drs + (dpr+dqr)/2 - (dps+dqs)/2) / 2
+	lens[3] = drs - lens[2]
+	lens[4] = (dpr+dps+dqr+dqs)/4 - (dpq+drs)/2
+	for i, l := range lens {
+		if l < 0.0001 {
+			lens[i] = 0.0001
+		}
+	}
+
+	// the transition probabilities
+	// of each terminal branch
+	// are fixed
+	type probMatrix [5][]float64
+	probs := make([]map[Model]*probMatrix, len(rates))
+	setProbs := func(b int) {
+		for k, r := range rates {
+			for md, pm := range probs[k] {
+				ns := md.States()
+				for s := 0; s < ns; s++ {
+					for x := 0; x < ns; x++ {
+						pm[b][s*ns+x] = md.Prob(s, x, lens[b]*r)
+					}
+				}
+			}
+		}
+	}
+	for k := range rates {
+		probs[k] = make(map[Model]*probMatrix)
+		for _, p := range pats {
+			if _, ok := probs[k][p.md]; ok {
+				continue
+			}
+			pm := &probMatrix{}
+			ns := p.md.States()
+			for b := range pm {
+				pm[b] = make([]float64, ns*ns)
+			}
+			probs[k][p.md] = pm
+		}
+	}
+	for b := range lens {
+		setProbs(b)
+	}
+
+	// conditionals at both sides
+	// of the internal branch,
+	// for each rate category
+	left := make([][][]float64, len(rates))
+	right := make([][][]float64, len(rates))
+	for k := range rates {
+		left[k] = make([][]float64, len(pats))
+		right[k] = make([][]float64, len(pats))
+		for i, p := range pats {
+			pm := probs[k][p.md]
+			ns := p.md.States()
+			term := func(b, s int) float64 {
+				st := p.states[o[b]]
+				v := float64(0)
+				for x := 0; x < ns; x++ {
+					if st&(1<<uint(x)) != 0 {
+						v += pm[b][s*ns+x]
+					}
+				}
+				return v
+			}
+			left[k][i] = make([]float64, ns)
+			right[k][i] = make([]float64, ns)
+			for s := 0; s < ns; s++ {
+				left[k][i][s] = p.md.Freq(s) * term(0, s) * term(1, s)
+				right[k][i][s] = term(2, s) * term(3, s)
+			}
+		}
+	}
+
+	like := func(l float64) float64 {
+		lens[4] = l
+		setProbs(4)
+		logLike := float64(0)
+		for i, p := range pats {
+			ns := p.md.States()
+			site := float64(0)
+			for k := range rates {
+				pm := probs[k][p.md]
+				for s, lv := range left[k][i] {
+					r := float64(0)
+					for y, rv := range right[k][i] {
+						r += pm[4][s*ns+y] * rv
+					}
+					site += lv * r
+				}
+			}
+			logLike += p.count * math.Log(site/float64(len(rates)))
+		}
+		return logLike
+	}
+
+	// golden section search
+	// on the log of the internal branch length
+	g := (math.Sqrt(5) - 1) / 2
+	f := func(ll float64) float64 {
+		return like(math.Exp(ll))
+	}
+	x, y := math.Log(0.0001), math.Log(10)
+	c := y - g*(y-x)
+	dd := x + g*(y-x)
+	lc, ld := f(c), f(dd)
+	for y-x > 0.01 {
+		if lc > ld {
+			y, dd, ld = dd, c, lc
+			c = y - g*(y-x)
+			lc = f(c)
+			continue
+		}
+		x, c, lc = c, dd, ld
+		dd = x + g*(y-x)
+		ld = f(dd)
+	}
+	return f((x + y) / 2)
+}
+
+// QuartetDist returns the corrected distance
+// between two terminals of a quartet,
+// under a Poisson model.
+// If the terminals share less than min sites,
+// a default distance is returned.
+func quartetDist(pats []qPattern, a, b, min int) float64 {
+	sites, diff, states := pairSites(pats, a, b)
+	if sites == 0 || sites < float64(min) {
+		return 0.1
+	}
+	pd := diff / sites
+	s := states / sites
+	v := 1 - pd*s/(s-1)
+	if v <= 0 {
+		return 10
+	}
+	return -(s - 1) / s * math.Log(v)
+}
+
+// PairSites returns the number of sites
+// in which two terminals
+// have an unambiguous state
+// (see unambiguous),
+// the number of those sites
+// with different states,
+// and the sum of the number of states
+// of the models of those sites.
+func pairSites(pats []qPattern, a, b int) (sites, diff, states float64) {
+	for _, p := range pats {
+		sa, sb := p.states[a], p.states[b]
+		n := p.md.States()
+		if !unambiguous(sa, n) || !unambiguous(sb, n) {
+			continue
+		}
+		sites += p.count
+		states += p.count * float64(n)
+		if sa != sb {
+			diff += p.count
+		}
+	}
+	return sites, diff, states
+}
+
+// Unambiguous returns true
+// if a state set has a single state
+// of a model with the indicated number of states.
+// A state outside the model
+// (e.g. a gap, when gaps are a state)
+// is not unambiguous.
+func unambiguous(st uint32, states int) bool {
+	return st < 1<<uint(states) && bits.OnesCount32(st) == 1
+}
+
+// Partner returns the terminal
+// that is the sister of x
+// in the best topology of the quartet
+// formed by x, a, b, and c.
+func (q *Quartets) partner(x, a, b, c int) int {
+	k := [4]int{x, a, b, c}
+	for i := 1; i < 4; i++ {
+		for j := i; j > 0 && k[j] < k[j-1]; j-- {
+			k[j], k[j-1] = k[j-1], k[j]
+		}
+	}
+	var pairs [2][2]int
+	switch q.best[k] {
+	case 0:
+		pairs = [2][2]int{{k[0], k[1]}, {k[2], k[3]}}
+	case 1:
+		pairs = [2][2]int{{k[0], k[2]}, {k[1], k[3]}}
+	default:
+		pairs = [2][2]int{{k[0], k[3]}, {k[1], k[2]}}
+	}
+	for _, p := range pairs {
+		if p[0] == x {
+			return p[1]
+		}
+		if p[1] == x {
+			return p[0]
+		}
+	}
+	return -1
+}
+
+// A puzzleTree is an unrooted tree
+// used during a puzzling step.
+// Nodes are identified by integers,
+// terminals have the same identifier
+// as its index in the taxa list.
+type puzzleTree struct {
+	adj map[int][]int
+	nxt int // next internal node
+}
+
+// Puzzle performs the indicated number
+// of puzzling steps,
+// and returns the intermediate tree
+// built in each step,
+// rooted at the outgroup.
+//
+// In each puzzling step,
+// terminals are added in a random order,
+// and each terminal is added
+// at the branch with the smallest number
+// of contradictions
+// with the quartet topologies.
+func (q *Quartets) Puzzle(steps int) []*tree.Tree {
+	n := len(q.Taxa)
+	if n < 4 {
+		return nil
+	}
+	trees := make([]*tree.Tree, 0, steps)
+	for i := 0; i < steps; i++ {
+		trees = append(trees, q.PuzzleStep())
+	}
+	return trees
+}
+
+// PuzzleStep performs a single puzzling step,
+// and returns the intermediate tree,
+// rooted at the outgroup.
+// It returns nil
+// if there are less than four terminals.
+func (q *Quartets) PuzzleStep() *tree.Tree {
+	n := len(q.Taxa)
+	if n < 4 {
+		return nil
+	}
+	order := rand.Perm(n)
+	pt := &puzzleTree{adj: make(map[int][]int), nxt: n}
+
+	// initial quartet
+	a, b, c, d := order[0], order[1], order[2], order[3]
+	p := q.partner(a, b, c, d)
+	var o1, o2 int
+	switch p {
+	case b:
+		o1, o2 = c, d
+	case c:
+		o1, o2 = b, d
+	default:
+		o1, o2 = b, c
+	}
+	u, v := pt.newNode(), pt.newNode()
+	pt.link(u, a)
+	pt.link(u, p)
+	pt.link(u, v)
+	pt.link(v, o1)
+	pt.link(v, o2)
+
+	for i := 4; i < n; i++ {
+		x := order[i]
+		penalty := make(map[[2]int]int)
+		for _, e := range pt.edges() {
+			penalty[e] = 0
+		}
+		for j := 0; j < i; j++ {
+			for k := j + 1; k < i; k++ {
+				for l := k + 1; l < i; l++ {
+					a, b, c := order[j], order[k], order[l]
+					p := q.partner(x, a, b, c)
+					var o1, o2 int
+					switch p {
+					case a:
+						o1, o2 = b, c
+					case b:
+						o1, o2 = a, c
+					default:
+						o1, o2 = a, b
+					}
+					for _, e := range pt.path(o1, o2) {
+						penalty[e]++
+					}
+				}
+			}
+		}
+		var best [][2]int
+		min := -1
+		for _, e := range pt.edges() {
+			v := penalty[e]
+			if min < 0 || v < min {
+				min = v
+				best = best[:0]
+			}
+			if v == min {
+				best = append(best, e)
+			}
+		}
+		pt.insert(x, best[rand.Intn(len(best))])
+	}
+	return pt.toTree(q.Taxa)
+}
+
+// NewNode returns a new internal node.
+func (pt *puzzleTree) newNode() int {
+	v := pt.nxt
+	pt.nxt++
+	return v
+}
+
+// Link connects two nodes.
+func (pt *puzzleTree) link(u, v int) {
+	pt.adj[u] = append(pt.adj[u], v)
+	pt.adj[v] = append(pt.adj[v], u)
+}
+
+// Unlink removes the connection of two nodes.
+func (pt *puzzleTree) unlink(u, v int) {
+	remove := func(ls []int, x int) []int {
+		for i, y := range ls {
+			if y == x {
+				return append(ls[:i], ls[i+1:]...)
+			}
+		}
+		return ls
+	}
+	pt.adj[u] = remove(pt.adj[u], v)
+	pt.adj[v] = remove(pt.adj[v], u)
+}
+
+// Edges returns the edges of the tree,
+// sorted.
+func (pt *puzzleTree) edges() [][2]int {
+	var ls [][2]int
+	for u := 0; u < pt.nxt; u++ {
+		for _, v := range pt.adj[u] {
+			if u < v {
+				ls = append(ls, [2]int{u, v})
+			}
+		}
+	}
+	return ls
+}
+
+// Path returns the edges in the path
+// between two nodes.
+func (pt *puzzleTree) path(from, to int) [][2]int {
+	anc := map[int]int{from: -1}
+	stack := []int{from}
+	for len(stack) > 0 {
+		u := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if u == to {
+			break
+		}
+		for _, v := range pt.adj[u] {
+			if _, ok := anc[v]; ok {
+				continue
+			}
+			anc[v] = u
+			stack = append(stack, v)
+		}
+	}
+	var ls [][2]int
+	for v := to; anc[v] >= 0; v = anc[v] {
+		u := anc[v]
+		if u < v {
+			ls = append(ls, [2]int{u, v})
+		} else {
+			ls = append(ls, [2]int{v, u})
+		}
+	}
+	return ls
+}
+
+// Insert adds a terminal
+// at the given edge.
+func (pt *puzzleTree) insert(x int, e [2]int) {
+	w := pt.newNode()
+	pt.unlink(e[0], e[1])
+	pt.link(e[0], w)
+	pt.link(w, e[1])
+	pt.link(w, x)
+}
+
+// ToTree returns the tree as a tree.Tree,
+// rooted at the first terminal.
+func (pt *puzzleTree) toTree(taxa []*matrix.Terminal) *tree.Tree {
+	var build func(u, anc int) *tree.Node
+	build = func(u, anc int) *tree.Node {
+		if u < len(taxa) {
+			return &tree.Node{Name: taxa[u].Name}
+		}
+		n := &tree.Node{}
+		for _, v := range pt.adj[u] {
+			if v == anc {
+				continue
+			}
+			n.Add(build(v, u))
+		}
+		return n
+	}
+	root := &tree.Node{}
+	root.Add(build(0, pt.adj[0][0]))
+	root.Add(build(pt.adj[0][0], 0))
+	return &tree.Tree{Root: root}
+}
+
+// An Overlap is the number of sites
+// shared by a pair of terminals.
+type Overlap struct {
+	A, B  string
+	Sites int
+}
+
+// Overlaps returns the pairs of terminals
+// that share less than min sites
+// in which both terminals
+// have an unambiguous state,
+// i.e. the sites used in pairwise distances
+// with pairwise deletion of missing data.
+func (m *Matrix) Overlaps(min int) []Overlap {
+	taxa := m.M.Taxa()
+	var ov []Overlap
+	for i, a := range taxa {
+		for _, b := range taxa[i+1:] {
+			pats := m.qPatterns([]*matrix.Terminal{a, b})
+			sites, _, _ := pairSites(pats, 0, 1)
+			if sites < float64(min) {
+				ov = append(ov, Overlap{A: a.Name, B: b.Name, Sites: int(sites)})
+			}
+		}
+	}
+	return ov
+}
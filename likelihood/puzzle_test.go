@@ -0,0 +1,105 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/consensus"
+	"github.com/js-arias/ramita/matrix"
+)
+
+var puzzleBlob = `
+> dna
+A AAAAAAAAAACCCCCCCCCCGGGGGGGGGG
+B AAAAAAAAAACCCCCCCCCCGGGGGGGGGT
+C TTTTTTTTTTCCCCCCCCCCGGGGGGGGGG
+D TTTTTTTTTTCCCCCCCCCCGGGGGGGGGA
+E TTTTTTTTTTGGGGGGGGGGGGGGGGGGGG
+F TTTTTTTTTTGGGGGGGGGGGGGGGGGGGC
+`
+
+func TestPuzzle(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(puzzleBlob))
+	if err != nil {
+		t.Fatalf("likelihood: puzzle: unexpected error while reading matrix: %v", err)
+	}
+	q := m.Quartets(1)
+	if q.Len() != 15 {
+		t.Errorf("likelihood: puzzle: %d quartets, want %d", q.Len(), 15)
+	}
+
+	// A and B are sisters
+	if p := q.partner(0, 1, 2, 3); p != 1 {
+		t.Errorf("likelihood: puzzle: partner of A: %s, want %s", q.Taxa[p].Name, "B")
+	}
+
+	trees := q.Puzzle(20)
+	if len(trees) != 20 {
+		t.Fatalf("likelihood: puzzle: %d trees, want %d", len(trees), 20)
+	}
+	set := &consensus.Set{}
+	for _, tr := range trees {
+		if len(tr.Terms()) != 6 {
+			t.Fatalf("likelihood: puzzle: tree with %d terminals, want %d", len(tr.Terms()), 6)
+		}
+		if err := set.Add(tr); err != nil {
+			t.Fatalf("likelihood: puzzle: unexpected error: %v", err)
+		}
+	}
+	for _, c := range [][]string{{"C", "D", "E", "F"}, {"E", "F"}} {
+		if s, _ := set.Support(c); s != 1 {
+			t.Errorf("likelihood: puzzle: clade %v: support %.2f, want %.2f", c, s, 1.0)
+		}
+	}
+}
+
+var overlapBlob = `
+> dna
+A ACGTACGTAC
+B ACGTA?????
+C ?????CGTAC
+D ACGTACGTNN
+`
+
+func TestOverlaps(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(overlapBlob))
+	if err != nil {
+		t.Fatalf("likelihood: overlaps: unexpected error while reading matrix: %v", err)
+	}
+	if ov := m.Overlaps(1); len(ov) != 1 || ov[0].A != "B" || ov[0].B != "C" || ov[0].Sites != 0 {
+		t.Errorf("likelihood: overlaps: %v, want [{B C 0}]", ov)
+	}
+	ov := m.Overlaps(5)
+	want := map[string]int{"B C": 0, "C D": 3}
+	if len(ov) != len(want) {
+		t.Errorf("likelihood: overlaps: %d pairs, want %d", len(ov), len(want))
+	}
+	for _, o := range ov {
+		if s, ok := want[o.A+" "+o.B]; !ok || s != o.Sites {
+			t.Errorf("likelihood: overlaps: pair %s %s: %d sites", o.A, o.B, o.Sites)
+		}
+	}
+	// gaps as a state
+	// are not unambiguous sites
+	gm, err := matrix.NewMatrix(strings.NewReader("> dna\nA ACGTAC\nB ACG---\nC AC-TAC\nD ACGTAC\n"))
+	if err != nil {
+		t.Fatalf("likelihood: overlaps: unexpected error while reading matrix: %v", err)
+	}
+	if err := gm.SetGapMode(matrix.GapState); err != nil {
+		t.Fatalf("likelihood: overlaps: unexpected error: %v", err)
+	}
+	ov = NewFromMatrix(gm).Overlaps(4)
+	want = map[string]int{"A B": 3, "B C": 2, "B D": 3}
+	if len(ov) != len(want) {
+		t.Errorf("likelihood: overlaps: gaps: %v, want %v", ov, want)
+	}
+	for _, o := range ov {
+		if s, ok := want[o.A+" "+o.B]; !ok || s != o.Sites {
+			t.Errorf("likelihood: overlaps: gaps: pair %s %s: %d sites", o.A, o.B, o.Sites)
+		}
+	}
+}
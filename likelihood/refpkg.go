@@ -0,0 +1,175 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"io"
+	"strings"
+
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+// RefPkgMagic is the header
+// of a reference package file.
+const refPkgMagic = "ramita-refpkg-1\n"
+
+// A RefPackage is a reference package
+// for the placement of query sequences,
+// i.e. a reference matrix,
+// a reference tree,
+// and the model parameters
+// fitted on the reference tree.
+type RefPackage struct {
+	M      *matrix.Matrix // reference matrix
+	Tree   *tree.Tree     // reference tree, with branch lengths
+	Models string         // model assignments, as in Matrix.SetModels
+	Cats   int            // number of categories of the gamma model
+	Alpha  float64        // shape of the gamma model
+}
+
+// RefPkgData is the data stored
+// in a reference package file.
+type refPkgData struct {
+	Matrix []byte // the matrix as a bundle
+	Tree   string
+	Models string
+	Cats   int
+	Alpha  float64
+}
+
+// NewRefPackage returns a reference package
+// from a likelihood tree,
+// using its current branch lengths
+// and model parameters.
+// All the models of the tree
+// must be models that can be parsed
+// with ParseModel.
+func NewRefPackage(tr *Tree) (*RefPackage, error) {
+	var defs []string
+	for _, p := range tr.M.ModelPartitions() {
+		if _, err := ParseModel(p.Name); err != nil {
+			return nil, errors.Wrap(err, "likelihood: refpkg")
+		}
+		defs = append(defs, p.Name+":"+matrix.FormatRange(p.Chars))
+	}
+	cats, alpha := tr.M.Gamma()
+	if cats < 2 {
+		cats, alpha = 0, 0
+	}
+	return &RefPackage{
+		M:      tr.M.M,
+		Tree:   tr.Topology(),
+		Models: strings.Join(defs, "; "),
+		Cats:   cats,
+		Alpha:  alpha,
+	}, nil
+}
+
+// Matrix returns a likelihood matrix
+// with the terminals of the reference matrix,
+// and the terminals of a query matrix
+// (that can be nil),
+// with the models of the reference package.
+// The query matrix must have
+// the same characters as the reference matrix.
+func (rp *RefPackage) Matrix(queries *matrix.Matrix) (*Matrix, error) {
+	mt := rp.M
+	if queries != nil {
+		var err error
+		mt, err = rp.M.AddTaxa(queries)
+		if err != nil {
+			return nil, errors.Wrap(err, "likelihood: refpkg")
+		}
+	}
+	m := NewFromMatrix(mt)
+	if err := m.SetModels(rp.Models); err != nil {
+		return nil, errors.Wrap(err, "likelihood: refpkg")
+	}
+	if err := m.SetGamma(rp.Cats, rp.Alpha); err != nil {
+		return nil, errors.Wrap(err, "likelihood: refpkg")
+	}
+	m.FixAlpha(true)
+	return m, nil
+}
+
+// WriteRefPackage writes a reference package
+// as a binary file.
+func WriteRefPackage(w io.Writer, rp *RefPackage) error {
+	if !rp.Tree.Lens {
+		return errors.New("likelihood: refpkg: reference tree without branch lengths")
+	}
+	for _, nm := range rp.Tree.Terms() {
+		if rp.M.Names[nm] == nil {
+			return errors.Errorf("likelihood: refpkg: terminal %s not in matrix", nm)
+		}
+	}
+
+	var mb bytes.Buffer
+	if err := matrix.WriteBundle(&mb, rp.M, nil); err != nil {
+		return errors.Wrap(err, "likelihood: refpkg")
+	}
+	var tb bytes.Buffer
+	rp.Tree.Write(&tb, true)
+
+	rd := refPkgData{
+		Matrix: mb.Bytes(),
+		Tree:   tb.String(),
+		Models: rp.Models,
+		Cats:   rp.Cats,
+		Alpha:  rp.Alpha,
+	}
+	if _, err := io.WriteString(w, refPkgMagic); err != nil {
+		return errors.Wrap(err, "likelihood: refpkg")
+	}
+	if err := gob.NewEncoder(w).Encode(rd); err != nil {
+		return errors.Wrap(err, "likelihood: refpkg")
+	}
+	return nil
+}
+
+// ReadRefPackage reads a reference package
+// from a binary file.
+func ReadRefPackage(r io.Reader) (*RefPackage, error) {
+	br := bufio.NewReader(r)
+	h, err := br.Peek(len(refPkgMagic))
+	if err != nil || string(h) != refPkgMagic {
+		return nil, errors.New("likelihood: refpkg: not a reference package")
+	}
+	if _, err := br.Discard(len(refPkgMagic)); err != nil {
+		return nil, errors.Wrap(err, "likelihood: refpkg")
+	}
+	var rd refPkgData
+	if err := gob.NewDecoder(br).Decode(&rd); err != nil {
+		return nil, errors.Wrap(err, "likelihood: refpkg")
+	}
+
+	m, err := matrix.NewMatrix(bytes.NewReader(rd.Matrix))
+	if err != nil {
+		return nil, errors.Wrap(err, "likelihood: refpkg")
+	}
+	t, err := tree.Read(strings.NewReader(rd.Tree))
+	if err != nil {
+		return nil, errors.Wrap(err, "likelihood: refpkg")
+	}
+	rp := &RefPackage{
+		M:      m,
+		Tree:   t,
+		Models: rd.Models,
+		Cats:   rd.Cats,
+		Alpha:  rd.Alpha,
+	}
+
+	// check the models
+	if _, err := rp.Matrix(nil); err != nil {
+		return nil, err
+	}
+	return rp, nil
+}
@@ -0,0 +1,94 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/tree"
+)
+
+func TestRefPackage(t *testing.T) {
+	mt, err := matrix.NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("likelihood: refpkg: unexpected error while reading matrix: %v", err)
+	}
+	query := "Argopecten_irradians"
+	qm, err := mt.Subset([]string{query})
+	if err != nil {
+		t.Fatalf("likelihood: refpkg: unexpected error: %v", err)
+	}
+	ref, err := tree.Read(strings.NewReader(treeLenBlob))
+	if err != nil {
+		t.Fatalf("likelihood: refpkg: unexpected error while reading tree: %v", err)
+	}
+	ref.Prune([]string{query})
+
+	m := NewFromMatrix(mt.DropTaxa([]string{query}))
+	if err := m.SetGamma(2, 0.5); err != nil {
+		t.Fatalf("likelihood: refpkg: unexpected error: %v", err)
+	}
+	tr, err := FromTopology(ref, m)
+	if err != nil {
+		t.Fatalf("likelihood: refpkg: unexpected error: %v", err)
+	}
+	rp, err := NewRefPackage(tr)
+	if err != nil {
+		t.Fatalf("likelihood: refpkg: unexpected error: %v", err)
+	}
+	all := make([]int, m.Chars())
+	for i := range all {
+		all[i] = i
+	}
+	if md := "jc:" + matrix.FormatRange(all); rp.Models != md {
+		t.Errorf("likelihood: refpkg: models %q, want %q", rp.Models, md)
+	}
+
+	var b bytes.Buffer
+	if err := WriteRefPackage(&b, rp); err != nil {
+		t.Fatalf("likelihood: refpkg: write: unexpected error: %v", err)
+	}
+	np, err := ReadRefPackage(&b)
+	if err != nil {
+		t.Fatalf("likelihood: refpkg: read: unexpected error: %v", err)
+	}
+	if np.Models != rp.Models {
+		t.Errorf("likelihood: refpkg: models %q, want %q", np.Models, rp.Models)
+	}
+	if np.Cats != 2 || np.Alpha != 0.5 {
+		t.Errorf("likelihood: refpkg: gamma %d %.6f, want %d %.6f", np.Cats, np.Alpha, 2, 0.5)
+	}
+	if c := np.Tree.Canonical(); c != ref.Canonical() {
+		t.Errorf("likelihood: refpkg: tree %s, want %s", c, ref.Canonical())
+	}
+	if len(np.M.Names) != len(mt.Names)-1 {
+		t.Errorf("likelihood: refpkg: %d terminals, want %d", len(np.M.Names), len(mt.Names)-1)
+	}
+
+	pm, err := np.Matrix(qm)
+	if err != nil {
+		t.Fatalf("likelihood: refpkg: matrix: unexpected error: %v", err)
+	}
+	if cats, alpha := pm.Gamma(); cats != 2 || alpha != 0.5 {
+		t.Errorf("likelihood: refpkg: matrix: gamma %d %.6f, want %d %.6f", cats, alpha, 2, 0.5)
+	}
+	ps, err := Place(np.Tree, pm, query)
+	if err != nil {
+		t.Fatalf("likelihood: refpkg: place: unexpected error: %v", err)
+	}
+	if nm := np.Tree.Nodes()[ps[0].Edge].Name; nm != "Chlamys_islandica" {
+		t.Errorf("likelihood: refpkg: best placement at %q, want %q", nm, "Chlamys_islandica")
+	}
+
+	if _, err := np.Matrix(mt); err == nil {
+		t.Errorf("likelihood: refpkg: matrix: expecting error on repeated terminals")
+	}
+	if _, err := ReadRefPackage(strings.NewReader(dnaBlob)); err == nil {
+		t.Errorf("likelihood: refpkg: read: expecting error on invalid file")
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/js-arias/ramita/matrix"
+)
+
+// SiteLikes returns the log likelihood
+// of each character.
+func (tr *Tree) SiteLikes() []float64 {
+	sites := make([]float64, len(tr.Root.Cond))
+	vp := tr.varProbs()
+	for i := range sites {
+		sites[i] = math.Log(tr.siteLike(i))
+		if v, ok := vp[tr.M.model[i]]; ok {
+			sites[i] -= math.Log(v)
+		}
+	}
+	return sites
+}
+
+// RELL performs a resampling estimated log-likelihood
+// (RELL) bootstrap
+// over a set of trees,
+// using the log likelihood of each character
+// on each tree
+// (as returned by SiteLikes).
+// In each replicate,
+// characters are resampled with replacement,
+// and the log likelihood of each tree
+// is the sum of the log likelihoods
+// of the sampled characters,
+// so trees are not re-optimized.
+//
+// It returns the index of the tree
+// with the best likelihood
+// in each replicate.
+func RELL(sites [][]float64, reps int) []int {
+	if len(sites) == 0 {
+		return nil
+	}
+	chars := len(sites[0])
+	return rell(sites, reps, func(count []int) {
+		for i := 0; i < chars; i++ {
+			count[rand.Intn(chars)]++
+		}
+	})
+}
+
+// RELLPartitions is like RELL,
+// but in each replicate,
+// whole partitions
+// (for example genes)
+// are resampled with replacement,
+// instead of individual characters.
+func RELLPartitions(sites [][]float64, ps []matrix.Partition, reps int) []int {
+	if len(sites) == 0 || len(ps) == 0 {
+		return nil
+	}
+	return rell(sites, reps, func(count []int) {
+		for range ps {
+			for _, c := range ps[rand.Intn(len(ps))].Chars {
+				count[c]++
+			}
+		}
+	})
+}
+
+// Rell performs a RELL bootstrap
+// using the given function
+// to count the number of times
+// each character is sampled.
+func rell(sites [][]float64, reps int, sample func(count []int)) []int {
+	chars := len(sites[0])
+	best := make([]int, reps)
+	count := make([]int, chars)
+	for r := range best {
+		for i := range count {
+			count[i] = 0
+		}
+		sample(count)
+		max := math.Inf(-1)
+		for t, sl := range sites {
+			like := float64(0)
+			for i, c := range count {
+				if c == 0 {
+					continue
+				}
+				like += float64(c) * sl[i]
+			}
+			if like > max {
+				max = like
+				best[r] = t
+			}
+		}
+	}
+	return best
+}
@@ -0,0 +1,69 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/matrix"
+)
+
+func TestRELL(t *testing.T) {
+	sites := [][]float64{
+		{-1, -1, -1, -1},
+		{-2, -2, -2, -2},
+		{-1, -1, -1, -10},
+	}
+	best := RELL(sites, 100)
+	if len(best) != 100 {
+		t.Fatalf("likelihood: rell: %d replicates, want %d", len(best), 100)
+	}
+	for i, b := range best {
+		if b != 0 {
+			t.Errorf("likelihood: rell: replicate %d: best tree %d, want %d", i, b, 0)
+		}
+	}
+}
+
+func TestRELLPartitions(t *testing.T) {
+	sites := [][]float64{
+		{-1, -1, -1, -5},
+		{-2, -2, -2, -1},
+	}
+	// tree 1 is better
+	// if the second partition is sampled
+	ps := []matrix.Partition{
+		{Name: "a", Chars: []int{0, 1, 2}},
+		{Name: "b", Chars: []int{3}},
+	}
+	best := RELLPartitions(sites, ps, 200)
+	count := 0
+	for _, b := range best {
+		count += b
+	}
+	if count == 0 || count == len(best) {
+		t.Errorf("likelihood: rell partitions: tree 1 best in %d of %d replicates", count, len(best))
+	}
+}
+
+func TestSiteLikes(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("likelihood: sitelikes: unexpected error while reading matrix: %v", err)
+	}
+	tr, err := ReadTree(strings.NewReader(treeLenBlob), m)
+	if err != nil {
+		t.Fatalf("likelihood: sitelikes: unexpected error while reading tree: %v", err)
+	}
+	sum := float64(0)
+	for _, l := range tr.SiteLikes() {
+		sum += l
+	}
+	if math.Abs(sum-tr.Like()) > 0.000001 {
+		t.Errorf("likelihood: sitelikes: sum %.6f, want %.6f", sum, tr.Like())
+	}
+}
@@ -5,7 +5,6 @@
 package likelihood
 
 import (
-	"bufio"
 	"fmt"
 	"io"
 	"math"
@@ -14,9 +13,9 @@ import (
 	"strconv"
 	"strings"
 	"time"
-	"unicode"
 
 	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/tree"
 
 	"github.com/pkg/errors"
 )
@@ -37,6 +36,9 @@ type Node struct {
 	Cond        []Conditional    // Conditional likelihood of each character
 	Len         float64          // Length of the current branch
 
+	buf    []float64 // conditionals of all characters
+	height int       // number of nodes to the farthest terminal
+
 	// backups
 	condCopy []Conditional
 }
@@ -51,43 +53,108 @@ type Tree struct {
 // Like returns the log likelihood of the tree.
 func (tr *Tree) Like() float64 {
 	logLike := float64(0)
-	for i, c := range tr.Root.Cond {
-		m := tr.M.Model(i)
-		like := float64(0)
-		for s, p := range c {
-			like += p * m.Freq(s)
+	vp := tr.varProbs()
+	for i := range tr.Root.Cond {
+		l := math.Log(tr.siteLike(i))
+		if v, ok := vp[tr.M.model[i]]; ok {
+			l -= math.Log(v)
 		}
-		logLike += math.Log(like)
+
+		// in a compressed matrix,
+		// a character is a site pattern
+		logLike += float64(tr.M.M.Count(i)) * l
 	}
 	return logLike
 }
 
+// SiteLike returns the likelihood
+// of a character,
+// averaged over the rate categories.
+func (tr *Tree) siteLike(c int) float64 {
+	m := tr.M.Model(c)
+	cats := tr.M.rateCats()
+	like := float64(0)
+	for k := range cats {
+		for s, p := range tr.Root.catCond(tr.M, c, k) {
+			like += p * m.Freq(s)
+		}
+	}
+	return like / float64(len(cats))
+}
+
 // Write writes a tree into a io.Writer.
 func (t *Tree) Write(w io.Writer, comma bool) {
-	t.Root.write(w, comma)
-	fmt.Fprintf(w, ";")
+	t.WriteOpts(w, comma, WriteOptions{})
 }
 
-// Write write a node into a io.Writer.
-func (n *Node) write(w io.Writer, comma bool) {
-	if n.Term != nil {
-		fmt.Fprintf(w, "%s:%.6f", n.Term.Name, n.Len)
-		return
+// WriteOptions are the annotations
+// that can be added to a written tree
+// (see Tree.WriteOpts).
+type WriteOptions struct {
+	// If true,
+	// internal nodes are labeled
+	// with a numeric identifier,
+	// in pre-order,
+	// starting at the root
+	// with the number of terminals plus one
+	// (as in PAML).
+	IDs bool
+
+	// If greater than 0,
+	// branch lengths are multiplied
+	// by the scale.
+	Scale float64
+
+	// If true,
+	// a comment with the model parameters
+	// is written after the tree.
+	Params bool
+}
+
+// WriteOpts writes a tree into a io.Writer,
+// with the indicated annotations.
+func (t *Tree) WriteOpts(w io.Writer, comma bool, opt WriteOptions) {
+	scale := opt.Scale
+	if scale <= 0 {
+		scale = 1
 	}
-	fmt.Fprintf(w, "(")
-	n.Left.write(w, comma)
-	if comma {
-		fmt.Fprintf(w, ",")
-	} else {
-		fmt.Fprintf(w, " ")
+	tp := t.Topology()
+	id := t.M.Terms() + 1
+	for _, n := range tp.Nodes() {
+		n.Len *= scale
+		if opt.IDs && !n.IsTerm() {
+			n.Label = strconv.Itoa(id)
+			id++
+		}
 	}
-	n.Right.write(w, comma)
-	fmt.Fprintf(w, ")")
-	if n.Anc != nil {
-		fmt.Fprintf(w, ":%.6f", n.Len)
+	tp.Write(w, comma)
+	if opt.Params {
+		fmt.Fprintf(w, "[%s]", t.params(scale))
 	}
 }
 
+// Params returns the model parameters
+// of a tree,
+// as a list of fields separated by semicolons.
+func (t *Tree) params(scale float64) string {
+	ls := []string{
+		fmt.Sprintf("lnL=%.6f", t.Like()),
+		fmt.Sprintf("chars=%d", t.M.Chars()),
+		fmt.Sprintf("scale=%.6f", scale),
+	}
+	if cats, alpha := t.M.Gamma(); cats > 1 {
+		ls = append(ls, fmt.Sprintf("gamma=%d alpha=%.6f", cats, alpha))
+	}
+	for _, p := range t.M.ModelPartitions() {
+		md := fmt.Sprintf("model %s=%s", p.Name, matrix.FormatRange(p.Chars))
+		if r, ok := t.M.mds[p.Name].(*Rated); ok {
+			md += fmt.Sprintf(" rate=%.6f", r.Rate)
+		}
+		ls = append(ls, md)
+	}
+	return strings.Join(ls, "; ")
+}
+
 // CondState calculates the conditional
 // of state s on a node for the c character.
 func (n *Node) condState(m Model, c, s int) float64 {
@@ -104,13 +171,7 @@ func (n *Node) optimize(m *Matrix) {
 	if n.Term != nil {
 		return
 	}
-	for i := range n.Cond {
-		mod := m.Model(i)
-		for s := range n.Cond[i] {
-			prob := n.Left.condState(mod, i, s) * n.Right.condState(mod, i, s)
-			n.Cond[i][s] = prob
-		}
-	}
+	n.batchOpt(m, "")
 }
 
 // FullOpt optimize a node
@@ -121,17 +182,7 @@ func (n *Node) fullOpt(m *Matrix, id string) {
 	}
 	n.Left.fullOpt(m, id)
 	n.Right.fullOpt(m, id)
-
-	for i := range n.Cond {
-		if m.model[i] != id {
-			continue
-		}
-		md := m.Model(i)
-		for s := range n.Cond[i] {
-			prob := n.Left.condState(md, i, s) * n.Right.condState(md, i, s)
-			n.Cond[i][s] = prob
-		}
-	}
+	n.batchOpt(m, id)
 }
 
 // IncreDown implements a simple incremental downpass,
@@ -150,6 +201,9 @@ func increDown(n *Node, m *Matrix) {
 // Estimate perfomrs a simple estimation
 // of the model parameters
 // under the current branch lengths.
+// If the matrix has a discrete gamma model,
+// and alpha is not fixed,
+// alpha is also estimated.
 func (tr *Tree) Estimate() {
 	// get the model list
 	models := make(map[string]bool)
@@ -162,6 +216,9 @@ func (tr *Tree) Estimate() {
 		for id := range models {
 			tr.estimate(id, 0.1)
 		}
+		if !tr.M.fixed {
+			tr.EstimateAlpha()
+		}
 		l := tr.Like()
 		if math.Abs(like-l) < 0.001 {
 			break
@@ -329,184 +386,161 @@ func (tr *Tree) refine(n *Node, step float64) {
 }
 
 // ReadTree reads a tree from a Reader.
+// Labels of internal nodes,
+// and comments,
+// are ignored.
+// Polytomies are resolved at random,
+// with new branches of length 0.
+// If the tree does not have branch lengths,
+// a default length of 0.01 will be used.
 func ReadTree(in io.Reader, m *Matrix) (*Tree, error) {
-	r := bufio.NewReader(in)
-	for {
-		r1, _, err := r.ReadRune()
-		if err != nil {
-			return nil, errors.Wrapf(err, "likelihood: readtree: unable to read tree")
-		}
-		if r1 == '(' {
-			break
-		}
+	t, err := tree.Read(in)
+	if err != nil {
+		return nil, errors.Wrap(err, "likelihood: readtree")
 	}
-	tr := &Tree{M: m}
-	terms := make(map[string]bool)
-	root, err := tr.readNode(r, nil, terms)
+	tr, err := FromTopology(t, m)
 	if err != nil {
 		return nil, errors.Wrap(err, "likelihood: readtree")
 	}
-	root.Len = 0
-	tr.Root = root
 	return tr, nil
 }
 
 func (n *Node) initializeConditionals(m *Matrix) {
+	m.layout()
+	if n.Term == nil && !m.keep(n) {
+		return
+	}
+	cats := len(m.rateCats())
+	n.buf = make([]float64, m.size*cats)
+	var cp []float64
+	if n.Term == nil {
+		cp = make([]float64, m.size)
+	}
 	for i := range n.Cond {
-		md := m.Model(i)
-		n.Cond[i] = make(Conditional, md.States())
+		st := m.Model(i).States()
+		p := m.pos[i]
+		n.Cond[i] = Conditional(n.buf[p : p+st : p+st])
 		if n.Term == nil {
-			n.condCopy[i] = make(Conditional, md.States())
+			n.condCopy[i] = Conditional(cp[p : p+st : p+st])
 			continue
 		}
 		tm := n.Term
 		for b := 0; b < m.states[i]; b++ {
-			if tm.Chars[i]&(1<<uint8(b)) != 0 {
+			if tm.State(i)&(1<<uint32(b)) != 0 {
 				n.Cond[i][b] = 1
 			}
 		}
 	}
+	if n.Term != nil {
+		// terminal conditionals
+		// are the same in all rate categories
+		for k := 1; k < cats; k++ {
+			copy(n.buf[k*m.size:(k+1)*m.size], n.buf[:m.size])
+		}
+	}
 }
 
-// ReadNode reads a node from an reader.
-func (tr *Tree) readNode(r *bufio.Reader, anc *Node, terms map[string]bool) (*Node, error) {
-	n := &Node{
-		Anc:      anc,
-		Cond:     make([]Conditional, tr.M.Chars()),
-		Len:      0.01,
-		condCopy: make([]Conditional, tr.M.Chars()),
+// FromTopology returns a new tree
+// from a tree topology,
+// using the data of the given matrix.
+// Polytomies are resolved at random
+// (the topology is not modified).
+// If the topology has branch lengths,
+// the new branches have length 0,
+// otherwise,
+// a default length of 0.01 will be used
+// in all branches.
+func FromTopology(t *tree.Tree, m *Matrix) (*Tree, error) {
+	if !t.IsBinary() {
+		t = t.Copy()
+		t.Resolve()
 	}
-	n.initializeConditionals(tr.M)
-	tr.Nodes = append(tr.Nodes, n)
-
-	for {
-		r1, _, err := r.ReadRune()
-		if err != nil {
-			return nil, err
-		}
-		if unicode.IsSpace(r1) {
-			continue
-		}
-		if r1 == ',' {
-			continue
-		}
-		if r1 == ')' {
-			break
-		}
-		if r1 == '(' {
-			d, err := tr.readNode(r, n, terms)
-			if err != nil {
-				return nil, err
-			}
-			if n.Left == nil {
-				n.Left = d
-			} else if n.Right == nil {
-				n.Right = d
-			} else {
-				return nil, errors.New("polytomic tree")
-			}
-			continue
-		}
+	tr := &Tree{M: m}
+	terms := make(map[string]bool)
+	root, err := tr.fromNode(t.Root, nil, t.Lens, terms)
+	if err != nil {
+		return nil, errors.Wrap(err, "likelihood: from topology")
+	}
+	if len(terms) != m.Terms() {
+		return nil, errors.Errorf("likelihood: from topology: tree with %d terminals, want %d", len(terms), m.Terms())
+	}
+	root.Len = 0
+	tr.Root = root
+	return tr, nil
+}
 
-		// a terminal
-		r.UnreadRune()
-		name, l, err := readTerm(r)
-		if err != nil {
-			return nil, err
-		}
-		tm := tr.M.M.Names[name]
+// FromNode adds a node from a tree topology.
+func (tr *Tree) fromNode(tn *tree.Node, anc *Node, lens bool, terms map[string]bool) (*Node, error) {
+	l := 0.01
+	if lens {
+		l = tn.Len
+	}
+	if tn.IsTerm() {
+		tm := tr.M.M.Names[tn.Name]
 		if tm == nil {
-			return nil, errors.Errorf("terminal %s not in matrix", name)
+			return nil, errors.Errorf("terminal %s not in matrix", tn.Name)
 		}
-		if terms[name] {
-			return nil, errors.Errorf("terminal %s repeated", name)
+		if terms[tn.Name] {
+			return nil, errors.Errorf("terminal %s repeated", tn.Name)
 		}
-		terms[name] = true
-
+		terms[tn.Name] = true
 		nt := &Node{
-			Anc:  n,
+			Anc:  anc,
 			Term: tm,
 			Len:  l,
 			Cond: make([]Conditional, tr.M.Chars()),
 		}
 		nt.initializeConditionals(tr.M)
-		if n.Left == nil {
-			n.Left = nt
-		} else if n.Right == nil {
-			n.Right = nt
-		} else {
-			return nil, errors.New("polytomic tree")
-		}
 		tr.Nodes = append(tr.Nodes, nt)
+		return nt, nil
+	}
+	if len(tn.Desc) != 2 {
+		return nil, errors.New("node with a single descendant")
+	}
+	n := &Node{
+		Anc:      anc,
+		Cond:     make([]Conditional, tr.M.Chars()),
+		Len:      l,
+		condCopy: make([]Conditional, tr.M.Chars()),
+	}
+	tr.Nodes = append(tr.Nodes, n)
+	var err error
+	if n.Left, err = tr.fromNode(tn.Desc[0], n, lens, terms); err != nil {
+		return nil, err
 	}
-	if n.Left == nil || n.Right == nil {
-		return nil, errors.New("node without two descendants")
+	if n.Right, err = tr.fromNode(tn.Desc[1], n, lens, terms); err != nil {
+		return nil, err
 	}
+	n.setHeight()
+	n.initializeConditionals(tr.M)
 	n.optimize(tr.M)
 	copy(n.condCopy, n.Cond)
-
-	if anc != nil {
-		r1, _, err := r.ReadRune()
-		if err != nil {
-			return nil, err
-		}
-		if r1 != ':' {
-			r.UnreadRune()
-		} else {
-			l, err := readBrLen(r)
-			if err != nil {
-				return nil, errors.Wrap(err, "bad branch length")
-			}
-			n.Len = l
-		}
-	}
 	return n, nil
 }
 
-// readTerm reads a terminal name on a tree.
-func readTerm(r *bufio.Reader) (string, float64, error) {
-	var b strings.Builder
-	l := 0.01
-	for {
-		r1, _, err := r.ReadRune()
-		if err != nil {
-			return "", 0, err
-		}
-		if unicode.IsSpace(r1) {
-			break
-		}
-		if r1 == ':' {
-			l, err = readBrLen(r)
-			if err != nil {
-				return "", 0, errors.Wrapf(err, "on terminal %s: bad branch length")
-			}
-			break
-		}
-		if r1 == ',' || r1 == '(' || r1 == ')' {
-			r.UnreadRune()
-			break
-		}
-		b.WriteRune(r1)
-	}
-	return b.String(), l, nil
+// Topology returns the topology of the tree,
+// with its branch lengths,
+// as a tree.Tree.
+func (t *Tree) Topology() *tree.Tree {
+	return tree.FromBinary(t.Root, true)
 }
 
-// readBrLen skips branch lengths.
-func readBrLen(r *bufio.Reader) (float64, error) {
-	var b strings.Builder
-	for {
-		r1, _, err := r.ReadRune()
-		if err != nil {
-			return 0, err
-		}
-		if unicode.IsSpace(r1) {
-			break
-		}
-		if r1 == ',' || r1 == '(' || r1 == ')' {
-			r.UnreadRune()
-			break
-		}
-		b.WriteRune(r1)
+// Children returns the descendants of the node.
+func (n *Node) Children() (left, right tree.Binary) {
+	return n.Left, n.Right
+}
+
+// TermName returns the name of the terminal,
+// or an empty string in internal nodes.
+func (n *Node) TermName() string {
+	if n.Term == nil {
+		return ""
 	}
-	return strconv.ParseFloat(b.String(), 64)
+	return n.Term.Name
+}
+
+// BranchLen returns the length of the branch
+// of the node.
+func (n *Node) BranchLen() float64 {
+	return n.Len
 }
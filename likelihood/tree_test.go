@@ -5,6 +5,8 @@
 package likelihood
 
 import (
+	"bytes"
+	"math"
 	"strings"
 	"testing"
 )
@@ -44,3 +46,78 @@ func checkTerminals(t *testing.T, n *Node, added map[string]bool) int {
 	}
 	return checkTerminals(t, n.Left, added) + checkTerminals(t, n.Right, added)
 }
+
+var writeBlob = `
+> dna
+A ACGTACGTAC
+B ACGTACGTTC
+C ACGAACCTTC
+D TCGAACCTTG
+`
+
+func TestWriteOpts(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(writeBlob))
+	if err != nil {
+		t.Fatalf("likelihood: write: unexpected error while reading matrix: %v", err)
+	}
+	tr, err := ReadTree(strings.NewReader("((A:0.1,B:0.2):0.05,(C:0.1,D:0.3):0.05);"), m)
+	if err != nil {
+		t.Fatalf("likelihood: write: unexpected error while reading tree: %v", err)
+	}
+
+	var b bytes.Buffer
+	tr.Write(&b, true)
+	want := "((A:0.100000,B:0.200000):0.050000,(C:0.100000,D:0.300000):0.050000);"
+	if b.String() != want {
+		t.Errorf("likelihood: write: got %q, want %q", b.String(), want)
+	}
+
+	b.Reset()
+	tr.WriteOpts(&b, true, WriteOptions{IDs: true, Scale: 10})
+	want = "((A:1.000000,B:2.000000)6:0.500000,(C:1.000000,D:3.000000)7:0.500000)5;"
+	if b.String() != want {
+		t.Errorf("likelihood: write: got %q, want %q", b.String(), want)
+	}
+
+	b.Reset()
+	tr.WriteOpts(&b, true, WriteOptions{Params: true})
+	s := b.String()
+	i := strings.Index(s, ";[")
+	if i < 0 || !strings.HasSuffix(s, "]") {
+		t.Fatalf("likelihood: write: parameters not found in %q", s)
+	}
+	for _, p := range []string{"lnL=", "chars=10", "scale=1.000000", "model jc=1-10"} {
+		if !strings.Contains(s[i:], p) {
+			t.Errorf("likelihood: write: parameter %q not found in %q", p, s[i:])
+		}
+	}
+}
+
+func TestTopology(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("likelihood: topology: unexpected error while reading matrix: %v", err)
+	}
+	tr, err := ReadTree(strings.NewReader(treeLenBlob), m)
+	if err != nil {
+		t.Fatalf("likelihood: topology: unexpected error while reading tree: %v", err)
+	}
+
+	cp, err := FromTopology(tr.Topology(), m)
+	if err != nil {
+		t.Fatalf("likelihood: topology: unexpected error: %v", err)
+	}
+	if math.Abs(cp.Like()-tr.Like()) > 1e-6 {
+		t.Errorf("likelihood: topology: likelihood %.6f, want %.6f", cp.Like(), tr.Like())
+	}
+
+	// labels and comments are ignored
+	blob := "[tree]((A:0.1[&rate=1],'B':0.2)95:0.05,(C:0.1,D:0.3)'clade x'[&p=0.5]:0.05);"
+	m, err = NewMatrix(strings.NewReader(writeBlob))
+	if err != nil {
+		t.Fatalf("likelihood: topology: unexpected error while reading matrix: %v", err)
+	}
+	if _, err := ReadTree(strings.NewReader(blob), m); err != nil {
+		t.Errorf("likelihood: topology: unexpected error while reading tree: %v", err)
+	}
+}
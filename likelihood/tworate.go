@@ -0,0 +1,202 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// TwoRate is a model for a binary character
+// with asymmetric rates,
+// i.e. the rate of change from 0 to 1
+// can be different from the rate
+// of change from 1 to 0.
+type TwoRate struct {
+	Q01, Q10 float64
+}
+
+// Prob is the probability of change
+// from one state to another,
+// with a given branch length.
+func (m *TwoRate) Prob(from, to int, blen float64) float64 {
+	s := m.Q01 + m.Q10
+	e := math.Exp(-s * blen)
+	p := m.Freq(to)
+	if from == to {
+		return p + (1-p)*e
+	}
+	return p * (1 - e)
+}
+
+// Freq is the frequency of a given state,
+// i.e. the stationary frequency of the model.
+func (m *TwoRate) Freq(s int) float64 {
+	if s == 0 {
+		return m.Q10 / (m.Q01 + m.Q10)
+	}
+	return m.Q01 / (m.Q01 + m.Q10)
+}
+
+// States is the number of states of a model.
+func (m *TwoRate) States() int {
+	return 2
+}
+
+// Changes is the number of free change types
+// allowed by the model.
+func (m *TwoRate) Changes() int {
+	return 2
+}
+
+// ChangeRate returns the change rate
+// of a given change type:
+// 0 for the change from 0 to 1,
+// and 1 for the change from 1 to 0.
+func (m *TwoRate) ChangeRate(tp int) float64 {
+	if tp == 0 {
+		return m.Q01
+	}
+	return m.Q10
+}
+
+// SetChangeRate changes the change rate
+// of a given change type.
+func (m *TwoRate) SetChangeRate(tp int, r float64) {
+	if tp == 0 {
+		m.Q01 = r
+		return
+	}
+	m.Q10 = r
+}
+
+// A RateTest is the result
+// of a likelihood ratio test
+// between a one-rate,
+// and an asymmetric two-rate,
+// Mk model
+// for a binary character.
+type RateTest struct {
+	Char     int
+	Rate     float64 // rate of the one-rate model
+	OneLike  float64 // log likelihood of the one-rate model
+	Q01, Q10 float64 // rates of the two-rate model
+	TwoLike  float64 // log likelihood of the two-rate model
+	LR       float64 // likelihood ratio statistic
+	P        float64 // p-value, from a chi-square with 1 df
+}
+
+// TwoRateTest compares the fit
+// of a one-rate,
+// and an asymmetric two-rate,
+// Mk model
+// for a binary character,
+// using the current branch lengths of the tree.
+// The rates are in changes per unit
+// of branch length,
+// and the state frequencies at the root
+// are the stationary frequencies of the model.
+func (tr *Tree) TwoRateTest(char int) (RateTest, error) {
+	if char < 0 || char >= tr.M.Chars() {
+		return RateTest{}, errors.Errorf("likelihood: rate test: invalid character %d", char+1)
+	}
+	if tr.M.States(char) != 2 || tr.M.Model(char).States() != 2 {
+		return RateTest{}, errors.Errorf("likelihood: rate test: character %d is not binary", char+1)
+	}
+
+	max := float64(0)
+	for _, n := range tr.Nodes {
+		if n.Len > max {
+			max = n.Len
+		}
+	}
+	if max == 0 {
+		return RateTest{}, errors.New("likelihood: rate test: tree without branch lengths")
+	}
+	// search from a maximum expected number of changes
+	// of 0.0001 to 100 on the longest branch
+	lo, hi := math.Log(0.0001/max), math.Log(100/max)
+
+	md := &TwoRate{}
+	like := func() float64 {
+		cond := tr.Root.rateCond(md, char, 1)
+		l := float64(0)
+		for s, p := range cond {
+			l += p * md.Freq(s)
+		}
+		return math.Log(l)
+	}
+
+	// one-rate model
+	rt := RateTest{Char: char}
+	lr := goldenMax(func(lr float64) float64 {
+		md.Q01, md.Q10 = math.Exp(lr), math.Exp(lr)
+		return like()
+	}, lo, hi)
+	rt.Rate = math.Exp(lr)
+	md.Q01, md.Q10 = rt.Rate, rt.Rate
+	rt.OneLike = like()
+
+	// two-rate model,
+	// each rate is optimized in turn
+	// starting from the one-rate model
+	best := rt.OneLike
+	for i := 0; i < 100; i++ {
+		l01 := goldenMax(func(lr float64) float64 {
+			md.Q01 = math.Exp(lr)
+			return like()
+		}, lo, hi)
+		md.Q01 = math.Exp(l01)
+		l10 := goldenMax(func(lr float64) float64 {
+			md.Q10 = math.Exp(lr)
+			return like()
+		}, lo, hi)
+		md.Q10 = math.Exp(l10)
+		l := like()
+		if l-best < 0.00001 {
+			if l > best {
+				best = l
+			}
+			break
+		}
+		best = l
+	}
+	rt.Q01, rt.Q10 = md.Q01, md.Q10
+	rt.TwoLike = like()
+	if rt.TwoLike < rt.OneLike {
+		// the one-rate model is a special case
+		// of the two-rate model
+		rt.Q01, rt.Q10 = rt.Rate, rt.Rate
+		rt.TwoLike = rt.OneLike
+	}
+
+	rt.LR = 2 * (rt.TwoLike - rt.OneLike)
+	rt.P = chiSquare1(rt.LR)
+	return rt, nil
+}
+
+// GoldenMax returns the value
+// that maximizes a function
+// in an interval,
+// using a golden section search.
+func goldenMax(f func(float64) float64, a, b float64) float64 {
+	g := (math.Sqrt(5) - 1) / 2
+	c := b - g*(b-a)
+	d := a + g*(b-a)
+	fc, fd := f(c), f(d)
+	for b-a > 0.0001 {
+		if fc > fd {
+			b, d, fd = d, c, fc
+			c = b - g*(b-a)
+			fc = f(c)
+			continue
+		}
+		a, c, fc = c, d, fd
+		d = a + g*(b-a)
+		fd = f(d)
+	}
+	return (a + b) / 2
+}
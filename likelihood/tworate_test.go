@@ -0,0 +1,93 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package likelihood
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/tree"
+)
+
+var twoRateBlob = `
+> morpho
+A 00
+B 01
+C 01
+D 11
+E 11
+F 11
+G 10
+H 11
+`
+
+func TestTwoRate(t *testing.T) {
+	md := &TwoRate{Q01: 0.5, Q10: 0.5}
+	p := NewPoisson(2)
+	for _, l := range []float64{0, 0.1, 1, 10} {
+		for from := 0; from < 2; from++ {
+			for to := 0; to < 2; to++ {
+				if v, w := md.Prob(from, to, l), p.Prob(from, to, l); math.Abs(v-w) > 1e-9 {
+					t.Errorf("likelihood: two rate: prob %d->%d [%.2f] = %.6f, want %.6f", from, to, l, v, w)
+				}
+			}
+		}
+	}
+	md = &TwoRate{Q01: 0.2, Q10: 1.3}
+	for from := 0; from < 2; from++ {
+		if s := md.Prob(from, 0, 0.7) + md.Prob(from, 1, 0.7); math.Abs(s-1) > 1e-9 {
+			t.Errorf("likelihood: two rate: probabilities from %d sum %.6f, want 1", from, s)
+		}
+	}
+
+	m, err := NewMatrix(strings.NewReader(twoRateBlob))
+	if err != nil {
+		t.Fatalf("likelihood: two rate: unexpected error while reading matrix: %v", err)
+	}
+	tp, err := tree.Read(strings.NewReader("(((A:1,B:1):1,(C:1,D:1):1):1,((E:1,F:1):1,(G:1,H:1):1):1);"))
+	if err != nil {
+		t.Fatalf("likelihood: two rate: unexpected error while reading tree: %v", err)
+	}
+	tr, err := FromTopology(tp, m)
+	if err != nil {
+		t.Fatalf("likelihood: two rate: unexpected error: %v", err)
+	}
+	for c := 0; c < m.Chars(); c++ {
+		rt, err := tr.TwoRateTest(c)
+		if err != nil {
+			t.Fatalf("likelihood: two rate: unexpected error: %v", err)
+		}
+		if rt.TwoLike < rt.OneLike {
+			t.Errorf("likelihood: two rate: char %d: two-rate likelihood %.6f, less than %.6f", c+1, rt.TwoLike, rt.OneLike)
+		}
+		if rt.LR < 0 || rt.P < 0 || rt.P > 1 {
+			t.Errorf("likelihood: two rate: char %d: invalid LR %.6f, p-value %.6f", c+1, rt.LR, rt.P)
+		}
+		if rt.LR == 0 && rt.P != 1 {
+			t.Errorf("likelihood: two rate: char %d: p-value %.6f, want 1", c+1, rt.P)
+		}
+	}
+	rt, _ := tr.TwoRateTest(1)
+	if rt.Q01 <= rt.Q10 {
+		t.Errorf("likelihood: two rate: q01 %.6f, want greater than q10 %.6f", rt.Q01, rt.Q10)
+	}
+
+	dm, err := NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("likelihood: two rate: unexpected error while reading matrix: %v", err)
+	}
+	dp, err := tree.Read(strings.NewReader(treeLenBlob))
+	if err != nil {
+		t.Fatalf("likelihood: two rate: unexpected error while reading tree: %v", err)
+	}
+	dt, err := FromTopology(dp, dm)
+	if err != nil {
+		t.Fatalf("likelihood: two rate: unexpected error: %v", err)
+	}
+	if _, err := dt.TwoRateTest(0); err == nil {
+		t.Errorf("likelihood: two rate: expecting error")
+	}
+}
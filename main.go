@@ -6,10 +6,25 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/js-arias/biodv/cmdapp"
+	"github.com/js-arias/ramita/internal/env"
+	"github.com/js-arias/ramita/parsimony"
 )
 
 func main() {
 	cmdapp.Short = "Ramita is a simple program for phylogenetic analysis"
+
+	// global options
+	args, err := env.ParseThreads(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ramita: %v\n", err)
+		os.Exit(1)
+	}
+	os.Args = append(os.Args[:1], args...)
+	parsimony.SetThreads(env.Threads())
+
 	cmdapp.Main()
 }
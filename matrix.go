@@ -0,0 +1,16 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package main
+
+import (
+	// initialize matrix sub-commands
+	_ "github.com/js-arias/ramita/internal/matrix/bundle"
+	_ "github.com/js-arias/ramita/internal/matrix/convert"
+	_ "github.com/js-arias/ramita/internal/matrix/cover"
+	_ "github.com/js-arias/ramita/internal/matrix/dups"
+	_ "github.com/js-arias/ramita/internal/matrix/ident"
+	_ "github.com/js-arias/ramita/internal/matrix/info"
+	_ "github.com/js-arias/ramita/internal/matrix/translate"
+)
@@ -0,0 +1,147 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"bufio"
+	"encoding/gob"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// BundleMagic is the header of a bundle file.
+const bundleMagic = "ramita-bundle-2\n"
+
+// BundleData is the data stored
+// in a bundle file.
+type bundleData struct {
+	Names    []string // outgroup is the first terminal
+	Chars    [][]uint32
+	Kind     []DataType
+	Blocks   []Block
+	Patterns [][]int
+	Parts    []Partition
+	Gaps     [][]int // DNA gaps of each terminal
+}
+
+// WriteBundle writes a matrix
+// as a binary bundle file,
+// that stores the parsed matrix,
+// its site patterns,
+// and a partition scheme
+// (that can be nil).
+//
+// Bundle files can be read with NewMatrix,
+// so repeated analyses on the same dataset
+// skip the parsing of the text matrix.
+func WriteBundle(w io.Writer, m *Matrix, ps []Partition) error {
+	for _, p := range ps {
+		for _, c := range p.Chars {
+			if c < 0 || c >= len(m.Kind) {
+				return errors.Errorf("matrix: bundle: partition %s: character %d out of range", p.Name, c+1)
+			}
+		}
+	}
+
+	bd := bundleData{
+		Kind:   m.Kind,
+		Blocks: m.Blocks,
+		Parts:  ps,
+	}
+	for _, t := range m.Taxa() {
+		bd.Names = append(bd.Names, t.Name)
+		bd.Chars = append(bd.Chars, t.Unpack())
+		bd.Gaps = append(bd.Gaps, t.gaps)
+	}
+	for _, p := range m.Patterns() {
+		bd.Patterns = append(bd.Patterns, p.Chars)
+	}
+
+	if _, err := io.WriteString(w, bundleMagic); err != nil {
+		return errors.Wrap(err, "matrix: bundle")
+	}
+	if err := gob.NewEncoder(w).Encode(bd); err != nil {
+		return errors.Wrap(err, "matrix: bundle")
+	}
+	return nil
+}
+
+// IsBundle returns true
+// if the reader starts with a bundle header.
+func isBundle(r *bufio.Reader) bool {
+	h, err := r.Peek(len(bundleMagic))
+	if err != nil {
+		return false
+	}
+	return string(h) == bundleMagic
+}
+
+// ReadBundle reads a matrix
+// from a bundle file.
+func readBundle(r *bufio.Reader) (*Matrix, error) {
+	if _, err := r.Discard(len(bundleMagic)); err != nil {
+		return nil, errors.Wrap(err, "matrix: bundle")
+	}
+	var bd bundleData
+	if err := gob.NewDecoder(r).Decode(&bd); err != nil {
+		return nil, errors.Wrap(err, "matrix: bundle")
+	}
+	if len(bd.Names) != len(bd.Chars) {
+		return nil, errors.New("matrix: bundle: bad formatted bundle")
+	}
+
+	m := &Matrix{
+		Names:  make(map[string]*Terminal, len(bd.Names)),
+		Kind:   bd.Kind,
+		Blocks: bd.Blocks,
+		parts:  bd.Parts,
+	}
+	for i, nm := range bd.Names {
+		t := &Terminal{Name: nm, Chars: bd.Chars[i]}
+		if len(bd.Gaps) == len(bd.Names) {
+			t.gaps = bd.Gaps[i]
+		}
+		m.Names[nm] = t
+		if i == 0 {
+			m.Out = t
+		}
+	}
+	if !m.IsValid() {
+		return nil, errors.New("matrix: bundle: bad formatted matrix")
+	}
+	for i, p := range bd.Patterns {
+		for _, c := range p {
+			if c < 0 || c >= len(m.Kind) {
+				return nil, errors.Errorf("matrix: bundle: pattern %d: character %d out of range", i+1, c+1)
+			}
+		}
+		m.patterns = append(m.patterns, Pattern{Chars: p})
+	}
+	for _, p := range bd.Parts {
+		for _, c := range p.Chars {
+			if c < 0 || c >= len(m.Kind) {
+				return nil, errors.Errorf("matrix: bundle: partition %s: character %d out of range", p.Name, c+1)
+			}
+		}
+	}
+	for _, t := range m.Names {
+		for _, c := range t.gaps {
+			if c < 0 || c >= len(m.Kind) {
+				return nil, errors.Errorf("matrix: bundle: terminal %s: gap %d out of range", t.Name, c+1)
+			}
+		}
+	}
+	return m, nil
+}
+
+// Partitions returns the partition scheme
+// stored in a bundle file.
+// If the matrix was not read from a bundle,
+// or the bundle does not have a partition scheme,
+// it returns nil.
+func (m *Matrix) Partitions() []Partition {
+	return m.parts
+}
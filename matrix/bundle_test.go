@@ -0,0 +1,157 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPatterns(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(dupBlob))
+	if err != nil {
+		t.Fatalf("matrix: patterns: unexpected error while reading matrix: %v", err)
+	}
+	want := [][]int{{0, 1}, {2, 3, 4}, {5}}
+	ps := m.Patterns()
+	if len(ps) != len(want) {
+		t.Fatalf("matrix: patterns: %d patterns, want %d", len(ps), len(want))
+	}
+	for i, p := range ps {
+		if !reflect.DeepEqual(p.Chars, want[i]) {
+			t.Errorf("matrix: patterns: pattern %d: %v, want %v", i, p.Chars, want[i])
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(dupBlob))
+	if err != nil {
+		t.Fatalf("matrix: compress: unexpected error while reading matrix: %v", err)
+	}
+	if err := m.SetWeights("2:1"); err != nil {
+		t.Fatalf("matrix: compress: unexpected error: %v", err)
+	}
+	m.Exclude([]int{5})
+
+	c, ps := m.Compress(nil)
+	if len(c.Kind) != 2 || len(ps) != 2 {
+		t.Fatalf("matrix: compress: %d characters, want %d", len(c.Kind), 2)
+	}
+	if !reflect.DeepEqual(ps[1].Chars, []int{2, 3, 4}) {
+		t.Errorf("matrix: compress: pattern %d: %v, want %v", 1, ps[1].Chars, []int{2, 3, 4})
+	}
+	if w := c.Weights(); !reflect.DeepEqual(w, []int{3, 3}) {
+		t.Errorf("matrix: compress: weights %v, want %v", w, []int{3, 3})
+	}
+	if n := []int{c.Count(0), c.Count(1)}; !reflect.DeepEqual(n, []int{2, 3}) {
+		t.Errorf("matrix: compress: counts %v, want %v", n, []int{2, 3})
+	}
+	if st := c.Names["D"].Chars; !reflect.DeepEqual(st, []uint32{2, 1}) {
+		t.Errorf("matrix: compress: terminal D: %v, want %v", st, []uint32{2, 1})
+	}
+	if c.Out.Name != "Out" {
+		t.Errorf("matrix: compress: outgroup %s, want %s", c.Out.Name, "Out")
+	}
+	if m.Count(0) != 1 {
+		t.Errorf("matrix: compress: original count %d, want %d", m.Count(0), 1)
+	}
+
+	c, _ = m.Compress([]string{"a", "b", "a", "a", "b", "b"})
+	if len(c.Kind) != 4 {
+		t.Fatalf("matrix: compress: with class: %d characters, want %d", len(c.Kind), 4)
+	}
+	if n := []int{c.Count(0), c.Count(1), c.Count(2), c.Count(3)}; !reflect.DeepEqual(n, []int{1, 1, 2, 1}) {
+		t.Errorf("matrix: compress: with class: counts %v, want %v", n, []int{1, 1, 2, 1})
+	}
+}
+
+func TestBundle(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(coverBlob))
+	if err != nil {
+		t.Fatalf("matrix: bundle: unexpected error while reading matrix: %v", err)
+	}
+	ps := m.BlockPartitions()
+
+	var b bytes.Buffer
+	if err := WriteBundle(&b, m, ps); err != nil {
+		t.Fatalf("matrix: bundle: unexpected error while writing bundle: %v", err)
+	}
+	c, err := NewMatrix(&b)
+	if err != nil {
+		t.Fatalf("matrix: bundle: unexpected error while reading bundle: %v", err)
+	}
+
+	if c.Out.Name != m.Out.Name {
+		t.Errorf("matrix: bundle: outgroup %s, want %s", c.Out.Name, m.Out.Name)
+	}
+	if len(c.Names) != len(m.Names) {
+		t.Fatalf("matrix: bundle: %d terminals, want %d", len(c.Names), len(m.Names))
+	}
+	for nm, tx := range m.Names {
+		ct, ok := c.Names[nm]
+		if !ok {
+			t.Errorf("matrix: bundle: terminal %s not found", nm)
+			continue
+		}
+		if !reflect.DeepEqual(ct.Chars, tx.Chars) {
+			t.Errorf("matrix: bundle: terminal %s: wrong characters", nm)
+		}
+	}
+	if !reflect.DeepEqual(c.Kind, m.Kind) {
+		t.Errorf("matrix: bundle: data types %v, want %v", c.Kind, m.Kind)
+	}
+	if !reflect.DeepEqual(c.Blocks, m.Blocks) {
+		t.Errorf("matrix: bundle: blocks %v, want %v", c.Blocks, m.Blocks)
+	}
+	if !reflect.DeepEqual(c.Patterns(), m.Patterns()) {
+		t.Errorf("matrix: bundle: patterns %v, want %v", c.Patterns(), m.Patterns())
+	}
+	if !reflect.DeepEqual(c.Partitions(), ps) {
+		t.Errorf("matrix: bundle: partitions %v, want %v", c.Partitions(), ps)
+	}
+	if m.Partitions() != nil {
+		t.Errorf("matrix: bundle: text matrix with partitions %v", m.Partitions())
+	}
+}
+
+func TestBundleRange(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(coverBlob))
+	if err != nil {
+		t.Fatalf("matrix: bundle: unexpected error while reading matrix: %v", err)
+	}
+	n := len(m.Kind)
+
+	tests := map[string]func(bd *bundleData){
+		"pattern":          func(bd *bundleData) { bd.Patterns = append(bd.Patterns, []int{0, n}) },
+		"negative pattern": func(bd *bundleData) { bd.Patterns = append(bd.Patterns, []int{-1}) },
+		"partition":        func(bd *bundleData) { bd.Parts = []Partition{{Name: "p", Chars: []int{n + 5}}} },
+		"gap":              func(bd *bundleData) { bd.Gaps[0] = []int{n} },
+	}
+	for name, set := range tests {
+		bd := bundleData{
+			Kind:   m.Kind,
+			Blocks: m.Blocks,
+		}
+		for _, tx := range m.Taxa() {
+			bd.Names = append(bd.Names, tx.Name)
+			bd.Chars = append(bd.Chars, tx.Unpack())
+			bd.Gaps = append(bd.Gaps, nil)
+		}
+		set(&bd)
+
+		var b bytes.Buffer
+		b.WriteString(bundleMagic)
+		if err := gob.NewEncoder(&b).Encode(bd); err != nil {
+			t.Fatalf("matrix: bundle: %s: unexpected error while writing bundle: %v", name, err)
+		}
+		if _, err := NewMatrix(&b); err == nil {
+			t.Errorf("matrix: bundle: %s: expecting an out of range error", name)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A GeneticCode is a table
+// for the translation of codons
+// into amino acids.
+type GeneticCode struct {
+	ID   int    // NCBI identifier of the table
+	Name string // name of the table
+
+	// amino acid of each codon,
+	// with the bases in TCAG order
+	// (as in NCBI tables),
+	// '*' is a stop codon
+	aa string
+}
+
+// GeneticCodes are the genetic code tables
+// defined by the NCBI,
+// the standard code is the first one.
+var GeneticCodes = []*GeneticCode{
+	{1, "standard", "FFLLSSSSYY**CC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG"},
+	{2, "vertebrate mitochondrial", "FFLLSSSSYY**CCWWLLLLPPPPHHQQRRRRIIMMTTTTNNKKSS**VVVVAAAADDEEGGGG"},
+	{3, "yeast mitochondrial", "FFLLSSSSYY**CCWWTTTTPPPPHHQQRRRRIIMMTTTTNNKKSSRRVVVVAAAADDEEGGGG"},
+	{4, "mold mitochondrial", "FFLLSSSSYY**CCWWLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG"},
+	{5, "invertebrate mitochondrial", "FFLLSSSSYY**CCWWLLLLPPPPHHQQRRRRIIMMTTTTNNKKSSSSVVVVAAAADDEEGGGG"},
+	{9, "echinoderm mitochondrial", "FFLLSSSSYY**CCWWLLLLPPPPHHQQRRRRIIIMTTTTNNNKSSSSVVVVAAAADDEEGGGG"},
+	{11, "bacterial", "FFLLSSSSYY**CC*WLLLLPPPPHHQQRRRRIIIMTTTTNNKKSSRRVVVVAAAADDEEGGGG"},
+}
+
+// ParseGeneticCode returns a genetic code
+// from its NCBI identifier,
+// or its name.
+// An empty string is the standard code.
+func ParseGeneticCode(name string) (*GeneticCode, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return GeneticCodes[0], nil
+	}
+	id, err := strconv.Atoi(name)
+	for _, gc := range GeneticCodes {
+		if err == nil && gc.ID == id {
+			return gc, nil
+		}
+		if gc.Name == name {
+			return gc, nil
+		}
+	}
+	return nil, errors.Errorf("matrix: unknown genetic code %q", name)
+}
+
+// TCAG is the index of each DNA state
+// (A, C, G, T)
+// in the TCAG order.
+var tcag = [4]int{2, 1, 3, 0}
+
+// Translate returns the amino acids
+// (as a protein state set)
+// coded by a codon,
+// given as three DNA state sets.
+// Ambiguous codons are translated
+// into the set of all the amino acids
+// they can code,
+// ignoring stop codons.
+// If all the codons are stop codons,
+// it returns 0 and true.
+func (gc *GeneticCode) Translate(codon [3]uint32) (aa uint32, stop bool) {
+	for i := 0; i < 4; i++ {
+		if codon[0]&(1<<uint(i)) == 0 {
+			continue
+		}
+		for j := 0; j < 4; j++ {
+			if codon[1]&(1<<uint(j)) == 0 {
+				continue
+			}
+			for k := 0; k < 4; k++ {
+				if codon[2]&(1<<uint(k)) == 0 {
+					continue
+				}
+				c := gc.aa[16*tcag[i]+4*tcag[j]+tcag[k]]
+				if c == '*' {
+					continue
+				}
+				aa |= 1 << uint(strings.IndexByte(aminoAcids, c))
+			}
+		}
+	}
+	return aa, aa == 0
+}
+
+// A FrameProblem is a problem
+// in the reading frame
+// of a terminal in a gene.
+type FrameProblem struct {
+	Term string // name of the terminal
+	Char int    // first character of the codon
+	Stop bool   // true for an internal stop codon, false for a frame shift
+}
+
+// CheckFrame returns the internal stop codons,
+// and the frame shifts
+// (i.e. codons with gaps
+// in only some positions)
+// of each terminal in a gene.
+// The characters of the gene
+// must be DNA characters,
+// starting at the first codon position.
+// Stop codons in the last codon
+// with data of a terminal
+// are not reported.
+// Problems are sorted by terminal name.
+func (m *Matrix) CheckFrame(gene Partition, gc *GeneticCode) ([]FrameProblem, error) {
+	if err := m.checkGene(gene); err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(m.Names))
+	for nm := range m.Names {
+		names = append(names, nm)
+	}
+	sort.Strings(names)
+
+	var fp []FrameProblem
+	for _, nm := range names {
+		t := m.Names[nm]
+		gaps := t.gapSet()
+
+		// last codon with data
+		last := -1
+		for i := 0; i < len(gene.Chars); i++ {
+			c := gene.Chars[i]
+			if !gaps[c] && t.State(c)&Unknown(DNA) != Unknown(DNA) {
+				last = i / 3
+			}
+		}
+
+		for i := 0; i < len(gene.Chars); i += 3 {
+			var codon [3]uint32
+			ng := 0
+			for j := range codon {
+				c := gene.Chars[i+j]
+				if gaps[c] {
+					ng++
+				}
+				codon[j] = t.State(c) &^ Gap
+			}
+			if ng > 0 {
+				if ng < 3 {
+					fp = append(fp, FrameProblem{Term: nm, Char: gene.Chars[i]})
+				}
+				continue
+			}
+			if i/3 == last {
+				continue
+			}
+			if _, stop := gc.Translate(codon); stop {
+				fp = append(fp, FrameProblem{Term: nm, Char: gene.Chars[i], Stop: true})
+			}
+		}
+	}
+	return fp, nil
+}
+
+// CheckGene returns an error
+// if a gene has characters that are not DNA,
+// or if its number of characters
+// is not a multiple of 3.
+func (m *Matrix) checkGene(gene Partition) error {
+	for _, c := range gene.Chars {
+		if m.Kind[c] != DNA {
+			return errors.Errorf("matrix: codon: gene %s: character %d is not DNA", gene.Name, c+1)
+		}
+	}
+	if len(gene.Chars)%3 != 0 {
+		return errors.Errorf("matrix: codon: gene %s: %d characters, not a multiple of 3", gene.Name, len(gene.Chars))
+	}
+	return nil
+}
+
+// Translate returns a new matrix
+// with the amino acids coded
+// by the DNA characters of a set of genes,
+// each gene translated as a protein block.
+// Stop codons,
+// and codons with gaps,
+// are translated as unknown amino acids.
+func (m *Matrix) Translate(genes []Partition, gc *GeneticCode) (*Matrix, error) {
+	for _, g := range genes {
+		if err := m.checkGene(g); err != nil {
+			return nil, err
+		}
+	}
+	names := make([]string, 0, len(m.Names))
+	for nm := range m.Names {
+		if nm != m.Out.Name {
+			names = append(names, nm)
+		}
+	}
+	sort.Strings(names)
+	names = append([]string{m.Out.Name}, names...)
+
+	gaps := make(map[string]map[int]bool, len(names))
+	for _, nm := range names {
+		gaps[nm] = m.Names[nm].gapSet()
+	}
+
+	var taxa []*Taxon
+	for b, g := range genes {
+		for _, nm := range names {
+			t := m.Names[nm]
+			tx := &Taxon{
+				Name:      nm,
+				Block:     b,
+				BlockName: g.Name,
+				Type:      Protein,
+				Chars:     make([]uint32, 0, len(g.Chars)/3),
+			}
+			for i := 0; i < len(g.Chars); i += 3 {
+				var codon [3]uint32
+				for j := range codon {
+					if c := g.Chars[i+j]; !gaps[nm][c] {
+						codon[j] = t.State(c)
+					}
+				}
+				aa, _ := gc.Translate(codon)
+				if aa == 0 {
+					aa = Unknown(Protein)
+				}
+				tx.Chars = append(tx.Chars, aa)
+			}
+			taxa = append(taxa, tx)
+		}
+	}
+	return fromTaxa(taxa)
+}
+
+// GapSet returns the DNA characters
+// with gaps
+// of a terminal.
+func (t *Terminal) gapSet() map[int]bool {
+	gaps := make(map[int]bool, len(t.gaps))
+	for _, c := range t.gaps {
+		gaps[c] = true
+	}
+	return gaps
+}
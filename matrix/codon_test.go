@@ -0,0 +1,126 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var codonBlob = `
+> dna coi
+Out ATGTGGAAATAA
+A   ATGTGAAAA---
+B   ATG-GGAAATAG
+C   ATGTGG---???
+`
+
+func TestGeneticCode(t *testing.T) {
+	if _, err := ParseGeneticCode("100"); err == nil {
+		t.Errorf("matrix: genetic code: expecting error on unknown code")
+	}
+	std, err := ParseGeneticCode("")
+	if err != nil {
+		t.Fatalf("matrix: genetic code: unexpected error: %v", err)
+	}
+	mt, err := ParseGeneticCode("Vertebrate mitochondrial")
+	if err != nil || mt.ID != 2 {
+		t.Fatalf("matrix: genetic code: got %v, %v", mt, err)
+	}
+
+	aa := func(s string) uint32 {
+		st, _ := aminoAcid(rune(s[0]))
+		return st
+	}
+	tests := []struct {
+		codon string
+		code  *GeneticCode
+		aa    uint32
+		stop  bool
+	}{
+		{"ATG", std, aa("M"), false},
+		{"TGG", std, aa("W"), false},
+		{"TGA", std, 0, true},
+		{"TGA", mt, aa("W"), false},
+		{"AGA", mt, 0, true},
+		{"ATA", mt, aa("M"), false},
+		{"GGN", std, aa("G"), false},
+		{"TAY", std, aa("Y"), false},
+		// TAR is always a stop codon,
+		// but TRG can be TAG (stop) or TGG
+		{"TAR", std, 0, true},
+		{"TRG", std, aa("W"), false},
+		{"NNN", std, Unknown(Protein), false},
+	}
+	for _, test := range tests {
+		var codon [3]uint32
+		r := bufio.NewReader(strings.NewReader(test.codon))
+		for i := range codon {
+			st, err := readStates(r, DNA)
+			if err != nil {
+				t.Fatalf("matrix: genetic code: unexpected error: %v", err)
+			}
+			codon[i] = st
+		}
+		a, stop := test.code.Translate(codon)
+		if a != test.aa || stop != test.stop {
+			t.Errorf("matrix: genetic code: %s (code %d): got %x %v, want %x %v", test.codon, test.code.ID, a, stop, test.aa, test.stop)
+		}
+	}
+}
+
+func TestCheckFrame(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(codonBlob))
+	if err != nil {
+		t.Fatalf("matrix: frame: unexpected error while reading matrix: %v", err)
+	}
+	gene := m.BlockPartitions()[0]
+	fp, err := m.CheckFrame(gene, GeneticCodes[0])
+	if err != nil {
+		t.Fatalf("matrix: frame: unexpected error: %v", err)
+	}
+	want := []FrameProblem{
+		{Term: "A", Char: 3, Stop: true},
+		{Term: "B", Char: 3},
+	}
+	if !reflect.DeepEqual(fp, want) {
+		t.Errorf("matrix: frame: got %v, want %v", fp, want)
+	}
+
+	if _, err := m.CheckFrame(Partition{Name: "bad", Chars: []int{0, 1}}, GeneticCodes[0]); err == nil {
+		t.Errorf("matrix: frame: expecting error on incomplete codons")
+	}
+
+	p, err := m.Translate([]Partition{gene}, GeneticCodes[0])
+	if err != nil {
+		t.Fatalf("matrix: translate: unexpected error: %v", err)
+	}
+	if len(p.Kind) != 4 || p.Kind[0] != Protein || p.Blocks[0].Name != "coi" {
+		t.Fatalf("matrix: translate: %d characters, blocks %v", len(p.Kind), p.Blocks)
+	}
+	unk := Unknown(Protein)
+	tests := map[string]string{
+		"Out": "MWK",
+		"A":   "M?K",
+		"B":   "M?K",
+		"C":   "MW?",
+	}
+	for nm, seq := range tests {
+		for i, r := range seq {
+			want, _ := aminoAcid(r)
+			if got := p.Names[nm].Chars[i]; got != want {
+				t.Errorf("matrix: translate: %s: codon %d: got %x, want %x", nm, i+1, got, want)
+			}
+		}
+		if got := p.Names[nm].Chars[3]; got != unk {
+			t.Errorf("matrix: translate: %s: stop codon: got %x, want %x", nm, got, unk)
+		}
+	}
+	if p.Out.Name != "Out" {
+		t.Errorf("matrix: translate: outgroup %s, want %s", p.Out.Name, "Out")
+	}
+}
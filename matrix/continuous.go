@@ -0,0 +1,111 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Continuous is a table of continuous characters
+// (e.g. body size).
+type Continuous struct {
+	Chars  []string             // names of the characters
+	Values map[string][]float64 // values of each terminal
+}
+
+// ReadContinuous reads a table of continuous characters.
+// The first line is a header,
+// with a label for the terminal column
+// (e.g. "taxon"),
+// and the names of the characters.
+// Each following line is a terminal,
+// with its name,
+// and the value of each character,
+// separated by spaces or tabs.
+// Missing values are indicated with '?',
+// and stored as NaN.
+// Empty lines,
+// and lines starting with '#'
+// are ignored.
+//
+// The table can also be read
+// from the continuous blocks
+// of a TNT file
+// (see ReadTNT).
+func ReadContinuous(r io.Reader) (*Continuous, error) {
+	br := bufio.NewReader(r)
+	if isTNT(br) {
+		_, c, err := readTNT(br)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			return nil, errors.New("matrix: tnt: no continuous data")
+		}
+		return c, nil
+	}
+	c := &Continuous{Values: make(map[string][]float64)}
+	s := bufio.NewScanner(br)
+	ln := 0
+	for s.Scan() {
+		ln++
+		f := strings.Fields(s.Text())
+		if len(f) == 0 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		if c.Chars == nil {
+			if len(f) < 2 {
+				return nil, errors.Errorf("matrix: continuous: line %d: header without characters", ln)
+			}
+			c.Chars = f[1:]
+			continue
+		}
+		if len(f) != len(c.Chars)+1 {
+			return nil, errors.Errorf("matrix: continuous: line %d: found %d values, want %d", ln, len(f)-1, len(c.Chars))
+		}
+		if _, dup := c.Values[f[0]]; dup {
+			return nil, errors.Errorf("matrix: continuous: line %d: terminal %s repeated", ln, f[0])
+		}
+		vals := make([]float64, len(c.Chars))
+		for i, v := range f[1:] {
+			if v == "?" {
+				vals[i] = math.NaN()
+				continue
+			}
+			x, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "matrix: continuous: line %d", ln)
+			}
+			vals[i] = x
+		}
+		c.Values[f[0]] = vals
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrap(err, "matrix: continuous")
+	}
+	if len(c.Values) == 0 {
+		return nil, errors.New("matrix: continuous: table without terminals")
+	}
+	return c, nil
+}
+
+// Char returns the values of a character,
+// for the terminals without missing data.
+func (c *Continuous) Char(char int) map[string]float64 {
+	vals := make(map[string]float64, len(c.Values))
+	for nm, v := range c.Values {
+		if math.IsNaN(v[char]) {
+			continue
+		}
+		vals[nm] = v[char]
+	}
+	return vals
+}
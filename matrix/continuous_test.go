@@ -0,0 +1,50 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"strings"
+	"testing"
+)
+
+var continuousBlob = `
+# body size and wing length
+taxon	size	wing
+A	10.5	2
+B	12	?
+C	8.25	1.5
+`
+
+func TestReadContinuous(t *testing.T) {
+	c, err := ReadContinuous(strings.NewReader(continuousBlob))
+	if err != nil {
+		t.Fatalf("matrix: continuous: unexpected error: %v", err)
+	}
+	if len(c.Chars) != 2 || c.Chars[0] != "size" || c.Chars[1] != "wing" {
+		t.Errorf("matrix: continuous: characters %v, want [size wing]", c.Chars)
+	}
+	size := c.Char(0)
+	if len(size) != 3 || size["C"] != 8.25 {
+		t.Errorf("matrix: continuous: size values %v", size)
+	}
+	wing := c.Char(1)
+	if len(wing) != 2 {
+		t.Errorf("matrix: continuous: %d terminals with wing values, want %d", len(wing), 2)
+	}
+	if _, ok := wing["B"]; ok {
+		t.Errorf("matrix: continuous: missing value of B found")
+	}
+
+	for _, blob := range []string{
+		"taxon size\nA 1 2\n",
+		"taxon size\nA x\n",
+		"taxon size\nA 1\nA 2\n",
+		"taxon size\n",
+	} {
+		if _, err := ReadContinuous(strings.NewReader(blob)); err == nil {
+			t.Errorf("matrix: continuous: %q: expecting error", blob)
+		}
+	}
+}
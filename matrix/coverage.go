@@ -0,0 +1,149 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import "math/rand"
+
+// Covered returns true if the terminal
+// has at least one known character
+// in the indicated block.
+func (m *Matrix) Covered(t *Terminal, b Block) bool {
+	for i := b.Start; i < b.End; i++ {
+		if t.State(i) != m.unknown(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// Occupancy returns the proportion
+// of terminal-block pairs
+// in which the terminal is covered by the block.
+func (m *Matrix) Occupancy() float64 {
+	if len(m.Names) == 0 || len(m.Blocks) == 0 {
+		return 0
+	}
+	n := 0
+	for _, t := range m.Names {
+		for _, b := range m.Blocks {
+			if m.Covered(t, b) {
+				n++
+			}
+		}
+	}
+	return float64(n) / float64(len(m.Names)*len(m.Blocks))
+}
+
+// Decisiveness is the result of a decisiveness analysis
+// of the taxon coverage of a matrix.
+//
+// A quartet of terminals is covered,
+// if there is at least one block
+// in which the four terminals are covered.
+// A taxon coverage pattern is decisive
+// for all trees if all quartets are covered.
+type Decisiveness struct {
+	Quartets int  // Number of evaluated quartets
+	Covered  int  // Number of covered quartets
+	Exact    bool // True if all quartets were evaluated
+
+	// Number of evaluated and covered quartets
+	// that include each terminal
+	TermQuartets map[string]int
+	TermCovered  map[string]int
+}
+
+// Prop returns the proportion of covered quartets.
+func (d *Decisiveness) Prop() float64 {
+	if d.Quartets == 0 {
+		return 1
+	}
+	return float64(d.Covered) / float64(d.Quartets)
+}
+
+// IsDecisive returns true if all evaluated
+// quartets are covered.
+func (d *Decisiveness) IsDecisive() bool {
+	return d.Covered == d.Quartets
+}
+
+// Without returns the proportion of covered quartets
+// if the indicated terminal is removed.
+func (d *Decisiveness) Without(name string) float64 {
+	q := d.Quartets - d.TermQuartets[name]
+	if q == 0 {
+		return 1
+	}
+	return float64(d.Covered-d.TermCovered[name]) / float64(q)
+}
+
+// Decisiveness calculates the decisiveness
+// of the taxon coverage of the matrix blocks.
+// If the number of quartets is greater than sample,
+// then only a random sample of quartets
+// will be evaluated.
+// If sample is 0 or less,
+// all quartets will be evaluated.
+func (m *Matrix) Decisiveness(sample int) *Decisiveness {
+	taxa := m.Taxa()
+	cover := make([][]bool, len(taxa))
+	for i, t := range taxa {
+		cover[i] = make([]bool, len(m.Blocks))
+		for j, b := range m.Blocks {
+			cover[i][j] = m.Covered(t, b)
+		}
+	}
+
+	d := &Decisiveness{
+		TermQuartets: make(map[string]int, len(taxa)),
+		TermCovered:  make(map[string]int, len(taxa)),
+	}
+	eval := func(q [4]int) {
+		d.Quartets++
+		covered := false
+		for j := range m.Blocks {
+			if cover[q[0]][j] && cover[q[1]][j] && cover[q[2]][j] && cover[q[3]][j] {
+				covered = true
+				break
+			}
+		}
+		if covered {
+			d.Covered++
+		}
+		for _, x := range q {
+			nm := taxa[x].Name
+			d.TermQuartets[nm]++
+			if covered {
+				d.TermCovered[nm]++
+			}
+		}
+	}
+
+	n := len(taxa)
+	if n < 4 {
+		d.Exact = true
+		return d
+	}
+	total := float64(n) * float64(n-1) * float64(n-2) * float64(n-3) / 24
+	if sample <= 0 || total <= float64(sample) {
+		d.Exact = true
+		for a := 0; a < n; a++ {
+			for b := a + 1; b < n; b++ {
+				for c := b + 1; c < n; c++ {
+					for x := c + 1; x < n; x++ {
+						eval([4]int{a, b, c, x})
+					}
+				}
+			}
+		}
+		return d
+	}
+
+	for i := 0; i < sample; i++ {
+		p := rand.Perm(n)
+		eval([4]int{p[0], p[1], p[2], p[3]})
+	}
+	return d
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"strings"
+	"testing"
+)
+
+var coverBlob = `
+> dna gene1
+A ACGT
+B ACGA
+C ACTT
+D ????
+> dna gene2
+A ACGT
+B ACGA
+D ACTT
+> morpho
+A 0011
+E 0101
+`
+
+func TestCoverage(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(coverBlob))
+	if err != nil {
+		t.Fatalf("matrix: coverage: unexpected error while reading matrix: %v", err)
+	}
+	if len(m.Blocks) != 3 {
+		t.Fatalf("matrix: coverage: %d blocks, want %d", len(m.Blocks), 3)
+	}
+	if m.Blocks[0].Name != "gene1" {
+		t.Errorf("matrix: coverage: block name %q, want %q", m.Blocks[0].Name, "gene1")
+	}
+	if m.Blocks[2].Name != "block3" {
+		t.Errorf("matrix: coverage: block name %q, want %q", m.Blocks[2].Name, "block3")
+	}
+	if m.Blocks[1].Start != 4 || m.Blocks[1].End != 8 {
+		t.Errorf("matrix: coverage: block range %d-%d, want %d-%d", m.Blocks[1].Start, m.Blocks[1].End, 4, 8)
+	}
+	if m.Covered(m.Names["D"], m.Blocks[0]) {
+		t.Errorf("matrix: coverage: terminal %s covered by block %s", "D", m.Blocks[0].Name)
+	}
+	if !m.Covered(m.Names["D"], m.Blocks[1]) {
+		t.Errorf("matrix: coverage: terminal %s not covered by block %s", "D", m.Blocks[1].Name)
+	}
+	if occ := m.Occupancy(); occ != float64(8)/15 {
+		t.Errorf("matrix: coverage: occupancy %.3f, want %.3f", occ, float64(8)/15)
+	}
+
+	d := m.Decisiveness(0)
+	if !d.Exact {
+		t.Errorf("matrix: coverage: decisiveness not exact")
+	}
+	if d.Quartets != 5 {
+		t.Errorf("matrix: coverage: %d quartets, want %d", d.Quartets, 5)
+	}
+	if d.Covered != 0 {
+		t.Errorf("matrix: coverage: %d covered quartets, want %d", d.Covered, 0)
+	}
+
+	m, err = NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("matrix: coverage: unexpected error while reading matrix: %v", err)
+	}
+	d = m.Decisiveness(100)
+	if d.Exact {
+		t.Errorf("matrix: coverage: decisiveness with sample is exact")
+	}
+	if !d.IsDecisive() {
+		t.Errorf("matrix: coverage: complete matrix is not decisive")
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+// Exclude deactivates the indicated characters,
+// so they are ignored in the analyses,
+// as its weight is 0.
+// Excluded characters are kept in the matrix,
+// so the indexes of the characters
+// are not changed,
+// and its weights are restored
+// when they are included again.
+func (m *Matrix) Exclude(chars []int) {
+	if len(chars) == 0 {
+		return
+	}
+	if m.excluded == nil {
+		m.excluded = make([]bool, len(m.Kind))
+	}
+	for _, c := range chars {
+		m.excluded[c] = true
+	}
+}
+
+// Include activates the indicated characters,
+// previously deactivated with Exclude.
+func (m *Matrix) Include(chars []int) {
+	if m.excluded == nil {
+		return
+	}
+	for _, c := range chars {
+		m.excluded[c] = false
+	}
+	for _, x := range m.excluded {
+		if x {
+			return
+		}
+	}
+	m.excluded = nil
+}
+
+// IsExcluded returns true
+// if a character is deactivated.
+func (m *Matrix) IsExcluded(char int) bool {
+	if m.excluded == nil {
+		return false
+	}
+	return m.excluded[char]
+}
+
+// Excluded returns the deactivated characters
+// of the matrix.
+func (m *Matrix) Excluded() []int {
+	var ls []int
+	for c, x := range m.excluded {
+		if x {
+			ls = append(ls, c)
+		}
+	}
+	return ls
+}
+
+// SelectExcluded returns the deactivated state
+// of the indicated characters,
+// or nil,
+// if no character is deactivated.
+func (m *Matrix) selectExcluded(chars []int) []bool {
+	if m.excluded == nil {
+		return nil
+	}
+	x := make([]bool, len(chars))
+	for i, c := range chars {
+		x[i] = m.excluded[c]
+	}
+	return x
+}
@@ -0,0 +1,62 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExclude(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(weightsBlob))
+	if err != nil {
+		t.Fatalf("matrix: exclude: unexpected error while reading matrix: %v", err)
+	}
+	if err := m.SetWeights("2:1-3"); err != nil {
+		t.Fatalf("matrix: exclude: unexpected error: %v", err)
+	}
+
+	chars, err := ParseRange("2-3 5", len(m.Kind))
+	if err != nil {
+		t.Fatalf("matrix: exclude: unexpected error: %v", err)
+	}
+	m.Exclude(chars)
+	if x := m.Excluded(); !reflect.DeepEqual(x, chars) {
+		t.Errorf("matrix: exclude: got %v, want %v", x, chars)
+	}
+	if !m.IsExcluded(4) || m.IsExcluded(3) {
+		t.Errorf("matrix: exclude: wrong excluded characters: %v", m.Excluded())
+	}
+	want := []int{2, 0, 0, 1, 0, 1}
+	if !reflect.DeepEqual(m.Weights(), want) {
+		t.Errorf("matrix: exclude: weights %v, want %v", m.Weights(), want)
+	}
+
+	// excluded characters are kept on derived matrices
+	d := m.DropChars([]int{0})
+	if w := []int{0, 0, 1, 0, 1}; !reflect.DeepEqual(d.Weights(), w) {
+		t.Errorf("matrix: exclude: drop chars: weights %v, want %v", d.Weights(), w)
+	}
+	r := m.Resample([]int{4, 0, 0})
+	if w := []int{2, 2, 0}; !reflect.DeepEqual(r.Weights(), w) {
+		t.Errorf("matrix: exclude: resample: weights %v, want %v", r.Weights(), w)
+	}
+
+	// weights are restored
+	m.Include([]int{1})
+	want = []int{2, 2, 0, 1, 0, 1}
+	if !reflect.DeepEqual(m.Weights(), want) {
+		t.Errorf("matrix: exclude: include: weights %v, want %v", m.Weights(), want)
+	}
+	m.Include([]int{2, 4})
+	if m.Excluded() != nil {
+		t.Errorf("matrix: exclude: include: excluded %v, want nil", m.Excluded())
+	}
+	want = []int{2, 2, 2, 1, 1, 1}
+	if !reflect.DeepEqual(m.Weights(), want) {
+		t.Errorf("matrix: exclude: include: weights %v, want %v", m.Weights(), want)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// NewFromFasta returns a new matrix
+// from an aligned FASTA file,
+// for example,
+// the output of MAFFT or MUSCLE.
+//
+// Each sequence starts with a header line,
+// that starts with '>',
+// and the first word of the header
+// is used as the name of the terminal
+// (the rest of the header is ignored).
+// The sequence can be split in several lines.
+// Lines starting with ';' are ignored.
+//
+// The sequences are read as a single DNA block,
+// or as a protein block
+// if there are amino acid symbols
+// that are not nucleotide symbols.
+// All sequences must have the same length.
+func NewFromFasta(r io.Reader) (*Matrix, error) {
+	taxa, err := readFasta(bufio.NewReader(r))
+	if err != nil {
+		return nil, err
+	}
+	return fromTaxa(taxa)
+}
+
+// IsFasta returns true if the reader
+// starts with a FASTA header,
+// i.e. a line that starts with '>',
+// and its first word is not a data type.
+func isFasta(r *bufio.Reader) bool {
+	h, _ := r.Peek(r.Size())
+	s := strings.TrimSpace(string(h))
+	if !strings.HasPrefix(s, ">") {
+		return false
+	}
+	ln := s[1:]
+	if i := strings.IndexByte(ln, '\n'); i >= 0 {
+		ln = ln[:i]
+	}
+	f := strings.Fields(ln)
+	if len(f) == 0 {
+		return false
+	}
+	tp := strings.ToLower(f[0])
+	return tp != "dna" && tp != "protein" && !strings.HasPrefix(tp, "morpho")
+}
+
+// ReadFasta reads the sequences
+// of a FASTA file.
+func readFasta(r *bufio.Reader) ([]*Taxon, error) {
+	var taxa []*Taxon
+	var tx *Taxon
+	var seq strings.Builder
+	names := make(map[string]bool)
+
+	var seqs []string
+
+	// add the sequence
+	// of the current taxon
+	addSeq := func() error {
+		if tx == nil {
+			return nil
+		}
+		s := seq.String()
+		if s == "" {
+			return errors.Errorf("matrix: fasta: taxon %s: no sequence", tx.Name)
+		}
+		taxa = append(taxa, tx)
+		seqs = append(seqs, s)
+		seq.Reset()
+		return nil
+	}
+
+	for i := 1; ; i++ {
+		ln, err := r.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, errors.Wrapf(err, "matrix: fasta: line %d", i)
+		}
+		ln = strings.TrimSpace(ln)
+		switch {
+		case ln == "" || strings.HasPrefix(ln, ";"):
+		case strings.HasPrefix(ln, ">"):
+			if err := addSeq(); err != nil {
+				return nil, err
+			}
+			f := strings.Fields(ln[1:])
+			if len(f) == 0 {
+				return nil, errors.Errorf("matrix: fasta: line %d: header without name", i)
+			}
+			if names[f[0]] {
+				return nil, errors.Errorf("matrix: fasta: line %d: taxon %s repeated", i, f[0])
+			}
+			names[f[0]] = true
+			tx = &Taxon{Name: f[0], Block: 1, Type: DNA, Line: i}
+		default:
+			if tx == nil {
+				return nil, errors.Errorf("matrix: fasta: line %d: sequence without header", i)
+			}
+			seq.WriteString(strings.Join(strings.Fields(ln), ""))
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	if err := addSeq(); err != nil {
+		return nil, err
+	}
+	if len(taxa) == 0 {
+		return nil, errors.New("matrix: fasta: no sequences")
+	}
+
+	kind := DNA
+	for _, s := range seqs {
+		if isProtein(s) {
+			kind = Protein
+			break
+		}
+	}
+	for i, tx := range taxa {
+		tx.Type = kind
+		sr := bufio.NewReader(strings.NewReader(seqs[i]))
+		for {
+			c, err := readStates(sr, kind)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, "matrix: fasta: taxon %s: char %d", tx.Name, len(tx.Chars)+1)
+			}
+			tx.Chars = append(tx.Chars, c)
+		}
+	}
+	return taxa, nil
+}
+
+// IsProtein returns true
+// if a sequence has amino acid symbols
+// that are not nucleotide symbols.
+func isProtein(s string) bool {
+	return strings.ContainsAny(strings.ToUpper(s), "EFILPQZJ*")
+}
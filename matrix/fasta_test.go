@@ -0,0 +1,91 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var fastaBlob = `>Out some description
+ACGT
+AC
+; an old style comment
+>A
+AC-T
+ac
+>B
+RCNT
+A?
+`
+
+func TestFasta(t *testing.T) {
+	want := map[string][]uint32{
+		"Out": {1, 2, 4, 8, 1, 2},
+		"A":   {1, 2, 15, 8, 1, 2},
+		"B":   {5, 2, 15, 8, 1, 15},
+	}
+	for _, fn := range []string{"NewFromFasta", "NewMatrix"} {
+		var m *Matrix
+		var err error
+		if fn == "NewMatrix" {
+			m, err = NewMatrix(strings.NewReader(fastaBlob))
+		} else {
+			m, err = NewFromFasta(strings.NewReader(fastaBlob))
+		}
+		if err != nil {
+			t.Fatalf("matrix: fasta: %s: unexpected error: %v", fn, err)
+		}
+		if m.Out.Name != "Out" {
+			t.Errorf("matrix: fasta: %s: outgroup %s, want Out", fn, m.Out.Name)
+		}
+		if len(m.Blocks) != 1 || m.Blocks[0].Type != DNA {
+			t.Errorf("matrix: fasta: %s: blocks %v, want a single DNA block", fn, m.Blocks)
+		}
+		if len(m.Names) != len(want) {
+			t.Errorf("matrix: fasta: %s: %d terminals, want %d", fn, len(m.Names), len(want))
+		}
+		for nm, w := range want {
+			tx, ok := m.Names[nm]
+			if !ok {
+				t.Errorf("matrix: fasta: %s: terminal %s not found", fn, nm)
+				continue
+			}
+			if !reflect.DeepEqual(tx.Chars, w) {
+				t.Errorf("matrix: fasta: %s: terminal %s: chars %v, want %v", fn, nm, tx.Chars, w)
+			}
+		}
+	}
+
+	prot, err := NewFromFasta(strings.NewReader(">A\nMKLV\n>B\nMKIX\n"))
+	if err != nil {
+		t.Fatalf("matrix: fasta: protein: unexpected error: %v", err)
+	}
+	if prot.Blocks[0].Type != Protein {
+		t.Errorf("matrix: fasta: protein: data type %s", prot.Blocks[0].Type)
+	}
+	if w := []uint32{1 << 12, 1 << 11, 1 << 9, 1<<20 - 1}; !reflect.DeepEqual(prot.Names["B"].Chars, w) {
+		t.Errorf("matrix: fasta: protein: terminal B: chars %v, want %v", prot.Names["B"].Chars, w)
+	}
+
+	// a native matrix is not read as FASTA
+	if _, err := NewMatrix(strings.NewReader(">dna\nA ACGT\nB ACGA\n")); err != nil {
+		t.Errorf("matrix: fasta: native matrix: unexpected error: %v", err)
+	}
+
+	bad := map[string]string{
+		"unaligned":   ">A\nACGT\n>B\nACG\n",
+		"stop":        ">A\nMKL*\n>B\nMKIV\n",
+		"no header":   "ACGT\n>A\nACGT\n",
+		"no sequence": ">A\n>B\nACGT\n",
+		"repeated":    ">A\nACGT\n>A\nACGT\n",
+	}
+	for n, b := range bad {
+		if _, err := NewFromFasta(strings.NewReader(b)); err == nil {
+			t.Errorf("matrix: fasta: %s: expecting error", n)
+		}
+	}
+}
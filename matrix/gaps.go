@@ -0,0 +1,159 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// A GapMode is the treatment of the gaps
+// of DNA characters.
+type GapMode int
+
+// Gap modes.
+const (
+	GapMissing GapMode = iota // Gaps are read as unknown states
+	GapState                  // Gaps are a fifth state
+)
+
+// Gap is the state of a gap
+// in DNA characters,
+// when gaps are a fifth state.
+// It is also the state of a gap
+// in the characters read by a Scanner,
+// and when a matrix is built,
+// the gaps are recorded
+// and set as unknown states.
+const Gap uint32 = 1 << 4
+
+// String returns the name of a gap mode.
+func (g GapMode) String() string {
+	if g == GapState {
+		return "state"
+	}
+	return "missing"
+}
+
+// ParseGapMode returns a gap mode from its name.
+// Valid names are "missing",
+// and "state"
+// (also "fifth").
+func ParseGapMode(name string) (GapMode, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "missing":
+		return GapMissing, nil
+	case "state", "fifth":
+		return GapState, nil
+	}
+	return GapMissing, errors.Errorf("matrix: unknown gap mode %q", name)
+}
+
+// GapMode returns the gap mode of the matrix.
+func (m *Matrix) GapMode() GapMode {
+	return m.gapMode
+}
+
+// SetGapMode sets the treatment of the gaps
+// of the DNA characters of the matrix.
+// By default,
+// gaps are read as unknown states.
+// If the mode is GapState,
+// gaps are set as a fifth state (Gap),
+// and the unknown states
+// include the gap state,
+// so parsimony optimizations
+// count a step for each change
+// between a gap and a nucleotide.
+//
+// The terminals are copied before its states are changed,
+// so matrices that share terminals with the matrix
+// (e.g. a matrix made with DropTaxa)
+// are not changed.
+// Gaps are only recorded
+// when the matrix is read,
+// or when blocks or characters are removed,
+// so in a resampled matrix
+// the gap mode should be set before resampling.
+func (m *Matrix) SetGapMode(mode GapMode) error {
+	if mode == m.gapMode {
+		return nil
+	}
+	for _, t := range m.Names {
+		if t.IsPacked() {
+			return errors.Errorf("matrix: gap mode: terminal %s: packed terminals not supported", t.Name)
+		}
+	}
+
+	gap, unk := Unknown(DNA), Unknown(DNA)
+	if mode == GapState {
+		gap, unk = Gap, Unknown(DNA)|Gap
+	}
+	for nm, t := range m.Names {
+		nt := &Terminal{
+			Name:  t.Name,
+			Chars: append([]uint32{}, t.Chars...),
+			gaps:  t.gaps,
+		}
+		if t == m.Out {
+			m.Out = nt
+		}
+		m.Names[nm] = nt
+		t = nt
+
+		for i, k := range m.Kind {
+			if k != DNA {
+				continue
+			}
+			if t.Chars[i] == Unknown(DNA) || t.Chars[i] == Unknown(DNA)|Gap {
+				t.Chars[i] = unk
+			}
+		}
+		for _, c := range t.gaps {
+			t.Chars[c] = gap
+		}
+	}
+	m.gapMode = mode
+
+	// reset the column views
+	m.colOnce = sync.Once{}
+	m.taxa = nil
+	m.cols = nil
+	m.patterns = nil
+	return nil
+}
+
+// Unknown returns the unknown state
+// of a character of the matrix,
+// taking into account the gap mode.
+func (m *Matrix) unknown(char int) uint32 {
+	k := m.Kind[char]
+	if k == DNA && m.gapMode == GapState {
+		return Unknown(DNA) | Gap
+	}
+	return Unknown(k)
+}
+
+// SelectGaps returns the gaps of a terminal
+// in the indicated characters,
+// using the new index of each character.
+func (t *Terminal) selectGaps(chars []int) []int {
+	if len(t.gaps) == 0 {
+		return nil
+	}
+	idx := make(map[int]int, len(chars))
+	for i, c := range chars {
+		idx[c] = i
+	}
+	var gaps []int
+	for _, c := range t.gaps {
+		if i, ok := idx[c]; ok {
+			gaps = append(gaps, i)
+		}
+	}
+	return gaps
+}
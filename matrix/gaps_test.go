@@ -0,0 +1,100 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var gapsBlob = `
+> morpho
+Out 0
+A   1
+B   ?
+> dna
+Out ACGT
+A   AC-T
+B   A?-T
+`
+
+func TestGapMode(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(gapsBlob))
+	if err != nil {
+		t.Fatalf("matrix: gap mode: unexpected error while reading matrix: %v", err)
+	}
+	if m.GapMode() != GapMissing {
+		t.Errorf("matrix: gap mode: mode %s, want %s", m.GapMode(), GapMissing)
+	}
+	if w := []uint32{2, 1, 2, 15, 8}; !reflect.DeepEqual(m.Names["A"].Chars, w) {
+		t.Errorf("matrix: gap mode: terminal A: chars %v, want %v", m.Names["A"].Chars, w)
+	}
+	if k := m.Known(m.Names["B"]); k != 2 {
+		t.Errorf("matrix: gap mode: terminal B: %d known characters, want 2", k)
+	}
+
+	// derived matrices keep the gaps
+	d := m.DropChars([]int{1})
+
+	// matrices that share terminals
+	// are not changed
+	sh := m.DropTaxa([]string{"Out"})
+
+	if err := m.SetGapMode(GapState); err != nil {
+		t.Fatalf("matrix: gap mode: unexpected error: %v", err)
+	}
+	want := map[string][]uint32{
+		"Out": {1, 1, 2, 4, 8},
+		"A":   {2, 1, 2, Gap, 8},
+		"B":   {255, 1, 31, Gap, 8},
+	}
+	for nm, w := range want {
+		if !reflect.DeepEqual(m.Names[nm].Chars, w) {
+			t.Errorf("matrix: gap mode: state: terminal %s: chars %v, want %v", nm, m.Names[nm].Chars, w)
+		}
+	}
+	if w := []uint32{2, 1, 2, 15, 8}; !reflect.DeepEqual(sh.Names["A"].Chars, w) {
+		t.Errorf("matrix: gap mode: shared: terminal A: chars %v, want %v", sh.Names["A"].Chars, w)
+	}
+	if sh.GapMode() != GapMissing {
+		t.Errorf("matrix: gap mode: shared: mode %s, want %s", sh.GapMode(), GapMissing)
+	}
+	if m.Out != m.Names["Out"] {
+		t.Errorf("matrix: gap mode: state: outgroup not updated")
+	}
+	if k := m.Known(m.Names["B"]); k != 3 {
+		t.Errorf("matrix: gap mode: state: terminal B: %d known characters, want 3", k)
+	}
+	if st := m.States(3); st != 4|Gap {
+		t.Errorf("matrix: gap mode: state: character 4: states %d, want %d", st, 4|Gap)
+	}
+	if col := m.Column(3); !reflect.DeepEqual(col, []uint32{4, Gap, Gap}) {
+		t.Errorf("matrix: gap mode: state: column 4: %v, want %v", col, []uint32{4, Gap, Gap})
+	}
+
+	if err := d.SetGapMode(GapState); err != nil {
+		t.Fatalf("matrix: gap mode: unexpected error: %v", err)
+	}
+	if w := []uint32{2, 2, Gap, 8}; !reflect.DeepEqual(d.Names["A"].Chars, w) {
+		t.Errorf("matrix: gap mode: derived: terminal A: chars %v, want %v", d.Names["A"].Chars, w)
+	}
+
+	if err := m.SetGapMode(GapMissing); err != nil {
+		t.Fatalf("matrix: gap mode: unexpected error: %v", err)
+	}
+	if w := []uint32{255, 1, 15, 15, 8}; !reflect.DeepEqual(m.Names["B"].Chars, w) {
+		t.Errorf("matrix: gap mode: missing: terminal B: chars %v, want %v", m.Names["B"].Chars, w)
+	}
+
+	for _, n := range []string{"", "missing", "state", "Fifth"} {
+		if _, err := ParseGapMode(n); err != nil {
+			t.Errorf("matrix: gap mode: %q: unexpected error: %v", n, err)
+		}
+	}
+	if _, err := ParseGapMode("newstate"); err == nil {
+		t.Errorf("matrix: gap mode: expecting error")
+	}
+}
@@ -0,0 +1,76 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+// StateCounts returns the number of terminals
+// with each state of a character.
+// Only terminals with a single state are counted,
+// so unknown and polymorphic data are ignored.
+func (m *Matrix) stateCounts(char int) [32]int {
+	var counts [32]int
+	for _, s := range m.Column(char) {
+		if s == 0 || s&(s-1) != 0 {
+			continue
+		}
+		for i := uint(0); i < 32; i++ {
+			if s == 1<<i {
+				counts[i]++
+				break
+			}
+		}
+	}
+	return counts
+}
+
+// Informative returns true
+// if a character is parsimony informative,
+// i.e. if it has at least two states
+// each one present in at least two terminals.
+// Unknown and polymorphic data are ignored.
+func (m *Matrix) Informative(char int) bool {
+	n := 0
+	for _, c := range m.stateCounts(char) {
+		if c > 1 {
+			n++
+		}
+	}
+	return n > 1
+}
+
+// A CharSummary is a count of the characters
+// of a matrix
+// by its parsimony informativeness.
+type CharSummary struct {
+	Constant      int // characters with a single state
+	Uninformative int // variable characters that are not informative
+	Informative   int // parsimony informative characters
+}
+
+// Summary returns the number of constant,
+// uninformative,
+// and informative characters of the matrix.
+func (m *Matrix) Summary() CharSummary {
+	var sum CharSummary
+	for i := range m.Kind {
+		var states, shared int
+		for _, c := range m.stateCounts(i) {
+			if c > 0 {
+				states++
+			}
+			if c > 1 {
+				shared++
+			}
+		}
+		switch {
+		case shared > 1:
+			sum.Informative++
+		case states > 1:
+			sum.Uninformative++
+		default:
+			sum.Constant++
+		}
+	}
+	return sum
+}
@@ -7,16 +7,37 @@
 package matrix
 
 import (
+	"bufio"
+	"fmt"
 	"io"
+	"math/rand"
+	"sort"
+	"sync"
 
 	"github.com/pkg/errors"
 )
 
 // A Matrix is a phylogenetic dataset.
 type Matrix struct {
-	Out   *Terminal
-	Names map[string]*Terminal
-	Kind  []DataType
+	Out    *Terminal
+	Names  map[string]*Terminal
+	Kind   []DataType
+	Blocks []Block
+
+	// column views
+	colOnce sync.Once
+	taxa    []*Terminal
+	cols    [][]uint32
+
+	// cached data
+	patterns []Pattern
+	parts    []Partition
+
+	weights  []int         // weight of each character
+	excluded []bool        // deactivated characters
+	steps    []*StepMatrix // step matrix of each character
+	gapMode  GapMode       // treatment of DNA gaps
+	counts   []int         // characters of each site pattern (see Compress)
 }
 
 // IsValid returns true,
@@ -27,44 +48,125 @@ func (m *Matrix) IsValid() bool {
 	var n int
 	if m.Out == nil {
 		for _, t := range m.Names {
-			n = len(t.Chars)
+			n = t.Len()
 			break
 		}
 	} else {
-		n = len(m.Out.Chars)
+		n = m.Out.Len()
 	}
 	for _, t := range m.Names {
-		if n != len(t.Chars) {
+		if n != t.Len() {
 			return false
 		}
 	}
 	return true
 }
 
+// A Block is a set of contiguous characters
+// of the same data type,
+// for example,
+// the characters of a gene in a supermatrix.
+type Block struct {
+	Name  string
+	Type  DataType
+	Start int // first character of the block
+	End   int // end of the block (not included)
+}
+
+// Len returns the number of characters in the block.
+func (b Block) Len() int {
+	return b.End - b.Start
+}
+
 // A Terminal is a terminal taxon
 // with phylogenetic (character) data.
+//
+// Characters can be stored as packed data
+// (see Matrix.Pack),
+// in that case,
+// the Chars field is nil,
+// and character states should be accessed
+// with the State method.
 type Terminal struct {
 	Name  string
-	Chars []uint8
+	Chars []uint32
+
+	packed []uint8 // packed data
+	size   int     // number of characters in packed data
+	gaps   []int   // DNA characters with gaps
 }
 
 // NewMatrix returns a new matrix
 // from a reader.
+// The reader can be a text matrix,
+// a NEXUS file
+// (see ReadNexus),
+// an aligned FASTA file
+// (see NewFromFasta),
+// a PHYLIP file
+// (see NewFromPhylip),
+// a TNT file
+// (see ReadTNT),
+// or a bundle file
+// (see WriteBundle).
 func NewMatrix(r io.Reader) (*Matrix, error) {
-	s := NewScanner(r)
+	br := bufio.NewReader(r)
+	if isBundle(br) {
+		return readBundle(br)
+	}
+	if isNexus(br) {
+		return readNexus(br)
+	}
+	if isFasta(br) {
+		taxa, err := readFasta(br)
+		if err != nil {
+			return nil, err
+		}
+		return fromTaxa(taxa)
+	}
+	if isPhylip(br) {
+		taxa, err := readPhylip(br)
+		if err != nil {
+			return nil, err
+		}
+		return fromTaxa(taxa)
+	}
+	if isTNT(br) {
+		taxa, _, err := readTNT(br)
+		if err != nil {
+			return nil, err
+		}
+		if len(taxa) == 0 {
+			return nil, errors.New("matrix: tnt: no numeric or DNA data")
+		}
+		return fromTaxa(taxa)
+	}
+	s := NewScanner(br)
+	var taxa []*Taxon
+	for s.Scan() {
+		taxa = append(taxa, s.Taxon())
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrap(err, "matrix")
+	}
+	return fromTaxa(taxa)
+}
 
+// FromTaxa returns a new matrix
+// from a list of taxa,
+// in the order in which they were read.
+func fromTaxa(taxa []*Taxon) (*Matrix, error) {
 	block := -1
 	var ct DataType        // character type of the current block
 	var nchars, cblock int // number of chars, total and in current block
 
-	var empty, empBlock []uint8 // slice of unknowns
+	var empty, empBlock []uint32 // slice of unknowns
 
-	var bmap map[string]bool // terminals read on the current block
+	var bmap map[string]*Taxon // terminals read on the current block
 
 	m := &Matrix{Names: make(map[string]*Terminal)}
 
-	for s.Scan() {
-		tx := s.Taxon()
+	for _, tx := range taxa {
 		if tx.Block != block {
 			// A new block
 			block = tx.Block
@@ -72,17 +174,27 @@ func NewMatrix(r io.Reader) (*Matrix, error) {
 
 			// add data to taxons not defined in the block
 			for n, t := range m.Names {
-				if bmap[n] {
+				if bmap[n] != nil {
 					continue
 				}
 				t.Chars = append(t.Chars, empBlock...)
 			}
 			empty = append(empty, empBlock...)
-			bmap = make(map[string]bool)
+			bmap = make(map[string]*Taxon)
 
 			nchars += cblock
 			cblock = len(tx.Chars)
-			empBlock = make([]uint8, len(tx.Chars))
+			name := tx.BlockName
+			if name == "" {
+				name = fmt.Sprintf("block%d", block)
+			}
+			m.Blocks = append(m.Blocks, Block{
+				Name:  name,
+				Type:  ct,
+				Start: nchars,
+				End:   nchars + cblock,
+			})
+			empBlock = make([]uint32, len(tx.Chars))
 			for i := range empBlock {
 				empBlock[i] = Unknown(ct)
 			}
@@ -91,39 +203,555 @@ func NewMatrix(r io.Reader) (*Matrix, error) {
 			}
 		}
 		if len(tx.Chars) != cblock {
-			return nil, errors.Errorf("matrix: on block %d: taxon %s with wrong number of chars: %d, want %d", block, tx.Name, len(tx.Chars), cblock)
+			return nil, errors.Errorf("matrix: %son block %d: taxon %s with wrong number of chars: %d, want %d", tx.pos(), block, tx.Name, len(tx.Chars), cblock)
 		}
-		if bmap[tx.Name] {
-			return nil, errors.Errorf("matrix: on block %d: taxon %s repeated", block, tx.Name)
+		if prev, ok := bmap[tx.Name]; ok {
+			if prev.Line > 0 {
+				return nil, errors.Errorf("matrix: %son block %d: taxon %s repeated (previous definition at line %d)", tx.pos(), block, tx.Name, prev.Line)
+			}
+			return nil, errors.Errorf("matrix: %son block %d: taxon %s repeated", tx.pos(), block, tx.Name)
 		}
-		bmap[tx.Name] = true
+		bmap[tx.Name] = tx
 		t := m.Names[tx.Name]
 		if t == nil {
 			t = &Terminal{
 				Name:  tx.Name,
-				Chars: append([]uint8{}, empty...),
+				Chars: append([]uint32{}, empty...),
 			}
 			m.Names[t.Name] = t
 			if m.Out == nil {
 				m.Out = t
 			}
 		}
+		off := len(t.Chars)
 		t.Chars = append(t.Chars, tx.Chars...)
-	}
-	if err := s.Err(); err != nil {
-		return nil, errors.Wrap(err, "matrix")
+		if ct != DNA {
+			continue
+		}
+		for i, c := range tx.Chars {
+			if c&Gap == 0 {
+				continue
+			}
+			if c == Gap {
+				t.gaps = append(t.gaps, off+i)
+				t.Chars[off+i] = Unknown(DNA)
+				continue
+			}
+			// a polymorphism with a gap
+			t.Chars[off+i] = c &^ Gap
+		}
 	}
 
 	// check last block
 	for n, t := range m.Names {
-		if bmap[n] {
+		if bmap[n] != nil {
 			continue
 		}
 		t.Chars = append(t.Chars, empBlock...)
 	}
 
 	if !m.IsValid() {
+		for _, t := range m.Names {
+			if t.Len() != m.Out.Len() {
+				return nil, errors.Errorf("matrix: bad formatted matrix: taxon %s with %d chars, want %d", t.Name, t.Len(), m.Out.Len())
+			}
+		}
 		return nil, errors.New("matrix: bad formatted matrix")
 	}
 	return m, nil
 }
+
+// Pos returns the position of a taxon
+// in its source,
+// as a prefix for an error message,
+// or an empty string,
+// if the position is unknown.
+func (tx *Taxon) pos() string {
+	if tx.Line == 0 {
+		return ""
+	}
+	return fmt.Sprintf("line %d: ", tx.Line)
+}
+
+// Identity returns the proportion of identical characters
+// between two terminals,
+// and the number of characters
+// that are known in both terminals.
+// Characters that are unknown in any of the terminals
+// are ignored.
+func (m *Matrix) Identity(a, b *Terminal) (float64, int) {
+	shared, same := 0, 0
+	for i := range m.Kind {
+		u := m.unknown(i)
+		sa, sb := a.State(i), b.State(i)
+		if sa == u || sb == u {
+			continue
+		}
+		shared++
+		if sa == sb {
+			same++
+		}
+	}
+	if shared == 0 {
+		return 0, 0
+	}
+	return float64(same) / float64(shared), shared
+}
+
+// Known returns the number of characters
+// that are known in a terminal.
+func (m *Matrix) Known(t *Terminal) int {
+	n := 0
+	for i := range m.Kind {
+		if t.State(i) != m.unknown(i) {
+			n++
+		}
+	}
+	return n
+}
+
+// Missing returns the number of terminals
+// in which a character is unknown.
+func (m *Matrix) Missing(char int) int {
+	u := m.unknown(char)
+	n := 0
+	for _, c := range m.Column(char) {
+		if c == u {
+			n++
+		}
+	}
+	return n
+}
+
+// Diff returns the number of characters
+// with different states between two terminals.
+// Unknown states are compared as any other state.
+func (m *Matrix) Diff(a, b *Terminal) int {
+	d := 0
+	for i := 0; i < a.Len(); i++ {
+		if a.State(i) != b.State(i) {
+			d++
+		}
+	}
+	return d
+}
+
+// Duplicates returns the groups of terminals
+// that differ at most in eps characters.
+// The first terminal of each group
+// is the representative of the group,
+// and groups with a single terminal are not returned.
+// The outgroup is always the representative
+// of its own group.
+func (m *Matrix) Duplicates(eps int) [][]string {
+	taxa := m.Taxa()
+	used := make(map[*Terminal]bool, len(taxa))
+	var groups [][]string
+	for i, t := range taxa {
+		if used[t] {
+			continue
+		}
+		g := []string{t.Name}
+		for _, o := range taxa[i+1:] {
+			if used[o] {
+				continue
+			}
+			if m.Diff(t, o) <= eps {
+				g = append(g, o.Name)
+				used[o] = true
+			}
+		}
+		if len(g) > 1 {
+			groups = append(groups, g)
+		}
+	}
+	return groups
+}
+
+// Collapse returns a new matrix
+// in which each group of terminals
+// is represented by the first terminal of the group.
+// Terminals are shared with the original matrix.
+func (m *Matrix) Collapse(groups [][]string) *Matrix {
+	var del []string
+	for _, g := range groups {
+		del = append(del, g[1:]...)
+	}
+	return m.DropTaxa(del)
+}
+
+// DropTaxa returns a new matrix
+// without the indicated terminals.
+// Terminals are shared with the original matrix,
+// but the weights,
+// excluded characters,
+// and step matrices are copied,
+// so they can be changed in the new matrix
+// without changing the original.
+// If the outgroup is removed,
+// the first terminal (in alphabetical order)
+// will be used as the new outgroup.
+func (m *Matrix) DropTaxa(names []string) *Matrix {
+	del := make(map[string]bool, len(names))
+	for _, nm := range names {
+		del[nm] = true
+	}
+	all := allChars(len(m.Kind))
+	c := &Matrix{
+		Names:    make(map[string]*Terminal, len(m.Names)),
+		Kind:     m.Kind,
+		Blocks:   m.Blocks,
+		parts:    m.parts,
+		weights:  m.selectWeights(all),
+		excluded: m.selectExcluded(all),
+		steps:    m.selectSteps(all),
+		gapMode:  m.gapMode,
+		counts:   m.selectCounts(all),
+	}
+	for _, t := range m.Taxa() {
+		if del[t.Name] {
+			continue
+		}
+		if c.Out == nil {
+			c.Out = t
+		}
+		c.Names[t.Name] = t
+	}
+	return c
+}
+
+// AllChars returns the indexes
+// of n characters.
+func allChars(n int) []int {
+	all := make([]int, n)
+	for i := range all {
+		all[i] = i
+	}
+	return all
+}
+
+// Outside returns the names of the terminals
+// of the matrix
+// that are not in the given list,
+// for example,
+// the terminals absent from a tree.
+// The names are sorted.
+func (m *Matrix) Outside(names []string) []string {
+	in := make(map[string]bool, len(names))
+	for _, nm := range names {
+		in[nm] = true
+	}
+	var out []string
+	for _, t := range m.Taxa() {
+		if !in[t.Name] {
+			out = append(out, t.Name)
+		}
+	}
+	return out
+}
+
+// Empty returns the names of the terminals
+// without known characters.
+func (m *Matrix) Empty() []string {
+	var empty []string
+	for _, t := range m.Taxa() {
+		if m.Known(t) == 0 {
+			empty = append(empty, t.Name)
+		}
+	}
+	return empty
+}
+
+// EmptyBlocks returns the index of the blocks
+// without known characters in all terminals.
+func (m *Matrix) EmptyBlocks() []int {
+	var empty []int
+	for i, b := range m.Blocks {
+		covered := false
+		for _, t := range m.Names {
+			if m.Covered(t, b) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			empty = append(empty, i)
+		}
+	}
+	return empty
+}
+
+// DropBlocks returns a new matrix
+// without the characters of the indicated blocks.
+// As characters are removed,
+// terminals are not shared with the original matrix.
+func (m *Matrix) DropBlocks(blocks []int) *Matrix {
+	del := make(map[int]bool, len(blocks))
+	for _, b := range blocks {
+		del[b] = true
+	}
+	c := &Matrix{Names: make(map[string]*Terminal, len(m.Names))}
+	var keep []int
+	for i, b := range m.Blocks {
+		if del[i] {
+			continue
+		}
+		nb := b
+		nb.Start = len(c.Kind)
+		for j := b.Start; j < b.End; j++ {
+			c.Kind = append(c.Kind, m.Kind[j])
+			keep = append(keep, j)
+		}
+		nb.End = len(c.Kind)
+		c.Blocks = append(c.Blocks, nb)
+	}
+	for nm, t := range m.Names {
+		nt := &Terminal{
+			Name:  nm,
+			Chars: make([]uint32, len(keep)),
+		}
+		for i, j := range keep {
+			nt.Chars[i] = t.State(j)
+		}
+		nt.gaps = t.selectGaps(keep)
+		c.Names[nm] = nt
+		if t == m.Out {
+			c.Out = nt
+		}
+	}
+	c.weights = m.selectWeights(keep)
+	c.excluded = m.selectExcluded(keep)
+	c.steps = m.selectSteps(keep)
+	c.gapMode = m.gapMode
+	c.counts = m.selectCounts(keep)
+	return c
+}
+
+// DropChars returns a new matrix
+// without the indicated characters.
+// Blocks without characters are removed.
+// As characters are removed,
+// terminals are not shared with the original matrix.
+func (m *Matrix) DropChars(chars []int) *Matrix {
+	del := make(map[int]bool, len(chars))
+	for _, c := range chars {
+		del[c] = true
+	}
+	c := &Matrix{Names: make(map[string]*Terminal, len(m.Names))}
+	var keep []int
+	for _, b := range m.Blocks {
+		nb := b
+		nb.Start = len(c.Kind)
+		for j := b.Start; j < b.End; j++ {
+			if del[j] {
+				continue
+			}
+			c.Kind = append(c.Kind, m.Kind[j])
+			keep = append(keep, j)
+		}
+		nb.End = len(c.Kind)
+		if nb.Len() > 0 {
+			c.Blocks = append(c.Blocks, nb)
+		}
+	}
+	for nm, t := range m.Names {
+		nt := &Terminal{
+			Name:  nm,
+			Chars: make([]uint32, len(keep)),
+		}
+		for i, j := range keep {
+			nt.Chars[i] = t.State(j)
+		}
+		nt.gaps = t.selectGaps(keep)
+		c.Names[nm] = nt
+		if t == m.Out {
+			c.Out = nt
+		}
+	}
+	c.weights = m.selectWeights(keep)
+	c.excluded = m.selectExcluded(keep)
+	c.steps = m.selectSteps(keep)
+	c.gapMode = m.gapMode
+	c.counts = m.selectCounts(keep)
+	return c
+}
+
+// Resample returns a new matrix
+// with the indicated characters,
+// in which a character can be repeated,
+// for example,
+// to build a bootstrap pseudoreplicate.
+// Characters are sorted,
+// so repeated characters are contiguous,
+// and blocks are preserved.
+//
+// The states are taken from the column views
+// of the matrix,
+// and all the terminals of the new matrix
+// share a single backing array,
+// so the data of the original terminals
+// is not copied nor unpacked.
+func (m *Matrix) Resample(chars []int) *Matrix {
+	sel := append([]int{}, chars...)
+	sort.Ints(sel)
+
+	c := &Matrix{Names: make(map[string]*Terminal, len(m.Names))}
+	j := 0
+	for _, b := range m.Blocks {
+		nb := b
+		nb.Start = len(c.Kind)
+		for ; j < len(sel) && sel[j] < b.End; j++ {
+			c.Kind = append(c.Kind, m.Kind[sel[j]])
+		}
+		nb.End = len(c.Kind)
+		if nb.Len() > 0 {
+			c.Blocks = append(c.Blocks, nb)
+		}
+	}
+
+	taxa := m.Taxa()
+	n := len(sel)
+	data := make([]uint32, n*len(taxa))
+	rows := make([]*Terminal, len(taxa))
+	for i, t := range taxa {
+		nt := &Terminal{
+			Name:  t.Name,
+			Chars: data[i*n : (i+1)*n : (i+1)*n],
+		}
+		rows[i] = nt
+		c.Names[t.Name] = nt
+		if t == m.Out {
+			c.Out = nt
+		}
+	}
+	for k, ch := range sel {
+		for i, s := range m.Column(ch) {
+			rows[i].Chars[k] = s
+		}
+	}
+	c.weights = m.selectWeights(sel)
+	c.excluded = m.selectExcluded(sel)
+	c.steps = m.selectSteps(sel)
+	c.gapMode = m.gapMode
+	c.counts = m.selectCounts(sel)
+	return c
+}
+
+// Permute returns a new matrix
+// in which the states of each character
+// are randomly permuted among the terminals,
+// for example,
+// to build a matrix without phylogenetic structure
+// for a permutation test.
+// The number of terminals with each state
+// is preserved in each character.
+//
+// As in Resample,
+// all the terminals of the new matrix
+// share a single backing array.
+func (m *Matrix) Permute() *Matrix {
+	all := allChars(len(m.Kind))
+	c := &Matrix{
+		Names:    make(map[string]*Terminal, len(m.Names)),
+		Kind:     m.Kind,
+		Blocks:   m.Blocks,
+		weights:  m.selectWeights(all),
+		excluded: m.selectExcluded(all),
+		steps:    m.selectSteps(all),
+		gapMode:  m.gapMode,
+		counts:   m.selectCounts(all),
+	}
+
+	taxa := m.Taxa()
+	n := len(m.Kind)
+	data := make([]uint32, n*len(taxa))
+	rows := make([]*Terminal, len(taxa))
+	for i, t := range taxa {
+		nt := &Terminal{
+			Name:  t.Name,
+			Chars: data[i*n : (i+1)*n : (i+1)*n],
+		}
+		rows[i] = nt
+		c.Names[t.Name] = nt
+		if t == m.Out {
+			c.Out = nt
+		}
+	}
+	for ch := range m.Kind {
+		col := m.Column(ch)
+		for i, j := range rand.Perm(len(col)) {
+			rows[i].Chars[ch] = col[j]
+		}
+	}
+	return c
+}
+
+// Taxa returns the terminals of the matrix,
+// the outgroup is the first terminal,
+// and the other terminals are sorted by name.
+// The returned slice is shared,
+// so it should not be modified.
+func (m *Matrix) Taxa() []*Terminal {
+	m.colOnce.Do(m.transpose)
+	return m.taxa
+}
+
+// Column returns the states of a character
+// in all terminals,
+// in the same order as the terminals
+// returned by Taxa.
+// The returned slice is shared,
+// so it should not be modified.
+//
+// Column views are built the first time
+// they are requested,
+// so changes in the terminals made after that
+// are not reflected in the columns.
+func (m *Matrix) Column(char int) []uint32 {
+	m.colOnce.Do(m.transpose)
+	return m.cols[char]
+}
+
+// States returns the union of all the known states
+// of a character.
+func (m *Matrix) States(char int) uint32 {
+	u := m.unknown(char)
+	var st uint32
+	for _, c := range m.Column(char) {
+		if c == u {
+			continue
+		}
+		st |= c
+	}
+	return st
+}
+
+// Transpose builds the column views of the matrix.
+func (m *Matrix) transpose() {
+	names := make([]string, 0, len(m.Names))
+	for nm, t := range m.Names {
+		if t == m.Out {
+			continue
+		}
+		names = append(names, nm)
+	}
+	sort.Strings(names)
+	m.taxa = make([]*Terminal, 0, len(m.Names))
+	if m.Out != nil {
+		m.taxa = append(m.taxa, m.Out)
+	}
+	for _, nm := range names {
+		m.taxa = append(m.taxa, m.Names[nm])
+	}
+
+	// all columns share a single backing array
+	data := make([]uint32, len(m.Kind)*len(m.taxa))
+	m.cols = make([][]uint32, len(m.Kind))
+	for i := range m.cols {
+		c := data[i*len(m.taxa) : (i+1)*len(m.taxa)]
+		for j, t := range m.taxa {
+			c[j] = t.State(i)
+		}
+		m.cols[i] = c
+	}
+}
@@ -5,6 +5,7 @@
 package matrix
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 )
@@ -55,3 +56,258 @@ func TestNewMatrix(t *testing.T) {
 		}
 	}
 }
+
+var identBlob = `
+> morpho
+A 0011??
+B 0111?1
+C ??????
+`
+
+func TestIdentity(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(identBlob))
+	if err != nil {
+		t.Fatalf("matrix: identity: unexpected error while reading matrix: %v", err)
+	}
+	id, shared := m.Identity(m.Names["A"], m.Names["B"])
+	if shared != 4 {
+		t.Errorf("matrix: identity: %d shared characters, want %d", shared, 4)
+	}
+	if id != 0.75 {
+		t.Errorf("matrix: identity: identity %.3f, want %.3f", id, 0.75)
+	}
+	if _, shared := m.Identity(m.Names["A"], m.Names["C"]); shared != 0 {
+		t.Errorf("matrix: identity: %d shared characters, want %d", shared, 0)
+	}
+	if k := m.Known(m.Names["B"]); k != 5 {
+		t.Errorf("matrix: identity: %d known characters, want %d", k, 5)
+	}
+}
+
+var dupBlob = `
+> morpho
+Out 000000
+A   001111
+B   001111
+C   001110
+D   110000
+`
+
+func TestDuplicates(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(dupBlob))
+	if err != nil {
+		t.Fatalf("matrix: duplicates: unexpected error while reading matrix: %v", err)
+	}
+	g := m.Duplicates(0)
+	if len(g) != 1 {
+		t.Fatalf("matrix: duplicates: %d groups, want %d", len(g), 1)
+	}
+	if strings.Join(g[0], " ") != "A B" {
+		t.Errorf("matrix: duplicates: group %v, want %v", g[0], "A B")
+	}
+	g = m.Duplicates(1)
+	if len(g) != 1 || strings.Join(g[0], " ") != "A B C" {
+		t.Errorf("matrix: duplicates: groups %v, want %v", g, "[[A B C]]")
+	}
+
+	c := m.Collapse(g)
+	if len(c.Names) != 3 {
+		t.Errorf("matrix: duplicates: %d terminals, want %d", len(c.Names), 3)
+	}
+	if c.Names["B"] != nil {
+		t.Errorf("matrix: duplicates: terminal %s not collapsed", "B")
+	}
+	if c.Out != m.Out {
+		t.Errorf("matrix: duplicates: outgroup changed")
+	}
+}
+
+func TestColumn(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(dupBlob))
+	if err != nil {
+		t.Fatalf("matrix: column: unexpected error while reading matrix: %v", err)
+	}
+	taxa := m.Taxa()
+	if len(taxa) != 5 {
+		t.Fatalf("matrix: column: %d terminals, want %d", len(taxa), 5)
+	}
+	if taxa[0] != m.Out {
+		t.Errorf("matrix: column: first terminal %s, want %s", taxa[0].Name, m.Out.Name)
+	}
+	for i := range m.Kind {
+		c := m.Column(i)
+		for j, tx := range taxa {
+			if c[j] != tx.Chars[i] {
+				t.Errorf("matrix: column: char %d, terminal %s: state %d, want %d", i, tx.Name, c[j], tx.Chars[i])
+			}
+		}
+	}
+	if st := m.States(5); st != 3 {
+		t.Errorf("matrix: column: char %d: states %d, want %d", 5, st, 3)
+	}
+}
+
+var emptyBlob = `
+> morpho
+Out 0000
+A   0011
+B   ????
+> dna
+Out ????
+A   ????
+`
+
+func TestEmpty(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(emptyBlob))
+	if err != nil {
+		t.Fatalf("matrix: empty: unexpected error while reading matrix: %v", err)
+	}
+	e := m.Empty()
+	if len(e) != 1 || e[0] != "B" {
+		t.Errorf("matrix: empty: empty terminals %v, want %v", e, "[B]")
+	}
+	eb := m.EmptyBlocks()
+	if len(eb) != 1 || eb[0] != 1 {
+		t.Errorf("matrix: empty: empty blocks %v, want %v", eb, "[1]")
+	}
+	if n := m.Missing(0); n != 1 {
+		t.Errorf("matrix: empty: character 0: %d missing, want %d", n, 1)
+	}
+	if n := m.Missing(4); n != 3 {
+		t.Errorf("matrix: empty: character 4: %d missing, want %d", n, 3)
+	}
+
+	c := m.DropTaxa(e).DropBlocks(eb)
+	if len(c.Names) != 2 {
+		t.Errorf("matrix: empty: %d terminals, want %d", len(c.Names), 2)
+	}
+	if len(c.Kind) != 4 || len(c.Blocks) != 1 {
+		t.Errorf("matrix: empty: %d characters, %d blocks, want %d, %d", len(c.Kind), len(c.Blocks), 4, 1)
+	}
+	if c.Out.Name != "Out" {
+		t.Errorf("matrix: empty: outgroup %s, want %s", c.Out.Name, "Out")
+	}
+	if !c.IsValid() {
+		t.Errorf("matrix: empty: invalid matrix")
+	}
+
+	c = m.DropTaxa([]string{"Out"})
+	if c.Out.Name != "A" {
+		t.Errorf("matrix: empty: outgroup %s, want %s", c.Out.Name, "A")
+	}
+
+	if o := m.Outside([]string{"A", "Out", "X"}); !reflect.DeepEqual(o, []string{"B"}) {
+		t.Errorf("matrix: empty: outside %v, want %v", o, []string{"B"})
+	}
+}
+
+func TestDropChars(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(emptyBlob))
+	if err != nil {
+		t.Fatalf("matrix: dropchars: unexpected error while reading matrix: %v", err)
+	}
+	c := m.DropChars([]int{0, 2, 4, 5, 6, 7})
+	if len(c.Kind) != 2 || len(c.Blocks) != 1 {
+		t.Fatalf("matrix: dropchars: %d characters, %d blocks, want %d, %d", len(c.Kind), len(c.Blocks), 2, 1)
+	}
+	if !c.IsValid() {
+		t.Errorf("matrix: dropchars: invalid matrix")
+	}
+	a := c.Names["A"]
+	if a.State(0) != m.Names["A"].State(1) || a.State(1) != m.Names["A"].State(3) {
+		t.Errorf("matrix: dropchars: wrong states in terminal %s", a.Name)
+	}
+	if c.Out.Name != "Out" {
+		t.Errorf("matrix: dropchars: outgroup %s, want %s", c.Out.Name, "Out")
+	}
+}
+
+func TestPermute(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(emptyBlob))
+	if err != nil {
+		t.Fatalf("matrix: permute: unexpected error while reading matrix: %v", err)
+	}
+	c := m.Permute()
+	if !c.IsValid() {
+		t.Errorf("matrix: permute: invalid matrix")
+	}
+	if len(c.Names) != len(m.Names) || c.Out.Name != m.Out.Name {
+		t.Errorf("matrix: permute: terminals not preserved")
+	}
+	for ch := range m.Kind {
+		want := make(map[uint32]int)
+		for _, s := range m.Column(ch) {
+			want[s]++
+		}
+		got := make(map[uint32]int)
+		for _, s := range c.Column(ch) {
+			got[s]++
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("matrix: permute: character %d: states %v, want %v", ch+1, got, want)
+		}
+	}
+}
+
+func TestResample(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(emptyBlob))
+	if err != nil {
+		t.Fatalf("matrix: resample: unexpected error while reading matrix: %v", err)
+	}
+	c := m.Resample([]int{7, 1, 1, 3})
+	if len(c.Kind) != 4 {
+		t.Fatalf("matrix: resample: %d characters, want %d", len(c.Kind), 4)
+	}
+	if !c.IsValid() {
+		t.Errorf("matrix: resample: invalid matrix")
+	}
+	for _, nm := range []string{"Out", "A"} {
+		r, o := c.Names[nm], m.Names[nm]
+		for i, j := range []int{1, 1, 3, 7} {
+			if r.State(i) != o.State(j) {
+				t.Errorf("matrix: resample: terminal %s: character %d: state %d, want %d", nm, i, r.State(i), o.State(j))
+			}
+		}
+	}
+	bl := 0
+	for _, b := range c.Blocks {
+		bl += b.Len()
+		for i := b.Start; i < b.End; i++ {
+			if c.Kind[i] != b.Type {
+				t.Errorf("matrix: resample: character %d: kind %s, want %s", i, c.Kind[i], b.Type)
+			}
+		}
+	}
+	if bl != 4 {
+		t.Errorf("matrix: resample: %d characters in blocks, want %d", bl, 4)
+	}
+	if c.Out.Name != "Out" {
+		t.Errorf("matrix: resample: outgroup %s, want %s", c.Out.Name, "Out")
+	}
+}
+
+var infoBlob = `
+> morpho
+Out 00000?
+A   01100?
+B   00110?
+C   001(01)1?
+D   00022?
+`
+
+func TestInformative(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(infoBlob))
+	if err != nil {
+		t.Fatalf("matrix: informative: unexpected error while reading matrix: %v", err)
+	}
+	want := []bool{false, false, true, false, false, false}
+	for i, w := range want {
+		if m.Informative(i) != w {
+			t.Errorf("matrix: informative: character %d: got %v, want %v", i, m.Informative(i), w)
+		}
+	}
+	sum := m.Summary()
+	if sum.Constant != 2 || sum.Uninformative != 3 || sum.Informative != 1 {
+		t.Errorf("matrix: informative: summary %+v, want {2 3 1}", sum)
+	}
+}
@@ -0,0 +1,629 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// NexusMagic is the header of a NEXUS file.
+const nexusMagic = "#nexus"
+
+// IsNexus returns true if the reader
+// starts with a NEXUS header.
+func isNexus(r *bufio.Reader) bool {
+	h, err := r.Peek(len(nexusMagic))
+	if err != nil {
+		return false
+	}
+	return strings.ToLower(string(h)) == nexusMagic
+}
+
+// ReadNexus returns a new matrix
+// from a NEXUS file.
+//
+// Each DATA, or CHARACTERS block
+// of the file is read as a block of the matrix,
+// using its TITLE as the block name.
+// Valid datatypes are DNA
+// (also RNA and NUCLEOTIDE),
+// PROTEIN,
+// and STANDARD
+// (read as morphology).
+// The FORMAT command can define
+// the GAP, MISSING and MATCHCHAR symbols,
+// the SYMBOLS of a standard block,
+// and if the matrix is INTERLEAVEd.
+// Both gaps and missing data
+// are read as unknowns.
+// Polymorphisms are given between parenthesis,
+// and uncertainties between braces.
+//
+// Any other block is ignored.
+// The trees of a TREES block
+// (with its translation table)
+// are read with tree.Reader,
+// so a single NEXUS file
+// can be used for both the matrix and the trees.
+func ReadNexus(r io.Reader) (*Matrix, error) {
+	br := bufio.NewReader(r)
+	if !isNexus(br) {
+		return nil, errors.New("matrix: nexus: expecting '#NEXUS' header")
+	}
+	return readNexus(br)
+}
+
+// NexusFormat stores the format
+// of a NEXUS character block.
+type nexusFormat struct {
+	kind       DataType
+	nchar      int
+	ntax       int
+	interleave bool
+	gap        rune
+	missing    rune
+	match      rune
+	symbols    string
+}
+
+func readNexus(r *bufio.Reader) (*Matrix, error) {
+	if _, err := r.Discard(len(nexusMagic)); err != nil {
+		return nil, errors.Wrap(err, "matrix: nexus")
+	}
+
+	var taxa []*Taxon
+	block := 0
+	for {
+		tk, err := nexusToken(r)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "matrix: nexus")
+		}
+		if strings.ToLower(tk) != "begin" {
+			return nil, errors.Errorf("matrix: nexus: expecting 'begin', found %q", tk)
+		}
+		name, err := nexusToken(r)
+		if err != nil {
+			return nil, errors.Wrap(err, "matrix: nexus: while reading block name")
+		}
+		if err := nexusEnd(r); err != nil {
+			return nil, errors.Wrapf(err, "matrix: nexus: block %s", name)
+		}
+		switch strings.ToLower(name) {
+		case "data", "characters":
+			block++
+			txs, err := readNexusChars(r, block)
+			if err != nil {
+				return nil, errors.Wrapf(err, "matrix: nexus: block %d", block)
+			}
+			taxa = append(taxa, txs...)
+		default:
+			if err := skipNexusBlock(r); err != nil {
+				return nil, errors.Wrapf(err, "matrix: nexus: block %s", name)
+			}
+		}
+	}
+	if len(taxa) == 0 {
+		return nil, errors.New("matrix: nexus: no character data")
+	}
+	return fromTaxa(taxa)
+}
+
+// ReadNexusChars reads the commands
+// of a DATA, or CHARACTERS block.
+func readNexusChars(r *bufio.Reader, block int) ([]*Taxon, error) {
+	f := nexusFormat{
+		kind:    Morphology,
+		gap:     '-',
+		missing: '?',
+		symbols: "01234567",
+	}
+	var title string
+	var taxa []*Taxon
+	for {
+		cmd, err := nexusToken(r)
+		if err != nil {
+			return nil, err
+		}
+		switch strings.ToLower(cmd) {
+		case "end", "endblock":
+			if err := nexusEnd(r); err != nil {
+				return nil, err
+			}
+			if taxa == nil {
+				return nil, errors.New("matrix not found")
+			}
+			return taxa, nil
+		case "title":
+			title, err = nexusToken(r)
+			if err != nil {
+				return nil, errors.Wrap(err, "title")
+			}
+			if err := nexusEnd(r); err != nil {
+				return nil, errors.Wrap(err, "title")
+			}
+		case "dimensions":
+			if err := readNexusDimensions(r, &f); err != nil {
+				return nil, errors.Wrap(err, "dimensions")
+			}
+		case "format":
+			if err := readNexusFormat(r, &f); err != nil {
+				return nil, errors.Wrap(err, "format")
+			}
+		case "matrix":
+			if f.nchar == 0 {
+				return nil, errors.New("matrix: undefined number of characters")
+			}
+			s, err := nexusMatrixText(r)
+			if err != nil {
+				return nil, errors.Wrap(err, "matrix")
+			}
+			taxa, err = f.parse(s)
+			if err != nil {
+				return nil, errors.Wrap(err, "matrix")
+			}
+			for _, tx := range taxa {
+				tx.Block = block
+				tx.BlockName = title
+			}
+		default:
+			if err := skipNexusCommand(r); err != nil {
+				return nil, errors.Wrap(err, cmd)
+			}
+		}
+	}
+}
+
+// ReadNexusDimensions reads
+// the number of taxa and characters
+// of a DIMENSIONS command.
+func readNexusDimensions(r *bufio.Reader, f *nexusFormat) error {
+	for {
+		key, val, err := nexusOption(r)
+		if err != nil {
+			return err
+		}
+		if key == ";" {
+			return nil
+		}
+		switch key {
+		case "nchar", "ntax":
+			n, err := strconv.Atoi(val)
+			if err != nil || n <= 0 {
+				return errors.Errorf("invalid value %q for %s", val, key)
+			}
+			if key == "nchar" {
+				f.nchar = n
+			} else {
+				f.ntax = n
+			}
+		}
+	}
+}
+
+// ReadNexusFormat reads
+// the options of a FORMAT command.
+func readNexusFormat(r *bufio.Reader, f *nexusFormat) error {
+	for {
+		key, val, err := nexusOption(r)
+		if err != nil {
+			return err
+		}
+		if key == ";" {
+			return nil
+		}
+		switch key {
+		case "datatype":
+			switch strings.ToLower(val) {
+			case "dna", "rna", "nucleotide":
+				f.kind = DNA
+			case "protein":
+				f.kind = Protein
+			case "standard":
+				f.kind = Morphology
+			default:
+				return errors.Errorf("unsupported datatype %q", val)
+			}
+		case "interleave":
+			f.interleave = val == "" || strings.ToLower(val) == "yes"
+		case "gap", "missing", "matchchar":
+			rs := []rune(val)
+			if len(rs) != 1 {
+				return errors.Errorf("invalid symbol %q for %s", val, key)
+			}
+			switch key {
+			case "gap":
+				f.gap = rs[0]
+			case "missing":
+				f.missing = rs[0]
+			default:
+				f.match = rs[0]
+			}
+		case "symbols":
+			s := strings.Join(strings.Fields(val), "")
+			if len(s) > 8 {
+				return errors.Errorf("too many symbols %q: max 8", val)
+			}
+			f.symbols = s
+		}
+	}
+}
+
+// Parse parses the content of a MATRIX command.
+func (f *nexusFormat) parse(s string) ([]*Taxon, error) {
+	var taxa []*Taxon
+	tm := make(map[string]*Taxon)
+	lines := []string{s}
+	if f.interleave {
+		lines = strings.Split(s, "\n")
+	}
+	for _, ln := range lines {
+		p := &nexusLine{s: ln}
+		for {
+			p.skipSpaces()
+			if p.done() {
+				break
+			}
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			tx := tm[name]
+			if tx != nil && !f.interleave {
+				return nil, errors.Errorf("taxon %s repeated", name)
+			}
+			if tx == nil {
+				tx = &Taxon{Name: name, Type: f.kind}
+				tm[name] = tx
+				taxa = append(taxa, tx)
+			}
+			for {
+				if !f.interleave && len(tx.Chars) == f.nchar {
+					break
+				}
+				p.skipSpaces()
+				if p.done() {
+					break
+				}
+				st, err := f.state(p, taxa[0], len(tx.Chars))
+				if err != nil {
+					return nil, errors.Wrapf(err, "taxon %s: char %d", name, len(tx.Chars)+1)
+				}
+				tx.Chars = append(tx.Chars, st)
+			}
+		}
+	}
+	for _, tx := range taxa {
+		if len(tx.Chars) != f.nchar {
+			return nil, errors.Errorf("taxon %s with wrong number of chars: %d, want %d", tx.Name, len(tx.Chars), f.nchar)
+		}
+	}
+	if f.ntax > 0 && len(taxa) != f.ntax {
+		return nil, errors.Errorf("wrong number of taxa: %d, want %d", len(taxa), f.ntax)
+	}
+	return taxa, nil
+}
+
+// State reads the state of a character.
+func (f *nexusFormat) state(p *nexusLine, first *Taxon, char int) (uint32, error) {
+	r1 := p.next()
+	var end rune
+	switch r1 {
+	case '(':
+		end = ')'
+	case '{':
+		end = '}'
+	default:
+		if f.match != 0 && r1 == f.match {
+			if char >= len(first.Chars) {
+				return 0, errors.Errorf("matchchar %q without a reference state", r1)
+			}
+			return first.Chars[char], nil
+		}
+		return f.symbol(r1)
+	}
+	var st uint32
+	for {
+		r1 := p.next()
+		if r1 == 0 {
+			return 0, errors.New("unfinished polymorphism")
+		}
+		if r1 == end {
+			break
+		}
+		if unicode.IsSpace(r1) || r1 == ',' {
+			continue
+		}
+		s, err := f.symbol(r1)
+		if err != nil {
+			return 0, err
+		}
+		st |= s
+	}
+	if st == 0 {
+		return 0, errors.New("empty polymorphism")
+	}
+	return st, nil
+}
+
+// Symbol returns the state of a symbol.
+func (f *nexusFormat) symbol(r1 rune) (uint32, error) {
+	if r1 == f.gap && f.kind == DNA {
+		return Gap, nil
+	}
+	if r1 == f.gap || r1 == f.missing {
+		return Unknown(f.kind), nil
+	}
+	if f.kind == DNA || f.kind == Protein {
+		return readStates(bufio.NewReader(strings.NewReader(string(r1))), f.kind)
+	}
+	i := strings.IndexRune(f.symbols, r1)
+	if i < 0 {
+		return 0, errors.Errorf("unknown symbol %q", r1)
+	}
+	return 1 << uint(i), nil
+}
+
+// A NexusLine is a cursor over the text
+// of a MATRIX command.
+type nexusLine struct {
+	s   string
+	pos int
+}
+
+func (p *nexusLine) done() bool {
+	return p.pos >= len(p.s)
+}
+
+func (p *nexusLine) next() rune {
+	if p.done() {
+		return 0
+	}
+	r1 := rune(p.s[p.pos])
+	p.pos++
+	return r1
+}
+
+func (p *nexusLine) skipSpaces() {
+	for !p.done() && unicode.IsSpace(rune(p.s[p.pos])) {
+		p.pos++
+	}
+}
+
+// Name reads a taxon name,
+// either quoted or as a single word.
+func (p *nexusLine) name() (string, error) {
+	if p.s[p.pos] != '\'' {
+		start := p.pos
+		for !p.done() && !unicode.IsSpace(rune(p.s[p.pos])) {
+			p.pos++
+		}
+		return p.s[start:p.pos], nil
+	}
+	p.pos++
+	var b strings.Builder
+	for {
+		r1 := p.next()
+		if r1 == 0 {
+			return "", errors.New("unfinished quoted name")
+		}
+		if r1 == '\'' {
+			if !p.done() && p.s[p.pos] == '\'' {
+				p.pos++
+			} else {
+				break
+			}
+		}
+		b.WriteRune(r1)
+	}
+	return b.String(), nil
+}
+
+// NexusMatrixText returns the text of a MATRIX command
+// without comments,
+// and consumes the ending semicolon.
+func nexusMatrixText(r *bufio.Reader) (string, error) {
+	var b strings.Builder
+	quoted := false
+	for {
+		r1, _, err := r.ReadRune()
+		if err != nil {
+			return "", errors.Wrap(err, "unfinished matrix")
+		}
+		if r1 == '\'' {
+			quoted = !quoted
+		}
+		if quoted {
+			b.WriteRune(r1)
+			continue
+		}
+		if r1 == ';' {
+			return b.String(), nil
+		}
+		if r1 == '[' {
+			if err := skipNexusComment(r); err != nil {
+				return "", err
+			}
+			continue
+		}
+		b.WriteRune(r1)
+	}
+}
+
+// NexusOption reads a key of a command,
+// and its value,
+// if it has one.
+// At the end of the command
+// it returns ";" as the key.
+func nexusOption(r *bufio.Reader) (key, val string, err error) {
+	key, err = nexusToken(r)
+	if err != nil {
+		return "", "", err
+	}
+	key = strings.ToLower(key)
+	if key == ";" {
+		return key, "", nil
+	}
+	if nexusPeek(r) != '=' {
+		return key, "", nil
+	}
+	r.ReadRune()
+	val, err = nexusToken(r)
+	if err != nil {
+		return "", "", errors.Wrap(err, key)
+	}
+	return key, val, nil
+}
+
+// NexusToken reads a token:
+// a word,
+// a quoted string,
+// or one of the punctuation symbols ';' and '='.
+func nexusToken(r *bufio.Reader) (string, error) {
+	r1 := nexusPeek(r)
+	if r1 == 0 {
+		_, _, err := r.ReadRune()
+		if err == nil {
+			err = errors.New("unexpected error")
+		}
+		return "", err
+	}
+	r.ReadRune()
+	if r1 == ';' || r1 == '=' {
+		return string(r1), nil
+	}
+	if r1 == '\'' || r1 == '"' {
+		var b strings.Builder
+		for {
+			c, _, err := r.ReadRune()
+			if err != nil {
+				return "", errors.Wrap(err, "unfinished quoted token")
+			}
+			if c == r1 {
+				if n, _, err := r.ReadRune(); err == nil {
+					if n == r1 {
+						b.WriteRune(c)
+						continue
+					}
+					r.UnreadRune()
+				}
+				return b.String(), nil
+			}
+			b.WriteRune(c)
+		}
+	}
+	var b strings.Builder
+	b.WriteRune(r1)
+	for {
+		c, _, err := r.ReadRune()
+		if err != nil {
+			return b.String(), nil
+		}
+		if unicode.IsSpace(c) || c == ';' || c == '=' || c == '[' {
+			r.UnreadRune()
+			return b.String(), nil
+		}
+		b.WriteRune(c)
+	}
+}
+
+// NexusPeek skips spaces and comments,
+// and returns the next rune,
+// without reading it.
+// It returns 0 on error.
+func nexusPeek(r *bufio.Reader) rune {
+	for {
+		if err := skipSpaces(r); err != nil {
+			return 0
+		}
+		r1, _, err := r.ReadRune()
+		if err != nil {
+			return 0
+		}
+		if r1 == '[' {
+			if err := skipNexusComment(r); err != nil {
+				return 0
+			}
+			continue
+		}
+		r.UnreadRune()
+		return r1
+	}
+}
+
+// SkipNexusComment skips a comment,
+// that might include nested comments.
+// The opening bracket is already read.
+func skipNexusComment(r *bufio.Reader) error {
+	depth := 1
+	for depth > 0 {
+		r1, _, err := r.ReadRune()
+		if err != nil {
+			return errors.Wrap(err, "unfinished comment")
+		}
+		switch r1 {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		}
+	}
+	return nil
+}
+
+// NexusEnd reads the semicolon
+// at the end of a command.
+func nexusEnd(r *bufio.Reader) error {
+	tk, err := nexusToken(r)
+	if err != nil {
+		return err
+	}
+	if tk != ";" {
+		return errors.Errorf("expecting ';', found %q", tk)
+	}
+	return nil
+}
+
+// SkipNexusCommand skips the tokens
+// up to the end of a command.
+func skipNexusCommand(r *bufio.Reader) error {
+	for {
+		tk, err := nexusToken(r)
+		if err != nil {
+			return err
+		}
+		if tk == ";" {
+			return nil
+		}
+	}
+}
+
+// SkipNexusBlock skips the commands
+// up to the end of a block.
+func skipNexusBlock(r *bufio.Reader) error {
+	for {
+		cmd, err := nexusToken(r)
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(cmd) {
+		case "end", "endblock":
+			return nexusEnd(r)
+		case ";":
+			continue
+		}
+		if err := skipNexusCommand(r); err != nil {
+			return err
+		}
+	}
+}
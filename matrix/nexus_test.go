@@ -0,0 +1,149 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var nexusBlob = `#NEXUS
+[a comment [nested] before the blocks]
+begin taxa;
+	dimensions ntax=4;
+	taxlabels Out A B 'C d';
+end;
+
+begin data;
+	title genes;
+	dimensions ntax=4 nchar=6;
+	format datatype=dna gap=- missing=? matchchar=. interleave;
+	matrix
+	Out    ACG
+	A      .T-
+	B      RC? [a comment]
+	'C d'  {AG}CN
+
+	Out    TTA
+	A      ...
+	B      T-A
+	'C d'  TTC
+	;
+end;
+
+begin characters;
+	dimensions nchar=3;
+	format datatype=standard symbols="0 1 2" missing=N;
+	matrix
+	Out 000
+	A   1(12)N
+	B   2{01}1
+	;
+end;
+
+begin trees;
+	translate
+		1 Out,
+		2 A,
+		3 B,
+		4 'C d';
+	tree one = ((1,2),(3,4));
+end;
+`
+
+func TestNexus(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(nexusBlob))
+	if err != nil {
+		t.Fatalf("matrix: nexus: unexpected error: %v", err)
+	}
+	if m.Out.Name != "Out" {
+		t.Errorf("matrix: nexus: outgroup %s, want Out", m.Out.Name)
+	}
+	if len(m.Blocks) != 2 {
+		t.Fatalf("matrix: nexus: %d blocks, want 2", len(m.Blocks))
+	}
+	if m.Blocks[0].Name != "genes" || m.Blocks[0].Type != DNA || m.Blocks[0].Len() != 6 {
+		t.Errorf("matrix: nexus: block 0: %v, want genes, dna, 6 chars", m.Blocks[0])
+	}
+	if m.Blocks[1].Name != "block2" || m.Blocks[1].Type != Morphology || m.Blocks[1].Len() != 3 {
+		t.Errorf("matrix: nexus: block 1: %v, want block2, morphology, 3 chars", m.Blocks[1])
+	}
+
+	want := map[string][]uint32{
+		"Out": {1, 2, 4, 8, 8, 1, 1, 1, 1},
+		"A":   {1, 8, 15, 8, 8, 1, 2, 6, 255},
+		"B":   {5, 2, 15, 8, 15, 1, 4, 3, 2},
+		"C d": {5, 2, 15, 8, 8, 2, 255, 255, 255},
+	}
+	if len(m.Names) != len(want) {
+		t.Errorf("matrix: nexus: %d terminals, want %d", len(m.Names), len(want))
+	}
+	for nm, w := range want {
+		tx, ok := m.Names[nm]
+		if !ok {
+			t.Errorf("matrix: nexus: terminal %s not found", nm)
+			continue
+		}
+		if !reflect.DeepEqual(tx.Chars, w) {
+			t.Errorf("matrix: nexus: terminal %s: chars %v, want %v", nm, tx.Chars, w)
+		}
+	}
+}
+
+func TestNexusErrors(t *testing.T) {
+	bad := map[string]string{
+		"wrong chars": `#NEXUS
+begin data;
+	dimensions ntax=2 nchar=3;
+	format datatype=dna;
+	matrix
+	A ACG
+	B AC
+	;
+end;
+`,
+		"wrong taxa": `#NEXUS
+begin data;
+	dimensions ntax=3 nchar=2;
+	format datatype=dna;
+	matrix
+	A AC
+	B AC
+	;
+end;
+`,
+		"unknown symbol": `#NEXUS
+begin data;
+	dimensions nchar=2;
+	format datatype=standard symbols="01";
+	matrix
+	A 01
+	B 02
+	;
+end;
+`,
+		"datatype": `#NEXUS
+begin data;
+	dimensions nchar=2;
+	format datatype=restriction;
+	matrix
+	A MK
+	B MK
+	;
+end;
+`,
+		"no data": `#NEXUS
+begin trees;
+	tree one = (A,(B,C));
+end;
+`,
+	}
+	for n, b := range bad {
+		if _, err := NewMatrix(strings.NewReader(b)); err == nil {
+			t.Errorf("matrix: nexus: %s: expecting error", n)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import "github.com/pkg/errors"
+
+// Len returns the number of characters
+// of a terminal.
+func (t *Terminal) Len() int {
+	if t.packed != nil {
+		return t.size
+	}
+	return len(t.Chars)
+}
+
+// State returns the state of a character
+// in a terminal.
+func (t *Terminal) State(char int) uint32 {
+	if t.packed == nil {
+		return t.Chars[char]
+	}
+	v := uint32(t.packed[char/2])
+	if char%2 == 1 {
+		return v >> 4
+	}
+	return v & 0x0f
+}
+
+// IsPacked returns true if the characters
+// of the terminal are stored as packed data.
+func (t *Terminal) IsPacked() bool {
+	return t.packed != nil
+}
+
+// Unpack returns the states of all the characters
+// of the terminal.
+// If the terminal is not packed,
+// it returns the Chars field of the terminal,
+// otherwise it returns a new slice
+// with the unpacked states.
+func (t *Terminal) Unpack() []uint32 {
+	if t.packed == nil {
+		return t.Chars
+	}
+	c := make([]uint32, t.size)
+	for i := range c {
+		c[i] = t.State(i)
+	}
+	return c
+}
+
+// Pack stores the characters of the terminal
+// using 4 bits per character,
+// i.e. two characters per byte.
+// After packing,
+// the Chars field of the terminal is set to nil,
+// and states should be accessed with
+// the State or Unpack methods.
+func (t *Terminal) pack() {
+	if t.packed != nil {
+		return
+	}
+	t.size = len(t.Chars)
+	t.packed = make([]uint8, (t.size+1)/2)
+	for i, c := range t.Chars {
+		if i%2 == 1 {
+			t.packed[i/2] |= uint8(c) << 4
+			continue
+		}
+		t.packed[i/2] = uint8(c)
+	}
+	t.Chars = nil
+}
+
+// Pack stores the characters of all terminals
+// using 4 bits per character,
+// i.e. an eighth of the memory used by the matrix.
+// As DNA states are stored as a bit field of 4 bits,
+// only matrices with DNA characters can be packed,
+// and gaps can not be treated as a state
+// (see SetGapMode).
+//
+// Pack should be called before any column view
+// of the matrix is requested.
+func (m *Matrix) Pack() error {
+	if m.gapMode == GapState {
+		return errors.New("matrix: pack: gaps treated as a state")
+	}
+	for i, k := range m.Kind {
+		if k != DNA {
+			return errors.Errorf("matrix: pack: character %d is %s, want %s", i+1, k, DNA)
+		}
+	}
+	for _, t := range m.Names {
+		if t.packed != nil {
+			continue
+		}
+		for i, c := range t.Chars {
+			if c&^0x0f != 0 {
+				return errors.Errorf("matrix: pack: terminal %s: character %d: invalid state %d", t.Name, i+1, c)
+			}
+		}
+	}
+	for _, t := range m.Names {
+		t.pack()
+	}
+	return nil
+}
+
+// CanPack returns true
+// if the matrix can be packed
+// (see Pack).
+func (m *Matrix) CanPack() bool {
+	if m.gapMode == GapState {
+		return false
+	}
+	for _, k := range m.Kind {
+		if k != DNA {
+			return false
+		}
+	}
+	for _, t := range m.Names {
+		if t.packed != nil {
+			continue
+		}
+		for _, c := range t.Chars {
+			if c&^0x0f != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPack(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("matrix: pack: unexpected error while reading matrix: %v", err)
+	}
+	data := make(map[string][]uint32, len(m.Names))
+	for nm, tx := range m.Names {
+		data[nm] = append([]uint32{}, tx.Chars...)
+	}
+	if err := m.Pack(); err != nil {
+		t.Fatalf("matrix: pack: unexpected error: %v", err)
+	}
+	if !m.IsValid() {
+		t.Errorf("matrix: pack: invalid matrix after packing")
+	}
+	for nm, tx := range m.Names {
+		if !tx.IsPacked() {
+			t.Errorf("matrix: pack: terminal %s not packed", nm)
+		}
+		if tx.Len() != len(data[nm]) {
+			t.Errorf("matrix: pack: terminal %s: %d characters, want %d", nm, tx.Len(), len(data[nm]))
+		}
+		for i, c := range tx.Unpack() {
+			if c != data[nm][i] {
+				t.Errorf("matrix: pack: terminal %s: char %d: state %d, want %d", nm, i, c, data[nm][i])
+			}
+		}
+	}
+
+	m, err = NewMatrix(strings.NewReader(dnaBlob + "\n" + morphoBlob))
+	if err != nil {
+		t.Fatalf("matrix: pack: unexpected error while reading matrix: %v", err)
+	}
+	if m.CanPack() {
+		t.Errorf("matrix: pack: morphological data can be packed")
+	}
+	if err := m.Pack(); err == nil {
+		t.Errorf("matrix: pack: expecting error on morphological data")
+	}
+
+	// gaps as a state
+	// do not fit in 4 bits
+	m, err = NewMatrix(strings.NewReader("> dna\nOut ACGT\nA AC-T\nB A--T\n"))
+	if err != nil {
+		t.Fatalf("matrix: pack: unexpected error while reading matrix: %v", err)
+	}
+	if !m.CanPack() {
+		t.Errorf("matrix: pack: DNA with gaps as missing data can not be packed")
+	}
+	if err := m.SetGapMode(GapState); err != nil {
+		t.Fatalf("matrix: pack: unexpected error: %v", err)
+	}
+	if m.CanPack() {
+		t.Errorf("matrix: pack: gaps as a state can be packed")
+	}
+	if err := m.Pack(); err == nil {
+		t.Errorf("matrix: pack: expecting error on gaps as a state")
+	}
+	for nm, tx := range m.Names {
+		if tx.IsPacked() {
+			t.Errorf("matrix: pack: terminal %s packed after an error", nm)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A Partition is a named set of characters,
+// for example a gene,
+// or the codon positions of a gene.
+type Partition struct {
+	Name  string
+	Chars []int // sorted list of characters (starting at 0)
+}
+
+// ReadPartitions reads a list of partitions
+// from a reader.
+//
+// Each partition is defined in a single line,
+// with the name of the partition,
+// an equal sign,
+// and the list of characters of the partition,
+// as defined in ParseRange,
+// for example:
+//
+//	coi_pos1 = 1-660\3
+//
+// Lines starting with '#' are ignored.
+// A character can be assigned
+// to a single partition.
+// Max is the number of characters in the matrix.
+func ReadPartitions(r io.Reader, max int) ([]Partition, error) {
+	var ps []Partition
+	names := make(map[string]bool)
+	used := make(map[int]string)
+	s := bufio.NewScanner(r)
+	ln := 0
+	for s.Scan() {
+		ln++
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return nil, errors.Errorf("matrix: partition: line %d: expecting '='", ln)
+		}
+		name := strings.TrimSpace(line[:i])
+		if name == "" {
+			return nil, errors.Errorf("matrix: partition: line %d: empty partition name", ln)
+		}
+		if names[name] {
+			return nil, errors.Errorf("matrix: partition: line %d: partition %s repeated", ln, name)
+		}
+		chars, err := ParseRange(line[i+1:], max)
+		if err != nil {
+			return nil, errors.Wrapf(err, "matrix: partition: line %d", ln)
+		}
+		if len(chars) == 0 {
+			return nil, errors.Errorf("matrix: partition: line %d: partition %s without characters", ln, name)
+		}
+		for _, c := range chars {
+			if p, ok := used[c]; ok {
+				return nil, errors.Errorf("matrix: partition: line %d: character %d already in partition %s", ln, c+1, p)
+			}
+			used[c] = name
+		}
+		names[name] = true
+		ps = append(ps, Partition{Name: name, Chars: chars})
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrap(err, "matrix: partition")
+	}
+	return ps, nil
+}
+
+// ReadPartitionFile reads a list of partitions
+// from a file,
+// as defined in ReadPartitions.
+func ReadPartitionFile(name string, max int) ([]Partition, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadPartitions(f, max)
+}
+
+// WritePartitions writes a list of partitions
+// into a writer,
+// in the format used by ReadPartitions.
+func WritePartitions(w io.Writer, ps []Partition) error {
+	for _, p := range ps {
+		if _, err := fmt.Fprintf(w, "%s = %s\n", p.Name, FormatRange(p.Chars)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BlockPartitions returns the blocks of the matrix
+// as partitions.
+func (m *Matrix) BlockPartitions() []Partition {
+	ps := make([]Partition, 0, len(m.Blocks))
+	for _, b := range m.Blocks {
+		p := Partition{Name: b.Name}
+		for i := b.Start; i < b.End; i++ {
+			p.Chars = append(p.Chars, i)
+		}
+		ps = append(ps, p)
+	}
+	return ps
+}
+
+// CodonPartitions returns a partition scheme
+// in which each gene with DNA data
+// is split by codon positions.
+// Genes must start at the first codon position.
+// Genes with other data types are kept
+// as a single partition.
+// Characters of the matrix
+// not assigned to any gene
+// are added into a partition named "unassigned".
+func (m *Matrix) CodonPartitions(genes []Partition) []Partition {
+	var ps []Partition
+	used := make([]bool, len(m.Kind))
+	for _, g := range genes {
+		for _, c := range g.Chars {
+			used[c] = true
+		}
+		dna := true
+		for _, c := range g.Chars {
+			if m.Kind[c] != DNA {
+				dna = false
+				break
+			}
+		}
+		if !dna {
+			ps = append(ps, Partition{Name: g.Name, Chars: append([]int{}, g.Chars...)})
+			continue
+		}
+		var cp [3]Partition
+		for i, c := range g.Chars {
+			cp[i%3].Chars = append(cp[i%3].Chars, c)
+		}
+		for i, p := range cp {
+			if len(p.Chars) == 0 {
+				continue
+			}
+			p.Name = fmt.Sprintf("%s_pos%d", g.Name, i+1)
+			ps = append(ps, p)
+		}
+	}
+
+	var rest []int
+	for c, u := range used {
+		if !u {
+			rest = append(rest, c)
+		}
+	}
+	if len(rest) > 0 {
+		ps = append(ps, Partition{Name: "unassigned", Chars: rest})
+	}
+	return ps
+}
+
+// PartitionMatrix returns a new matrix
+// with only the characters of a partition,
+// and without the terminals
+// without known characters in the partition
+// (e.g. the matrix of a gene
+// in a supermatrix).
+func (m *Matrix) PartitionMatrix(p Partition) *Matrix {
+	in := make(map[int]bool, len(p.Chars))
+	for _, c := range p.Chars {
+		in[c] = true
+	}
+	var del []int
+	for c := range m.Kind {
+		if !in[c] {
+			del = append(del, c)
+		}
+	}
+	pm := m.DropChars(del)
+	if empty := pm.Empty(); len(empty) > 0 {
+		pm = pm.DropTaxa(empty)
+	}
+	return pm
+}
@@ -0,0 +1,74 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCodonPartitions(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(coverBlob))
+	if err != nil {
+		t.Fatalf("matrix: partition: unexpected error while reading matrix: %v", err)
+	}
+	ps := m.CodonPartitions(m.BlockPartitions())
+	want := []Partition{
+		{"gene1_pos1", []int{0, 3}},
+		{"gene1_pos2", []int{1}},
+		{"gene1_pos3", []int{2}},
+		{"gene2_pos1", []int{4, 7}},
+		{"gene2_pos2", []int{5}},
+		{"gene2_pos3", []int{6}},
+		{"block3", []int{8, 9, 10, 11}},
+	}
+	if !reflect.DeepEqual(ps, want) {
+		t.Errorf("matrix: partition: codon partitions %v, want %v", ps, want)
+	}
+
+	var b bytes.Buffer
+	if err := WritePartitions(&b, ps); err != nil {
+		t.Fatalf("matrix: partition: unexpected error: %v", err)
+	}
+	rp, err := ReadPartitions(&b, len(m.Kind))
+	if err != nil {
+		t.Fatalf("matrix: partition: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(rp, want) {
+		t.Errorf("matrix: partition: read partitions %v, want %v", rp, want)
+	}
+
+	// genes without all characters
+	ps = m.CodonPartitions([]Partition{{"g", []int{0, 1, 2, 3, 4, 5}}})
+	if last := ps[len(ps)-1]; last.Name != "unassigned" || len(last.Chars) != 6 {
+		t.Errorf("matrix: partition: last partition %v, want 6 unassigned characters", last)
+	}
+
+	if _, err := ReadPartitions(strings.NewReader("a = 1-3\nb = 3-5\n"), 10); err == nil {
+		t.Errorf("matrix: partition: expecting error on overlapping partitions")
+	}
+}
+
+func TestPartitionMatrix(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(writeBlob))
+	if err != nil {
+		t.Fatalf("matrix: partition: unexpected error while reading matrix: %v", err)
+	}
+	pm := m.PartitionMatrix(Partition{Name: "coi", Chars: []int{4, 5, 6}})
+	if len(pm.Kind) != 3 {
+		t.Errorf("matrix: partition: %d characters, want 3", len(pm.Kind))
+	}
+	if len(pm.Names) != 2 {
+		t.Errorf("matrix: partition: %d terminals, want 2", len(pm.Names))
+	}
+	if _, ok := pm.Names["B"]; ok {
+		t.Errorf("matrix: partition: terminal B without data in partition")
+	}
+	if want := []uint32{1, 2, 15}; !reflect.DeepEqual(pm.Names["A"].Unpack(), want) {
+		t.Errorf("matrix: partition: terminal A: chars %v, want %v", pm.Names["A"].Unpack(), want)
+	}
+}
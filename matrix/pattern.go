@@ -0,0 +1,163 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import "sort"
+
+// A Pattern is a set of characters
+// of the same data type,
+// with identical states
+// in all terminals.
+type Pattern struct {
+	Chars []int // characters with the pattern
+}
+
+// Count returns the number of characters
+// with the pattern.
+func (p Pattern) Count() int {
+	return len(p.Chars)
+}
+
+// Patterns returns the site patterns of the matrix,
+// in the order of their first character.
+//
+// Patterns are calculated the first time
+// they are requested,
+// or read from a bundle file
+// (see WriteBundle).
+func (m *Matrix) Patterns() []Pattern {
+	if m.patterns != nil {
+		return m.patterns
+	}
+	idx := make(map[string]int)
+	for i, k := range m.Kind {
+		col := m.Column(i)
+		key := make([]byte, 0, 4*len(col)+1)
+		for _, c := range col {
+			key = append(key, byte(c), byte(c>>8), byte(c>>16), byte(c>>24))
+		}
+		key = append(key, byte(k))
+		j, ok := idx[string(key)]
+		if !ok {
+			j = len(m.patterns)
+			idx[string(key)] = j
+			m.patterns = append(m.patterns, Pattern{})
+		}
+		m.patterns[j].Chars = append(m.patterns[j].Chars, i)
+	}
+	return m.patterns
+}
+
+// Compress returns a new matrix
+// with a character for each site pattern
+// of the matrix,
+// so the analyses that only depend
+// on the number of characters with each pattern
+// (e.g. a tree search)
+// evaluate each pattern only once.
+//
+// The weight of each character
+// of the new matrix
+// is the sum of the weights
+// of the characters with the pattern,
+// and its count
+// (see Count)
+// is the number of characters
+// with the pattern.
+// It also returns the characters
+// of the original matrix
+// collapsed in each character
+// of the new matrix.
+// Excluded characters are removed,
+// and characters with different step matrices
+// are not collapsed.
+// If class is not nil,
+// it is a label for each character,
+// and characters with different labels
+// are not collapsed
+// (for example,
+// characters with different evolutionary models).
+//
+// As in Resample,
+// terminals are not shared
+// with the original matrix.
+func (m *Matrix) Compress(class []string) (*Matrix, []Pattern) {
+	type key struct {
+		sm    *StepMatrix
+		class string
+	}
+	var pats [][]int
+	for _, p := range m.Patterns() {
+		idx := make(map[key]int)
+		for _, c := range p.Chars {
+			if m.IsExcluded(c) {
+				continue
+			}
+			k := key{sm: m.Step(c)}
+			if class != nil {
+				k.class = class[c]
+			}
+			j, ok := idx[k]
+			if !ok {
+				j = len(pats)
+				idx[k] = j
+				pats = append(pats, nil)
+			}
+			pats[j] = append(pats[j], c)
+		}
+	}
+
+	// as in Resample,
+	// characters are sorted
+	sort.Slice(pats, func(i, j int) bool {
+		return pats[i][0] < pats[j][0]
+	})
+	sel := make([]int, len(pats))
+	for i, p := range pats {
+		sel[i] = p[0]
+	}
+
+	c := m.Resample(sel)
+	c.excluded = nil
+	c.weights = make([]int, len(pats))
+	c.counts = make([]int, len(pats))
+	ps := make([]Pattern, len(pats))
+	for i, p := range pats {
+		for _, ch := range p {
+			c.weights[i] += m.Weight(ch)
+			c.counts[i] += m.Count(ch)
+		}
+		ps[i] = Pattern{Chars: p}
+	}
+	return c, ps
+}
+
+// Count returns the number of characters
+// of the original matrix
+// represented by a character,
+// in a matrix built with Compress.
+// In any other matrix,
+// it is 1.
+func (m *Matrix) Count(char int) int {
+	if m.counts == nil {
+		return 1
+	}
+	return m.counts[char]
+}
+
+// SelectCounts returns the counts
+// of the indicated characters,
+// or nil,
+// if the matrix is not compressed.
+func (m *Matrix) selectCounts(chars []int) []int {
+	if m.counts == nil {
+		return nil
+	}
+	n := make([]int, len(chars))
+	for i, c := range chars {
+		n[i] = m.counts[c]
+	}
+	return n
+}
@@ -0,0 +1,218 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// PhylipName is the length of a taxon name
+// in strict PHYLIP format.
+const phylipName = 10
+
+// NewFromPhylip returns a new matrix
+// from a PHYLIP file,
+// as used by RAxML or PhyML.
+//
+// The first line has the number of taxa
+// and the number of characters.
+// The sequences can be in sequential,
+// or interleaved format,
+// and the names can be relaxed
+// (i.e. the name is the first word of the line)
+// or strict
+// (i.e. the name is given by the first 10 characters of the line).
+// Relaxed names,
+// and the sequential format
+// are tried first.
+//
+// If all the characters are digits
+// the sequences are read as morphology,
+// if there are amino acid symbols
+// that are not nucleotide symbols
+// they are read as protein,
+// otherwise,
+// they are read as DNA.
+func NewFromPhylip(r io.Reader) (*Matrix, error) {
+	taxa, err := readPhylip(bufio.NewReader(r))
+	if err != nil {
+		return nil, err
+	}
+	return fromTaxa(taxa)
+}
+
+// IsPhylip returns true if the reader
+// starts with a PHYLIP header,
+// i.e. a line with the number of taxa
+// and the number of characters.
+func isPhylip(r *bufio.Reader) bool {
+	h, _ := r.Peek(r.Size())
+	s := strings.TrimSpace(string(h))
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		s = s[:i]
+	}
+	_, _, err := phylipHeader(s)
+	return err == nil
+}
+
+// PhylipHeader returns the number of taxa
+// and characters
+// in the header of a PHYLIP file.
+func phylipHeader(ln string) (ntax, nchar int, err error) {
+	f := strings.Fields(ln)
+	if len(f) < 2 {
+		return 0, 0, errors.New("expecting number of taxa and characters")
+	}
+	ntax, err = strconv.Atoi(f[0])
+	if err != nil || ntax < 1 {
+		return 0, 0, errors.Errorf("invalid number of taxa %q", f[0])
+	}
+	nchar, err = strconv.Atoi(f[1])
+	if err != nil || nchar < 1 {
+		return 0, 0, errors.Errorf("invalid number of characters %q", f[1])
+	}
+	return ntax, nchar, nil
+}
+
+// ReadPhylip reads the sequences
+// of a PHYLIP file.
+func readPhylip(r *bufio.Reader) ([]*Taxon, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "matrix: phylip")
+	}
+	var lines []string
+	for _, ln := range strings.Split(string(b), "\n") {
+		ln = strings.TrimRight(ln, " \t\r")
+		if strings.TrimSpace(ln) == "" {
+			continue
+		}
+		lines = append(lines, ln)
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("matrix: phylip: empty file")
+	}
+	ntax, nchar, err := phylipHeader(lines[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "matrix: phylip: header")
+	}
+	lines = lines[1:]
+
+	var first error
+	for _, strict := range []bool{false, true} {
+		for _, interleaved := range []bool{false, true} {
+			seqs, err := phylipSeqs(lines, ntax, nchar, strict, interleaved)
+			if err == nil {
+				var taxa []*Taxon
+				taxa, err = phylipTaxa(seqs)
+				if err == nil {
+					return taxa, nil
+				}
+			}
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return nil, errors.Wrap(first, "matrix: phylip")
+}
+
+// PhylipSeqs returns the names and sequences
+// of a PHYLIP file.
+func phylipSeqs(lines []string, ntax, nchar int, strict, interleaved bool) ([][2]string, error) {
+	seqs := make([][2]string, 0, ntax)
+	seqLen := make([]int, 0, ntax)
+	names := make(map[string]bool)
+	cur := 0
+	for i, ln := range lines {
+		if len(seqs) < ntax && (interleaved || len(seqs) == 0 || seqLen[len(seqs)-1] >= nchar) {
+			// a new taxon
+			var name, rest string
+			if strict {
+				if len(ln) <= phylipName {
+					return nil, errors.Errorf("line %d: taxon without sequence", i+2)
+				}
+				name, rest = strings.TrimSpace(ln[:phylipName]), ln[phylipName:]
+			} else {
+				f := strings.Fields(ln)
+				name = f[0]
+				rest = strings.Join(f[1:], "")
+			}
+			if name == "" {
+				return nil, errors.Errorf("line %d: taxon without name", i+2)
+			}
+			if names[name] {
+				return nil, errors.Errorf("line %d: taxon %s repeated", i+2, name)
+			}
+			names[name] = true
+			s := strings.Join(strings.Fields(rest), "")
+			seqs = append(seqs, [2]string{name, s})
+			seqLen = append(seqLen, len(s))
+			cur = len(seqs) - 1
+		} else {
+			if len(seqs) == 0 {
+				return nil, errors.Errorf("line %d: sequence without taxon", i+2)
+			}
+			if interleaved {
+				cur = (i - ntax) % ntax
+			}
+			s := strings.Join(strings.Fields(ln), "")
+			seqs[cur][1] += s
+			seqLen[cur] += len(s)
+		}
+		if seqLen[cur] > nchar {
+			return nil, errors.Errorf("taxon %s: more than %d characters", seqs[cur][0], nchar)
+		}
+	}
+	if len(seqs) != ntax {
+		return nil, errors.Errorf("%d taxa, want %d", len(seqs), ntax)
+	}
+	for i, s := range seqs {
+		if seqLen[i] != nchar {
+			return nil, errors.Errorf("taxon %s: %d characters, want %d", s[0], seqLen[i], nchar)
+		}
+	}
+	return seqs, nil
+}
+
+// PhylipTaxa returns the taxa
+// from the names and sequences
+// of a PHYLIP file.
+func phylipTaxa(seqs [][2]string) ([]*Taxon, error) {
+	kind := Morphology
+	for _, s := range seqs {
+		if strings.Trim(s[1], "0123456789?-") != "" {
+			kind = DNA
+		}
+		if isProtein(s[1]) {
+			kind = Protein
+			break
+		}
+	}
+
+	var taxa []*Taxon
+	for _, s := range seqs {
+		tx := &Taxon{Name: s[0], Block: 1, Type: kind}
+		sr := bufio.NewReader(strings.NewReader(s[1]))
+		for {
+			c, err := readStates(sr, kind)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, errors.Wrapf(err, "taxon %s: char %d", tx.Name, len(tx.Chars)+1)
+			}
+			tx.Chars = append(tx.Chars, c)
+		}
+		taxa = append(taxa, tx)
+	}
+	return taxa, nil
+}
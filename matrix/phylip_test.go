@@ -0,0 +1,107 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPhylip(t *testing.T) {
+	want := map[string][]uint32{
+		"Out":        {1, 2, 4, 8, 1, 2},
+		"Homo_sap":   {1, 2, 15, 8, 1, 2},
+		"Pan_troglo": {5, 2, 15, 8, 1, 15},
+	}
+	blobs := map[string]string{
+		"relaxed sequential": `3 6
+Out       ACGTAC
+Homo_sap  AC-TAC
+Pan_troglo RCNT
+A?
+`,
+		"relaxed interleaved": `3 6
+Out ACG
+Homo_sap AC-
+Pan_troglo RCN
+
+TAC
+TAC
+TA?
+`,
+		"strict sequential": `  3   6
+Out       ACGTAC
+Homo_sap  AC-TAC
+Pan_trogloRCNTA?
+`,
+		"strict interleaved": `3 6
+Out       AC GT
+Homo_sap  AC -T
+Pan_trogloRC NT
+AC
+AC
+A?
+`,
+	}
+	for n, b := range blobs {
+		for _, fn := range []string{"NewFromPhylip", "NewMatrix"} {
+			var m *Matrix
+			var err error
+			if fn == "NewMatrix" {
+				m, err = NewMatrix(strings.NewReader(b))
+			} else {
+				m, err = NewFromPhylip(strings.NewReader(b))
+			}
+			if err != nil {
+				t.Errorf("matrix: phylip: %s: %s: unexpected error: %v", n, fn, err)
+				continue
+			}
+			if m.Out.Name != "Out" {
+				t.Errorf("matrix: phylip: %s: %s: outgroup %s, want Out", n, fn, m.Out.Name)
+			}
+			if len(m.Names) != len(want) {
+				t.Errorf("matrix: phylip: %s: %s: %d terminals, want %d", n, fn, len(m.Names), len(want))
+			}
+			for nm, w := range want {
+				tx, ok := m.Names[nm]
+				if !ok {
+					t.Errorf("matrix: phylip: %s: %s: terminal %s not found", n, fn, nm)
+					continue
+				}
+				if !reflect.DeepEqual(tx.Chars, w) {
+					t.Errorf("matrix: phylip: %s: %s: terminal %s: chars %v, want %v", n, fn, nm, tx.Chars, w)
+				}
+			}
+		}
+	}
+
+	m, err := NewFromPhylip(strings.NewReader("2 3\nA 01?\nB 1-0\n"))
+	if err != nil {
+		t.Fatalf("matrix: phylip: morphology: unexpected error: %v", err)
+	}
+	if m.Blocks[0].Type != Morphology {
+		t.Errorf("matrix: phylip: morphology: data type %s", m.Blocks[0].Type)
+	}
+
+	m, err = NewFromPhylip(strings.NewReader("2 4\nA MKLV\nB MKIV\n"))
+	if err != nil {
+		t.Fatalf("matrix: phylip: protein: unexpected error: %v", err)
+	}
+	if m.Blocks[0].Type != Protein {
+		t.Errorf("matrix: phylip: protein: data type %s", m.Blocks[0].Type)
+	}
+
+	bad := map[string]string{
+		"short": "2 4\nA ACGT\nB ACG\n",
+		"taxa":  "3 4\nA ACGT\nB ACGT\n",
+		"stop":  "2 4\nA MKL*\nB MKIV\n",
+	}
+	for n, b := range bad {
+		if _, err := NewFromPhylip(strings.NewReader(b)); err == nil {
+			t.Errorf("matrix: phylip: %s: expecting error", n)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ParseRange reads a list of characters,
+// defined by its number,
+// or by a range of numbers
+// (e.g. "1-5 8 10-12").
+// A range can have a step
+// (e.g. "1-12\3" is 1, 4, 7, and 10),
+// as in NEXUS character sets.
+// Character numbers in the list
+// start at 1,
+// but the returned indexes start at 0.
+// Max is the number of characters in the matrix.
+func ParseRange(s string, max int) ([]int, error) {
+	in := make(map[int]bool)
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == ' ' || r == ',' || r == '\t'
+	}) {
+		rng, step := f, 1
+		if i := strings.Index(f, "\\"); i >= 0 {
+			v, err := strconv.Atoi(f[i+1:])
+			if err != nil {
+				return nil, errors.Wrapf(err, "matrix: range %q", f)
+			}
+			if v < 1 {
+				return nil, errors.Errorf("matrix: range %q: invalid step", f)
+			}
+			rng, step = f[:i], v
+		}
+		first, last := rng, rng
+		if i := strings.Index(rng, "-"); i >= 0 {
+			first, last = rng[:i], rng[i+1:]
+		}
+		a, err := strconv.Atoi(first)
+		if err != nil {
+			return nil, errors.Wrapf(err, "matrix: range %q", f)
+		}
+		b, err := strconv.Atoi(last)
+		if err != nil {
+			return nil, errors.Wrapf(err, "matrix: range %q", f)
+		}
+		if a < 1 || b > max || a > b {
+			return nil, errors.Errorf("matrix: range %q: out of range 1-%d", f, max)
+		}
+		for i := a; i <= b; i += step {
+			in[i-1] = true
+		}
+	}
+	ls := make([]int, 0, len(in))
+	for i := range in {
+		ls = append(ls, i)
+	}
+	sort.Ints(ls)
+	return ls, nil
+}
+
+// FormatRange returns a list of characters
+// (with indexes starting at 0)
+// as a string that can be read by ParseRange.
+// The list must be sorted.
+func FormatRange(chars []int) string {
+	var ls []string
+	for i := 0; i < len(chars); {
+		a := chars[i]
+		if i+1 == len(chars) {
+			ls = append(ls, strconv.Itoa(a+1))
+			break
+		}
+		step := chars[i+1] - a
+		j := i + 1
+		for j+1 < len(chars) && chars[j+1]-chars[j] == step {
+			j++
+		}
+		b := chars[j]
+		switch {
+		case step == 1:
+			ls = append(ls, fmt.Sprintf("%d-%d", a+1, b+1))
+		case j-i < 2:
+			// only two elements
+			// on a stepped range
+			ls = append(ls, strconv.Itoa(a+1))
+			j = i
+		default:
+			ls = append(ls, fmt.Sprintf("%d-%d\\%d", a+1, b+1, step))
+		}
+		i = j + 1
+	}
+	return strings.Join(ls, " ")
+}
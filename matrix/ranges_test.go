@@ -0,0 +1,66 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRange(t *testing.T) {
+	testData := []struct {
+		in   string
+		want []int
+	}{
+		{"1", []int{0}},
+		{"1-3 7", []int{0, 1, 2, 6}},
+		{"5,2-3,3", []int{1, 2, 4}},
+		{"1-10\\3", []int{0, 3, 6, 9}},
+		{"2-9\\3 1", []int{0, 1, 4, 7}},
+	}
+	for _, d := range testData {
+		ls, err := ParseRange(d.in, 10)
+		if err != nil {
+			t.Errorf("matrix: parserange: %q: unexpected error: %v", d.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(ls, d.want) {
+			t.Errorf("matrix: parserange: %q: %v, want %v", d.in, ls, d.want)
+		}
+	}
+
+	for _, in := range []string{"0", "3-1", "11", "a-b", "1-5\\0"} {
+		if _, err := ParseRange(in, 10); err == nil {
+			t.Errorf("matrix: parserange: %q: expecting error", in)
+		}
+	}
+}
+
+func TestFormatRange(t *testing.T) {
+	testData := []struct {
+		in   []int
+		want string
+	}{
+		{[]int{0}, "1"},
+		{[]int{0, 1, 2, 6}, "1-3 7"},
+		{[]int{0, 3, 6, 9}, "1-10\\3"},
+		{[]int{0, 4}, "1 5"},
+		{[]int{1, 4, 7, 8, 9, 10}, "2-8\\3 9-11"},
+	}
+	for _, d := range testData {
+		s := FormatRange(d.in)
+		if s != d.want {
+			t.Errorf("matrix: formatrange: %v: %q, want %q", d.in, s, d.want)
+		}
+		ls, err := ParseRange(s, 20)
+		if err != nil {
+			t.Errorf("matrix: formatrange: %q: unexpected error: %v", s, err)
+			continue
+		}
+		if !reflect.DeepEqual(ls, d.in) {
+			t.Errorf("matrix: formatrange: %q: %v, want %v", s, ls, d.in)
+		}
+	}
+}
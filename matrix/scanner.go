@@ -9,18 +9,22 @@ package matrix
 import (
 	"bufio"
 	"io"
+	"strconv"
 	"strings"
 	"unicode"
+	"unicode/utf8"
 
 	"github.com/pkg/errors"
 )
 
 // A Taxon is a taxon name with phylogenetic (character) data.
 type Taxon struct {
-	Name  string
-	Block int // Current block
-	Type  DataType
-	Chars []uint8
+	Name      string
+	Block     int    // Current block
+	BlockName string // Name of the current block
+	Type      DataType
+	Chars     []uint32
+	Line      int // Line of the taxon in the source (0 if unknown)
 }
 
 // A DataType is the kind of the read phylogenetic data.
@@ -30,8 +34,13 @@ type DataType uint
 const (
 	Morphology DataType = iota // Morphological data, valid states: 0-7
 	DNA                        // DNA data, valid states: A, C, G, T (and IUPAC polymorphics)
+	Protein                    // Protein data, valid states: the 20 amino acids (and B, Z, J, X)
 )
 
+// AminoAcids are the symbols of the protein states,
+// in the order of its bits.
+const aminoAcids = "ARNDCQEGHILKMFPSTWYV"
+
 // String returns the name of a data type.
 func (d DataType) String() string {
 	switch d {
@@ -39,16 +48,42 @@ func (d DataType) String() string {
 		return "morphology"
 	case DNA:
 		return "dna"
+	case Protein:
+		return "protein"
 	}
 	return "unknown"
 }
 
+// Symbol returns the symbol used for a state
+// (defined by its index)
+// of a data type.
+func (d DataType) Symbol(state int) string {
+	if d == DNA {
+		if state < 4 {
+			return string("ACGT"[state])
+		}
+		if state == 4 {
+			return "-" // the gap state
+		}
+		return "?"
+	}
+	if d == Protein {
+		if state < len(aminoAcids) {
+			return string(aminoAcids[state])
+		}
+		return "?"
+	}
+	return strconv.Itoa(state)
+}
+
 // A Scanner reads phylogenetic character data from a reader.
 type Scanner struct {
 	r     *bufio.Reader
 	kind  DataType
 	block int
+	name  string // block name
 	taxon *Taxon
+	line  int // number of read lines
 	err   error
 }
 
@@ -56,7 +91,7 @@ type Scanner struct {
 func NewScanner(r io.Reader) *Scanner {
 	s := &Scanner{r: bufio.NewReader(r)}
 	for {
-		r1 := peekRune(s.r)
+		r1 := s.peek()
 		if r1 == 0 {
 			_, _, err := s.r.ReadRune()
 			if err == nil {
@@ -66,20 +101,25 @@ func NewScanner(r io.Reader) *Scanner {
 			return s
 		}
 		if r1 == '#' {
-			s.r.ReadString('\n')
+			s.skipLine()
 			continue
 		}
 		if r1 == '>' {
 			s.r.ReadRune()
-			kind, err := readDataType(s.r)
+			ln := s.line + 1
+			kind, name, err := readDataType(s.r)
 			if err != nil {
-				s.err = errors.Wrap(err, "while starting scanner")
+				s.err = errors.Wrapf(err, "line %d: while starting scanner", ln)
 				return s
 			}
+			s.line++
 			s.kind = kind
+			s.name = name
 			s.block = 1
 			break
 		}
+		s.err = errors.Errorf("line %d: expecting block header", s.line+1)
+		return s
 	}
 	return s
 }
@@ -99,55 +139,77 @@ func (s *Scanner) Scan() bool {
 		return false
 	}
 	for {
-		r1 := peekRune(s.r)
+		r1 := s.peek()
 		if r1 == 0 {
 			_, _, err := s.r.ReadRune()
 			if err == nil {
-				err = errors.Errorf("block %d: unexpected error", s.block)
+				err = errors.Errorf("line %d: block %d: unexpected error", s.line+1, s.block)
 			}
 			s.err = err
 			return false
 		}
 		if r1 == '#' {
-			s.r.ReadString('\n')
+			s.skipLine()
 			continue
 		}
 		if r1 == '>' {
 			s.r.ReadRune()
-			kind, err := readDataType(s.r)
+			ln := s.line + 1
+			kind, name, err := readDataType(s.r)
 			if err != nil {
-				s.err = errors.Wrapf(err, "expecting block: %d", s.block+1)
+				s.err = errors.Wrapf(err, "line %d: expecting block: %d", ln, s.block+1)
 				return false
 			}
+			s.line++
 			s.kind = kind
+			s.name = name
 			s.block++
 			continue
 		}
-		ln, err := s.r.ReadString('\n')
+		ln := s.line + 1
+		line, err := s.r.ReadString('\n')
 		if err != nil {
 			s.err = err
 			return false
 		}
-		entry := strings.Fields(ln)
+		s.line++
+		entry := strings.Fields(line)
 		name := entry[0]
 		if len(entry) == 1 {
-			s.err = errors.Errorf("block %d: taxon %s: no characers", s.block, name)
+			s.err = errors.Errorf("line %d: block %d: taxon %s: no characters", ln, s.block, name)
 			return false
 		}
-		var data []uint8
-		dr := bufio.NewReader(strings.NewReader(strings.Join(entry[1:], "")))
+
+		// characters are read without spaces,
+		// keeping the column of each symbol
+		var chars []byte
+		var cols []int
+		start := strings.Index(line, name) + len(name)
+		for i, r1 := range line[start:] {
+			if unicode.IsSpace(r1) {
+				continue
+			}
+			for b := 0; b < utf8.RuneLen(r1); b++ {
+				chars = append(chars, line[start+i+b])
+				cols = append(cols, start+i+1)
+			}
+		}
+		sr := strings.NewReader(string(chars))
+		dr := bufio.NewReader(sr)
+		var data []uint32
 		for {
+			off := len(chars) - sr.Len() - dr.Buffered()
 			c, err := readStates(dr, s.kind)
 			if err == io.EOF {
 				break
 			}
 			if err != nil {
-				s.err = errors.Wrapf(err, "block %d: taxon %s", s.block, name)
+				s.err = errors.Wrapf(err, "line %d, column %d: block %d: taxon %s: character %d", ln, cols[off], s.block, name, len(data)+1)
 				return false
 			}
 			data = append(data, c)
 		}
-		s.taxon = &Taxon{Name: name, Block: s.block, Type: s.kind, Chars: data}
+		s.taxon = &Taxon{Name: name, Block: s.block, BlockName: s.name, Type: s.kind, Chars: data, Line: ln}
 		return true
 	}
 }
@@ -175,46 +237,50 @@ func (s *Scanner) Err() error {
 	return s.err
 }
 
-func readDataType(r *bufio.Reader) (DataType, error) {
+// ReadDataType reads the data type of a block,
+// and the optional name of the block.
+func readDataType(r *bufio.Reader) (DataType, string, error) {
 	if err := skipSpaces(r); err != nil {
-		return 0, err
+		return 0, "", err
 	}
 
-	var last rune
-	var b strings.Builder
-	for {
-		r1, _, err := r.ReadRune()
-		if err != nil {
-			return 0, err
-		}
-		if unicode.IsSpace(r1) {
-			last = r1
-			break
-		}
-		b.WriteRune(r1)
+	ln, err := r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return 0, "", err
+	}
+	f := strings.Fields(ln)
+	if len(f) == 0 {
+		return 0, "", io.ErrUnexpectedEOF
 	}
-	if last != '\n' {
-		r.ReadString('\n')
+	var name string
+	if len(f) > 1 {
+		name = f[1]
 	}
-	tp := strings.ToLower(b.String())
+	tp := strings.ToLower(f[0])
 	if tp == "dna" {
-		return DNA, nil
+		return DNA, name, nil
+	}
+	if tp == "protein" {
+		return Protein, name, nil
 	}
 	if strings.HasPrefix(tp, "morpho") {
-		return Morphology, nil
+		return Morphology, name, nil
 	}
-	return 0, errors.Errorf("unknown data type: %s", tp)
+	return 0, "", errors.Errorf("unknown data type: %s", tp)
 }
 
 // Unknown returns the Unknown state for a datatype.
-func Unknown(kind DataType) uint8 {
+func Unknown(kind DataType) uint32 {
 	if kind == DNA {
 		return 15
 	}
+	if kind == Protein {
+		return 1<<uint(len(aminoAcids)) - 1
+	}
 	return 255
 }
 
-func readStates(r *bufio.Reader, kind DataType) (uint8, error) {
+func readStates(r *bufio.Reader, kind DataType) (uint32, error) {
 	if kind == DNA {
 		r1, _, err := r.ReadRune()
 		if err != nil {
@@ -249,11 +315,37 @@ func readStates(r *bufio.Reader, kind DataType) (uint8, error) {
 			return 1 | 2 | 8, nil // not G
 		case 'V', 'v':
 			return 1 | 2 | 4, nil // not T
-		case 'X', 'x', 'N', 'n', '?', '-', 'O', 'o':
+		case '-':
+			return Gap, nil
+		case 'X', 'x', 'N', 'n', '?', 'O', 'o':
 			return Unknown(DNA), nil
 		}
 		return 0, errors.Errorf("unknown symbol %q", r1)
 	}
+	if kind == Protein {
+		r1, _, err := r.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		if r1 != '[' && r1 != '(' {
+			return aminoAcid(r1)
+		}
+		var c uint32
+		for {
+			r1, _, err := r.ReadRune()
+			if err != nil {
+				return 0, errors.Wrap(err, "while reading polymorh")
+			}
+			if r1 == ')' || r1 == ']' {
+				return c, nil
+			}
+			st, err := aminoAcid(r1)
+			if err != nil {
+				return 0, errors.Wrap(err, "while reading polymorph")
+			}
+			c |= st
+		}
+	}
 	if kind != Morphology {
 		return 0, io.EOF
 	}
@@ -266,7 +358,7 @@ func readStates(r *bufio.Reader, kind DataType) (uint8, error) {
 			return Unknown(Morphology), nil
 		}
 		if r1 == '[' || r1 == '(' {
-			var c uint8
+			var c uint32
 			for {
 				r1, _, err := r.ReadRune()
 				if err != nil {
@@ -278,23 +370,68 @@ func readStates(r *bufio.Reader, kind DataType) (uint8, error) {
 				if !unicode.IsDigit(r1) {
 					return 0, errors.Errorf("while reading polymorph: unknown symbol %q", r1)
 				}
-				c |= 1 << (uint8(r1) - uint8('0'))
+				if r1 > '7' {
+					return 0, errors.Errorf("while reading polymorph: state %q: only states 0-7 are supported", r1)
+				}
+				c |= 1 << (uint32(r1) - uint32('0'))
 			}
 		}
 		if !unicode.IsDigit(r1) {
 			return 0, errors.Errorf("unknown symbol %q", r1)
 		}
-		return 1 << (uint8(r1) - uint8('0')), nil
+		if r1 > '7' {
+			return 0, errors.Errorf("state %q: only states 0-7 are supported", r1)
+		}
+		return 1 << (uint32(r1) - uint32('0')), nil
 	}
 }
 
-func peekRune(r *bufio.Reader) rune {
-	if err := skipSpaces(r); err != nil {
-		return 0
+// AminoAcid returns the state set
+// of an amino acid symbol.
+func aminoAcid(r1 rune) (uint32, error) {
+	switch unicode.ToUpper(r1) {
+	case 'B':
+		return 1<<2 | 1<<3, nil // N or D
+	case 'Z':
+		return 1<<5 | 1<<6, nil // Q or E
+	case 'J':
+		return 1<<9 | 1<<10, nil // I or L
+	case 'X', '?', '-':
+		return Unknown(Protein), nil
+	}
+	i := strings.IndexRune(aminoAcids, unicode.ToUpper(r1))
+	if i < 0 {
+		return 0, errors.Errorf("unknown symbol %q", r1)
+	}
+	return 1 << uint(i), nil
+}
+
+// Peek returns the next rune
+// that is not a space,
+// without reading it,
+// and counts the skipped lines.
+// It returns 0 on error.
+func (s *Scanner) peek() rune {
+	for {
+		r1, _, err := s.r.ReadRune()
+		if err != nil {
+			return 0
+		}
+		if !unicode.IsSpace(r1) {
+			s.r.UnreadRune()
+			return r1
+		}
+		if r1 == '\n' {
+			s.line++
+		}
+	}
+}
+
+// SkipLine reads the rest of the current line.
+func (s *Scanner) skipLine() {
+	if _, err := s.r.ReadString('\n'); err == nil {
+		s.line++
 	}
-	r1, _, _ := r.ReadRune()
-	r.UnreadRune()
-	return r1
 }
 
 func skipSpaces(r *bufio.Reader) error {
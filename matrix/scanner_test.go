@@ -129,7 +129,7 @@ func TestReadStates(t *testing.T) {
 	testData := []struct {
 		entry string
 		kind  DataType
-		want  uint8
+		want  uint32
 	}{
 		{"0", Morphology, 1},
 		{"3", Morphology, 8},
@@ -140,8 +140,15 @@ func TestReadStates(t *testing.T) {
 		{"t", DNA, 8},
 		{"M", DNA, 3},
 		{"?", DNA, 15},
-		{"-", DNA, 15},
+		{"-", DNA, Gap},
 		{"X", DNA, 15},
+		{"A", Protein, 1},
+		{"v", Protein, 1 << 19},
+		{"B", Protein, 1<<2 | 1<<3},
+		{"Z", Protein, 1<<5 | 1<<6},
+		{"[KR]", Protein, 1<<1 | 1<<11},
+		{"X", Protein, 1<<20 - 1},
+		{"-", Protein, 1<<20 - 1},
 	}
 
 	for _, d := range testData {
@@ -155,3 +162,30 @@ func TestReadStates(t *testing.T) {
 		}
 	}
 }
+
+func TestScanErrors(t *testing.T) {
+	testData := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"symbol", "> dna\nA ACGT\nB AC ZT\n", "line 3, column 6: block 1: taxon B: character 3: unknown symbol 'Z'"},
+		{"block", "> dna\nA ACGT\nB ACGT\n> morpho\nA 01\nB 0x\n", "line 6, column 4: block 2: taxon B: character 2"},
+		{"count", "> dna\nA ACGT\n\n# comment\nB ACG\n", "line 5: on block 1: taxon B with wrong number of chars: 3, want 4"},
+		{"repeated", "> dna\nA ACGT\nA ACGA\n", "line 3: on block 1: taxon A repeated (previous definition at line 2)"},
+		{"no chars", "> dna\nA ACGT\nB\n", "line 3: block 1: taxon B: no characters"},
+		{"no header", "A ACGT\n", "line 1: expecting block header"},
+		{"state", "> morpho\nA 01\nB 09\n", "taxon B: character 2: state '9': only states 0-7 are supported"},
+		{"polymorph state", "> morpho\nA 01\nB 0[18]\n", "taxon B: character 2: while reading polymorph: state '8'"},
+	}
+	for _, d := range testData {
+		_, err := NewMatrix(strings.NewReader(d.data))
+		if err == nil {
+			t.Errorf("scan errors: %s: expecting error", d.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), d.want) {
+			t.Errorf("scan errors: %s: error %q, want %q", d.name, err.Error(), d.want)
+		}
+	}
+}
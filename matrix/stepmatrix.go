@@ -0,0 +1,209 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"bufio"
+	"io"
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A StepMatrix is a matrix
+// with the cost of the transformations
+// between the states of a character,
+// used in generalized (Sankoff) parsimony.
+type StepMatrix struct {
+	Name string
+	Cost [][]int // Cost[i][j] is the cost from state i to state j
+}
+
+// States returns the number of states
+// of a step matrix.
+func (sm *StepMatrix) States() int {
+	return len(sm.Cost)
+}
+
+// Max returns the maximum cost
+// of a transformation
+// in a step matrix.
+func (sm *StepMatrix) Max() int {
+	max := 0
+	for _, r := range sm.Cost {
+		for _, c := range r {
+			if c > max {
+				max = c
+			}
+		}
+	}
+	return max
+}
+
+// Step returns the step matrix
+// assigned to a character,
+// or nil,
+// if the character does not have a step matrix.
+func (m *Matrix) Step(char int) *StepMatrix {
+	if m.steps == nil {
+		return nil
+	}
+	return m.steps[char]
+}
+
+// SetStep assigns a step matrix
+// to a character.
+// If sm is nil,
+// the step matrix of the character
+// is removed.
+func (m *Matrix) SetStep(char int, sm *StepMatrix) error {
+	if sm != nil && bits.Len32(m.States(char)) > sm.States() {
+		return errors.Errorf("matrix: step matrix %s: character %d with %d states", sm.Name, char+1, bits.Len32(m.States(char)))
+	}
+	if m.steps == nil {
+		if sm == nil {
+			return nil
+		}
+		m.steps = make([]*StepMatrix, len(m.Kind))
+	}
+	m.steps[char] = sm
+	return nil
+}
+
+// ReadAssumptions reads a file of assumptions,
+// with the step matrices of the characters.
+//
+// A step matrix is defined with a line
+// with the keyword 'stepmatrix'
+// and the name of the matrix,
+// followed by a line for each state,
+// with the cost of transformation
+// from the state,
+// to each state,
+// separated by spaces.
+// Then a line with the name of the matrix,
+// an equal sign,
+// and a list of characters
+// (as defined in ParseRange),
+// assigns the step matrix to the characters.
+// For example:
+//
+//	# an ordered character
+//	stepmatrix ord3
+//	0 1 2
+//	1 0 1
+//	2 1 0
+//	ord3 = 1-5 8
+//
+// Lines starting with '#' are ignored.
+// The costs must be non-negative,
+// and the costs in the diagonal
+// must be 0.
+func (m *Matrix) ReadAssumptions(r io.Reader) error {
+	sms := make(map[string]*StepMatrix)
+	var cur *StepMatrix
+	s := bufio.NewScanner(r)
+	ln := 0
+	for s.Scan() {
+		ln++
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		f := strings.Fields(line)
+		if strings.ToLower(f[0]) == "stepmatrix" {
+			if err := checkStep(cur); err != nil {
+				return errors.Wrapf(err, "matrix: assumptions: line %d", ln)
+			}
+			if len(f) != 2 {
+				return errors.Errorf("matrix: assumptions: line %d: expecting step matrix name", ln)
+			}
+			if sms[f[1]] != nil {
+				return errors.Errorf("matrix: assumptions: line %d: step matrix %s repeated", ln, f[1])
+			}
+			cur = &StepMatrix{Name: f[1]}
+			sms[cur.Name] = cur
+			continue
+		}
+
+		if i := strings.Index(line, "="); i >= 0 {
+			if err := checkStep(cur); err != nil {
+				return errors.Wrapf(err, "matrix: assumptions: line %d", ln)
+			}
+			cur = nil
+			name := strings.TrimSpace(line[:i])
+			sm := sms[name]
+			if sm == nil {
+				return errors.Errorf("matrix: assumptions: line %d: undefined step matrix %s", ln, name)
+			}
+			chars, err := ParseRange(line[i+1:], len(m.Kind))
+			if err != nil {
+				return errors.Wrapf(err, "matrix: assumptions: line %d", ln)
+			}
+			for _, c := range chars {
+				if err := m.SetStep(c, sm); err != nil {
+					return errors.Wrapf(err, "matrix: assumptions: line %d", ln)
+				}
+			}
+			continue
+		}
+
+		if cur == nil {
+			return errors.Errorf("matrix: assumptions: line %d: unexpected line %q", ln, line)
+		}
+		row := make([]int, len(f))
+		for i, v := range f {
+			c, err := strconv.Atoi(v)
+			if err != nil || c < 0 {
+				return errors.Errorf("matrix: assumptions: line %d: step matrix %s: invalid cost %q", ln, cur.Name, v)
+			}
+			row[i] = c
+		}
+		cur.Cost = append(cur.Cost, row)
+	}
+	if err := s.Err(); err != nil {
+		return errors.Wrap(err, "matrix: assumptions")
+	}
+	if err := checkStep(cur); err != nil {
+		return errors.Wrap(err, "matrix: assumptions")
+	}
+	return nil
+}
+
+// CheckStep checks that a step matrix is valid.
+func checkStep(sm *StepMatrix) error {
+	if sm == nil {
+		return nil
+	}
+	if sm.States() < 2 || sm.States() > 8 {
+		return errors.Errorf("step matrix %s: invalid number of states %d", sm.Name, sm.States())
+	}
+	for i, r := range sm.Cost {
+		if len(r) != sm.States() {
+			return errors.Errorf("step matrix %s: state %d: %d costs, want %d", sm.Name, i, len(r), sm.States())
+		}
+		if r[i] != 0 {
+			return errors.Errorf("step matrix %s: state %d: cost to itself is not 0", sm.Name, i)
+		}
+	}
+	return nil
+}
+
+// SelectSteps returns the step matrices
+// of the indicated characters,
+// or nil,
+// if the matrix does not have step matrices.
+func (m *Matrix) selectSteps(chars []int) []*StepMatrix {
+	if m.steps == nil {
+		return nil
+	}
+	st := make([]*StepMatrix, len(chars))
+	for i, c := range chars {
+		st[i] = m.steps[c]
+	}
+	return st
+}
@@ -0,0 +1,71 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var assumptionsBlob = `
+# an ordered character
+stepmatrix ord
+0 1 2
+1 0 1
+2 1 0
+
+stepmatrix irrev
+0 1
+5 0
+
+ord = 2 4
+irrev = 6
+`
+
+func TestReadAssumptions(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(weightsBlob))
+	if err != nil {
+		t.Fatalf("matrix: assumptions: unexpected error while reading matrix: %v", err)
+	}
+	if m.Step(1) != nil {
+		t.Errorf("matrix: assumptions: got step matrix %s, want nil", m.Step(1).Name)
+	}
+	if err := m.ReadAssumptions(strings.NewReader(assumptionsBlob)); err != nil {
+		t.Fatalf("matrix: assumptions: unexpected error: %v", err)
+	}
+	names := make([]string, len(m.Kind))
+	for c := range m.Kind {
+		if sm := m.Step(c); sm != nil {
+			names[c] = sm.Name
+		}
+	}
+	want := []string{"", "ord", "", "ord", "", "irrev"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("matrix: assumptions: got %v, want %v", names, want)
+	}
+	if sm := m.Step(1); sm.States() != 3 || sm.Max() != 2 {
+		t.Errorf("matrix: assumptions: states %d, max %d, want %d, %d", sm.States(), sm.Max(), 3, 2)
+	}
+
+	// step matrices are kept on derived matrices
+	d := m.DropChars([]int{0, 1})
+	if sm := d.Step(1); sm == nil || sm.Name != "ord" {
+		t.Errorf("matrix: assumptions: drop chars: step matrix not kept")
+	}
+
+	for _, a := range []string{
+		"0 1\n1 0\n",
+		"stepmatrix a\n0 1\n1 0\nb = 1\n",
+		"stepmatrix a\n0 1 1\n1 0\n",
+		"stepmatrix a\n1 1\n1 0\n",
+		"stepmatrix a\n0 -1\n1 0\n",
+		"stepmatrix a\n0 1\n1 0\na = 7\n",
+	} {
+		if err := m.ReadAssumptions(strings.NewReader(a)); err == nil {
+			t.Errorf("matrix: assumptions: %q: expecting error", a)
+		}
+	}
+}
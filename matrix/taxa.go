@@ -0,0 +1,85 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ReadTaxa reads a list of terminal names,
+// one per line.
+// Empty lines,
+// and lines starting with '#'
+// are ignored.
+func ReadTaxa(r io.Reader) ([]string, error) {
+	s := bufio.NewScanner(r)
+	var ls []string
+	in := make(map[string]bool)
+	for s.Scan() {
+		nm := strings.TrimSpace(s.Text())
+		if nm == "" || nm[0] == '#' {
+			continue
+		}
+		if in[nm] {
+			continue
+		}
+		in[nm] = true
+		ls = append(ls, nm)
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrap(err, "matrix: taxa")
+	}
+	return ls, nil
+}
+
+// Subset returns a new matrix
+// with only the indicated terminals,
+// as in DropTaxa,
+// for example,
+// to restrict an analysis
+// to a subset of the terminals.
+// All the terminals must be in the matrix.
+func (m *Matrix) Subset(names []string) (*Matrix, error) {
+	for _, nm := range names {
+		if m.Names[nm] == nil {
+			return nil, errors.Errorf("matrix: subset: terminal %s not in matrix", nm)
+		}
+	}
+	return m.DropTaxa(m.Outside(names)), nil
+}
+
+// AddTaxa returns a new matrix
+// with the terminals of the matrix
+// and the terminals of another matrix,
+// for example,
+// to add query terminals
+// to a reference matrix.
+// Both matrices must have the same characters.
+// Terminals are shared with the original matrices,
+// and the characters definitions
+// (weights, step matrices, exclusions)
+// are taken from the first matrix.
+func (m *Matrix) AddTaxa(o *Matrix) (*Matrix, error) {
+	if len(o.Kind) != len(m.Kind) {
+		return nil, errors.Errorf("matrix: add taxa: matrix with %d characters, want %d", len(o.Kind), len(m.Kind))
+	}
+	for i, k := range o.Kind {
+		if k != m.Kind[i] {
+			return nil, errors.Errorf("matrix: add taxa: character %d: data type %s, want %s", i+1, k, m.Kind[i])
+		}
+	}
+	c := m.DropTaxa(nil)
+	for _, t := range o.Taxa() {
+		if c.Names[t.Name] != nil {
+			return nil, errors.Errorf("matrix: add taxa: terminal %s repeated", t.Name)
+		}
+		c.Names[t.Name] = t
+	}
+	return c, nil
+}
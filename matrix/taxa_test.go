@@ -0,0 +1,76 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSubset(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(emptyBlob))
+	if err != nil {
+		t.Fatalf("matrix: subset: unexpected error while reading matrix: %v", err)
+	}
+	ls, err := ReadTaxa(strings.NewReader("# taxa\nB\n\n  A \nB\n"))
+	if err != nil {
+		t.Fatalf("matrix: subset: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(ls, []string{"B", "A"}) {
+		t.Errorf("matrix: subset: taxa %v, want %v", ls, []string{"B", "A"})
+	}
+
+	s, err := m.Subset(ls)
+	if err != nil {
+		t.Fatalf("matrix: subset: unexpected error: %v", err)
+	}
+	if len(s.Names) != 2 || s.Names["A"] == nil || s.Names["B"] == nil {
+		t.Errorf("matrix: subset: terminals %v, want %v", s.Taxa(), ls)
+	}
+	if s.Out.Name != "A" {
+		t.Errorf("matrix: subset: outgroup %s, want %s", s.Out.Name, "A")
+	}
+
+	if _, err := m.Subset([]string{"A", "X"}); err == nil {
+		t.Errorf("matrix: subset: expecting error")
+	}
+}
+
+func TestAddTaxa(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(emptyBlob))
+	if err != nil {
+		t.Fatalf("matrix: add taxa: unexpected error while reading matrix: %v", err)
+	}
+	q, err := NewMatrix(strings.NewReader("> morpho\nC 0101\n> dna\nC ACGT\n"))
+	if err != nil {
+		t.Fatalf("matrix: add taxa: unexpected error while reading queries: %v", err)
+	}
+
+	a, err := m.AddTaxa(q)
+	if err != nil {
+		t.Fatalf("matrix: add taxa: unexpected error: %v", err)
+	}
+	if len(a.Names) != 4 || a.Names["C"] != q.Names["C"] {
+		t.Errorf("matrix: add taxa: terminals %v", a.Taxa())
+	}
+	if a.Out.Name != "Out" {
+		t.Errorf("matrix: add taxa: outgroup %s, want %s", a.Out.Name, "Out")
+	}
+	if len(m.Names) != 3 {
+		t.Errorf("matrix: add taxa: original matrix modified")
+	}
+
+	if _, err := a.AddTaxa(q); err == nil {
+		t.Errorf("matrix: add taxa: expecting error on repeated terminal")
+	}
+	q, err = NewMatrix(strings.NewReader("> dna\nC ACGTACGT\n"))
+	if err != nil {
+		t.Fatalf("matrix: add taxa: unexpected error while reading queries: %v", err)
+	}
+	if _, err := m.AddTaxa(q); err == nil {
+		t.Errorf("matrix: add taxa: expecting error on different characters")
+	}
+}
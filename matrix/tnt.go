@@ -0,0 +1,405 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// TntCommands are the commands
+// that can start a TNT file.
+var tntCommands = map[string]bool{
+	"mxram":   true,
+	"nstates": true,
+	"taxname": true,
+	"xread":   true,
+}
+
+// IsTNT returns true if the reader
+// starts with a TNT command.
+func isTNT(r *bufio.Reader) bool {
+	h, _ := r.Peek(r.Size())
+	s := strings.TrimSpace(string(h))
+	i := strings.IndexFunc(s, func(r1 rune) bool {
+		return unicode.IsSpace(r1) || r1 == ';' || r1 == '\''
+	})
+	if i >= 0 {
+		s = s[:i]
+	}
+	return tntCommands[strings.ToLower(s)]
+}
+
+// ReadTNT returns a new matrix,
+// and a table of continuous characters,
+// from the XREAD command of a TNT file.
+//
+// The XREAD command can have a title
+// (between single quotes),
+// and must have the number of characters,
+// and the number of taxa.
+// The data can be divided in blocks,
+// each one starting with '&'
+// and the data type between brackets,
+// for example:
+//
+//	xread
+//	'a small dataset'
+//	8 3
+//	& [num]
+//	Out    000
+//	Homo   1[01]1
+//	Pan    11?
+//	& [dna]
+//	Out    ACGT
+//	Homo   AC-T
+//	Pan    ACGT
+//	& [cont]
+//	Homo   1.5
+//	Pan    1.2-1.8
+//	;
+//
+// Valid data types are numeric
+// (read as morphology,
+// only states 0-7 are supported),
+// DNA,
+// protein,
+// and continuous.
+// If the data is not divided in blocks,
+// the data type is defined by the NSTATES command
+// (by default, numeric).
+// Polymorphisms are given between brackets.
+// Gaps are read as unknowns.
+// The data of a taxon
+// must be in a single line.
+// Taxa not present in a block
+// are read as unknowns.
+//
+// The numeric, DNA and protein blocks
+// are read as blocks of the matrix.
+// The continuous blocks are read
+// as a table of continuous characters,
+// with the characters named "cont<n>".
+// A range of values
+// is read as its midpoint.
+// If there are no numeric, DNA or protein blocks,
+// the returned matrix is nil,
+// and if there are no continuous blocks,
+// the returned table is nil.
+//
+// Other commands are ignored.
+func ReadTNT(r io.Reader) (*Matrix, *Continuous, error) {
+	taxa, c, err := readTNT(bufio.NewReader(r))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(taxa) == 0 {
+		return nil, c, nil
+	}
+	m, err := fromTaxa(taxa)
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, c, nil
+}
+
+// TntContinuous is the data type
+// of a continuous block of a TNT file.
+const tntContinuous DataType = 1 << 8
+
+func readTNT(r *bufio.Reader) ([]*Taxon, *Continuous, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "matrix: tnt")
+	}
+	kind := Morphology
+	for _, cmd := range strings.Split(string(b), ";") {
+		f := strings.Fields(cmd)
+		if len(f) == 0 {
+			continue
+		}
+		name := strings.ToLower(f[0])
+		if strings.HasPrefix(name, "xread") {
+			cmd = strings.TrimSpace(cmd)[len("xread"):]
+			taxa, c, err := readXread(cmd, kind)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "matrix: tnt: xread")
+			}
+			return taxa, c, nil
+		}
+		if name != "nstates" || len(f) < 2 {
+			continue
+		}
+		kind, err = tntDataType(f[1])
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "matrix: tnt: nstates")
+		}
+	}
+	return nil, nil, errors.New("matrix: tnt: xread command not found")
+}
+
+// TntDataType returns the data type
+// of a TNT data type name.
+func tntDataType(name string) (DataType, error) {
+	name = strings.ToLower(name)
+	if _, err := strconv.Atoi(name); err == nil {
+		return Morphology, nil
+	}
+	switch {
+	case strings.HasPrefix(name, "num"):
+		return Morphology, nil
+	case name == "dna", strings.HasPrefix(name, "nucl"):
+		return DNA, nil
+	case strings.HasPrefix(name, "cont"):
+		return tntContinuous, nil
+	case strings.HasPrefix(name, "prot"):
+		return Protein, nil
+	}
+	return 0, errors.Errorf("unknown data type: %s", name)
+}
+
+// ReadXread reads the content
+// of a XREAD command.
+func readXread(cmd string, kind DataType) ([]*Taxon, *Continuous, error) {
+	cmd = strings.TrimSpace(cmd)
+	if strings.HasPrefix(cmd, "'") {
+		end := strings.IndexByte(cmd[1:], '\'')
+		if end < 0 {
+			return nil, nil, errors.New("unfinished title")
+		}
+		cmd = strings.TrimSpace(cmd[end+2:])
+	}
+	f := strings.Fields(cmd)
+	if len(f) < 2 {
+		return nil, nil, errors.New("expecting number of characters and taxa")
+	}
+	nchar, err := strconv.Atoi(f[0])
+	if err != nil || nchar < 1 {
+		return nil, nil, errors.Errorf("invalid number of characters %q", f[0])
+	}
+	ntax, err := strconv.Atoi(f[1])
+	if err != nil || ntax < 1 {
+		return nil, nil, errors.Errorf("invalid number of taxa %q", f[1])
+	}
+	// skip the header
+	for i := 0; i < 2; i++ {
+		cmd = strings.TrimSpace(cmd)
+		sp := strings.IndexFunc(cmd, unicode.IsSpace)
+		if sp < 0 {
+			cmd = ""
+			break
+		}
+		cmd = cmd[sp+1:]
+	}
+
+	var taxa []*Taxon
+	var conts []map[string][]float64 // values of each continuous block
+	var cchars []int                 // characters of each continuous block
+	names := make(map[string]bool)
+	block, size := 0, -1
+	seen := make(map[string]bool)
+	newBlock := func(k DataType) {
+		kind, size = k, -1
+		seen = make(map[string]bool)
+		if k == tntContinuous {
+			conts = append(conts, make(map[string][]float64))
+			cchars = append(cchars, 0)
+			return
+		}
+		block++
+	}
+	started := false
+	for _, ln := range strings.Split(cmd, "\n") {
+		ln = strings.TrimSpace(ln)
+		if ln == "" {
+			continue
+		}
+		if strings.HasPrefix(ln, "&") {
+			ln = strings.TrimSpace(ln[1:])
+			tp := kind
+			if strings.HasPrefix(ln, "[") {
+				end := strings.IndexByte(ln, ']')
+				if end < 0 {
+					return nil, nil, errors.New("unfinished data type")
+				}
+				w := strings.Fields(ln[1:end])
+				if len(w) > 0 {
+					tp, err = tntDataType(w[0])
+					if err != nil {
+						return nil, nil, err
+					}
+				}
+			}
+			newBlock(tp)
+			started = true
+			continue
+		}
+		if !started {
+			newBlock(kind)
+			started = true
+		}
+
+		w := strings.Fields(ln)
+		name := w[0]
+		if seen[name] {
+			return nil, nil, errors.Errorf("taxon %s repeated", name)
+		}
+		seen[name] = true
+		names[name] = true
+		if kind == tntContinuous {
+			vals := make([]float64, 0, len(w)-1)
+			for _, v := range w[1:] {
+				x, err := tntValue(v)
+				if err != nil {
+					return nil, nil, errors.Wrapf(err, "taxon %s", name)
+				}
+				vals = append(vals, x)
+			}
+			if size < 0 {
+				size = len(vals)
+				cchars[len(cchars)-1] = size
+			}
+			if len(vals) != size {
+				return nil, nil, errors.Errorf("taxon %s: %d characters, want %d", name, len(vals), size)
+			}
+			conts[len(conts)-1][name] = vals
+			continue
+		}
+		chars, err := tntStates(strings.Join(w[1:], ""), kind)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "taxon %s", name)
+		}
+		if size < 0 {
+			size = len(chars)
+		}
+		if len(chars) != size {
+			return nil, nil, errors.Errorf("taxon %s: %d characters, want %d", name, len(chars), size)
+		}
+		taxa = append(taxa, &Taxon{
+			Name:  name,
+			Block: block,
+			Type:  kind,
+			Chars: chars,
+		})
+	}
+	if len(names) != ntax {
+		return nil, nil, errors.Errorf("%d taxa, want %d", len(names), ntax)
+	}
+
+	n := 0
+	lens := make(map[int]int)
+	for _, tx := range taxa {
+		lens[tx.Block] = len(tx.Chars)
+	}
+	for _, l := range lens {
+		n += l
+	}
+	var c *Continuous
+	if len(conts) > 0 {
+		c = &Continuous{Values: make(map[string][]float64, len(names))}
+		for _, cn := range cchars {
+			for j := 0; j < cn; j++ {
+				c.Chars = append(c.Chars, fmt.Sprintf("cont%d", len(c.Chars)+1))
+			}
+		}
+		n += len(c.Chars)
+		for nm := range names {
+			var vals []float64
+			for i, cv := range conts {
+				v, ok := cv[nm]
+				if !ok {
+					v = make([]float64, cchars[i])
+					for j := range v {
+						v[j] = math.NaN()
+					}
+				}
+				vals = append(vals, v...)
+			}
+			c.Values[nm] = vals
+		}
+	}
+	if n != nchar {
+		return nil, nil, errors.Errorf("%d characters, want %d", n, nchar)
+	}
+	return taxa, c, nil
+}
+
+// TntStates returns the states
+// of the data of a taxon.
+func tntStates(s string, kind DataType) ([]uint32, error) {
+	var chars []uint32
+	for i := 0; i < len(s); i++ {
+		if s[i] != '[' && s[i] != '(' {
+			st, err := tntSymbol(s[i], kind)
+			if err != nil {
+				return nil, errors.Wrapf(err, "char %d", len(chars)+1)
+			}
+			chars = append(chars, st)
+			continue
+		}
+		end := strings.IndexAny(s[i:], "])")
+		if end < 0 {
+			return nil, errors.Errorf("char %d: unfinished polymorphism", len(chars)+1)
+		}
+		var st uint32
+		for j := i + 1; j < i+end; j++ {
+			x, err := tntSymbol(s[j], kind)
+			if err != nil {
+				return nil, errors.Wrapf(err, "char %d", len(chars)+1)
+			}
+			st |= x
+		}
+		if st == 0 {
+			return nil, errors.Errorf("char %d: empty polymorphism", len(chars)+1)
+		}
+		chars = append(chars, st)
+		i += end
+	}
+	return chars, nil
+}
+
+// TntSymbol returns the state of a symbol.
+func tntSymbol(b byte, kind DataType) (uint32, error) {
+	if kind == DNA || kind == Protein {
+		return readStates(bufio.NewReader(strings.NewReader(string(b))), kind)
+	}
+	switch {
+	case b == '?' || b == '-':
+		return Unknown(Morphology), nil
+	case b >= '0' && b <= '7':
+		return 1 << (b - '0'), nil
+	case b >= '8' && b <= '9', b >= 'A' && b <= 'V', b >= 'a' && b <= 'v':
+		return 0, errors.Errorf("state %q: only states 0-7 are supported", b)
+	}
+	return 0, errors.Errorf("unknown symbol %q", b)
+}
+
+// TntValue returns the value
+// of a continuous character.
+func tntValue(v string) (float64, error) {
+	if v == "?" || v == "-" {
+		return math.NaN(), nil
+	}
+	if i := strings.IndexByte(v, '-'); i > 0 {
+		min, err := strconv.ParseFloat(v[:i], 64)
+		if err != nil {
+			return 0, err
+		}
+		max, err := strconv.ParseFloat(v[i+1:], 64)
+		if err != nil {
+			return 0, err
+		}
+		return (min + max) / 2, nil
+	}
+	return strconv.ParseFloat(v, 64)
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var tntBlob = `mxram 100;
+nstates 8;
+xread
+'a small dataset'
+9 3
+& [num]
+Out    000
+Homo   1[01]1
+Pan    11?
+& [dna gaps]
+Out    ACGT
+Homo   AC-T
+Pan    [AG]CGT
+& [cont]
+Homo   1.5 2
+Pan    1.2-1.8 ?
+;
+cc - .;
+proc/;
+`
+
+func TestTNT(t *testing.T) {
+	m, c, err := ReadTNT(strings.NewReader(tntBlob))
+	if err != nil {
+		t.Fatalf("matrix: tnt: unexpected error: %v", err)
+	}
+	if len(m.Blocks) != 2 {
+		t.Fatalf("matrix: tnt: %d blocks, want 2", len(m.Blocks))
+	}
+	if m.Blocks[0].Type != Morphology || m.Blocks[1].Type != DNA {
+		t.Errorf("matrix: tnt: block types %s %s, want morphology dna", m.Blocks[0].Type, m.Blocks[1].Type)
+	}
+	want := map[string][]uint32{
+		"Out":  {1, 1, 1, 1, 2, 4, 8},
+		"Homo": {2, 3, 2, 1, 2, 15, 8},
+		"Pan":  {2, 2, 255, 5, 2, 4, 8},
+	}
+	for nm, w := range want {
+		tx, ok := m.Names[nm]
+		if !ok {
+			t.Errorf("matrix: tnt: terminal %s not found", nm)
+			continue
+		}
+		if !reflect.DeepEqual(tx.Chars, w) {
+			t.Errorf("matrix: tnt: terminal %s: chars %v, want %v", nm, tx.Chars, w)
+		}
+	}
+
+	if len(c.Chars) != 2 {
+		t.Fatalf("matrix: tnt: %d continuous characters, want 2", len(c.Chars))
+	}
+	if v := c.Values["Homo"]; v[0] != 1.5 || v[1] != 2 {
+		t.Errorf("matrix: tnt: terminal Homo: values %v, want [1.5 2]", v)
+	}
+	if v := c.Values["Pan"]; math.Abs(v[0]-1.5) > 1e-9 || !math.IsNaN(v[1]) {
+		t.Errorf("matrix: tnt: terminal Pan: values %v, want [1.5 NaN]", v)
+	}
+	if v := c.Values["Out"]; !math.IsNaN(v[0]) || !math.IsNaN(v[1]) {
+		t.Errorf("matrix: tnt: terminal Out: values %v, want [NaN NaN]", v)
+	}
+
+	nm, err := NewMatrix(strings.NewReader(tntBlob))
+	if err != nil {
+		t.Fatalf("matrix: tnt: NewMatrix: unexpected error: %v", err)
+	}
+	if len(nm.Kind) != 7 {
+		t.Errorf("matrix: tnt: NewMatrix: %d characters, want 7", len(nm.Kind))
+	}
+	nc, err := ReadContinuous(strings.NewReader(tntBlob))
+	if err != nil {
+		t.Fatalf("matrix: tnt: ReadContinuous: unexpected error: %v", err)
+	}
+	if len(nc.Chars) != 2 {
+		t.Errorf("matrix: tnt: ReadContinuous: %d characters, want 2", len(nc.Chars))
+	}
+
+	dna, _, err := ReadTNT(strings.NewReader("nstates dna;\nxread 4 2\nA ACGT\nB AC-T\n;\n"))
+	if err != nil {
+		t.Fatalf("matrix: tnt: nstates dna: unexpected error: %v", err)
+	}
+	if dna.Blocks[0].Type != DNA {
+		t.Errorf("matrix: tnt: nstates dna: data type %s", dna.Blocks[0].Type)
+	}
+
+	prot, _, err := ReadTNT(strings.NewReader("xread 4 2\n& [prot]\nA MKLV\nB MK[IL]B\n;\n"))
+	if err != nil {
+		t.Fatalf("matrix: tnt: protein: unexpected error: %v", err)
+	}
+	if w := []uint32{1 << 12, 1 << 11, 1<<9 | 1<<10, 1<<2 | 1<<3}; !reflect.DeepEqual(prot.Names["B"].Chars, w) {
+		t.Errorf("matrix: tnt: protein: terminal B: chars %v, want %v", prot.Names["B"].Chars, w)
+	}
+
+	bad := map[string]string{
+		"chars":   "xread 4 2\nA 0101\nB 010\n;",
+		"nchar":   "xread 5 2\nA 0101\nB 0101\n;",
+		"ntax":    "xread 4 3\nA 0101\nB 0101\n;",
+		"states":  "xread 4 2\nA 0109\nB 0101\n;",
+		"protein": "xread 4 2\n& [prot]\nA MKL*\nB MKIV\n;",
+		"xread":   "mxram 100;\n",
+	}
+	for n, b := range bad {
+		if _, _, err := ReadTNT(strings.NewReader(b)); err == nil {
+			t.Errorf("matrix: tnt: %s: expecting error", n)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A TrimFilter is a set of filters
+// used to remove poorly aligned,
+// or noisy columns of an alignment
+// (as in trimAl).
+// A filter with a value of 0
+// is not applied.
+type TrimFilter struct {
+	// Maximum proportion of terminals
+	// with gaps, or missing data,
+	// in a column.
+	Gaps float64
+
+	// Maximum Shannon entropy
+	// (in bits)
+	// of the states of a column.
+	Entropy float64
+}
+
+// ParseTrim returns a trimming filter
+// from a definition,
+// i.e. a list of filters,
+// separated by semicolons,
+// each one with the name of the filter,
+// an equal sign,
+// and its value,
+// for example:
+//
+//	gaps=0.5; entropy=1.5
+//
+// Valid filters are "gaps",
+// the maximum proportion of gaps and missing data
+// (from 0 to 1),
+// and "entropy",
+// the maximum entropy of a column.
+func ParseTrim(def string) (TrimFilter, error) {
+	var f TrimFilter
+	for _, a := range strings.Split(def, ";") {
+		a = strings.TrimSpace(a)
+		if a == "" {
+			continue
+		}
+		i := strings.Index(a, "=")
+		if i < 0 {
+			return TrimFilter{}, errors.Errorf("matrix: trim: %q: expecting '='", a)
+		}
+		name := strings.ToLower(strings.TrimSpace(a[:i]))
+		v, err := strconv.ParseFloat(strings.TrimSpace(a[i+1:]), 64)
+		if err != nil || v < 0 {
+			return TrimFilter{}, errors.Errorf("matrix: trim: %q: invalid value", a)
+		}
+		switch name {
+		case "gaps":
+			if v > 1 {
+				return TrimFilter{}, errors.Errorf("matrix: trim: %q: invalid proportion", a)
+			}
+			f.Gaps = v
+		case "entropy":
+			f.Entropy = v
+		default:
+			return TrimFilter{}, errors.Errorf("matrix: trim: unknown filter %q", name)
+		}
+	}
+	return f, nil
+}
+
+// IsZero returns true
+// if no filter is applied.
+func (f TrimFilter) IsZero() bool {
+	return f.Gaps == 0 && f.Entropy == 0
+}
+
+// Trim excludes
+// (see Exclude)
+// the characters of the matrix
+// rejected by a trimming filter,
+// and returns the excluded characters.
+// Characters already excluded
+// are not returned.
+func (m *Matrix) Trim(f TrimFilter) []int {
+	var ex []int
+	for c := range m.Kind {
+		if m.IsExcluded(c) {
+			continue
+		}
+		if f.Gaps > 0 && m.gapProp(c) > f.Gaps {
+			ex = append(ex, c)
+			continue
+		}
+		if f.Entropy > 0 && m.Entropy(c) > f.Entropy {
+			ex = append(ex, c)
+		}
+	}
+	m.Exclude(ex)
+	return ex
+}
+
+// GapProp returns the proportion of terminals
+// with a gap,
+// or missing data,
+// in a character.
+func (m *Matrix) gapProp(char int) float64 {
+	col := m.Column(char)
+	if len(col) == 0 {
+		return 0
+	}
+	u := m.unknown(char)
+	n := 0
+	for _, s := range col {
+		if s == u || s == Gap {
+			n++
+		}
+	}
+	return float64(n) / float64(len(col))
+}
+
+// Entropy returns the Shannon entropy
+// (in bits)
+// of the states of a character.
+// Only terminals with a single state are counted,
+// so unknown and polymorphic data are ignored.
+func (m *Matrix) Entropy(char int) float64 {
+	counts := m.stateCounts(char)
+	sum := 0
+	for _, c := range counts {
+		sum += c
+	}
+	h := 0.0
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / float64(sum)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// BlockCounts returns the number
+// of characters of each block of the matrix
+// in a list of characters,
+// for example,
+// the characters excluded by Trim.
+func (m *Matrix) BlockCounts(chars []int) []int {
+	return PartitionCounts(m.BlockPartitions(), chars)
+}
+
+// PartitionCounts returns the number
+// of characters of each partition
+// in a list of characters.
+func PartitionCounts(ps []Partition, chars []int) []int {
+	part := make(map[int]int)
+	for i, p := range ps {
+		for _, c := range p.Chars {
+			part[c] = i
+		}
+	}
+	n := make([]int, len(ps))
+	for _, c := range chars {
+		if i, ok := part[c]; ok {
+			n[i]++
+		}
+	}
+	return n
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var trimBlob = `
+> morpho
+Out 01
+A   10
+B   ?1
+C   11
+> dna
+Out ACGTA
+A   AC-TC
+B   A?-GG
+C   AT-AT
+`
+
+func TestTrim(t *testing.T) {
+	if _, err := ParseTrim("gaps=2"); err == nil {
+		t.Errorf("matrix: trim: expecting error on invalid proportion")
+	}
+	if _, err := ParseTrim("size=2"); err == nil {
+		t.Errorf("matrix: trim: expecting error on unknown filter")
+	}
+	f, err := ParseTrim("gaps=0.5; entropy=1.9")
+	if err != nil {
+		t.Fatalf("matrix: trim: unexpected error: %v", err)
+	}
+	if w := (TrimFilter{Gaps: 0.5, Entropy: 1.9}); f != w {
+		t.Errorf("matrix: trim: filter %v, want %v", f, w)
+	}
+
+	m, err := NewMatrix(strings.NewReader(trimBlob))
+	if err != nil {
+		t.Fatalf("matrix: trim: unexpected error while reading matrix: %v", err)
+	}
+	if h := m.Entropy(2); h != 0 {
+		t.Errorf("matrix: trim: constant character: entropy %.4f, want 0", h)
+	}
+	if h := m.Entropy(6); math.Abs(h-2) > 1e-9 {
+		t.Errorf("matrix: trim: entropy %.4f, want 2", h)
+	}
+	m.Exclude([]int{0})
+	ex := m.Trim(f)
+	if w := []int{4, 6}; !reflect.DeepEqual(ex, w) {
+		t.Errorf("matrix: trim: excluded %v, want %v", ex, w)
+	}
+	if w := []int{0, 2}; !reflect.DeepEqual(m.BlockCounts(ex), w) {
+		t.Errorf("matrix: trim: block counts %v, want %v", m.BlockCounts(ex), w)
+	}
+	ps := []Partition{
+		{Name: "odd", Chars: []int{0, 2, 4, 6}},
+		{Name: "even", Chars: []int{1, 3, 5}},
+	}
+	if w := []int{2, 0}; !reflect.DeepEqual(PartitionCounts(ps, ex), w) {
+		t.Errorf("matrix: trim: partition counts %v, want %v", PartitionCounts(ps, ex), w)
+	}
+}
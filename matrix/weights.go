@@ -0,0 +1,145 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Weight returns the weight of a character.
+// By default,
+// the weight of each character is 1,
+// and the weight of an excluded character is 0.
+func (m *Matrix) Weight(char int) int {
+	if m.IsExcluded(char) {
+		return 0
+	}
+	if m.weights == nil {
+		return 1
+	}
+	return m.weights[char]
+}
+
+// Weights returns the weight of each character,
+// or nil,
+// if all the characters have the default weight.
+// The returned slice is shared,
+// so it should not be modified.
+// If there are excluded characters,
+// the returned slice is a copy
+// in which the excluded characters
+// have weight 0.
+func (m *Matrix) Weights() []int {
+	if m.excluded == nil {
+		return m.weights
+	}
+	w := make([]int, len(m.Kind))
+	for c := range w {
+		w[c] = m.Weight(c)
+	}
+	return w
+}
+
+// Active returns the number of characters
+// with a weight greater than 0,
+// i.e. the characters that are counted
+// in a parsimony analysis.
+func (m *Matrix) Active() int {
+	n := 0
+	for c := range m.Kind {
+		if m.Weight(c) > 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// SetWeights sets the weight of the characters
+// from a definition,
+// i.e. a list of assignments,
+// separated by semicolons,
+// each one with a weight,
+// a colon,
+// and a list of characters,
+// as in ParseRange,
+// for example:
+//
+//	2:1-300\3; 0:301-310
+//
+// Characters not included in the definition
+// keep its current weight.
+func (m *Matrix) SetWeights(def string) error {
+	for _, a := range strings.Split(def, ";") {
+		a = strings.TrimSpace(a)
+		if a == "" {
+			continue
+		}
+		i := strings.Index(a, ":")
+		if i < 0 {
+			return errors.Errorf("matrix: weight assignment %q: expecting ':'", a)
+		}
+		w, err := strconv.Atoi(strings.TrimSpace(a[:i]))
+		if err != nil || w < 0 {
+			return errors.Errorf("matrix: weight assignment %q: invalid weight", a)
+		}
+		chars, err := ParseRange(a[i+1:], len(m.Kind))
+		if err != nil {
+			return errors.Wrapf(err, "matrix: weight %d", w)
+		}
+		if m.weights == nil {
+			m.weights = make([]int, len(m.Kind))
+			for c := range m.weights {
+				m.weights[c] = 1
+			}
+		}
+		for _, c := range chars {
+			m.weights[c] = w
+		}
+	}
+	return nil
+}
+
+// ReadWeights sets the weight of the characters
+// from a weights file,
+// in which each line is a weight assignment,
+// as in SetWeights.
+// Empty lines,
+// and lines starting with '#'
+// are ignored.
+func (m *Matrix) ReadWeights(r io.Reader) error {
+	s := bufio.NewScanner(r)
+	var ls []string
+	for s.Scan() {
+		ln := strings.TrimSpace(s.Text())
+		if ln == "" || ln[0] == '#' {
+			continue
+		}
+		ls = append(ls, ln)
+	}
+	if err := s.Err(); err != nil {
+		return errors.Wrap(err, "matrix: weights")
+	}
+	return m.SetWeights(strings.Join(ls, ";"))
+}
+
+// SelectWeights returns the weights
+// of the indicated characters,
+// or nil,
+// if the matrix has the default weights.
+func (m *Matrix) selectWeights(chars []int) []int {
+	if m.weights == nil {
+		return nil
+	}
+	w := make([]int, len(chars))
+	for i, c := range chars {
+		w[i] = m.weights[c]
+	}
+	return w
+}
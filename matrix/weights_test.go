@@ -0,0 +1,94 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var weightsBlob = `
+> morpho
+A 000000
+B 011010
+C 110101
+`
+
+func TestWeights(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(weightsBlob))
+	if err != nil {
+		t.Fatalf("matrix: weights: unexpected error while reading matrix: %v", err)
+	}
+	if m.Weights() != nil {
+		t.Errorf("matrix: weights: got %v, want nil", m.Weights())
+	}
+	if w := m.Weight(3); w != 1 {
+		t.Errorf("matrix: weights: weight %d, want %d", w, 1)
+	}
+
+	if err := m.SetWeights("2:1-5\\2; 0:6"); err != nil {
+		t.Fatalf("matrix: weights: unexpected error: %v", err)
+	}
+	want := []int{2, 1, 2, 1, 2, 0}
+	if !reflect.DeepEqual(m.Weights(), want) {
+		t.Errorf("matrix: weights: got %v, want %v", m.Weights(), want)
+	}
+	if n := m.Active(); n != 5 {
+		t.Errorf("matrix: weights: active %d, want %d", n, 5)
+	}
+
+	// weights are kept on derived matrices
+	d := m.DropChars([]int{0, 5})
+	if w := []int{1, 2, 1, 2}; !reflect.DeepEqual(d.Weights(), w) {
+		t.Errorf("matrix: weights: drop chars: got %v, want %v", d.Weights(), w)
+	}
+	r := m.Resample([]int{5, 0, 0})
+	if w := []int{2, 2, 0}; !reflect.DeepEqual(r.Weights(), w) {
+		t.Errorf("matrix: weights: resample: got %v, want %v", r.Weights(), w)
+	}
+	if dt := m.DropTaxa([]string{"B"}); !reflect.DeepEqual(dt.Weights(), want) {
+		t.Errorf("matrix: weights: drop taxa: got %v, want %v", dt.Weights(), want)
+	}
+
+	// changes on derived matrices
+	// are not made on the original
+	dt := m.DropTaxa([]string{"B"})
+	if err := dt.SetWeights("3:1"); err != nil {
+		t.Fatalf("matrix: weights: unexpected error: %v", err)
+	}
+	dt.Exclude([]int{2})
+	p := m.Permute()
+	if err := p.SetWeights("4:2"); err != nil {
+		t.Fatalf("matrix: weights: unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(m.Weights(), want) {
+		t.Errorf("matrix: weights: after changing a derived matrix: got %v, want %v", m.Weights(), want)
+	}
+	if m.IsExcluded(2) {
+		t.Errorf("matrix: weights: after changing a derived matrix: character 3 excluded")
+	}
+
+	if err := m.ReadWeights(strings.NewReader("# weights\n3: 2\n\n4:4\n")); err != nil {
+		t.Fatalf("matrix: weights: unexpected error: %v", err)
+	}
+	want = []int{2, 3, 2, 4, 2, 0}
+	if !reflect.DeepEqual(m.Weights(), want) {
+		t.Errorf("matrix: weights: got %v, want %v", m.Weights(), want)
+	}
+
+	if err := m.SetWeights("0:1-6"); err != nil {
+		t.Fatalf("matrix: weights: unexpected error: %v", err)
+	}
+	if n := m.Active(); n != 0 {
+		t.Errorf("matrix: weights: active %d, want %d", n, 0)
+	}
+
+	for _, def := range []string{"2 1-3", "-1:2", "a:2", "2:7"} {
+		if err := m.SetWeights(def); err == nil {
+			t.Errorf("matrix: weights: definition %q: expecting error", def)
+		}
+	}
+}
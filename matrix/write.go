@@ -0,0 +1,286 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// IupacCodes are the IUPAC symbols
+// of each DNA state set
+// (the unknown state is written as 'N').
+// The gap state is written as '-'.
+const iupacCodes = "-ACMGRSVTWYHKDBN"
+
+// StateString returns the string
+// of a state set of a data type,
+// using open and close as the delimiters
+// of a morphological,
+// or protein polymorphism.
+// If open is 0,
+// polymorphisms are not allowed.
+func stateString(kind DataType, st uint32, open, close byte) (string, error) {
+	if kind == DNA {
+		if st == Gap {
+			return "-", nil
+		}
+		// a set with the gap state
+		// is written as its nucleotides
+		st &^= Gap
+		if st == 0 || st > 15 {
+			return "", errors.Errorf("invalid state %d", st)
+		}
+		return string(iupacCodes[st]), nil
+	}
+	if kind == Protein {
+		switch st {
+		case Unknown(Protein):
+			return "X", nil
+		case 1<<2 | 1<<3:
+			return "B", nil
+		case 1<<5 | 1<<6:
+			return "Z", nil
+		case 1<<9 | 1<<10:
+			return "J", nil
+		}
+	}
+	if st == Unknown(kind) {
+		return "?", nil
+	}
+	if kind == Morphology && st&^Unknown(Morphology) != 0 {
+		return "", errors.Errorf("invalid state %d: only states 0-7 are supported", st)
+	}
+	var states []byte
+	for i := 0; i < 32; i++ {
+		if st&(1<<uint(i)) != 0 {
+			states = append(states, kind.Symbol(i)[0])
+		}
+	}
+	if len(states) == 0 {
+		return "", errors.Errorf("invalid state %d", st)
+	}
+	if len(states) == 1 {
+		return string(states), nil
+	}
+	if open == 0 {
+		return "", errors.New("polymorphism not supported")
+	}
+	return string(open) + string(states) + string(close), nil
+}
+
+// BlockString returns the characters of a block
+// of a terminal.
+// DNA gaps are written as '-'.
+func (m *Matrix) blockString(t *Terminal, b Block, open, close byte) (string, error) {
+	var gaps map[int]bool
+	if len(t.gaps) > 0 {
+		gaps = t.gapSet()
+	}
+	var sb strings.Builder
+	for c := b.Start; c < b.End; c++ {
+		if gaps[c] {
+			sb.WriteByte('-')
+			continue
+		}
+		s, err := stateString(b.Type, t.State(c), open, close)
+		if err != nil {
+			return "", errors.Wrapf(err, "terminal %s: char %d", t.Name, c+1)
+		}
+		sb.WriteString(s)
+	}
+	return sb.String(), nil
+}
+
+// NameWidth returns the length
+// of the longest terminal name,
+// plus one.
+func (m *Matrix) nameWidth() int {
+	w := 0
+	for _, t := range m.Taxa() {
+		if len(t.Name) > w {
+			w = len(t.Name)
+		}
+	}
+	return w + 1
+}
+
+// Write writes the matrix
+// in the native format of ramita
+// (see NewScanner).
+// Each block is written with its data type,
+// and its name.
+func (m *Matrix) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	width := m.nameWidth()
+	for _, b := range m.Blocks {
+		fmt.Fprintf(bw, "> %s %s\n", b.Type, b.Name)
+		for _, t := range m.Taxa() {
+			s, err := m.blockString(t, b, '[', ']')
+			if err != nil {
+				return errors.Wrap(err, "matrix: write")
+			}
+			fmt.Fprintf(bw, "%-*s %s\n", width, t.Name, s)
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return errors.Wrap(err, "matrix: write")
+	}
+	return nil
+}
+
+// WriteNexus writes the matrix
+// as a NEXUS file.
+// A matrix with a single block
+// is written as a DATA block,
+// otherwise,
+// the terminals are written in a TAXA block,
+// and each block of the matrix
+// is written as a CHARACTERS block.
+// Polymorphisms are written between parenthesis.
+func (m *Matrix) WriteNexus(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	width := m.nameWidth()
+	fmt.Fprintf(bw, "#NEXUS\n\n")
+	bname := "DATA"
+	if len(m.Blocks) > 1 {
+		bname = "CHARACTERS"
+		fmt.Fprintf(bw, "BEGIN TAXA;\n")
+		fmt.Fprintf(bw, "\tDIMENSIONS NTAX=%d;\n", len(m.Names))
+		fmt.Fprintf(bw, "\tTAXLABELS")
+		for _, t := range m.Taxa() {
+			fmt.Fprintf(bw, " %s", t.Name)
+		}
+		fmt.Fprintf(bw, ";\nEND;\n\n")
+	}
+	for _, b := range m.Blocks {
+		fmt.Fprintf(bw, "BEGIN %s;\n", bname)
+		fmt.Fprintf(bw, "\tTITLE %s;\n", b.Name)
+		fmt.Fprintf(bw, "\tDIMENSIONS NTAX=%d NCHAR=%d;\n", len(m.Names), b.Len())
+		switch b.Type {
+		case DNA:
+			fmt.Fprintf(bw, "\tFORMAT DATATYPE=DNA GAP=- MISSING=?;\n")
+		case Protein:
+			fmt.Fprintf(bw, "\tFORMAT DATATYPE=PROTEIN GAP=- MISSING=?;\n")
+		default:
+			fmt.Fprintf(bw, "\tFORMAT DATATYPE=STANDARD SYMBOLS=\"01234567\" GAP=- MISSING=?;\n")
+		}
+		fmt.Fprintf(bw, "\tMATRIX\n")
+		for _, t := range m.Taxa() {
+			s, err := m.blockString(t, b, '(', ')')
+			if err != nil {
+				return errors.Wrap(err, "matrix: nexus")
+			}
+			fmt.Fprintf(bw, "\t%-*s %s\n", width, t.Name, s)
+		}
+		fmt.Fprintf(bw, "\t;\nEND;\n\n")
+	}
+	if err := bw.Flush(); err != nil {
+		return errors.Wrap(err, "matrix: nexus")
+	}
+	return nil
+}
+
+// WritePhylip writes the matrix
+// as a relaxed sequential PHYLIP file.
+// All the characters of the matrix
+// must be of the same data type,
+// and polymorphic morphological characters
+// are not allowed.
+func (m *Matrix) WritePhylip(w io.Writer) error {
+	for _, k := range m.Kind {
+		if k != m.Kind[0] {
+			return errors.New("matrix: phylip: mixed data types not supported")
+		}
+	}
+	bw := bufio.NewWriter(w)
+	width := m.nameWidth()
+	fmt.Fprintf(bw, "%d %d\n", len(m.Names), len(m.Kind))
+	for _, t := range m.Taxa() {
+		fmt.Fprintf(bw, "%-*s ", width, t.Name)
+		for _, b := range m.Blocks {
+			s, err := m.blockString(t, b, 0, 0)
+			if err != nil {
+				return errors.Wrap(err, "matrix: phylip")
+			}
+			bw.WriteString(s)
+		}
+		fmt.Fprintf(bw, "\n")
+	}
+	if err := bw.Flush(); err != nil {
+		return errors.Wrap(err, "matrix: phylip")
+	}
+	return nil
+}
+
+// WriteFasta writes the matrix
+// as an aligned FASTA file.
+// Only matrices with DNA,
+// or protein characters
+// can be written as FASTA.
+func (m *Matrix) WriteFasta(w io.Writer) error {
+	for i, k := range m.Kind {
+		if k != DNA && k != Protein {
+			return errors.Errorf("matrix: fasta: character %d is %s, want %s or %s", i+1, k, DNA, Protein)
+		}
+		if k != m.Kind[0] {
+			return errors.New("matrix: fasta: mixed data types not supported")
+		}
+	}
+	bw := bufio.NewWriter(w)
+	for _, t := range m.Taxa() {
+		fmt.Fprintf(bw, ">%s\n", t.Name)
+		for _, b := range m.Blocks {
+			s, err := m.blockString(t, b, 0, 0)
+			if err != nil {
+				return errors.Wrap(err, "matrix: fasta")
+			}
+			bw.WriteString(s)
+		}
+		fmt.Fprintf(bw, "\n")
+	}
+	if err := bw.Flush(); err != nil {
+		return errors.Wrap(err, "matrix: fasta")
+	}
+	return nil
+}
+
+// WriteTNT writes the matrix
+// as the XREAD command of a TNT file.
+// Each block of the matrix
+// is written as a block of the XREAD command,
+// with its data type.
+// Polymorphisms are written between brackets.
+func (m *Matrix) WriteTNT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	width := m.nameWidth()
+	fmt.Fprintf(bw, "xread\n%d %d\n", len(m.Kind), len(m.Names))
+	for _, b := range m.Blocks {
+		tp := "num"
+		switch b.Type {
+		case DNA:
+			tp = "dna"
+		case Protein:
+			tp = "prot"
+		}
+		fmt.Fprintf(bw, "& [%s]\n", tp)
+		for _, t := range m.Taxa() {
+			s, err := m.blockString(t, b, '[', ']')
+			if err != nil {
+				return errors.Wrap(err, "matrix: tnt")
+			}
+			fmt.Fprintf(bw, "%-*s %s\n", width, t.Name, s)
+		}
+	}
+	fmt.Fprintf(bw, ";\nproc/;\n")
+	if err := bw.Flush(); err != nil {
+		return errors.Wrap(err, "matrix: tnt")
+	}
+	return nil
+}
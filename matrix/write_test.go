@@ -0,0 +1,183 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package matrix
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var writeBlob = `> morphology morpho
+Out  0000
+A    1[01]1?
+B    1121
+> dna coi
+Out  ACGTRN
+A    AC-TAC
+`
+
+// SameMatrix returns an error message
+// if two matrices are different.
+func sameMatrix(got, want *Matrix) string {
+	if got.Out.Name != want.Out.Name {
+		return "outgroup " + got.Out.Name + ", want " + want.Out.Name
+	}
+	if len(got.Names) != len(want.Names) {
+		return "different number of terminals"
+	}
+	if len(got.Blocks) != len(want.Blocks) {
+		return "different number of blocks"
+	}
+	for i, b := range want.Blocks {
+		gb := got.Blocks[i]
+		if gb.Type != b.Type || gb.Start != b.Start || gb.End != b.End {
+			return "block " + b.Name + ": different block"
+		}
+	}
+	for nm, t := range want.Names {
+		gt, ok := got.Names[nm]
+		if !ok {
+			return "terminal " + nm + " not found"
+		}
+		if !reflect.DeepEqual(gt.Unpack(), t.Unpack()) {
+			return "terminal " + nm + ": different characters"
+		}
+	}
+	return ""
+}
+
+func TestWrite(t *testing.T) {
+	m, err := NewMatrix(strings.NewReader(writeBlob))
+	if err != nil {
+		t.Fatalf("matrix: write: unexpected error: %v", err)
+	}
+	dna, err := NewMatrix(strings.NewReader(fastaBlob))
+	if err != nil {
+		t.Fatalf("matrix: write: unexpected error: %v", err)
+	}
+
+	prot, err := NewMatrix(strings.NewReader("> protein\nOut MKLVBZ\nA   MK[IV]-XJ\n"))
+	if err != nil {
+		t.Fatalf("matrix: write: unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		m     *Matrix
+		write func(w io.Writer) error
+	}{
+		{"native", m, m.Write},
+		{"nexus", m, m.WriteNexus},
+		{"tnt", m, m.WriteTNT},
+		{"nexus dna", dna, dna.WriteNexus},
+		{"phylip", dna, dna.WritePhylip},
+		{"fasta", dna, dna.WriteFasta},
+		{"native protein", prot, prot.Write},
+		{"nexus protein", prot, prot.WriteNexus},
+		{"tnt protein", prot, prot.WriteTNT},
+	}
+	for _, test := range tests {
+		var b bytes.Buffer
+		if err := test.write(&b); err != nil {
+			t.Errorf("matrix: write: %s: unexpected error: %v", test.name, err)
+			continue
+		}
+		got, err := NewMatrix(strings.NewReader(b.String()))
+		if err != nil {
+			t.Errorf("matrix: write: %s: unexpected error: %v\n%s", test.name, err, b.String())
+			continue
+		}
+		if msg := sameMatrix(got, test.m); msg != "" {
+			t.Errorf("matrix: write: %s: %s\n%s", test.name, msg, b.String())
+		}
+	}
+
+	var b bytes.Buffer
+	if err := m.WriteFasta(&b); err == nil {
+		t.Errorf("matrix: write: fasta: expecting error on morphological data")
+	}
+	if err := m.WritePhylip(&b); err == nil {
+		t.Errorf("matrix: write: phylip: expecting error on mixed data")
+	}
+
+	// states above 7 can not be read back
+	big, err := NewMatrix(strings.NewReader("> morphology\nOut 00\nA 11\n"))
+	if err != nil {
+		t.Fatalf("matrix: write: unexpected error: %v", err)
+	}
+	big.Names["A"].Chars[1] = 1 << 9
+	for name, write := range map[string]func(w io.Writer) error{
+		"native": big.Write,
+		"nexus":  big.WriteNexus,
+		"tnt":    big.WriteTNT,
+	} {
+		if err := write(&b); err == nil {
+			t.Errorf("matrix: write: %s: expecting error on state 9", name)
+		}
+	}
+
+	poly, err := NewMatrix(strings.NewReader("> morphology\nOut 00\nA [01]1\n"))
+	if err != nil {
+		t.Fatalf("matrix: write: unexpected error: %v", err)
+	}
+	if err := poly.WritePhylip(&b); err == nil {
+		t.Errorf("matrix: write: phylip: expecting error on polymorphism")
+	}
+}
+
+func TestWriteGaps(t *testing.T) {
+	blob := "> dna coi\nOut ACGT-N\nA   A--TRC\nB   -CG-AC\n"
+	for _, mode := range []GapMode{GapMissing, GapState} {
+		m, err := NewMatrix(strings.NewReader(blob))
+		if err != nil {
+			t.Fatalf("matrix: write: gaps: unexpected error: %v", err)
+		}
+		if err := m.SetGapMode(mode); err != nil {
+			t.Fatalf("matrix: write: gaps: unexpected error: %v", err)
+		}
+
+		tests := []struct {
+			name  string
+			write func(w io.Writer) error
+		}{
+			{"native", m.Write},
+			{"nexus", m.WriteNexus},
+			{"phylip", m.WritePhylip},
+			{"fasta", m.WriteFasta},
+			{"tnt", m.WriteTNT},
+		}
+		for _, test := range tests {
+			var b bytes.Buffer
+			if err := test.write(&b); err != nil {
+				t.Errorf("matrix: write: gaps: %s: %s: unexpected error: %v", mode, test.name, err)
+				continue
+			}
+			got, err := NewMatrix(strings.NewReader(b.String()))
+			if err != nil {
+				t.Errorf("matrix: write: gaps: %s: %s: unexpected error: %v\n%s", mode, test.name, err, b.String())
+				continue
+			}
+			for nm, tx := range m.Names {
+				gt := got.Names[nm]
+				if gt == nil {
+					t.Errorf("matrix: write: gaps: %s: %s: terminal %s not found", mode, test.name, nm)
+					continue
+				}
+				if !reflect.DeepEqual(gt.gaps, tx.gaps) {
+					t.Errorf("matrix: write: gaps: %s: %s: terminal %s: gaps %v, want %v", mode, test.name, nm, gt.gaps, tx.gaps)
+				}
+			}
+			if err := got.SetGapMode(mode); err != nil {
+				t.Fatalf("matrix: write: gaps: unexpected error: %v", err)
+			}
+			if msg := sameMatrix(got, m); msg != "" {
+				t.Errorf("matrix: write: gaps: %s: %s: %s\n%s", mode, test.name, msg, b.String())
+			}
+		}
+	}
+}
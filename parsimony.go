@@ -6,6 +6,19 @@ package main
 
 import (
 	// initialize parsimony sub-commands
+	_ "github.com/js-arias/ramita/internal/parsimony/allbin"
+	_ "github.com/js-arias/ramita/internal/parsimony/bandb"
+	_ "github.com/js-arias/ramita/internal/parsimony/boot"
+	_ "github.com/js-arias/ramita/internal/parsimony/bremer"
+	_ "github.com/js-arias/ramita/internal/parsimony/cons"
+	_ "github.com/js-arias/ramita/internal/parsimony/g1"
+	_ "github.com/js-arias/ramita/internal/parsimony/lba"
 	_ "github.com/js-arias/ramita/internal/parsimony/lencmd"
+	_ "github.com/js-arias/ramita/internal/parsimony/mapcmd"
+	_ "github.com/js-arias/ramita/internal/parsimony/ptp"
+	_ "github.com/js-arias/ramita/internal/parsimony/rogue"
+	_ "github.com/js-arias/ramita/internal/parsimony/sitedel"
+	_ "github.com/js-arias/ramita/internal/parsimony/taxjack"
 	_ "github.com/js-arias/ramita/internal/parsimony/wagday"
+	_ "github.com/js-arias/ramita/internal/parsimony/window"
 )
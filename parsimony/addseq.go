@@ -0,0 +1,174 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"math/rand"
+	"sort"
+
+	"github.com/js-arias/ramita/matrix"
+
+	"github.com/pkg/errors"
+)
+
+// An Addition is the sequence
+// used to add the terminals
+// to a Wagner tree.
+type Addition int
+
+// Addition sequences.
+const (
+	// Random order.
+	Random Addition = iota
+
+	// Terminals are added in the order of the matrix,
+	// i.e. sorted by name.
+	AsIs
+
+	// At each step,
+	// the terminal that produces
+	// the smallest increase in length
+	// is added.
+	Closest
+
+	// At each step,
+	// the terminal whose minimum increase in length
+	// is the largest is added,
+	// i.e. the max-mini sequence of Farris.
+	MaxMini
+)
+
+// String returns the name of an addition sequence.
+func (a Addition) String() string {
+	switch a {
+	case Random:
+		return "random"
+	case AsIs:
+		return "asis"
+	case Closest:
+		return "closest"
+	case MaxMini:
+		return "maxmini"
+	}
+	return "unknown"
+}
+
+// ParseAddition returns an addition sequence
+// from its name.
+func ParseAddition(name string) (Addition, error) {
+	switch name {
+	case "random":
+		return Random, nil
+	case "asis":
+		return AsIs, nil
+	case "closest":
+		return Closest, nil
+	case "maxmini":
+		return MaxMini, nil
+	}
+	return 0, errors.Errorf("parsimony: unknown addition sequence %q", name)
+}
+
+// RandomOrder returns the terminals
+// in a random order.
+func randomOrder(terms []*matrix.Terminal) []*matrix.Terminal {
+	keys := make(map[int]*matrix.Terminal, len(terms))
+	ls := make([]int, 0, len(terms))
+	for _, t := range terms {
+		v := rand.Int()
+		ls = append(ls, v)
+		keys[v] = t
+	}
+	sort.Ints(ls)
+	order := make([]*matrix.Terminal, 0, len(terms))
+	for _, v := range ls {
+		order = append(order, keys[v])
+	}
+	return order
+}
+
+// FirstPair returns the terminals
+// with the first two terminals
+// selected using the addition sequence.
+// The first terminal is selected
+// by its distance to the outgroup,
+// and the second one
+// by the length of the three terminal tree.
+func firstPair(out *matrix.Terminal, terms []*matrix.Terminal, seq Addition) []*matrix.Terminal {
+	order := append([]*matrix.Terminal{}, terms...)
+	n := out.Len()
+
+	best, bc := 0, -1
+	for i, t := range order {
+		c := 0
+		for j := 0; j < n; j++ {
+			if t.State(j)&out.State(j) == 0 {
+				c++
+			}
+		}
+		if bc < 0 || better(c, bc, seq) {
+			best, bc = i, c
+		}
+	}
+	order[0], order[best] = order[best], order[0]
+
+	first := order[0]
+	best, bc = 1, -1
+	for i, t := range order[1:] {
+		c := 0
+		for j := 0; j < n; j++ {
+			s, f := t.State(j), first.State(j)
+			v := s & f
+			if v == 0 {
+				v = s | f
+				c++
+			}
+			if v&out.State(j) == 0 {
+				c++
+			}
+		}
+		if bc < 0 || better(c, bc, seq) {
+			best, bc = i+1, c
+		}
+	}
+	order[1], order[best] = order[best], order[1]
+	return order
+}
+
+// AddBySeq adds the terminals to the tree,
+// selecting at each step
+// the terminal to be added
+// using the addition sequence.
+// It returns the terminals
+// in the order in which they were added.
+func (tr *Tree) addBySeq(terms []*matrix.Terminal, seq Addition) []*matrix.Terminal {
+	terms = append([]*matrix.Terminal{}, terms...)
+	order := make([]*matrix.Terminal, 0, len(terms))
+	for len(terms) > 0 {
+		var na, pos *Node
+		best, bc := 0, -1
+		for i, t := range terms {
+			n, c, p := tr.bestPos(t)
+			if bc < 0 || better(c, bc, seq) {
+				best, bc = i, c
+				na, pos = n, p
+			}
+		}
+		tr.insert(na, pos)
+		order = append(order, terms[best])
+		terms = append(terms[:best], terms[best+1:]...)
+	}
+	return order
+}
+
+// Better returns true
+// if the cost c is better than the cost b,
+// using the criterion of the addition sequence.
+func better(c, b int, seq Addition) bool {
+	if seq == MaxMini {
+		return c > b
+	}
+	return c < b
+}
@@ -0,0 +1,55 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/matrix"
+)
+
+func TestWagnerSeq(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("parsimony: wagner seq: unexpected error while reading matrix: %v", err)
+	}
+	for _, seq := range []Addition{Random, AsIs, Closest, MaxMini} {
+		tr := WagnerSeq(m, seq)
+		added := make(map[string]bool)
+		if nt := checkTerminals(t, tr.Root, added); nt != len(m.Names) {
+			t.Errorf("parsimony: wagner seq: %s: tree size %d terminals, want %d", seq, nt, len(m.Names))
+		}
+		cp, err := FromTopology(tr.Topology(), m)
+		if err != nil {
+			t.Fatalf("parsimony: wagner seq: %s: unexpected error: %v", seq, err)
+		}
+		if cp.Cost() != tr.Cost() {
+			t.Errorf("parsimony: wagner seq: %s: cost %d, want %d", seq, tr.Cost(), cp.Cost())
+		}
+		if seq == Random {
+			continue
+		}
+
+		// other sequences are deterministic
+		ot := WagnerSeq(m, seq)
+		if ot.Topology().Canonical() != tr.Topology().Canonical() {
+			t.Errorf("parsimony: wagner seq: %s: different trees", seq)
+		}
+	}
+
+	for _, seq := range []Addition{Random, AsIs, Closest, MaxMini} {
+		a, err := ParseAddition(seq.String())
+		if err != nil {
+			t.Errorf("parsimony: parse addition: unexpected error: %v", err)
+		}
+		if a != seq {
+			t.Errorf("parsimony: parse addition: got %s, want %s", a, seq)
+		}
+	}
+	if _, err := ParseAddition("unknown"); err == nil {
+		t.Errorf("parsimony: parse addition: expecting error")
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import "github.com/js-arias/ramita/matrix"
+
+// BranchAndBound makes an exact search
+// with the branch and bound algorithm,
+// and returns the set
+// with all the most parsimonious trees.
+//
+// The terminals are added to a growing tree
+// in all possible positions,
+// following a maxmini addition sequence,
+// and a partial tree is discarded
+// as soon as its cost,
+// evaluated with an incremental down-pass,
+// is greater than the cost of the best tree found.
+// The initial bound is the cost
+// of a Wagner tree improved with SPR.
+//
+// As the number of trees grows exponentially,
+// it should be used only with small matrices
+// (about 20 terminals or less).
+func BranchAndBound(m *matrix.Matrix) *TreeSet {
+	var terms []*matrix.Terminal
+	for _, t := range m.Taxa() {
+		if t == m.Out {
+			continue
+		}
+		terms = append(terms, t)
+	}
+	terms = firstPair(m.Out, terms, MaxMini)
+
+	// the terminals that increase the most
+	// the length of the tree
+	// are added first,
+	// so the bound is effective
+	// early in the search
+	mt := startTree(m, terms[0], terms[1])
+	order := mt.addBySeq(terms[2:], MaxMini)
+
+	ts := &TreeSet{}
+	wt := WagnerSeq(m, Closest)
+	wt.Dayoff()
+	ts.Add(wt)
+
+	tr := startTree(m, terms[0], terms[1])
+	tr.bandb(order, ts)
+	return ts
+}
+
+// Bandb adds the first terminal of the list
+// at each position of the tree
+// in which the cost is not greater
+// than the cost of the tree set,
+// and then adds the remaining terminals.
+// When all the terminals are added,
+// the tree is stored in the tree set.
+func (tr *Tree) bandb(terms []*matrix.Terminal, ts *TreeSet) {
+	if len(terms) == 0 {
+		ts.Add(tr)
+		return
+	}
+
+	tm := terms[0]
+	na := &Node{
+		Chars:     make([]uint32, tm.Len()),
+		charsCopy: make([]uint32, tm.Len()),
+	}
+	nt := &Node{
+		Anc:   na,
+		Term:  tm,
+		Chars: tm.Chars,
+	}
+	na.Left = nt
+
+	// the list of nodes grows
+	// when the terminals are added
+	pos := append([]*Node{}, tr.Nodes[2:]...)
+	for _, d := range pos {
+		a := d.Anc
+		na.Anc = a
+		na.Right = d
+		d.Anc = na
+		if a.Left == d {
+			a.Left = na
+		} else {
+			a.Right = na
+		}
+
+		cost, stop := increBound(na, ts.Cost(), tr.w)
+		added := cost <= ts.Cost()
+		if added {
+			for x := na; x != nil; x = x.Anc {
+				x.save()
+			}
+			tr.Nodes = append(tr.Nodes, na, nt)
+			tr.bandb(terms[1:], ts)
+			tr.Nodes = tr.Nodes[:len(tr.Nodes)-2]
+		}
+
+		// Restore the position
+		if a.Left == na {
+			a.Left = d
+		} else {
+			a.Right = d
+		}
+		d.Anc = a
+
+		// Restore the assignations
+		if added {
+			increDown(a, tr.w)
+			for x := a; x != nil; x = x.Anc {
+				x.save()
+			}
+			continue
+		}
+		for x := a; x != nil; x = x.Anc {
+			x.restore()
+			if x == stop {
+				break
+			}
+		}
+	}
+	na.Anc = nil
+	na.Right = nil
+}
@@ -0,0 +1,132 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/matrix"
+)
+
+func TestBranchAndBound(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(flatBlob))
+	if err != nil {
+		t.Fatalf("parsimony: branch and bound: unexpected error while reading matrix: %v", err)
+	}
+	ts := BranchAndBound(m)
+	if ts.Cost() != 4 {
+		t.Errorf("parsimony: branch and bound: cost %d, want %d", ts.Cost(), 4)
+	}
+	// all the rooted trees of 4 terminals
+	if ts.Len() != 15 {
+		t.Errorf("parsimony: branch and bound: %d trees, want %d", ts.Len(), 15)
+	}
+
+	m, err = matrix.NewMatrix(strings.NewReader(resolvedBlob))
+	if err != nil {
+		t.Fatalf("parsimony: branch and bound: unexpected error while reading matrix: %v", err)
+	}
+	ts = BranchAndBound(m)
+	if ts.Cost() != 8 || ts.Len() != 1 {
+		t.Errorf("parsimony: branch and bound: %d trees of cost %d, want 1 tree of cost %d", ts.Len(), ts.Cost(), 8)
+	}
+
+	// compare with an exhaustive search
+	m, err = matrix.NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("parsimony: branch and bound: unexpected error while reading matrix: %v", err)
+	}
+	var drop, names []string
+	for _, tm := range m.Taxa() {
+		if tm == m.Out {
+			continue
+		}
+		if len(names) < 6 {
+			names = append(names, tm.Name)
+			continue
+		}
+		drop = append(drop, tm.Name)
+	}
+	m = m.DropTaxa(drop)
+	ts = BranchAndBound(m)
+
+	best := -1
+	var bestTrees []string
+	for _, tp := range allTrees(names) {
+		tr, err := ReadTree(strings.NewReader("("+m.Out.Name+","+tp.String()+");"), m)
+		if err != nil {
+			t.Fatalf("parsimony: branch and bound: unexpected error: %v", err)
+		}
+		if best >= 0 && tr.Cost() > best {
+			continue
+		}
+		if best < 0 || tr.Cost() < best {
+			best = tr.Cost()
+			bestTrees = nil
+		}
+		bestTrees = append(bestTrees, tr.Topology().Canonical())
+	}
+	if ts.Cost() != best {
+		t.Errorf("parsimony: branch and bound: cost %d, want %d", ts.Cost(), best)
+	}
+	if ts.Len() != len(bestTrees) {
+		t.Errorf("parsimony: branch and bound: %d trees, want %d", ts.Len(), len(bestTrees))
+	}
+	found := make(map[string]bool)
+	for _, tp := range ts.Trees() {
+		found[tp.Canonical()] = true
+	}
+	for _, k := range bestTrees {
+		if !found[k] {
+			t.Errorf("parsimony: branch and bound: tree %q not found", k)
+		}
+	}
+}
+
+// A bNode is a node of a rooted binary tree
+// used to enumerate all the trees.
+type bNode struct {
+	left, right *bNode
+	name        string
+}
+
+func (n *bNode) String() string {
+	if n.name != "" {
+		return n.name
+	}
+	return "(" + n.left.String() + "," + n.right.String() + ")"
+}
+
+// AllTrees returns all the rooted binary trees
+// of a list of terminals.
+func allTrees(names []string) []*bNode {
+	if len(names) == 1 {
+		return []*bNode{{name: names[0]}}
+	}
+	leaf := &bNode{name: names[len(names)-1]}
+	var trees []*bNode
+	for _, t := range allTrees(names[:len(names)-1]) {
+		trees = append(trees, insertAll(t, leaf)...)
+	}
+	return trees
+}
+
+// InsertAll returns the trees
+// made by adding a terminal
+// in each branch of a tree.
+func insertAll(n, leaf *bNode) []*bNode {
+	trees := []*bNode{{left: n, right: leaf}}
+	if n.name != "" {
+		return trees
+	}
+	for _, x := range insertAll(n.left, leaf) {
+		trees = append(trees, &bNode{left: x, right: n.right})
+	}
+	for _, x := range insertAll(n.right, leaf) {
+		trees = append(trees, &bNode{left: n.left, right: x})
+	}
+	return trees
+}
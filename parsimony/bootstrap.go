@@ -0,0 +1,57 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"math/rand"
+
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/tree"
+)
+
+// A SearchFunc is a tree search
+// that returns the best tree found
+// for a matrix.
+type SearchFunc func(m *matrix.Matrix) *Tree
+
+// WagnerSPR is a SearchFunc
+// that builds a Wagner tree
+// with a random addition sequence,
+// and improves it with SPR branch swapping.
+func WagnerSPR(m *matrix.Matrix) *Tree {
+	tr := Wagner(m)
+	tr.Dayoff()
+	return tr
+}
+
+// Bootstrap performs a non-parametric bootstrap,
+// and returns the topology of the tree
+// found in each replicate.
+//
+// In each replicate,
+// the characters are resampled with replacement,
+// and the resampled matrix,
+// compressed into site patterns
+// (see matrix.Matrix.Compress),
+// is searched with the given search function.
+// If search is nil,
+// WagnerSPR is used.
+func Bootstrap(m *matrix.Matrix, replicates int, search SearchFunc) []*tree.Tree {
+	if search == nil {
+		search = WagnerSPR
+	}
+	chars := len(m.Kind)
+	trees := make([]*tree.Tree, 0, replicates)
+	sel := make([]int, chars)
+	for i := 0; i < replicates; i++ {
+		for j := range sel {
+			sel[j] = rand.Intn(chars)
+		}
+		rm, _ := m.Resample(sel).Compress(nil)
+		tr := search(rm)
+		trees = append(trees, tr.Topology())
+	}
+	return trees
+}
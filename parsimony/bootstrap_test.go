@@ -0,0 +1,42 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/matrix"
+)
+
+func TestBootstrap(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(resolvedBlob))
+	if err != nil {
+		t.Fatalf("parsimony: bootstrap: unexpected error while reading matrix: %v", err)
+	}
+	calls := 0
+	search := func(rm *matrix.Matrix) *Tree {
+		calls++
+		// replicates are compressed
+		// so each character is a site pattern
+		n := 0
+		for i := range rm.Kind {
+			n += rm.Count(i)
+		}
+		if n != len(m.Kind) {
+			t.Errorf("parsimony: bootstrap: %d characters, want %d", n, len(m.Kind))
+		}
+		return WagnerSPR(rm)
+	}
+	trees := Bootstrap(m, 10, search)
+	if len(trees) != 10 || calls != 10 {
+		t.Fatalf("parsimony: bootstrap: %d trees, %d searches, want %d", len(trees), calls, 10)
+	}
+	for _, tp := range trees {
+		if len(tp.Terms()) != len(m.Names) {
+			t.Errorf("parsimony: bootstrap: tree with %d terminals, want %d", len(tp.Terms()), len(m.Names))
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/tree"
+)
+
+// A Support is the support value
+// of a clade.
+type Support struct {
+	Clade []string // terminals of the clade, sorted by name
+	Value int
+
+	// Lower is true
+	// if the value is a lower bound,
+	// i.e. the clade is found
+	// in all the trees used to estimate it.
+	Lower bool
+}
+
+// Bremer returns the Bremer support
+// (or decay index)
+// of each clade of a tree,
+// using the trees of a tree set
+// (usually with suboptimal trees,
+// see Suboptimal).
+//
+// The support of a clade
+// is the difference between the cost
+// of the shortest tree of the set
+// without the clade,
+// and the best cost of the set.
+// If the clade is found in all the trees of the set,
+// the support is reported as the slack of the set
+// plus one,
+// and it is marked as a lower bound.
+func Bremer(t *tree.Tree, ts *TreeSet) []Support {
+	var sup []Support
+	for _, cl := range t.Clades() {
+		s := Support{
+			Clade: cl,
+			Value: ts.Slack + 1,
+			Lower: true,
+		}
+		for i, st := range ts.Trees() {
+			if st.HasClade(cl) {
+				continue
+			}
+			if v := ts.Costs()[i] - ts.Cost(); s.Lower || v < s.Value {
+				s.Value = v
+				s.Lower = false
+			}
+		}
+		sup = append(sup, s)
+	}
+	return sup
+}
+
+// Suboptimal fills a tree set
+// with the trees that are found
+// by SPR rearrangements of the trees in the set,
+// with a cost not worse than
+// the best cost plus the slack of the set.
+//
+// Each tree of the set is swapped once,
+// and the search continues
+// until all the trees of the set are swapped,
+// or the limit is reached.
+// It returns false
+// if the search was stopped by the limit.
+func (ts *TreeSet) Suboptimal(m *matrix.Matrix, lim *Limit) (bool, error) {
+	done := make(map[*tree.Tree]bool)
+	for !lim.Done() {
+		var next *tree.Tree
+		for _, t := range ts.trees {
+			if !done[t] {
+				done[t] = true
+				next = t
+				break
+			}
+		}
+		if next == nil {
+			return true, nil
+		}
+		tr, err := FromTopology(next, m)
+		if err != nil {
+			return false, err
+		}
+		tr.spread(ts, lim)
+	}
+	return false, nil
+}
+
+// Spread evaluates all the SPR rearrangements
+// of a tree,
+// and stores in the tree set
+// the trees that are not worse than
+// the best cost plus the slack of the set.
+// The tree is not modified.
+// If lim is not nil,
+// the evaluation stops when the limit is reached.
+func (tr *Tree) spread(ts *TreeSet, lim *Limit) {
+	for _, n := range tr.Nodes {
+		n.save()
+	}
+	for _, n := range tr.Nodes {
+		if lim.Done() {
+			return
+		}
+		if n == tr.Root || n.Anc == tr.Root {
+			continue
+		}
+
+		// removes the node
+		a := n.Anc
+		sis := a.Left
+		if sis == n {
+			sis = a.Right
+		}
+		a.Left = n
+		a.Right = nil
+
+		gf := a.Anc
+		unc := gf.Left
+		if unc == a {
+			unc = gf.Right
+		}
+		gf.Left = unc
+		gf.Right = sis
+		a.Anc = nil
+		sis.Anc = gf
+
+		increDown(gf, tr.w)
+		for x := gf; x != nil; x = x.Anc {
+			x.save()
+		}
+
+		// test positions of the node
+		for _, p := range tr.Nodes {
+			if p == tr.Root || p.IsDesc(a) {
+				continue
+			}
+			if p == sis {
+				continue
+			}
+			if p.Anc == tr.Root {
+				continue
+			}
+
+			pa := p.Anc
+			psis := pa.Left
+			if psis == p {
+				psis = pa.Right
+			}
+			pa.Left = psis
+			pa.Right = a
+			p.Anc = a
+			a.Right = p
+			a.Anc = pa
+
+			_, stop := increBound(a, ts.Cost()+ts.Slack, tr.w)
+			if stop == nil {
+				ts.Add(tr)
+			}
+
+			// restore positions
+			p.Anc = pa
+			pa.Right = p
+			a.Anc = nil
+			a.Right = nil
+
+			// Restore assignations
+			for x := p; x != nil; x = x.Anc {
+				x.restore()
+				if x == stop {
+					break
+				}
+			}
+			if lim.step() {
+				break
+			}
+		}
+
+		// restore the node
+		sis.Anc = a
+		a.Right = sis
+		a.Anc = gf
+		gf.Left = unc
+		gf.Right = a
+		a.restore()
+		increDown(gf, tr.w)
+		for x := gf; x != nil; x = x.Anc {
+			x.save()
+		}
+	}
+}
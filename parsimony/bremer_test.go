@@ -0,0 +1,84 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/tree"
+)
+
+var bremerBlob = `
+> morpho
+Out 000
+A   110
+B   110
+C   001
+D   001
+`
+
+func TestBremer(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(bremerBlob))
+	if err != nil {
+		t.Fatalf("parsimony: bremer: unexpected error while reading matrix: %v", err)
+	}
+	tp, err := tree.Read(strings.NewReader("(Out,((A,B),(C,D)));"))
+	if err != nil {
+		t.Fatalf("parsimony: bremer: unexpected error while reading tree: %v", err)
+	}
+	tr, err := FromTopology(tp, m)
+	if err != nil {
+		t.Fatalf("parsimony: bremer: unexpected error: %v", err)
+	}
+
+	ts := &TreeSet{Slack: 3}
+	ts.Add(tr)
+	ok, err := ts.Suboptimal(m, nil)
+	if err != nil {
+		t.Fatalf("parsimony: bremer: unexpected error: %v", err)
+	}
+	if !ok {
+		t.Errorf("parsimony: bremer: search stopped without a limit")
+	}
+	// all rooted trees of 4 ingroup terminals
+	if ts.Len() != 15 {
+		t.Errorf("parsimony: bremer: %d trees, want %d", ts.Len(), 15)
+	}
+	for i, c := range ts.Costs() {
+		pt, err := FromTopology(ts.Trees()[i], m)
+		if err != nil {
+			t.Fatalf("parsimony: bremer: unexpected error: %v", err)
+		}
+		if pt.Cost() != c {
+			t.Errorf("parsimony: bremer: tree %d: cost %d, want %d", i, c, pt.Cost())
+		}
+	}
+
+	want := map[string]int{
+		"A B":     2,
+		"C D":     1,
+		"A B C D": 4,
+	}
+	for _, s := range Bremer(tp, ts) {
+		cl := strings.Join(s.Clade, " ")
+		if s.Value != want[cl] {
+			t.Errorf("parsimony: bremer: clade %s: support %d, want %d", cl, s.Value, want[cl])
+		}
+		if s.Lower != (cl == "A B C D") {
+			t.Errorf("parsimony: bremer: clade %s: lower bound %v", cl, s.Lower)
+		}
+	}
+
+	ts = &TreeSet{Slack: 1}
+	ts.Add(tr)
+	ts.Suboptimal(m, nil)
+	for _, c := range ts.Costs() {
+		if c > ts.Cost()+1 {
+			t.Errorf("parsimony: bremer: tree with cost %d, want at most %d", c, ts.Cost()+1)
+		}
+	}
+}
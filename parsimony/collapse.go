@@ -0,0 +1,98 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/tree"
+)
+
+// Collapse returns the topology of the tree
+// in which the internal branches
+// without unambiguous support
+// (i.e. with a minimum length of zero)
+// are collapsed into polytomies,
+// as the "rule 1" of TNT.
+// A branch has a minimum length of zero
+// if in each character,
+// the final state sets of the node
+// and its ancestor share a state.
+// Characters with weight 0 are ignored.
+func (t *Tree) Collapse() *tree.Tree {
+	sets := t.finalSets()
+	used := make([]bool, len(t.Root.Chars))
+	for i := range used {
+		used[i] = t.w.weight(i) > 0
+	}
+	if t.w != nil {
+		for _, sc := range t.w.steps {
+			used[sc.char] = sc.w > 0
+		}
+	}
+
+	root := &tree.Node{}
+	root.Add(t.Root.Left.collapse(sets, used))
+	root.Add(t.Root.Right.collapse(sets, used))
+	return &tree.Tree{Root: root}
+}
+
+// Collapse returns the topology of a node
+// and its descendants,
+// with the descendant branches
+// of minimum length zero
+// collapsed.
+func (n *Node) collapse(sets map[*Node][]uint32, used []bool) *tree.Node {
+	if n.Term != nil {
+		return &tree.Node{Name: n.Term.Name}
+	}
+	tn := &tree.Node{}
+	for _, d := range []*Node{n.Left, n.Right} {
+		dn := d.collapse(sets, used)
+		if d.Term != nil || !d.isZero(sets, used) {
+			tn.Add(dn)
+			continue
+		}
+		for _, x := range dn.Desc {
+			tn.Add(x)
+		}
+	}
+	return tn
+}
+
+// IsZero returns true
+// if the branch of the node
+// has a minimum length of zero.
+func (n *Node) isZero(sets map[*Node][]uint32, used []bool) bool {
+	f, a := sets[n], sets[n.Anc]
+	for i, u := range used {
+		if !u {
+			continue
+		}
+		if f[i]&a[i] == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// CollapseSet returns the collapsed topologies
+// of the trees of a tree set,
+// removing the duplicated topologies.
+func CollapseSet(ts *TreeSet, m *matrix.Matrix) ([]*tree.Tree, error) {
+	var uniq tree.Unique
+	var trees []*tree.Tree
+	for _, tp := range ts.Trees() {
+		tr, err := FromTopology(tp, m)
+		if err != nil {
+			return nil, err
+		}
+		ct := tr.Collapse()
+		if !uniq.Add(ct) {
+			continue
+		}
+		trees = append(trees, ct)
+	}
+	return trees, nil
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/matrix"
+)
+
+var collapseBlob = `
+> morpho
+Out 0000
+A   1100
+B   1100
+C   1011
+D   1010
+E   1010
+`
+
+func TestCollapse(t *testing.T) {
+	tests := []struct {
+		name    string
+		weights string
+		clades  []string
+	}{
+		{"unweighted", "", []string{"A B", "C D E"}},
+		{"excluded character", "0:2", []string{"C D E"}},
+	}
+	for _, test := range tests {
+		m, err := matrix.NewMatrix(strings.NewReader(collapseBlob))
+		if err != nil {
+			t.Fatalf("parsimony: collapse: unexpected error while reading matrix: %v", err)
+		}
+		if err := m.SetWeights(test.weights); err != nil {
+			t.Fatalf("parsimony: collapse: %s: unexpected error: %v", test.name, err)
+		}
+		tr, err := ReadTree(strings.NewReader("(Out,((A,B),((C,D),E)));"), m)
+		if err != nil {
+			t.Fatalf("parsimony: collapse: %s: unexpected error while reading tree: %v", test.name, err)
+		}
+		ct := tr.Collapse()
+		want := append([]string{"A B C D E"}, test.clades...)
+		cl := ct.Clades()
+		if len(cl) != len(want) {
+			t.Errorf("parsimony: collapse: %s: %d clades, want %d", test.name, len(cl), len(want))
+		}
+		for _, w := range want {
+			if !ct.HasClade(strings.Fields(w)) {
+				t.Errorf("parsimony: collapse: %s: clade %q not found", test.name, w)
+			}
+		}
+	}
+
+	// two trees that are identical after collapsing
+	m, err := matrix.NewMatrix(strings.NewReader(collapseBlob))
+	if err != nil {
+		t.Fatalf("parsimony: collapse: unexpected error while reading matrix: %v", err)
+	}
+	ts := &TreeSet{}
+	for _, s := range []string{"(Out,((A,B),((C,D),E)));", "(Out,((A,B),((C,E),D)));"} {
+		tr, err := ReadTree(strings.NewReader(s), m)
+		if err != nil {
+			t.Fatalf("parsimony: collapse: unexpected error while reading tree: %v", err)
+		}
+		ts.Add(tr)
+	}
+	if ts.Len() != 2 {
+		t.Fatalf("parsimony: collapse: %d trees in set, want %d", ts.Len(), 2)
+	}
+	trees, err := CollapseSet(ts, m)
+	if err != nil {
+		t.Fatalf("parsimony: collapse: unexpected error: %v", err)
+	}
+	if len(trees) != 1 {
+		t.Errorf("parsimony: collapse: %d collapsed trees, want %d", len(trees), 1)
+	}
+}
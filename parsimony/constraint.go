@@ -0,0 +1,279 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"sort"
+
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+// A Constraint is a topological constraint
+// for a parsimony search.
+//
+// In a positive constraint,
+// the trees must have all the clades
+// of the constraint tree.
+// In a negative constraint,
+// the trees must not have all the clades
+// of the constraint tree,
+// (e.g. with a single clade,
+// the clade must not be in the tree).
+//
+// Terminals not in the constraint tree
+// are not constrained,
+// so they can be placed anywhere in the tree.
+// A constraint can also be defined
+// by a list of clade definitions,
+// in that case,
+// all the terminals are constrained.
+type Constraint struct {
+	negative bool
+	out      string         // outgroup of the matrix
+	index    map[string]int // index of the constrained terminals
+	clades   []bitField     // constrained clades
+	words    int            // size of the bit fields
+}
+
+// A bitField is a set of terminals.
+type bitField []uint64
+
+func (b bitField) set(i int) {
+	b[i/64] |= 1 << uint(i%64)
+}
+
+func (b bitField) or(c bitField) {
+	for i := range b {
+		b[i] |= c[i]
+	}
+}
+
+func (b bitField) count() int {
+	n := 0
+	for _, w := range b {
+		for ; w != 0; w &= w - 1 {
+			n++
+		}
+	}
+	return n
+}
+
+func (b bitField) equal(c bitField) bool {
+	for i := range b {
+		if b[i] != c[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// NewConstraint returns a new constraint
+// from a constraint tree.
+// If the outgroup of the matrix
+// is in the constraint tree,
+// the clades are taken as if the tree
+// were rooted at the outgroup,
+// otherwise the rooting of the constraint tree
+// is used.
+// The constraint tree can have polytomies.
+func NewConstraint(t *tree.Tree, m *matrix.Matrix, negative bool) (*Constraint, error) {
+	c, err := newConstraint(t.Terms(), m, negative)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range t.Nodes() {
+		if n.IsTerm() || n == t.Root {
+			continue
+		}
+		c.add(n.Terms())
+	}
+	if len(c.clades) == 0 {
+		return nil, errors.New("parsimony: constraint: tree without informative clades")
+	}
+	return c, nil
+}
+
+// NewCladeConstraint returns a new constraint
+// from a list of clade definitions.
+// All the terminals of the matrix
+// are constrained,
+// so in a positive constraint,
+// each clade must have only
+// the terminals of its definition.
+// Clades that include the outgroup
+// are taken as if the tree
+// were rooted at the outgroup.
+func NewCladeConstraint(defs []tree.CladeDef, m *matrix.Matrix, negative bool) (*Constraint, error) {
+	names := make([]string, 0, len(m.Names))
+	for nm := range m.Names {
+		names = append(names, nm)
+	}
+	sort.Strings(names)
+	c, err := newConstraint(names, m, negative)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range defs {
+		for _, nm := range d.Terms {
+			if _, ok := c.index[nm]; !ok {
+				return nil, errors.Errorf("parsimony: constraint: clade %s: terminal %s not in matrix", d.Name, nm)
+			}
+		}
+		c.add(d.Terms)
+	}
+	if len(c.clades) == 0 {
+		return nil, errors.New("parsimony: constraint: definitions without informative clades")
+	}
+	return c, nil
+}
+
+// NewConstraint returns an empty constraint
+// with the indicated terminals.
+func newConstraint(terms []string, m *matrix.Matrix, negative bool) (*Constraint, error) {
+	c := &Constraint{
+		negative: negative,
+		out:      m.Out.Name,
+		index:    make(map[string]int),
+	}
+	for _, nm := range terms {
+		if m.Names[nm] == nil {
+			return nil, errors.Errorf("parsimony: constraint: terminal %s not in matrix", nm)
+		}
+		if _, ok := c.index[nm]; ok {
+			return nil, errors.Errorf("parsimony: constraint: terminal %s repeated", nm)
+		}
+		c.index[nm] = len(c.index)
+	}
+	c.words = (len(c.index) + 63) / 64
+	return c, nil
+}
+
+// Add adds a clade to the constraint,
+// if the clade is informative,
+// and it is not already in the constraint.
+// The terminals must be in the constraint.
+func (c *Constraint) add(terms []string) {
+	all := c.newField()
+	for _, i := range c.index {
+		all.set(i)
+	}
+	out, hasOut := c.index[c.out]
+	in := len(c.index)
+	if hasOut {
+		in--
+	}
+
+	cl := c.newField()
+	for _, nm := range terms {
+		cl.set(c.index[nm])
+	}
+	if hasOut && cl[out/64]&(1<<uint(out%64)) != 0 {
+		// use the clade without the outgroup
+		for i := range cl {
+			cl[i] = ^cl[i] & all[i]
+		}
+	}
+	if k := cl.count(); k < 2 || k >= in {
+		return
+	}
+	for _, o := range c.clades {
+		if o.equal(cl) {
+			return
+		}
+	}
+	c.clades = append(c.clades, cl)
+}
+
+// NewField returns an empty bit field.
+func (c *Constraint) newField() bitField {
+	return make(bitField, c.words)
+}
+
+// Fits returns true
+// if the tree fulfills the constraint.
+// The tree can be incomplete
+// (e.g. during the addition of terminals).
+func (tr *Tree) fits() bool {
+	c := tr.c
+	if c == nil {
+		return true
+	}
+
+	// terminals of each node,
+	// restricted to the constrained terminals
+	var nodes []bitField
+	var terms func(n *Node) bitField
+	terms = func(n *Node) bitField {
+		b := c.newField()
+		if n.Term != nil {
+			if i, ok := c.index[n.Term.Name]; ok {
+				b.set(i)
+			}
+			return b
+		}
+		b.or(terms(n.Left))
+		b.or(terms(n.Right))
+		nodes = append(nodes, b)
+		return b
+	}
+	present := terms(tr.Root)
+
+	if c.negative {
+		// the clades can be evaluated
+		// only if all constrained terminals
+		// are in the tree
+		if present.count() < len(c.index) {
+			return true
+		}
+		for _, cl := range c.clades {
+			if !hasField(nodes, cl) {
+				return true
+			}
+		}
+		return false
+	}
+
+	cl := c.newField()
+	for _, o := range c.clades {
+		for i := range cl {
+			cl[i] = o[i] & present[i]
+		}
+		if cl.count() < 2 {
+			continue
+		}
+		if !hasField(nodes, cl) {
+			return false
+		}
+	}
+	return true
+}
+
+// HasField returns true
+// if a bit field is in a list.
+func hasField(ls []bitField, b bitField) bool {
+	for _, x := range ls {
+		if x.equal(b) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetConstraint sets a constraint
+// for the branch swapping of a tree.
+// It returns an error
+// if the tree does not fulfill the constraint.
+func (tr *Tree) SetConstraint(c *Constraint) error {
+	old := tr.c
+	tr.c = c
+	if !tr.fits() {
+		tr.c = old
+		return errors.New("parsimony: constraint: tree does not fulfill the constraint")
+	}
+	return nil
+}
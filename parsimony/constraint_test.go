@@ -0,0 +1,120 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/tree"
+)
+
+func TestConstraint(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(resolvedBlob))
+	if err != nil {
+		t.Fatalf("parsimony: constraint: unexpected error while reading matrix: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		tree     string
+		negative bool
+		clade    []string
+		has      bool
+	}{
+		{"positive", "((A,C),B,D);", false, []string{"A", "C"}, true},
+		{"positive with outgroup", "((Out,B),(A,C,D));", false, []string{"A", "C", "D"}, true},
+		{"negative", "((A,B),C,D);", true, []string{"A", "B"}, false},
+	}
+	for _, test := range tests {
+		ct, err := tree.Read(strings.NewReader(test.tree))
+		if err != nil {
+			t.Fatalf("parsimony: constraint: %s: unexpected error: %v", test.name, err)
+		}
+		c, err := NewConstraint(ct, m, test.negative)
+		if err != nil {
+			t.Fatalf("parsimony: constraint: %s: unexpected error: %v", test.name, err)
+		}
+		for _, sw := range []Swap{NNI, SPR, TBR} {
+			for i := 0; i < 5; i++ {
+				tr := WagnerConstraint(m, Random, c)
+				tr.SwapLimit(sw, nil, nil)
+				if tr.Cost() <= 8 {
+					t.Errorf("parsimony: constraint: %s: %s: cost %d, want > %d", test.name, sw, tr.Cost(), 8)
+				}
+				if h := tr.Topology().HasClade(test.clade); h != test.has {
+					t.Errorf("parsimony: constraint: %s: %s: clade %v: %v, want %v", test.name, sw, test.clade, h, test.has)
+				}
+			}
+		}
+	}
+
+	// a constraint without clades
+	ct, _ := tree.Read(strings.NewReader("(A,B,C,D);"))
+	if _, err := NewConstraint(ct, m, false); err == nil {
+		t.Errorf("parsimony: constraint: expecting error on tree without clades")
+	}
+
+	// a starting tree that violates the constraint
+	ct, _ = tree.Read(strings.NewReader("((A,B),C,D);"))
+	c, _ := NewConstraint(ct, m, false)
+	tr, err := ReadTree(strings.NewReader("(Out,((A,C),(B,D)));"), m)
+	if err != nil {
+		t.Fatalf("parsimony: constraint: unexpected error: %v", err)
+	}
+	if err := tr.SetConstraint(c); err == nil {
+		t.Errorf("parsimony: constraint: expecting error on a tree that violates the constraint")
+	}
+}
+
+func TestCladeConstraint(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(resolvedBlob))
+	if err != nil {
+		t.Fatalf("parsimony: clade constraint: unexpected error while reading matrix: %v", err)
+	}
+
+	defs, err := tree.ReadCladeDefs(strings.NewReader("# clades\nAC = A C\nOutB = Out B\n"))
+	if err != nil {
+		t.Fatalf("parsimony: clade constraint: unexpected error: %v", err)
+	}
+	c, err := NewCladeConstraint(defs, m, false)
+	if err != nil {
+		t.Fatalf("parsimony: clade constraint: unexpected error: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		tr := WagnerConstraint(m, Random, c)
+		tr.SwapLimit(SPR, nil, nil)
+		for _, cl := range [][]string{{"A", "C"}, {"A", "C", "D"}} {
+			if !tr.Topology().HasClade(cl) {
+				t.Errorf("parsimony: clade constraint: clade %v not found", cl)
+			}
+		}
+	}
+
+	c, err = NewCladeConstraint(defs[:1], m, true)
+	if err != nil {
+		t.Fatalf("parsimony: clade constraint: negative: unexpected error: %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		tr := WagnerConstraint(m, Random, c)
+		tr.SwapLimit(SPR, nil, nil)
+		if tr.Topology().HasClade([]string{"A", "C"}) {
+			t.Errorf("parsimony: clade constraint: negative: clade %v found", []string{"A", "C"})
+		}
+	}
+
+	// a clade with an unknown terminal
+	defs = []tree.CladeDef{{Name: "AX", Terms: []string{"A", "X"}}}
+	if _, err := NewCladeConstraint(defs, m, false); err == nil {
+		t.Errorf("parsimony: clade constraint: expecting error on unknown terminal")
+	}
+
+	// only trivial clades
+	defs = []tree.CladeDef{{Name: "A", Terms: []string{"A"}}}
+	if _, err := NewCladeConstraint(defs, m, false); err == nil {
+		t.Errorf("parsimony: clade constraint: expecting error on definitions without clades")
+	}
+}
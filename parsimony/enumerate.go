@@ -0,0 +1,98 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import "github.com/js-arias/ramita/matrix"
+
+// NumTrees returns the number
+// of binary trees,
+// rooted on the outgroup,
+// for a number of terminals
+// (including the outgroup).
+func NumTrees(terms int) int {
+	n := 1
+	for i := 5; i <= 2*terms-3; i += 2 {
+		n *= i - 2
+	}
+	return n
+}
+
+// Enumerate calls fn
+// with each binary tree of a matrix,
+// rooted on the outgroup.
+//
+// The terminals are added to a growing tree
+// in all possible positions,
+// in the order of the matrix.
+// The tree is modified after fn returns,
+// so fn should make a copy of the tree
+// (for example, with its Topology),
+// if the tree is required later.
+//
+// As the number of trees grows very fast,
+// it should be used only with very small matrices
+// (about 10 terminals or less).
+func Enumerate(m *matrix.Matrix, fn func(tr *Tree)) {
+	var terms []*matrix.Terminal
+	for _, t := range m.Taxa() {
+		if t == m.Out {
+			continue
+		}
+		terms = append(terms, t)
+	}
+	if len(terms) < 2 {
+		return
+	}
+	tr := startTree(m, terms[0], terms[1])
+	tr.enumerate(terms[2:], fn)
+}
+
+// Enumerate adds the first terminal of the list
+// at each position of the tree,
+// and then adds the remaining terminals.
+// When all the terminals are added,
+// fn is called with the tree.
+func (tr *Tree) enumerate(terms []*matrix.Terminal, fn func(tr *Tree)) {
+	if len(terms) == 0 {
+		fn(tr)
+		return
+	}
+
+	tm := terms[0]
+	na := &Node{
+		Chars:     make([]uint32, tm.Len()),
+		charsCopy: make([]uint32, tm.Len()),
+	}
+	nt := &Node{
+		Anc:   na,
+		Term:  tm,
+		Chars: tm.Chars,
+	}
+	na.Left = nt
+
+	// the list of nodes grows
+	// when the terminals are added
+	pos := append([]*Node{}, tr.Nodes[2:]...)
+	for _, d := range pos {
+		tr.insert(na, d)
+		tr.enumerate(terms[1:], fn)
+		tr.Nodes = tr.Nodes[:len(tr.Nodes)-2]
+
+		// Restore the position
+		a := na.Anc
+		if a.Left == na {
+			a.Left = d
+		} else {
+			a.Right = d
+		}
+		d.Anc = a
+		increDown(a, tr.w)
+		for x := a; x != nil; x = x.Anc {
+			x.save()
+		}
+	}
+	na.Anc = nil
+	na.Right = nil
+}
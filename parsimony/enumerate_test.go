@@ -0,0 +1,61 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/matrix"
+)
+
+func TestEnumerate(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("parsimony: enumerate: unexpected error while reading matrix: %v", err)
+	}
+	var drop, names []string
+	for _, tm := range m.Taxa() {
+		if tm == m.Out {
+			continue
+		}
+		if len(names) < 5 {
+			names = append(names, tm.Name)
+			continue
+		}
+		drop = append(drop, tm.Name)
+	}
+	m = m.DropTaxa(drop)
+
+	want := make(map[string]int)
+	for _, tp := range allTrees(names) {
+		tr, err := ReadTree(strings.NewReader("("+m.Out.Name+","+tp.String()+");"), m)
+		if err != nil {
+			t.Fatalf("parsimony: enumerate: unexpected error: %v", err)
+		}
+		want[tr.Topology().Canonical()] = tr.Cost()
+	}
+
+	got := make(map[string]int)
+	Enumerate(m, func(tr *Tree) {
+		got[tr.Topology().Canonical()] = tr.Cost()
+	})
+	if n := NumTrees(len(m.Names)); n != len(want) {
+		t.Errorf("parsimony: enumerate: NumTrees %d, want %d", n, len(want))
+	}
+	if len(got) != len(want) {
+		t.Errorf("parsimony: enumerate: %d trees, want %d", len(got), len(want))
+	}
+	for k, c := range want {
+		gc, ok := got[k]
+		if !ok {
+			t.Errorf("parsimony: enumerate: tree %q not found", k)
+			continue
+		}
+		if gc != c {
+			t.Errorf("parsimony: enumerate: tree %q: cost %d, want %d", k, gc, c)
+		}
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"math/bits"
+	"sort"
+
+	"github.com/js-arias/ramita/matrix"
+)
+
+// Characters with up to four states
+// (e.g. DNA characters)
+// are packed into 64 bit words,
+// using four bits per character,
+// so the Fitch optimization
+// is made on sixteen characters at once.
+const (
+	packBits  = 4
+	packChars = 64 / packBits
+
+	// lowBits has the lowest bit
+	// of each packed character
+	lowBits = 0x1111111111111111
+
+	// packMask is the mask
+	// of a single packed character
+	packMask = 1<<packBits - 1
+)
+
+// A packing is the layout of the characters
+// packed into words.
+type packing struct {
+	chars []int // packed characters, in word order
+	w     []int // weight of each word
+	rest  []int // characters that are not packed
+}
+
+// NewPacking returns the packing layout
+// of a matrix.
+// Characters without a step matrix,
+// and with all its state sets
+// (including unknown data)
+// in the first four states
+// are packed.
+// As the cost of a word
+// is counted with a single weight,
+// the packed characters are sorted by weight,
+// and each word only has characters
+// with the same weight.
+// If there are no characters to pack,
+// it returns nil.
+func newPacking(m *matrix.Matrix, w *costs) *packing {
+	p := &packing{}
+	byWeight := make(map[int][]int)
+	for c := range m.Kind {
+		if m.Step(c) != nil || !isPackable(m.Column(c)) {
+			p.rest = append(p.rest, c)
+			continue
+		}
+		wt := w.weight(c)
+		byWeight[wt] = append(byWeight[wt], c)
+	}
+	if len(byWeight) == 0 {
+		return nil
+	}
+
+	ws := make([]int, 0, len(byWeight))
+	for wt := range byWeight {
+		ws = append(ws, wt)
+	}
+	sort.Ints(ws)
+	for _, wt := range ws {
+		chars := byWeight[wt]
+		for i := 0; i < len(chars); i += packChars {
+			p.w = append(p.w, wt)
+		}
+		p.chars = append(p.chars, chars...)
+
+		// fill the last word
+		// with empty slots,
+		// so each word has a single weight
+		for len(p.chars)%packChars != 0 {
+			p.chars = append(p.chars, -1)
+		}
+	}
+	return p
+}
+
+// IsPackable returns true
+// if all the state sets of a character
+// are in the first four states.
+func isPackable(col []uint32) bool {
+	for _, s := range col {
+		if s&^packMask != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Words returns the packed state sets of a node.
+// The words of a terminal are built
+// the first time they are requested.
+func (p *packing) words(n *Node) []uint64 {
+	if len(n.words) == len(p.w) {
+		return n.words
+	}
+	n.words = make([]uint64, len(p.w))
+	if n.Term == nil {
+		return n.words
+	}
+	for i, c := range p.chars {
+		// empty slots are unknown,
+		// so they never add steps
+		st := uint64(packMask)
+		if c >= 0 {
+			st = fitState(n.state(c))
+		}
+		n.words[i/packChars] |= st << uint(packBits*(i%packChars))
+	}
+	return n.words
+}
+
+// FitState returns a state set
+// masked to the states of a packed character.
+// The layout is made from the state sets
+// of the matrix terminals,
+// so a state set that does not fit
+// is from a terminal outside the matrix,
+// and without any packed state
+// it is taken as unknown,
+// instead of overwriting the next character.
+func fitState(st uint32) uint64 {
+	if st&packMask == 0 {
+		return packMask
+	}
+	return uint64(st & packMask)
+}
+
+// Fitch makes the Fitch optimization
+// of the packed characters of a node,
+// and returns its cost.
+func (p *packing) fitch(n *Node) int {
+	l, r := p.words(n.Left), p.words(n.Right)
+	x := p.words(n)
+	n.pk = p
+	cost := 0
+	for i := range x {
+		a, b := l[i], r[i]
+		v := a & b
+
+		// set the lowest bit
+		// of each empty intersection
+		t := v | v>>1
+		t |= t >> 2
+		empty := ^t & lowBits
+
+		x[i] = v | (a|b)&(empty*packMask)
+		cost += p.w[i] * bits.OnesCount64(empty)
+	}
+	return cost
+}
+
+// Unpack sets the state sets
+// of the packed characters of a node
+// from its packed words.
+func (p *packing) unpack(n *Node) {
+	for i, c := range p.chars {
+		if c < 0 {
+			continue
+		}
+		n.Chars[c] = uint32(n.words[i/packChars]>>uint(packBits*(i%packChars))) & packMask
+	}
+}
+
+// States returns the down-pass state sets
+// of the node.
+// In internal nodes,
+// the state sets of the characters
+// packed into words
+// are only updated in the Chars field
+// when they are requested
+// with this method.
+//
+// In terminals with packed data
+// (see matrix.Matrix.Pack),
+// a new slice with the unpacked states
+// is returned.
+func (n *Node) States() []uint32 {
+	if n.Term != nil {
+		return n.Term.Unpack()
+	}
+	if n.pk != nil {
+		n.pk.unpack(n)
+	}
+	return n.Chars
+}
+
+// State returns the down-pass state set
+// of a character in a node.
+// Terminals with packed data
+// are read without unpacking.
+func (n *Node) state(char int) uint32 {
+	if n.Chars == nil {
+		return n.Term.State(char)
+	}
+	return n.Chars[char]
+}
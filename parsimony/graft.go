@@ -0,0 +1,80 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+// Graft returns a new tree
+// from a backbone topology,
+// in which the terminals of the matrix
+// that are not in the backbone
+// are added at its best position
+// (i.e. the position with the smallest
+// increase in length),
+// as in the Wagner algorithm,
+// using the indicated addition sequence.
+// The clades of the backbone are kept,
+// and its polytomies are resolved
+// as in ResolveBest.
+// The tree is rooted at the outgroup.
+func Graft(t *tree.Tree, m *matrix.Matrix, seq Addition) (*Tree, error) {
+	names := t.Terms()
+	for _, nm := range names {
+		if m.Names[nm] == nil {
+			return nil, errors.Errorf("parsimony: graft: terminal %s not in matrix", nm)
+		}
+	}
+	if len(names) < 3 {
+		return nil, errors.Errorf("parsimony: graft: backbone with %d terminals, at least 3 are required", len(names))
+	}
+	missing := m.Outside(names)
+	if len(missing) == 0 {
+		return ResolveBest(t, m)
+	}
+
+	sub := m.DropTaxa(missing)
+	t = t.Copy()
+	if err := t.Reroot(sub.Out.Name); err != nil {
+		return nil, errors.Wrap(err, "parsimony: graft")
+	}
+	rt, err := ResolveBest(t, sub)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsimony: graft")
+	}
+
+	// the costs are taken from the whole matrix,
+	// so the packing layout
+	// is valid for the grafted terminals
+	tr, _, err := partialTree(rt.Topology(), m)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsimony: graft")
+	}
+
+	terms := make([]*matrix.Terminal, 0, len(missing))
+	for _, nm := range missing {
+		terms = append(terms, m.Names[nm])
+	}
+	switch seq {
+	case Random:
+		terms = randomOrder(terms)
+	case Closest, MaxMini:
+		tr.addBySeq(terms, seq)
+		terms = nil
+	}
+	for _, tm := range terms {
+		tr.addTerm(tm)
+	}
+
+	tp := tr.Topology()
+	if err := tp.Reroot(m.Out.Name); err != nil {
+		return nil, errors.Wrap(err, "parsimony: graft")
+	}
+	return FromTopology(tp, m)
+}
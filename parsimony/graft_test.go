@@ -0,0 +1,96 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/tree"
+)
+
+var graftBlob = `
+> morpho
+Out 00000000
+A   00000000
+B   00000000
+C   00000000
+D   01010101
+X   ?1?1?1?1
+`
+
+func TestGraft(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("parsimony: graft: unexpected error while reading matrix: %v", err)
+	}
+	tp, err := tree.Read(strings.NewReader(treeBlob))
+	if err != nil {
+		t.Fatalf("parsimony: graft: unexpected error while reading tree: %v", err)
+	}
+	missing := []string{"Argopecten_irradians", "Dicyema_sp.", "Eisenia_foetida"}
+	back := tp.Copy()
+	back.Prune(missing)
+	if err := back.Reroot(m.Out.Name); err != nil {
+		t.Fatalf("parsimony: graft: unexpected error: %v", err)
+	}
+	want := back.Canonical()
+
+	for _, seq := range []Addition{Random, AsIs, Closest, MaxMini} {
+		tr, err := Graft(back, m, seq)
+		if err != nil {
+			t.Errorf("parsimony: graft: %s: unexpected error: %v", seq, err)
+			continue
+		}
+		added := make(map[string]bool)
+		if nt := checkTerminals(t, tr.Root, added); nt != len(m.Names) {
+			t.Errorf("parsimony: graft: %s: tree size %d terminals, want %d", seq, nt, len(m.Names))
+		}
+
+		// the backbone is not modified
+		gt := tr.Topology()
+		gt.Prune(missing)
+		if err := gt.Reroot(m.Out.Name); err != nil {
+			t.Fatalf("parsimony: graft: %s: unexpected error: %v", seq, err)
+		}
+		if c := gt.Canonical(); c != want {
+			t.Errorf("parsimony: graft: %s: backbone %s, want %s", seq, c, want)
+		}
+	}
+
+	// a terminal with missing data
+	// with a single best position
+	miss, err := matrix.NewMatrix(strings.NewReader(graftBlob))
+	if err != nil {
+		t.Fatalf("parsimony: graft: unexpected error while reading matrix: %v", err)
+	}
+	back, _ = tree.Read(strings.NewReader("(Out,(A,(B,(C,D))));"))
+	best, _ := tree.Read(strings.NewReader("(Out,(A,(B,(C,(D,X)))));"))
+	bt, err := FromTopology(best, miss)
+	if err != nil {
+		t.Fatalf("parsimony: graft: unexpected error: %v", err)
+	}
+	for _, seq := range []Addition{Random, AsIs, Closest, MaxMini} {
+		tr, err := Graft(back, miss, seq)
+		if err != nil {
+			t.Errorf("parsimony: graft: missing data: %s: unexpected error: %v", seq, err)
+			continue
+		}
+		if tr.Cost() != bt.Cost() {
+			t.Errorf("parsimony: graft: missing data: %s: cost %d, want %d", seq, tr.Cost(), bt.Cost())
+		}
+		if !tr.Topology().HasClade([]string{"D", "X"}) {
+			t.Errorf("parsimony: graft: missing data: %s: terminal X not sister of D", seq)
+		}
+	}
+
+	// terminals not in the matrix
+	bad := back.Copy()
+	bad.Root.Add(&tree.Node{Name: "Unknown"})
+	if _, err := Graft(bad, m, AsIs); err == nil {
+		t.Errorf("parsimony: graft: expecting error on terminal not in matrix")
+	}
+}
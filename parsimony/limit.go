@@ -0,0 +1,108 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"math"
+	"time"
+)
+
+// A Limit bounds the effort
+// of a search.
+//
+// A single Limit can be shared
+// by all the swappings of a search,
+// so the limits apply to the whole search.
+// When a limit is reached,
+// the swapping stops,
+// leaving the tree in a valid state.
+type Limit struct {
+	maxTime  time.Duration
+	maxRearr int
+
+	start time.Time
+	rearr int
+	done  bool
+}
+
+// NewLimit returns a new search limit,
+// with a maximum running time,
+// and a maximum number of rearrangements.
+// A zero value means no limit.
+// The time starts when the limit is created.
+func NewLimit(maxTime time.Duration, maxRearr int) *Limit {
+	return &Limit{
+		maxTime:  maxTime,
+		maxRearr: maxRearr,
+		start:    time.Now(),
+	}
+}
+
+// Done returns true
+// if any of the limits was reached.
+func (l *Limit) Done() bool {
+	if l == nil {
+		return false
+	}
+	if !l.done && l.maxTime > 0 && time.Since(l.start) >= l.maxTime {
+		l.done = true
+	}
+	return l.done
+}
+
+// Rearrangements returns the number of rearrangements
+// evaluated since the limit was created.
+func (l *Limit) Rearrangements() int {
+	if l == nil {
+		return 0
+	}
+	return l.rearr
+}
+
+// Step counts a rearrangement,
+// and returns true
+// if any of the limits was reached.
+// As reading the clock is expensive,
+// the time limit is checked
+// every 256 rearrangements.
+func (l *Limit) step() bool {
+	if l == nil {
+		return false
+	}
+	l.rearr++
+	if l.maxRearr > 0 && l.rearr >= l.maxRearr {
+		l.done = true
+	}
+	if l.rearr%256 == 0 {
+		return l.Done()
+	}
+	return l.done
+}
+
+// Steps counts a number of rearrangements,
+// and returns true
+// if any of the limits was reached.
+func (l *Limit) steps(n int) bool {
+	if l == nil {
+		return false
+	}
+	l.rearr += n
+	if l.maxRearr > 0 && l.rearr >= l.maxRearr {
+		l.done = true
+	}
+	return l.Done()
+}
+
+// Remaining returns the number of rearrangements
+// that can be evaluated
+// before the rearrangement limit is reached.
+// Without a rearrangement limit,
+// it returns the maximum int32.
+func (l *Limit) remaining() int {
+	if l == nil || l.maxRearr == 0 {
+		return math.MaxInt32
+	}
+	return l.maxRearr - l.rearr
+}
@@ -0,0 +1,42 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/matrix"
+)
+
+func TestLimit(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("parsimony: limit: unexpected error while reading matrix: %v", err)
+	}
+
+	tr := Wagner(m)
+	lim := NewLimit(0, 10)
+	if tr.DayoffLimit(nil, lim) {
+		t.Errorf("parsimony: limit: swapping not stopped")
+	}
+	if lim.Rearrangements() != 10 {
+		t.Errorf("parsimony: limit: %d rearrangements, want %d", lim.Rearrangements(), 10)
+	}
+
+	// the tree is valid after the swapping is stopped
+	cp, err := FromTopology(tr.Topology(), m)
+	if err != nil {
+		t.Fatalf("parsimony: limit: unexpected error: %v", err)
+	}
+	if cp.Cost() != tr.Cost() {
+		t.Errorf("parsimony: limit: cost %d, want %d", tr.Cost(), cp.Cost())
+	}
+
+	tr = Wagner(m)
+	if !tr.DayoffLimit(nil, NewLimit(0, 0)) {
+		t.Errorf("parsimony: limit: swapping stopped without limits")
+	}
+}
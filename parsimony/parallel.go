@@ -0,0 +1,310 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Threads is the number of goroutines
+// used in the SPR branch swapping.
+var threads = 1
+
+// SetThreads sets the number of goroutines
+// used to evaluate the rearrangements
+// of the SPR branch swapping.
+// By default,
+// a single goroutine is used.
+//
+// Each goroutine uses its own copy of the tree,
+// and evaluates a share of the reinsertion points
+// of each pruned subtree.
+// As the first improvement
+// in the order of the reinsertion points is used,
+// and the rearrangement limit
+// stops the swapping at the same point,
+// the resulting tree is the same
+// as with a single goroutine.
+// The swapping is only parallel
+// with SPR,
+// and if the trees with the best cost
+// are not stored.
+func SetThreads(n int) {
+	if n < 1 {
+		n = 1
+	}
+	threads = n
+}
+
+// Threads returns the number of goroutines
+// used in the SPR branch swapping.
+func Threads() int {
+	return threads
+}
+
+// A pruned is a subtree
+// pruned from a tree.
+type pruned struct {
+	n   *Node // root of the subtree
+	a   *Node // ancestor of the subtree
+	sis *Node // sister of the subtree
+	gf  *Node // ancestor of a
+	unc *Node // sister of a
+}
+
+// Prune removes a subtree from the tree,
+// and optimizes the remaining tree.
+func (tr *Tree) prune(n *Node) pruned {
+	pr := pruned{n: n, a: n.Anc}
+	a := pr.a
+	pr.sis = a.Left
+	if pr.sis == n {
+		pr.sis = a.Right
+	}
+	a.Left = n
+	a.Right = nil
+
+	pr.gf = a.Anc
+	pr.unc = pr.gf.Left
+	if pr.unc == a {
+		pr.unc = pr.gf.Right
+	}
+	pr.gf.Left = pr.unc
+	pr.gf.Right = pr.sis
+	a.Anc = nil
+	pr.sis.Anc = pr.gf
+
+	increDown(pr.gf, tr.w)
+	for x := pr.gf; x != nil; x = x.Anc {
+		x.save()
+	}
+	return pr
+}
+
+// Unprune sets a pruned subtree
+// back in its original position.
+func (tr *Tree) unprune(pr pruned) {
+	pr.sis.Anc = pr.a
+	pr.a.Right = pr.sis
+	pr.a.Anc = pr.gf
+	pr.gf.Left = pr.unc
+	pr.gf.Right = pr.a
+	pr.a.restore()
+	increDown(pr.gf, tr.w)
+	for x := pr.gf; x != nil; x = x.Anc {
+		x.save()
+	}
+}
+
+// Attach sets a pruned subtree
+// as the sister of a node.
+func attach(pr pruned, p *Node) {
+	pa := p.Anc
+	psis := pa.Left
+	if psis == p {
+		psis = pa.Right
+	}
+	pa.Left = psis
+	pa.Right = pr.a
+	p.Anc = pr.a
+	pr.a.Right = p
+	pr.a.Anc = pa
+}
+
+// Regraft sets a pruned subtree
+// as the sister of a node.
+func (tr *Tree) regraft(pr pruned, p *Node) {
+	attach(pr, p)
+	increDown(pr.a, tr.w)
+	for x := pr.a; x != nil; x = x.Anc {
+		x.save()
+	}
+}
+
+// TryRegraft returns true
+// if the tree with the pruned subtree
+// as the sister of a node
+// has a cost lower than the bound.
+// The pruned tree is not modified.
+func (tr *Tree) tryRegraft(pr pruned, p *Node, bound int) bool {
+	pa := p.Anc
+	attach(pr, p)
+	cost, stop := increBound(pr.a, bound, tr.w)
+	if cost < bound && !tr.fits() {
+		// the tree violates the constraint
+		cost = bound
+	}
+
+	// restore positions
+	p.Anc = pa
+	pa.Right = p
+	pr.a.Anc = nil
+	pr.a.Right = nil
+
+	// restore assignations
+	for x := p; x != nil; x = x.Anc {
+		x.restore()
+		if x == stop {
+			break
+		}
+	}
+	return cost < bound
+}
+
+// Replica returns a copy of the tree,
+// in which each node has the same index
+// in the node list
+// as in the original tree.
+func (tr *Tree) replica() *Tree {
+	idx := make(map[*Node]int, len(tr.Nodes))
+	for i, n := range tr.Nodes {
+		idx[n] = i
+	}
+	cp := &Tree{
+		Nodes: make([]*Node, len(tr.Nodes)),
+		w:     tr.w,
+		c:     tr.c,
+	}
+	for i, n := range tr.Nodes {
+		if n.Term != nil {
+			// terminal states are never changed
+			cp.Nodes[i] = &Node{Term: n.Term, Chars: n.Chars}
+			continue
+		}
+		cp.Nodes[i] = &Node{Chars: append([]uint32{}, n.Chars...)}
+	}
+	link := func(n *Node) *Node {
+		if n == nil {
+			return nil
+		}
+		return cp.Nodes[idx[n]]
+	}
+	for i, n := range tr.Nodes {
+		x := cp.Nodes[i]
+		x.Anc = link(n.Anc)
+		x.Left = link(n.Left)
+		x.Right = link(n.Right)
+	}
+	cp.Root = link(tr.Root)
+	downPass(cp.Root, cp.w)
+	for _, n := range cp.Nodes {
+		n.save()
+	}
+	return cp
+}
+
+// DownPass optimizes a node
+// and all of its descendants.
+func downPass(n *Node, w *costs) {
+	if n.Term != nil {
+		return
+	}
+	downPass(n.Left, w)
+	downPass(n.Right, w)
+	optimize(n, w)
+}
+
+// ParallelSwap is the SPR branch swapping
+// evaluated by a set of replicas of the tree
+// (the first replica is the tree).
+// The nodes are tested
+// in the indicated order
+// of its index in the node list.
+// It returns true if a new position is found.
+// If lim is not nil,
+// the swapping stops when the limit is reached.
+func (tr *Tree) parallelSwap(reps []*Tree, order []int, lim *Limit) bool {
+	improved := false
+	bestCost := tr.Cost()
+	for _, i := range order {
+		if lim.Done() {
+			break
+		}
+		if tr.Nodes[i].Anc == tr.Root {
+			continue
+		}
+
+		prs := make([]pruned, len(reps))
+		for r, rt := range reps {
+			prs[r] = rt.prune(rt.Nodes[i])
+		}
+
+		// the positions are taken
+		// from the first replica,
+		// as all the replicas
+		// have the same topology
+		var pos []int
+		for _, k := range order {
+			p := tr.Nodes[k]
+			if p.IsDesc(prs[0].a) || p == prs[0].sis || p.Anc == tr.Root {
+				continue
+			}
+			pos = append(pos, k)
+		}
+
+		// as in the serial swapping,
+		// the positions after the rearrangement limit
+		// are not tested
+		if rem := lim.remaining(); rem < len(pos) {
+			pos = pos[:rem]
+		}
+
+		// each replica tests a share
+		// of the positions,
+		// and stops at the first improvement,
+		// or when another replica
+		// found an improvement
+		// at an earlier position
+		found := int64(len(pos))
+		var wg sync.WaitGroup
+		for r, rt := range reps {
+			wg.Add(1)
+			go func(r int, rt *Tree) {
+				defer wg.Done()
+				for j := r; j < len(pos); j += len(reps) {
+					if int64(j) > atomic.LoadInt64(&found) {
+						return
+					}
+					if rt.tryRegraft(prs[r], rt.Nodes[pos[j]], bestCost) {
+						setMin(&found, int64(j))
+						return
+					}
+				}
+			}(r, rt)
+		}
+		wg.Wait()
+
+		// only the positions
+		// up to the first improvement
+		// are counted
+		if found < int64(len(pos)) {
+			lim.steps(int(found) + 1)
+			for r, rt := range reps {
+				rt.regraft(prs[r], rt.Nodes[pos[found]])
+			}
+			bestCost = tr.Cost()
+			improved = true
+			continue
+		}
+		lim.steps(len(pos))
+		for r, rt := range reps {
+			rt.unprune(prs[r])
+		}
+	}
+	return improved
+}
+
+// SetMin sets an integer
+// to the minimum of its value
+// and the given value.
+func setMin(v *int64, x int64) {
+	for {
+		old := atomic.LoadInt64(v)
+		if x >= old || atomic.CompareAndSwapInt64(v, old, x) {
+			return
+		}
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/matrix"
+)
+
+func TestParallelSwap(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("parsimony: parallel: unexpected error while reading matrix: %v", err)
+	}
+	defer SetThreads(1)
+
+	for seed := int64(1); seed <= 3; seed++ {
+		rand.Seed(seed)
+		SetThreads(1)
+		serial := Wagner(m)
+		serial.Dayoff()
+
+		rand.Seed(seed)
+		SetThreads(4)
+		tr := Wagner(m)
+		tr.Dayoff()
+
+		// the result is the same
+		// as the serial swapping
+		if tr.Cost() != serial.Cost() {
+			t.Errorf("parsimony: parallel: seed %d: cost %d, want %d", seed, tr.Cost(), serial.Cost())
+		}
+		if tr.Topology().Canonical() != serial.Topology().Canonical() {
+			t.Errorf("parsimony: parallel: seed %d: different topology", seed)
+		}
+
+		cp, err := FromTopology(tr.Topology(), m)
+		if err != nil {
+			t.Fatalf("parsimony: parallel: unexpected error: %v", err)
+		}
+		if cp.Cost() != tr.Cost() {
+			t.Errorf("parsimony: parallel: seed %d: cost %d, want %d", seed, tr.Cost(), cp.Cost())
+		}
+	}
+}
+
+func TestParallelLimit(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("parsimony: parallel: limit: unexpected error while reading matrix: %v", err)
+	}
+	defer SetThreads(1)
+
+	for _, max := range []int{1, 7, 50, 333} {
+		rand.Seed(int64(max))
+		SetThreads(1)
+		serial := Wagner(m)
+		sl := NewLimit(0, max)
+		serial.DayoffLimit(nil, sl)
+
+		rand.Seed(int64(max))
+		SetThreads(4)
+		tr := Wagner(m)
+		lim := NewLimit(0, max)
+		tr.DayoffLimit(nil, lim)
+
+		if lim.Rearrangements() != sl.Rearrangements() {
+			t.Errorf("parsimony: parallel: limit %d: %d rearrangements, want %d", max, lim.Rearrangements(), sl.Rearrangements())
+		}
+		if tr.Cost() != serial.Cost() {
+			t.Errorf("parsimony: parallel: limit %d: cost %d, want %d", max, tr.Cost(), serial.Cost())
+		}
+		if tr.Topology().Canonical() != serial.Topology().Canonical() {
+			t.Errorf("parsimony: parallel: limit %d: different topology", max)
+		}
+	}
+}
@@ -7,6 +7,7 @@
 package parsimony
 
 import (
+	"math"
 	"math/rand"
 	"sort"
 	"time"
@@ -22,24 +23,63 @@ func init() {
 // build with the Wagner algorithm and
 // a random addition sequence.
 func Wagner(m *matrix.Matrix) *Tree {
-	// randomize terminal order
-	terms := make(map[int]*matrix.Terminal, len(m.Names)-1)
-	var ls []int
-	for _, t := range m.Names {
+	return WagnerSeq(m, Random)
+}
+
+// WagnerSeq returns a new tree,
+// build with the Wagner algorithm
+// and the indicated addition sequence.
+func WagnerSeq(m *matrix.Matrix, seq Addition) *Tree {
+	return WagnerConstraint(m, seq, nil)
+}
+
+// WagnerConstraint returns a new tree,
+// build with the Wagner algorithm
+// and the indicated addition sequence,
+// in which each terminal is added
+// at the best position
+// that fulfills the constraint.
+// The constraint is kept in the tree,
+// and used during the branch swapping.
+// If c is nil,
+// the tree is not constrained.
+func WagnerConstraint(m *matrix.Matrix, seq Addition, c *Constraint) *Tree {
+	var terms []*matrix.Terminal
+	for _, t := range m.Taxa() {
 		if t == m.Out {
 			continue
 		}
-		v := rand.Int()
-		ls = append(ls, v)
-		terms[v] = t
+		terms = append(terms, t)
 	}
-	sort.Ints(ls)
+	switch seq {
+	case Random:
+		terms = randomOrder(terms)
+	case Closest, MaxMini:
+		terms = firstPair(m.Out, terms, seq)
+	}
+
+	tr := startTree(m, terms[0], terms[1])
+	tr.c = c
 
-	// Add the firts three terminals
-	tr := &Tree{}
+	// add the remaning terminals
+	if seq == Closest || seq == MaxMini {
+		tr.addBySeq(terms[2:], seq)
+		return tr
+	}
+	for _, t := range terms[2:] {
+		tr.addTerm(t)
+	}
+	return tr
+}
+
+// StartTree returns a new tree
+// with the outgroup
+// and two terminals.
+func startTree(m *matrix.Matrix, t0, t1 *matrix.Terminal) *Tree {
+	tr := &Tree{w: newCosts(m)}
 	root := &Node{
-		Chars:     make([]uint8, len(m.Out.Chars)),
-		charsCopy: make([]uint8, len(m.Out.Chars)),
+		Chars:     make([]uint32, m.Out.Len()),
+		charsCopy: make([]uint32, m.Out.Len()),
 	}
 	tr.Root = root
 	tr.Nodes = append(tr.Nodes, root)
@@ -51,53 +91,53 @@ func Wagner(m *matrix.Matrix) *Tree {
 	tr.Nodes = append(tr.Nodes, out)
 	n0 := &Node{
 		Anc:       root,
-		Chars:     make([]uint8, len(m.Out.Chars)),
-		charsCopy: make([]uint8, len(m.Out.Chars)),
+		Chars:     make([]uint32, m.Out.Len()),
+		charsCopy: make([]uint32, m.Out.Len()),
 	}
 	tr.Nodes = append(tr.Nodes, n0)
 	root.Left = out
 	root.Right = n0
 
-	tm := terms[ls[0]]
-	t0 := &Node{
+	nt0 := &Node{
 		Anc:   n0,
-		Term:  tm,
-		Chars: tm.Chars,
+		Term:  t0,
+		Chars: t0.Chars,
 	}
-	tr.Nodes = append(tr.Nodes, t0)
-	tm = terms[ls[1]]
-	t1 := &Node{
+	tr.Nodes = append(tr.Nodes, nt0)
+	nt1 := &Node{
 		Anc:   n0,
-		Term:  tm,
-		Chars: tm.Chars,
+		Term:  t1,
+		Chars: t1.Chars,
 	}
-	tr.Nodes = append(tr.Nodes, t1)
-	n0.Left = t0
-	n0.Right = t1
-	increDown(n0)
+	tr.Nodes = append(tr.Nodes, nt1)
+	n0.Left = nt0
+	n0.Right = nt1
+	increDown(n0, tr.w)
 
 	// make the copy of assignations and costs
 	for _, n := range tr.Nodes {
 		if n.Term != nil {
 			continue
 		}
-		copy(n.charsCopy, n.Chars)
-		n.costCopy = n.Cost
-	}
-
-	// add the remaning terminals
-	for _, i := range ls[2:] {
-		tr.addTerm(terms[i])
+		n.save()
 	}
-
 	return tr
 }
 
 // AddTerm adds a new terminal to the tree.
 func (tr *Tree) addTerm(tm *matrix.Terminal) {
+	na, _, pos := tr.bestPos(tm)
+	tr.insert(na, pos)
+}
+
+// BestPos returns the nodes used to add a terminal,
+// the cost of the tree
+// with the terminal added at its best position,
+// and the best position.
+func (tr *Tree) bestPos(tm *matrix.Terminal) (*Node, int, *Node) {
 	na := &Node{
-		Chars:     make([]uint8, len(tm.Chars)),
-		charsCopy: make([]uint8, len(tm.Chars)),
+		Chars:     make([]uint32, tm.Len()),
+		charsCopy: make([]uint32, tm.Len()),
 	}
 	nt := &Node{
 		Anc:   na,
@@ -106,8 +146,13 @@ func (tr *Tree) addTerm(tm *matrix.Terminal) {
 	}
 	na.Left = nt
 
+	// the first valid position
+	// sets the initial bound,
+	// so a position is always found,
+	// even if all characters
+	// have weight 0
 	var bestPos *Node
-	bestCost := tr.Cost() + len(tm.Chars)*2
+	bestCost := math.MaxInt32
 	for _, d := range tr.Nodes[2:] {
 		// Test the position
 		a := d.Anc
@@ -120,7 +165,11 @@ func (tr *Tree) addTerm(tm *matrix.Terminal) {
 			a.Right = na
 		}
 
-		cost, stop := increBound(na, bestCost)
+		cost, stop := increBound(na, bestCost, tr.w)
+		if cost < bestCost && !tr.fits() {
+			// the position violates the constraint
+			cost = bestCost
+		}
 		if cost < bestCost {
 			bestCost = cost
 			bestPos = d
@@ -135,47 +184,51 @@ func (tr *Tree) addTerm(tm *matrix.Terminal) {
 		d.Anc = a
 
 		// Restore the assignations
-		for a != nil {
-			copy(a.Chars, a.charsCopy)
-			a.Cost = a.costCopy
-			a = a.Anc
-			if a == stop {
+		for x := a; x != nil; x = x.Anc {
+			x.restore()
+			if x == stop {
 				break
 			}
 		}
 	}
+	na.Anc = nil
+	na.Right = nil
+	return na, bestCost, bestPos
+}
 
-	// Add the nodes
-	a := bestPos.Anc
+// Insert adds the nodes of a terminal
+// at the given position.
+func (tr *Tree) insert(na, pos *Node) {
+	a := pos.Anc
 	na.Anc = a
-	na.Right = bestPos
-	bestPos.Anc = na
-	if a.Left == bestPos {
+	na.Right = pos
+	pos.Anc = na
+	if a.Left == pos {
 		a.Left = na
 	} else {
 		a.Right = na
 	}
-	increDown(na)
+	increDown(na, tr.w)
 
 	// Set assignations
 	for x := na; x != nil; x = x.Anc {
-		copy(x.charsCopy, x.Chars)
-		x.costCopy = x.Cost
+		x.save()
 	}
-	tr.Nodes = append(tr.Nodes, na, nt)
+	tr.Nodes = append(tr.Nodes, na, na.Left)
 }
 
 // IncreDown implements a simple incremental downpass,
-// that optimize a node and its ancestors.
+// that optimize a node and its ancestors,
+// using the indicated character weights.
 // It returns the final cost of the optimization.
-func increDown(n *Node) int {
+func increDown(n *Node, w *costs) int {
 	cost := 0
 	for n != nil {
 		if n.Term != nil {
 			n = n.Anc
 			continue
 		}
-		optimize(n)
+		optimize(n, w)
 		cost = n.Cost
 		n = n.Anc
 	}
@@ -184,17 +237,18 @@ func increDown(n *Node) int {
 
 // IncreBound implements a simple incremental downpass,
 // and stopped the optimization
-// when the cost is greather than a given bound.
+// when the cost is greather than a given bound,
+// using the indicated character weights.
 // It returns the final cost,
 // and the stopping node.
-func increBound(n *Node, bound int) (int, *Node) {
+func increBound(n *Node, bound int, w *costs) (int, *Node) {
 	cost := 0
 	for n != nil {
 		if n.Term != nil {
 			n = n.Anc
 			continue
 		}
-		optimize(n)
+		optimize(n, w)
 		cost = n.Cost
 		if cost > bound {
 			return cost, n
@@ -206,30 +260,93 @@ func increBound(n *Node, bound int) (int, *Node) {
 
 // Optimize makes an optimization,
 // of the current node.
-func optimize(n *Node) {
+// If w is not nil,
+// each step of a character
+// is counted with the weight of the character,
+// and the characters with a step matrix
+// are optimized with Sankoff,
+// and the characters packed into words
+// are optimized sixteen at a time.
+func optimize(n *Node, w *costs) {
 	if n.Term != nil {
 		return
 	}
 	n.Cost = n.Left.Cost + n.Right.Cost
-	for i := range n.Chars {
-		v := n.Left.Chars[i] & n.Right.Chars[i]
-		if v == 0 {
-			v = n.Left.Chars[i] | n.Right.Chars[i]
-			n.Cost++
+	if w != nil && w.pk != nil {
+		n.Cost += w.pk.fitch(n)
+		for _, i := range w.pk.rest {
+			fitchChar(n, i, w)
+		}
+	} else {
+		for i := range n.Chars {
+			fitchChar(n, i, w)
 		}
-		n.Chars[i] = v
+	}
+	if w != nil && w.steps != nil {
+		w.sankoff(n)
 	}
 }
 
+// FitchChar makes the Fitch optimization
+// of a single character
+// of a node.
+func fitchChar(n *Node, i int, w *costs) {
+	l, r := n.Left.state(i), n.Right.state(i)
+	v := l & r
+	if v == 0 {
+		v = l | r
+		n.Cost += w.weight(i)
+	}
+	n.Chars[i] = v
+}
+
 // Dayoff performs an SPR branch swapping
 // on a tree.
 func (tr *Tree) Dayoff() {
+	tr.dayoff(nil, nil, false)
+}
+
+// DayoffSet performs an SPR branch swapping
+// on a tree,
+// and stores in the tree set
+// the trees with the best cost
+// found during the swapping.
+func (tr *Tree) DayoffSet(ts *TreeSet) {
+	ts.Add(tr)
+	tr.dayoff(ts, nil, false)
+}
+
+// DayoffLimit performs an SPR branch swapping
+// on a tree,
+// that stops if the limit is reached.
+// If ts is not nil,
+// the trees with the best cost
+// are stored in the set.
+// It returns false
+// if the swapping was stopped by the limit.
+func (tr *Tree) DayoffLimit(ts *TreeSet, lim *Limit) bool {
+	if ts != nil {
+		ts.Add(tr)
+	}
+	tr.dayoff(ts, lim, false)
+	return !lim.Done()
+}
+
+// Dayoff performs an SPR branch swapping,
+// or a TBR branch swapping if tbr is true.
+// If ts is not nil,
+// the best trees are stored in the set,
+// and if lim is not nil,
+// the swapping stops when the limit is reached.
+// With more than one thread
+// (see SetThreads),
+// the SPR swapping is made in parallel.
+func (tr *Tree) dayoff(ts *TreeSet, lim *Limit, tbr bool) {
 	// randomize node order
 	nodes := make(map[int]*Node, len(tr.Nodes))
 	ls := make([]int, 0, len(tr.Nodes))
 	for _, n := range tr.Nodes {
-		copy(n.charsCopy, n.Chars)
-		n.costCopy = n.Cost
+		n.save()
 		if n == tr.Root {
 			continue
 		}
@@ -238,18 +355,48 @@ func (tr *Tree) Dayoff() {
 		nodes[v] = n
 	}
 	sort.Ints(ls)
-	for improve := true; improve; {
-		improve = tr.swap(nodes, ls)
+
+	if threads > 1 && !tbr && ts == nil {
+		idx := make(map[*Node]int, len(tr.Nodes))
+		for i, n := range tr.Nodes {
+			idx[n] = i
+		}
+		order := make([]int, 0, len(ls))
+		for _, v := range ls {
+			order = append(order, idx[nodes[v]])
+		}
+		reps := []*Tree{tr}
+		for len(reps) < threads {
+			reps = append(reps, tr.replica())
+		}
+		for improve := true; improve && !lim.Done(); {
+			improve = tr.parallelSwap(reps, order, lim)
+		}
+		return
+	}
+
+	for improve := true; improve && !lim.Done(); {
+		improve = tr.swap(nodes, ls, ts, lim, tbr)
 	}
 }
 
 // Swap test a node position among all
 // nodes in the indicated node set.
+// If tbr is true,
+// all the rootings of the pruned subtree
+// are also tested.
 // It returns true if a new position is found.
-func (tr *Tree) swap(nodes map[int]*Node, ls []int) bool {
+// If ts is not nil,
+// the trees with the best cost are stored in the set.
+// If lim is not nil,
+// the swapping stops when the limit is reached.
+func (tr *Tree) swap(nodes map[int]*Node, ls []int, ts *TreeSet, lim *Limit, tbr bool) bool {
 	improved := false
 	bestCost := tr.Cost()
 	for _, i := range ls {
+		if lim.Done() {
+			break
+		}
 		imp := false
 
 		// removes the node
@@ -276,70 +423,103 @@ func (tr *Tree) swap(nodes map[int]*Node, ls []int) bool {
 		a.Anc = nil
 		sis.Anc = gf
 
-		increDown(gf)
+		increDown(gf, tr.w)
 		for x := gf; x != nil; x = x.Anc {
-			copy(x.charsCopy, x.Chars)
-			x.costCopy = x.Cost
+			x.save()
 		}
 
-		// test positions of the node
-		for _, j := range ls {
-			p := nodes[j]
-			if p.IsDesc(a) {
-				continue
-			}
-			if p == sis {
-				continue
-			}
-			if p.Anc == tr.Root {
-				continue
+		// rootings of the pruned subtree,
+		// in SPR only the current rooting is used
+		roots := []*Node{nil}
+		ol, or := n.Left, n.Right
+		if tbr {
+			roots = append(roots, n.rerootings()...)
+		}
+		for _, x := range roots {
+			if x != nil {
+				reroot(n, x, tr.w)
 			}
+			// test positions of the subtree
+			for _, j := range ls {
+				p := nodes[j]
+				if p.IsDesc(a) {
+					continue
+				}
+				if p == sis && x == nil {
+					continue
+				}
+				if p.Anc == tr.Root {
+					continue
+				}
 
-			pa := p.Anc
-			psis := pa.Left
-			if psis == p {
-				psis = pa.Right
-			}
-			pa.Left = psis
-			pa.Right = a
-			p.Anc = a
-			a.Right = p
-			a.Anc = pa
-
-			cost, stop := increBound(a, bestCost)
-			if cost < bestCost {
-				// The new position is the best
-				// so update backups and break
-				for x := a; x != nil; x = x.Anc {
-					copy(x.charsCopy, x.Chars)
-					x.costCopy = x.Cost
+				pa := p.Anc
+				psis := pa.Left
+				if psis == p {
+					psis = pa.Right
+				}
+				pa.Left = psis
+				pa.Right = a
+				p.Anc = a
+				a.Right = p
+				a.Anc = pa
+
+				cost, stop := increBound(a, bestCost, tr.w)
+				if cost <= bestCost && !tr.fits() {
+					// the tree violates the constraint
+					cost = bestCost + 1
+				}
+				if ts != nil && cost == bestCost && stop == nil {
+					ts.Add(tr)
+				}
+				if cost < bestCost {
+					// The new position is the best
+					// so update backups and break
+					for x := a; x != nil; x = x.Anc {
+						x.save()
+					}
+					bestCost = cost
+					improved = true
+					imp = true
+					if ts != nil {
+						ts.Add(tr)
+					}
+					lim.step()
+					break
 				}
-				bestCost = cost
-				improved = true
-				imp = true
-				break
-			}
 
-			// restore positions
-			p.Anc = pa
-			pa.Right = p
-			a.Anc = nil
-			a.Right = nil
-
-			// Restore assignations
-			for x := p; x != nil; x = x.Anc {
-				copy(x.Chars, x.charsCopy)
-				x.Cost = x.costCopy
-				if x == stop {
+				// restore positions
+				p.Anc = pa
+				pa.Right = p
+				a.Anc = nil
+				a.Right = nil
+
+				// Restore assignations
+				for x := p; x != nil; x = x.Anc {
+					x.restore()
+					if x == stop {
+						break
+					}
+				}
+				if lim.step() {
 					break
 				}
 			}
+			if imp || lim.Done() {
+				break
+			}
 		}
 
 		if imp {
 			// improvement in this node
+			if tbr {
+				// the subtree could be rerooted
+				n.backup()
+			}
 			continue
 		}
+		if tbr {
+			restoreRoot(n, ol, or, tr.w)
+		}
 
 		// restore the node
 		sis.Anc = a
@@ -347,12 +527,10 @@ func (tr *Tree) swap(nodes map[int]*Node, ls []int) bool {
 		a.Anc = gf
 		gf.Left = unc
 		gf.Right = a
-		copy(a.Chars, a.charsCopy)
-		a.Cost = a.costCopy
-		increDown(gf)
+		a.restore()
+		increDown(gf, tr.w)
 		for x := gf; x != nil; x = x.Anc {
-			copy(x.charsCopy, x.Chars)
-			x.costCopy = x.Cost
+			x.save()
 		}
 	}
 	return improved
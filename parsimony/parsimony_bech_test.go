@@ -89,7 +89,7 @@ func noCopyWagner(m *matrix.Matrix) *Tree {
 	// Add the firts three terminals
 	tr := &Tree{}
 	root := &Node{
-		Chars: make([]uint8, len(m.Out.Chars)),
+		Chars: make([]uint32, len(m.Out.Chars)),
 	}
 	tr.Root = root
 	tr.Nodes = append(tr.Nodes, root)
@@ -101,7 +101,7 @@ func noCopyWagner(m *matrix.Matrix) *Tree {
 	tr.Nodes = append(tr.Nodes, out)
 	n0 := &Node{
 		Anc:   root,
-		Chars: make([]uint8, len(m.Out.Chars)),
+		Chars: make([]uint32, len(m.Out.Chars)),
 	}
 	tr.Nodes = append(tr.Nodes, n0)
 	root.Left = out
@@ -123,7 +123,7 @@ func noCopyWagner(m *matrix.Matrix) *Tree {
 	tr.Nodes = append(tr.Nodes, t1)
 	n0.Left = t0
 	n0.Right = t1
-	increDown(n0)
+	increDown(n0, tr.w)
 
 	// add the remaning terminals
 	for _, i := range ls[2:] {
@@ -136,7 +136,7 @@ func noCopyWagner(m *matrix.Matrix) *Tree {
 // NoCopyAddTerm adds a new terminal to the tree.
 func (tr *Tree) noCopyAddTerm(tm *matrix.Terminal) {
 	na := &Node{
-		Chars: make([]uint8, len(tm.Chars)),
+		Chars: make([]uint32, len(tm.Chars)),
 	}
 	nt := &Node{
 		Anc:   na,
@@ -159,7 +159,7 @@ func (tr *Tree) noCopyAddTerm(tm *matrix.Terminal) {
 			a.Right = na
 		}
 
-		cost := increDown(na)
+		cost := increDown(na, tr.w)
 		if cost < bestCost {
 			bestCost = cost
 			bestPos = d
@@ -172,7 +172,7 @@ func (tr *Tree) noCopyAddTerm(tm *matrix.Terminal) {
 			a.Right = d
 		}
 		d.Anc = a
-		increDown(na)
+		increDown(na, tr.w)
 	}
 
 	// Add the nodes
@@ -185,7 +185,7 @@ func (tr *Tree) noCopyAddTerm(tm *matrix.Terminal) {
 	} else {
 		a.Right = na
 	}
-	increDown(na)
+	increDown(na, tr.w)
 	tr.Nodes = append(tr.Nodes, na, nt)
 }
 
@@ -209,8 +209,8 @@ func unboundWagner(m *matrix.Matrix) *Tree {
 	// Add the firts three terminals
 	tr := &Tree{}
 	root := &Node{
-		Chars:     make([]uint8, len(m.Out.Chars)),
-		charsCopy: make([]uint8, len(m.Out.Chars)),
+		Chars:     make([]uint32, len(m.Out.Chars)),
+		charsCopy: make([]uint32, len(m.Out.Chars)),
 	}
 	tr.Root = root
 	tr.Nodes = append(tr.Nodes, root)
@@ -222,8 +222,8 @@ func unboundWagner(m *matrix.Matrix) *Tree {
 	tr.Nodes = append(tr.Nodes, out)
 	n0 := &Node{
 		Anc:       root,
-		Chars:     make([]uint8, len(m.Out.Chars)),
-		charsCopy: make([]uint8, len(m.Out.Chars)),
+		Chars:     make([]uint32, len(m.Out.Chars)),
+		charsCopy: make([]uint32, len(m.Out.Chars)),
 	}
 	tr.Nodes = append(tr.Nodes, n0)
 	root.Left = out
@@ -245,15 +245,14 @@ func unboundWagner(m *matrix.Matrix) *Tree {
 	tr.Nodes = append(tr.Nodes, t1)
 	n0.Left = t0
 	n0.Right = t1
-	increDown(n0)
+	increDown(n0, tr.w)
 
 	// make the copy of assignations and costs
 	for _, n := range tr.Nodes {
 		if n.Term != nil {
 			continue
 		}
-		copy(n.charsCopy, n.Chars)
-		n.costCopy = n.Cost
+		n.save()
 	}
 
 	// add the remaning terminals
@@ -267,8 +266,8 @@ func unboundWagner(m *matrix.Matrix) *Tree {
 // UnboundAddTerm adds a new terminal to the tree.
 func (tr *Tree) unboundAddTerm(tm *matrix.Terminal) {
 	na := &Node{
-		Chars:     make([]uint8, len(tm.Chars)),
-		charsCopy: make([]uint8, len(tm.Chars)),
+		Chars:     make([]uint32, len(tm.Chars)),
+		charsCopy: make([]uint32, len(tm.Chars)),
 	}
 	nt := &Node{
 		Anc:   na,
@@ -291,7 +290,7 @@ func (tr *Tree) unboundAddTerm(tm *matrix.Terminal) {
 			a.Right = na
 		}
 
-		cost := increDown(na)
+		cost := increDown(na, tr.w)
 		if cost < bestCost {
 			bestCost = cost
 			bestPos = d
@@ -307,8 +306,7 @@ func (tr *Tree) unboundAddTerm(tm *matrix.Terminal) {
 
 		// Restore the assignations
 		for a != nil {
-			copy(a.Chars, a.charsCopy)
-			a.Cost = a.costCopy
+			a.restore()
 			a = a.Anc
 		}
 	}
@@ -323,12 +321,11 @@ func (tr *Tree) unboundAddTerm(tm *matrix.Terminal) {
 	} else {
 		a.Right = na
 	}
-	increDown(na)
+	increDown(na, tr.w)
 
 	// Set assignations
 	for x := na; x != nil; x = x.Anc {
-		copy(x.charsCopy, x.Chars)
-		x.costCopy = x.Cost
+		x.save()
 	}
 	tr.Nodes = append(tr.Nodes, na, nt)
 }
@@ -338,8 +335,7 @@ func (tr *Tree) dayoffJustOne() {
 	nodes := make(map[int]*Node, len(tr.Nodes))
 	ls := make([]int, 0, len(tr.Nodes))
 	for _, n := range tr.Nodes {
-		copy(n.charsCopy, n.Chars)
-		n.costCopy = n.Cost
+		n.save()
 		if n == tr.Root {
 			continue
 		}
@@ -383,10 +379,9 @@ func (tr *Tree) swapOneTime(nodes map[int]*Node, ls []int) bool {
 		a.Anc = nil
 		sis.Anc = gf
 
-		increDown(gf)
+		increDown(gf, tr.w)
 		for x := gf; x != nil; x = x.Anc {
-			copy(x.charsCopy, x.Chars)
-			x.costCopy = x.Cost
+			x.save()
 		}
 
 		// test positions of the node
@@ -413,13 +408,12 @@ func (tr *Tree) swapOneTime(nodes map[int]*Node, ls []int) bool {
 			a.Right = p
 			a.Anc = pa
 
-			cost, stop := increBound(a, bestCost)
+			cost, stop := increBound(a, bestCost, tr.w)
 			if cost < bestCost {
 				// The new position is the best
 				// so update backups and return
 				for x := a; x != nil; x = x.Anc {
-					copy(x.charsCopy, x.Chars)
-					x.costCopy = x.Cost
+					x.save()
 				}
 				return true
 			}
@@ -432,8 +426,7 @@ func (tr *Tree) swapOneTime(nodes map[int]*Node, ls []int) bool {
 
 			// Restore assignations
 			for x := p; x != nil; x = x.Anc {
-				copy(x.Chars, x.charsCopy)
-				x.Cost = x.costCopy
+				x.restore()
 				if x == stop {
 					break
 				}
@@ -446,12 +439,10 @@ func (tr *Tree) swapOneTime(nodes map[int]*Node, ls []int) bool {
 		a.Anc = gf
 		gf.Left = unc
 		gf.Right = a
-		copy(a.Chars, a.charsCopy)
-		a.Cost = a.costCopy
-		increDown(gf)
+		a.restore()
+		increDown(gf, tr.w)
 		for x := gf; x != nil; x = x.Anc {
-			copy(x.charsCopy, x.Chars)
-			x.costCopy = x.Cost
+			x.save()
 		}
 	}
 	return false
@@ -5,6 +5,7 @@
 package parsimony
 
 import (
+	"fmt"
 	"strings"
 	"testing"
 
@@ -78,3 +79,303 @@ func checkTerminals(t *testing.T, n *Node, added map[string]bool) int {
 	}
 	return checkTerminals(t, n.Left, added) + checkTerminals(t, n.Right, added)
 }
+
+var accBlob = `
+> morpho
+Out 0
+A   1
+B   0
+C   1
+D   1
+`
+
+func TestReconstruct(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(accBlob))
+	if err != nil {
+		t.Fatalf("parsimony: reconstruct: unexpected error while reading matrix: %v", err)
+	}
+	tr, err := ReadTree(strings.NewReader("(Out (A (B (C D))));"), m)
+	if err != nil {
+		t.Fatalf("parsimony: reconstruct: unexpected error while reading tree: %v", err)
+	}
+	a := tr.Root.Right
+	b := a.Right
+
+	testData := []struct {
+		m    Method
+		a, b uint32
+	}{
+		{Acctran, 2, 2},
+		{Deltran, 1, 1},
+	}
+	for _, d := range testData {
+		rec := tr.Reconstruct(d.m)
+		if rec[tr.Root][0] != 1 {
+			t.Errorf("parsimony: reconstruct: %s: root state %d, want %d", d.m, rec[tr.Root][0], 1)
+		}
+		if rec[a][0] != d.a {
+			t.Errorf("parsimony: reconstruct: %s: node state %d, want %d", d.m, rec[a][0], d.a)
+		}
+		if rec[b][0] != d.b {
+			t.Errorf("parsimony: reconstruct: %s: node state %d, want %d", d.m, rec[b][0], d.b)
+		}
+		changes := 0
+		for _, n := range tr.Nodes {
+			if n.Anc == nil {
+				continue
+			}
+			if rec[n][0] != rec[n.Anc][0] {
+				changes++
+			}
+		}
+		if changes != tr.Cost() {
+			t.Errorf("parsimony: reconstruct: %s: %d changes, want %d", d.m, changes, tr.Cost())
+		}
+	}
+}
+
+func TestWeights(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(matrix3))
+	if err != nil {
+		t.Fatalf("parsimony: weights: unexpected error while reading matrix: %v", err)
+	}
+	if err := m.SetWeights("2:1-8"); err != nil {
+		t.Fatalf("parsimony: weights: unexpected error: %v", err)
+	}
+	tr := Wagner(m)
+	if tr.Cost() != 12 {
+		t.Errorf("parsimony: weights: wrong cost %d, want %d", tr.Cost(), 12)
+	}
+
+	// weighted cost is kept after swapping
+	m, err = matrix.NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("parsimony: weights: unexpected error while reading matrix: %v", err)
+	}
+	if err := m.SetWeights("3:1-100; 0:200-300"); err != nil {
+		t.Fatalf("parsimony: weights: unexpected error: %v", err)
+	}
+	tr = Wagner(m)
+	tr.Dayoff()
+	steps := tr.Steps()
+	cost := 0
+	for i, s := range steps {
+		cost += s * m.Weight(i)
+	}
+	if tr.Cost() != cost {
+		t.Errorf("parsimony: weights: wrong cost %d, want %d", tr.Cost(), cost)
+	}
+	cp, err := FromTopology(tr.Topology(), m)
+	if err != nil {
+		t.Fatalf("parsimony: weights: unexpected error: %v", err)
+	}
+	if cp.Cost() != tr.Cost() {
+		t.Errorf("parsimony: weights: cost %d, want %d", tr.Cost(), cp.Cost())
+	}
+
+	// without weighted characters
+	// all the terminals are added
+	if err := m.SetWeights(fmt.Sprintf("0:1-%d", len(m.Kind))); err != nil {
+		t.Fatalf("parsimony: weights: unexpected error: %v", err)
+	}
+	tr = Wagner(m)
+	if n := len(tr.Topology().Terms()); n != len(m.Names) {
+		t.Errorf("parsimony: weights: zero weights: %d terminals, want %d", n, len(m.Names))
+	}
+	if tr.Cost() != 0 {
+		t.Errorf("parsimony: weights: zero weights: cost %d, want %d", tr.Cost(), 0)
+	}
+}
+
+func TestCompress(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("parsimony: compress: unexpected error while reading matrix: %v", err)
+	}
+	if err := m.SetWeights("3:1-100"); err != nil {
+		t.Fatalf("parsimony: compress: unexpected error: %v", err)
+	}
+	m.Exclude([]int{200, 201, 202})
+	c, _ := m.Compress(nil)
+
+	tr := Wagner(c)
+	tr.Dayoff()
+	cp, err := FromTopology(tr.Topology(), m)
+	if err != nil {
+		t.Fatalf("parsimony: compress: unexpected error: %v", err)
+	}
+	if cp.Cost() != tr.Cost() {
+		t.Errorf("parsimony: compress: cost %d, want %d", tr.Cost(), cp.Cost())
+	}
+}
+
+func TestGapMode(t *testing.T) {
+	blob := `
+> dna
+Out ACGT
+A   AC-T
+B   AC-T
+C   ACGT
+`
+	m, err := matrix.NewMatrix(strings.NewReader(blob))
+	if err != nil {
+		t.Fatalf("parsimony: gap mode: unexpected error while reading matrix: %v", err)
+	}
+	tr, err := ReadTree(strings.NewReader("(Out,(C,(A,B)));"), m)
+	if err != nil {
+		t.Fatalf("parsimony: gap mode: unexpected error: %v", err)
+	}
+	if tr.Cost() != 0 {
+		t.Errorf("parsimony: gap mode: missing: cost %d, want %d", tr.Cost(), 0)
+	}
+
+	if err := m.SetGapMode(matrix.GapState); err != nil {
+		t.Fatalf("parsimony: gap mode: unexpected error: %v", err)
+	}
+	tr, err = ReadTree(strings.NewReader("(Out,(C,(A,B)));"), m)
+	if err != nil {
+		t.Fatalf("parsimony: gap mode: unexpected error: %v", err)
+	}
+	if tr.Cost() != 1 {
+		t.Errorf("parsimony: gap mode: state: cost %d, want %d", tr.Cost(), 1)
+	}
+	tr, err = ReadTree(strings.NewReader("(Out,(A,(C,B)));"), m)
+	if err != nil {
+		t.Fatalf("parsimony: gap mode: unexpected error: %v", err)
+	}
+	if tr.Cost() != 2 {
+		t.Errorf("parsimony: gap mode: state: cost %d, want %d", tr.Cost(), 2)
+	}
+}
+
+func TestFinalStates(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(accBlob))
+	if err != nil {
+		t.Fatalf("parsimony: final states: unexpected error while reading matrix: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if i == 1 {
+			// an unordered step matrix
+			// gives the same sets
+			if err := m.ReadAssumptions(strings.NewReader("stepmatrix unord\n0 1\n1 0\nunord = 1\n")); err != nil {
+				t.Fatalf("parsimony: final states: unexpected error: %v", err)
+			}
+		}
+		tr, err := ReadTree(strings.NewReader("(Out (A (B (C D))));"), m)
+		if err != nil {
+			t.Fatalf("parsimony: final states: unexpected error while reading tree: %v", err)
+		}
+		a := tr.Root.Right
+		b := a.Right
+		cd := b.Right
+
+		fs := tr.FinalStates()
+		testData := []struct {
+			n    *Node
+			want uint32
+		}{
+			{tr.Root, 3},
+			{a, 3},
+			{b, 3},
+			{cd, 2},
+			{b.Left, 1},
+		}
+		for _, d := range testData {
+			if fs[d.n][0] != d.want {
+				t.Errorf("parsimony: final states: step matrix %v: set %d, want %d", i == 1, fs[d.n][0], d.want)
+			}
+		}
+	}
+}
+
+func TestChanges(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(accBlob))
+	if err != nil {
+		t.Fatalf("parsimony: changes: unexpected error while reading matrix: %v", err)
+	}
+	tr, err := ReadTree(strings.NewReader("(Out (A (B (C D))));"), m)
+	if err != nil {
+		t.Fatalf("parsimony: changes: unexpected error while reading tree: %v", err)
+	}
+	a := tr.Root.Right
+	b := a.Right
+
+	testData := []struct {
+		m     Method
+		nodes []*Node
+	}{
+		{Acctran, []*Node{a, b.Left}},
+		{Deltran, []*Node{a.Left, b.Right}},
+	}
+	for _, d := range testData {
+		chs := tr.Changes(d.m)
+		if len(chs) != len(d.nodes) {
+			t.Errorf("parsimony: changes: %s: %d branches with changes, want %d", d.m, len(chs), len(d.nodes))
+		}
+		for _, n := range d.nodes {
+			if len(chs[n]) != 1 {
+				t.Errorf("parsimony: changes: %s: %d changes, want %d", d.m, len(chs[n]), 1)
+				continue
+			}
+			c := chs[n][0]
+			want := Change{Char: 0, From: 1, To: 2}
+			if n == b.Left {
+				want.From, want.To = 2, 1
+			}
+			if c != want {
+				t.Errorf("parsimony: changes: %s: change %v, want %v", d.m, c, want)
+			}
+		}
+	}
+}
+
+func TestPacking(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("parsimony: packing: unexpected error while reading matrix: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if i == 1 {
+			if err := m.SetWeights("3:1-100; 0:150-160"); err != nil {
+				t.Fatalf("parsimony: packing: unexpected error: %v", err)
+			}
+		}
+		tr := Wagner(m)
+		tr.Dayoff()
+		if tr.w == nil || tr.w.pk == nil {
+			t.Fatalf("parsimony: packing: characters not packed")
+		}
+
+		_, cost := fitchSets(t, tr.Root, tr.w)
+		if tr.Cost() != cost {
+			t.Errorf("parsimony: packing: weighted %v: cost %d, want %d", i == 1, tr.Cost(), cost)
+		}
+	}
+}
+
+// FitchSets makes a Fitch optimization
+// character by character,
+// and checks the state sets of each node.
+func fitchSets(t *testing.T, n *Node, w *costs) ([]uint32, int) {
+	if n.Term != nil {
+		return n.Chars, 0
+	}
+	l, lc := fitchSets(t, n.Left, w)
+	r, rc := fitchSets(t, n.Right, w)
+	cost := lc + rc
+	sets := make([]uint32, len(l))
+	for i := range sets {
+		sets[i] = l[i] & r[i]
+		if sets[i] == 0 {
+			sets[i] = l[i] | r[i]
+			cost += w.weight(i)
+		}
+	}
+	for i, s := range n.States() {
+		if s != sets[i] {
+			t.Errorf("parsimony: packing: char %d: set %d, want %d", i, s, sets[i])
+			break
+		}
+	}
+	return sets, cost
+}
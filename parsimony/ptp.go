@@ -0,0 +1,62 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import "github.com/js-arias/ramita/matrix"
+
+// A PTP is the result
+// of a permutation tail probability test.
+type PTP struct {
+	Length  int   // length of the tree of the original matrix
+	Lengths []int // length of the tree of each permuted matrix
+}
+
+// PermutationTest performs a permutation tail probability test
+// (Archie 1989, Syst. Zool. 38: 239;
+// Faith & Cranston 1991, Cladistics 7: 1).
+//
+// In each replicate,
+// the states of each character
+// are permuted among the terminals,
+// and the permuted matrix,
+// compressed into site patterns
+// (see matrix.Matrix.Compress),
+// is searched with the given search function.
+// As the characters are permuted independently,
+// the matrix is compressed
+// after the permutation.
+// If search is nil,
+// WagnerSPR is used.
+func PermutationTest(m *matrix.Matrix, replicates int, search SearchFunc) *PTP {
+	if search == nil {
+		search = WagnerSPR
+	}
+	cm, _ := m.Compress(nil)
+	p := &PTP{
+		Length:  search(cm).Cost(),
+		Lengths: make([]int, 0, replicates),
+	}
+	for i := 0; i < replicates; i++ {
+		pm, _ := m.Permute().Compress(nil)
+		p.Lengths = append(p.Lengths, search(pm).Cost())
+	}
+	return p
+}
+
+// Prob returns the tail probability of the test,
+// i.e. the proportion of matrices,
+// including the original matrix,
+// with a tree as short,
+// or shorter,
+// than the tree of the original matrix.
+func (p *PTP) Prob() float64 {
+	n := 1
+	for _, l := range p.Lengths {
+		if l <= p.Length {
+			n++
+		}
+	}
+	return float64(n) / float64(len(p.Lengths)+1)
+}
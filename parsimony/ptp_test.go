@@ -0,0 +1,38 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/matrix"
+)
+
+func TestPermutationTest(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(resolvedBlob))
+	if err != nil {
+		t.Fatalf("parsimony: ptp: unexpected error while reading matrix: %v", err)
+	}
+	p := PermutationTest(m, 9, nil)
+	if p.Length != 8 {
+		t.Errorf("parsimony: ptp: length %d, want %d", p.Length, 8)
+	}
+	if len(p.Lengths) != 9 {
+		t.Fatalf("parsimony: ptp: %d replicates, want %d", len(p.Lengths), 9)
+	}
+	// permuted characters are still variable
+	for i, l := range p.Lengths {
+		if l < 8 {
+			t.Errorf("parsimony: ptp: replicate %d: length %d, want at least %d", i, l, 8)
+		}
+	}
+
+	p = &PTP{Length: 10, Lengths: []int{9, 10, 11, 12}}
+	if pr := p.Prob(); math.Abs(pr-0.6) > 1e-9 {
+		t.Errorf("parsimony: ptp: probability %.4f, want %.4f", pr, 0.6)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/js-arias/ramita/matrix"
+)
+
+// RandomTree returns a random binary tree
+// of a matrix,
+// rooted on the outgroup.
+// The terminals are added
+// in a random order,
+// each one in a random branch
+// of the growing tree,
+// so all the trees have the same probability.
+func RandomTree(m *matrix.Matrix) *Tree {
+	var terms []*matrix.Terminal
+	for _, t := range m.Taxa() {
+		if t == m.Out {
+			continue
+		}
+		terms = append(terms, t)
+	}
+	terms = randomOrder(terms)
+	tr := startTree(m, terms[0], terms[1])
+	for _, tm := range terms[2:] {
+		na := &Node{
+			Chars:     make([]uint32, tm.Len()),
+			charsCopy: make([]uint32, tm.Len()),
+		}
+		na.Left = &Node{
+			Anc:   na,
+			Term:  tm,
+			Chars: tm.Chars,
+		}
+
+		// any node,
+		// except the root and the outgroup
+		pos := tr.Nodes[2+rand.Intn(len(tr.Nodes)-2)]
+		tr.insert(na, pos)
+	}
+	return tr
+}
+
+// G1 returns the g1 statistic
+// (the skewness)
+// of a distribution of tree lengths.
+// A strong negative skew
+// (i.e. few short trees,
+// and many long trees)
+// indicates phylogenetic signal in the data
+// (Hillis & Huelsenbeck 1992, J. Hered. 83: 189).
+func G1(lens []int) float64 {
+	if len(lens) == 0 {
+		return 0
+	}
+	mean := 0.0
+	for _, l := range lens {
+		mean += float64(l)
+	}
+	mean /= float64(len(lens))
+
+	var m2, m3 float64
+	for _, l := range lens {
+		d := float64(l) - mean
+		m2 += d * d
+		m3 += d * d * d
+	}
+	m2 /= float64(len(lens))
+	m3 /= float64(len(lens))
+	if m2 == 0 {
+		return 0
+	}
+	return m3 / math.Pow(m2, 1.5)
+}
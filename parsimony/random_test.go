@@ -0,0 +1,61 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/matrix"
+)
+
+func TestRandomTree(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(flatBlob))
+	if err != nil {
+		t.Fatalf("parsimony: random tree: unexpected error while reading matrix: %v", err)
+	}
+	count := make(map[string]int)
+	for i := 0; i < 1500; i++ {
+		tr := RandomTree(m)
+		if len(tr.Nodes) != 2*len(m.Names)-1 {
+			t.Fatalf("parsimony: random tree: %d nodes, want %d", len(tr.Nodes), 2*len(m.Names)-1)
+		}
+		rt, err := FromTopology(tr.Topology(), m)
+		if err != nil {
+			t.Fatalf("parsimony: random tree: unexpected error: %v", err)
+		}
+		if rt.Cost() != tr.Cost() {
+			t.Errorf("parsimony: random tree: cost %d, want %d", tr.Cost(), rt.Cost())
+		}
+		count[tr.Topology().Canonical()]++
+	}
+	if len(count) != NumTrees(len(m.Names)) {
+		t.Errorf("parsimony: random tree: %d different trees, want %d", len(count), NumTrees(len(m.Names)))
+	}
+	for k, c := range count {
+		// expected 100 trees of each topology
+		if c < 50 || c > 150 {
+			t.Errorf("parsimony: random tree: tree %q found %d times", k, c)
+		}
+	}
+}
+
+func TestG1(t *testing.T) {
+	tests := []struct {
+		lens []int
+		want float64
+	}{
+		{[]int{10, 11, 12}, 0},
+		{[]int{10, 10, 10}, 0},
+		{[]int{1, 10, 10, 10}, -1.154701},
+		{[]int{1, 1, 1, 10}, 1.154701},
+	}
+	for _, test := range tests {
+		if g := G1(test.lens); math.Abs(g-test.want) > 1e-6 {
+			t.Errorf("parsimony: g1: %v: got %.6f, want %.6f", test.lens, g, test.want)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"io"
+
+	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+// A TreeReader reads the trees of a reader,
+// as parsimony trees of a matrix.
+//
+// The trees can be stored
+// in any format read by tree.Reader,
+// i.e. parenthetical (newick) format,
+// separated by semicolons,
+// or the trees block of a NEXUS file.
+// As in ReadTree,
+// polytomies are resolved at random.
+type TreeReader struct {
+	// If Prune is true,
+	// the terminals of the matrix
+	// that are not in a tree
+	// are removed from the matrix
+	// used with that tree,
+	// instead of returning an error.
+	Prune bool
+
+	r      *tree.Reader
+	m      *matrix.Matrix
+	tm     *matrix.Matrix // matrix used with the last tree
+	tp     *tree.Tree
+	tree   *Tree
+	pruned []string
+	n      int // number of trees read
+	err    error
+}
+
+// NewTreeReader returns a tree reader
+// that reads from r,
+// using the data of the given matrix.
+func NewTreeReader(r io.Reader, m *matrix.Matrix) *TreeReader {
+	return &TreeReader{r: tree.NewReader(r), m: m}
+}
+
+// Scan moves the reader to the next tree.
+// If there are no more trees,
+// or an error happens while reading it,
+// it will return false.
+// A call to Err should be made to discriminate
+// among these options.
+func (tr *TreeReader) Scan() bool {
+	if tr.err != nil {
+		return false
+	}
+	if !tr.r.Scan() {
+		if err := tr.r.Err(); err != nil {
+			tr.err = errors.Wrap(err, "parsimony: tree reader")
+		}
+		return false
+	}
+	tr.n++
+	tp := tr.r.Tree()
+	m := tr.m
+	var pruned []string
+	if tr.Prune {
+		pruned = m.Outside(tp.Terms())
+		if len(pruned) > 0 {
+			m = m.DropTaxa(pruned)
+		}
+	}
+	t, err := FromTopology(tp, m)
+	if err != nil {
+		tr.err = errors.Wrapf(err, "parsimony: tree reader: tree %d", tr.n)
+		return false
+	}
+	tr.tp = tp
+	tr.tree = t
+	tr.tm = m
+	tr.pruned = pruned
+	return true
+}
+
+// Tree returns the last tree read by Scan.
+func (tr *TreeReader) Tree() *Tree {
+	return tr.tree
+}
+
+// Topology returns the topology
+// of the last tree read by Scan,
+// as found in the file
+// (i.e. with its polytomies,
+// and its name,
+// if any).
+func (tr *TreeReader) Topology() *tree.Tree {
+	return tr.tp
+}
+
+// Matrix returns the matrix
+// used with the last tree read by Scan,
+// i.e. the original matrix,
+// or if the reader prunes the matrix,
+// the matrix without the terminals
+// absent from the tree.
+func (tr *TreeReader) Matrix() *matrix.Matrix {
+	return tr.tm
+}
+
+// Pruned returns the terminals
+// removed from the matrix
+// when the last tree was read by Scan.
+func (tr *TreeReader) Pruned() []string {
+	return tr.pruned
+}
+
+// Err returns the first error,
+// if any,
+// found by the reader.
+func (tr *TreeReader) Err() error {
+	return tr.err
+}
@@ -0,0 +1,211 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import "github.com/pkg/errors"
+
+// A Method is a method to resolve
+// ambiguous state reconstructions.
+type Method int
+
+// Reconstruction methods.
+const (
+	// Accelerated transformation,
+	// changes are placed as close as possible to the root.
+	Acctran Method = iota
+
+	// Delayed transformation,
+	// changes are placed as far as possible from the root.
+	Deltran
+)
+
+// String returns the name of a method.
+func (m Method) String() string {
+	switch m {
+	case Acctran:
+		return "acctran"
+	case Deltran:
+		return "deltran"
+	}
+	return "unknown"
+}
+
+// ParseMethod returns a method from its name.
+func ParseMethod(name string) (Method, error) {
+	switch name {
+	case "acctran":
+		return Acctran, nil
+	case "deltran":
+		return Deltran, nil
+	}
+	return 0, errors.Errorf("parsimony: unknown reconstruction method %q", name)
+}
+
+// Reconstruct returns a single state
+// for each character on each node of the tree,
+// using the indicated method
+// to resolve ambiguous reconstructions.
+// States are returned as bit fields
+// (i.e. state 0 is 1, state 1 is 2,
+// state 2 is 4, and so on).
+//
+// In terminals with unknown or polymorphic data,
+// the state of the ancestor is used,
+// if possible.
+func (t *Tree) Reconstruct(m Method) map[*Node][]uint32 {
+	sets := make(map[*Node][]uint32, len(t.Nodes))
+	if m == Deltran {
+		sets = t.finalSets()
+	} else {
+		for _, n := range t.Nodes {
+			sets[n] = n.States()
+		}
+	}
+
+	rec := make(map[*Node][]uint32, len(t.Nodes))
+	root := make([]uint32, len(t.Root.Chars))
+	out := t.Root.Left
+	for i, c := range sets[t.Root] {
+		// when possible,
+		// use the outgroup state at the root
+		if v := c & out.States()[i]; v != 0 {
+			c = v
+		}
+		root[i] = lowest(c)
+	}
+	rec[t.Root] = root
+	t.Root.Left.reconstruct(root, sets, rec)
+	t.Root.Right.reconstruct(root, sets, rec)
+	return rec
+}
+
+// Reconstruct set the reconstructed states
+// of a node and its descendants.
+func (n *Node) reconstruct(anc []uint32, sets, rec map[*Node][]uint32) {
+	st := make([]uint32, len(anc))
+	for i, c := range sets[n] {
+		if c&anc[i] != 0 {
+			st[i] = anc[i]
+			continue
+		}
+		st[i] = lowest(c)
+	}
+	rec[n] = st
+	if n.Term != nil {
+		return
+	}
+	n.Left.reconstruct(st, sets, rec)
+	n.Right.reconstruct(st, sets, rec)
+}
+
+// FinalStates returns the final state sets
+// of each node of the tree,
+// i.e. the set of states
+// found in at least one most parsimonious reconstruction
+// (MPR)
+// of the node.
+// States are returned as bit fields
+// (i.e. state 0 is 1, state 1 is 2,
+// state 2 is 4, and so on).
+func (t *Tree) FinalStates() map[*Node][]uint32 {
+	return t.finalSets()
+}
+
+// FinalSets returns the final (most parsimonious)
+// state sets of each node of the tree,
+// using the Fitch final pass rules,
+// or the Sankoff up-pass,
+// in characters with a step matrix.
+func (t *Tree) finalSets() map[*Node][]uint32 {
+	sets := make(map[*Node][]uint32, len(t.Nodes))
+	sets[t.Root] = append([]uint32{}, t.Root.States()...)
+	t.Root.Left.final(sets[t.Root], sets)
+	t.Root.Right.final(sets[t.Root], sets)
+	if t.w != nil && t.w.steps != nil {
+		t.w.upPass(t.Root, make([]int, t.w.size), sets)
+	}
+	return sets
+}
+
+// Final set the final state sets
+// of a node and its descendants.
+func (n *Node) final(anc []uint32, sets map[*Node][]uint32) {
+	f := make([]uint32, len(anc))
+	var l, r []uint32
+	if n.Term == nil {
+		l, r = n.Left.States(), n.Right.States()
+	}
+	for i, p := range n.States() {
+		a := anc[i]
+		if n.Term != nil {
+			if v := p & a; v != 0 {
+				f[i] = v
+				continue
+			}
+			f[i] = p
+			continue
+		}
+		if p&a == a {
+			f[i] = a
+			continue
+		}
+		if l[i]&r[i] == 0 {
+			// the down-pass set is an union
+			f[i] = p | a
+			continue
+		}
+		f[i] = p | (a & (l[i] | r[i]))
+	}
+	sets[n] = f
+	if n.Term != nil {
+		return
+	}
+	n.Left.final(f, sets)
+	n.Right.final(f, sets)
+}
+
+// Lowest returns the lowest state
+// of a state set.
+func lowest(c uint32) uint32 {
+	return c & -c
+}
+
+// A Change is a change of state
+// of a character
+// in a branch of a tree.
+type Change struct {
+	Char     int
+	From, To uint32 // states, as bit fields
+}
+
+// Changes returns the changes of each branch
+// of the tree,
+// i.e. the characters with a different reconstructed state
+// between a node
+// and its ancestor,
+// using the indicated method
+// to resolve ambiguous reconstructions
+// (see Reconstruct).
+// The changes of a branch
+// are stored in the node
+// at the end of the branch,
+// sorted by character.
+func (t *Tree) Changes(m Method) map[*Node][]Change {
+	rec := t.Reconstruct(m)
+	chs := make(map[*Node][]Change, len(t.Nodes))
+	for _, n := range t.Nodes {
+		if n.Anc == nil {
+			continue
+		}
+		st, anc := rec[n], rec[n.Anc]
+		for i := range st {
+			if st[i] == anc[i] {
+				continue
+			}
+			chs[n] = append(chs[n], Change{Char: i, From: anc[i], To: st[i]})
+		}
+	}
+	return chs
+}
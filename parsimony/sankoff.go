@@ -0,0 +1,261 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import "github.com/js-arias/ramita/matrix"
+
+// SankInf is the cost used for impossible states
+// in a Sankoff optimization.
+const sankInf = 1 << 28
+
+// Costs stores the character costs
+// used in an optimization.
+type costs struct {
+	w     []int      // weight of each character (nil for the default)
+	steps []sankChar // characters with a step matrix
+	size  int        // size of the Sankoff cost vector
+	pk    *packing   // characters packed into words
+}
+
+// A sankChar is a character optimized
+// with a step matrix.
+type sankChar struct {
+	char int
+	sm   *matrix.StepMatrix
+	w    int // weight of the character
+	off  int // offset in the Sankoff cost vector
+}
+
+// NewCosts returns the character costs of a matrix,
+// or nil,
+// if all the characters are Fitch characters
+// with the default weight,
+// and none of them can be packed into words.
+func newCosts(m *matrix.Matrix) *costs {
+	c := &costs{}
+	for ch := range m.Kind {
+		sm := m.Step(ch)
+		if sm == nil {
+			continue
+		}
+		c.steps = append(c.steps, sankChar{char: ch, sm: sm, w: m.Weight(ch), off: c.size})
+		c.size += sm.States()
+	}
+	if c.steps == nil {
+		c.w = m.Weights()
+	} else {
+		// characters with step matrices
+		// are not counted by Fitch
+		c.w = make([]int, len(m.Kind))
+		for ch := range c.w {
+			c.w[ch] = m.Weight(ch)
+		}
+		for _, sc := range c.steps {
+			c.w[sc.char] = 0
+		}
+	}
+
+	c.pk = newPacking(m, c)
+	if c.w == nil && c.pk == nil {
+		return nil
+	}
+	return c
+}
+
+// Weight returns the Fitch weight
+// of a character.
+func (c *costs) weight(char int) int {
+	if c == nil || c.w == nil {
+		return 1
+	}
+	return c.w[char]
+}
+
+// Vector returns the Sankoff cost vector of a node.
+// The vector of a terminal is built
+// the first time it is requested.
+func (c *costs) vector(n *Node) []int {
+	if n.Term == nil || len(n.sank) == c.size {
+		return n.sank
+	}
+	n.sank = make([]int, c.size)
+	for _, sc := range c.steps {
+		st := n.state(sc.char)
+		if st&(1<<uint(sc.sm.States())-1) == 0 {
+			// unknown state
+			continue
+		}
+		for s := 0; s < sc.sm.States(); s++ {
+			if st&(1<<uint(s)) == 0 {
+				n.sank[sc.off+s] = sankInf
+			}
+		}
+	}
+	return n.sank
+}
+
+// Sankoff optimizes the characters
+// with a step matrix
+// at an internal node,
+// adding its cost to the cost of the node.
+// As the Sankoff cost vector of a node
+// stores the cost of the subtree,
+// only the increase
+// with respect to the descendants is added.
+func (c *costs) sankoff(n *Node) {
+	l, r := c.vector(n.Left), c.vector(n.Right)
+	if len(n.sank) != c.size {
+		n.sank = make([]int, c.size)
+	}
+	for _, sc := range c.steps {
+		k := sc.sm.States()
+		min, lMin, rMin := sankInf, sankInf, sankInf
+		for s := 0; s < k; s++ {
+			bl, br := sankInf, sankInf
+			for t, m := range sc.sm.Cost[s] {
+				if v := m + l[sc.off+t]; v < bl {
+					bl = v
+				}
+				if v := m + r[sc.off+t]; v < br {
+					br = v
+				}
+			}
+			v := bl + br
+			if v > sankInf {
+				v = sankInf
+			}
+			n.sank[sc.off+s] = v
+			if v < min {
+				min = v
+			}
+			if l[sc.off+s] < lMin {
+				lMin = l[sc.off+s]
+			}
+			if r[sc.off+s] < rMin {
+				rMin = r[sc.off+s]
+			}
+		}
+		n.Cost += sc.w * (min - lMin - rMin)
+
+		var st uint32
+		for s := 0; s < k; s++ {
+			if n.sank[sc.off+s] == min {
+				st |= 1 << uint(s)
+			}
+		}
+		n.Chars[sc.char] = st
+	}
+}
+
+// SankMin returns the minimum cost
+// of a character with a step matrix
+// at a node.
+func (c *costs) sankMin(n *Node, sc sankChar) int {
+	v := c.vector(n)
+	min := sankInf
+	for s := 0; s < sc.sm.States(); s++ {
+		if v[sc.off+s] < min {
+			min = v[sc.off+s]
+		}
+	}
+	return min
+}
+
+// UpPass sets the final state sets
+// of the characters with a step matrix
+// of a node and its descendants,
+// using the cost vector of the rest of the tree
+// above the node.
+// The final set are the states
+// with the minimum cost
+// in the sum of both vectors.
+func (c *costs) upPass(n *Node, up []int, sets map[*Node][]uint32) {
+	down := c.vector(n)
+	f := sets[n]
+	for _, sc := range c.steps {
+		min := sankInf + 1
+		var st uint32
+		for s := 0; s < sc.sm.States(); s++ {
+			v := down[sc.off+s] + up[sc.off+s]
+			if v < min {
+				min = v
+				st = 0
+			}
+			if v == min {
+				st |= 1 << uint(s)
+			}
+		}
+		f[sc.char] = st
+	}
+	if n.Term != nil {
+		return
+	}
+	c.upPass(n.Left, c.upVector(up, c.vector(n.Right)), sets)
+	c.upPass(n.Right, c.upVector(up, c.vector(n.Left)), sets)
+}
+
+// UpVector returns the cost vector
+// of the rest of the tree
+// above a node,
+// from the cost vector above its ancestor,
+// and the down-pass cost vector of its sister.
+func (c *costs) upVector(up, sis []int) []int {
+	v := make([]int, c.size)
+	for _, sc := range c.steps {
+		k := sc.sm.States()
+		anc := make([]int, k)
+		for t := 0; t < k; t++ {
+			bs := sankInf
+			for u, m := range sc.sm.Cost[t] {
+				if x := m + sis[sc.off+u]; x < bs {
+					bs = x
+				}
+			}
+			anc[t] = up[sc.off+t] + bs
+		}
+		for s := 0; s < k; s++ {
+			b := sankInf
+			for t := 0; t < k; t++ {
+				if x := sc.sm.Cost[t][s] + anc[t]; x < b {
+					b = x
+				}
+			}
+			v[sc.off+s] = b
+		}
+	}
+	return v
+}
+
+// Save stores a copy
+// of the assignations and cost of a node.
+func (n *Node) save() {
+	if len(n.charsCopy) != len(n.Chars) {
+		n.charsCopy = make([]uint32, len(n.Chars))
+	}
+	copy(n.charsCopy, n.Chars)
+	n.costCopy = n.Cost
+	if n.words != nil {
+		if len(n.wordsCopy) != len(n.words) {
+			n.wordsCopy = make([]uint64, len(n.words))
+		}
+		copy(n.wordsCopy, n.words)
+	}
+	if n.sank == nil {
+		return
+	}
+	if len(n.sankCopy) != len(n.sank) {
+		n.sankCopy = make([]int, len(n.sank))
+	}
+	copy(n.sankCopy, n.sank)
+}
+
+// Restore sets the assignations and cost of a node
+// from its stored copy.
+func (n *Node) restore() {
+	copy(n.Chars, n.charsCopy)
+	n.Cost = n.costCopy
+	copy(n.words, n.wordsCopy)
+	copy(n.sank, n.sankCopy)
+}
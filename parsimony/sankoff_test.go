@@ -0,0 +1,95 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/matrix"
+)
+
+var orderedBlob = `
+> morpho
+Out 00
+A   22
+B   22
+`
+
+var dnaSteps = `
+stepmatrix unord
+0 1 1 1
+1 0 1 1
+1 1 0 1
+1 1 1 0
+
+stepmatrix ord
+0 1 2 3
+1 0 1 2
+2 1 0 1
+3 2 1 0
+`
+
+func TestSankoff(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(orderedBlob))
+	if err != nil {
+		t.Fatalf("parsimony: sankoff: unexpected error while reading matrix: %v", err)
+	}
+	if err := m.ReadAssumptions(strings.NewReader("stepmatrix ord\n0 1 2\n1 0 1\n2 1 0\nord = 2\n")); err != nil {
+		t.Fatalf("parsimony: sankoff: unexpected error: %v", err)
+	}
+	tr := Wagner(m)
+	if tr.Cost() != 3 {
+		t.Errorf("parsimony: sankoff: ordered: cost %d, want %d", tr.Cost(), 3)
+	}
+	steps := tr.Steps()
+	if steps[0] != 1 || steps[1] != 2 {
+		t.Errorf("parsimony: sankoff: ordered: steps %v, want [1 2]", steps)
+	}
+
+	// a step matrix with equal costs
+	// is the same as Fitch
+	m, err = matrix.NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("parsimony: sankoff: unexpected error while reading matrix: %v", err)
+	}
+	tr = Wagner(m)
+	tr.Dayoff()
+	if err := m.ReadAssumptions(strings.NewReader(dnaSteps + "unord = 1-300\n")); err != nil {
+		t.Fatalf("parsimony: sankoff: unexpected error: %v", err)
+	}
+	cp, err := FromTopology(tr.Topology(), m)
+	if err != nil {
+		t.Fatalf("parsimony: sankoff: unexpected error: %v", err)
+	}
+	if cp.Cost() != tr.Cost() {
+		t.Errorf("parsimony: sankoff: unordered: cost %d, want %d", cp.Cost(), tr.Cost())
+	}
+
+	// mixed Fitch and Sankoff characters
+	// are kept after swapping
+	if err := m.ReadAssumptions(strings.NewReader(dnaSteps + "ord = 1-100\n")); err != nil {
+		t.Fatalf("parsimony: sankoff: unexpected error: %v", err)
+	}
+	if err := m.SetWeights("2:50-150"); err != nil {
+		t.Fatalf("parsimony: sankoff: unexpected error: %v", err)
+	}
+	tr = Wagner(m)
+	tr.Dayoff()
+	cost := 0
+	for i, s := range tr.Steps() {
+		cost += s * m.Weight(i)
+	}
+	if tr.Cost() != cost {
+		t.Errorf("parsimony: sankoff: mixed: cost %d, want %d", tr.Cost(), cost)
+	}
+	cp, err = FromTopology(tr.Topology(), m)
+	if err != nil {
+		t.Fatalf("parsimony: sankoff: unexpected error: %v", err)
+	}
+	if cp.Cost() != tr.Cost() {
+		t.Errorf("parsimony: sankoff: mixed: cost %d, want %d", tr.Cost(), cp.Cost())
+	}
+}
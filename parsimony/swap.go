@@ -0,0 +1,309 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"sort"
+
+	"github.com/js-arias/ramita/tree"
+
+	"github.com/pkg/errors"
+)
+
+// A Swap is a branch swapping algorithm.
+type Swap int
+
+// Branch swapping algorithms.
+const (
+	// Subtree pruning and regrafting,
+	// the default.
+	SPR Swap = iota
+
+	// Nearest neighbor interchange,
+	// faster,
+	// but less effective than SPR.
+	NNI
+
+	// Tree bisection and reconnection,
+	// slower,
+	// but more effective than SPR.
+	TBR
+)
+
+// String returns the name of a swapping algorithm.
+func (sw Swap) String() string {
+	switch sw {
+	case SPR:
+		return "spr"
+	case NNI:
+		return "nni"
+	case TBR:
+		return "tbr"
+	}
+	return "unknown"
+}
+
+// ParseSwap returns a swapping algorithm
+// from its name.
+func ParseSwap(name string) (Swap, error) {
+	switch name {
+	case "spr":
+		return SPR, nil
+	case "nni":
+		return NNI, nil
+	case "tbr":
+		return TBR, nil
+	}
+	return 0, errors.Errorf("parsimony: unknown branch swapping %q", name)
+}
+
+// NNI performs a nearest neighbor interchange
+// (NNI) branch swapping
+// on a tree.
+func (tr *Tree) NNI() {
+	tr.nni(nil, nil)
+}
+
+// TBR performs a tree bisection and reconnection
+// (TBR) branch swapping
+// on a tree.
+func (tr *Tree) TBR() {
+	tr.dayoff(nil, nil, true)
+}
+
+// SwapLimit performs a branch swapping
+// on a tree,
+// with the indicated algorithm,
+// that stops if the limit is reached.
+// If ts is not nil,
+// the trees with the best cost
+// are stored in the set.
+// It returns false
+// if the swapping was stopped by the limit.
+func (tr *Tree) SwapLimit(sw Swap, ts *TreeSet, lim *Limit) bool {
+	if ts != nil {
+		ts.Add(tr)
+	}
+	switch sw {
+	case NNI:
+		tr.nni(ts, lim)
+	case TBR:
+		tr.dayoff(ts, lim, true)
+	default:
+		tr.dayoff(ts, lim, false)
+	}
+	return !lim.Done()
+}
+
+// SPRNeighbors returns the topologies
+// of the trees found by SPR rearrangements of a tree,
+// with a cost not worse than the best cost found
+// plus the slack,
+// sorted by cost,
+// and the cost of each topology.
+// The topology of the tree is not included,
+// and the tree is not modified.
+// If lim is not nil,
+// the evaluation stops when the limit is reached.
+func (tr *Tree) SPRNeighbors(slack int, lim *Limit) ([]*tree.Tree, []int) {
+	ts := &TreeSet{Slack: slack}
+	ts.Add(tr)
+	own := ts.trees[0]
+	tr.spread(ts, lim)
+
+	idx := make([]int, 0, ts.Len())
+	for i, t := range ts.trees {
+		if t == own {
+			continue
+		}
+		idx = append(idx, i)
+	}
+	sort.SliceStable(idx, func(i, j int) bool {
+		return ts.costs[idx[i]] < ts.costs[idx[j]]
+	})
+	trees := make([]*tree.Tree, len(idx))
+	costs := make([]int, len(idx))
+	for i, j := range idx {
+		trees[i] = ts.trees[j]
+		costs[i] = ts.costs[j]
+	}
+	return trees, costs
+}
+
+// Nni performs an NNI branch swapping,
+// if ts is not nil,
+// the best trees are stored in the set,
+// and if lim is not nil,
+// the swapping stops when the limit is reached.
+//
+// For each internal branch,
+// each descendant of the node
+// is exchanged with the sister of the node.
+func (tr *Tree) nni(ts *TreeSet, lim *Limit) {
+	for improve := true; improve && !lim.Done(); {
+		improve = false
+		best := tr.Cost()
+		for _, n := range tr.Nodes {
+			if lim.Done() {
+				break
+			}
+			if n.Term != nil || n == tr.Root || n.Anc == tr.Root {
+				continue
+			}
+			for i := 0; i < 2; i++ {
+				sis := n.Anc.Left
+				if sis == n {
+					sis = n.Anc.Right
+				}
+				d := n.Left
+				if i == 1 {
+					d = n.Right
+				}
+				exchange(d, sis)
+				cost := increDown(n, tr.w)
+				if cost <= best && !tr.fits() {
+					// the tree violates the constraint
+					cost = best + 1
+				}
+				if ts != nil && cost == best {
+					ts.Add(tr)
+				}
+				stop := lim.step()
+				if cost < best {
+					best = cost
+					improve = true
+					if ts != nil {
+						ts.Add(tr)
+					}
+					break
+				}
+
+				// restore the nodes
+				exchange(d, sis)
+				increDown(n, tr.w)
+				if stop {
+					break
+				}
+			}
+		}
+	}
+}
+
+// Exchange exchanges the position
+// of two nodes.
+func exchange(x, y *Node) {
+	px, py := x.Anc, y.Anc
+	if px.Left == x {
+		px.Left = y
+	} else {
+		px.Right = y
+	}
+	if py.Left == y {
+		py.Left = x
+	} else {
+		py.Right = x
+	}
+	x.Anc, y.Anc = py, px
+}
+
+// Rerootings returns the nodes
+// that define the alternative rootings
+// of the subtree of a node,
+// i.e. the nodes of the subtree,
+// except the node
+// and its descendants.
+func (n *Node) rerootings() []*Node {
+	if n.Term != nil {
+		return nil
+	}
+	var ls []*Node
+	var add func(x *Node)
+	add = func(x *Node) {
+		if x.Term != nil {
+			return
+		}
+		ls = append(ls, x.Left, x.Right)
+		add(x.Left)
+		add(x.Right)
+	}
+	add(n.Left)
+	add(n.Right)
+	return ls
+}
+
+// Reroot changes the rooting of the subtree of top,
+// so the root of the subtree
+// is placed on the branch of x,
+// i.e. x will be a descendant of top.
+// The assignations of the nodes
+// whose descendants are changed
+// are updated,
+// using the indicated character weights.
+func reroot(top, x *Node, w *costs) {
+	if x.Anc == top {
+		return
+	}
+
+	// path from x to the descendant of top
+	var path []*Node
+	for y := x; y != top; y = y.Anc {
+		path = append(path, y)
+	}
+	last := path[len(path)-1]
+	other := top.Left
+	if other == last {
+		other = top.Right
+	}
+
+	// reverse the path
+	for i := 1; i < len(path); i++ {
+		y := path[i]
+		nd := other
+		if i < len(path)-1 {
+			nd = path[i+1]
+		}
+		if y.Left == path[i-1] {
+			y.Left = nd
+		} else {
+			y.Right = nd
+		}
+		nd.Anc = y
+	}
+	top.Left = x
+	top.Right = path[1]
+	x.Anc = top
+	path[1].Anc = top
+
+	for i := len(path) - 1; i > 0; i-- {
+		optimize(path[i], w)
+	}
+	optimize(top, w)
+}
+
+// RestoreRoot restores the rooting of the subtree of top,
+// as it has left and right as descendants.
+func restoreRoot(top, left, right *Node, w *costs) {
+	if top.Term != nil {
+		return
+	}
+	if left.Anc == right {
+		reroot(top, left, w)
+		return
+	}
+	if right.Anc == left {
+		reroot(top, right, w)
+	}
+}
+
+// Backup stores the assignations
+// of a node and its descendants.
+func (n *Node) backup() {
+	if n.Term != nil {
+		return
+	}
+	n.save()
+	n.Left.backup()
+	n.Right.backup()
+}
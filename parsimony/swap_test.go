@@ -0,0 +1,95 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/matrix"
+)
+
+func TestSwap(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("parsimony: swap: unexpected error while reading matrix: %v", err)
+	}
+
+	for _, sw := range []Swap{NNI, SPR, TBR} {
+		tr := Wagner(m)
+		init := tr.Cost()
+		ts := &TreeSet{}
+		if !tr.SwapLimit(sw, ts, nil) {
+			t.Errorf("parsimony: swap: %s: swapping stopped without limits", sw)
+		}
+		if tr.Cost() > init {
+			t.Errorf("parsimony: swap: %s: cost %d, worse than initial cost %d", sw, tr.Cost(), init)
+		}
+		if ts.Cost() != tr.Cost() {
+			t.Errorf("parsimony: swap: %s: tree set cost %d, want %d", sw, ts.Cost(), tr.Cost())
+		}
+
+		// the swapped tree is valid
+		cp, err := FromTopology(tr.Topology(), m)
+		if err != nil {
+			t.Fatalf("parsimony: swap: %s: unexpected error: %v", sw, err)
+		}
+		if cp.Cost() != tr.Cost() {
+			t.Errorf("parsimony: swap: %s: cost %d, want %d", sw, tr.Cost(), cp.Cost())
+		}
+		for _, st := range ts.Trees() {
+			cp, err := FromTopology(st, m)
+			if err != nil {
+				t.Fatalf("parsimony: swap: %s: unexpected error: %v", sw, err)
+			}
+			if cp.Cost() != ts.Cost() {
+				t.Errorf("parsimony: swap: %s: stored tree cost %d, want %d", sw, cp.Cost(), ts.Cost())
+			}
+		}
+	}
+
+	// TBR never worsens an SPR tree
+	tr := Wagner(m)
+	tr.Dayoff()
+	spr := tr.Cost()
+	tr.TBR()
+	if tr.Cost() > spr {
+		t.Errorf("parsimony: swap: tbr: cost %d, worse than spr cost %d", tr.Cost(), spr)
+	}
+	cp, err := FromTopology(tr.Topology(), m)
+	if err != nil {
+		t.Fatalf("parsimony: swap: tbr: unexpected error: %v", err)
+	}
+	if cp.Cost() != tr.Cost() {
+		t.Errorf("parsimony: swap: tbr: cost %d, want %d", tr.Cost(), cp.Cost())
+	}
+
+	tr = Wagner(m)
+	if tr.SwapLimit(TBR, nil, NewLimit(0, 10)) {
+		t.Errorf("parsimony: swap: tbr: swapping not stopped")
+	}
+	cp, err = FromTopology(tr.Topology(), m)
+	if err != nil {
+		t.Fatalf("parsimony: swap: tbr: unexpected error: %v", err)
+	}
+	if cp.Cost() != tr.Cost() {
+		t.Errorf("parsimony: swap: tbr: after limit, cost %d, want %d", tr.Cost(), cp.Cost())
+	}
+}
+
+func TestParseSwap(t *testing.T) {
+	for _, sw := range []Swap{NNI, SPR, TBR} {
+		p, err := ParseSwap(sw.String())
+		if err != nil {
+			t.Errorf("parsimony: parse swap: unexpected error: %v", err)
+		}
+		if p != sw {
+			t.Errorf("parsimony: parse swap: got %s, want %s", p, sw)
+		}
+	}
+	if _, err := ParseSwap("xyz"); err == nil {
+		t.Errorf("parsimony: parse swap: expecting error")
+	}
+}
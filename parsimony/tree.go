@@ -5,13 +5,10 @@
 package parsimony
 
 import (
-	"bufio"
-	"fmt"
 	"io"
-	"strings"
-	"unicode"
 
 	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/tree"
 
 	"github.com/pkg/errors"
 )
@@ -21,16 +18,24 @@ type Node struct {
 	Anc         *Node            // Ancestor
 	Left, Right *Node            // Descendants of the node
 	Term        *matrix.Terminal // A Terminal (in case the node is a terminal)
-	Chars       []uint8          // Down-pass assignations
+	Chars       []uint32         // Down-pass assignations (nil in packed terminals)
 	Cost        int              // Cost at this node
-	charsCopy   []uint8          // A copy of the down-pass assignation
+	charsCopy   []uint32         // A copy of the down-pass assignation
 	costCopy    int              // A copy if the cost
+	sank        []int            // Sankoff cost vector
+	sankCopy    []int            // A copy of the Sankoff cost vector
+	words       []uint64         // Packed down-pass assignations
+	wordsCopy   []uint64         // A copy of the packed assignations
+	pk          *packing         // Packing of the assignations
 }
 
 // A Tree is a phylogenetic tree.
 type Tree struct {
 	Root  *Node   // The root node
 	Nodes []*Node // A list of nodes
+
+	w *costs      // character costs (nil if not weighted)
+	c *Constraint // topological constraint (nil if not constrained)
 }
 
 // Cost returns the current cost of the tree.
@@ -38,27 +43,36 @@ func (t *Tree) Cost() int {
 	return t.Root.Cost
 }
 
-// Write writes a tree into a io.Writer.
-func (t *Tree) Write(w io.Writer, comma bool) {
-	t.Root.write(w, comma)
-	fmt.Fprintf(w, ";")
-}
-
-// Write write a node into a io.Writer.
-func (n *Node) write(w io.Writer, comma bool) {
-	if n.Term != nil {
-		fmt.Fprintf(w, "%s", n.Term.Name)
-		return
+// Steps returns the number of steps
+// of each character on the tree.
+// Steps are not weighted,
+// and in characters with a step matrix,
+// they are the cost of the transformations.
+func (t *Tree) Steps() []int {
+	steps := make([]int, len(t.Root.Chars))
+	for _, n := range t.Nodes {
+		if n.Term != nil {
+			continue
+		}
+		l, r := n.Left.States(), n.Right.States()
+		for i := range steps {
+			if l[i]&r[i] == 0 {
+				steps[i]++
+			}
+		}
 	}
-	fmt.Fprintf(w, "(")
-	n.Left.write(w, comma)
-	if comma {
-		fmt.Fprintf(w, ",")
-	} else {
-		fmt.Fprintf(w, " ")
+	if t.w == nil {
+		return steps
 	}
-	n.Right.write(w, comma)
-	fmt.Fprintf(w, ")")
+	for _, sc := range t.w.steps {
+		steps[sc.char] = t.w.sankMin(t.Root, sc)
+	}
+	return steps
+}
+
+// Write writes a tree into a io.Writer.
+func (t *Tree) Write(w io.Writer, comma bool) {
+	t.Topology().Write(w, comma)
 }
 
 // Laderize moves smaller branches to be left descendants,
@@ -89,143 +103,159 @@ func (n *Node) laderize(right bool) int {
 }
 
 // ReadTree reads a tree from a Reader.
+// Labels of internal nodes
+// (e.g. support values),
+// and comments,
+// are ignored.
+// Polytomies are resolved at random.
+// Only the first tree is read,
+// use a TreeReader to read all the trees.
 func ReadTree(in io.Reader, m *matrix.Matrix) (*Tree, error) {
-	r := bufio.NewReader(in)
-	for {
-		r1, _, err := r.ReadRune()
-		if err != nil {
-			return nil, errors.Wrapf(err, "parsimony: readtree: unable to read tree")
-		}
-		if r1 == '(' {
-			break
+	r := NewTreeReader(in, m)
+	if !r.Scan() {
+		if err := r.Err(); err != nil {
+			return nil, err
 		}
+		return nil, errors.New("parsimony: readtree: no tree found")
 	}
-	tr := &Tree{}
-	terms := make(map[string]bool)
-	root, err := tr.readNode(r, nil, m, terms)
+	return r.Tree(), nil
+}
+
+// Topology returns the topology of the tree
+// as a tree.Tree.
+func (t *Tree) Topology() *tree.Tree {
+	return tree.FromBinary(t.Root, false)
+}
+
+// Children returns the descendants of the node.
+func (n *Node) Children() (left, right tree.Binary) {
+	return n.Left, n.Right
+}
+
+// TermName returns the name of the terminal,
+// or an empty string in internal nodes.
+func (n *Node) TermName() string {
+	if n.Term == nil {
+		return ""
+	}
+	return n.Term.Name
+}
+
+// BranchLen returns the length of the branch
+// of the node.
+// As branch lengths are not stored
+// in a parsimony tree,
+// it is always 0.
+func (n *Node) BranchLen() float64 {
+	return 0
+}
+
+// FromTopology returns a new tree
+// from a tree topology,
+// using the data of the given matrix.
+// Polytomies are resolved at random
+// (the topology is not modified).
+func FromTopology(t *tree.Tree, m *matrix.Matrix) (*Tree, error) {
+	tr, n, err := partialTree(t, m)
 	if err != nil {
-		return nil, errors.Wrap(err, "parsimony: readtree")
+		return nil, errors.Wrap(err, "parsimony: from topology")
+	}
+	if n != len(m.Names) {
+		return nil, errors.Errorf("parsimony: from topology: tree with %d terminals, want %d", n, len(m.Names))
 	}
-	tr.Root = root
 	return tr, nil
 }
 
-// ReadNode reads a node from an reader.
-func (tr *Tree) readNode(r *bufio.Reader, anc *Node, m *matrix.Matrix, terms map[string]bool) (*Node, error) {
-	n := &Node{Anc: anc}
-	tr.Nodes = append(tr.Nodes, n)
+// PartialTree returns a new tree
+// from a tree topology
+// that can have only some terminals of the matrix,
+// and the number of terminals of the tree.
+// The character costs are taken
+// from the whole matrix,
+// so the other terminals
+// can be added to the tree.
+func partialTree(t *tree.Tree, m *matrix.Matrix) (*Tree, int, error) {
+	if !t.IsBinary() {
+		t = t.Copy()
+		t.Resolve()
+	}
+	tr := &Tree{w: newCosts(m)}
+	terms := make(map[string]bool)
+	root, err := tr.fromNode(t.Root, nil, m, terms)
+	if err != nil {
+		return nil, 0, err
+	}
+	tr.Root = root
+	return tr, len(terms), nil
+}
 
-	for {
-		r1, _, err := r.ReadRune()
-		if err != nil {
-			return nil, err
-		}
-		if unicode.IsSpace(r1) {
-			continue
-		}
-		if r1 == ',' {
-			continue
-		}
-		if r1 == ':' {
-			skipBrLen(r)
-			continue
-		}
-		if r1 == ')' {
-			break
-		}
-		if r1 == '(' {
-			d, err := tr.readNode(r, n, m, terms)
-			if err != nil {
-				return nil, err
-			}
-			if n.Left == nil {
-				n.Left = d
-			} else if n.Right == nil {
-				n.Right = d
-			} else {
-				return nil, errors.New("polytomic tree")
-			}
-			continue
-		}
+// ResolveBest returns a new tree
+// from a tree topology,
+// in which the polytomies are resolved
+// to minimize the length of the tree.
+// The tree is rooted at the outgroup,
+// the polytomies are resolved at random,
+// and then the tree is improved with SPR,
+// keeping all the clades of the topology.
+// As it is a heuristic,
+// the best resolution is not guaranteed.
+func ResolveBest(t *tree.Tree, m *matrix.Matrix) (*Tree, error) {
+	if t.IsBinary() {
+		return FromTopology(t, m)
+	}
+	t = t.Copy()
+	if err := t.Reroot(m.Out.Name); err != nil {
+		return nil, errors.Wrap(err, "parsimony: resolve")
+	}
+	tr, err := FromTopology(t, m)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsimony: resolve")
+	}
+	c, err := NewConstraint(t, m, false)
+	if err == nil {
+		tr.c = c
+	}
+	// without informative clades
+	// (i.e. a bush),
+	// the swapping is not constrained
+	tr.Dayoff()
+	tr.c = nil
+	return tr, nil
+}
 
-		// a terminal
-		r.UnreadRune()
-		name, err := readTerm(r)
-		if err != nil {
-			return nil, err
-		}
-		tm := m.Names[name]
+// FromNode adds a node from a tree topology.
+func (tr *Tree) fromNode(tn *tree.Node, anc *Node, m *matrix.Matrix, terms map[string]bool) (*Node, error) {
+	if tn.IsTerm() {
+		tm := m.Names[tn.Name]
 		if tm == nil {
-			return nil, errors.Errorf("terminal %s not in matrix", name)
+			return nil, errors.Errorf("terminal %s not in matrix", tn.Name)
 		}
-		if terms[name] {
-			return nil, errors.Errorf("terminal %s repeated", name)
+		if terms[tn.Name] {
+			return nil, errors.Errorf("terminal %s repeated", tn.Name)
 		}
-		terms[name] = true
-
+		terms[tn.Name] = true
 		nt := &Node{
-			Anc:   n,
+			Anc:   anc,
 			Term:  tm,
 			Chars: tm.Chars,
 		}
-		if n.Left == nil {
-			n.Left = nt
-		} else if n.Right == nil {
-			n.Right = nt
-		} else {
-			return nil, errors.New("polytomic tree")
-		}
 		tr.Nodes = append(tr.Nodes, nt)
+		return nt, nil
 	}
-
-	if n.Left == nil || n.Right == nil {
-		return nil, errors.New("node without two descendants")
+	if len(tn.Desc) != 2 {
+		return nil, errors.New("node with a single descendant")
 	}
-	n.Chars = make([]uint8, len(n.Left.Chars))
-	optimize(n)
-	n.charsCopy = make([]uint8, len(n.Chars))
-	copy(n.charsCopy, n.Chars)
-	n.costCopy = n.Cost
-	return n, nil
-}
-
-// readTerm reads a terminal name on a tree.
-func readTerm(r *bufio.Reader) (string, error) {
-	var b strings.Builder
-	for {
-		r1, _, err := r.ReadRune()
-		if err != nil {
-			return "", err
-		}
-		if unicode.IsSpace(r1) {
-			break
-		}
-		if r1 == ':' {
-			skipBrLen(r)
-			break
-		}
-		if r1 == ',' || r1 == '(' || r1 == ')' {
-			r.UnreadRune()
-			break
-		}
-		b.WriteRune(r1)
+	n := &Node{Anc: anc}
+	tr.Nodes = append(tr.Nodes, n)
+	var err error
+	if n.Left, err = tr.fromNode(tn.Desc[0], n, m, terms); err != nil {
+		return nil, err
 	}
-	return b.String(), nil
-}
-
-// skipBrLen skips branch lengths.
-func skipBrLen(r *bufio.Reader) {
-	for {
-		r1, _, err := r.ReadRune()
-		if err != nil {
-			return
-		}
-		if unicode.IsSpace(r1) {
-			return
-		}
-		if r1 == ',' || r1 == '(' || r1 == ')' {
-			r.UnreadRune()
-			return
-		}
+	if n.Right, err = tr.fromNode(tn.Desc[1], n, m, terms); err != nil {
+		return nil, err
 	}
+	n.Chars = make([]uint32, len(m.Kind))
+	optimize(n, tr.w)
+	n.save()
+	return n, nil
 }
@@ -5,10 +5,12 @@
 package parsimony
 
 import (
+	"bytes"
 	"strings"
 	"testing"
 
 	"github.com/js-arias/ramita/matrix"
+	"github.com/js-arias/ramita/tree"
 )
 
 var treeBlob = `
@@ -44,10 +46,35 @@ func TestReadTree(t *testing.T) {
 		}
 	}
 
+	if err := m.Pack(); err != nil {
+		t.Errorf("parsinomy: readtree: unexpected error while packing matrix: %v", err)
+	}
 	tr, err = ReadTree(strings.NewReader(treeLenBlob), m)
 	if err != nil {
 		t.Errorf("parsinomy: readtree: unexpected error while reading tree: %v", err)
 	}
+	if tr.Cost() != 3822 {
+		t.Errorf("parsimony: readtree: packed matrix: tree length %d, want %d", tr.Cost(), 3822)
+	}
+	for _, n := range tr.Nodes {
+		if n.Term != nil && n.Chars != nil {
+			t.Errorf("parsimony: readtree: packed matrix: terminal %s unpacked", n.Term.Name)
+		}
+	}
+	steps := 0
+	for _, s := range tr.Steps() {
+		steps += s
+	}
+	if steps != 3822 {
+		t.Errorf("parsimony: readtree: packed matrix: %d steps, want %d", steps, 3822)
+	}
+	if err := m.SetWeights("2:1-10"); err != nil {
+		t.Fatalf("parsimony: readtree: unexpected error: %v", err)
+	}
+	wt := Wagner(m)
+	if nt := checkTerminals(t, wt.Root, make(map[string]bool)); nt != 21 {
+		t.Errorf("parsimony: readtree: packed matrix: Wagner tree size %d terminals, want %d", nt, 21)
+	}
 	added = make(map[string]bool)
 	nt = checkTerminals(t, tr.Root, added)
 	if nt != 21 {
@@ -59,3 +86,178 @@ func TestReadTree(t *testing.T) {
 		}
 	}
 }
+
+var treeLabelBlob = `
+[a tree with support values]
+(Acanthopleura_japonica:0.123,((Chlamys_islandica:0.011,Argopecten_irradians:0.012)100:0.17,(Barentsia_hildegardae:0.08,((Enchytraeus_sp.:0.14,Eisenia_foetida:0.3246)99:0.43546,(((Antedon_serrata:0.001,Balanoglossus_carnosus:0.123)'a clade':0.131,(Anemonia_sulcata:0.324,Branchiostoma_floridae:0.23445)0.95:0.234)[&prob=0.9]:0.32243,((Gordius_aquaticus:0.00234,('Berndtia_purpurea':0.456,Aphonopelma_sp.[&rate=1.2]:0.2345234)42:0.008)87:0.045,(Brachionus_plicatilis:0.0014,((Chaetonotus_sp.:0.0456,(Dicyema_sp.:0.0345,Gnathostomula_paradoxa:0.0067)55:0.0056)60:0.14,((Discocelis_tigrina:0.0354,Geocentrophora_sp.:0.045)70:0.23,(Grillotia_erinaceus:0.785,Fasciolopsis_bushi:0.0123)80:0.4365)90:0.0012)11:0.4346)22:0.04567)33:0.00015)44:0.0014)66:0.0002)77:0.882)root:0.345);
+`
+
+func TestReadTreeLabels(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(dnaBlob))
+	if err != nil {
+		t.Fatalf("parsimony: readtree: unexpected error while reading matrix: %v", err)
+	}
+
+	tr, err := ReadTree(strings.NewReader(treeLabelBlob), m)
+	if err != nil {
+		t.Fatalf("parsimony: readtree: labels: unexpected error while reading tree: %v", err)
+	}
+	if tr.Cost() != 3822 {
+		t.Errorf("parsimony: readtree: labels: tree length %d, want %d", tr.Cost(), 3822)
+	}
+	added := make(map[string]bool)
+	if nt := checkTerminals(t, tr.Root, added); nt != 21 {
+		t.Errorf("parsimony: readtree: labels: tree size %d terminals, want %d", nt, 21)
+	}
+}
+
+func TestTopology(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(matrix3))
+	if err != nil {
+		t.Fatalf("parsimony: topology: unexpected error while reading matrix: %v", err)
+	}
+	tr, err := ReadTree(strings.NewReader("(A (B C));"), m)
+	if err != nil {
+		t.Fatalf("parsimony: topology: unexpected error while reading tree: %v", err)
+	}
+	tp := tr.Topology()
+	tp.Expand([][]string{{"B", "D", "E"}})
+	var b bytes.Buffer
+	tp.Write(&b, true)
+	if want := "(A,((B,D,E),C));"; b.String() != want {
+		t.Errorf("parsimony: topology: tree %s, want %s", b.String(), want)
+	}
+}
+
+func TestSteps(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(matrix3))
+	if err != nil {
+		t.Fatalf("parsimony: steps: unexpected error while reading matrix: %v", err)
+	}
+	tr, err := ReadTree(strings.NewReader("(A (B C));"), m)
+	if err != nil {
+		t.Fatalf("parsimony: steps: unexpected error while reading tree: %v", err)
+	}
+	steps := tr.Steps()
+	want := []int{0, 1, 1, 1, 1, 1, 1, 0}
+	sum := 0
+	for i, s := range steps {
+		if s != want[i] {
+			t.Errorf("parsimony: steps: character %d: %d steps, want %d", i, s, want[i])
+		}
+		sum += s
+	}
+	if sum != tr.Cost() {
+		t.Errorf("parsimony: steps: total %d steps, want %d", sum, tr.Cost())
+	}
+}
+
+func TestResolve(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(resolvedBlob))
+	if err != nil {
+		t.Fatalf("parsimony: resolve: unexpected error while reading matrix: %v", err)
+	}
+
+	tr, err := ReadTree(strings.NewReader("(Out,(A,B,C,D));"), m)
+	if err != nil {
+		t.Fatalf("parsimony: resolve: unexpected error: %v", err)
+	}
+	if tr.Cost() < 8 {
+		t.Errorf("parsimony: resolve: random resolution: cost %d, want >= %d", tr.Cost(), 8)
+	}
+
+	tp, _ := tree.Read(strings.NewReader("(A,B,C,D,Out);"))
+	tr, err = ResolveBest(tp, m)
+	if err != nil {
+		t.Fatalf("parsimony: resolve: unexpected error: %v", err)
+	}
+	if tr.Cost() != 8 {
+		t.Errorf("parsimony: resolve: best resolution: cost %d, want %d", tr.Cost(), 8)
+	}
+
+	// the clades of the tree are kept
+	tp, _ = tree.Read(strings.NewReader("(Out,((A,C),B,D));"))
+	tr, err = ResolveBest(tp, m)
+	if err != nil {
+		t.Fatalf("parsimony: resolve: unexpected error: %v", err)
+	}
+	if !tr.Topology().HasClade([]string{"A", "C"}) {
+		t.Errorf("parsimony: resolve: best resolution: clade A C not found")
+	}
+	if tr.Cost() != 16 {
+		t.Errorf("parsimony: resolve: best resolution: cost %d, want %d", tr.Cost(), 16)
+	}
+}
+
+func TestTreeReader(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(resolvedBlob))
+	if err != nil {
+		t.Fatalf("parsimony: tree reader: unexpected error while reading matrix: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		blob  string
+		costs []int
+	}{
+		{"newick", "(Out,((A,B),(C,D)));\n(Out,((A,C),(B,D)));\n", []int{8, 16}},
+		{"newick polytomy", "(Out,(A,B),(C,D));", []int{8}},
+		{"nexus", "#NEXUS\nbegin trees;\n\ttree one = (Out,((A,B),(C,D)));\n\ttree two = (Out,((A,D),(B,C)));\nend;\n", []int{8, 16}},
+	}
+	for _, test := range tests {
+		r := NewTreeReader(strings.NewReader(test.blob), m)
+		var costs []int
+		for r.Scan() {
+			costs = append(costs, r.Tree().Cost())
+			if r.Topology() == nil {
+				t.Errorf("parsimony: tree reader: %s: nil topology", test.name)
+			}
+		}
+		if err := r.Err(); err != nil {
+			t.Fatalf("parsimony: tree reader: %s: unexpected error: %v", test.name, err)
+		}
+		if len(costs) != len(test.costs) {
+			t.Errorf("parsimony: tree reader: %s: %d trees, want %d", test.name, len(costs), len(test.costs))
+			continue
+		}
+		for i, c := range costs {
+			if c != test.costs[i] {
+				t.Errorf("parsimony: tree reader: %s: tree %d: cost %d, want %d", test.name, i+1, c, test.costs[i])
+			}
+		}
+	}
+
+	r := NewTreeReader(strings.NewReader("(Out,((A,B),(C,D)));\n(Out,((A,B),(C,X)));\n"), m)
+	n := 0
+	for r.Scan() {
+		n++
+	}
+	if n != 1 {
+		t.Errorf("parsimony: tree reader: %d trees read, want %d", n, 1)
+	}
+	if r.Err() == nil {
+		t.Errorf("parsimony: tree reader: expecting error on unknown terminal")
+	}
+
+	// trees without all the terminals
+	blob := "(Out,(A,(B,C)));\n(Out,((A,B),(C,D)));\n"
+	r = NewTreeReader(strings.NewReader(blob), m)
+	if r.Scan() || r.Err() == nil {
+		t.Errorf("parsimony: tree reader: expecting error on missing terminal")
+	}
+	r = NewTreeReader(strings.NewReader(blob), m)
+	r.Prune = true
+	var pruned [][]string
+	for r.Scan() {
+		pruned = append(pruned, r.Pruned())
+		if len(r.Matrix().Names) != len(r.Tree().Nodes)/2+1 {
+			t.Errorf("parsimony: tree reader: prune: tree %d: %d terminals in matrix, want %d", len(pruned), len(r.Matrix().Names), len(r.Tree().Nodes)/2+1)
+		}
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("parsimony: tree reader: prune: unexpected error: %v", err)
+	}
+	if len(pruned) != 2 || len(pruned[0]) != 1 || pruned[0][0] != "D" || pruned[1] != nil {
+		t.Errorf("parsimony: tree reader: prune: pruned terminals %v, want %v", pruned, "[[D] []]")
+	}
+}
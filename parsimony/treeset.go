@@ -0,0 +1,102 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import "github.com/js-arias/ramita/tree"
+
+// A TreeSet stores the different trees
+// with the best cost
+// found during a search.
+//
+// Trees are compared by its topology
+// (using a hash of its canonical form),
+// so each topology is stored only once.
+//
+// If Slack is greater than 0,
+// the set also stores suboptimal trees,
+// i.e. trees with a cost up to Slack steps
+// longer than the best cost.
+type TreeSet struct {
+	// Max is the maximum number of trees stored.
+	// If it is 0,
+	// there is no limit.
+	Max int
+
+	// Slack is the maximum difference
+	// between the cost of a stored tree
+	// and the best cost.
+	Slack int
+
+	cost  int
+	trees []*tree.Tree
+	costs []int // cost of each tree
+	uniq  tree.Unique
+}
+
+// Add adds the topology of a tree to the set,
+// if it is not worse than the trees in the set
+// (plus the slack of the set).
+// If the tree is better,
+// all the previous trees
+// that are outside the slack
+// are discarded.
+// It returns true if the tree was stored.
+func (ts *TreeSet) Add(t *Tree) bool {
+	if len(ts.trees) > 0 && t.Cost() > ts.cost+ts.Slack {
+		return false
+	}
+	if len(ts.trees) == 0 || t.Cost() < ts.cost {
+		ts.cost = t.Cost()
+		ts.discard()
+	}
+	if ts.Max > 0 && len(ts.trees) >= ts.Max {
+		return false
+	}
+	tp := t.Topology()
+	if !ts.uniq.Add(tp) {
+		return false
+	}
+	ts.trees = append(ts.trees, tp)
+	ts.costs = append(ts.costs, t.Cost())
+	return true
+}
+
+// Discard removes the trees
+// whose cost is outside the slack
+// of the best cost.
+func (ts *TreeSet) discard() {
+	trees, costs := ts.trees, ts.costs
+	ts.trees, ts.costs = nil, nil
+	ts.uniq = tree.Unique{}
+	for i, t := range trees {
+		if costs[i] > ts.cost+ts.Slack {
+			continue
+		}
+		ts.trees = append(ts.trees, t)
+		ts.costs = append(ts.costs, costs[i])
+		ts.uniq.Add(t)
+	}
+}
+
+// Cost returns the best cost of the trees in the set.
+func (ts *TreeSet) Cost() int {
+	return ts.cost
+}
+
+// Len returns the number of trees in the set.
+func (ts *TreeSet) Len() int {
+	return len(ts.trees)
+}
+
+// Trees returns the trees of the set.
+func (ts *TreeSet) Trees() []*tree.Tree {
+	return ts.trees
+}
+
+// Costs returns the cost of each tree of the set,
+// in the same order as Trees.
+func (ts *TreeSet) Costs() []int {
+	return ts.costs
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package parsimony
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/js-arias/ramita/matrix"
+)
+
+var flatBlob = `
+> morpho
+Out 0000
+A   1000
+B   0100
+C   0010
+D   0001
+`
+
+var resolvedBlob = `
+> morpho
+Out 00000000
+A   11110000
+B   11110000
+C   00001111
+D   00001111
+`
+
+func TestTreeSet(t *testing.T) {
+	m, err := matrix.NewMatrix(strings.NewReader(flatBlob))
+	if err != nil {
+		t.Fatalf("parsimony: treeset: unexpected error while reading matrix: %v", err)
+	}
+	ts := &TreeSet{}
+	tr := Wagner(m)
+	tr.DayoffSet(ts)
+	if ts.Len() < 2 {
+		t.Errorf("parsimony: treeset: %d trees, want more than 1", ts.Len())
+	}
+	if ts.Cost() != tr.Cost() {
+		t.Errorf("parsimony: treeset: cost %d, want %d", ts.Cost(), tr.Cost())
+	}
+	keys := make(map[string]bool)
+	for _, tp := range ts.Trees() {
+		k := tp.Canonical()
+		if keys[k] {
+			t.Errorf("parsimony: treeset: repeated topology %s", k)
+		}
+		keys[k] = true
+		pt, err := FromTopology(tp, m)
+		if err != nil {
+			t.Fatalf("parsimony: treeset: unexpected error: %v", err)
+		}
+		if pt.Cost() != ts.Cost() {
+			t.Errorf("parsimony: treeset: tree with cost %d, want %d", pt.Cost(), ts.Cost())
+		}
+	}
+
+	max := &TreeSet{Max: 2}
+	Wagner(m).DayoffSet(max)
+	if max.Len() != 2 {
+		t.Errorf("parsimony: treeset: %d trees, want %d", max.Len(), 2)
+	}
+
+	m, err = matrix.NewMatrix(strings.NewReader(resolvedBlob))
+	if err != nil {
+		t.Fatalf("parsimony: treeset: unexpected error while reading matrix: %v", err)
+	}
+	ts = &TreeSet{}
+	Wagner(m).DayoffSet(ts)
+	if ts.Len() != 1 {
+		t.Errorf("parsimony: treeset: %d trees, want %d", ts.Len(), 1)
+	}
+	if ts.Cost() != 8 {
+		t.Errorf("parsimony: treeset: cost %d, want %d", ts.Cost(), 8)
+	}
+}
@@ -0,0 +1,11 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package main
+
+import (
+	// initialize tool sub-commands
+	_ "github.com/js-arias/ramita/internal/selftest"
+	_ "github.com/js-arias/ramita/internal/verify"
+)
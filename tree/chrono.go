@@ -0,0 +1,199 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package tree
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A Calibration is an age constraint
+// for a clade.
+type Calibration struct {
+	Name  string   // Name of the calibrated clade
+	Min   float64  // Minimum age
+	Max   float64  // Maximum age, 0 if undefined
+	Terms []string // Terminals that define the clade
+}
+
+// ReadCalibrations reads a list of calibrations
+// from a reader.
+//
+// Each calibration is defined in a single line,
+// with the name of the clade,
+// the minimum age,
+// the maximum age (or "-" if undefined),
+// and a list of terminals,
+// whose most recent common ancestor
+// is the calibrated node.
+// Lines starting with '#' are ignored.
+func ReadCalibrations(r io.Reader) ([]Calibration, error) {
+	var cals []Calibration
+	s := bufio.NewScanner(r)
+	ln := 0
+	for s.Scan() {
+		ln++
+		f := strings.Fields(s.Text())
+		if len(f) == 0 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		if len(f) < 4 {
+			return nil, errors.Errorf("tree: calibration: line %d: expecting name, min, max, and terminals", ln)
+		}
+		c := Calibration{Name: f[0], Terms: f[3:]}
+		var err error
+		if f[1] != "-" {
+			c.Min, err = strconv.ParseFloat(f[1], 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "tree: calibration: line %d", ln)
+			}
+		}
+		if f[2] != "-" {
+			c.Max, err = strconv.ParseFloat(f[2], 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "tree: calibration: line %d", ln)
+			}
+			if c.Max < c.Min {
+				return nil, errors.Errorf("tree: calibration: line %d: maximum age %.6f less than minimum age %.6f", ln, c.Max, c.Min)
+			}
+		}
+		cals = append(cals, c)
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrap(err, "tree: calibration")
+	}
+	return cals, nil
+}
+
+// MRCA returns the most recent common ancestor
+// of a set of terminals.
+func (t *Tree) MRCA(terms []string) (*Node, error) {
+	tips := make(map[string]*Node)
+	for _, n := range t.Nodes() {
+		if n.IsTerm() {
+			tips[n.Name] = n
+		}
+	}
+	var mrca *Node
+	for _, nm := range terms {
+		n, ok := tips[nm]
+		if !ok {
+			return nil, errors.Errorf("tree: terminal %s not in tree", nm)
+		}
+		if mrca == nil {
+			mrca = n
+			continue
+		}
+		anc := make(map[*Node]bool)
+		for x := mrca; x != nil; x = x.Anc {
+			anc[x] = true
+		}
+		for x := n; x != nil; x = x.Anc {
+			if anc[x] {
+				mrca = x
+				break
+			}
+		}
+	}
+	if mrca == nil {
+		return nil, errors.New("tree: empty terminal list")
+	}
+	return mrca, nil
+}
+
+// Chronogram transforms the branch lengths of a tree
+// (in substitution units)
+// into times,
+// using the mean path length method
+// to build an ultrametric tree,
+// and the calibrations to scale the tree
+// into absolute time units.
+// If there are no calibrations,
+// the root will have an age of 1.
+//
+// As a single scale factor is used
+// (i.e. a strict clock),
+// it returns an error if the calibrations
+// are not compatible.
+func (t *Tree) Chronogram(cals []Calibration) error {
+	// relative ages by mean path length
+	ages := make(map[*Node]float64)
+	t.Root.meanPath(ages)
+	for _, n := range t.Nodes() {
+		if n.Anc != nil && ages[n] > ages[n.Anc] {
+			ages[n] = ages[n.Anc]
+		}
+	}
+	if ages[t.Root] == 0 {
+		return errors.New("tree: chronogram: tree without branch lengths")
+	}
+
+	// scale factor
+	low, high := float64(0), math.Inf(1)
+	for _, c := range cals {
+		n, err := t.MRCA(c.Terms)
+		if err != nil {
+			return errors.Wrapf(err, "tree: chronogram: calibration %s", c.Name)
+		}
+		a := ages[n]
+		if a == 0 {
+			return errors.Errorf("tree: chronogram: calibration %s: node with age 0", c.Name)
+		}
+		if v := c.Min / a; v > low {
+			low = v
+		}
+		if c.Max > 0 {
+			if v := c.Max / a; v < high {
+				high = v
+			}
+		}
+	}
+	if low > high {
+		return errors.New("tree: chronogram: calibrations incompatible with a strict clock")
+	}
+	scale := 1 / ages[t.Root]
+	switch {
+	case math.IsInf(high, 1) && low > 0:
+		scale = low
+	case !math.IsInf(high, 1) && low == 0:
+		scale = high
+	case !math.IsInf(high, 1):
+		scale = (low + high) / 2
+	}
+
+	for _, n := range t.Nodes() {
+		if n.Anc == nil {
+			n.Len = 0
+			continue
+		}
+		n.Len = (ages[n.Anc] - ages[n]) * scale
+	}
+	t.Lens = true
+	return nil
+}
+
+// MeanPath sets the mean path length
+// from a node to its descendant terminals.
+// It returns the sum of the path lengths,
+// and the number of terminals.
+func (n *Node) meanPath(ages map[*Node]float64) (float64, int) {
+	if n.IsTerm() {
+		ages[n] = 0
+		return 0, 1
+	}
+	sum, tips := float64(0), 0
+	for _, d := range n.Desc {
+		s, tp := d.meanPath(ages)
+		sum += s + d.Len*float64(tp)
+		tips += tp
+	}
+	ages[n] = sum / float64(tips)
+	return sum, tips
+}
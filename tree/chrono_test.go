@@ -0,0 +1,48 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package tree
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+var calBlob = `
+# clade min max terminals
+AB 10 20 A B
+CD 30 - C D
+`
+
+func TestChronogram(t *testing.T) {
+	cals, err := ReadCalibrations(strings.NewReader(calBlob))
+	if err != nil {
+		t.Fatalf("tree: chronogram: unexpected error: %v", err)
+	}
+	if len(cals) != 2 {
+		t.Fatalf("tree: chronogram: %d calibrations, want %d", len(cals), 2)
+	}
+
+	tr, _ := Read(strings.NewReader("((A:1,B:1):2,(C:2.5,D:1.5):1);"))
+	if err := tr.Chronogram(cals); err != nil {
+		t.Fatalf("tree: chronogram: unexpected error: %v", err)
+	}
+	if !tr.IsUltrametric(0.0001) {
+		t.Errorf("tree: chronogram: tree is not ultrametric")
+	}
+	if a := tr.Age(); math.Abs(a-52.5) > 0.0001 {
+		t.Errorf("tree: chronogram: root age %.6f, want %.6f", a, 52.5)
+	}
+	n, _ := tr.MRCA([]string{"C", "D"})
+	if n.Desc[0].Len < 30 {
+		t.Errorf("tree: chronogram: calibrated age %.6f, want at least %.6f", n.Desc[0].Len, 30.0)
+	}
+
+	cals[0].Max = 10
+	tr, _ = Read(strings.NewReader("((A:1,B:1):2,(C:2,D:2):1);"))
+	if err := tr.Chronogram(cals); err == nil {
+		t.Errorf("tree: chronogram: expecting error on incompatible calibrations")
+	}
+}
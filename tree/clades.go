@@ -0,0 +1,272 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package tree
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A CladeDef is a named clade,
+// defined by its terminals.
+type CladeDef struct {
+	Name  string
+	Terms []string
+}
+
+// ReadCladeDefs reads a list of clade definitions
+// from a reader.
+//
+// Each clade is defined in a single line,
+// with the name of the clade,
+// an equal sign,
+// and the list of terminals of the clade,
+// for example:
+//
+//	Primates = Homo_sapiens Lemur_catta
+//
+// Lines starting with '#' are ignored.
+func ReadCladeDefs(r io.Reader) ([]CladeDef, error) {
+	var defs []CladeDef
+	names := make(map[string]bool)
+	s := bufio.NewScanner(r)
+	ln := 0
+	for s.Scan() {
+		ln++
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i < 0 {
+			return nil, errors.Errorf("tree: clade definition: line %d: expecting '='", ln)
+		}
+		name := strings.TrimSpace(line[:i])
+		if name == "" {
+			return nil, errors.Errorf("tree: clade definition: line %d: empty clade name", ln)
+		}
+		if names[name] {
+			return nil, errors.Errorf("tree: clade definition: line %d: clade %s repeated", ln, name)
+		}
+		terms := strings.Fields(line[i+1:])
+		if len(terms) == 0 {
+			return nil, errors.Errorf("tree: clade definition: line %d: clade %s without terminals", ln, name)
+		}
+		names[name] = true
+		defs = append(defs, CladeDef{Name: name, Terms: terms})
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrap(err, "tree: clade definition")
+	}
+	return defs, nil
+}
+
+// FindClade returns the definition
+// of a clade with a given name.
+func FindClade(defs []CladeDef, name string) (CladeDef, bool) {
+	for _, d := range defs {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return CladeDef{}, false
+}
+
+// IsMonophyletic returns true
+// if the indicated terminals form a clade
+// in the tree.
+// Terminals not found in the tree are ignored,
+// so the test can be done
+// on trees with incomplete sampling.
+// It returns an error
+// if none of the terminals are in the tree.
+func (t *Tree) IsMonophyletic(terms []string) (bool, error) {
+	tips := make(map[string]bool)
+	for _, nm := range t.Terms() {
+		tips[nm] = true
+	}
+	var in []string
+	for _, nm := range terms {
+		if tips[nm] {
+			in = append(in, nm)
+		}
+	}
+	if len(in) == 0 {
+		return false, errors.New("tree: monophyly: no terminal in tree")
+	}
+	mrca, err := t.MRCA(in)
+	if err != nil {
+		return false, err
+	}
+	return len(mrca.Terms()) == len(in), nil
+}
+
+// Subtree returns a copy of the subtree
+// rooted at the most recent common ancestor
+// of the indicated terminals.
+func (t *Tree) Subtree(terms []string) (*Tree, error) {
+	mrca, err := t.MRCA(terms)
+	if err != nil {
+		return nil, err
+	}
+	return &Tree{Root: mrca.copy(), Lens: t.Lens}, nil
+}
+
+// Copy returns a copy of the tree.
+func (t *Tree) Copy() *Tree {
+	return &Tree{Name: t.Name, Root: t.Root.copy(), Lens: t.Lens}
+}
+
+// Copy returns a copy of a node
+// and its descendants.
+func (n *Node) copy() *Node {
+	c := &Node{
+		Name:    n.Name,
+		Label:   n.Label,
+		Len:     n.Len,
+		Comment: n.Comment,
+	}
+	for _, d := range n.Desc {
+		c.Add(d.copy())
+	}
+	return c
+}
+
+// NameClades sets the name of each defined clade
+// as a persistent label
+// of the most recent common ancestor
+// of the clade terminals,
+// if the clade is monophyletic in the tree.
+// It returns the names of the clades
+// that were not labeled.
+func (t *Tree) NameClades(defs []CladeDef) []string {
+	tips := make(map[string]bool)
+	for _, nm := range t.Terms() {
+		tips[nm] = true
+	}
+	var missing []string
+	for _, d := range defs {
+		var in []string
+		for _, nm := range d.Terms {
+			if tips[nm] {
+				in = append(in, nm)
+			}
+		}
+		if len(in) == 0 {
+			missing = append(missing, d.Name)
+			continue
+		}
+		mrca, err := t.MRCA(in)
+		if err != nil || len(mrca.Terms()) != len(in) {
+			missing = append(missing, d.Name)
+			continue
+		}
+		mrca.SetCladeName(d.Name)
+	}
+	return missing
+}
+
+// CladeKey is the annotation key
+// used to store the name of a clade.
+const cladeKey = "clade"
+
+// CladeName returns the name of the clade
+// rooted at a node,
+// or an empty string,
+// if the node is not labeled.
+//
+// The clade name is stored in the comment of the node
+// as an annotation in FigTree format,
+// i.e. [&clade="name"],
+// so it is preserved when the tree is written
+// and read again.
+func (n *Node) CladeName() string {
+	ann, _ := annotations(n.Comment)
+	for _, a := range ann {
+		i := strings.Index(a, "=")
+		if i < 0 || a[:i] != cladeKey {
+			continue
+		}
+		return strings.Trim(a[i+1:], "\"")
+	}
+	return ""
+}
+
+// SetCladeName sets the name of the clade
+// rooted at a node.
+// If name is empty,
+// the clade name is removed.
+func (n *Node) SetCladeName(name string) {
+	ann, rest := annotations(n.Comment)
+	set := false
+	for i := 0; i < len(ann); i++ {
+		a := ann[i]
+		if j := strings.Index(a, "="); j < 0 || a[:j] != cladeKey {
+			continue
+		}
+		if name == "" {
+			ann = append(ann[:i], ann[i+1:]...)
+			i--
+			continue
+		}
+		ann[i] = cladeKey + "=\"" + name + "\""
+		set = true
+	}
+	if !set && name != "" {
+		ann = append(ann, cladeKey+"=\""+name+"\"")
+	}
+
+	c := ""
+	if len(ann) > 0 {
+		c = "&" + strings.Join(ann, ",")
+	}
+	if rest != "" {
+		if c != "" {
+			c += " "
+		}
+		c += rest
+	}
+	n.Comment = c
+}
+
+// Annotations returns the key-value pairs
+// of a comment in FigTree format,
+// and the rest of the comment.
+// Commas and spaces inside quotes
+// are not taken as separators.
+func annotations(c string) (ann []string, rest string) {
+	if !strings.HasPrefix(c, "&") {
+		return nil, c
+	}
+	quote := false
+	start := 1
+	for i, r := range c {
+		switch r {
+		case '"':
+			quote = !quote
+		case ',':
+			if quote {
+				continue
+			}
+			ann = append(ann, c[start:i])
+			start = i + 1
+		case ' ':
+			if quote {
+				continue
+			}
+			if start < i {
+				ann = append(ann, c[start:i])
+			}
+			return ann, strings.TrimSpace(c[i:])
+		}
+	}
+	if start < len(c) {
+		ann = append(ann, c[start:])
+	}
+	return ann, ""
+}
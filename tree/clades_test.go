@@ -0,0 +1,120 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package tree
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var cladeDefsBlob = `# clade definitions
+Ingroup = B C D E
+DE= D E
+
+Non-clade = B E
+`
+
+func TestCladeDefs(t *testing.T) {
+	defs, err := ReadCladeDefs(strings.NewReader(cladeDefsBlob))
+	if err != nil {
+		t.Fatalf("tree: clade definition: unexpected error: %v", err)
+	}
+	if len(defs) != 3 {
+		t.Fatalf("tree: clade definition: %d clades, want %d", len(defs), 3)
+	}
+	d, ok := FindClade(defs, "DE")
+	if !ok {
+		t.Fatalf("tree: clade definition: clade DE not found")
+	}
+	if strings.Join(d.Terms, " ") != "D E" {
+		t.Errorf("tree: clade definition: DE terminals %v", d.Terms)
+	}
+
+	if _, err := ReadCladeDefs(strings.NewReader("A B C\n")); err == nil {
+		t.Errorf("tree: clade definition: expecting error on line without '='")
+	}
+
+	tr, err := Read(strings.NewReader("(A,(B,(C,(D,E))));"))
+	if err != nil {
+		t.Fatalf("tree: clade definition: unexpected error: %v", err)
+	}
+	want := map[string]bool{
+		"Ingroup":   true,
+		"DE":        true,
+		"Non-clade": false,
+	}
+	for _, d := range defs {
+		m, err := tr.IsMonophyletic(d.Terms)
+		if err != nil {
+			t.Errorf("tree: monophyly: %s: unexpected error: %v", d.Name, err)
+			continue
+		}
+		if m != want[d.Name] {
+			t.Errorf("tree: monophyly: %s: %v, want %v", d.Name, m, want[d.Name])
+		}
+	}
+
+	// with missing terminals
+	if m, _ := tr.IsMonophyletic([]string{"D", "E", "F"}); !m {
+		t.Errorf("tree: monophyly: clade with missing terminal should be monophyletic")
+	}
+
+	st, err := tr.Subtree([]string{"C", "E"})
+	if err != nil {
+		t.Fatalf("tree: subtree: unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	st.Write(&buf, true)
+	if s := buf.String(); s != "(C,(D,E));" {
+		t.Errorf("tree: subtree: %s, want %s", s, "(C,(D,E));")
+	}
+}
+
+func TestNameClades(t *testing.T) {
+	defs, err := ReadCladeDefs(strings.NewReader(cladeDefsBlob))
+	if err != nil {
+		t.Fatalf("tree: clade names: unexpected error: %v", err)
+	}
+	tr, err := Read(strings.NewReader("(A,(B,(C,(D,E)[&support=90])));"))
+	if err != nil {
+		t.Fatalf("tree: clade names: unexpected error: %v", err)
+	}
+	missing := tr.NameClades(defs)
+	if len(missing) != 1 || missing[0] != "Non-clade" {
+		t.Errorf("tree: clade names: missing %v, want [Non-clade]", missing)
+	}
+
+	var buf bytes.Buffer
+	tr.Write(&buf, true)
+	want := `(A,(B,(C,(D,E)[&support=90,clade="DE"]))[&clade="Ingroup"]);`
+	if s := buf.String(); s != want {
+		t.Errorf("tree: clade names: %s, want %s", s, want)
+	}
+
+	// labels are preserved when reading the tree
+	tr, err = Read(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatalf("tree: clade names: unexpected error: %v", err)
+	}
+	n, _ := tr.MRCA([]string{"D", "E"})
+	if nm := n.CladeName(); nm != "DE" {
+		t.Errorf("tree: clade names: %q, want %q", nm, "DE")
+	}
+
+	n.SetCladeName("Two words")
+	if nm := n.CladeName(); nm != "Two words" {
+		t.Errorf("tree: clade names: %q, want %q", nm, "Two words")
+	}
+	n.SetCladeName("")
+	if n.Comment != "&support=90" {
+		t.Errorf("tree: clade names: comment %q, want %q", n.Comment, "&support=90")
+	}
+	n.Comment = "a note"
+	n.SetCladeName("DE")
+	if n.Comment != `&clade="DE" a note` {
+		t.Errorf("tree: clade names: comment %q", n.Comment)
+	}
+}
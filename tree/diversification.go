@@ -0,0 +1,160 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package tree
+
+import (
+	"math"
+	"sort"
+)
+
+// BranchingTimes returns the ages
+// of the branching events of an ultrametric tree,
+// from the oldest to the youngest.
+// A polytomy with n descendants
+// is taken as n-1 branching events
+// of the same age.
+func (t *Tree) BranchingTimes() []float64 {
+	ages := t.NodeAges()
+	var bt []float64
+	t.PreOrder(func(n *Node) {
+		for i := 1; i < len(n.Desc); i++ {
+			bt = append(bt, ages[n])
+		}
+	})
+	sort.Sort(sort.Reverse(sort.Float64Slice(bt)))
+	return bt
+}
+
+// Intervals returns the internode intervals
+// of an ultrametric tree.
+// The element i of the returned slice
+// is the time in which there are i+2 lineages.
+func (t *Tree) intervals() []float64 {
+	bt := t.BranchingTimes()
+	g := make([]float64, len(bt))
+	for i := range bt {
+		next := float64(0)
+		if i+1 < len(bt) {
+			next = bt[i+1]
+		}
+		g[i] = bt[i] - next
+	}
+	return g
+}
+
+// Gamma returns the gamma statistic
+// of Pybus and Harvey (2000)
+// for an ultrametric tree.
+// Negative values indicate that internal nodes
+// are closer to the root than expected
+// under a pure birth model.
+// The tree must have at least three terminals.
+func (t *Tree) Gamma() float64 {
+	g := t.intervals()
+	n := len(g) + 1
+	if n < 3 {
+		return 0
+	}
+	T := float64(0)
+	for i, v := range g {
+		T += float64(i+2) * v
+	}
+	sum, acc := float64(0), float64(0)
+	for i := 0; i < n-2; i++ {
+		acc += float64(i+2) * g[i]
+		sum += acc
+	}
+	num := sum/float64(n-2) - T/2
+	return num / (T * math.Sqrt(1/(12*float64(n-2))))
+}
+
+// GammaP returns the two-tailed probability
+// of a gamma value,
+// under a standard normal distribution.
+func GammaP(gamma float64) float64 {
+	return math.Erfc(math.Abs(gamma) / math.Sqrt2)
+}
+
+// YuleRate returns the maximum likelihood estimate
+// of the speciation rate
+// under a pure birth (Yule) model,
+// conditioned on the root age.
+func (t *Tree) YuleRate() float64 {
+	g := t.intervals()
+	n := len(g) + 1
+	if n < 3 {
+		return 0
+	}
+	T := float64(0)
+	for i, v := range g {
+		T += float64(i+2) * v
+	}
+	if T == 0 {
+		return 0
+	}
+	return float64(n-2) / T
+}
+
+// A RateInterval is the estimated speciation rate
+// on a time interval.
+type RateInterval struct {
+	Start, End float64 // Age of the interval
+	Events     int     // Number of branching events
+	Lineages   float64 // Sum of lineage time in the interval
+}
+
+// Rate returns the speciation rate of the interval.
+func (ri RateInterval) Rate() float64 {
+	if ri.Lineages == 0 {
+		return 0
+	}
+	return float64(ri.Events) / ri.Lineages
+}
+
+// RateIntervals returns the pure birth speciation rate,
+// in a given number of time intervals
+// of the same length,
+// from the root to the present.
+// The branching event at the root is not counted.
+func (t *Tree) RateIntervals(bins int) []RateInterval {
+	bt := t.BranchingTimes()
+	age := t.Age()
+	if bins < 1 || len(bt) == 0 {
+		return nil
+	}
+	size := age / float64(bins)
+	ri := make([]RateInterval, bins)
+	for i := range ri {
+		ri[i].Start = age - float64(i)*size
+		ri[i].End = age - float64(i+1)*size
+	}
+	ri[bins-1].End = 0
+
+	// lineage time
+	for i, b := range bt {
+		end := float64(0)
+		if i+1 < len(bt) {
+			end = bt[i+1]
+		}
+		k := float64(i + 2)
+		for j := range ri {
+			lo := math.Max(end, ri[j].End)
+			hi := math.Min(b, ri[j].Start)
+			if hi > lo {
+				ri[j].Lineages += k * (hi - lo)
+			}
+		}
+	}
+
+	// events
+	for _, b := range bt[1:] {
+		j := int((age - b) / size)
+		if j >= bins {
+			j = bins - 1
+		}
+		ri[j].Events++
+	}
+	return ri
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package tree
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestDiversification(t *testing.T) {
+	tr, err := Read(strings.NewReader("(((A:1,B:1):1,C:2):1,D:3);"))
+	if err != nil {
+		t.Fatalf("tree: diversification: unexpected error: %v", err)
+	}
+	bt := tr.BranchingTimes()
+	want := []float64{3, 2, 1}
+	for i, b := range bt {
+		if math.Abs(b-want[i]) > 0.000001 {
+			t.Errorf("tree: diversification: branching time %d: %.6f, want %.6f", i, b, want[i])
+		}
+	}
+
+	// g = 1, 1, 1; T = 2+3+4 = 9
+	// sum = 2 + 5 = 7; num = 7/2 - 9/2 = -1
+	// gamma = -1 / (9 * sqrt(1/24))
+	if g, w := tr.Gamma(), -1/(9*math.Sqrt(1.0/24)); math.Abs(g-w) > 0.000001 {
+		t.Errorf("tree: diversification: gamma %.6f, want %.6f", g, w)
+	}
+	if r, w := tr.YuleRate(), 2.0/9; math.Abs(r-w) > 0.000001 {
+		t.Errorf("tree: diversification: yule rate %.6f, want %.6f", r, w)
+	}
+
+	ri := tr.RateIntervals(3)
+	if len(ri) != 3 {
+		t.Fatalf("tree: diversification: %d intervals, want %d", len(ri), 3)
+	}
+	for i, w := range []float64{0, 1.0 / 3, 1.0 / 4} {
+		if math.Abs(ri[i].Rate()-w) > 0.000001 {
+			t.Errorf("tree: diversification: interval %d: rate %.6f, want %.6f", i, ri[i].Rate(), w)
+		}
+	}
+}
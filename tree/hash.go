@@ -0,0 +1,97 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package tree
+
+import (
+	"hash/fnv"
+	"sort"
+	"strings"
+)
+
+// Canonical returns the canonical form
+// of the topology of the tree,
+// i.e. a parenthetical string
+// in which the descendants of each node
+// are sorted,
+// so two trees with the same topology,
+// but with its nodes rotated,
+// have the same canonical form.
+// Branch lengths and labels are ignored.
+func (t *Tree) Canonical() string {
+	return t.Root.canonical()
+}
+
+// Canonical returns the canonical form
+// of a node and its descendants.
+func (n *Node) canonical() string {
+	if n.IsTerm() {
+		return quote(n.Name)
+	}
+	ls := make([]string, 0, len(n.Desc))
+	for _, d := range n.Desc {
+		ls = append(ls, d.canonical())
+	}
+	sort.Strings(ls)
+	return "(" + strings.Join(ls, ",") + ")"
+}
+
+// Hash returns a hash
+// of the canonical form of the tree.
+// Trees with the same topology
+// have the same hash,
+// but as in any hash,
+// different topologies can have the same hash,
+// so the canonical forms should be compared
+// to confirm that two trees are identical.
+func (t *Tree) Hash() uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(t.Canonical()))
+	return h.Sum64()
+}
+
+// A Unique is a set of topologies,
+// used to detect repeated trees
+// in a collection.
+// Trees are indexed by its hash,
+// and compared by its canonical form
+// only when the hashes are equal,
+// so only the added trees,
+// and a hash of each one,
+// are kept in memory.
+// The added trees should not be modified.
+type Unique struct {
+	keys map[uint64][]*Tree
+}
+
+// Add adds a tree to the set.
+// It returns false
+// if the topology of the tree
+// was already in the set.
+func (u *Unique) Add(t *Tree) bool {
+	if u.keys == nil {
+		u.keys = make(map[uint64][]*Tree)
+	}
+	c := t.Canonical()
+	h := fnv.New64a()
+	h.Write([]byte(c))
+	k := h.Sum64()
+	for _, o := range u.keys[k] {
+		if o.Canonical() == c {
+			return false
+		}
+	}
+	u.keys[k] = append(u.keys[k], t)
+	return true
+}
+
+// Len returns the number of topologies
+// in the set.
+func (u *Unique) Len() int {
+	n := 0
+	for _, ls := range u.keys {
+		n += len(ls)
+	}
+	return n
+}
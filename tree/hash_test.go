@@ -0,0 +1,66 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package tree
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCanonical(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"((A,B),(C,D));", "((D,C),(B,A));", true},
+		{"((A:1,B:2)0.9,C,D);", "(D,(B,A),C);", true},
+		{"((A,B),(C,D));", "((A,C),(B,D));", false},
+		{"((A,B),(C,D));", "(A,(B,(C,D)));", false},
+		{"((A,B),C,D);", "((A,B),(C,D));", false},
+	}
+	for _, test := range tests {
+		a, err := Read(strings.NewReader(test.a))
+		if err != nil {
+			t.Fatalf("tree: canonical: %s: unexpected error: %v", test.a, err)
+		}
+		b, err := Read(strings.NewReader(test.b))
+		if err != nil {
+			t.Fatalf("tree: canonical: %s: unexpected error: %v", test.b, err)
+		}
+		if same := a.Canonical() == b.Canonical(); same != test.same {
+			t.Errorf("tree: canonical: %s and %s: same %v, want %v", test.a, test.b, same, test.same)
+		}
+		if same := a.Hash() == b.Hash(); same != test.same {
+			t.Errorf("tree: canonical: %s and %s: same hash %v, want %v", test.a, test.b, same, test.same)
+		}
+	}
+	a, _ := Read(strings.NewReader("((D,C),(B,A));"))
+	if c := a.Canonical(); c != "((A,B),(C,D))" {
+		t.Errorf("tree: canonical: got %s, want %s", c, "((A,B),(C,D))")
+	}
+}
+
+func TestUnique(t *testing.T) {
+	var u Unique
+	n := 0
+	for _, s := range []string{
+		"((A,B),(C,D));",
+		"((C,D),(A,B));",
+		"((A,C),(B,D));",
+		"(A,(B,(C,D)));",
+		"((A,C),(D,B));",
+	} {
+		tr, err := Read(strings.NewReader(s))
+		if err != nil {
+			t.Fatalf("tree: unique: %s: unexpected error: %v", s, err)
+		}
+		if u.Add(tr) {
+			n++
+		}
+	}
+	if n != 3 || u.Len() != 3 {
+		t.Errorf("tree: unique: %d trees added, %d in set, want %d", n, u.Len(), 3)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package tree
+
+import "math"
+
+// Lambda transforms the branch lengths
+// with Pagel's lambda:
+// the internal branches are multiplied by lambda,
+// and the terminal branches are extended,
+// so the distance from the root
+// to each terminal is unchanged.
+// With a lambda of 0,
+// the tree becomes a star tree.
+func (t *Tree) Lambda(lambda float64) {
+	dist := t.rootDists()
+	for _, n := range t.Nodes() {
+		if n == t.Root {
+			continue
+		}
+		if n.IsTerm() {
+			n.Len += (1 - lambda) * dist[n.Anc]
+			continue
+		}
+		n.Len *= lambda
+	}
+}
+
+// Kappa transforms the branch lengths
+// with Pagel's kappa:
+// each branch length is raised
+// to the power kappa.
+// With a kappa of 0,
+// all branches
+// (except branches of length 0)
+// have the same length.
+func (t *Tree) Kappa(kappa float64) {
+	for _, n := range t.Nodes() {
+		if n == t.Root || n.Len == 0 {
+			continue
+		}
+		n.Len = math.Pow(n.Len, kappa)
+	}
+}
+
+// Delta transforms the branch lengths
+// with Pagel's delta:
+// the distance from the root
+// of each node
+// is raised to the power delta.
+// With a delta greater than 1,
+// the branches near the terminals
+// become longer.
+func (t *Tree) Delta(delta float64) {
+	dist := t.rootDists()
+	for _, n := range t.Nodes() {
+		if n == t.Root {
+			continue
+		}
+		n.Len = math.Pow(dist[n], delta) - math.Pow(dist[n.Anc], delta)
+	}
+}
@@ -0,0 +1,46 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package tree
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestPagel(t *testing.T) {
+	blob := "((A:1,B:1):2,(C:2,D:2):1);"
+	tests := []struct {
+		name      string
+		transform func(t *Tree)
+		want      map[string]float64
+	}{
+		{"lambda 0.5", func(t *Tree) { t.Lambda(0.5) }, map[string]float64{"A": 2, "C": 2.5, "A B": 1, "C D": 0.5}},
+		{"lambda 0", func(t *Tree) { t.Lambda(0) }, map[string]float64{"A": 3, "C": 3, "A B": 0, "C D": 0}},
+		{"kappa 0", func(t *Tree) { t.Kappa(0) }, map[string]float64{"A": 1, "C": 1, "A B": 1, "C D": 1}},
+		{"kappa 2", func(t *Tree) { t.Kappa(2) }, map[string]float64{"A": 1, "C": 4, "A B": 4, "C D": 1}},
+		{"delta 2", func(t *Tree) { t.Delta(2) }, map[string]float64{"A": 5, "C": 8, "A B": 4, "C D": 1}},
+		{"delta 1", func(t *Tree) { t.Delta(1) }, map[string]float64{"A": 1, "C": 2, "A B": 2, "C D": 1}},
+	}
+	for _, test := range tests {
+		tr, err := Read(strings.NewReader(blob))
+		if err != nil {
+			t.Fatalf("tree: pagel: unexpected error: %v", err)
+		}
+		test.transform(tr)
+		for _, n := range tr.Nodes() {
+			if n == tr.Root {
+				continue
+			}
+			w, ok := test.want[strings.Join(n.Terms(), " ")]
+			if !ok {
+				continue
+			}
+			if math.Abs(n.Len-w) > 1e-9 {
+				t.Errorf("tree: pagel: %s: node %v: length %.6f, want %.6f", test.name, n.Terms(), n.Len, w)
+			}
+		}
+	}
+}
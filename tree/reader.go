@@ -0,0 +1,589 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package tree
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// A Reader reads trees from a reader.
+//
+// The trees can be stored
+// in parenthetical (newick) format,
+// separated by semicolons,
+// or in the trees block of a NEXUS file.
+// In parenthetical format,
+// lines starting with '#' are ignored.
+// In NEXUS files,
+// translation tables are used
+// to set the terminal names.
+type Reader struct {
+	r         *bufio.Reader
+	nexus     bool              // the file is a NEXUS file
+	inTrees   bool              // the reader is inside a trees block
+	translate map[string]string // translation table
+	tree      *Tree
+	err       error
+}
+
+// NewReader returns a tree reader
+// that reads from r.
+func NewReader(r io.Reader) *Reader {
+	tr := &Reader{r: bufio.NewReader(r)}
+	if err := skipSpaces(tr.r); err != nil {
+		tr.err = err
+		return tr
+	}
+	b, _ := tr.r.Peek(6)
+	if strings.ToLower(string(b)) == "#nexus" {
+		tr.nexus = true
+		tr.r.ReadString('\n')
+	}
+	return tr
+}
+
+// Read reads the first tree from a reader.
+func Read(r io.Reader) (*Tree, error) {
+	tr := NewReader(r)
+	if !tr.Scan() {
+		if err := tr.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errors.New("tree: read: no tree found")
+	}
+	return tr.Tree(), nil
+}
+
+// Scan moves the reader to the next tree.
+// If there are no more trees,
+// or an error happens while reading it,
+// it will return false.
+// A call to Err should be made to discriminate
+// among these options.
+//
+// Every call to Tree,
+// even the first one,
+// must be preceded by a Scan call.
+func (tr *Reader) Scan() bool {
+	if tr.err != nil {
+		return false
+	}
+	if tr.nexus {
+		return tr.scanNexus()
+	}
+	for {
+		if err := skipSpaces(tr.r); err != nil {
+			tr.err = err
+			return false
+		}
+		r1, _, _ := tr.r.ReadRune()
+		if r1 == '#' {
+			tr.r.ReadString('\n')
+			continue
+		}
+		if r1 == '[' {
+			if _, err := readComment(tr.r); err != nil {
+				tr.err = errors.Wrap(err, "tree: reader")
+				return false
+			}
+			continue
+		}
+		if r1 == ';' {
+			continue
+		}
+		tr.r.UnreadRune()
+		break
+	}
+	t, err := parse(tr.r)
+	if err != nil {
+		tr.err = errors.Wrap(err, "tree: reader")
+		return false
+	}
+	tr.tree = t
+	return true
+}
+
+// ScanNexus reads the next tree
+// of a NEXUS file.
+func (tr *Reader) scanNexus() bool {
+	for {
+		tk, err := readToken(tr.r)
+		if err != nil {
+			tr.err = err
+			return false
+		}
+		tk = strings.ToLower(tk)
+		if !tr.inTrees {
+			if tk == "begin" {
+				bk, err := readToken(tr.r)
+				if err != nil {
+					tr.err = errors.Wrap(err, "tree: reader: nexus")
+					return false
+				}
+				if strings.ToLower(strings.TrimSuffix(bk, ";")) == "trees" {
+					tr.inTrees = true
+					tr.translate = nil
+				}
+				tk = bk
+			}
+			if err := skipCommand(tr.r, tk); err != nil {
+				tr.err = errors.Wrap(err, "tree: reader: nexus")
+				return false
+			}
+			continue
+		}
+
+		switch tk {
+		case "end;", "endblock;", "end", "endblock":
+			tr.inTrees = false
+			if err := skipCommand(tr.r, tk); err != nil {
+				tr.err = errors.Wrap(err, "tree: reader: nexus")
+				return false
+			}
+		case "translate":
+			if err := tr.readTranslate(); err != nil {
+				tr.err = errors.Wrap(err, "tree: reader: nexus: translate")
+				return false
+			}
+		case "tree", "utree":
+			name, err := readTreeName(tr.r)
+			if err != nil {
+				tr.err = errors.Wrap(err, "tree: reader: nexus: tree")
+				return false
+			}
+			t, err := parse(tr.r)
+			if err != nil {
+				tr.err = errors.Wrapf(err, "tree: reader: nexus: tree %s", name)
+				return false
+			}
+			t.Name = name
+			if tr.translate != nil {
+				for _, n := range t.Nodes() {
+					if !n.IsTerm() {
+						continue
+					}
+					if nm, ok := tr.translate[n.Name]; ok {
+						n.Name = nm
+					}
+				}
+			}
+			tr.tree = t
+			return true
+		default:
+			if err := skipCommand(tr.r, tk); err != nil {
+				tr.err = errors.Wrap(err, "tree: reader: nexus")
+				return false
+			}
+		}
+	}
+}
+
+// Tree returns the last read tree.
+//
+// Every call to Tree,
+// even the first one,
+// must be preceded by a Scan call.
+func (tr *Reader) Tree() *Tree {
+	if tr.tree == nil {
+		panic("tree: scan should be called before tree")
+	}
+	t := tr.tree
+	tr.tree = nil
+	return t
+}
+
+// Err returns the last error
+// found during iteration.
+func (tr *Reader) Err() error {
+	if tr.err == io.EOF {
+		return nil
+	}
+	return tr.err
+}
+
+// ReadTranslate reads a translation table.
+func (tr *Reader) readTranslate() error {
+	tr.translate = make(map[string]string)
+	for {
+		id, err := readToken(tr.r)
+		if err != nil {
+			return err
+		}
+		if id == ";" {
+			return nil
+		}
+		nm, err := readToken(tr.r)
+		if err != nil {
+			return err
+		}
+		end := false
+		if strings.HasSuffix(nm, ";") {
+			end = true
+		}
+		nm = strings.TrimRight(nm, ",;")
+		tr.translate[id] = nm
+		if end {
+			return nil
+		}
+		if err := skipSpaces(tr.r); err != nil {
+			return err
+		}
+		r1, _, _ := tr.r.ReadRune()
+		if r1 == ';' {
+			return nil
+		}
+		if r1 != ',' {
+			tr.r.UnreadRune()
+		}
+	}
+}
+
+// ReadTreeName reads the name of a tree
+// in a NEXUS tree command,
+// up to the equal sign.
+func readTreeName(r *bufio.Reader) (string, error) {
+	var b strings.Builder
+	for {
+		r1, _, err := r.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		if r1 == '=' {
+			break
+		}
+		if r1 == '[' {
+			if _, err := readComment(r); err != nil {
+				return "", err
+			}
+			continue
+		}
+		b.WriteRune(r1)
+	}
+	nm := strings.TrimSpace(b.String())
+	if strings.HasPrefix(nm, "*") {
+		nm = strings.TrimSpace(nm[1:])
+	}
+	return strings.Trim(nm, "'"), nil
+}
+
+// ReadToken reads a NEXUS token,
+// skipping comments.
+// Quoted tokens are returned without quotes.
+func readToken(r *bufio.Reader) (string, error) {
+	for {
+		if err := skipSpaces(r); err != nil {
+			return "", err
+		}
+		r1, _, _ := r.ReadRune()
+		if r1 == '[' {
+			if _, err := readComment(r); err != nil {
+				return "", err
+			}
+			continue
+		}
+		if r1 == ';' || r1 == ',' {
+			return string(r1), nil
+		}
+		r.UnreadRune()
+		break
+	}
+	r1, _, _ := r.ReadRune()
+	if r1 == '\'' {
+		nm, err := readQuoted(r)
+		if err != nil {
+			return "", err
+		}
+		r2, _, err := r.ReadRune()
+		if err == nil {
+			if r2 == ';' || r2 == ',' {
+				return nm + string(r2), nil
+			}
+			r.UnreadRune()
+		}
+		return nm, nil
+	}
+	r.UnreadRune()
+	var b strings.Builder
+	for {
+		r1, _, err := r.ReadRune()
+		if err != nil {
+			if err == io.EOF && b.Len() > 0 {
+				break
+			}
+			return "", err
+		}
+		if unicode.IsSpace(r1) {
+			break
+		}
+		if r1 == '[' {
+			r.UnreadRune()
+			break
+		}
+		b.WriteRune(r1)
+		if r1 == ';' || r1 == ',' {
+			break
+		}
+	}
+	return b.String(), nil
+}
+
+// SkipCommand skips a NEXUS command
+// up to the semicolon.
+func skipCommand(r *bufio.Reader, tk string) error {
+	if strings.HasSuffix(tk, ";") {
+		return nil
+	}
+	for {
+		r1, _, err := r.ReadRune()
+		if err != nil {
+			return err
+		}
+		if r1 == '[' {
+			if _, err := readComment(r); err != nil {
+				return err
+			}
+			continue
+		}
+		if r1 == '\'' {
+			if _, err := readQuoted(r); err != nil {
+				return err
+			}
+			continue
+		}
+		if r1 == ';' {
+			return nil
+		}
+	}
+}
+
+// Parse reads a tree in parenthetical format.
+func parse(r *bufio.Reader) (*Tree, error) {
+	t := &Tree{}
+	root, err := t.readNode(r)
+	if err != nil {
+		return nil, err
+	}
+	t.Root = root
+	if err := skipSpaces(r); err != nil {
+		if err == io.EOF {
+			return t, nil
+		}
+		return nil, err
+	}
+	r1, _, _ := r.ReadRune()
+	if r1 != ';' {
+		r.UnreadRune()
+	}
+	return t, nil
+}
+
+// ReadNode reads a node from a reader.
+func (t *Tree) readNode(r *bufio.Reader) (*Node, error) {
+	var r1 rune
+	for {
+		if err := skipSpaces(r); err != nil {
+			return nil, err
+		}
+		r1, _, _ = r.ReadRune()
+		if r1 != '[' {
+			break
+		}
+		// comments before a node are ignored
+		if _, err := readComment(r); err != nil {
+			return nil, err
+		}
+	}
+	n := &Node{}
+	if r1 == '(' {
+		for {
+			if err := skipSpaces(r); err != nil {
+				return nil, err
+			}
+			r1, _, _ := r.ReadRune()
+			if r1 == ',' {
+				continue
+			}
+			if r1 == ')' {
+				break
+			}
+			r.UnreadRune()
+			d, err := t.readNode(r)
+			if err != nil {
+				return nil, err
+			}
+			n.Add(d)
+		}
+		if len(n.Desc) == 0 {
+			return nil, errors.New("node without descendants")
+		}
+		if len(n.Desc) == 1 {
+			// a node with a single descendant
+			// is the same as its descendant
+			d := n.Desc[0]
+			d.Anc = nil
+			n = d
+		}
+		lb, err := readName(r)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if lb != "" {
+			n.Label = lb
+		}
+	} else {
+		r.UnreadRune()
+		name, err := readName(r)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if name == "" {
+			return nil, errors.Errorf("unexpected symbol %q", r1)
+		}
+		n.Name = name
+	}
+
+	// read comments and branch length
+	for {
+		if err := skipSpaces(r); err != nil {
+			if err == io.EOF {
+				return n, nil
+			}
+			return nil, err
+		}
+		r1, _, _ := r.ReadRune()
+		if r1 == '[' {
+			c, err := readComment(r)
+			if err != nil {
+				return nil, err
+			}
+			if n.Comment != "" {
+				n.Comment += " "
+			}
+			n.Comment += c
+			continue
+		}
+		if r1 == ':' {
+			l, err := readBrLen(r)
+			if err != nil {
+				return nil, errors.Wrap(err, "bad branch length")
+			}
+			n.Len = l
+			t.Lens = true
+			continue
+		}
+		r.UnreadRune()
+		return n, nil
+	}
+}
+
+// ReadName reads a terminal name,
+// or a node label.
+func readName(r *bufio.Reader) (string, error) {
+	r1, _, err := r.ReadRune()
+	if err != nil {
+		return "", err
+	}
+	if r1 == '\'' {
+		return readQuoted(r)
+	}
+	r.UnreadRune()
+	var b strings.Builder
+	for {
+		r1, _, err := r.ReadRune()
+		if err != nil {
+			return b.String(), err
+		}
+		if unicode.IsSpace(r1) || strings.ContainsRune(",;:()[]", r1) {
+			r.UnreadRune()
+			break
+		}
+		b.WriteRune(r1)
+	}
+	return b.String(), nil
+}
+
+// ReadQuoted reads a quoted name,
+// the initial quote should be already read.
+func readQuoted(r *bufio.Reader) (string, error) {
+	var b strings.Builder
+	for {
+		r1, _, err := r.ReadRune()
+		if err != nil {
+			return "", errors.Wrap(err, "while reading quoted name")
+		}
+		if r1 == '\'' {
+			r2, _, err := r.ReadRune()
+			if err == nil && r2 == '\'' {
+				b.WriteRune('\'')
+				continue
+			}
+			if err == nil {
+				r.UnreadRune()
+			}
+			break
+		}
+		b.WriteRune(r1)
+	}
+	return b.String(), nil
+}
+
+// ReadComment reads a comment,
+// the initial square bracket should be already read.
+func readComment(r *bufio.Reader) (string, error) {
+	var b strings.Builder
+	for {
+		r1, _, err := r.ReadRune()
+		if err != nil {
+			return "", errors.Wrap(err, "while reading comment")
+		}
+		if r1 == ']' {
+			break
+		}
+		b.WriteRune(r1)
+	}
+	return b.String(), nil
+}
+
+// ReadBrLen reads a branch length.
+func readBrLen(r *bufio.Reader) (float64, error) {
+	if err := skipSpaces(r); err != nil {
+		return 0, err
+	}
+	var b strings.Builder
+	for {
+		r1, _, err := r.ReadRune()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return 0, err
+		}
+		if unicode.IsSpace(r1) || strings.ContainsRune(",;()[]", r1) {
+			r.UnreadRune()
+			break
+		}
+		b.WriteRune(r1)
+	}
+	return strconv.ParseFloat(b.String(), 64)
+}
+
+func skipSpaces(r *bufio.Reader) error {
+	for {
+		r1, _, err := r.ReadRune()
+		if err != nil {
+			return err
+		}
+		if !unicode.IsSpace(r1) {
+			r.UnreadRune()
+			return nil
+		}
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package tree
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+var multiBlob = `
+# Wagner Length: 12
+(A (B (C D)));
+(A,((B,C),D));
+(A:0.1,(B:0.2,(C:0.3,D:0.4)90:0.5):0.6);
+`
+
+var nexusBlob = `#NEXUS
+[ a comment ]
+begin taxa;
+	dimensions ntax=4;
+	taxlabels A B C D;
+end;
+
+begin trees;
+	translate
+		1 A,
+		2 B,
+		3 'C c',
+		4 D
+		;
+	tree gen.1 = [&U] (1:0.1,(2:0.2,(3:0.3,4:0.4):0.5):0.6);
+	tree gen.2 = [&U] (1,((2,3),4));
+end;
+`
+
+var nexusDataBlob = `#NEXUS
+begin data;
+	dimensions ntax=3 nchar=4;
+	format datatype=dna missing=? gap=- interleave;
+	matrix
+	A    AC
+	B    (AG)-
+	'C c' ?T [a comment]
+
+	A    GT
+	B    GT
+	'C c' GA
+	;
+end;
+
+begin trees;
+	translate 1 A, 2 B, 3 'C c';
+	tree one = (1,(2,3));
+end;
+`
+
+func TestReader(t *testing.T) {
+	r := NewReader(strings.NewReader(multiBlob))
+	var trees []*Tree
+	for r.Scan() {
+		trees = append(trees, r.Tree())
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("tree: reader: unexpected error: %v", err)
+	}
+	if len(trees) != 3 {
+		t.Fatalf("tree: reader: %d trees read, want %d", len(trees), 3)
+	}
+	for i, tr := range trees {
+		terms := tr.Terms()
+		sort.Strings(terms)
+		if strings.Join(terms, " ") != "A B C D" {
+			t.Errorf("tree: reader: tree %d: terminals %v, want %v", i, terms, "A B C D")
+		}
+	}
+	if trees[0].Lens {
+		t.Errorf("tree: reader: tree %d: with branch lengths", 0)
+	}
+	if !trees[2].Lens {
+		t.Errorf("tree: reader: tree %d: without branch lengths", 2)
+	}
+	var b bytes.Buffer
+	trees[2].Write(&b, true)
+	if want := "(A:0.100000,(B:0.200000,(C:0.300000,D:0.400000)90:0.500000):0.600000);"; b.String() != want {
+		t.Errorf("tree: reader: tree %d: %s, want %s", 2, b.String(), want)
+	}
+}
+
+func TestNexusReader(t *testing.T) {
+	r := NewReader(strings.NewReader(nexusBlob))
+	var trees []*Tree
+	for r.Scan() {
+		trees = append(trees, r.Tree())
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("tree: reader: nexus: unexpected error: %v", err)
+	}
+	if len(trees) != 2 {
+		t.Fatalf("tree: reader: nexus: %d trees read, want %d", len(trees), 2)
+	}
+	if trees[0].Name != "gen.1" {
+		t.Errorf("tree: reader: nexus: tree name %q, want %q", trees[0].Name, "gen.1")
+	}
+	for i, tr := range trees {
+		terms := tr.Terms()
+		sort.Strings(terms)
+		if strings.Join(terms, ",") != "A,B,C c,D" {
+			t.Errorf("tree: reader: nexus: tree %d: terminals %v, want %v", i, terms, "A,B,C c,D")
+		}
+	}
+}
+
+func TestWriter(t *testing.T) {
+	r := NewReader(strings.NewReader(multiBlob))
+	var b bytes.Buffer
+	w := NewWriter(&b, []string{"D", "C", "B", "A"})
+	for r.Scan() {
+		if err := w.Write(r.Tree()); err != nil {
+			t.Fatalf("tree: writer: unexpected error: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("tree: writer: unexpected error: %v", err)
+	}
+
+	r = NewReader(&b)
+	i := 0
+	for r.Scan() {
+		tr := r.Tree()
+		terms := tr.Terms()
+		sort.Strings(terms)
+		if strings.Join(terms, " ") != "A B C D" {
+			t.Errorf("tree: writer: tree %d: terminals %v, want %v", i, terms, "A B C D")
+		}
+		i++
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("tree: writer: unexpected error: %v", err)
+	}
+	if i != 3 {
+		t.Errorf("tree: writer: %d trees read, want %d", i, 3)
+	}
+
+	tr, _ := Read(strings.NewReader("(A,(B,E));"))
+	if err := w.Write(tr); err == nil {
+		t.Errorf("tree: writer: expecting error on unknown terminal")
+	}
+}
+
+func TestCreate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ramita")
+	if err != nil {
+		t.Fatalf("tree: create: unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "trees.nex")
+
+	w, err := Create(name, []string{"A", "B", "C", "D"})
+	if err != nil {
+		t.Fatalf("tree: create: unexpected error: %v", err)
+	}
+	defer w.Close()
+	if err := w.Comment("seed=1"); err != nil {
+		t.Fatalf("tree: create: unexpected error: %v", err)
+	}
+	tr, _ := Read(strings.NewReader("(A,(B,(C,D)[score=3]));"))
+	tr.Name = "rep1"
+	if err := w.Write(tr); err != nil {
+		t.Fatalf("tree: create: unexpected error: %v", err)
+	}
+
+	// trees are available
+	// before the writer is closed
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatalf("tree: create: unexpected error: %v", err)
+	}
+	defer f.Close()
+	r := NewReader(f)
+	if !r.Scan() {
+		t.Fatalf("tree: create: tree not found: %v", r.Err())
+	}
+	if r.Tree().Name != "rep1" {
+		t.Errorf("tree: create: tree name %q, want %q", r.Tree().Name, "rep1")
+	}
+
+	if err := w.Close(); err != nil {
+		t.Errorf("tree: create: unexpected error: %v", err)
+	}
+	if err := w.Write(tr); err == nil {
+		t.Errorf("tree: create: expecting error on a closed writer")
+	}
+}
+
+func TestNexusWithData(t *testing.T) {
+	r := NewReader(strings.NewReader(nexusDataBlob))
+	var trees []*Tree
+	for r.Scan() {
+		trees = append(trees, r.Tree())
+	}
+	if err := r.Err(); err != nil {
+		t.Fatalf("tree: reader: nexus with data: unexpected error: %v", err)
+	}
+	if len(trees) != 1 {
+		t.Fatalf("tree: reader: nexus with data: %d trees read, want %d", len(trees), 1)
+	}
+	terms := trees[0].Terms()
+	sort.Strings(terms)
+	if strings.Join(terms, ",") != "A,B,C c" {
+		t.Errorf("tree: reader: nexus with data: terminals %v, want %v", terms, "A,B,C c")
+	}
+}
@@ -0,0 +1,227 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package tree
+
+import (
+	"github.com/pkg/errors"
+)
+
+// A Reconciliation is the reconciliation
+// of a gene tree with a species tree.
+// The events are counted on the branch
+// of each node of the species tree.
+type Reconciliation struct {
+	Dups     map[*Node]int // Gene duplications
+	Losses   map[*Node]int // Gene losses
+	DeepCoal map[*Node]int // Deep coalescences (extra lineages)
+}
+
+// Totals returns the total number of duplications,
+// losses,
+// and deep coalescences
+// of a reconciliation.
+func (rc *Reconciliation) Totals() (dups, losses, deep int) {
+	for _, v := range rc.Dups {
+		dups += v
+	}
+	for _, v := range rc.Losses {
+		losses += v
+	}
+	for _, v := range rc.DeepCoal {
+		deep += v
+	}
+	return dups, losses, deep
+}
+
+// Reconcile reconciles a gene tree
+// with a species tree.
+// If smap is not nil,
+// it is used to map the terminals of the gene tree
+// to the terminals of the species tree.
+// Terminals not in the map
+// use its own name as the species.
+//
+// Each node of the gene tree
+// is mapped to the most recent common ancestor
+// of its species,
+// and a gene node is a duplication
+// if it maps to the same species node
+// as one of its descendants
+// (Page 1994, Syst. Biol. 43: 58).
+// Losses are counted on the species branches
+// that are missing in the path of each gene branch.
+// As a species absent from the gene tree
+// is counted as a loss,
+// the gene trees should be complete.
+//
+// Deep coalescences are the extra lineages
+// (Maddison 1997, Syst. Biol. 46: 523),
+// i.e. the number of gene lineages
+// that cross the top of a species branch,
+// minus one,
+// calculated on the species tree
+// restricted to the species of the gene tree.
+// In a gene tree with duplications,
+// paralogs are counted as extra lineages.
+func Reconcile(species, gene *Tree, smap map[string]string) (*Reconciliation, error) {
+	rc := &Reconciliation{
+		Dups:     make(map[*Node]int),
+		Losses:   make(map[*Node]int),
+		DeepCoal: make(map[*Node]int),
+	}
+
+	sp := newSpeciesMap(species)
+	m, err := sp.mapGene(gene, smap)
+	if err != nil {
+		return nil, err
+	}
+
+	// duplications and losses
+	for _, g := range gene.Nodes() {
+		if g.IsTerm() {
+			continue
+		}
+		dup := false
+		for _, c := range g.Desc {
+			if m[c] == m[g] {
+				dup = true
+				break
+			}
+		}
+		if dup {
+			rc.Dups[m[g]]++
+		}
+		for _, c := range g.Desc {
+			for x := m[c]; x != m[g]; x = x.Anc {
+				if !dup && x.Anc == m[g] {
+					break
+				}
+				for _, s := range x.Anc.Desc {
+					if s != x {
+						rc.Losses[s]++
+					}
+				}
+			}
+		}
+	}
+
+	// deep coalescences
+	present := make(map[string]bool)
+	for _, n := range gene.Nodes() {
+		if n.IsTerm() {
+			present[m[n].Name] = true
+		}
+	}
+	var del []string
+	for _, nm := range species.Terms() {
+		if !present[nm] {
+			del = append(del, nm)
+		}
+	}
+	ps := species.Copy()
+	ps.Prune(del)
+	if ps.Root.IsTerm() {
+		return rc, nil
+	}
+	psm := newSpeciesMap(ps)
+	pm, err := psm.mapGene(gene, smap)
+	if err != nil {
+		return nil, err
+	}
+	lineages := make(map[*Node]int)
+	for _, g := range gene.Nodes() {
+		if g == gene.Root {
+			continue
+		}
+		for x := pm[g]; x != pm[g.Anc]; x = x.Anc {
+			lineages[x]++
+		}
+	}
+	for x, k := range lineages {
+		if k < 2 {
+			continue
+		}
+		var ns []*Node
+		for _, nm := range x.Terms() {
+			ns = append(ns, sp.terms[nm])
+		}
+		rc.DeepCoal[sp.lca(ns)] += k - 1
+	}
+	return rc, nil
+}
+
+// A speciesMap stores the nodes of a species tree.
+type speciesMap struct {
+	depth map[*Node]int
+	terms map[string]*Node
+}
+
+func newSpeciesMap(t *Tree) *speciesMap {
+	sp := &speciesMap{
+		depth: make(map[*Node]int),
+		terms: make(map[string]*Node),
+	}
+	for _, n := range t.Nodes() {
+		if n.Anc != nil {
+			sp.depth[n] = sp.depth[n.Anc] + 1
+		}
+		if n.IsTerm() {
+			sp.terms[n.Name] = n
+		}
+	}
+	return sp
+}
+
+// MapGene maps each node of a gene tree
+// to a node of the species tree.
+func (sp *speciesMap) mapGene(gene *Tree, smap map[string]string) (map[*Node]*Node, error) {
+	m := make(map[*Node]*Node)
+	var err error
+	gene.PostOrder(func(g *Node) {
+		if err != nil {
+			return
+		}
+		if g.IsTerm() {
+			nm := g.Name
+			if s, ok := smap[nm]; ok {
+				nm = s
+			}
+			s, ok := sp.terms[nm]
+			if !ok {
+				err = errors.Errorf("tree: reconcile: gene %s: species %s not in species tree", g.Name, nm)
+				return
+			}
+			m[g] = s
+			return
+		}
+		ns := make([]*Node, 0, len(g.Desc))
+		for _, c := range g.Desc {
+			ns = append(ns, m[c])
+		}
+		m[g] = sp.lca(ns)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// LCA returns the most recent common ancestor
+// of a set of species nodes.
+func (sp *speciesMap) lca(ns []*Node) *Node {
+	a := ns[0]
+	for _, b := range ns[1:] {
+		for sp.depth[a] > sp.depth[b] {
+			a = a.Anc
+		}
+		for sp.depth[b] > sp.depth[a] {
+			b = b.Anc
+		}
+		for a != b {
+			a, b = a.Anc, b.Anc
+		}
+	}
+	return a
+}
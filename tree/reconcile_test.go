@@ -0,0 +1,111 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package tree
+
+import (
+	"strings"
+	"testing"
+)
+
+func readOne(t *testing.T, s string) *Tree {
+	r := NewReader(strings.NewReader(s))
+	if !r.Scan() {
+		t.Fatalf("tree: unexpected error: %v", r.Err())
+	}
+	return r.Tree()
+}
+
+// cladeNode returns the node of a clade.
+func cladeNode(t *Tree, terms string) *Node {
+	want := strings.Fields(terms)
+	for _, n := range t.Nodes() {
+		ls := n.Terms()
+		if len(ls) != len(want) {
+			continue
+		}
+		in := make(map[string]bool)
+		for _, nm := range ls {
+			in[nm] = true
+		}
+		ok := true
+		for _, nm := range want {
+			if !in[nm] {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return n
+		}
+	}
+	return nil
+}
+
+func TestReconcile(t *testing.T) {
+	sp := readOne(t, "(A,(B,(C,D)));")
+
+	tests := []struct {
+		gene   string
+		smap   map[string]string
+		dups   map[string]int
+		losses map[string]int
+		deep   map[string]int
+	}{
+		{
+			gene: "(A,(B,(C,D)));",
+		},
+		{
+			// loss of C
+			gene:   "(A,(B,D));",
+			losses: map[string]int{"C": 1},
+		},
+		{
+			// duplication before C and D
+			gene: "(A,(B,((c1,d1),(c2,d2))));",
+			smap: map[string]string{"c1": "C", "c2": "C", "d1": "D", "d2": "D"},
+			dups: map[string]int{"C D": 1},
+			// paralogs are extra lineages
+			deep: map[string]int{"C": 1, "D": 1},
+		},
+		{
+			// incomplete lineage sorting
+			gene:   "(A,(C,(B,D)));",
+			dups:   map[string]int{"B C D": 1},
+			losses: map[string]int{"B": 1, "C": 1, "D": 1},
+			deep:   map[string]int{"C D": 1},
+		},
+	}
+	for _, test := range tests {
+		gene := readOne(t, test.gene)
+		rc, err := Reconcile(sp, gene, test.smap)
+		if err != nil {
+			t.Fatalf("tree: reconcile: %s: unexpected error: %v", test.gene, err)
+		}
+		check := func(name string, got map[*Node]int, want map[string]int) {
+			sum := 0
+			for cl, v := range want {
+				n := cladeNode(sp, cl)
+				if got[n] != v {
+					t.Errorf("tree: reconcile: %s: %s on %s: %d, want %d", test.gene, name, cl, got[n], v)
+				}
+				sum += v
+			}
+			total := 0
+			for _, v := range got {
+				total += v
+			}
+			if total != sum {
+				t.Errorf("tree: reconcile: %s: %s: total %d, want %d", test.gene, name, total, sum)
+			}
+		}
+		check("duplications", rc.Dups, test.dups)
+		check("losses", rc.Losses, test.losses)
+		check("deep coalescences", rc.DeepCoal, test.deep)
+	}
+
+	if _, err := Reconcile(sp, readOne(t, "(A,(B,E));"), nil); err == nil {
+		t.Errorf("tree: reconcile: expecting error on unknown species")
+	}
+}
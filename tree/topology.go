@@ -0,0 +1,172 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package tree
+
+import "github.com/pkg/errors"
+
+// PreOrder calls a function
+// for each node of the tree,
+// in pre-order
+// (i.e. each node is visited
+// before its descendants).
+func (t *Tree) PreOrder(fn func(n *Node)) {
+	var pre func(n *Node)
+	pre = func(n *Node) {
+		fn(n)
+		for _, d := range n.Desc {
+			pre(d)
+		}
+	}
+	pre(t.Root)
+}
+
+// PostOrder calls a function
+// for each node of the tree,
+// in post-order
+// (i.e. each node is visited
+// after its descendants).
+func (t *Tree) PostOrder(fn func(n *Node)) {
+	var post func(n *Node)
+	post = func(n *Node) {
+		for _, d := range n.Desc {
+			post(d)
+		}
+		fn(n)
+	}
+	post(t.Root)
+}
+
+// IsDesc returns true,
+// if the node a,
+// is an ancestor of n
+// (or n itself).
+func (n *Node) IsDesc(a *Node) bool {
+	for n != nil {
+		if n == a {
+			return true
+		}
+		n = n.Anc
+	}
+	return false
+}
+
+// Detach removes a node,
+// and its descendants,
+// from the tree
+// (i.e. it prunes a subtree).
+// If the ancestor of the node
+// is left with a single descendant,
+// the ancestor is removed,
+// and its branch length is added
+// to the branch of its descendant.
+// The detached node can be grafted again
+// with Graft.
+func (t *Tree) Detach(n *Node) error {
+	a := n.Anc
+	if a == nil {
+		return errors.New("tree: detach: node is the root")
+	}
+	a.remove(n)
+	if len(a.Desc) != 1 {
+		return nil
+	}
+	d := a.Desc[0]
+	if a == t.Root {
+		a.remove(d)
+		d.Len = 0
+		t.Root = d
+		return nil
+	}
+	d.Len += a.Len
+	aa := a.Anc
+	for i, c := range aa.Desc {
+		if c == a {
+			aa.Desc[i] = d
+			break
+		}
+	}
+	d.Anc = aa
+	a.Anc, a.Desc = nil, nil
+	return nil
+}
+
+// Graft inserts a node,
+// and its descendants,
+// as the sister of a node of the tree
+// (i.e. it regrafts a subtree),
+// adding a new node
+// at the middle of the branch
+// of the sister node.
+// If the sister node is the root,
+// a new root is added.
+func (t *Tree) Graft(n, sister *Node) error {
+	if n.Anc != nil {
+		return errors.New("tree: graft: node already in a tree")
+	}
+	if sister.IsDesc(n) {
+		return errors.New("tree: graft: sister is descendant of node")
+	}
+	nn := &Node{}
+	if sister == t.Root {
+		nn.Add(sister)
+		nn.Add(n)
+		t.Root = nn
+		return nil
+	}
+	a := sister.Anc
+	for i, c := range a.Desc {
+		if c == sister {
+			a.Desc[i] = nn
+			break
+		}
+	}
+	nn.Anc = a
+	nn.Len = sister.Len / 2
+	sister.Len -= nn.Len
+	nn.Add(sister)
+	nn.Add(n)
+	return nil
+}
+
+// A Binary is a node of a binary tree
+// decorated with the data of an analysis
+// (e.g. the state sets of a parsimony analysis,
+// or the conditional likelihoods
+// of a likelihood analysis).
+type Binary interface {
+	// Children returns the descendants of the node.
+	// It is only called in internal nodes.
+	Children() (left, right Binary)
+
+	// TermName returns the name of the terminal,
+	// or an empty string in internal nodes.
+	TermName() string
+
+	// BranchLen returns the length of the branch
+	// of the node.
+	BranchLen() float64
+}
+
+// FromBinary returns a tree topology
+// from the root of a binary tree.
+// If lens is true,
+// the branch lengths are copied.
+func FromBinary(root Binary, lens bool) *Tree {
+	var build func(b Binary) *Node
+	build = func(b Binary) *Node {
+		n := &Node{Name: b.TermName()}
+		if lens {
+			n.Len = b.BranchLen()
+		}
+		if n.Name != "" {
+			return n
+		}
+		left, right := b.Children()
+		n.Add(build(left))
+		n.Add(build(right))
+		return n
+	}
+	return &Tree{Root: build(root), Lens: lens}
+}
@@ -0,0 +1,114 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package tree
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestTraversal(t *testing.T) {
+	tr, err := Read(strings.NewReader("((A,B),(C,D));"))
+	if err != nil {
+		t.Fatalf("tree: traversal: unexpected error: %v", err)
+	}
+	var pre, post []string
+	tr.PreOrder(func(n *Node) {
+		if n.IsTerm() {
+			pre = append(pre, n.Name)
+			return
+		}
+		pre = append(pre, "*")
+	})
+	tr.PostOrder(func(n *Node) {
+		if n.IsTerm() {
+			post = append(post, n.Name)
+			return
+		}
+		post = append(post, "*")
+	})
+	if s := strings.Join(pre, " "); s != "* * A B * C D" {
+		t.Errorf("tree: traversal: pre-order %q, want %q", s, "* * A B * C D")
+	}
+	if s := strings.Join(post, " "); s != "A B * C D * *" {
+		t.Errorf("tree: traversal: post-order %q, want %q", s, "A B * C D * *")
+	}
+
+	a := tr.Root.Desc[0].Desc[0]
+	if !a.IsDesc(tr.Root.Desc[0]) {
+		t.Errorf("tree: traversal: A is not descendant of (A,B)")
+	}
+	if a.IsDesc(tr.Root.Desc[1]) {
+		t.Errorf("tree: traversal: A is descendant of (C,D)")
+	}
+}
+
+func TestDetachGraft(t *testing.T) {
+	tr, err := Read(strings.NewReader("((A:1,B:2):3,(C:4,D:5):6);"))
+	if err != nil {
+		t.Fatalf("tree: detach: unexpected error: %v", err)
+	}
+	var a, c *Node
+	for _, n := range tr.Nodes() {
+		switch n.Name {
+		case "A":
+			a = n
+		case "C":
+			c = n
+		}
+	}
+
+	if err := tr.Detach(a); err != nil {
+		t.Fatalf("tree: detach: unexpected error: %v", err)
+	}
+	var buf bytes.Buffer
+	tr.Write(&buf, true)
+	if s, want := buf.String(), "(B:5.000000,(C:4.000000,D:5.000000):6.000000);"; s != want {
+		t.Errorf("tree: detach: tree %q, want %q", s, want)
+	}
+
+	if err := tr.Graft(a, c); err != nil {
+		t.Fatalf("tree: graft: unexpected error: %v", err)
+	}
+	if !tr.HasClade([]string{"A", "C"}) {
+		t.Errorf("tree: graft: clade (A,C) not found")
+	}
+	if math.Abs(c.Len-2) > 1e-9 || math.Abs(c.Anc.Len-2) > 1e-9 {
+		t.Errorf("tree: graft: lengths %.6f and %.6f, want %.6f", c.Len, c.Anc.Len, 2.0)
+	}
+
+	// graft at the root
+	if err := tr.Detach(a); err != nil {
+		t.Fatalf("tree: detach: unexpected error: %v", err)
+	}
+	if err := tr.Graft(a, tr.Root); err != nil {
+		t.Fatalf("tree: graft: unexpected error: %v", err)
+	}
+	if a.Anc != tr.Root || len(tr.Root.Desc) != 2 {
+		t.Errorf("tree: graft: A is not at the root")
+	}
+	if len(tr.Terms()) != 4 {
+		t.Errorf("tree: graft: %d terminals, want %d", len(tr.Terms()), 4)
+	}
+
+	// detach a descendant of the root
+	// with two descendants
+	if err := tr.Detach(a); err != nil {
+		t.Fatalf("tree: detach: unexpected error: %v", err)
+	}
+	if tr.Root.Anc != nil || tr.Root.Len != 0 || len(tr.Terms()) != 3 {
+		t.Errorf("tree: detach: invalid root after detach")
+	}
+
+	if err := tr.Detach(tr.Root); err == nil {
+		t.Errorf("tree: detach: expecting error on root")
+	}
+	b := tr.Root.Desc[0]
+	if err := tr.Graft(tr.Root, b); err == nil {
+		t.Errorf("tree: graft: expecting error on a node in a tree")
+	}
+}
@@ -0,0 +1,577 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+// Package tree implements a simple representation
+// of phylogenetic trees,
+// independent of the data used to build them,
+// as well as readers and writers
+// for tree collections.
+//
+// It is the shared representation of trees
+// between optimality criteria:
+// the parsimony and likelihood packages
+// read and write trees using this package,
+// and a tree of one criterion
+// can be used by another one
+// with its Topology method,
+// and the FromTopology function
+// of the other package.
+package tree
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A Node is a node of a phylogenetic tree.
+type Node struct {
+	Anc     *Node   // Ancestor
+	Desc    []*Node // Descendants of the node
+	Name    string  // Terminal name (empty on internal nodes)
+	Label   string  // Label of an internal node (e.g. a support value)
+	Len     float64 // Length of the branch
+	Comment string  // A comment associated with the node
+}
+
+// A Tree is a phylogenetic tree.
+type Tree struct {
+	Name string // Name of the tree
+	Root *Node  // The root node
+	Lens bool   // True if the tree has branch lengths
+}
+
+// IsTerm returns true if the node is a terminal.
+func (n *Node) IsTerm() bool {
+	return len(n.Desc) == 0
+}
+
+// Add adds a descendant to the node.
+func (n *Node) Add(d *Node) {
+	d.Anc = n
+	n.Desc = append(n.Desc, d)
+}
+
+// Terms returns the names of the terminals
+// of the tree.
+func (t *Tree) Terms() []string {
+	return t.Root.Terms()
+}
+
+// Terms returns the names of the terminals
+// descendant from a node.
+func (n *Node) Terms() []string {
+	if n.IsTerm() {
+		return []string{n.Name}
+	}
+	var ls []string
+	for _, d := range n.Desc {
+		ls = append(ls, d.Terms()...)
+	}
+	return ls
+}
+
+// Nodes returns the list of nodes of the tree,
+// in pre-order.
+func (t *Tree) Nodes() []*Node {
+	var ls []*Node
+	t.PreOrder(func(n *Node) {
+		ls = append(ls, n)
+	})
+	return ls
+}
+
+// Write writes a tree into a io.Writer
+// in parenthetical format.
+func (t *Tree) Write(w io.Writer, comma bool) {
+	t.Root.write(w, comma, t.Lens, nil)
+	fmt.Fprintf(w, ";")
+}
+
+// Write writes a node into a io.Writer.
+// If ids is not nil,
+// terminals will be written
+// using the given identifiers.
+func (n *Node) write(w io.Writer, comma, lens bool, ids map[string]string) {
+	if n.IsTerm() {
+		if id, ok := ids[n.Name]; ok {
+			fmt.Fprintf(w, "%s", id)
+		} else {
+			fmt.Fprintf(w, "%s", quote(n.Name))
+		}
+	} else {
+		fmt.Fprintf(w, "(")
+		for i, d := range n.Desc {
+			if i > 0 {
+				if comma {
+					fmt.Fprintf(w, ",")
+				} else {
+					fmt.Fprintf(w, " ")
+				}
+			}
+			d.write(w, comma, lens, ids)
+		}
+		fmt.Fprintf(w, ")")
+		if n.Label != "" {
+			fmt.Fprintf(w, "%s", quote(n.Label))
+		}
+	}
+	if n.Comment != "" {
+		fmt.Fprintf(w, "[%s]", n.Comment)
+	}
+	if lens && n.Anc != nil {
+		fmt.Fprintf(w, ":%.6f", n.Len)
+	}
+}
+
+// Quote returns a name quoted,
+// if it contains reserved characters.
+func quote(name string) string {
+	if !strings.ContainsAny(name, " \t\n,;:()[]'") {
+		return name
+	}
+	return "'" + strings.Replace(name, "'", "''", -1) + "'"
+}
+
+// Expand replaces each terminal of the tree
+// that is the first element of a group
+// with a polytomy that includes
+// all the terminals of the group.
+func (t *Tree) Expand(groups [][]string) {
+	rep := make(map[string][]string, len(groups))
+	for _, g := range groups {
+		rep[g[0]] = g
+	}
+	for _, n := range t.Nodes() {
+		if !n.IsTerm() {
+			continue
+		}
+		g, ok := rep[n.Name]
+		if !ok {
+			continue
+		}
+		for _, nm := range g {
+			n.Add(&Node{Name: nm})
+		}
+		n.Name = ""
+	}
+}
+
+// IsBinary returns true
+// if all the internal nodes of the tree
+// have two descendants.
+func (t *Tree) IsBinary() bool {
+	for _, n := range t.Nodes() {
+		if !n.IsTerm() && len(n.Desc) != 2 {
+			return false
+		}
+	}
+	return true
+}
+
+// Resolve resolves the polytomies of the tree
+// at random,
+// joining two random descendants of a polytomy
+// in a new node,
+// until the node has two descendants.
+// The new nodes have a branch length of 0.
+func (t *Tree) Resolve() {
+	for _, n := range t.Nodes() {
+		for len(n.Desc) > 2 {
+			i := rand.Intn(len(n.Desc))
+			j := rand.Intn(len(n.Desc) - 1)
+			if j >= i {
+				j++
+			}
+			a, b := n.Desc[i], n.Desc[j]
+			n.remove(a)
+			n.remove(b)
+			nn := &Node{}
+			nn.Add(a)
+			nn.Add(b)
+			n.Add(nn)
+		}
+	}
+}
+
+// Age returns the maximum distance
+// from the root to a terminal,
+// i.e. the age of the root
+// in an ultrametric tree.
+func (t *Tree) Age() float64 {
+	max := float64(0)
+	for _, d := range t.tipDists() {
+		if d > max {
+			max = d
+		}
+	}
+	return max
+}
+
+// NodeAges returns the age of each node,
+// i.e. the age of the root
+// minus the distance from the root to the node.
+// In a non-ultrametric tree,
+// the age of the root
+// is the distance to the farthest terminal.
+func (t *Tree) NodeAges() map[*Node]float64 {
+	age := t.Age()
+	ages := t.rootDists()
+	for n, d := range ages {
+		ages[n] = age - d
+	}
+	return ages
+}
+
+// IsUltrametric returns true if all terminals
+// are at the same distance from the root,
+// with a given tolerance
+// (as a proportion of the root age).
+func (t *Tree) IsUltrametric(tol float64) bool {
+	age := t.Age()
+	for _, d := range t.tipDists() {
+		if age-d > tol*age {
+			return false
+		}
+	}
+	return true
+}
+
+// Scale multiplies all branch lengths
+// by a given factor.
+func (t *Tree) Scale(f float64) {
+	for _, n := range t.Nodes() {
+		n.Len *= f
+	}
+}
+
+// TipDists returns the distance
+// from the root to each terminal.
+func (t *Tree) tipDists() []float64 {
+	var ds []float64
+	dists := t.rootDists()
+	t.PreOrder(func(n *Node) {
+		if n.IsTerm() {
+			ds = append(ds, dists[n])
+		}
+	})
+	return ds
+}
+
+// RootDists returns the distance
+// from the root to each node.
+func (t *Tree) rootDists() map[*Node]float64 {
+	dists := make(map[*Node]float64)
+	t.PreOrder(func(n *Node) {
+		if n == t.Root {
+			dists[n] = 0
+			return
+		}
+		dists[n] = dists[n.Anc] + n.Len
+	})
+	return dists
+}
+
+// Prune removes the indicated terminals
+// from the tree.
+// Internal nodes left with a single descendant
+// are removed,
+// and its branch length is added
+// to the branch of its descendant.
+func (t *Tree) Prune(terms []string) {
+	del := make(map[string]bool, len(terms))
+	for _, nm := range terms {
+		del[nm] = true
+	}
+	t.Root = t.Root.prune(del)
+	if t.Root != nil {
+		t.Root.Anc = nil
+		t.Root.Len = 0
+	}
+}
+
+// Prune removes the indicated terminals
+// from a node and its descendants,
+// and returns the resulting node,
+// or nil if no terminal remains.
+func (n *Node) prune(del map[string]bool) *Node {
+	if n.IsTerm() {
+		if del[n.Name] {
+			return nil
+		}
+		return n
+	}
+	desc := n.Desc
+	n.Desc = nil
+	for _, d := range desc {
+		if d = d.prune(del); d != nil {
+			n.Add(d)
+		}
+	}
+	switch len(n.Desc) {
+	case 0:
+		return nil
+	case 1:
+		d := n.Desc[0]
+		d.Len += n.Len
+		d.Anc = n.Anc
+		return d
+	}
+	return n
+}
+
+// Reroot roots the tree
+// on the branch of a terminal,
+// i.e. the terminal will be the sister
+// of all the other terminals.
+// The whole length of the branch
+// is kept on the terminal.
+// Internal nodes left with a single descendant
+// are removed.
+func (t *Tree) Reroot(term string) error {
+	var x *Node
+	for _, n := range t.Nodes() {
+		if n.IsTerm() && n.Name == term {
+			x = n
+			break
+		}
+	}
+	if x == nil {
+		return errors.Errorf("tree: reroot: terminal %s not in tree", term)
+	}
+	p := x.Anc
+	if p == nil {
+		return errors.Errorf("tree: reroot: terminal %s is the root", term)
+	}
+	p.remove(x)
+	p.reverse()
+	p.Len = 0
+
+	root := &Node{}
+	root.Add(x)
+	root.Add(p)
+	t.Root = root.prune(nil)
+	t.Root.Anc = nil
+	t.Root.Len = 0
+	return nil
+}
+
+// Remove removes a descendant of a node.
+func (n *Node) remove(d *Node) {
+	for i, c := range n.Desc {
+		if c == d {
+			n.Desc = append(n.Desc[:i], n.Desc[i+1:]...)
+			break
+		}
+	}
+	d.Anc = nil
+}
+
+// Reverse makes a node the root
+// of its tree,
+// by reversing the path to the root.
+func (n *Node) reverse() {
+	a := n.Anc
+	if a == nil {
+		return
+	}
+	a.reverse()
+	a.remove(n)
+	a.Len = n.Len
+	n.Add(a)
+	n.Len = 0
+}
+
+// Sister returns the terminals
+// of the sister group of a terminal,
+// sorted by name.
+// It returns nil
+// if the terminal is not in the tree,
+// or it is the root.
+func (t *Tree) Sister(term string) []string {
+	for _, n := range t.Nodes() {
+		if !n.IsTerm() || n.Name != term {
+			continue
+		}
+		if n.Anc == nil {
+			return nil
+		}
+		var ls []string
+		for _, d := range n.Anc.Desc {
+			if d != n {
+				ls = append(ls, d.Terms()...)
+			}
+		}
+		sort.Strings(ls)
+		return ls
+	}
+	return nil
+}
+
+// Clades returns the terminals of each clade
+// of the tree,
+// i.e. each internal node,
+// except the root,
+// with the terminals sorted by name.
+func (t *Tree) Clades() [][]string {
+	var cl [][]string
+	for _, n := range t.Nodes() {
+		if n.IsTerm() || n == t.Root {
+			continue
+		}
+		terms := n.Terms()
+		sort.Strings(terms)
+		cl = append(cl, terms)
+	}
+	return cl
+}
+
+// HasClade returns true
+// if the tree has a clade
+// with exactly the given terminals.
+func (t *Tree) HasClade(terms []string) bool {
+	in := make(map[string]bool, len(terms))
+	for _, tm := range terms {
+		in[tm] = true
+	}
+	var found bool
+	var count func(n *Node) (int, bool)
+	count = func(n *Node) (int, bool) {
+		if n.IsTerm() {
+			return 1, in[n.Name]
+		}
+		c, all := 0, true
+		for _, d := range n.Desc {
+			dc, da := count(d)
+			c += dc
+			all = all && da
+		}
+		if all && c == len(in) && n != t.Root {
+			found = true
+		}
+		return c, all
+	}
+	count(t.Root)
+	return found
+}
+
+// HasSplit returns true
+// if the tree has a branch
+// that splits the given terminals
+// from the other terminals of the tree,
+// ignoring the root.
+// Terminals not in the tree are ignored.
+// If the split is not informative
+// (i.e. there are less than two terminals
+// in any side of the split)
+// informative is false.
+func (t *Tree) HasSplit(terms []string) (ok, informative bool) {
+	want := make(map[string]bool, len(terms))
+	for _, tm := range terms {
+		want[tm] = true
+	}
+	var in, out []string
+	for _, tm := range t.Terms() {
+		if want[tm] {
+			in = append(in, tm)
+			continue
+		}
+		out = append(out, tm)
+	}
+	if len(in) < 2 || len(out) < 2 {
+		return false, false
+	}
+	return t.HasClade(in) || t.HasClade(out), true
+}
+
+// Splits returns the informative splits
+// of the tree,
+// ignoring the root.
+// Each split is given by the terminals
+// of the side of the split
+// that does not include the first terminal
+// of the tree
+// (in alphabetical order),
+// sorted by name.
+func (t *Tree) Splits() [][]string {
+	terms := t.Terms()
+	if len(terms) < 4 {
+		return nil
+	}
+	sort.Strings(terms)
+	first := terms[0]
+
+	var sp [][]string
+	seen := make(map[string]bool)
+	for _, n := range t.Nodes() {
+		if n.IsTerm() || n == t.Root {
+			continue
+		}
+		side := n.Terms()
+		if len(side) < 2 || len(side) > len(terms)-2 {
+			continue
+		}
+		in := make(map[string]bool, len(side))
+		for _, tm := range side {
+			in[tm] = true
+		}
+		if in[first] {
+			var out []string
+			for _, tm := range terms {
+				if !in[tm] {
+					out = append(out, tm)
+				}
+			}
+			side = out
+		}
+		sort.Strings(side)
+		key := strings.Join(side, " ")
+		if seen[key] {
+			// the two sides of a rooted tree
+			continue
+		}
+		seen[key] = true
+		sp = append(sp, side)
+	}
+	return sp
+}
+
+// RF returns the Robinson-Foulds distance
+// between two trees,
+// i.e. the number of splits
+// found in only one of the trees.
+// Both trees must have the same terminals.
+func RF(a, b *Tree) (int, error) {
+	ta, tb := a.Terms(), b.Terms()
+	if len(ta) != len(tb) {
+		return 0, errors.Errorf("tree: rf: trees with %d and %d terminals", len(ta), len(tb))
+	}
+	in := make(map[string]bool, len(ta))
+	for _, tm := range ta {
+		in[tm] = true
+	}
+	for _, tm := range tb {
+		if !in[tm] {
+			return 0, errors.Errorf("tree: rf: terminal %s not in both trees", tm)
+		}
+	}
+
+	sa := make(map[string]bool)
+	for _, s := range a.Splits() {
+		sa[strings.Join(s, " ")] = true
+	}
+	d := len(sa)
+	for _, s := range b.Splits() {
+		if sa[strings.Join(s, " ")] {
+			d--
+			continue
+		}
+		d++
+	}
+	return d, nil
+}
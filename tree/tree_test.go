@@ -0,0 +1,183 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package tree
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestUltrametric(t *testing.T) {
+	tr, err := Read(strings.NewReader("((A:1,B:1):2,(C:2,D:2):1);"))
+	if err != nil {
+		t.Fatalf("tree: ultrametric: unexpected error: %v", err)
+	}
+	if !tr.IsUltrametric(0.001) {
+		t.Errorf("tree: ultrametric: tree is not ultrametric")
+	}
+	if a := tr.Age(); math.Abs(a-3) > 0.000001 {
+		t.Errorf("tree: ultrametric: age %.6f, want %.6f", a, 3.0)
+	}
+	ages := tr.NodeAges()
+	if a := ages[tr.Root.Desc[1]]; math.Abs(a-2) > 0.000001 {
+		t.Errorf("tree: ultrametric: node age %.6f, want %.6f", a, 2.0)
+	}
+	if a := ages[tr.Root.Desc[1].Desc[0]]; math.Abs(a) > 0.000001 {
+		t.Errorf("tree: ultrametric: terminal age %.6f, want %.6f", a, 0.0)
+	}
+	tr.Scale(2)
+	if a := tr.Age(); math.Abs(a-6) > 0.000001 {
+		t.Errorf("tree: ultrametric: scaled age %.6f, want %.6f", a, 6.0)
+	}
+
+	tr, _ = Read(strings.NewReader("((A:1,B:2):2,(C:2,D:2):1);"))
+	if tr.IsUltrametric(0.001) {
+		t.Errorf("tree: ultrametric: tree is ultrametric")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	tr, err := Read(strings.NewReader("(A:1,(B:1,(C:1,(D:1,E:1):2):1):1);"))
+	if err != nil {
+		t.Fatalf("tree: prune: unexpected error: %v", err)
+	}
+	if s := strings.Join(tr.Sister("C"), " "); s != "D E" {
+		t.Errorf("tree: sister: %q, want %q", s, "D E")
+	}
+	cl := tr.Clades()
+	if len(cl) != 3 {
+		t.Errorf("tree: clades: %d clades, want %d", len(cl), 3)
+	}
+	if s := strings.Join(cl[len(cl)-1], " "); s != "D E" {
+		t.Errorf("tree: clades: last clade %q, want %q", s, "D E")
+	}
+	if !tr.HasClade([]string{"E", "C", "D"}) {
+		t.Errorf("tree: has clade: clade %q not found", "C D E")
+	}
+	for _, cl := range [][]string{{"B", "D"}, {"D"}, {"A", "B", "C", "D", "E"}} {
+		if tr.HasClade(cl) {
+			t.Errorf("tree: has clade: clade %q found", strings.Join(cl, " "))
+		}
+	}
+
+	tr.Prune([]string{"C", "A"})
+	var b bytes.Buffer
+	tr.Write(&b, true)
+	if want := "(B:1.000000,(D:1.000000,E:1.000000):3.000000);"; b.String() != want {
+		t.Errorf("tree: prune: %s, want %s", b.String(), want)
+	}
+	if s := strings.Join(tr.Sister("B"), " "); s != "D E" {
+		t.Errorf("tree: sister: %q, want %q", s, "D E")
+	}
+}
+
+func TestReroot(t *testing.T) {
+	tr, err := Read(strings.NewReader("(A:1,(B:1,(C:1,(D:1,E:1):2):1):1);"))
+	if err != nil {
+		t.Fatalf("tree: reroot: unexpected error: %v", err)
+	}
+	if err := tr.Reroot("D"); err != nil {
+		t.Fatalf("tree: reroot: unexpected error: %v", err)
+	}
+	var b bytes.Buffer
+	tr.Write(&b, true)
+	if want := "(D:1.000000,(E:1.000000,(C:1.000000,(B:1.000000,A:2.000000):1.000000):2.000000):0.000000);"; b.String() != want {
+		t.Errorf("tree: reroot: %s, want %s", b.String(), want)
+	}
+	if err := tr.Reroot("X"); err == nil {
+		t.Errorf("tree: reroot: expecting error for terminal %s", "X")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tr, err := Read(strings.NewReader("(A:1,(B:1,C:1,D:1,E:1):1,F:1);"))
+	if err != nil {
+		t.Fatalf("tree: resolve: unexpected error: %v", err)
+	}
+	if tr.IsBinary() {
+		t.Errorf("tree: resolve: polytomic tree is binary")
+	}
+	tr.Resolve()
+	if !tr.IsBinary() {
+		t.Errorf("tree: resolve: resolved tree is not binary")
+	}
+	if s := strings.Join(tr.Terms(), " "); len(tr.Terms()) != 6 {
+		t.Errorf("tree: resolve: terminals %q", s)
+	}
+	if n := len(tr.Clades()); n != 4 {
+		t.Errorf("tree: resolve: %d clades, want %d", n, 4)
+	}
+	if !tr.HasClade([]string{"B", "C", "D", "E"}) {
+		t.Errorf("tree: resolve: clade B C D E not found")
+	}
+	if a := tr.Age(); math.Abs(a-2) > 0.000001 {
+		t.Errorf("tree: resolve: age %.6f, want %.6f", a, 2.0)
+	}
+}
+
+func TestHasSplit(t *testing.T) {
+	tr, err := Read(strings.NewReader("(A,((B,C),(D,E)));"))
+	if err != nil {
+		t.Fatalf("tree: split: unexpected error: %v", err)
+	}
+	tests := []struct {
+		terms       string
+		ok          bool
+		informative bool
+	}{
+		{"B C", true, true},
+		{"A D E", true, true},
+		{"B D", false, true},
+		{"B C X", true, true},
+		{"D E Y Z", true, true},
+		{"A X", false, false},
+		{"B C D E", false, false},
+	}
+	for _, test := range tests {
+		ok, inf := tr.HasSplit(strings.Fields(test.terms))
+		if ok != test.ok || inf != test.informative {
+			t.Errorf("tree: split: %s: got %v %v, want %v %v", test.terms, ok, inf, test.ok, test.informative)
+		}
+	}
+}
+
+func TestRF(t *testing.T) {
+	a, err := Read(strings.NewReader("(A,((B,C),(D,(E,F))));"))
+	if err != nil {
+		t.Fatalf("tree: rf: unexpected error: %v", err)
+	}
+	if sp := a.Splits(); len(sp) != 3 {
+		t.Errorf("tree: rf: %d splits, want %d", len(sp), 3)
+	}
+	tests := []struct {
+		tree string
+		rf   int
+	}{
+		// same tree, with a different root
+		{"((B,C),(A,(D,(E,F))));", 0},
+		{"(A,((B,D),(C,(E,F))));", 4},
+		{"(A,(B,C,D,E,F));", 3},
+	}
+	for _, test := range tests {
+		b, err := Read(strings.NewReader(test.tree))
+		if err != nil {
+			t.Fatalf("tree: rf: unexpected error: %v", err)
+		}
+		rf, err := RF(a, b)
+		if err != nil {
+			t.Fatalf("tree: rf: unexpected error: %v", err)
+		}
+		if rf != test.rf {
+			t.Errorf("tree: rf: %s: distance %d, want %d", test.tree, rf, test.rf)
+		}
+	}
+
+	b, _ := Read(strings.NewReader("(A,((B,C),(D,(E,X))));"))
+	if _, err := RF(a, b); err == nil {
+		t.Errorf("tree: rf: expecting error with different terminals")
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package tree
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+)
+
+// A Writer writes trees into a NEXUS trees block.
+//
+// To keep the output compact,
+// terminals are written using the numeric
+// identifiers of a translation table.
+// As trees are written as soon as they are given,
+// a Writer can be used to store large
+// tree collections.
+type Writer struct {
+	w     *bufio.Writer
+	c     io.Closer // file created by Create
+	ids   map[string]string
+	count int
+	err   error
+
+	closed bool
+}
+
+// NewWriter returns a new tree writer
+// that writes into w,
+// using the indicated terminal names
+// for the translation table.
+func NewWriter(w io.Writer, names []string) *Writer {
+	ls := append([]string{}, names...)
+	sort.Strings(ls)
+	tw := &Writer{
+		w:   bufio.NewWriter(w),
+		ids: make(map[string]string, len(ls)),
+	}
+	fmt.Fprintf(tw.w, "#NEXUS\n\nbegin trees;\n\ttranslate\n")
+	for i, nm := range ls {
+		id := strconv.Itoa(i + 1)
+		tw.ids[nm] = id
+		end := ","
+		if i == len(ls)-1 {
+			end = ";"
+		}
+		fmt.Fprintf(tw.w, "\t\t%s %s%s\n", id, quote(nm), end)
+	}
+	return tw
+}
+
+// Create creates a file
+// and returns a new tree writer
+// that writes into it.
+// The file is closed
+// when the writer is closed.
+func Create(name string, names []string) (*Writer, error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, errors.Wrap(err, "tree: writer")
+	}
+	tw := NewWriter(f, names)
+	tw.c = f
+	if err := tw.w.Flush(); err != nil {
+		f.Close()
+		return nil, errors.Wrap(err, "tree: writer")
+	}
+	return tw, nil
+}
+
+// Write writes a tree.
+// All the terminals of the tree
+// must be in the translation table.
+func (tw *Writer) Write(t *Tree) error {
+	if tw.err != nil {
+		return tw.err
+	}
+	if tw.closed {
+		return errors.New("tree: writer: write on a closed writer")
+	}
+	for _, nm := range t.Terms() {
+		if _, ok := tw.ids[nm]; !ok {
+			return errors.Errorf("tree: writer: terminal %s not in translation table", nm)
+		}
+	}
+	tw.count++
+	name := t.Name
+	if name == "" {
+		name = fmt.Sprintf("tree%d", tw.count)
+	}
+	fmt.Fprintf(tw.w, "\ttree %s = ", quote(name))
+	t.Root.write(tw.w, true, t.Lens, tw.ids)
+	fmt.Fprintf(tw.w, ";\n")
+	if err := tw.w.Flush(); err != nil {
+		tw.err = errors.Wrap(err, "tree: writer")
+	}
+	return tw.err
+}
+
+// Comment writes a comment
+// in the trees block.
+func (tw *Writer) Comment(c string) error {
+	if tw.err != nil {
+		return tw.err
+	}
+	if tw.closed {
+		return errors.New("tree: writer: write on a closed writer")
+	}
+	fmt.Fprintf(tw.w, "\t[%s]\n", c)
+	if err := tw.w.Flush(); err != nil {
+		tw.err = errors.Wrap(err, "tree: writer")
+	}
+	return tw.err
+}
+
+// Close closes the trees block.
+// It does not close the underlying writer,
+// unless the writer was created with Create.
+func (tw *Writer) Close() error {
+	if tw.closed {
+		return tw.err
+	}
+	tw.closed = true
+	if tw.err == nil {
+		fmt.Fprintf(tw.w, "end;\n")
+		if err := tw.w.Flush(); err != nil {
+			tw.err = errors.Wrap(err, "tree: writer")
+		}
+	}
+	if tw.c != nil {
+		if err := tw.c.Close(); err != nil && tw.err == nil {
+			tw.err = errors.Wrap(err, "tree: writer")
+		}
+	}
+	return tw.err
+}
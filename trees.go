@@ -0,0 +1,22 @@
+// Copyright (c) 2018, J. Salvador Arias <jsalarias@csnat.unt.edu.ar>
+// All rights reserved.
+// Distributed under BSD2 license that can be found in the LICENSE file.
+
+package main
+
+import (
+	// initialize tree sub-commands
+	_ "github.com/js-arias/ramita/internal/tree/annot"
+	_ "github.com/js-arias/ramita/internal/tree/chrono"
+	_ "github.com/js-arias/ramita/internal/tree/divers"
+	_ "github.com/js-arias/ramita/internal/tree/graft"
+	_ "github.com/js-arias/ramita/internal/tree/label"
+	_ "github.com/js-arias/ramita/internal/tree/merge"
+	_ "github.com/js-arias/ramita/internal/tree/mono"
+	_ "github.com/js-arias/ramita/internal/tree/reconcile"
+	_ "github.com/js-arias/ramita/internal/tree/recons"
+	_ "github.com/js-arias/ramita/internal/tree/species"
+	_ "github.com/js-arias/ramita/internal/tree/subtree"
+	_ "github.com/js-arias/ramita/internal/tree/sumt"
+	_ "github.com/js-arias/ramita/internal/tree/super"
+)